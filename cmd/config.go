@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the commands that read and change the user
+// configuration.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set user defaults in ~/.smurf/config.yaml",
+	Long: `The user configuration, ~/.smurf/config.yaml (under $SMURF_HOME when set),
+holds defaults that don't belong in a project's smurf.yaml.
+
+Its sdkr, selm, stf, deploy and timeouts sections take the keys of smurf.yaml
+and are merged beneath the smurf.yaml of every project: a key the project
+sets wins, sections are merged key by key, lists are replaced whole. Use them
+for preferred registries and regions, a default namespace, credentials
+referenced as ${VAR}, or your own timeouts.
+
+The other keys are per-user settings:
+
+  ai.provider   AI provider --ai explains errors with (openai)
+  ai.model      model it uses, unless OPENAI_MODEL is set
+  color         auto (only on a terminal), always or never
+  pluginPaths   Helm plugin directories, unless HELM_PLUGINS is set
+  telemetry     opt in to anonymous usage reporting (smurf reports nothing
+                today; off by default)
+
+Keys are dotted paths, such as selm.namespace.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:          "get [KEY]",
+	Short:        "Print a key of the user configuration, or all of it",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := ""
+		if len(args) == 1 {
+			key = args[0]
+		}
+		value, ok, err := configs.UserConfigValue(key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if key == "" {
+				path, _ := configs.UserConfigPath()
+				pterm.Info.Printfln("No user configuration in %s", path)
+				return nil
+			}
+			return fmt.Errorf("%s is not set", key)
+		}
+		fmt.Println(value)
+		return nil
+	},
+	Example: `
+  # The whole user configuration
+  smurf config get
+
+  # The default namespace
+  smurf config get selm.namespace
+`,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Set a key of the user configuration",
+	Long: `Set KEY to VALUE in the user configuration, creating it when needed. VALUE
+is read as YAML, so true is a boolean and "[a, b]" a list. Unknown keys and
+invalid values are rejected, and the rest of the file, comments included, is
+kept. The file is only readable by you, since it may hold credentials.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := configs.SetUserConfigValue(args[0], args[1]); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Set %s", args[0])
+		return nil
+	},
+	Example: `
+  # Deploy to the staging namespace unless smurf.yaml says otherwise
+  smurf config set selm.namespace staging
+
+  # Push to ECR in eu-west-1 by default
+  smurf config set sdkr.awsECR true
+  smurf config set sdkr.awsRegion eu-west-1
+
+  # Plain output, and a cheaper model for --ai
+  smurf config set color never
+  smurf config set ai.model gpt-4o-mini
+`,
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:          "unset KEY",
+	Short:        "Remove a key from the user configuration",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := configs.UnsetUserConfigValue(args[0]); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Unset %s", args[0])
+		return nil
+	},
+	Example: `
+  smurf config unset selm.namespace
+`,
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd)
+	RootCmd.AddCommand(configCmd)
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -10,8 +12,10 @@ import (
 	"time"
 
 	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ci"
 	"github.com/clouddrove/smurf/internal/docker"
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/notify"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -20,53 +24,290 @@ var deployCmd = &cobra.Command{
 	Use:   "deploy",
 	Short: "Deploy builds and pushes Docker image as per smurf.yaml, then optionally runs Helm deploy.",
 	Long: `Deploy reads smurf.yaml and runs the full pipeline: build the Docker image,
-push it to whichever registry is enabled (awsECR, dockerHub, ghcrRepo, or gcpRepo),
+push it to whichever registry is enabled (awsECR, dockerHub, ghcrRepo, gcpRepo,
+or azureACR),
 and then, if selm.deployHelm is true, install or upgrade the Helm release with the
 new image repository and tag.
 
-Use --timeout to control how long the push and Helm operations are allowed to run.`,
+Timeouts for each phase (build, push, helmWait, readiness) come from the
+timeouts section of smurf.yaml, falling back to built-in defaults. Use
+--timeout to override both the push and the Helm timeouts for a single run.
+
+When selm.cluster names a managed cluster (EKS, GKE or AKS), its credentials
+are fetched and it becomes the current kubeconfig context before anything
+else runs, as with 'smurf selm connect'.
+
+With selm.imagePullSecret (or --image-pull-secret), the helm phase creates or
+updates a dockerconfigjson Secret of that name in the release namespace from
+the credentials the image is pushed with, and sets imagePullSecrets[0].name to
+it, so the cluster can pull from a private registry.
+
+Before a release is deployed, the CPU and memory its rendered workloads
+request are compared with what is free on the ready nodes and in the
+namespace's ResourceQuotas; deploy warns when they don't fit, or fails with
+--capacity-check=fail (deploy.capacityCheck).
+
+selm.valuesFrom (and --values-from) merge YAML values stored in ConfigMaps or
+Secrets in the cluster into the release, after the values file. A
+smurf-defaults ConfigMap in the release namespace supplies default values
+under its values.yaml key, below everything else (--no-namespace-defaults
+ignores it).
+
+selm.releases lists several releases to deploy instead of one. Each release
+is installed or upgraded after the releases in its dependsOn, and deploy waits
+for a release's workloads to be ready before deploying its dependents. Only
+releases with image: true get the pushed image. 'smurf deploy destroy'
+uninstalls them in reverse order.
+
+When selm.deployHelm is true, every run is recorded in the release's deploy
+ledger in the cluster; see 'smurf deploy history'.
+
+deploy.notifications sends a message to Slack, a webhook or email when a run
+starts, succeeds or fails, rendered from a Go template per event over the
+run's image, environment, actor, releases, durations and links.
+
+The pipeline runs in three phases: build, push and helm. --only and --skip
+select a subset, so a failed phase can be re-run on its own. Each run writes
+the outcome of its phases and the pushed image (repository, tag, digest) to a
+run report (.smurf/deploy-report.json by default); a run that skips the push
+phase deploys the image recorded there.
+
+A run interrupted with Ctrl-C or SIGTERM saves a resume token in the run
+report. --resume runs the phases it had left, skipping the releases it had
+deployed; --abort instead cleans up the release it was deploying (a pending
+install is uninstalled, a pending upgrade rolled back).
+
+With --rollback-on-failure (deploy.rollbackOnFailure), a run whose helm
+phase fails is undone: the values files it wrote the new image to are
+restored, and every release it deployed is rolled back to the revision it
+had before the run, or uninstalled when the run installed it.
+--delete-pushed-tag (deploy.deletePushedTag) also deletes the tag the run
+pushed from the registry, unless it has been pushed again since.
+
+--dry-run prints what the selected phases would do instead: the image that
+would be built (context, Dockerfile, tag) and the registry it would be pushed
+to, the change to each values file the image would be written to, and the
+diff of each release against its deployed revision. Nothing is built, pushed,
+written or deployed; the cluster is only read to compute the diffs.`,
 	SilenceUsage: true,
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		cfg, err := configs.LoadConfig(configs.FileName)
+		if err != nil {
+			return err
+		}
+
+		configs.Timeouts = cfg.Timeouts.WithDefaults(configs.DefaultTimeouts)
+		if cmd.Flags().Changed("timeout") {
+			configs.Timeouts.Push = deployTimeout
+			configs.Timeouts.HelmWait = deployTimeout
+		}
 		// configs.Timeout is a shared global also bound by selm's install,
 		// rollback, and upgrade flags. Each of those flag registrations
 		// writes its own default into configs.Timeout at init() time (last
 		// one registered wins), so deploy cannot bind --timeout directly to
 		// configs.Timeout without its default being clobbered by another
-		// package's init() order. Instead deploy owns its own local flag var
-		// and assigns it into configs.Timeout here, at RunE time, so the
+		// package's init() order. Instead deploy resolves the Helm wait
+		// timeout from its policy and assigns it here, at RunE time, so the
 		// value seen downstream is always the one deploy intended.
-		configs.Timeout = deployTimeout
+		configs.Timeout = configs.Timeouts.HelmWait
+		configs.Interpolate = cfg.Selm.Interpolate
+		configs.RedactKeys = cfg.Selm.RedactKeys
+		configs.ValuesFrom = append(cfg.Selm.ValuesFrom, deployValuesFrom...)
+		configs.DependencyPaths = cfg.Selm.DependencyPaths
+		configs.Scheduling = cfg.Selm.Scheduling
+		configs.ReleaseBackup = cfg.Selm.Backup
+		configs.ValuesSchema = cfg.Selm.ValuesSchema
+		if err := cfg.Selm.ListMerge.Validate(); err != nil {
+			return err
+		}
+		configs.ListMergePaths = cfg.Selm.ListMerge
+		healthChecks, err := deployHealthChecks()
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("capacity-check") {
+			cfg.Deploy.CapacityCheck = deployCapacityCheck
+			if err := cfg.Deploy.Validate(); err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Changed("rollback-on-failure") {
+			cfg.Deploy.RollbackOnFailure = deployRollbackOnFailure
+		}
+		if cmd.Flags().Changed("delete-pushed-tag") {
+			cfg.Deploy.DeletePushedTag = deployDeletePushedTag
+		}
+		deployUndo = nil
+		if cfg.Deploy.RollbackOnFailure || cfg.Deploy.DeletePushedTag {
+			deployUndo = &undoLog{}
+		}
+		if !cmd.Flags().Changed("builder") && cfg.Sdkr.Builder != "" {
+			deployBuilder = cfg.Sdkr.Builder
+		}
+		if err := docker.ValidBuilder(deployBuilder); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("cache-from") {
+			configs.CacheFrom = cfg.Sdkr.CacheFrom
+		}
+		if !cmd.Flags().Changed("cache-to") {
+			configs.CacheTo = cfg.Sdkr.CacheTo
+		}
+		if configs.RemoteBuild != "" {
+			if err := docker.ValidRemoteBuild(configs.RemoteBuild); err != nil {
+				return err
+			}
+			if deployBuilder == docker.BuilderContainerd {
+				return fmt.Errorf("--remote-build builds with buildkitd; it can't be combined with the containerd builder")
+			}
+			// The image lands in smurf's image store and is pushed from there.
+			deployBuilder = docker.BuilderBuildkitd
+		}
+		configs.RequirePinnedBases = cfg.Sdkr.RequirePinnedBases
+		if err := docker.ValidScanSeverity(configs.ScanFailOn); err != nil {
+			return err
+		}
+		if configs.ScanFailOnNewCritical {
+			configs.EcrScanFindings = true
+		}
 
-		cfg, err := configs.LoadConfig(configs.FileName)
+		previous, err := loadDeployReport(deployReportPath)
+		if err != nil {
+			return err
+		}
+		if deployAbort {
+			return abortDeploy(cfg, previous)
+		}
+
+		var phases map[string]bool
+		if deployResume {
+			phases, err = resumePhases(previous)
+		} else {
+			phases, err = selectDeployPhases(deployOnly, deploySkip)
+		}
 		if err != nil {
 			return err
 		}
+		if deployResume {
+			resumeFrom = previous.Resume
+		}
+		deployPhases = phases
+		runsImagePhases := deployPhases[phaseBuild] || deployPhases[phasePush]
+		runsHelm := deployPhases[phaseHelm] && cfg.Selm.HelmDeploy
 
-		if cfg.Sdkr.ImageName == "" {
+		if runsImagePhases && cfg.Sdkr.ImageName == "" {
 			return fmt.Errorf("no image name provided in smurf.yaml or CLI argument")
 		}
 
-		var imageRepo, imageTag string
+		notifier, err := notify.New(cfg.Deploy.Notifications)
+		if err != nil {
+			return err
+		}
 
-		switch {
-		case cfg.Sdkr.AwsECR:
-			imageRepo, imageTag, err = handleECRPush(cfg)
-		case cfg.Sdkr.DockerHub:
-			imageRepo, imageTag, err = handleDockerHubPush(cfg)
-		case cfg.Sdkr.GHCRRepo:
-			imageRepo, imageTag, err = handleGHCRPush(cfg)
-		case cfg.Sdkr.GCPRepo:
-			imageRepo, imageTag, err = handleGCPPush(cfg)
-		default:
-			pterm.Warning.Println("No registry selected (awsECR/dockerHub/ghcrRepo/gcpRepo). Skipping image push.")
+		if deployDryRun {
+			return planDeploy(cmd, cfg, previous, runsImagePhases, runsHelm)
 		}
 
-		if err != nil {
+		deployRun = newDeployReport(previous)
+		deployRun.resetPhases(deployPhases)
+		defer func() {
+			if saveErr := deployRun.save(deployReportPath, err); saveErr != nil {
+				pterm.Warning.Printfln("Could not write deploy run report %s: %v", deployReportPath, saveErr)
+			}
+		}()
+		defer handleDeployInterrupt(deployReportPath)()
+		defer func() { recordDeployDurations(cfg, err) }()
+
+		notification := newDeployNotification(cfg, runsImagePhases, runsHelm)
+		if err := notifier.Notify(notification.event(cfg, notify.Started, nil)); err != nil {
 			return err
 		}
+		defer func() {
+			event := notify.Succeeded
+			if err != nil {
+				event = notify.Failed
+			}
+			if notifyErr := notifier.Notify(notification.event(cfg, event, err)); notifyErr != nil && err == nil {
+				err = notifyErr
+			}
+		}()
 
-		if cfg.Selm.HelmDeploy {
-			if err := handleHelmDeploy(cfg, imageRepo, imageTag); err != nil {
+		// Resolve the target cluster before the (slow) build, so a cluster
+		// that can't be reached fails the run early.
+		if runsHelm && cfg.Selm.Cluster.Name != "" {
+			_, namespace := helmDeployTarget(cfg.Selm)
+			if _, err := helm.ConnectCluster(cfg.Selm.Cluster, namespace); err != nil {
+				return err
+			}
+		}
+
+		var imageRepo, imageTag string
+
+		if runsHelm && !deployNoHistory {
+			start := time.Now()
+			defer func() {
+				recordDeployRun(cfg, imageRepo, imageTag, start, err)
+			}()
+		}
+
+		if runsImagePhases {
+			endSection := ci.Section("deploy_image", "Build and push the image", true)
+			start := time.Now()
+			switch {
+			case cfg.Sdkr.AwsECR:
+				imageRepo, imageTag, err = handleECRPush(cfg)
+			case cfg.Sdkr.DockerHub:
+				imageRepo, imageTag, err = handleDockerHubPush(cfg)
+			case cfg.Sdkr.GHCRRepo:
+				imageRepo, imageTag, err = handleGHCRPush(cfg)
+			case cfg.Sdkr.GCPRepo:
+				imageRepo, imageTag, err = handleGCPPush(cfg)
+			case cfg.Sdkr.AzureACR:
+				imageRepo, imageTag, err = handleACRPush(cfg)
+			default:
+				pterm.Warning.Println("No registry selected (awsECR/dockerHub/ghcrRepo/gcpRepo/azureACR). Skipping image push.")
+			}
+			deployRun.finishImagePhases(start, err)
+			endSection()
+			if err != nil {
+				return err
+			}
+		}
+
+		if deployPhases[phasePush] {
+			if imageRepo != "" {
+				deployRun.setImage(deployReportImage{Repository: imageRepo, Tag: imageTag, Digest: pushedDigest})
+				if cfg.Deploy.DeletePushedTag {
+					deployUndo.savePushedImage(imageRepo+":"+imageTag, pushedDigest)
+				}
+				if err := afterImagePush(cfg, imageRepo+":"+imageTag); err != nil {
+					return err
+				}
+			}
+		} else if runsHelm {
+			// Deploy the image pushed by an earlier run.
+			imageRepo, imageTag, pushedDigest = deployRun.Image.Repository, deployRun.Image.Tag, deployRun.Image.Digest
+			if imageRepo != "" {
+				pterm.Info.Printf("📄 Using image %s:%s from the run report %s\n", imageRepo, imageTag, deployReportPath)
+			} else {
+				pterm.Warning.Printfln("No pushed image in the run report %s; the image in the values file is left as is.", deployReportPath)
+			}
+		}
+
+		if runsHelm {
+			endSection := ci.Section("deploy_helm", "Deploy the Helm release", true)
+			start := time.Now()
+			var pullSecret string
+			pullSecret, err = ensureDeployPullSecret(cmd, cfg, imageRepo, imageTag)
+			if err == nil {
+				err = handleHelmDeploy(cfg, imageRepo, imageTag, pullSecret, healthChecks)
+			}
+			if err != nil && deployUndo != nil {
+				err = deployUndo.rollback(err)
+			}
+			deployRun.finishPhase(phaseHelm, start, err)
+			endSection()
+			if err != nil {
 				return err
 			}
 		}
@@ -77,8 +318,40 @@ Use --timeout to control how long the push and Helm operations are allowed to ru
   # Run the full build, push, and Helm deploy pipeline using smurf.yaml
   smurf deploy
 
-  # Override the timeout for push and Helm operations (in seconds)
+  # Override the push and Helm timeouts from smurf.yaml (in seconds)
   smurf deploy --timeout 900
+
+  # Show the deploy timeline recorded for the release
+  smurf deploy history
+
+  # Build and push only, then deploy the pushed image in a later step
+  smurf deploy --only build,push
+  smurf deploy --only helm
+
+  # Re-run everything but the build, pushing the image already built locally
+  smurf deploy --skip build
+
+  # Continue a deploy that was interrupted, or clean up after it
+  smurf deploy --resume
+  smurf deploy --abort
+
+  # Preview the image, the values file changes and the release diffs
+  smurf deploy --dry-run
+
+  # Undo the whole run, pushed tag included, when a release fails to deploy
+  smurf deploy --rollback-on-failure --delete-pushed-tag
+
+  # Roll back when the app doesn't answer its health endpoint
+  smurf deploy --health-url https://my-app.example.com/healthz --rollback-on-unhealthy
+
+  # Let the cluster pull from the private registry with the push credentials
+  smurf deploy --image-pull-secret smurf-regcred
+
+  # Record the pushed image, its SBOM and scan report in one manifest
+  smurf deploy --artifacts-manifest dist/artifacts.json --sbom dist/sbom.spdx.json --scan-report dist/trivy.json
+
+  # Block CRITICAL vulnerabilities the previously deployed image didn't have
+  smurf deploy --fail-on-new-critical
 `,
 }
 
@@ -88,17 +361,190 @@ Use --timeout to control how long the push and Helm operations are allowed to ru
 // commands that bind the same shared global to their own --timeout flags.
 var deployTimeout int
 
+// deployOnly, deploySkip and deployReportPath select the phases of a run and
+// where the run report that carries their outputs between runs is kept.
+var (
+	deployOnly       []string
+	deploySkip       []string
+	deployReportPath string
+)
+
+// deployBuilder is the image builder, docker unless --builder or
+// sdkr.builder selects a daemonless one.
+var deployBuilder string
+
+// deployPullSecret overrides selm.imagePullSecret for this run.
+var deployPullSecret string
+
+// deployValuesFrom adds in-cluster values sources to selm.valuesFrom.
+var deployValuesFrom []string
+
+// deployHealthURLs add health checks to the release that gets the pushed
+// image, and deployRollbackOnUnhealthy rolls a release back when its health
+// checks don't pass.
+var (
+	deployHealthURLs          []string
+	deployRollbackOnUnhealthy bool
+)
+
+// deployCapacityCheck overrides deploy.capacityCheck for this run.
+var deployCapacityCheck string
+
+// deployNoHistory disables writing the run to the release's deploy ledger.
+var deployNoHistory bool
+
+// deployArtifacts configures the optional artifacts manifest written after
+// the image push.
+var deployArtifacts docker.ArtifactsOptions
+
+// deployWebhooks and deployWebhookSecret add webhooks to sdkr.webhooks for
+// the image pushed by this run.
+var (
+	deployWebhooks      []string
+	deployWebhookSecret string
+)
+
 func init() {
-	deployCmd.Flags().IntVar(&deployTimeout, "timeout", 600, "Timeout in seconds for push and Helm operations")
+	deployCmd.Flags().IntVar(&deployTimeout, "timeout", configs.DefaultTimeouts.HelmWait, "Timeout in seconds for push and Helm operations (overrides timeouts.push and timeouts.helmWait in smurf.yaml)")
+	deployCmd.Flags().StringVar(&deployBuilder, "builder", docker.BuilderDocker, "Image builder: docker, containerd (nerdctl) or buildkitd (buildctl) (overrides sdkr.builder in smurf.yaml)")
+	deployCmd.Flags().StringVar(&configs.RemoteBuild, "remote-build", "", "Build on a buildkitd pod in the current kube context instead of locally: k8s (needs buildctl and kubectl, no Docker)")
+	deployCmd.Flags().StringVar(&configs.RemoteBuildNamespace, "remote-build-namespace", docker.DefaultRemoteBuildNamespace, "Namespace of the buildkitd deployment used by --remote-build")
+	deployCmd.Flags().BoolVar(&configs.KeepRemoteBuilder, "keep-remote-builder", false, "Leave the buildkitd deployment --remote-build started running, for faster builds after it")
+	deployCmd.Flags().StringArrayVar(&configs.CacheFrom, "cache-from", []string{}, "Import the build cache from this source, e.g. type=registry,ref=REPO:buildcache (can be repeated; default: sdkr.cacheFrom in smurf.yaml)")
+	deployCmd.Flags().StringArrayVar(&configs.CacheTo, "cache-to", []string{}, "Export the build cache to this destination, e.g. type=registry,ref=REPO:buildcache,mode=max (can be repeated; default: sdkr.cacheTo in smurf.yaml)")
+	deployCmd.Flags().BoolVar(&configs.Reproducible, "reproducible", false, "Build reproducibly: SOURCE_DATE_EPOCH from the environment or the last commit, normalized build context timestamps, and print the resulting digest")
+	deployCmd.Flags().BoolVar(&configs.EcrScanFindings, "scan-findings", false, "After pushing to ECR, wait for its scan on push and report the findings")
+	deployCmd.Flags().DurationVar(&configs.EcrScanTimeout, "scan-timeout", docker.DefaultECRScanTimeout, "How long to wait for ECR's scan on push")
+	deployCmd.Flags().StringVar(&configs.ScanFailOn, "fail-on", "", "Fail when ECR's scan finds vulnerabilities of this severity or higher (low|medium|high|critical)")
+	deployCmd.Flags().BoolVar(&configs.ScanFailOnNewCritical, "fail-on-new-critical", false, "Fail when ECR's scan finds CRITICAL vulnerabilities that the previously deployed image doesn't have (implies --scan-findings)")
+	deployCmd.Flags().StringVar(&deployPullSecret, "image-pull-secret", "", "Create or update this image pull secret from the push credentials and pass it to the chart (overrides selm.imagePullSecret)")
+	deployCmd.Flags().StringArrayVar(&deployValuesFrom, "values-from", []string{}, "Merge values stored in the cluster, as configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY (repeatable; adds to selm.valuesFrom)")
+	deployCmd.Flags().BoolVar(&configs.ShowSecrets, "show-secrets", false, "Print the values of keys matching selm.redactKeys instead of masking them, for local debugging")
+	deployCmd.Flags().BoolVar(&configs.NoNamespaceDefaults, "no-namespace-defaults", false, "Ignore the default values of the smurf-defaults ConfigMap in the release namespace")
+	deployCmd.Flags().StringArrayVar(&deployHealthURLs, "health-url", []string{}, "HTTP(S) endpoint that must answer 200 before the release that gets the image counts as deployed (repeatable; adds to selm.healthChecks)")
+	deployCmd.Flags().BoolVar(&deployRollbackOnUnhealthy, "rollback-on-unhealthy", false, "Roll a release back to its previous revision when its health checks don't pass")
+	deployCmd.Flags().BoolVar(&deployRollbackOnFailure, "rollback-on-failure", false, "When the helm phase fails, restore the values files and roll back or uninstall the releases this run deployed (overrides deploy.rollbackOnFailure)")
+	deployCmd.Flags().BoolVar(&deployDeletePushedTag, "delete-pushed-tag", false, "On rollback, also delete the tag this run pushed from the registry; implies --rollback-on-failure (overrides deploy.deletePushedTag)")
+	deployCmd.Flags().StringVar(&deployCapacityCheck, "capacity-check", "warn", "What to do when a release's CPU/memory requests don't fit the cluster or namespace quota: warn, fail or off (overrides deploy.capacityCheck)")
+	deployCmd.Flags().BoolVar(&deployNoHistory, "no-history", false, "Do not record this run in the release's deploy history ledger")
+	deployCmd.Flags().StringVar(&deployArtifacts.Path, "artifacts-manifest", "", "Write a JSON manifest of the pushed image, its digest, SBOM, scan report and signatures to this path")
+	deployCmd.Flags().StringVar(&deployArtifacts.SBOM, "sbom", "", "SBOM file to reference in the artifacts manifest")
+	deployCmd.Flags().StringVar(&deployArtifacts.ScanReport, "scan-report", "", "Vulnerability scan report to reference in the artifacts manifest")
+	deployCmd.Flags().StringArrayVar(&deployArtifacts.Signatures, "signature", []string{}, "Signature reference to record in the artifacts manifest (repeatable)")
+	deployCmd.Flags().BoolVar(&deployArtifacts.Attach, "attach-artifacts", false, "Also attach the artifacts manifest to the image as an OCI referrer (requires oras)")
+	deployCmd.Flags().StringArrayVar(&deployWebhooks, "webhook", []string{}, "URL to POST the pushed image reference and digest to (repeatable; adds to sdkr.webhooks in smurf.yaml)")
+	deployCmd.Flags().StringVar(&deployWebhookSecret, "webhook-secret", "", "HMAC-SHA256 key used to sign --webhook calls (default $SMURF_WEBHOOK_SECRET)")
+	deployCmd.Flags().StringSliceVar(&deployOnly, "only", nil, "Run only these phases (build, push, helm), e.g. --only build,push")
+	deployCmd.Flags().StringSliceVar(&deploySkip, "skip", nil, "Skip these phases (build, push, helm), e.g. --skip helm")
+	deployCmd.Flags().StringVar(&deployReportPath, "run-report", defaultDeployReport, "Run report recording each phase's outcome and the pushed image, read by later --only/--skip runs")
+	deployCmd.Flags().BoolVar(&deployResume, "resume", false, "Continue an interrupted deploy from the phase and release it stopped at")
+	deployCmd.Flags().BoolVar(&deployAbort, "abort", false, "Clean up after an interrupted deploy: uninstall or roll back the release it left pending")
+	deployCmd.Flags().BoolVar(&deployDryRun, "dry-run", false, "Print the image that would be built and pushed, the values file changes and the diff of each release, without building, pushing or deploying")
+	deployCmd.MarkFlagsMutuallyExclusive("dry-run", "abort")
+	deployCmd.MarkFlagsMutuallyExclusive("resume", "abort", "only", "skip")
+	_ = deployCmd.RegisterFlagCompletionFunc("only", completeDeployPhases)
+	_ = deployCmd.RegisterFlagCompletionFunc("skip", completeDeployPhases)
+	_ = deployCmd.RegisterFlagCompletionFunc("capacity-check", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return configs.CapacityCheckModes, cobra.ShellCompDirectiveNoFileComp
+	})
 	RootCmd.AddCommand(deployCmd)
 }
 
-func buildImageWithOpts(imageName, tag string) error {
+func completeDeployPhases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return deployPhaseOrder, cobra.ShellCompDirectiveNoFileComp
+}
+
+// afterImagePush writes the artifacts manifest, exports the image to later
+// CI jobs and calls the webhooks for the image the push phase pushed.
+func afterImagePush(cfg *configs.Config, image string) error {
+	if deployArtifacts.Path != "" {
+		deployArtifacts.Digest = pushedDigest
+		if _, err := docker.WriteArtifactsManifest(image, deployArtifacts); err != nil {
+			return err
+		}
+	}
+
+	if err := ci.Export(ci.ImageVars(image, pushedDigest)); err != nil {
+		return err
+	}
+
+	secret := deployWebhookSecret
+	if secret == "" {
+		secret = os.Getenv("SMURF_WEBHOOK_SECRET")
+	}
+	hooks := cfg.Sdkr.Webhooks
+	for _, u := range deployWebhooks {
+		hooks = append(hooks, configs.WebhookConfig{URL: u, Secret: secret})
+	}
+	return docker.NotifyWebhooks(hooks, docker.NewPushEvent(image, pushedDigest))
+}
+
+// ensureDeployPullSecret keeps the image pull secret named by
+// --image-pull-secret or selm.imagePullSecret in step with the registry
+// credentials of the deployed image, in the namespace of every release that
+// gets the image, and returns its name for the charts to use.
+func ensureDeployPullSecret(cmd *cobra.Command, cfg *configs.Config, imageRepo, imageTag string) (string, error) {
+	name := cfg.Selm.ImagePullSecret
+	if cmd.Flags().Changed("image-pull-secret") {
+		name = deployPullSecret
+	}
+	if name == "" {
+		return "", nil
+	}
+	if imageRepo == "" {
+		pterm.Warning.Printfln("No image was pushed; image pull secret %s is not updated", name)
+		return "", nil
+	}
+
+	releases, err := cfg.Selm.DeployReleases()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server, username, password, err := docker.PullCredential(ctx, imageRepo+":"+imageTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve registry credentials for image pull secret %s: %w", name, err)
+	}
+	done := map[string]bool{}
+	for _, rel := range releases {
+		if !rel.Image || done[rel.Namespace] {
+			continue
+		}
+		if err := helm.EnsureImagePullSecret(rel.Namespace, name, server, username, password); err != nil {
+			return "", err
+		}
+		done[rel.Namespace] = true
+	}
+	return name, nil
+}
+
+func buildImageWithOpts(imageName, tag string) (err error) {
+	start := time.Now()
+	defer func() { deployRun.finishPhase(phaseBuild, start, err) }()
+
 	opts, err := prepareDockerBuild()
 	if err != nil {
 		return err
 	}
-	return docker.Build(imageName, tag, opts, false)
+	return docker.BuildWith(deployBuilder, imageName, tag, opts, false)
+}
+
+// daemonless reports whether images are built and pushed without the Docker
+// daemon.
+func daemonless() bool {
+	return deployBuilder != docker.BuilderDocker
+}
+
+// pushDaemonless pushes source, built by a daemonless builder, as target and
+// records the pushed digest.
+func pushDaemonless(source, target string) error {
+	digest, err := docker.PushBuilt(deployBuilder, source, target, configs.Timeouts.PushTimeout())
+	if err != nil {
+		return err
+	}
+	pushedDigest = digest
+	return nil
 }
 
 func prepareDockerBuild() (docker.BuildOptions, error) {
@@ -125,12 +571,17 @@ func prepareDockerBuild() (docker.BuildOptions, error) {
 		BuildArgs:      buildArgs,
 		Target:         configs.Target,
 		Platform:       configs.Platform,
-		Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
+		Timeout:        configs.Timeouts.BuildTimeout(),
+		RemoteBuild:    configs.RemoteBuild,
+		Remote:         docker.RemoteBuildOptions{Namespace: configs.RemoteBuildNamespace, Keep: configs.KeepRemoteBuilder},
+		Reproducible:   configs.Reproducible,
+		CacheFrom:      configs.CacheFrom,
+		CacheTo:        configs.CacheTo,
 	}, nil
 }
 
 func maybeCleanup(image string) {
-	if configs.DeleteAfterPush {
+	if configs.DeleteAfterPush && !daemonless() {
 		_ = docker.RemoveImage(image, false)
 		pterm.Info.Printf("🧹 Deleted local image: %s\n", image)
 	}
@@ -146,21 +597,46 @@ func handleECRPush(cfg *configs.Config) (string, string, error) {
 	if tag == "" {
 		tag = "latest"
 	}
+	aws := configs.NewCredentials(configs.CredentialFlags{AWSRegion: region}, &cfg.Sdkr).AWS()
+	if err := aws.Validate(); err != nil {
+		return "", "", err
+	}
+	if err := aws.Export(); err != nil {
+		return "", "", err
+	}
 
 	localImage := fmt.Sprintf("%s:%s", repo, tag)
-	pterm.Info.Printf("🔧 Building local image %s\n", localImage)
-	if err := buildImageWithOpts(repo, tag); err != nil {
-		return "", "", err
+	if deployPhases[phaseBuild] {
+		pterm.Info.Printf("🔧 Building local image %s\n", localImage)
+		if err := buildImageWithOpts(repo, tag); err != nil {
+			return "", "", err
+		}
+	}
+	if skipPush(localImage) {
+		return "", "", nil
 	}
 
 	fullRemote := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s:%s", accountID, region, repo, tag)
+	if configs.EcrScanFindings {
+		configs.ScanBaselineDigest = scanBaselineDigest(cfg)
+	}
 	pterm.Info.Printf("🚀 Pushing to ECR: %s\n", fullRemote)
 
-	if err := docker.PushImageToECR(fullRemote, region, repo, false); err != nil {
+	if daemonless() {
+		if err := pushDaemonless(localImage, fullRemote); err != nil {
+			return "", "", err
+		}
+		if configs.EcrScanFindings {
+			if err := docker.CheckECRScan(region, repo, tag); err != nil {
+				return "", "", err
+			}
+		}
+	} else if err := docker.PushImageToECR(fullRemote, region, repo, false); err != nil {
 		return "", "", err
 	}
 
 	pterm.Success.Printf("✅ Successfully pushed to ECR: %s\n", fullRemote)
+	capturePushedDigest(fullRemote)
 	maybeCleanup(localImage)
 
 	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", accountID, region, repo), tag, nil
@@ -175,35 +651,37 @@ func handleDockerHubPush(cfg *configs.Config) (string, string, error) {
 		repo, tag = parts[0], parts[1]
 	}
 
-	envVars := map[string]string{}
-	if os.Getenv("DOCKER_USERNAME") == "" && cfg.Sdkr.DockerUsername != "" {
-		envVars["DOCKER_USERNAME"] = cfg.Sdkr.DockerUsername
-	}
-	if os.Getenv("DOCKER_PASSWORD") == "" && cfg.Sdkr.DockerPassword != "" {
-		envVars["DOCKER_PASSWORD"] = cfg.Sdkr.DockerPassword
+	hub := configs.NewCredentials(configs.CredentialFlags{}, &cfg.Sdkr).DockerHub()
+	if err := hub.Export(); err != nil {
+		return "", "", err
 	}
-	if len(envVars) > 0 {
-		if err := configs.ExportEnvironmentVariables(envVars); err != nil {
+
+	fullImage := fmt.Sprintf("%s:%s", repo, tag)
+	if deployPhases[phaseBuild] {
+		pterm.Info.Printf("🔧 Building Docker image %s:%s\n", repo, tag)
+		if err := buildImageWithOpts(repo, tag); err != nil {
 			return "", "", err
 		}
 	}
-
-	pterm.Info.Printf("🔧 Building Docker image %s:%s\n", repo, tag)
-	if err := buildImageWithOpts(repo, tag); err != nil {
-		return "", "", err
+	if skipPush(fullImage) {
+		return "", "", nil
 	}
 
-	fullImage := fmt.Sprintf("%s:%s", repo, tag)
 	pterm.Info.Printf("🚀 Pushing image %s\n", fullImage)
 
-	if err := docker.PushImage(docker.PushOptions{
+	if daemonless() {
+		if err := pushDaemonless(fullImage, fullImage); err != nil {
+			return "", "", err
+		}
+	} else if err := docker.PushImage(docker.PushOptions{
 		ImageName: fullImage,
-		Timeout:   time.Duration(configs.Timeout) * time.Second,
+		Timeout:   configs.Timeouts.PushTimeout(),
 	}, false); err != nil {
 		return "", "", err
 	}
 
 	pterm.Success.Printf("✅ Successfully pushed to DockerHub: %s\n", fullImage)
+	capturePushedDigest(fullImage)
 	maybeCleanup(fullImage)
 
 	return repo, tag, nil
@@ -222,35 +700,37 @@ func handleGHCRPush(cfg *configs.Config) (string, string, error) {
 		repo, tag = parts[0], parts[1]
 	}
 
-	ghcrEnvVars := map[string]string{}
-	if os.Getenv("GITHUB_USERNAME") == "" && cfg.Sdkr.GithubUsername != "" {
-		ghcrEnvVars["GITHUB_USERNAME"] = cfg.Sdkr.GithubUsername
-	}
-	if os.Getenv("GITHUB_TOKEN") == "" && cfg.Sdkr.GithubToken != "" {
-		ghcrEnvVars["GITHUB_TOKEN"] = cfg.Sdkr.GithubToken
+	ghcr := configs.NewCredentials(configs.CredentialFlags{}, &cfg.Sdkr).GHCR()
+	if err := ghcr.Export(); err != nil {
+		return "", "", err
 	}
-	if len(ghcrEnvVars) > 0 {
-		if err := configs.ExportEnvironmentVariables(ghcrEnvVars); err != nil {
+
+	fullImage := fmt.Sprintf("%s:%s", repo, tag)
+	if deployPhases[phaseBuild] {
+		pterm.Info.Printf("🔧 Building GHCR image %s:%s\n", repo, tag)
+		if err := buildImageWithOpts(repo, tag); err != nil {
 			return "", "", err
 		}
 	}
-
-	pterm.Info.Printf("🔧 Building GHCR image %s:%s\n", repo, tag)
-	if err := buildImageWithOpts(repo, tag); err != nil {
-		return "", "", err
+	if skipPush(fullImage) {
+		return "", "", nil
 	}
 
-	fullImage := fmt.Sprintf("%s:%s", repo, tag)
 	pterm.Info.Printf("🚀 Pushing %s to GHCR...\n", fullImage)
 
-	if err := docker.PushToGHCR(docker.PushOptions{
+	if daemonless() {
+		if err := pushDaemonless(fullImage, fullImage); err != nil {
+			return "", "", err
+		}
+	} else if err := docker.PushToGHCR(docker.PushOptions{
 		ImageName: fullImage,
-		Timeout:   time.Duration(configs.Timeout) * time.Second,
+		Timeout:   configs.Timeouts.PushTimeout(),
 	}, false); err != nil {
 		return "", "", err
 	}
 
 	pterm.Success.Printf("✅ Successfully pushed to GHCR: %s\n", fullImage)
+	capturePushedDigest(fullImage)
 	maybeCleanup(fullImage)
 
 	return repo, tag, nil
@@ -267,9 +747,14 @@ func handleGCPPush(cfg *configs.Config) (string, string, error) {
 		repo, tag = parts[0], parts[1]
 	}
 
-	// Validate GCP Registry
-	if !strings.HasPrefix(repo, "gcr.io/") && !strings.Contains(repo, "-docker.pkg.dev/") {
-		return "", "", fmt.Errorf("invalid GCP registry. Must be gcr.io/ or *.pkg.dev")
+	gcp := configs.NewCredentials(configs.CredentialFlags{GCPProjectID: configs.ProjectID}, &cfg.Sdkr).GCP()
+	if err := gcp.Export(); err != nil {
+		return "", "", err
+	}
+	// A bare image name goes to sdkr.gcpRegion/gcpRepository, or gcr.io.
+	repo, err := configs.GcpImageRepository(repo, gcp.ProjectID, cfg.Sdkr.GCPRegion, cfg.Sdkr.GCPRepository)
+	if err != nil {
+		return "", "", err
 	}
 
 	// Extract local build image name
@@ -281,13 +766,25 @@ func handleGCPPush(cfg *configs.Config) (string, string, error) {
 
 	// Build local image
 	localImageRef := fmt.Sprintf("%s:%s", localRepo, tag)
-	pterm.Info.Printf("🔧 Building image %s\n", localImageRef)
-	if err := buildImageWithOpts(localRepo, tag); err != nil {
-		return "", "", err
+	if deployPhases[phaseBuild] {
+		pterm.Info.Printf("🔧 Building image %s\n", localImageRef)
+		if err := buildImageWithOpts(localRepo, tag); err != nil {
+			return "", "", err
+		}
+	}
+	if skipPush(localImageRef) {
+		return "", "", nil
 	}
 
 	// FULL GCP image reference
 	fullRemote := fmt.Sprintf("%s:%s", repo, tag)
+	if daemonless() {
+		if err := pushDaemonless(localImageRef, fullRemote); err != nil {
+			return "", "", err
+		}
+		pterm.Success.Printf("✅ Successfully pushed to GCP: %s\n", fullRemote)
+		return repo, tag, nil
+	}
 	pterm.Info.Printf("🔖 Tagging image: %s → %s\n", localImageRef, fullRemote)
 
 	// Tag
@@ -297,18 +794,69 @@ func handleGCPPush(cfg *configs.Config) (string, string, error) {
 	}
 
 	// PUSH using GCP-specific function (like ECR does 🎯)
-	if err := docker.PushImageToGCR(configs.ProjectID, fullRemote, false); err != nil {
+	if err := docker.PushImageToGCR(gcp.ProjectID, fullRemote, false); err != nil {
 		return "", "", err
 	}
 
 	pterm.Success.Printf("✅ Successfully pushed to GCP: %s\n", fullRemote)
+	capturePushedDigest(fullRemote)
 	maybeCleanup(localImageRef)
 
 	// Return repository + tag like ECR function does
 	return repo, tag, nil
 }
 
-func handleHelmDeploy(data *configs.Config, imageRepo, imageTag string) error {
+// handleACRPush builds sdkr.imageName and pushes it to the Azure Container
+// Registry sdkr.provisionAcrRegistryName, with the subscription and resource
+// group of the config file or the environment. The image name may carry the
+// registry host (myacr.azurecr.io/app:v1) or not (app:v1).
+func handleACRPush(cfg *configs.Config) (string, string, error) {
+	pterm.Info.Println("📦 Handling Azure ACR push...")
+
+	localImage, repo, tag, err := configs.NormalizeAcrLocalImage(cfg.Sdkr.ImageName)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid image name %q: %w", cfg.Sdkr.ImageName, err)
+	}
+	az := configs.NewCredentials(configs.CredentialFlags{}, &cfg.Sdkr).Azure()
+	if err := az.Validate(); err != nil {
+		return "", "", err
+	}
+
+	if deployPhases[phaseBuild] {
+		pterm.Info.Printf("🔧 Building local image %s\n", localImage)
+		if err := buildImageWithOpts(repo, tag); err != nil {
+			return "", "", err
+		}
+	}
+	if skipPush(localImage) {
+		return "", "", nil
+	}
+
+	remoteRepo := fmt.Sprintf("%s.azurecr.io/%s", strings.ToLower(az.RegistryName), repo)
+	fullRemote := remoteRepo + ":" + tag
+	pterm.Info.Printf("🚀 Pushing to ACR: %s\n", fullRemote)
+
+	if daemonless() {
+		if err := pushDaemonless(localImage, fullRemote); err != nil {
+			return "", "", err
+		}
+	} else if err := docker.PushImageToACR(az.SubscriptionID, az.ResourceGroup, az.RegistryName, localImage, false); err != nil {
+		return "", "", err
+	}
+
+	pterm.Success.Printf("✅ Successfully pushed to ACR: %s\n", fullRemote)
+	capturePushedDigest(fullRemote)
+	maybeCleanup(localImage)
+
+	return remoteRepo, tag, nil
+}
+
+// handleHelmDeploy installs or upgrades the releases of selm.releases (or
+// the single selm release) in dependency order. Each release must pass its
+// health checks, and extraChecks for the release that gets the image, and
+// before a release that others depend on is followed by the next one, its
+// workloads must be ready.
+func handleHelmDeploy(data *configs.Config, imageRepo, imageTag, pullSecret string, extraChecks []configs.HealthCheck) error {
 	pterm.Info.Println("Starting Helm deployment...")
 
 	if strings.Contains(imageRepo, ":") {
@@ -317,34 +865,91 @@ func handleHelmDeploy(data *configs.Config, imageRepo, imageTag string) error {
 		pterm.Info.Printf("🧹 Cleaned image repo: %s (removed internal tag)\n", imageRepo)
 	}
 
-	releaseName := data.Selm.ReleaseName
-	if releaseName == "" {
-		releaseName = filepath.Base(data.Selm.ChartName)
+	releases, err := data.Selm.DeployReleases()
+	if err != nil {
+		return err
 	}
+	for i, rel := range releases {
+		if len(releases) > 1 {
+			pterm.DefaultSection.Printf("Release %d/%d: %s", i+1, len(releases), rel.Name)
+		}
+		deploy, err := resumeRelease(rel.Namespace, rel.Name)
+		if err != nil {
+			return err
+		}
+		if !deploy {
+			continue
+		}
+		deployRun.startRelease(rel.Namespace + "/" + rel.Name)
+		if err := deployUndo.saveRelease(rel.Name, rel.Namespace); err != nil {
+			return err
+		}
+		if err := deployRelease(rel, imageRepo, imageTag, pullSecret, data.Deploy); err != nil {
+			if len(releases) > 1 {
+				return fmt.Errorf("release %s: %w", rel.Name, err)
+			}
+			return err
+		}
+		checks := rel.HealthChecks
+		if rel.Image {
+			checks = append(append([]configs.HealthCheck{}, checks...), extraChecks...)
+		}
+		if err := helm.HealthGate(rel.Name, rel.Namespace, checks, configs.Timeouts.ReadinessTimeout(), deployRollbackOnUnhealthy, configs.Debug); err != nil {
+			return err
+		}
+		if i < len(releases)-1 && configs.HasDependents(releases, rel.Name) {
+			if err := helm.WaitForRelease(rel.Name, rel.Namespace, configs.Timeouts.ReadinessTimeout(), configs.Debug); err != nil {
+				return err
+			}
+		}
+		deployRun.finishRelease(rel.Namespace + "/" + rel.Name)
+	}
+	return nil
+}
 
-	chartPath := data.Selm.ChartName
+// deployRelease installs or upgrades one release. Releases marked image get
+// the pushed image written to their values file and the image pull secret.
+// With deploy.requireSignedImages, every image of the rendered chart must be
+// signed by a trusted identity before anything is deployed.
+func deployRelease(rel configs.ReleaseConfig, imageRepo, imageTag, pullSecret string, policy configs.DeployConfig) error {
+	releaseName, namespace, chartPath := rel.Name, rel.Namespace, rel.ChartName
 	if releaseName == "" || chartPath == "" {
 		return errors.New("release name or chart path missing in config")
 	}
 
-	namespace := data.Selm.Namespace
-	if namespace == "" {
-		namespace = "default"
-	}
-
-	valuesFilePath, err := getValuesFilePath(data.Selm, chartPath)
+	valuesFilePath, files, sets, err := releaseInputs(rel, pullSecret)
 	if err != nil {
 		return err
 	}
-
-	if imageRepo != "" && imageTag != "" {
+	if rel.Image && imageRepo != "" && imageTag != "" {
+		if err := deployUndo.saveValuesFile(valuesFilePath); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", valuesFilePath, err)
+		}
 		if err := updateValuesYamlFile(valuesFilePath, imageRepo, imageTag); err != nil {
 			return fmt.Errorf("failed to update values.yaml: %v", err)
 		}
 		pterm.Success.Println("✅ Updated values.yaml with new image details")
 	}
 
-	timeoutDuration := time.Duration(configs.Timeout) * time.Second
+	capacityCheck := policy.CapacityCheckMode()
+	if policy.RequireSignedImages || capacityCheck != "off" {
+		manifest, err := helm.RenderManifest(releaseName, chartPath, namespace, files, sets, configs.SetLiteral, configs.Debug)
+		if err != nil {
+			return err
+		}
+		if policy.RequireSignedImages {
+			if err := verifyReleaseImages(manifest, policy); err != nil {
+				return err
+			}
+		}
+		if capacityCheck != "off" {
+			if err := checkReleaseCapacity(releaseName, namespace, manifest, capacityCheck); err != nil {
+				return err
+			}
+		}
+	}
+
+	timeoutDuration := configs.Timeouts.HelmWaitTimeout()
 
 	exists, err := helm.HelmReleaseExists(releaseName, namespace, configs.Debug, false)
 	if err != nil {
@@ -357,11 +962,11 @@ func handleHelmDeploy(data *configs.Config, imageRepo, imageTag string) error {
 			releaseName,
 			chartPath,
 			namespace,
-			configs.File,
+			files,
 			timeoutDuration,
 			configs.Atomic,
 			configs.Debug,
-			configs.Set,
+			sets,
 			configs.SetLiteral,
 			"",
 			"",
@@ -375,8 +980,8 @@ func handleHelmDeploy(data *configs.Config, imageRepo, imageTag string) error {
 		releaseName,
 		chartPath,
 		namespace,
-		configs.Set,
-		configs.File,
+		sets,
+		files,
 		configs.SetLiteral,
 		true,
 		configs.Atomic,
@@ -391,6 +996,101 @@ func handleHelmDeploy(data *configs.Config, imageRepo, imageTag string) error {
 	)
 }
 
+// releaseInputs returns the values files and --set values rel is deployed
+// with. For a release marked image, valuesFile is the values file the pushed
+// image is written to; it is among files only when rel.FileName names it,
+// being the chart's own values.yaml otherwise.
+func releaseInputs(rel configs.ReleaseConfig, pullSecret string) (valuesFile string, files, sets []string, err error) {
+	files = configs.File
+	sets = append(append([]string{}, configs.Set...), rel.Set...)
+	if rel.Image {
+		valuesFile, err = getValuesFilePath(configs.SelmConfig{FileName: rel.FileName}, rel.ChartName)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if pullSecret != "" {
+			sets = append(sets, helm.ImagePullSecretValue(pullSecret))
+		}
+		if rel.FileName != "" {
+			files = append(append([]string{}, files...), valuesFile)
+		}
+	} else if rel.FileName != "" {
+		files = append(append([]string{}, files...), rel.FileName)
+	}
+	return valuesFile, files, sets, nil
+}
+
+// verifyReleaseImages verifies the signatures of all the images the
+// manifest of a release, rendered with the values it is about to be
+// deployed with, runs, third-party sidecars included.
+func verifyReleaseImages(manifest string, policy configs.DeployConfig) error {
+	images, err := helm.ManifestImages(manifest)
+	if err != nil {
+		return err
+	}
+	if err := docker.VerifyImageSignatures(images, policy); err != nil {
+		return fmt.Errorf("deploy.requireSignedImages: %w", err)
+	}
+	return nil
+}
+
+// checkReleaseCapacity warns, or fails when mode is fail, when the CPU and
+// memory the manifest of a release requests don't fit on the cluster's
+// nodes or in the namespace's ResourceQuotas, so a deploy doesn't hang on
+// Pending pods.
+func checkReleaseCapacity(releaseName, namespace, manifest, mode string) error {
+	report, err := helm.CheckCapacity(releaseName, namespace, manifest)
+	if err != nil {
+		if mode == "fail" {
+			return fmt.Errorf("capacity preflight: %w", err)
+		}
+		pterm.Warning.Printfln("Capacity preflight skipped: %v", err)
+		return nil
+	}
+	if len(report.Problems) == 0 {
+		if report.Requested != "" {
+			pterm.Info.Printfln("Capacity preflight: %s requests %s; %s free", releaseName, report.Requested, report.Free)
+		}
+		return nil
+	}
+	if mode == "fail" {
+		return fmt.Errorf("capacity preflight: release %s doesn't fit: %s", releaseName, report)
+	}
+	for _, problem := range report.Problems {
+		pterm.Warning.Printfln("Capacity preflight: %s", problem)
+	}
+	return nil
+}
+
+// deployHealthChecks returns the health checks given with --health-url.
+func deployHealthChecks() ([]configs.HealthCheck, error) {
+	checks := make([]configs.HealthCheck, len(deployHealthURLs))
+	for i, u := range deployHealthURLs {
+		checks[i] = configs.HealthCheck{URL: u}
+		if err := checks[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+// helmDeployTarget returns the release name and namespace deploy targets,
+// defaulting the release name to the chart's base name and the namespace to
+// "default".
+func helmDeployTarget(selm configs.SelmConfig) (string, string) {
+	releaseName := selm.ReleaseName
+	if releaseName == "" && selm.ChartName != "" {
+		releaseName = filepath.Base(selm.ChartName)
+	}
+
+	namespace := selm.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return releaseName, namespace
+}
+
 // updateValuesYamlFile updates image.repository and image.tag fields in values.yaml safely.
 func updateValuesYamlFile(valuesFilePath, imageRepo, imageTag string) error {
 	if imageRepo == "" && imageTag == "" {
@@ -400,17 +1100,33 @@ func updateValuesYamlFile(valuesFilePath, imageRepo, imageTag string) error {
 
 	pterm.Info.Printf("🔧 Updating values.yaml: %s\n", valuesFilePath)
 
-	file, err := os.Open(valuesFilePath)
+	data, err := os.ReadFile(valuesFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to open values.yaml: %v", err)
 	}
-	defer file.Close()
+	output, err := updateImageValues(data, imageRepo, imageTag)
+	if err != nil {
+		return err
+	}
 
+	// write back
+	if err := os.WriteFile(valuesFilePath, output, 0644); err != nil {
+		return fmt.Errorf("failed to write updated values.yaml: %v", err)
+	}
+
+	pterm.Success.Printf("✅ Updated values.yaml successfully:\n  repository: %s\n  tag: %s\n", imageRepo, imageTag)
+	return nil
+}
+
+// updateImageValues returns the content of a values file with
+// image.repository and image.tag set, appending an image section when the
+// file has none.
+func updateImageValues(data []byte, imageRepo, imageTag string) ([]byte, error) {
 	var updatedLines []string
 	inImageSection := false
 	repoUpdated, tagUpdated := false, false
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := scanner.Text()
 		trimmed := strings.TrimSpace(line)
@@ -450,7 +1166,7 @@ func updateValuesYamlFile(valuesFilePath, imageRepo, imageTag string) error {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading values.yaml: %v", err)
+		return nil, fmt.Errorf("error reading values.yaml: %v", err)
 	}
 
 	// if repository/tag not found, append new image section
@@ -465,14 +1181,7 @@ func updateValuesYamlFile(valuesFilePath, imageRepo, imageTag string) error {
 		}
 	}
 
-	// write back
-	output := strings.Join(updatedLines, "\n")
-	if err := os.WriteFile(valuesFilePath, []byte(output), 0644); err != nil {
-		return fmt.Errorf("failed to write updated values.yaml: %v", err)
-	}
-
-	pterm.Success.Printf("✅ Updated values.yaml successfully:\n  repository: %s\n  tag: %s\n", imageRepo, imageTag)
-	return nil
+	return []byte(strings.Join(updatedLines, "\n")), nil
 }
 
 // Helper function to get values file path
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	deployDestroyYes     bool
+	deployDestroyTimeout time.Duration
+)
+
+// deployDestroyCmd uninstalls the releases `smurf deploy` manages, in the
+// reverse of their deploy order, so a release is removed before the releases
+// it depends on.
+var deployDestroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Uninstall the Helm releases of smurf.yaml in reverse dependency order",
+	Long: `Destroy uninstalls the releases deploy manages (selm.releases, or the single
+selm release) in the reverse of the order deploy installs them: a release goes
+before the releases it depends on. Releases that are not installed are skipped.
+
+Destroy asks for confirmation, and needs --yes when stdin is not a terminal.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := configs.LoadConfig(configs.FileName)
+		if err != nil {
+			return err
+		}
+		releases, err := cfg.Selm.DeployReleases()
+		if err != nil {
+			return err
+		}
+		slices.Reverse(releases)
+		if len(releases) == 0 || releases[0].Name == "" {
+			return errors.New("no releases to destroy: set selm.releaseName, selm.chartName or selm.releases in smurf.yaml")
+		}
+
+		if cfg.Selm.Cluster.Name != "" {
+			if _, err := helm.ConnectCluster(cfg.Selm.Cluster, releases[0].Namespace); err != nil {
+				return err
+			}
+		}
+
+		names := make([]string, len(releases))
+		for i, rel := range releases {
+			names[i] = rel.Namespace + "/" + rel.Name
+		}
+		pterm.Info.Printfln("Releases to uninstall, in order: %s", strings.Join(names, ", "))
+		if err := confirmDestroy(deployDestroyYes); err != nil {
+			return err
+		}
+
+		for _, rel := range releases {
+			exists, err := helm.HelmReleaseExists(rel.Name, rel.Namespace, configs.Debug, false)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				pterm.Info.Printfln("Release %s/%s is not installed; skipping", rel.Namespace, rel.Name)
+				continue
+			}
+			err = helm.HelmUninstall(helm.UninstallOptions{
+				ReleaseName: rel.Name,
+				Namespace:   rel.Namespace,
+				Timeout:     deployDestroyTimeout,
+				Cascade:     "foreground",
+			}, false)
+			if err != nil {
+				return fmt.Errorf("release %s: %w", rel.Name, err)
+			}
+		}
+		pterm.Success.Printfln("Destroyed %d release(s)", len(releases))
+		return nil
+	},
+	Example: `
+  # Uninstall every release in smurf.yaml, dependents first
+  smurf deploy destroy
+
+  # In CI
+  smurf deploy destroy --yes
+`,
+}
+
+// confirmDestroy asks before uninstalling. Unlike the push prompts it does not
+// proceed silently without a terminal: destroying needs an explicit --yes.
+func confirmDestroy(skip bool) error {
+	if skip {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("refusing to destroy releases without confirmation; pass --yes")
+	}
+	pterm.Warning.Printf("Uninstall these releases? [y/N]: ")
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response != "y" && response != "Y" {
+		return errors.New("aborted by user")
+	}
+	return nil
+}
+
+func init() {
+	deployDestroyCmd.Flags().BoolVarP(&deployDestroyYes, "yes", "y", false, "Skip the confirmation prompt")
+	deployDestroyCmd.Flags().DurationVar(&deployDestroyTimeout, "timeout", 10*time.Minute, "Time to wait for each release's deletion")
+
+	deployCmd.AddCommand(deployDestroyCmd)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deployHistoryRelease   string
+	deployHistoryNamespace string
+	deployHistoryOutput    string
+	deployHistoryMax       int
+)
+
+// pushedDigest holds the registry digest of the image pushed by the current
+// deploy run, captured right after the push (before the optional local
+// cleanup removes the image) so it can be written to the deploy ledger.
+var pushedDigest string
+
+// deployHistoryCmd prints the deploy ledger that `smurf deploy` keeps for a
+// release in its target namespace.
+var deployHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the deploy history recorded for a release in the cluster",
+	Long: `History prints the deploy ledger of a release: every 'smurf deploy' run
+that targeted it, with the image and digest, chart version, values hash,
+duration, result and actor.
+
+The ledger is stored in the ConfigMap smurf-ledger-<release> in the release's
+namespace and keeps the most recent 100 runs. The release and namespace
+default to selm.releaseName and selm.namespace from smurf.yaml.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(deployHistoryOutput, "table", "json", "yaml") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json, yaml", deployHistoryOutput)
+		}
+
+		releaseName, namespace := deployHistoryRelease, deployHistoryNamespace
+		if releaseName == "" || namespace == "" {
+			cfg, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+			cfgRelease, cfgNamespace := helmDeployTarget(cfg.Selm)
+			if releaseName == "" {
+				releaseName = cfgRelease
+			}
+			if namespace == "" {
+				namespace = cfgNamespace
+			}
+		}
+		if releaseName == "" {
+			return fmt.Errorf("release name must be provided with --release or in smurf.yaml")
+		}
+
+		records, err := helm.DeployHistory(releaseName, namespace)
+		if err != nil {
+			return err
+		}
+		return helm.PrintDeployHistory(records, deployHistoryOutput, deployHistoryMax)
+	},
+	Example: `
+  # Show the deploy timeline of the release configured in smurf.yaml
+  smurf deploy history
+
+  # Show the last 10 deploys of another release as JSON
+  smurf deploy history --release my-app -n prod --max 10 -o json
+`,
+}
+
+func init() {
+	deployHistoryCmd.Flags().StringVar(&deployHistoryRelease, "release", "", "Release name (default: selm.releaseName from smurf.yaml)")
+	deployHistoryCmd.Flags().StringVarP(&deployHistoryNamespace, "namespace", "n", "", "Namespace of the release (default: selm.namespace from smurf.yaml)")
+	deployHistoryCmd.Flags().StringVarP(&deployHistoryOutput, "output", "o", "table", "output format (table|json|yaml)")
+	deployHistoryCmd.Flags().IntVar(&deployHistoryMax, "max", 0, "Maximum number of runs to show, newest first (0 = all)")
+
+	_ = deployHistoryCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"table", "json", "yaml"}, cobra.ShellCompDirectiveDefault
+	})
+
+	deployCmd.AddCommand(deployHistoryCmd)
+}
+
+// capturePushedDigest remembers the registry digest of a just-pushed image
+// for the deploy ledger. Failing to resolve it is not an error; the ledger
+// entry simply goes without a digest.
+func capturePushedDigest(image string) {
+	if daemonless() {
+		// pushDaemonless already recorded the digest it pushed.
+		return
+	}
+	digest, err := docker.ImageDigest(image)
+	if err != nil {
+		pterm.Debug.Printfln("could not resolve digest of %s: %v", image, err)
+		return
+	}
+	pushedDigest = digest
+}
+
+// scanBaselineDigest returns the digest of the image the last successful
+// deploy gave the release that gets the image, whose scan findings count as
+// pre-existing. Without a ledger to read it from it is "", and every finding
+// counts as new.
+func scanBaselineDigest(cfg *configs.Config) string {
+	releases, err := cfg.Selm.DeployReleases()
+	if err != nil || !cfg.Selm.HelmDeploy {
+		return ""
+	}
+	for _, rel := range releases {
+		if !rel.Image || rel.Name == "" {
+			continue
+		}
+		digest, err := helm.LastDeployedDigest(rel.Name, rel.Namespace)
+		if err != nil {
+			pterm.Warning.Printfln("Could not read the deploy history of %s/%s: %v", rel.Namespace, rel.Name, err)
+			return ""
+		}
+		return digest
+	}
+	return ""
+}
+
+// recordDeployRun writes the outcome of a deploy run to the deploy ledger of
+// each release it deployed. The ledger is bookkeeping, so a failure to write
+// it is reported as a warning and never changes the result of the deploy
+// itself.
+func recordDeployRun(cfg *configs.Config, imageRepo, imageTag string, start time.Time, runErr error) {
+	releases, err := cfg.Selm.DeployReleases()
+	if err != nil {
+		return
+	}
+	for _, rel := range releases {
+		if rel.Name == "" {
+			continue
+		}
+		recordReleaseRun(rel, imageRepo, imageTag, start, runErr)
+	}
+}
+
+func recordReleaseRun(rel configs.ReleaseConfig, imageRepo, imageTag string, start time.Time, runErr error) {
+	releaseName, namespace := rel.Name, rel.Namespace
+
+	rec := helm.DeployRecord{
+		Time:         start.UTC(),
+		Release:      releaseName,
+		Namespace:    namespace,
+		Chart:        rel.ChartName,
+		ChartVersion: helm.ChartVersion(rel.ChartName),
+		Duration:     time.Since(start).Round(time.Second).String(),
+		Result:       "success",
+		Actor:        utils.Actor(),
+	}
+	if rel.Image {
+		rec.ImageDigest = pushedDigest
+		if imageRepo != "" {
+			rec.Image = imageRepo
+			if imageTag != "" {
+				rec.Image += ":" + imageTag
+			}
+		}
+	}
+	files := configs.File
+	if rel.Image {
+		if valuesFile, err := getValuesFilePath(configs.SelmConfig{FileName: rel.FileName}, rel.ChartName); err == nil {
+			files = append([]string{valuesFile}, files...)
+		}
+	} else if rel.FileName != "" {
+		files = append([]string{rel.FileName}, files...)
+	}
+	rec.ValuesHash = valuesHash(files, configs.Set, rel.Set, configs.SetLiteral)
+	if runErr != nil {
+		rec.Result = "failed"
+		rec.Error = runErr.Error()
+	}
+
+	if err := helm.RecordDeploy(rec); err != nil {
+		pterm.Warning.Printfln("Could not record deploy history for %s/%s: %v", namespace, releaseName, err)
+		return
+	}
+	pterm.Info.Printfln("Recorded deploy in history of %s/%s", namespace, releaseName)
+}
+
+// valuesHash returns a sha256 over the contents of the values files and the
+// --set style overrides, so two deploys with identical effective inputs get
+// the same hash. Unreadable files are skipped.
+func valuesHash(files []string, sets ...[]string) string {
+	h := sha256.New()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	for _, set := range sets {
+		for _, s := range set {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/notify"
+	"github.com/clouddrove/smurf/internal/utils"
+)
+
+// deployNotification is the part of a run's notification context that is
+// known when it starts: the image it deploys and where.
+type deployNotification struct {
+	environment string
+	image       deployReportImage
+	releases    []string // namespace/name of the releases the run deploys
+}
+
+// newDeployNotification describes the run about to start: the image the
+// image phases are to push, or the one from the run report the helm phase
+// deploys, and the releases of selm.releases.
+func newDeployNotification(cfg *configs.Config, runsImagePhases, runsHelm bool) deployNotification {
+	_, namespace := helmDeployTarget(cfg.Selm)
+	n := deployNotification{environment: cfg.Deploy.Environment}
+	if n.environment == "" {
+		n.environment = namespace
+	}
+
+	if runsImagePhases {
+		if image, err := planImage(cfg); err == nil && image != nil {
+			n.image = deployReportImage{Repository: image.Repo, Tag: image.Tag}
+		}
+	} else {
+		n.image = deployRun.Image
+	}
+	if runsHelm {
+		releases, _ := cfg.Selm.DeployReleases()
+		for _, rel := range releases {
+			n.releases = append(n.releases, rel.Namespace+"/"+rel.Name)
+		}
+	}
+	return n
+}
+
+// event is the notification context of event: for started the releases the
+// run deploys, afterwards the ones it deployed, with the phase durations
+// and runErr of a failed run.
+func (n deployNotification) event(cfg *configs.Config, event string, runErr error) notify.Event {
+	e := notify.Event{
+		Event:       event,
+		Environment: n.environment,
+		Actor:       utils.Actor(),
+		Repository:  n.image.Repository,
+		Tag:         n.image.Tag,
+		Digest:      n.image.Digest,
+		Releases:    n.releases,
+		Commit:      notify.Commit(),
+		StartedAt:   deployRun.StartedAt,
+		Duration:    time.Since(deployRun.StartedAt).Round(time.Second).String(),
+		Links:       map[string]string{},
+	}
+	if e.Repository != "" {
+		e.Image = e.Repository + ":" + e.Tag
+	}
+	if url := notify.RunURL(); url != "" {
+		e.Links["run"] = url
+	}
+	for name, url := range cfg.Deploy.Links {
+		e.Links[name] = url
+	}
+
+	if event == notify.Started {
+		return e
+	}
+	if pushedDigest != "" {
+		e.Digest = pushedDigest
+	}
+	deployRun.mu.Lock()
+	defer deployRun.mu.Unlock()
+	e.Releases = append([]string(nil), deployRun.released...)
+	e.Durations = map[string]string{}
+	for _, p := range deployRun.Phases {
+		if deployPhases[p.Name] && p.Duration != "" {
+			e.Durations[p.Name] = p.Duration
+		}
+	}
+	if runErr != nil {
+		e.Error = runErr.Error()
+	}
+	return e
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// deployDryRun prints what the run would do instead of doing it.
+var deployDryRun bool
+
+// plannedImage is the image a run would build and push: the local image the
+// builder tags, and the remote repository and tag it is pushed as.
+type plannedImage struct {
+	Registry string
+	Local    string
+	Repo     string
+	Tag      string
+}
+
+// planImage works out the image the push handler of the enabled registry
+// would build and push, as handleECRPush and the others do. It returns nil
+// when no registry is enabled.
+func planImage(cfg *configs.Config) (*plannedImage, error) {
+	imageName := cfg.Sdkr.ImageName
+	repo, tag := imageName, "latest"
+	if parts := strings.SplitN(imageName, ":", 2); len(parts) == 2 {
+		repo, tag = parts[0], parts[1]
+	}
+	switch {
+	case cfg.Sdkr.AwsECR:
+		accountID, region, name, tag, err := configs.ParseEcrImageRef(imageName)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "" {
+			tag = "latest"
+		}
+		return &plannedImage{
+			Registry: "AWS ECR",
+			Local:    name + ":" + tag,
+			Repo:     fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", accountID, region, name),
+			Tag:      tag,
+		}, nil
+	case cfg.Sdkr.DockerHub:
+		return &plannedImage{Registry: "Docker Hub", Local: repo + ":" + tag, Repo: repo, Tag: tag}, nil
+	case cfg.Sdkr.GHCRRepo:
+		if !strings.HasPrefix(imageName, "ghcr.io/") {
+			return nil, errors.New("GHCR image must start with 'ghcr.io/'")
+		}
+		return &plannedImage{Registry: "GHCR", Local: repo + ":" + tag, Repo: repo, Tag: tag}, nil
+	case cfg.Sdkr.GCPRepo:
+		gcp := configs.NewCredentials(configs.CredentialFlags{GCPProjectID: configs.ProjectID}, &cfg.Sdkr).GCP()
+		repo, err := configs.GcpImageRepository(repo, gcp.ProjectID, cfg.Sdkr.GCPRegion, cfg.Sdkr.GCPRepository)
+		if err != nil {
+			return nil, err
+		}
+		local := repo[strings.LastIndex(repo, "/")+1:]
+		return &plannedImage{Registry: "GCP", Local: local + ":" + tag, Repo: repo, Tag: tag}, nil
+	case cfg.Sdkr.AzureACR:
+		local, name, tag, err := configs.NormalizeAcrLocalImage(imageName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image name %q: %w", imageName, err)
+		}
+		az := configs.NewCredentials(configs.CredentialFlags{}, &cfg.Sdkr).Azure()
+		if err := az.Validate(); err != nil {
+			return nil, err
+		}
+		return &plannedImage{
+			Registry: "Azure ACR",
+			Local:    local,
+			Repo:     fmt.Sprintf("%s.azurecr.io/%s", strings.ToLower(az.RegistryName), name),
+			Tag:      tag,
+		}, nil
+	}
+	return nil, nil
+}
+
+// planDeploy prints what the selected phases of a run would do: the image
+// that would be built and where it would be pushed, the change to each
+// values file the image is written to, and the diff of each release against
+// its deployed revision. Nothing is built, pushed, written or deployed; the
+// cluster is only read, and the run report and deploy ledger are left alone.
+func planDeploy(cmd *cobra.Command, cfg *configs.Config, previous *deployReport, runsImagePhases, runsHelm bool) error {
+	pterm.Info.Println("Dry run: nothing is built, pushed, written or deployed.")
+
+	var imageRepo, imageTag string
+	if runsImagePhases {
+		image, err := planImage(cfg)
+		if err != nil {
+			return err
+		}
+		if deployPhases[phaseBuild] {
+			if err := printBuildPlan(image); err != nil {
+				return err
+			}
+		}
+		switch {
+		case image == nil:
+			pterm.Warning.Println("No registry selected (awsECR/dockerHub/ghcrRepo/gcpRepo/azureACR). The image would not be pushed.")
+		case deployPhases[phasePush]:
+			pterm.DefaultSection.Println("Push")
+			pterm.Printfln("Registry:   %s", image.Registry)
+			pterm.Printfln("Image:      %s:%s", image.Repo, image.Tag)
+			if image.Local != image.Repo+":"+image.Tag {
+				pterm.Printfln("From:       %s", image.Local)
+			}
+			imageRepo, imageTag = image.Repo, image.Tag
+		}
+	} else if runsHelm {
+		if previous != nil {
+			imageRepo, imageTag = previous.Image.Repository, previous.Image.Tag
+		}
+		if imageRepo != "" {
+			pterm.Info.Printfln("The image %s:%s from the run report %s would be deployed", imageRepo, imageTag, deployReportPath)
+		} else {
+			pterm.Warning.Printfln("No pushed image in the run report %s; the image in the values file would be left as is.", deployReportPath)
+		}
+	}
+
+	if !runsHelm {
+		return nil
+	}
+	if cfg.Selm.Cluster.Name != "" {
+		pterm.Warning.Printfln("A dry run doesn't connect to selm.cluster %s; releases are compared with the current kube context.", cfg.Selm.Cluster.Name)
+	}
+	pullSecret := cfg.Selm.ImagePullSecret
+	if cmd.Flags().Changed("image-pull-secret") {
+		pullSecret = deployPullSecret
+	}
+	if imageRepo == "" {
+		pullSecret = ""
+	}
+	releases, err := cfg.Selm.DeployReleases()
+	if err != nil {
+		return err
+	}
+	for _, rel := range releases {
+		if err := planRelease(rel, imageRepo, imageTag, pullSecret); err != nil {
+			return fmt.Errorf("release %s: %w", rel.Name, err)
+		}
+	}
+	return nil
+}
+
+// printBuildPlan prints how the image would be built. Only the names of
+// build arguments are shown, since their values may be secrets.
+func printBuildPlan(image *plannedImage) error {
+	opts, err := prepareDockerBuild()
+	if err != nil {
+		return err
+	}
+	pterm.DefaultSection.Println("Build")
+	if image != nil {
+		pterm.Printfln("Image:      %s", image.Local)
+	}
+	pterm.Printfln("Builder:    %s", deployBuilder)
+	if opts.RemoteBuild != "" {
+		pterm.Printfln("Remote:     %s (namespace %s)", opts.RemoteBuild, opts.Remote.Namespace)
+	}
+	pterm.Printfln("Context:    %s", opts.ContextDir)
+	pterm.Printfln("Dockerfile: %s", opts.DockerfilePath)
+	if opts.Target != "" {
+		pterm.Printfln("Target:     %s", opts.Target)
+	}
+	if opts.Platform != "" {
+		pterm.Printfln("Platform:   %s", opts.Platform)
+	}
+	if len(opts.BuildArgs) > 0 {
+		names := make([]string, 0, len(opts.BuildArgs))
+		for name := range opts.BuildArgs {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		pterm.Printfln("Build args: %s", strings.Join(names, ", "))
+	}
+	if opts.NoCache {
+		pterm.Println("Cache:      disabled")
+	}
+	return nil
+}
+
+// planRelease prints the change the run would make to the values file of
+// rel and the diff of the rendered release against its deployed revision.
+// The release is rendered from a copy of the values file with the image
+// written to it, in the place of the file itself.
+func planRelease(rel configs.ReleaseConfig, imageRepo, imageTag, pullSecret string) error {
+	if rel.Name == "" || rel.ChartName == "" {
+		return errors.New("release name or chart path missing in config")
+	}
+	valuesFile, files, sets, err := releaseInputs(rel, pullSecret)
+	if err != nil {
+		return err
+	}
+
+	if rel.Image && imageRepo != "" && imageTag != "" {
+		before, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return fmt.Errorf("failed to open values.yaml: %v", err)
+		}
+		after, err := updateImageValues(before, imageRepo, imageTag)
+		if err != nil {
+			return err
+		}
+		pterm.DefaultSection.Printfln("Values file %s", valuesFile)
+		if !helm.PrintFileDiff(valuesFile, string(before), string(after)) {
+			pterm.Info.Println("Already set to the image; left as is.")
+		}
+
+		tmp, err := os.CreateTemp("", "smurf-values-*.yaml")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(after); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		// The chart's own values.yaml ranks below every other values file.
+		if i := slices.Index(files, valuesFile); i >= 0 {
+			files = slices.Replace(slices.Clone(files), i, i+1, tmp.Name())
+		} else {
+			files = append([]string{tmp.Name()}, files...)
+		}
+	}
+
+	d, err := helm.DiffRelease(rel.Name, rel.ChartName, rel.Namespace, files, sets, configs.SetLiteral, "", "", configs.Debug, false)
+	if err != nil {
+		return err
+	}
+	return helm.PrintReleaseDiff(d, "table")
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Phases of a deploy run, in the order they run.
+const (
+	phaseBuild = "build"
+	phasePush  = "push"
+	phaseHelm  = "helm"
+)
+
+var deployPhaseOrder = []string{phaseBuild, phasePush, phaseHelm}
+
+// Outcomes of a phase in the run report.
+const (
+	phaseSucceeded = "succeeded"
+	phaseFailed    = "failed"
+	phaseNotRun    = "not-run"
+)
+
+// defaultDeployReport is where deploy keeps its run report, relative to the
+// directory it runs in (next to smurf.yaml).
+const defaultDeployReport = ".smurf/deploy-report.json"
+
+// deployPhases is the set of phases the current deploy run executes; it
+// starts as every phase and is narrowed by --only / --skip.
+var deployPhases = map[string]bool{phaseBuild: true, phasePush: true, phaseHelm: true}
+
+// deployRun is the report of the current deploy run, written to the run
+// report file when the run ends.
+var deployRun *deployReport
+
+// deployReport is the run report of the last deploy: the outcome of each
+// phase and the image the push phase produced. A run that skips phases keeps
+// the previous record of those phases, so the report always holds the most
+// recent outcome of every phase and a later --only helm can deploy the image
+// pushed by an earlier run.
+type deployReport struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	StartedAt     time.Time            `json:"startedAt"`
+	FinishedAt    time.Time            `json:"finishedAt"`
+	Result        string               `json:"result"`
+	Error         string               `json:"error,omitempty"`
+	Phases        []deployPhaseOutcome `json:"phases"`
+	Image         deployReportImage    `json:"image"`
+	Resume        *resumeToken         `json:"resume,omitempty"`
+
+	// mu guards the report against the interrupt handler, which saves it
+	// while the run is still going.
+	mu sync.Mutex
+	// released and current are the releases this run has deployed and the
+	// one it is deploying, as namespace/name.
+	released []string
+	current  string
+}
+
+// resumeToken is what an interrupted run leaves in its report: the phases
+// it was to run and how far the helm phase got, so 'deploy --resume' can
+// continue where it stopped and 'deploy --abort' can clean up after it.
+type resumeToken struct {
+	InterruptedAt time.Time `json:"interruptedAt"`
+	Phases        []string  `json:"phases"`
+	Released      []string  `json:"released,omitempty"`
+	Release       string    `json:"release,omitempty"`
+}
+
+type deployPhaseOutcome struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	FinishedAt time.Time `json:"finishedAt,omitzero"`
+	Duration   string    `json:"duration,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// deployReportImage is the output of the push phase.
+type deployReportImage struct {
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+// selectDeployPhases resolves --only and --skip into the set of phases to run.
+func selectDeployPhases(only, skip []string) (map[string]bool, error) {
+	if len(only) > 0 && len(skip) > 0 {
+		return nil, fmt.Errorf("--only and --skip cannot be used together")
+	}
+
+	selected := map[string]bool{}
+	for _, p := range deployPhaseOrder {
+		selected[p] = len(only) == 0
+	}
+	names := append(append([]string{}, only...), skip...)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(deployPhaseOrder, name) {
+			return nil, fmt.Errorf("unknown deploy phase %q (phases: %s)", name, strings.Join(deployPhaseOrder, ", "))
+		}
+		selected[name] = len(only) > 0
+	}
+	for _, p := range deployPhaseOrder {
+		if selected[p] {
+			return selected, nil
+		}
+	}
+	return nil, fmt.Errorf("--skip leaves no phase to run")
+}
+
+// loadDeployReport reads the run report at path. A missing report is not an
+// error; it returns nil.
+func loadDeployReport(path string) (*deployReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read deploy run report: %w", err)
+	}
+	var report deployReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse deploy run report %s: %w", path, err)
+	}
+	if report.SchemaVersion != 1 {
+		return nil, fmt.Errorf("unsupported deploy run report schema version %d", report.SchemaVersion)
+	}
+	return &report, nil
+}
+
+// newDeployReport starts the report of a run, carrying over the phase
+// records and image of previous, the report of the last run, if any.
+func newDeployReport(previous *deployReport) *deployReport {
+	report := &deployReport{SchemaVersion: 1, StartedAt: time.Now().UTC()}
+	for _, name := range deployPhaseOrder {
+		outcome := deployPhaseOutcome{Name: name, Status: phaseNotRun}
+		if previous != nil {
+			if prev := previous.phase(name); prev != nil {
+				outcome = *prev
+			}
+		}
+		report.Phases = append(report.Phases, outcome)
+	}
+	if previous != nil {
+		report.Image = previous.Image
+	}
+	return report
+}
+
+func (r *deployReport) phase(name string) *deployPhaseOutcome {
+	for i := range r.Phases {
+		if r.Phases[i].Name == name {
+			return &r.Phases[i]
+		}
+	}
+	return nil
+}
+
+// resetPhases marks the phases this run executes as not run yet, so a
+// failure before a phase starts does not leave an earlier run's outcome.
+func (r *deployReport) resetPhases(selected map[string]bool) {
+	for i := range r.Phases {
+		if selected[r.Phases[i].Name] {
+			r.Phases[i] = deployPhaseOutcome{Name: r.Phases[i].Name, Status: phaseNotRun}
+		}
+	}
+}
+
+// finishImagePhases records the outcome of the registry handler that ran the
+// build and push phases from start. The build phase records itself; an
+// error is the push's unless the build failed or never started.
+func (r *deployReport) finishImagePhases(start time.Time, err error) {
+	build := r.phase(phaseBuild)
+	if deployPhases[phaseBuild] {
+		if build.Status == phaseFailed {
+			return
+		}
+		if build.Status == phaseNotRun {
+			if err != nil {
+				r.finishPhase(phaseBuild, start, err)
+				return
+			}
+		} else {
+			start = build.FinishedAt
+		}
+	}
+	if deployPhases[phasePush] {
+		r.finishPhase(phasePush, start, err)
+	}
+}
+
+// finishPhase records the outcome of a phase that started at start.
+func (r *deployReport) finishPhase(name string, start time.Time, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.phase(name)
+	if p == nil {
+		return
+	}
+	p.Status, p.Error = phaseSucceeded, ""
+	p.FinishedAt = time.Now().UTC()
+	p.Duration = time.Since(start).Round(time.Second).String()
+	if err != nil {
+		p.Status, p.Error = phaseFailed, err.Error()
+	}
+}
+
+// setImage records the image the push phase produced.
+func (r *deployReport) setImage(image deployReportImage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Image = image
+}
+
+// startRelease records that the helm phase is deploying release
+// (namespace/name), and finishRelease that it has deployed it.
+func (r *deployReport) startRelease(release string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = release
+}
+
+func (r *deployReport) finishRelease(release string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.released = append(r.released, release)
+	r.current = ""
+}
+
+// interrupt turns the report into the resume token of a run of the selected
+// phases that was stopped before it finished.
+func (r *deployReport) interrupt(selected map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resume := &resumeToken{
+		InterruptedAt: time.Now().UTC(),
+		Released:      r.released,
+		Release:       r.current,
+	}
+	for _, name := range deployPhaseOrder {
+		if selected[name] {
+			resume.Phases = append(resume.Phases, name)
+		}
+	}
+	r.Resume = resume
+}
+
+// remainingPhases returns the phases of the interrupted run that did not
+// succeed before it stopped.
+func (r *deployReport) remainingPhases() map[string]bool {
+	remaining := map[string]bool{}
+	for _, name := range r.Resume.Phases {
+		if p := r.phase(name); p == nil || p.Status != phaseSucceeded {
+			remaining[name] = true
+		}
+	}
+	return remaining
+}
+
+// save writes the report, creating its directory.
+func (r *deployReport) save(path string, runErr error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now().UTC()
+	r.Result, r.Error = phaseSucceeded, ""
+	if runErr != nil {
+		r.Result, r.Error = phaseFailed, runErr.Error()
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// skipPush reports whether the push phase is deselected, logging that the
+// image built for it stays local.
+func skipPush(image string) bool {
+	if deployPhases[phasePush] {
+		return false
+	}
+	pterm.Info.Printf("⏭️  Skipping push phase; %s stays local\n", image)
+	return true
+}
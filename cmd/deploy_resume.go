@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+)
+
+// deployResume and deployAbort continue or clean up after a run that was
+// interrupted, from the resume token in its run report.
+var (
+	deployResume bool
+	deployAbort  bool
+)
+
+// resumeFrom is the resume token the current run continues, when it runs
+// with --resume.
+var resumeFrom *resumeToken
+
+// errDeployAborted is recorded in the run report by --abort.
+var errDeployAborted = errors.New("interrupted run aborted with --abort")
+
+// handleDeployInterrupt saves the run report, with a resume token, when the
+// run is interrupted by SIGINT or SIGTERM, and exits. Helm operations can't
+// be cancelled half way, so a release the run was deploying may be left
+// pending; --resume and --abort clean it up. The returned function stops
+// the handler.
+func handleDeployInterrupt(reportPath string) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			deployRun.interrupt(deployPhases)
+			if err := deployRun.save(reportPath, fmt.Errorf("interrupted by %s", sig)); err != nil {
+				pterm.Error.Printfln("Deploy interrupted, and the run report %s could not be written: %v", reportPath, err)
+				os.Exit(130)
+			}
+			pterm.Warning.Printfln("Deploy interrupted. Continue it with 'smurf deploy --resume', or clean up with 'smurf deploy --abort'.")
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// resumePhases returns the phases an interrupted run had left, from the
+// resume token of its run report.
+func resumePhases(previous *deployReport) (map[string]bool, error) {
+	if previous == nil || previous.Resume == nil {
+		return nil, fmt.Errorf("no interrupted deploy to resume in %s", deployReportPath)
+	}
+	phases := previous.remainingPhases()
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("the interrupted deploy had finished all its phases; nothing to resume (clear it with --abort)")
+	}
+	var names []string
+	for _, name := range deployPhaseOrder {
+		if phases[name] {
+			names = append(names, name)
+		} else {
+			phases[name] = false
+		}
+	}
+	pterm.Info.Printfln("Resuming the deploy interrupted at %s: running %s", previous.Resume.InterruptedAt.Local().Format("2006-01-02 15:04:05"), strings.Join(names, ", "))
+	return phases, nil
+}
+
+// resumeRelease prepares release (namespace/name) for the helm phase of a
+// resumed run. It reports false for a release the interrupted run had
+// already deployed, and cleans up the one it was deploying when it stopped.
+func resumeRelease(namespace, name string) (bool, error) {
+	if resumeFrom == nil {
+		return true, nil
+	}
+	key := namespace + "/" + name
+	if slices.Contains(resumeFrom.Released, key) {
+		pterm.Info.Printfln("Release %s was deployed before the interruption; skipping", key)
+		deployRun.finishRelease(key)
+		return false, nil
+	}
+	if key == resumeFrom.Release {
+		if _, err := helm.RecoverPendingRelease(name, namespace, configs.Debug); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// abortDeploy cleans up after the interrupted run of previous: the release
+// it was deploying is uninstalled when its install never finished, or rolled
+// back when its upgrade didn't. Releases the run had finished are left
+// deployed. The resume token is then dropped.
+func abortDeploy(cfg *configs.Config, previous *deployReport) error {
+	if previous == nil || previous.Resume == nil {
+		return fmt.Errorf("no interrupted deploy to abort in %s", deployReportPath)
+	}
+	resume := previous.Resume
+	if resume.Release != "" {
+		namespace, name, _ := strings.Cut(resume.Release, "/")
+		if cfg.Selm.Cluster.Name != "" {
+			if _, err := helm.ConnectCluster(cfg.Selm.Cluster, namespace); err != nil {
+				return err
+			}
+		}
+		recovered, err := helm.RecoverPendingRelease(name, namespace, configs.Debug)
+		if err != nil {
+			return err
+		}
+		if recovered == "" {
+			pterm.Info.Printfln("Release %s has no pending operation; nothing to clean up", resume.Release)
+		} else {
+			pterm.Success.Printfln("Release %s %s", resume.Release, recovered)
+		}
+	}
+	if len(resume.Released) > 0 {
+		pterm.Info.Printfln("Releases deployed before the interruption are left as they are: %s", strings.Join(resume.Released, ", "))
+	}
+
+	previous.Resume = nil
+	if err := previous.save(deployReportPath, errDeployAborted); err != nil {
+		return fmt.Errorf("failed to update the run report %s: %w", deployReportPath, err)
+	}
+	pterm.Success.Println("Interrupted deploy aborted")
+	return nil
+}
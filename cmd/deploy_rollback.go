@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+)
+
+// deployRollbackOnFailure and deployDeletePushedTag override
+// deploy.rollbackOnFailure and deploy.deletePushedTag for this run.
+var (
+	deployRollbackOnFailure bool
+	deployDeletePushedTag   bool
+)
+
+// deployUndo is what the current run changed, recorded as it goes when it
+// runs with rollback on failure, and nil otherwise.
+var deployUndo *undoLog
+
+// undoLog records the state a run replaces, so that a run whose helm phase
+// fails can put it back: the values files as they were before the image was
+// written to them, the revision of each release before it was deployed, and
+// the image tag the run pushed, when it is to be deleted.
+type undoLog struct {
+	valuesFiles []savedFile
+	releases    []savedRelease
+	image       string
+	digest      string
+}
+
+type savedFile struct {
+	path string
+	data []byte
+	mode os.FileMode
+}
+
+type savedRelease struct {
+	name, namespace string
+	revision        int // 0 when the run installs the release
+}
+
+// saveValuesFile keeps the content of path before the run first writes it.
+func (u *undoLog) saveValuesFile(path string) error {
+	if u == nil {
+		return nil
+	}
+	for _, f := range u.valuesFiles {
+		if f.path == path {
+			return nil
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	u.valuesFiles = append(u.valuesFiles, savedFile{path: path, data: data, mode: info.Mode().Perm()})
+	return nil
+}
+
+// saveRelease records the revision of a release before the run deploys it.
+func (u *undoLog) saveRelease(name, namespace string) error {
+	if u == nil {
+		return nil
+	}
+	revision, err := helm.DeployedRevision(name, namespace, configs.Debug)
+	if err != nil {
+		return err
+	}
+	u.releases = append(u.releases, savedRelease{name: name, namespace: namespace, revision: revision})
+	return nil
+}
+
+// savePushedImage records the image the run pushed, to be deleted from the
+// registry on rollback. The digest guards against deleting a tag someone
+// pushed again since; when the push didn't report it, it is resolved now.
+func (u *undoLog) savePushedImage(image, digest string) {
+	if u == nil {
+		return
+	}
+	if digest == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), configs.Timeouts.PushTimeout())
+		defer cancel()
+		resolved, err := docker.RemoteDigest(ctx, image)
+		if err != nil {
+			pterm.Warning.Printfln("Could not resolve the digest of %s; it won't be deleted on rollback: %v", image, err)
+			return
+		}
+		digest = resolved
+	}
+	u.image, u.digest = image, digest
+}
+
+// rollback undoes the run after its helm phase failed with cause: the
+// releases it touched are put back at their previous revision, last
+// deployed first, the values files are restored and the pushed tag is
+// deleted. It returns cause, joined with what could not be undone.
+func (u *undoLog) rollback(cause error) error {
+	pterm.Warning.Println("Deploy failed; rolling back what this run changed...")
+	var errs []error
+	for i := len(u.releases) - 1; i >= 0; i-- {
+		r := u.releases[i]
+		done, err := helm.RevertRelease(r.name, r.namespace, r.revision, configs.Debug)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case done != "":
+			pterm.Success.Printfln("Release %s/%s %s", r.namespace, r.name, done)
+		}
+	}
+	for i := len(u.valuesFiles) - 1; i >= 0; i-- {
+		f := u.valuesFiles[i]
+		if err := os.WriteFile(f.path, f.data, f.mode); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", f.path, err))
+			continue
+		}
+		pterm.Success.Printfln("Restored %s", f.path)
+	}
+	if u.image != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), configs.Timeouts.PushTimeout())
+		defer cancel()
+		if err := docker.DeleteRemoteTag(ctx, u.image, u.digest); err != nil {
+			errs = append(errs, err)
+		} else {
+			pterm.Success.Printfln("Deleted the pushed tag %s", u.image)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(cause, fmt.Errorf("rollback incomplete: %w", errors.Join(errs...)))
+	}
+	pterm.Info.Println("Rolled back; the cluster and the values files are as they were before this run.")
+	return cause
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/stats"
+	"github.com/pterm/pterm"
+)
+
+// recordDeployDurations adds the phases this run executed to the deploy
+// duration history and warns about each one that took much longer than it
+// usually does for the service. Like the ledger, the history is bookkeeping:
+// a failure to use it is a warning and never changes the deploy's result.
+func recordDeployDurations(cfg *configs.Config, runErr error) {
+	service := deployService(cfg)
+	if service == "" || deployRun == nil {
+		return
+	}
+	run := stats.Run{Time: deployRun.StartedAt, Result: phaseSucceeded, Phases: map[string]time.Duration{}}
+	if runErr != nil {
+		run.Result = phaseFailed
+	}
+	for _, name := range deployPhaseOrder {
+		p := deployRun.phase(name)
+		if !deployPhases[name] || p == nil || p.Status == phaseNotRun {
+			continue
+		}
+		if d, err := time.ParseDuration(p.Duration); err == nil {
+			run.Phases[name] = d
+		}
+	}
+	if len(run.Phases) == 0 {
+		return
+	}
+
+	path, err := stats.HistoryPath()
+	if err != nil {
+		pterm.Warning.Printfln("Could not record deploy durations: %v", err)
+		return
+	}
+	h, err := stats.Open(path)
+	if err != nil {
+		pterm.Warning.Printfln("Could not record deploy durations: %v", err)
+		return
+	}
+	defer h.Close()
+
+	if run.Result == phaseSucceeded {
+		past, err := h.Runs(service)
+		if err != nil {
+			pterm.Warning.Printfln("Could not read the deploy duration history: %v", err)
+		}
+		for _, r := range stats.Regressions(past, run, cfg.Deploy.SlowdownThreshold) {
+			pterm.Warning.Printfln("🐢 The %s phase took %s, %.1fx the median of %s over recent deploys of %s",
+				r.Phase, r.Duration, r.Factor, r.Median, service)
+		}
+	}
+	if err := h.Record(service, run); err != nil {
+		pterm.Warning.Printfln("Could not record deploy durations: %v", err)
+	}
+}
+
+// deployService names the service a deploy's durations are kept under: the
+// image it builds, or the release it deploys when it builds none.
+func deployService(cfg *configs.Config) string {
+	if cfg.Sdkr.ImageName != "" {
+		return cfg.Sdkr.ImageName
+	}
+	release, _ := helmDeployTarget(cfg.Selm)
+	return release
+}
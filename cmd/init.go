@@ -8,7 +8,7 @@ import (
 // defaultYamlContent is the full smurf.yaml scaffold: the union of the sdkr
 // section written by "smurf sdkr init" and the selm section written by
 // "smurf selm init", so the three init commands stop producing conflicting
-// schemas.
+// schemas. It also carries the timeouts section with the built-in defaults.
 var defaultYamlContent = `sdkr:
   docker_username: "my-docker-username"
   docker_password: "my-docker-password"
@@ -29,6 +29,7 @@ var defaultYamlContent = `sdkr:
   dockerHub: false
   ghcrRepo: false
   gcpRepo: false
+  azureACR: false
 selm:
   deployHelm: false
   releaseName: "Release Name"
@@ -36,6 +37,11 @@ selm:
   chartName: "Chart Name"
   fileName: ""
   revision: 0
+timeouts:
+  build: 1500
+  push: 600
+  helmWait: 600
+  readiness: 300
 `
 
 // generateConfig represents the "smurf init" command, which generates a
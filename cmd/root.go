@@ -2,7 +2,12 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/internal/ci"
+	"github.com/clouddrove/smurf/internal/helm"
 	"github.com/pterm/pterm"
 	"github.com/pterm/pterm/putils"
 	"github.com/spf13/cobra"
@@ -31,6 +36,11 @@ var RootCmd = &cobra.Command{
 }
 
 func Execute() {
+	if err := checkPinnedVersion(os.Args[1:]); err != nil {
+		pterm.Error.Println(err)
+		os.Exit(1)
+	}
+	foldCommandsIntoSections(RootCmd)
 	err := RootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -47,10 +57,80 @@ func init() {
 		originalHelpFunc(cmd, args)
 	})
 
+	RootCmd.PersistentFlags().BoolVar(&ai.Offline, "ai-offline", false, "Explain failures only from the local AI explanation cache (~/.smurf/ai-cache), without calling the AI provider")
+	RootCmd.PersistentFlags().Var(&ci.Current, "ci", "CI integration mode (gitlab): dotenv artifacts, collapsible log sections and OIDC auth (default $SMURF_CI)")
+	RootCmd.PersistentFlags().StringVar(&ci.DotenvPath, "ci-dotenv", ci.DefaultDotenv, "Dotenv artifact --ci writes the image digest and release revision to")
+	_ = RootCmd.RegisterFlagCompletionFunc("ci", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return ci.Modes, cobra.ShellCompDirectiveNoFileComp
+	})
+	cobra.OnInitialize(applyUserConfig, setupCIAuth)
+
 	// Add commands
 	RootCmd.AddCommand(versionCmd)
 }
 
+// applyUserConfig applies the per-user settings of the user configuration
+// (~/.smurf/config.yaml); its smurf.yaml defaults are merged when smurf.yaml
+// is loaded. The environment wins over the file.
+func applyUserConfig() {
+	user, err := configs.LoadUserConfig()
+	if err != nil {
+		pterm.Warning.Printfln("Ignoring the user configuration: %v", err)
+		return
+	}
+	switch user.Color {
+	case "never":
+		pterm.DisableColor()
+	case "auto":
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			pterm.DisableColor()
+		}
+	}
+	if user.AI.Model != "" && os.Getenv("OPENAI_MODEL") == "" {
+		_ = os.Setenv("OPENAI_MODEL", user.AI.Model)
+	}
+	if len(user.PluginPaths) > 0 && os.Getenv("HELM_PLUGINS") == "" {
+		helm.UsePluginPaths(user.PluginPaths)
+	}
+}
+
+// setupCIAuth points the cloud SDKs at the job's OIDC token in CI mode,
+// once the flags are parsed and before any command authenticates.
+func setupCIAuth() {
+	clouds, err := ci.SetupOIDC()
+	if err != nil {
+		pterm.Warning.Printfln("GitLab OIDC authentication not set up: %v", err)
+		return
+	}
+	if len(clouds) > 0 {
+		pterm.Info.Printfln("Authenticating to %s with the GitLab OIDC token", strings.Join(clouds, ", "))
+	}
+}
+
+// foldCommandsIntoSections wraps every runnable command under c in a CI log
+// section named after its command path. Sections are only written in CI
+// mode, which is known once the flags are parsed, so this is decided when
+// the command runs.
+func foldCommandsIntoSections(c *cobra.Command) {
+	for _, sub := range c.Commands() {
+		foldCommandsIntoSections(sub)
+	}
+	switch {
+	case c.RunE != nil:
+		run := c.RunE
+		c.RunE = func(cmd *cobra.Command, args []string) error {
+			defer ci.Section(cmd.CommandPath(), cmd.CommandPath(), false)()
+			return run(cmd, args)
+		}
+	case c.Run != nil:
+		run := c.Run
+		c.Run = func(cmd *cobra.Command, args []string) {
+			defer ci.Section(cmd.CommandPath(), cmd.CommandPath(), false)()
+			run(cmd, args)
+		}
+	}
+}
+
 // display smurf word
 func displayBigText() {
 	pterm.DefaultBigText.WithLetters(
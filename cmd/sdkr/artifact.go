@@ -0,0 +1,126 @@
+package sdkr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	artifactType        string
+	artifactAnnotations []string
+	artifactOutputDir   string
+	artifactTimeout     int
+)
+
+// artifactCmd groups the commands for generic OCI artifacts: files such as
+// Helm charts, SBOMs, WASM modules or config bundles stored in a registry
+// next to images.
+var artifactCmd = &cobra.Command{
+	Use:   "artifact",
+	Short: "Push and pull generic OCI artifacts",
+	Long: `Store arbitrary files in an OCI registry and fetch them back, as the oras CLI does.
+Credentials are resolved per registry as for image pushes: ECR through the AWS
+SDK, GHCR, GCP and Docker Hub from their environment variables, and the docker
+config as a fallback.`,
+}
+
+var artifactPushCmd = &cobra.Command{
+	Use:   "push REFERENCE FILE[:MEDIA_TYPE]...",
+	Short: "Push files to a registry as an OCI artifact",
+	Long: `Push one or more files to a registry as a single OCI artifact. Each file becomes
+a layer named after it; its media type defaults to ` + docker.DefaultArtifactMediaType + `.
+A directory is pushed as a gzipped tarball and unpacked again on pull.`,
+	Args:         cobra.MinimumNArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			artifactTimeout = configs.Timeouts.Push
+		}
+		annotations, err := parseAnnotations(artifactAnnotations)
+		if err != nil {
+			return err
+		}
+		result, err := docker.PushArtifact(args[0], docker.ArtifactPushOptions{
+			Files:        args[1:],
+			ArtifactType: artifactType,
+			Annotations:  annotations,
+			Timeout:      time.Duration(artifactTimeout) * time.Second,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+		docker.PrintArtifactResult(result)
+		return nil
+	},
+	Example: `
+  # Push an SBOM
+  smurf sdkr artifact push ghcr.io/my-org/myapp-sbom:v1.4.0 sbom.spdx.json:application/spdx+json \
+    --artifact-type application/vnd.example.sbom
+
+  # Push a config bundle directory with an annotation
+  smurf sdkr artifact push 123456789012.dkr.ecr.us-east-1.amazonaws.com/config:prod ./config \
+    --annotation org.opencontainers.image.source=https://github.com/my-org/config
+`,
+}
+
+var artifactPullCmd = &cobra.Command{
+	Use:          "pull REFERENCE",
+	Short:        "Pull the files of an OCI artifact",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			artifactTimeout = configs.Timeouts.Push
+		}
+		result, err := docker.PullArtifact(args[0], artifactOutputDir, time.Duration(artifactTimeout)*time.Second, useAI)
+		if err != nil {
+			return err
+		}
+		docker.PrintArtifactResult(result)
+		return nil
+	},
+	Example: `
+  smurf sdkr artifact pull ghcr.io/my-org/myapp-sbom:v1.4.0 -o ./sbom
+  smurf sdkr artifact pull ghcr.io/my-org/config@sha256:4f1c...
+`,
+}
+
+// parseAnnotations turns KEY=VALUE flags into a map.
+func parseAnnotations(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	annotations := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --annotation %q: want KEY=VALUE", pair)
+		}
+		annotations[key] = value
+	}
+	return annotations, nil
+}
+
+func init() {
+	artifactPushCmd.Flags().StringVar(&artifactType, "artifact-type", "", "Artifact type of the manifest (default application/vnd.unknown.artifact.v1)")
+	artifactPushCmd.Flags().StringArrayVar(&artifactAnnotations, "annotation", []string{}, "Manifest annotation as KEY=VALUE (can be repeated)")
+	artifactPullCmd.Flags().StringVarP(&artifactOutputDir, "output", "o", ".", "Directory to write the artifact's files to")
+	for _, c := range []*cobra.Command{artifactPushCmd, artifactPullCmd} {
+		c.Flags().IntVar(&artifactTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for the transfer in seconds (overrides timeouts.push in smurf.yaml)")
+		c.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+		artifactCmd.AddCommand(c)
+	}
+
+	sdkrCmd.AddCommand(artifactCmd)
+}
@@ -0,0 +1,32 @@
+package sdkr
+
+import (
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+// artifactsOpts backs the artifacts manifest flags shared by the provision
+// commands; only one command runs per process, so sharing them is safe.
+var artifactsOpts docker.ArtifactsOptions
+
+// addArtifactsFlags registers the artifacts manifest flags on a command that
+// builds and pushes an image.
+func addArtifactsFlags(c *cobra.Command) {
+	c.Flags().StringVar(&artifactsOpts.Path, "artifacts-manifest", "", "Write a JSON manifest of the pushed image, its digest, SBOM, scan report and signatures to this path")
+	c.Flags().StringVar(&artifactsOpts.SBOM, "sbom", "", "SBOM file to reference in the artifacts manifest")
+	c.Flags().StringVar(&artifactsOpts.ScanReport, "scan-report", "", "Vulnerability scan report to reference in the artifacts manifest")
+	c.Flags().StringArrayVar(&artifactsOpts.Signatures, "signature", []string{}, "Signature reference to record in the artifacts manifest (repeatable)")
+	c.Flags().BoolVar(&artifactsOpts.Attach, "attach-artifacts", false, "Also attach the artifacts manifest to the image as an OCI referrer (requires oras)")
+}
+
+// emitArtifactsManifest writes (and optionally attaches) the artifacts
+// manifest for a pushed image when --artifacts-manifest was given. It must
+// run before the local image is deleted, since the digest comes from the
+// local Docker daemon.
+func emitArtifactsManifest(image string) error {
+	if artifactsOpts.Path == "" {
+		return nil
+	}
+	_, err := docker.WriteArtifactsManifest(image, artifactsOpts)
+	return err
+}
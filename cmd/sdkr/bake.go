@@ -0,0 +1,138 @@
+package sdkr
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ci"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bakeFiles []string
+	bakeSet   []string
+	bakePush  bool
+)
+
+// bakeCmd builds the targets of a buildx bake file, and with --push publishes
+// them and runs smurf's post-push steps (artifacts manifest, webhooks) for
+// every pushed image.
+var bakeCmd = &cobra.Command{
+	Use:   "bake [TARGET...]",
+	Short: "Build the targets of a buildx bake file",
+	Long: `Build several images at once from a buildx bake file (docker-bake.hcl,
+docker-bake.json or a compose file), honoring its groups, targets, platforms,
+tags and args. Targets and groups are given as arguments; without any, bake
+builds its "default" group.
+
+Without --push, single-platform images are loaded into the local Docker
+daemon. With --push, bake publishes every tag itself, and smurf then writes
+the artifacts manifest and calls the push webhooks for each pushed image,
+using the digest bake reports. Requires docker with the buildx plugin.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !bakePush && (artifactsOpts.Path != "" || len(webhookURLs) > 0) {
+			return errors.New("--artifacts-manifest and --webhook need --push")
+		}
+		if bakePush {
+			if err := confirmPush(); err != nil {
+				return err
+			}
+		}
+
+		results, err := docker.Bake(docker.BakeOptions{
+			Files:   bakeFiles,
+			Targets: args,
+			Set:     bakeSet,
+			Push:    bakePush,
+			NoCache: configs.NoCache,
+			Timeout: time.Duration(configs.BuildTimeout) * time.Second,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+		if err := docker.PrintBakeResults(results); err != nil {
+			return err
+		}
+		if !bakePush {
+			pterm.Success.Printfln("Baked %d target(s)", len(results))
+			return nil
+		}
+
+		hooks, err := pushWebhooks()
+		if err != nil {
+			return err
+		}
+		var errs []error
+		exported := false
+		for _, r := range results {
+			if len(r.Tags) == 0 {
+				continue
+			}
+			if artifactsOpts.Path != "" {
+				opts := artifactsOpts
+				opts.Path = bakeArtifactsPath(artifactsOpts.Path, r.Target, len(results))
+				opts.Digest = r.Digest
+				if _, err := docker.WriteArtifactsManifest(r.Tags[0], opts); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			// The first pushed target is the image later CI jobs deploy.
+			if !exported {
+				errs = append(errs, ci.Export(ci.ImageVars(r.Tags[0], r.Digest)))
+				exported = true
+			}
+			for _, tag := range r.Tags {
+				if len(hooks) > 0 {
+					errs = append(errs, docker.NotifyWebhooks(hooks, docker.NewPushEvent(tag, r.Digest)))
+				}
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Baked and pushed %d target(s)", len(results))
+		return nil
+	},
+	Example: `
+  # Build the default group of ./docker-bake.hcl into the local daemon
+  smurf sdkr bake
+
+  # Build and push two targets from a given bake file
+  smurf sdkr bake -f docker-bake.hcl api worker --push -y
+
+  # Override a field of every target, and record what was pushed
+  smurf sdkr bake --set "*.args.VERSION=1.4.0" --push -y --artifacts-manifest artifacts.json
+`,
+}
+
+// bakeArtifactsPath gives each target its own artifacts manifest when a bake
+// pushes several: artifacts.json becomes artifacts-<target>.json.
+func bakeArtifactsPath(path, target string, targets int) string {
+	if targets <= 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + target + ext
+}
+
+func init() {
+	bakeCmd.Flags().StringArrayVarP(&bakeFiles, "file", "f", []string{}, "Bake file (repeatable; default: docker-bake.hcl, docker-bake.json or compose file in the current directory)")
+	bakeCmd.Flags().StringArrayVar(&bakeSet, "set", []string{}, "Override a target field, as targetpattern.key=value (repeatable)")
+	bakeCmd.Flags().BoolVar(&bakePush, "push", false, "Push the built images to their registries")
+	bakeCmd.Flags().BoolVarP(&configs.ConfirmAfterPush, "yes", "y", false, "Push without confirmation")
+	bakeCmd.Flags().BoolVar(&configs.NoCache, "no-cache", false, "Do not use cache when building the images")
+	bakeCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Bake timeout in seconds (overrides timeouts.build in smurf.yaml)")
+	bakeCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addArtifactsFlags(bakeCmd)
+	addWebhookFlags(bakeCmd)
+	sdkrCmd.AddCommand(bakeCmd)
+}
@@ -26,10 +26,17 @@ var buildCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		builder, err := resolveBuilder(cmd)
+		if err != nil {
+			return err
+		}
+
 		var imageName, tag string
 
 		if len(args) >= 1 {
-			var err error
 			imageName, tag, err = configs.ParseImage(args[0])
 			if err != nil {
 				return fmt.Errorf("invalid image format: %w", err)
@@ -91,17 +98,30 @@ var buildCmd = &cobra.Command{
 			Platform:       configs.Platform,
 			Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
 			BuildKit:       configs.BuildKit,
+			RemoteBuild:    configs.RemoteBuild,
+			Remote:         remoteBuildOptions(),
+			Reproducible:   configs.Reproducible,
+			CacheFrom:      configs.CacheFrom,
+			CacheTo:        configs.CacheTo,
 		}
 
-		err = docker.Build(imageName, tag, opts, useAI)
+		err = docker.BuildWith(builder, imageName, tag, opts, useAI)
 		if err != nil {
 			return err
 		}
+		if configs.RemoteBuild != "" {
+			pterm.Info.Printfln("The image is in smurf's image store; push it with --builder %s", docker.BuilderBuildkitd)
+		}
 		return nil
 	},
 	Example: `
 smurf sdkr build my-image:v1
 smurf sdkr build my-image:v1 --file Dockerfile --context ./build-context --no-cache --build-arg key1=value1,key2=value2 --target my-target --platform linux/amd64 --timeout 400
+smurf sdkr build my-image:v1 --builder containerd
+smurf sdkr build my-image:v1 --remote-build k8s  # build on a buildkitd pod in the cluster
+smurf sdkr build my-image:v1 --builder buildkitd --reproducible  # same sources, same digest on every machine
+smurf sdkr build my-image:v1 --cache-from type=registry,ref=ghcr.io/my-org/my-image:buildcache \
+  --cache-to type=registry,ref=ghcr.io/my-org/my-image:buildcache,mode=max  # reuse layers across CI runs
 smurf sdkr build
 # In the last example, it will read "image:v1" from config and use the parsed image name and tag
 `,
@@ -114,8 +134,12 @@ func init() {
 	buildCmd.Flags().StringArrayVar(&configs.BuildArgs, "build-arg", []string{}, "Set build-time variables (key=value). Repeat the flag or pass comma-separated pairs")
 	buildCmd.Flags().StringVar(&configs.Target, "target", "", "Set the target build stage to build")
 	buildCmd.Flags().StringVar(&configs.Platform, "platform", "", "Set the platform for the build (e.g., linux/amd64, linux/arm64)")
-	buildCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", 1500, "Set the build timeout in seconds")
+	buildCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Set the build timeout in seconds (overrides timeouts.build in smurf.yaml)")
 	buildCmd.Flags().BoolVar(&configs.BuildKit, "buildkit", false, "Enable BuildKit for advanced Dockerfile features")
+	addBuilderFlag(buildCmd)
+	addRemoteBuildFlags(buildCmd)
+	addReproducibleFlag(buildCmd)
+	addCacheFlags(buildCmd)
 	buildCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	sdkrCmd.AddCommand(buildCmd)
@@ -0,0 +1,78 @@
+package sdkr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// addBuildxFlags registers the buildx flags on a provision command.
+func addBuildxFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&configs.BuildxPush, "push", false, "Build with buildx and push from the builder, without loading the image into the local Docker daemon (implied by several --platform values)")
+	addCacheFlags(c)
+}
+
+// addCacheFlags registers the build cache flags on a command that builds.
+func addCacheFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&configs.CacheFrom, "cache-from", []string{}, "Import the build cache from this source, e.g. type=registry,ref=REPO:buildcache (can be repeated; default: sdkr.cacheFrom in smurf.yaml)")
+	c.Flags().StringArrayVar(&configs.CacheTo, "cache-to", []string{}, "Export the build cache to this destination, e.g. type=registry,ref=REPO:buildcache,mode=max (can be repeated; default: sdkr.cacheTo in smurf.yaml)")
+}
+
+// applyBuildCache uses sdkr.cacheFrom and sdkr.cacheTo from smurf.yaml for
+// the cache flags c has and that were not given.
+func applyBuildCache(c *cobra.Command) error {
+	from, to, err := configs.LoadSdkrBuildCache(configs.FileName)
+	if err != nil {
+		return err
+	}
+	if f := c.Flags().Lookup("cache-from"); f != nil && !f.Changed {
+		configs.CacheFrom = from
+	}
+	if f := c.Flags().Lookup("cache-to"); f != nil && !f.Changed {
+		configs.CacheTo = to
+	}
+	return nil
+}
+
+// withBuildx adds the buildx flags to opts. A multi-platform image can only
+// leave the builder by being pushed, so several platforms imply --push.
+func withBuildx(opts *docker.BuildOptions) {
+	opts.CacheFrom = configs.CacheFrom
+	opts.CacheTo = configs.CacheTo
+	opts.Push = configs.BuildxPush || opts.MultiPlatform()
+}
+
+// provisionWithBuildx builds target with buildx and pushes it from the
+// builder, in place of the build, tag and push steps of a provision command.
+func provisionWithBuildx(cmd *cobra.Command, target string, opts docker.BuildOptions) error {
+	if cmd.Flags().Changed("smoke-test") || smokeTestHealth != "" {
+		return errors.New("--smoke-test needs the image in the local Docker daemon; it cannot be combined with --push or several --platform values")
+	}
+	// The builder pushes as soon as the build ends, so confirm before it starts.
+	if err := confirmPush(); err != nil {
+		return err
+	}
+
+	name, tag := target, "latest"
+	if i := strings.LastIndex(target, ":"); i > strings.LastIndex(target, "/") {
+		name, tag = target[:i], target[i+1:]
+	}
+	if err := docker.Build(name, tag, opts, useAI); err != nil {
+		return err
+	}
+
+	if err := emitArtifactsManifest(target); err != nil {
+		return err
+	}
+	if err := notifyPushWebhooks(target); err != nil {
+		return err
+	}
+	if configs.DeleteAfterPush {
+		pterm.Info.Println("The image was pushed from the builder; there is no local image to delete.")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sdkr
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/spf13/cobra"
+)
+
+// completeImageNames returns a cobra ValidArgsFunction that suggests the
+// image references declared in smurf.yaml (sdkr.imageName and
+// sdkr.targetImageTag) for commands taking IMAGE[:TAG] arguments. maxArgs is
+// the number of image positions the command accepts, so completion stops
+// once they are all filled in.
+//
+// It never prints, and degrades to no completions when smurf.yaml is missing
+// or unreadable; those commands also accept images that exist only in the
+// local daemon, which is why the file-completion fallback stays disabled
+// rather than suggesting paths.
+func completeImageNames(maxArgs int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= maxArgs {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		data, err := configs.LoadConfig(configs.FileName)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var names []string
+		for _, name := range []string{data.Sdkr.ImageName, data.Sdkr.TargetImageTag} {
+			if name != "" && (len(names) == 0 || names[0] != name) {
+				names = append(names, name)
+			}
+		}
+
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	// Only ValidArgsFunction is set here, which (unlike
+	// RegisterFlagCompletionFunc) does not depend on the commands' flags
+	// already being registered by their own init().
+	for _, c := range []*cobra.Command{
+		buildCmd, scanCmd, removeCmd,
+		pushAcrCmd, pushEcrCmd, pushGcrCmd, pushHubCmd,
+		provisionAcrCmd, provisionEcrCmd, provisionGHCRCmd, provisionGcpCmd, provisionHubCmd,
+	} {
+		c.ValidArgsFunction = completeImageNames(1)
+	}
+	tagCmd.ValidArgsFunction = completeImageNames(2)
+}
@@ -0,0 +1,96 @@
+package sdkr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffPlatform string
+	diffRemote   bool
+	diffOutput   string
+	diffMax      int
+	diffTimeout  int
+)
+
+// diffCmd compares the layers and file systems of two images, e.g. to check
+// that a rebuild changed only what it should have before promoting it.
+var diffCmd = &cobra.Command{
+	Use:   "diff IMAGE[:TAG] IMAGE[:TAG]",
+	Short: "Compare the layers and files of two images",
+	Long: `Compare two images layer by layer and file by file: which layers they share,
+which files were added, removed or changed, and how the total size moved.
+Files are compared by content, type, mode and link target, not by modification
+time, so a reproducible rebuild shows no changes.
+
+An image is read from the local Docker daemon when it is there, and otherwise
+from its registry with the same credentials as 'sdkr push'; --remote always
+uses the registry. The second image may be given as just :TAG to use the
+repository of the first.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(diffOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", diffOutput)
+		}
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			diffTimeout = configs.Timeouts.Push
+		}
+
+		if diffOutput == "json" {
+			pterm.SetDefaultOutput(os.Stderr)
+			defer pterm.SetDefaultOutput(os.Stdout)
+		}
+		diff, err := docker.DiffImages(args[0], args[1], docker.ImageDiffOptions{
+			Platform: diffPlatform,
+			Remote:   diffRemote,
+			Timeout:  time.Duration(diffTimeout) * time.Second,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+
+		if diffOutput == "json" {
+			data, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		docker.PrintImageDiff(diff, diffMax)
+		return nil
+	},
+	Example: `
+  # Compare a rebuild with the image running in prod
+  smurf sdkr diff ghcr.io/my-org/app:v1.4.0 ghcr.io/my-org/app:v1.4.1
+
+  # Same repository, shorter
+  smurf sdkr diff ghcr.io/my-org/app:v1.4.0 :v1.4.1
+
+  # The arm64 images, as JSON
+  smurf sdkr diff app:v1 app:v2 --platform linux/arm64 -o json
+`,
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffPlatform, "platform", "linux/amd64", "Platform to compare for multi-platform images in a registry")
+	diffCmd.Flags().BoolVar(&diffRemote, "remote", false, "Read both images from their registries, even if they exist locally")
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "table", "output format (table|json)")
+	diffCmd.Flags().IntVar(&diffMax, "max", 100, "Maximum number of changed files to list (0 = all)")
+	diffCmd.Flags().IntVar(&diffTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for reading both images in seconds (overrides timeouts.push in smurf.yaml)")
+	diffCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	sdkrCmd.AddCommand(diffCmd)
+}
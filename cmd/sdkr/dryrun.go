@@ -0,0 +1,35 @@
+package sdkr
+
+import (
+	"os"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// provisionDryRun backs the --dry-run flag shared by the provision commands;
+// only one command runs per process, so sharing it is safe.
+var provisionDryRun bool
+
+// addDryRunFlag registers --dry-run on a command that builds and pushes an
+// image.
+func addDryRunFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&provisionDryRun, "dry-run", false, "Resolve credentials, compute the target image, measure the build context and validate the Dockerfile without building or pushing; print the plan as JSON")
+}
+
+// beginDryRun sends progress output to stderr for a dry run, so stdout
+// carries only the JSON plan. The returned func restores it.
+func beginDryRun() func() {
+	if !provisionDryRun {
+		return func() {}
+	}
+	pterm.SetDefaultOutput(os.Stderr)
+	return func() { pterm.SetDefaultOutput(os.Stdout) }
+}
+
+// printProvisionPlan prints the plan for building image and pushing it to
+// registry, and fails when the real run would.
+func printProvisionPlan(image, registry string, opts docker.BuildOptions) error {
+	return docker.PrintBuildPlan(os.Stdout, docker.PlanBuild(image, registry, opts))
+}
@@ -0,0 +1,24 @@
+package sdkr
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+// addFailOnFlag registers the scan severity gate shared by the local scan
+// and the ECR scan on push.
+func addFailOnFlag(c *cobra.Command) {
+	c.Flags().StringVar(&configs.ScanFailOn, "fail-on", "", "Fail when the scan finds vulnerabilities of this severity or higher (low|medium|high|critical)")
+	_ = c.RegisterFlagCompletionFunc("fail-on", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"low", "medium", "high", "critical"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// addEcrScanFlags registers the flags that wait for the scan ECR runs on
+// push on a command that pushes to ECR.
+func addEcrScanFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&configs.EcrScanFindings, "scan-findings", false, "Wait for ECR's scan on push and report its findings (new repositories are created with scan on push)")
+	c.Flags().DurationVar(&configs.EcrScanTimeout, "scan-timeout", docker.DefaultECRScanTimeout, "How long to wait for ECR's scan on push")
+	addFailOnFlag(c)
+}
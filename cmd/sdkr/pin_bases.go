@@ -0,0 +1,69 @@
+package sdkr
+
+import (
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pinBuildArgs []string
+	pinLockFile  string
+	pinCheck     bool
+)
+
+// pinBasesCmd pins the base images of a Dockerfile to the digests their
+// tags point to now, so a rebuild can't silently pick up a changed base.
+var pinBasesCmd = &cobra.Command{
+	Use:   "pin-bases [DOCKERFILE]",
+	Short: "Pin the FROM images of a Dockerfile to their current digests",
+	Long: `Resolve the digest each FROM image of a Dockerfile (./Dockerfile by
+default) currently points to in its registry, and rewrite the FROM as
+IMAGE:TAG@DIGEST. The tag stays for readers; the digest is what builds use.
+Images already pinned, earlier build stages and scratch are left alone.
+Registries are accessed with the same credentials as 'sdkr push'.
+
+With --lock FILE the Dockerfile is not changed; the digests are recorded in
+FILE instead, and --check --lock FILE fails when a base image no longer
+resolves to its locked digest, to notice base image updates.
+
+--check without --lock changes nothing and fails when a base image is not
+pinned. Builds do the same check themselves when sdkr.requirePinnedBases is
+true in smurf.yaml.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "Dockerfile"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		buildArgs, err := buildArgsFrom(pinBuildArgs)
+		if err != nil {
+			return err
+		}
+		return docker.PinBases(path, docker.PinBasesOptions{
+			BuildArgs: buildArgs,
+			LockFile:  pinLockFile,
+			Check:     pinCheck,
+		}, useAI)
+	},
+	Example: `
+  # Pin every base image of ./Dockerfile in place
+  smurf sdkr pin-bases
+
+  # Fail CI when a Dockerfile has unpinned base images
+  smurf sdkr pin-bases docker/api.Dockerfile --check
+
+  # Record the digests in a lock file, and later check the bases haven't moved
+  smurf sdkr pin-bases --lock bases.lock.yaml
+  smurf sdkr pin-bases --lock bases.lock.yaml --check
+`,
+}
+
+func init() {
+	pinBasesCmd.Flags().StringArrayVar(&pinBuildArgs, "build-arg", []string{}, "Value of a build argument used in FROM (key=value), over the ARG default")
+	pinBasesCmd.Flags().StringVar(&pinLockFile, "lock", "", "Record the digests in this lock file instead of rewriting the Dockerfile")
+	pinBasesCmd.Flags().BoolVar(&pinCheck, "check", false, "Change nothing; fail when a base image is unpinned (or differs from --lock)")
+	pinBasesCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	sdkrCmd.AddCommand(pinBasesCmd)
+}
@@ -13,17 +13,26 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// acrRemoteBuild selects a remote builder for provision-acr ("" builds locally).
+var acrRemoteBuild string
+
 // provisionAcrCmd sets up the "provision-acr" command, enabling the build,
 // and optional push of a Docker image to Azure Container Registry.
 // It supports default values from the config file if no arguments are provided,
 // as well as advanced features like specifying build args, timeouts, and
-// removing the local image once it's successfully pushed.
+// removing the local image once it's successfully pushed. With --remote-build=acr
+// the build runs on ACR Tasks and the image is pushed from there.
 var provisionAcrCmd = &cobra.Command{
 	Use:          "provision-acr [IMAGE_NAME[:TAG]]",
 	Short:        "Build and push a Docker image to Azure Container Registry.",
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		defer beginDryRun()()
+
 		var imageRef string
 		if len(args) == 1 {
 			imageRef = args[0]
@@ -37,21 +46,11 @@ var provisionAcrCmd = &cobra.Command{
 				return errors.New("image name (with optional tag) must be provided either as an argument or in the config")
 			}
 			imageRef = data.Sdkr.ImageName
-
-			if configs.SubscriptionID == "" {
-				configs.SubscriptionID = data.Sdkr.ProvisionAcrSubscriptionID
-			}
-			if configs.ResourceGroup == "" {
-				configs.ResourceGroup = data.Sdkr.ProvisionAcrResourceGroup
-			}
-			if configs.RegistryName == "" {
-				configs.RegistryName = data.Sdkr.ProvisionAcrRegistryName
-			}
 		}
 
-		if configs.SubscriptionID == "" || configs.ResourceGroup == "" || configs.RegistryName == "" {
+		if err := resolveAcr(); err != nil {
 			pterm.Error.Println("Azure subscription ID, resource group name, and registry name are required")
-			return errors.New("azure subscription ID, resource group name, and registry name are required")
+			return err
 		}
 
 		fullAcrImage := fmt.Sprintf("%s.azurecr.io/%s", configs.RegistryName, imageRef)
@@ -85,19 +84,78 @@ var provisionAcrCmd = &cobra.Command{
 			Platform:       configs.Platform,
 			Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
 		}
+		withBuildx(&buildOpts)
 
-		pterm.Info.Println("Starting ACR build...")
 		localImage, localImageName, localTag, parseErr := configs.NormalizeAcrLocalImage(imageRef)
 		if parseErr != nil {
 			pterm.Error.Println("Image Parse Err:", parseErr)
 			return parseErr
 		}
 
+		if provisionDryRun {
+			acrImage := fullAcrImage
+			if _, ref, err := configs.AcrImageReferences(localImage, configs.RegistryName+".azurecr.io"); err == nil {
+				acrImage = ref
+			}
+			return printProvisionPlan(acrImage, "acr", buildOpts)
+		}
+
+		switch acrRemoteBuild {
+		case "":
+			if !buildOpts.Push {
+				break
+			}
+			acrImage := fullAcrImage
+			if _, ref, err := configs.AcrImageReferences(localImage, configs.RegistryName+".azurecr.io"); err == nil {
+				acrImage = ref
+			}
+			if err := provisionWithBuildx(cmd, acrImage, buildOpts); err != nil {
+				return err
+			}
+			pterm.Success.Println("ACR provisioning completed successfully.")
+			return nil
+		case "acr":
+			if cmd.Flags().Changed("smoke-test") || smokeTestHealth != "" {
+				return errors.New("--smoke-test needs a local build; it cannot be combined with --remote-build")
+			}
+			// The run pushes the image itself, so confirm before it starts.
+			if err := confirmPush(); err != nil {
+				return err
+			}
+			pterm.Info.Printf("Offloading build of %s to ACR Tasks in %s...\n", localImage, configs.RegistryName)
+			if err := docker.BuildImageWithACRTask(
+				configs.SubscriptionID,
+				configs.ResourceGroup,
+				configs.RegistryName,
+				localImage,
+				buildOpts,
+				useAI,
+			); err != nil {
+				pterm.Error.Println("ACR remote build failed:", err)
+				return err
+			}
+			if _, acrImage, err := configs.AcrImageReferences(localImage, configs.RegistryName+".azurecr.io"); err == nil {
+				if err := notifyPushWebhooks(acrImage); err != nil {
+					return err
+				}
+			}
+			pterm.Success.Println("ACR provisioning completed successfully.")
+			return nil
+		default:
+			return fmt.Errorf("invalid --remote-build %q: only \"acr\" is supported", acrRemoteBuild)
+		}
+
+		pterm.Info.Println("Starting ACR build...")
+
 		if err := docker.Build(localImageName, localTag, buildOpts, useAI); err != nil {
 			return err
 		}
 		pterm.Success.Println("Build completed successfully.")
 
+		if err := runSmokeTest(cmd, localImage); err != nil {
+			return err
+		}
+
 		pushImage := imageRef
 		if pushImage == "" {
 			pushImage = fullAcrImage
@@ -120,6 +178,15 @@ var provisionAcrCmd = &cobra.Command{
 		}
 		pterm.Success.Println("Push to ACR completed successfully.")
 
+		if _, acrImage, err := configs.AcrImageReferences(localImage, configs.RegistryName+".azurecr.io"); err == nil {
+			if err := emitArtifactsManifest(acrImage); err != nil {
+				return err
+			}
+			if err := notifyPushWebhooks(acrImage); err != nil {
+				return err
+			}
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", localImage)
 			if err := docker.RemoveImage(localImage, useAI); err != nil {
@@ -135,6 +202,12 @@ var provisionAcrCmd = &cobra.Command{
 	Example: `
   smurf sdkr provision-acr myimage:v1 -s <SUBSCRIPTION_ID> -r <RESOURCE_GROUP> -g <REGISTRY_NAME>
   smurf sdkr provision-acr -f Dockerfile -c -a key1=value1 -a key2=value2 -t my-target -p linux/amd64 -y -d
+
+  # Build on ACR Tasks instead of the local Docker daemon
+  smurf sdkr provision-acr myimage:v1 -s <SUBSCRIPTION_ID> -r <RESOURCE_GROUP> -g <REGISTRY_NAME> --remote-build=acr -y
+
+  # Print what would be built and pushed, as JSON, without building
+  smurf sdkr provision-acr myimage:v1 -s <SUBSCRIPTION_ID> -r <RESOURCE_GROUP> -g <REGISTRY_NAME> --dry-run
 `,
 }
 
@@ -147,12 +220,22 @@ func init() {
 	provisionAcrCmd.Flags().BoolVarP(&configs.NoCache, "no-cache", "c", false, "Do not use cache when building the image")
 	provisionAcrCmd.Flags().StringArrayVarP(&configs.BuildArgs, "build-arg", "a", []string{}, "Set build-time variables (key=value). Repeat the flag or pass comma-separated pairs")
 	provisionAcrCmd.Flags().StringVarP(&configs.Target, "target", "t", "", "Set the target build stage to build")
-	provisionAcrCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Platform for the image")
+	provisionAcrCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Platform for the image, or comma-separated platforms for a multi-arch manifest list (e.g., linux/amd64,linux/arm64)")
 	provisionAcrCmd.Flags().StringVar(&configs.ContextDir, "context", "", "Build context directory (default: current directory)")
-	provisionAcrCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", 1500, "Build timeout")
+	provisionAcrCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Build timeout (overrides timeouts.build in smurf.yaml)")
+
+	provisionAcrCmd.Flags().StringVar(&acrRemoteBuild, "remote-build", "", "Build remotely instead of with the local Docker daemon (acr: run the build on ACR Tasks and push from there)")
+	_ = provisionAcrCmd.RegisterFlagCompletionFunc("remote-build", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"acr"}, cobra.ShellCompDirectiveNoFileComp
+	})
 
 	provisionAcrCmd.Flags().BoolVarP(&configs.ConfirmAfterPush, "yes", "y", false, "Push the image to ACR without confirmation")
 	provisionAcrCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
 	provisionAcrCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addDryRunFlag(provisionAcrCmd)
+	addSmokeTestFlags(provisionAcrCmd)
+	addBuildxFlags(provisionAcrCmd)
+	addArtifactsFlags(provisionAcrCmd)
+	addWebhookFlags(provisionAcrCmd)
 	sdkrCmd.AddCommand(provisionAcrCmd)
 }
@@ -23,6 +23,14 @@ var provisionEcrCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		defer beginDryRun()()
+		if err := docker.ValidScanSeverity(configs.ScanFailOn); err != nil {
+			return err
+		}
+
 		var imageRef string
 		if len(args) == 1 {
 			imageRef = args[0]
@@ -55,6 +63,10 @@ var provisionEcrCmd = &cobra.Command{
 			return errors.New("invalid image reference: missing account ID, region, or repository name")
 		}
 
+		if err := resolveAws(ecrRegionName); err != nil {
+			return err
+		}
+
 		fullEcrImage := fmt.Sprintf(
 			"%s.dkr.ecr.%s.amazonaws.com/%s:%s",
 			accountID,
@@ -91,11 +103,28 @@ var provisionEcrCmd = &cobra.Command{
 			Platform:       configs.Platform,
 			Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
 		}
+		withBuildx(&buildOpts)
+
+		if provisionDryRun {
+			return printProvisionPlan(fullEcrImage, "ecr", buildOpts)
+		}
+
+		if buildOpts.Push {
+			if err := provisionWithBuildx(cmd, fullEcrImage, buildOpts); err != nil {
+				return err
+			}
+			pterm.Success.Println("ECR provisioning completed successfully.")
+			return nil
+		}
 
 		if err := docker.Build(localImageName, localTag, buildOpts, useAI); err != nil {
 			return fmt.Errorf("build failed: %v", err)
 		}
 
+		if err := runSmokeTest(cmd, localImageName+":"+localTag); err != nil {
+			return err
+		}
+
 		pushImage := imageRef
 		if pushImage == "" {
 			pushImage = fullEcrImage
@@ -111,6 +140,14 @@ var provisionEcrCmd = &cobra.Command{
 		}
 		pterm.Success.Println("Push to ECR completed successfully.")
 
+		if err := emitArtifactsManifest(fullEcrImage); err != nil {
+			return err
+		}
+
+		if err := notifyPushWebhooks(fullEcrImage); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", fullEcrImage)
 			if err := docker.RemoveImage(fullEcrImage, useAI); err != nil {
@@ -132,6 +169,13 @@ var provisionEcrCmd = &cobra.Command{
       --platform linux/amd64 \
       --yes \
       --delete
+
+  # Build for amd64 and arm64 with buildx and push one manifest list
+  smurf sdkr provision-ecr 123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo:python \
+      --platform linux/amd64,linux/arm64 --yes
+
+  # Print what would be built and pushed, as JSON, without building
+  smurf sdkr provision-ecr 123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo:python --dry-run
 `,
 }
 
@@ -140,13 +184,19 @@ func init() {
 	provisionEcrCmd.Flags().BoolVarP(&configs.NoCache, "no-cache", "c", false, "Do not use cache when building the image")
 	provisionEcrCmd.Flags().StringArrayVarP(&configs.BuildArgs, "build-arg", "a", []string{}, "Set build-time variables (key=value). Repeat the flag or pass comma-separated pairs")
 	provisionEcrCmd.Flags().StringVarP(&configs.Target, "target", "t", "", "Set the target build stage to build")
-	provisionEcrCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Platform for the image")
+	provisionEcrCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Platform for the image, or comma-separated platforms for a multi-arch manifest list (e.g., linux/amd64,linux/arm64)")
 
 	provisionEcrCmd.Flags().StringVar(&configs.ContextDir, "context", "", "Build context directory (default: current directory)")
-	provisionEcrCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", 1500, "Build timeout")
+	provisionEcrCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Build timeout (overrides timeouts.build in smurf.yaml)")
 
 	provisionEcrCmd.Flags().BoolVarP(&configs.ConfirmAfterPush, "yes", "y", false, "Push the image to ECR without confirmation")
 	provisionEcrCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
 	provisionEcrCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addDryRunFlag(provisionEcrCmd)
+	addSmokeTestFlags(provisionEcrCmd)
+	addBuildxFlags(provisionEcrCmd)
+	addArtifactsFlags(provisionEcrCmd)
+	addWebhookFlags(provisionEcrCmd)
+	addEcrScanFlags(provisionEcrCmd)
 	sdkrCmd.AddCommand(provisionEcrCmd)
 }
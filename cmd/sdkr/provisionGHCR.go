@@ -49,6 +49,14 @@ Example: ghcr.io/my-org/my-app:latest`,
 
   # Read image name from config file
   smurf sdkr provision-ghcr --delete
+
+  # Push from buildx, reusing the build cache stored in the registry
+  smurf sdkr provision-ghcr ghcr.io/my-org/my-app:latest --push \
+    --cache-from type=registry,ref=ghcr.io/my-org/my-app:buildcache \
+    --cache-to type=registry,ref=ghcr.io/my-org/my-app:buildcache,mode=max
+
+  # Print what would be built and pushed, as JSON, without building
+  smurf sdkr provision-ghcr ghcr.io/my-org/my-app:latest --dry-run
 `,
 }
 
@@ -57,18 +65,27 @@ func init() {
 	provisionGHCRCmd.Flags().BoolVar(&configs.NoCache, "no-cache", false, "Disable build cache")
 	provisionGHCRCmd.Flags().StringArrayVar(&configs.BuildArgs, "build-arg", []string{}, "Set build-time variables (key=value). Repeat the flag or pass comma-separated pairs")
 	provisionGHCRCmd.Flags().StringVar(&configs.Target, "target", "", "Target build stage")
-	provisionGHCRCmd.Flags().StringVar(&configs.Platform, "platform", "", "Platform (e.g. linux/amd64)")
-	provisionGHCRCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", 1500, "Build timeout in seconds")
+	provisionGHCRCmd.Flags().StringVar(&configs.Platform, "platform", "", "Platform, or comma-separated platforms for a multi-arch manifest list (e.g. linux/amd64,linux/arm64)")
+	provisionGHCRCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Build timeout in seconds (overrides timeouts.build in smurf.yaml)")
 	provisionGHCRCmd.Flags().StringVar(&configs.ContextDir, "context", "", "Build context (default: current directory)")
 	provisionGHCRCmd.Flags().BoolVarP(&configs.ConfirmAfterPush, "yes", "y", false, "Push without confirmation")
 	provisionGHCRCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete local image after push")
 	provisionGHCRCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addDryRunFlag(provisionGHCRCmd)
+	addSmokeTestFlags(provisionGHCRCmd)
+	addBuildxFlags(provisionGHCRCmd)
+	addArtifactsFlags(provisionGHCRCmd)
+	addWebhookFlags(provisionGHCRCmd)
 	sdkrCmd.AddCommand(provisionGHCRCmd)
 }
 
 func runProvisionGHCR(cmd *cobra.Command, args []string) error {
+	if err := applyTimeoutPolicy(cmd); err != nil {
+		return err
+	}
+	defer beginDryRun()()
+
 	var imageRef string
-	var cfg *configs.Config
 
 	if len(args) == 1 {
 		imageRef = args[0]
@@ -80,8 +97,7 @@ func runProvisionGHCR(cmd *cobra.Command, args []string) error {
 		if loaded.Sdkr.ImageName == "" {
 			return errors.New("image name (with optional tag) must be provided either as an argument or in the config")
 		}
-		cfg = loaded
-		imageRef = cfg.Sdkr.ImageName
+		imageRef = loaded.Sdkr.ImageName
 	}
 
 	if imageRef == "" {
@@ -92,31 +108,24 @@ func runProvisionGHCR(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Load config-based credentials regardless of whether the image was passed
-	// as an argument, so smurf.yaml creds work in both cases. Already-set
-	// environment variables always win.
-	if os.Getenv("GITHUB_USERNAME") == "" || os.Getenv("GITHUB_TOKEN") == "" {
-		if cfg == nil {
-			loaded, err := configs.LoadConfig(configs.FileName)
-			if err != nil {
-				return err
-			}
-			cfg = loaded
-		}
-		if err := exportGHCRCreds(cfg.Sdkr.GithubUsername, cfg.Sdkr.GithubToken); err != nil {
-			return err
-		}
+	// Config file credentials apply whether or not the image was passed as an
+	// argument; credentials in the environment win over them.
+	creds, err := configs.LoadCredentials(configs.CredentialFlags{})
+	if err != nil {
+		return err
 	}
-
-	username := os.Getenv("GITHUB_USERNAME")
-	token := os.Getenv("GITHUB_TOKEN")
-	if username == "" || token == "" {
+	ghcr := creds.GHCR()
+	// A dry run reports missing credentials in its plan instead.
+	if err := ghcr.Validate(); err != nil && !provisionDryRun {
 		pterm.Error.Println("GitHub Container Registry credentials missing.")
 		pterm.Info.Println("Set using environment variables:")
 		pterm.Info.Println("  export GITHUB_USERNAME=\"your-username\"")
 		pterm.Info.Println("  export GITHUB_TOKEN=\"your-github-personal-access-token\"")
 		pterm.Info.Println("Or define github_username and github_token in smurf.yaml.")
-		return errors.New("missing required GHCR credentials")
+		return err
+	}
+	if err := ghcr.Export(); err != nil {
+		return err
 	}
 
 	imageName, tag, err := configs.ParseImage(imageRef)
@@ -135,11 +144,27 @@ func runProvisionGHCR(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if provisionDryRun {
+		return printProvisionPlan(fullImage, "ghcr", buildOpts)
+	}
+
+	if buildOpts.Push {
+		if err := provisionWithBuildx(cmd, fullImage, buildOpts); err != nil {
+			return err
+		}
+		pterm.Success.Println("🚀 GHCR provisioning completed successfully.")
+		return nil
+	}
+
 	if err := docker.Build(imageName, tag, buildOpts, useAI); err != nil {
 		return fmt.Errorf("build failed: %v", err)
 	}
 	pterm.Success.Println("✅ Build completed successfully.")
 
+	if err := runSmokeTest(cmd, fullImage); err != nil {
+		return err
+	}
+
 	if err := confirmPush(); err != nil {
 		return err
 	}
@@ -148,6 +173,14 @@ func runProvisionGHCR(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := emitArtifactsManifest(fullImage); err != nil {
+		return err
+	}
+
+	if err := notifyPushWebhooks(fullImage); err != nil {
+		return err
+	}
+
 	if configs.DeleteAfterPush {
 		cleanupLocalImage(fullImage)
 	}
@@ -178,23 +211,6 @@ func validateGHCRImage(image string) error {
 	return nil
 }
 
-// exportGHCRCreds exports the config-provided GHCR credentials into the
-// environment for any variable that is not already set, so environment
-// variables always take precedence over smurf.yaml values.
-func exportGHCRCreds(configUsername, configToken string) error {
-	envVars := map[string]string{}
-	if os.Getenv("GITHUB_USERNAME") == "" && configUsername != "" {
-		envVars["GITHUB_USERNAME"] = configUsername
-	}
-	if os.Getenv("GITHUB_TOKEN") == "" && configToken != "" {
-		envVars["GITHUB_TOKEN"] = configToken
-	}
-	if len(envVars) == 0 {
-		return nil
-	}
-	return configs.ExportEnvironmentVariables(envVars)
-}
-
 func prepareBuildOptions() (docker.BuildOptions, error) {
 	if configs.ContextDir == "" {
 		wd, err := os.Getwd()
@@ -216,7 +232,7 @@ func prepareBuildOptions() (docker.BuildOptions, error) {
 		return docker.BuildOptions{}, err
 	}
 
-	return docker.BuildOptions{
+	opts := docker.BuildOptions{
 		DockerfilePath: configs.DockerfilePath,
 		NoCache:        configs.NoCache,
 		BuildArgs:      buildArgsMap,
@@ -224,14 +240,16 @@ func prepareBuildOptions() (docker.BuildOptions, error) {
 		Platform:       configs.Platform,
 		Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
 		ContextDir:     configs.ContextDir,
-	}, nil
+	}
+	withBuildx(&opts)
+	return opts, nil
 }
 
 func pushToGHCR(fullImage string) error {
 	pterm.Info.Printf("📦 Pushing image %s to GitHub Container Registry...\n", fullImage)
 	pushOpts := docker.PushOptions{
 		ImageName: fullImage,
-		Timeout:   1000 * time.Second,
+		Timeout:   configs.Timeouts.PushTimeout(),
 	}
 	if err := docker.PushToGHCR(pushOpts, useAI); err != nil {
 		pterm.Error.Printfln("Push failed: %v", err)
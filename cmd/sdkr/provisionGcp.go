@@ -26,7 +26,6 @@ const (
 
 	// Default values
 	DefaultTag             = "latest"
-	DefaultTimeout         = 1500
 	ArtifactRegistryFormat = "us-central1-docker.pkg.dev/%s/%s:%s"
 	GCRFormat              = "gcr.io/%s/%s:%s"
 )
@@ -182,7 +181,7 @@ func NewBuildConfig() *BuildConfig {
 
 	return &BuildConfig{
 		ContextDir: wd,
-		Timeout:    DefaultTimeout,
+		Timeout:    configs.DefaultTimeouts.Build,
 	}
 }
 
@@ -212,38 +211,33 @@ func (bc *BuildConfig) PrepareBuildOptions() (docker.BuildOptions, error) {
 	}, nil
 }
 
-// loadConfiguration loads configuration from file or environment
+// loadConfiguration returns the image reference, from args or smurf.yaml,
+// and resolves the Google Cloud credentials and project.
 func loadConfiguration(args []string) (string, error) {
+	imageRef := ""
 	if len(args) == 1 {
-		return args[0], nil
-	}
-
-	data, err := configs.LoadConfig(configs.FileName)
-	if err != nil {
-		return "", err
-	}
-
-	// Set environment variables if needed
-	envVars := make(map[string]string)
-	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" && data.Sdkr.GoogleApplicationCredentials != "" {
-		envVars["GOOGLE_APPLICATION_CREDENTIALS"] = data.Sdkr.GoogleApplicationCredentials
-	}
-
-	if len(envVars) > 0 {
-		if err := configs.ExportEnvironmentVariables(envVars); err != nil {
+		imageRef = args[0]
+	} else {
+		data, err := configs.LoadConfig(configs.FileName)
+		if err != nil {
 			return "", err
 		}
+		if data.Sdkr.ImageName == "" {
+			return "", errors.New("image name (with optional tag) must be provided either as an argument or in the config")
+		}
+		imageRef = data.Sdkr.ImageName
 	}
 
-	if envVars["GOOGLE_APPLICATION_CREDENTIALS"] == "" {
-		return "", errors.New("missing required Google Application Credentials")
+	creds, err := configs.LoadCredentials(configs.CredentialFlags{GCPProjectID: configs.ProjectID})
+	if err != nil {
+		return "", err
 	}
-
-	if data.Sdkr.ImageName == "" {
-		return "", errors.New("image name (with optional tag) must be provided either as an argument or in the config")
+	gcp := creds.GCP()
+	if err := gcp.Export(); err != nil {
+		return "", err
 	}
-
-	return data.Sdkr.ImageName, nil
+	configs.ProjectID = gcp.ProjectID
+	return imageRef, nil
 }
 
 // cleanupImages cleans up local images after push if configured
@@ -275,7 +269,9 @@ var provisionGcpCmd = &cobra.Command{
 	Use:   "provision-gcp [IMAGE_NAME[:TAG]]",
 	Short: "Build and push a Docker image to Google Container Registry or Artifact Registry.",
 	Long: `Build and push a Docker image to Google Container Registry or Artifact Registry.
-Set the GOOGLE_APPLICATION_CREDENTIALS environment variable to the path of your service account JSON key file.
+Set the GOOGLE_APPLICATION_CREDENTIALS environment variable, or google_application_credentials
+in smurf.yaml, to the path of your service account JSON key file; without either, the gcloud
+application default credentials are used.
 
 Supports:
 - Full Artifact Registry path: us-central1-docker.pkg.dev/PROJECT/REPO/IMAGE:TAG
@@ -286,6 +282,11 @@ Supports:
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		defer beginDryRun()()
+
 		// Load configuration
 		imageRef, err := loadConfiguration(args)
 		if err != nil {
@@ -325,6 +326,22 @@ Supports:
 		if err != nil {
 			return err
 		}
+		withBuildx(&buildOpts)
+
+		if provisionDryRun {
+			return printProvisionPlan(parsedImage.FullPath, "gcp", buildOpts)
+		}
+
+		// buildx builds and pushes the registry image itself; there is no
+		// local image to tag.
+		if buildOpts.Push {
+			if err := provisionWithBuildx(cmd, parsedImage.FullPath, buildOpts); err != nil {
+				return err
+			}
+			pterm.Success.Printf("%s provisioning completed successfully.\n", parsedImage.RegistryType)
+			pterm.Info.Printf("Image reference: %s\n", parsedImage.FullPath)
+			return nil
+		}
 
 		// Build Docker image
 		pterm.Info.Println("Starting Docker build...")
@@ -334,6 +351,10 @@ Supports:
 			return err
 		}
 
+		if err := runSmokeTest(cmd, localImageRef); err != nil {
+			return err
+		}
+
 		// Tag image for registry
 		pterm.Info.Printf("Tagging image for %s...\n", parsedImage.RegistryType)
 		tagOpts := docker.TagOptions{
@@ -355,6 +376,14 @@ Supports:
 			return err
 		}
 
+		if err := emitArtifactsManifest(parsedImage.FullPath); err != nil {
+			return err
+		}
+
+		if err := notifyPushWebhooks(parsedImage.FullPath); err != nil {
+			return err
+		}
+
 		// Cleanup images if configured
 		cleanupImages(parsedImage, registry)
 
@@ -389,6 +418,9 @@ Supports:
   smurf sdkr provision-gcp myapp:v1.0 --project-id my-project --file Dockerfile --no-cache \
     --build-arg key1=value1,key2=value2 --target my-target \
     --delete --platform linux/amd64
+
+  # Print what would be built and pushed, as JSON, without building
+  smurf sdkr provision-gcp myapp:v1.0 --project-id my-project --dry-run
 `,
 }
 
@@ -402,13 +434,18 @@ func init() {
 	provisionGcpCmd.Flags().BoolVarP(&configs.NoCache, "no-cache", "c", false, "Do not use cache when building the image")
 	provisionGcpCmd.Flags().StringArrayVarP(&configs.BuildArgs, "build-arg", "a", []string{}, "Set build-time variables (key=value). Repeat the flag or pass comma-separated pairs")
 	provisionGcpCmd.Flags().StringVarP(&configs.Target, "target", "t", "", "Set the target build stage to build")
-	provisionGcpCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Set the platform for the image (e.g., linux/amd64)")
+	provisionGcpCmd.Flags().StringVarP(&configs.Platform, "platform", "p", "", "Set the platform for the image, or comma-separated platforms for a multi-arch manifest list (e.g., linux/amd64,linux/arm64)")
 	provisionGcpCmd.Flags().StringVar(&configs.ContextDir, "context", "", "Build context directory (default: current directory)")
-	provisionGcpCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", DefaultTimeout, "Build timeout in seconds")
+	provisionGcpCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", configs.DefaultTimeouts.Build, "Build timeout in seconds (overrides timeouts.build in smurf.yaml)")
 
 	// Behavior flags
 	provisionGcpCmd.Flags().BoolVarP(&configs.ConfirmAfterPush, "yes", "y", false, "Push the image to registry without confirmation")
 	provisionGcpCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
 	provisionGcpCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addDryRunFlag(provisionGcpCmd)
+	addSmokeTestFlags(provisionGcpCmd)
+	addBuildxFlags(provisionGcpCmd)
+	addArtifactsFlags(provisionGcpCmd)
+	addWebhookFlags(provisionGcpCmd)
 	sdkrCmd.AddCommand(provisionGcpCmd)
 }
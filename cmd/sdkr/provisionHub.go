@@ -27,6 +27,11 @@ var provisionHubCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		defer beginDryRun()()
+
 		var imageRef string
 		if len(args) == 1 {
 			imageRef = args[0]
@@ -42,24 +47,18 @@ var provisionHubCmd = &cobra.Command{
 			imageRef = data.Sdkr.ImageName
 		}
 
-		if os.Getenv("DOCKER_USERNAME") == "" && os.Getenv("DOCKER_PASSWORD") == "" {
-			data, err := configs.LoadConfig(configs.FileName)
-			if err != nil {
-				return err
-			}
-
-			envVars := map[string]string{
-				"DOCKER_USERNAME": data.Sdkr.DockerUsername,
-				"DOCKER_PASSWORD": data.Sdkr.DockerPassword,
-			}
-			if err := configs.ExportEnvironmentVariables(envVars); err != nil {
-				return err
-			}
+		creds, err := configs.LoadCredentials(configs.CredentialFlags{})
+		if err != nil {
+			return err
 		}
-
-		if os.Getenv("DOCKER_USERNAME") == "" || os.Getenv("DOCKER_PASSWORD") == "" {
+		hub := creds.DockerHub()
+		// A dry run reports missing credentials in its plan instead.
+		if err := hub.Validate(); err != nil && !provisionDryRun {
 			pterm.Error.Println("Docker Hub credentials are required")
-			return errors.New("missing required Docker Hub credentials")
+			return err
+		}
+		if err := hub.Export(); err != nil {
+			return err
 		}
 
 		localImageName, localTag, parseErr := configs.ParseImage(imageRef)
@@ -106,6 +105,19 @@ var provisionHubCmd = &cobra.Command{
 			Timeout:        time.Duration(configs.BuildTimeout) * time.Second,
 			ContextDir:     configs.ContextDir,
 		}
+		withBuildx(&buildOpts)
+
+		if provisionDryRun {
+			return printProvisionPlan(fullImageName, "hub", buildOpts)
+		}
+
+		if buildOpts.Push {
+			if err := provisionWithBuildx(cmd, fullImageName, buildOpts); err != nil {
+				return err
+			}
+			pterm.Success.Println("Provisioning completed successfully.")
+			return nil
+		}
 
 		pterm.Info.Println("Starting build...")
 		if err := docker.Build(localImageName, localTag, buildOpts, useAI); err != nil {
@@ -113,6 +125,10 @@ var provisionHubCmd = &cobra.Command{
 		}
 		pterm.Success.Println("Build completed successfully.")
 
+		if err := runSmokeTest(cmd, fullImageName); err != nil {
+			return err
+		}
+
 		if err := confirmPush(); err != nil {
 			return err
 		}
@@ -120,13 +136,21 @@ var provisionHubCmd = &cobra.Command{
 		pterm.Info.Printf("Pushing image %s...\n", fullImageName)
 		pushOpts := docker.PushOptions{
 			ImageName: fullImageName,
-			Timeout:   time.Duration(configs.BuildTimeout) * time.Second,
+			Timeout:   configs.Timeouts.PushTimeout(),
 		}
 		if err := docker.PushImage(pushOpts, useAI); err != nil {
 			pterm.Error.Println("Push failed:", err)
 			return err
 		}
 
+		if err := emitArtifactsManifest(fullImageName); err != nil {
+			return err
+		}
+
+		if err := notifyPushWebhooks(fullImageName); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", fullImageName)
 			if err := docker.RemoveImage(fullImageName, useAI); err != nil {
@@ -145,6 +169,9 @@ var provisionHubCmd = &cobra.Command{
     --build-arg key1=value1,key2=value2 --target my-target --platform linux/amd64 \
     --yes --delete
 
+  # Print what would be built and pushed, as JSON, without building
+  smurf sdkr provision-hub myuser/myimage:latest --dry-run
+
   # If you omit the argument, it will read from config and rely on "image_name" from there
   smurf sdkr provision-hub --yes --delete
 `,
@@ -179,13 +206,13 @@ func init() {
 		&configs.Platform,
 		"platform",
 		"",
-		"Set the platform for the image (e.g., linux/amd64)",
+		"Set the platform for the image, or comma-separated platforms for a multi-arch manifest list (e.g., linux/amd64,linux/arm64)",
 	)
 	provisionHubCmd.Flags().IntVar(
 		&configs.BuildTimeout,
 		"timeout",
-		1500,
-		"Build timeout",
+		configs.DefaultTimeouts.Build,
+		"Build timeout (overrides timeouts.build in smurf.yaml)",
 	)
 	provisionHubCmd.Flags().StringVar(
 		&configs.ContextDir,
@@ -207,5 +234,10 @@ func init() {
 	)
 	provisionHubCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
+	addDryRunFlag(provisionHubCmd)
+	addSmokeTestFlags(provisionHubCmd)
+	addBuildxFlags(provisionHubCmd)
+	addArtifactsFlags(provisionHubCmd)
+	addWebhookFlags(provisionHubCmd)
 	sdkrCmd.AddCommand(provisionHubCmd)
 }
@@ -1,23 +1,168 @@
 package sdkr
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ci"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
-// pushCmd represents the push subcommand for sdkr command to push images to Docker Hub, ACR, GCR, ECR
+var (
+	pushTargets   []string
+	pushOnFailure string
+	pushPlanFile  string
+	pushRetryPlan string
+	pushTimeout   int
+)
+
+// pushCmd represents the push subcommand for sdkr command to push images to Docker Hub, ACR, GCR, ECR.
+// Given --to targets (or --retry-plan), it pushes one local image to several tags or
+// registries as a unit: per-target results are tracked, and a partial failure is either
+// rolled back or written to a plan file that --retry-plan resumes.
 var pushCmd = &cobra.Command{
-	Use:   "push",
+	Use:   "push [IMAGE_NAME[:TAG]] --to TARGET [--to TARGET...]",
 	Short: "Push cmd helps to push images to Docker Hub, ACR, GCR, ECR",
+	Long: `Push images to Docker Hub, ACR, GCR or ECR with the registry subcommands, or push
+one local image to several tags and registries at once with --to.
+
+With --to, targets are pushed in order and the push stops at the first failure.
+What happens to the targets already pushed depends on --on-failure:
+  plan      leave them in place and write a retry plan (default)
+  rollback  point each tag back at its previous digest, or delete it if it was
+            new, then write the plan
+
+Either way the plan records every target's status, and
+'smurf sdkr push --retry-plan FILE' pushes the targets that are not pushed yet.
+Images built with --builder containerd or buildkitd are pushed from that
+builder's image store without a Docker daemon; pass the same --builder here.
+Credentials are taken per registry as by the registry subcommands, with the
+docker config as a fallback.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Use 'smurf sdkr push [command]' to push images to Docker Hub, ACR, GCR, ECR ")
-		return nil
+		if len(pushTargets) == 0 && pushRetryPlan == "" {
+			fmt.Println("Use 'smurf sdkr push [command]' to push images to Docker Hub, ACR, GCR, ECR ")
+			return nil
+		}
+		if pushOnFailure != docker.OnFailurePlan && pushOnFailure != docker.OnFailureRollback {
+			return fmt.Errorf("invalid --on-failure %q: must be plan or rollback", pushOnFailure)
+		}
+
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			pushTimeout = configs.Timeouts.Push
+		}
+
+		var plan *docker.PushPlan
+		planFile := pushPlanFile
+		if pushRetryPlan != "" {
+			if len(args) > 0 || len(pushTargets) > 0 {
+				return errors.New("--retry-plan takes the image and targets from the plan; don't pass them again")
+			}
+			loaded, err := docker.LoadPushPlan(pushRetryPlan)
+			if err != nil {
+				return err
+			}
+			plan = loaded
+			if !cmd.Flags().Changed("plan-file") {
+				planFile = pushRetryPlan
+			}
+		} else {
+			source := ""
+			if len(args) == 1 {
+				source = args[0]
+			} else {
+				data, err := configs.LoadConfig(configs.FileName)
+				if err != nil {
+					return err
+				}
+				if data.Sdkr.ImageName == "" {
+					pterm.Error.Printfln("image name (with optional tag) must be provided either as an argument or in the config")
+					return errors.New("image name (with optional tag) must be provided either as an argument or in the config")
+				}
+				source = data.Sdkr.ImageName
+			}
+			builder, err := resolveBuilder(cmd)
+			if err != nil {
+				return err
+			}
+			created, err := docker.NewPushPlan(source, pushTargets, builder)
+			if err != nil {
+				return err
+			}
+			plan = created
+		}
+
+		err := docker.PushToTargets(plan, docker.MultiPushOptions{
+			OnFailure: pushOnFailure,
+			PlanFile:  planFile,
+			Timeout:   time.Duration(pushTimeout) * time.Second,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+		if pushRetryPlan != "" {
+			// Keep the plan file in step with the registries.
+			if err := plan.Save(pushRetryPlan); err != nil {
+				return err
+			}
+		}
+
+		// The first target is the image later CI jobs deploy.
+		if len(plan.Targets) > 0 {
+			if err := ci.Export(ci.ImageVars(plan.Targets[0].Image, plan.Targets[0].Digest)); err != nil {
+				return err
+			}
+		}
+		hooks, err := pushWebhooks()
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			return nil
+		}
+		var errs []error
+		for _, t := range plan.Targets {
+			errs = append(errs, docker.NotifyWebhooks(hooks, docker.NewPushEvent(t.Image, t.Digest)))
+		}
+		return errors.Join(errs...)
 	},
-	Example: `smurf sdkr push --help`,
+	Example: `
+  smurf sdkr push --help
+
+  # Push one image to two tags in ECR and a mirror in GHCR
+  smurf sdkr push myapp:v1.4.0 \
+    --to 123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.4.0 \
+    --to 123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:latest \
+    --to ghcr.io/my-org/myapp:v1.4.0
+
+  # Undo the pushed targets if any target fails
+  smurf sdkr push myapp:v1.4.0 --to ... --on-failure rollback
+
+  # Push an image built without Docker Engine
+  smurf sdkr push myapp:v1.4.0 --builder buildkitd --to ghcr.io/my-org/myapp:v1.4.0
+
+  # Resume a push that failed part-way
+  smurf sdkr push --retry-plan smurf-push-plan.json
+`,
 }
 
 func init() {
+	pushCmd.Flags().StringArrayVar(&pushTargets, "to", []string{}, "Full image reference to push the image to (can be repeated)")
+	pushCmd.Flags().StringVar(&pushOnFailure, "on-failure", docker.OnFailurePlan, "What to do with already pushed targets when one fails: plan or rollback")
+	pushCmd.Flags().StringVar(&pushPlanFile, "plan-file", "smurf-push-plan.json", "Where to write the retry plan when a push fails")
+	pushCmd.Flags().StringVar(&pushRetryPlan, "retry-plan", "", "Resume a failed push from its plan file")
+	pushCmd.Flags().IntVar(&pushTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for each target's push in seconds (overrides timeouts.push in smurf.yaml)")
+	addBuilderFlag(pushCmd)
+	pushCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addWebhookFlags(pushCmd)
 
 	sdkrCmd.AddCommand(pushCmd)
 }
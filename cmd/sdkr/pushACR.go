@@ -33,16 +33,6 @@ var pushAcrCmd = &cobra.Command{
 				return errors.New(pterm.Error.Sprintfln("image name (with optional tag) must be provided either as an argument or in the config"))
 			}
 			imageRef = data.Sdkr.ImageName
-
-			if configs.SubscriptionID == "" {
-				configs.SubscriptionID = data.Sdkr.ProvisionAcrSubscriptionID
-			}
-			if configs.ResourceGroup == "" {
-				configs.ResourceGroup = data.Sdkr.ProvisionAcrResourceGroup
-			}
-			if configs.RegistryName == "" {
-				configs.RegistryName = data.Sdkr.ProvisionAcrRegistryName
-			}
 		}
 
 		localImage, repository, tag, parseErr := configs.NormalizeAcrLocalImage(imageRef)
@@ -54,9 +44,9 @@ var pushAcrCmd = &cobra.Command{
 			return errors.New("invalid image reference")
 		}
 
-		if configs.SubscriptionID == "" || configs.ResourceGroup == "" || configs.RegistryName == "" {
+		if err := resolveAcr(); err != nil {
 			pterm.Error.Println("Required flags are missing. Please provide the required flags.")
-			return errors.New("missing required ACR parameters")
+			return err
 		}
 
 		acrImage := fmt.Sprintf("%s.azurecr.io/%s:%s", configs.RegistryName, repository, tag)
@@ -68,6 +58,10 @@ var pushAcrCmd = &cobra.Command{
 		}
 		pterm.Success.Println("Successfully pushed image to ACR:", acrImage)
 
+		if err := notifyPushWebhooks(acrImage); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", localImage)
 			if err := docker.RemoveImage(localImage, useAI); err != nil {
@@ -90,5 +84,23 @@ func init() {
 	pushAcrCmd.Flags().StringVarP(&configs.RegistryName, "registry-name", "g", "", "Azure Container Registry name (required)")
 	pushAcrCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
 	pushAcrCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addWebhookFlags(pushAcrCmd)
 	pushCmd.AddCommand(pushAcrCmd)
 }
+
+// resolveAcr resolves the subscription, resource group and registry name of
+// --subscription-id, --resource-group and --registry-name that were not
+// given, from the environment and the config file.
+func resolveAcr() error {
+	creds, err := configs.LoadCredentials(configs.CredentialFlags{
+		AzureSubscriptionID: configs.SubscriptionID,
+		AzureResourceGroup:  configs.ResourceGroup,
+		AzureRegistryName:   configs.RegistryName,
+	})
+	if err != nil {
+		return err
+	}
+	az := creds.Azure()
+	configs.SubscriptionID, configs.ResourceGroup, configs.RegistryName = az.SubscriptionID, az.ResourceGroup, az.RegistryName
+	return az.Validate()
+}
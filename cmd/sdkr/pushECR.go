@@ -19,6 +19,9 @@ var pushEcrCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := docker.ValidScanSeverity(configs.ScanFailOn); err != nil {
+			return err
+		}
 		var imageRef string
 
 		if len(args) == 1 {
@@ -45,6 +48,10 @@ var pushEcrCmd = &cobra.Command{
 			return errors.New("invalid image reference: missing account ID, region, or repository name")
 		}
 
+		if err := resolveAws(ecrRegionName); err != nil {
+			return err
+		}
+
 		ecrImage := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s:%s",
 			accountID, ecrRegionName, ecrRepositoryName, ecrImageTag,
 		)
@@ -57,6 +64,10 @@ var pushEcrCmd = &cobra.Command{
 		}
 		pterm.Success.Println("Successfully pushed image to ECR:", ecrImage)
 
+		if err := notifyPushWebhooks(ecrImage); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", imageRef)
 			if err := docker.RemoveImage(imageRef, useAI); err != nil {
@@ -74,6 +85,9 @@ var pushEcrCmd = &cobra.Command{
 
   smurf sdkr push aws 123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo:python
   smurf sdkr push aws 123456789012.dkr.ecr.us-east-1.amazonaws.com/repo-name:python --delete
+
+  # Wait for ECR's scan on push and fail on HIGH or CRITICAL findings
+  smurf sdkr push aws 123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo:python --scan-findings --fail-on high
 `,
 }
 
@@ -84,5 +98,21 @@ func init() {
 	pushEcrCmd.Flags().BoolVar(&useAI, "ai", false,
 		"To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.",
 	)
+	addWebhookFlags(pushEcrCmd)
+	addEcrScanFlags(pushEcrCmd)
 	pushCmd.AddCommand(pushEcrCmd)
 }
+
+// resolveAws resolves the AWS credentials for region, the region of the image
+// reference, and exports those of the config file for the AWS SDK.
+func resolveAws(region string) error {
+	creds, err := configs.LoadCredentials(configs.CredentialFlags{AWSRegion: region})
+	if err != nil {
+		return err
+	}
+	aws := creds.AWS()
+	if err := aws.Validate(); err != nil {
+		return err
+	}
+	return aws.Export()
+}
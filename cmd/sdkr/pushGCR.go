@@ -2,7 +2,6 @@ package sdkr
 
 import (
 	"errors"
-	"os"
 	"strings"
 
 	"github.com/clouddrove/smurf/configs"
@@ -19,7 +18,11 @@ var pushGcrCmd = &cobra.Command{
 
 Authentication Methods:
 1. gcloud CLI (recommended): Run 'gcloud auth login' and 'gcloud auth configure-docker'
-2. Service Account: Set GOOGLE_APPLICATION_CREDENTIALS environment variable
+2. Service Account: Set GOOGLE_APPLICATION_CREDENTIALS environment variable, or
+   google_application_credentials in smurf.yaml
+
+The project is --project-id, GOOGLE_CLOUD_PROJECT or provisionGcrProjectID in
+smurf.yaml, in that order.
 
 Supports:
 - GCR: gcr.io/PROJECT_ID/IMAGE_NAME:TAG
@@ -30,8 +33,6 @@ Supports:
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var imageRef string
-		var envVars map[string]string
-
 		if len(args) == 1 {
 			imageRef = args[0]
 		} else {
@@ -44,21 +45,17 @@ Supports:
 				return errors.New("image name (with optional tag) must be provided either as an argument or in the config")
 			}
 			imageRef = data.Sdkr.ImageName
+		}
 
-			if configs.ProjectID == "" {
-				configs.ProjectID = data.Sdkr.ProvisionGcrProjectID
-			}
-			if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" && data.Sdkr.GoogleApplicationCredentials != "" {
-				envVars = map[string]string{
-					"GOOGLE_APPLICATION_CREDENTIALS": data.Sdkr.GoogleApplicationCredentials,
-				}
-			}
-			if envVars != nil {
-				if err := configs.ExportEnvironmentVariables(envVars); err != nil {
-					return err
-				}
-			}
+		creds, err := configs.LoadCredentials(configs.CredentialFlags{GCPProjectID: configs.ProjectID})
+		if err != nil {
+			return err
+		}
+		gcp := creds.GCP()
+		if err := gcp.Export(); err != nil {
+			return err
 		}
+		configs.ProjectID = gcp.ProjectID
 
 		// Verify authentication before proceeding
 		pterm.Info.Println("Verifying Google Cloud authentication...")
@@ -87,6 +84,10 @@ Supports:
 		// Construct a success message after the push is successful
 		pterm.Success.Printf("Successfully pushed image to %s: %s\n", registryType, imageRef)
 
+		if err := notifyPushWebhooks(imageRef); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			// Extract base image name for deletion
 			baseName := imageRef
@@ -122,5 +123,6 @@ func init() {
 	pushGcrCmd.Flags().StringVar(&configs.ProjectID, "project-id", "", "GCP project ID (required for short image names)")
 	pushGcrCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
 	pushGcrCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addWebhookFlags(pushGcrCmd)
 	pushCmd.AddCommand(pushGcrCmd)
 }
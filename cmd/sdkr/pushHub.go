@@ -3,7 +3,6 @@ package sdkr
 import (
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/clouddrove/smurf/configs"
@@ -12,6 +11,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// pushHubTimeout backs push hub's --timeout flag, in seconds.
+var pushHubTimeout int
+
 // pushHubCmd defines the "hub" command, which pushes Docker images to Docker Hub.
 // It supports both command-line arguments and config file values for the image reference,
 // as well as environment variables or config-defined credentials for authentication.
@@ -23,13 +25,20 @@ var pushHubCmd = &cobra.Command{
 Push Docker images to Docker Hub.
 Export DOCKER_USERNAME and DOCKER_PASSWORD as environment variables for Docker Hub authentication, for example:
   export DOCKER_USERNAME="your-username"
-  export DOCKER_PASSWORD="your-password"`,
+  export DOCKER_PASSWORD="your-password"
+Without them, docker_username and docker_password from smurf.yaml (or the user
+defaults in ~/.smurf/config.yaml) are used.`,
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var imageRef string
-		var envVars map[string]string
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			pushHubTimeout = configs.Timeouts.Push
+		}
 
+		var imageRef string
 		if len(args) == 1 {
 			imageRef = args[0]
 		} else {
@@ -44,25 +53,18 @@ Export DOCKER_USERNAME and DOCKER_PASSWORD as environment variables for Docker H
 			imageRef = data.Sdkr.ImageName
 		}
 
-		if os.Getenv("DOCKER_USERNAME") == "" && os.Getenv("DOCKER_PASSWORD") == "" {
-			data, err := configs.LoadConfig(configs.FileName)
-			if err != nil {
-				return err
-			}
-
-			envVars = map[string]string{
-				"DOCKER_USERNAME": data.Sdkr.DockerUsername,
-				"DOCKER_PASSWORD": data.Sdkr.DockerPassword,
-			}
-			if err := configs.ExportEnvironmentVariables(envVars); err != nil {
-				pterm.Error.Println("Error exporting Docker Hub credentials:", err)
-				return err
-			}
+		creds, err := configs.LoadCredentials(configs.CredentialFlags{})
+		if err != nil {
+			return err
 		}
-
-		if os.Getenv("DOCKER_USERNAME") == "" || os.Getenv("DOCKER_PASSWORD") == "" {
+		hub := creds.DockerHub()
+		if err := hub.Validate(); err != nil {
 			pterm.Error.Println("Missing required Docker Hub credentials")
-			return errors.New("missing required Docker Hub credentials")
+			return err
+		}
+		if err := hub.Export(); err != nil {
+			pterm.Error.Println("Error exporting Docker Hub credentials:", err)
+			return err
 		}
 
 		repoName, tag, parseErr := configs.ParseImage(imageRef)
@@ -82,7 +84,7 @@ Export DOCKER_USERNAME and DOCKER_PASSWORD as environment variables for Docker H
 
 		opts := docker.PushOptions{
 			ImageName: fullImageName,
-			Timeout:   time.Duration(configs.BuildTimeout) * time.Second,
+			Timeout:   time.Duration(pushHubTimeout) * time.Second,
 		}
 		if err := docker.PushImage(opts, useAI); err != nil {
 			pterm.Error.Println("Failed to push image to Docker Hub:", err)
@@ -90,6 +92,10 @@ Export DOCKER_USERNAME and DOCKER_PASSWORD as environment variables for Docker H
 		}
 		pterm.Success.Println("Successfully pushed image to Docker Hub:", fullImageName)
 
+		if err := notifyPushWebhooks(fullImageName); err != nil {
+			return err
+		}
+
 		if configs.DeleteAfterPush {
 			pterm.Info.Printf("Deleting local image %s...\n", fullImageName)
 			if err := docker.RemoveImage(fullImageName, useAI); err != nil {
@@ -108,7 +114,8 @@ Export DOCKER_USERNAME and DOCKER_PASSWORD as environment variables for Docker H
 
 func init() {
 	pushHubCmd.Flags().BoolVarP(&configs.DeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
-	pushHubCmd.Flags().IntVar(&configs.BuildTimeout, "timeout", 1500, "Timeout for the push operation in seconds")
+	pushHubCmd.Flags().IntVar(&pushHubTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for the push operation in seconds (overrides timeouts.push in smurf.yaml)")
 	pushHubCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addWebhookFlags(pushHubCmd)
 	pushCmd.AddCommand(pushHubCmd)
 }
@@ -0,0 +1,156 @@
+package sdkr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ci"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushMultiTargets     []string
+	pushMultiConcurrency int
+	pushMultiOnFailure   string
+	pushMultiPlanFile    string
+	pushMultiTimeout     int
+)
+
+// pushMultiCmd pushes one local image to several registries at once: the
+// --to targets and the repositories of sdkr.registries, each pushed in its
+// own goroutine with that registry's credentials.
+var pushMultiCmd = &cobra.Command{
+	Use:   "push-multi [IMAGE_NAME[:TAG]] [--to TARGET...]",
+	Short: "Push one image to several registries concurrently",
+	Long: `Push one local image to several registries at the same time, e.g. ECR for
+production and GHCR as a mirror, and print a summary of every target.
+
+The targets are the --to images and the repositories listed under
+sdkr.registries in smurf.yaml. A repository without a tag gets the tag of the
+image, so the same version lands everywhere. The image and its tag default to
+sdkr.imageName.
+
+Each target is pushed with the credentials of its registry, as by the
+registry subcommands, with the docker config as a fallback. Every target is
+attempted even when another fails; the targets that failed are recorded in
+the plan file, and 'smurf sdkr push --retry-plan FILE' pushes them again.
+With --on-failure rollback the pushed targets are rolled back instead.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pushMultiOnFailure != docker.OnFailurePlan && pushMultiOnFailure != docker.OnFailureRollback {
+			return fmt.Errorf("invalid --on-failure %q: must be plan or rollback", pushMultiOnFailure)
+		}
+		if pushMultiConcurrency < 0 {
+			return errors.New("--concurrency must not be negative")
+		}
+
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			pushMultiTimeout = configs.Timeouts.Push
+		}
+
+		cfg := &configs.Config{}
+		if _, err := os.Stat(configs.FileName); err == nil {
+			loaded, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+			cfg = loaded
+		}
+
+		source := cfg.Sdkr.ImageName
+		if len(args) == 1 {
+			source = args[0]
+		}
+		if source == "" {
+			return errors.New("image name (with optional tag) must be provided either as an argument or as sdkr.imageName in the config")
+		}
+		targets := append(append([]string{}, pushMultiTargets...), configs.RegistryTargets(cfg.Sdkr.Registries, source)...)
+		if len(targets) == 0 {
+			return errors.New("no targets: pass --to or list repositories under sdkr.registries in smurf.yaml")
+		}
+
+		builder, err := resolveBuilder(cmd)
+		if err != nil {
+			return err
+		}
+		plan, err := docker.NewPushPlan(source, targets, builder)
+		if err != nil {
+			return err
+		}
+		if err := exportRegistryCredentials(); err != nil {
+			return err
+		}
+
+		concurrency := pushMultiConcurrency
+		if concurrency == 0 {
+			concurrency = len(plan.Targets)
+		}
+		err = docker.PushToTargets(plan, docker.MultiPushOptions{
+			OnFailure:   pushMultiOnFailure,
+			PlanFile:    pushMultiPlanFile,
+			Timeout:     time.Duration(pushMultiTimeout) * time.Second,
+			Concurrency: concurrency,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+
+		// The first target is the image later CI jobs deploy.
+		if err := ci.Export(ci.ImageVars(plan.Targets[0].Image, plan.Targets[0].Digest)); err != nil {
+			return err
+		}
+		hooks, err := pushWebhooks()
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			return nil
+		}
+		var errs []error
+		for _, t := range plan.Targets {
+			errs = append(errs, docker.NotifyWebhooks(hooks, docker.NewPushEvent(t.Image, t.Digest)))
+		}
+		return errors.Join(errs...)
+	},
+	Example: `
+  # Push to the repositories of sdkr.registries in smurf.yaml:
+  #   sdkr:
+  #     imageName: myapp:v1.4.0
+  #     registries:
+  #       - 123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp
+  #       - ghcr.io/my-org/myapp
+  smurf sdkr push-multi
+
+  # Push to ECR and a GHCR mirror, given on the command line
+  smurf sdkr push-multi myapp:v1.4.0 \
+    --to 123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.4.0 \
+    --to ghcr.io/my-org/myapp:v1.4.0
+
+  # At most two pushes at a time, rolling back if any target fails
+  smurf sdkr push-multi --concurrency 2 --on-failure rollback
+
+  # Retry the targets that failed
+  smurf sdkr push --retry-plan smurf-push-plan.json
+`,
+}
+
+func init() {
+	pushMultiCmd.Flags().StringArrayVar(&pushMultiTargets, "to", []string{}, "Full image reference to push the image to, besides sdkr.registries (can be repeated)")
+	pushMultiCmd.Flags().IntVar(&pushMultiConcurrency, "concurrency", 0, "How many targets to push at once (0: all of them)")
+	pushMultiCmd.Flags().StringVar(&pushMultiOnFailure, "on-failure", docker.OnFailurePlan, "What to do with the pushed targets when one fails: plan or rollback")
+	pushMultiCmd.Flags().StringVar(&pushMultiPlanFile, "plan-file", "smurf-push-plan.json", "Where to write the retry plan when a push fails")
+	pushMultiCmd.Flags().IntVar(&pushMultiTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for each target's push in seconds (overrides timeouts.push in smurf.yaml)")
+	addBuilderFlag(pushMultiCmd)
+	pushMultiCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	addWebhookFlags(pushMultiCmd)
+
+	sdkrCmd.AddCommand(pushMultiCmd)
+}
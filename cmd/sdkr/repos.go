@@ -0,0 +1,206 @@
+package sdkr
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	reposCreatedBySmurf bool
+	reposOutput         string
+	reposDryRun         bool
+	reposYes            bool
+	reposTimeout        int
+)
+
+// reposCmd groups the commands that manage the repositories of a registry,
+// chiefly to clean up those smurf created on push.
+var reposCmd = &cobra.Command{
+	Use:   "repos",
+	Short: "List and delete the repositories of an ECR or Artifact Registry registry",
+	Long: `Pushing to an ECR repository or an Artifact Registry repository that doesn't
+exist creates it, tagged (ECR) or labelled (Artifact Registry) ` + docker.CreatedByKey + `=` + docker.CreatedByValue + `.
+These commands find those repositories again, e.g. to remove the ones left
+over from experiments.
+
+REGISTRY is an ECR registry, ACCOUNT.dkr.ecr.REGION.amazonaws.com, used with
+the AWS credentials of the environment, or the Artifact Registry repositories
+of a project in one location, LOCATION-docker.pkg.dev/PROJECT, used with the
+gcloud or application default credentials.`,
+}
+
+var reposListCmd = &cobra.Command{
+	Use:          "list REGISTRY",
+	Short:        "List the repositories of a registry",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(reposOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", reposOutput)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(reposTimeout)*time.Second)
+		defer cancel()
+		repos, err := docker.ListRepositories(ctx, args[0], reposCreatedBySmurf)
+		if err != nil {
+			return err
+		}
+		if reposOutput == "json" {
+			if repos == nil {
+				repos = []docker.Repository{}
+			}
+			data, err := json.MarshalIndent(repos, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		docker.PrintRepositories(repos)
+		return nil
+	},
+	Example: `
+  # The repositories smurf created in an ECR registry
+  smurf sdkr repos list 123456789012.dkr.ecr.us-east-1.amazonaws.com --created-by-smurf
+
+  # Every Docker repository of a project in Artifact Registry, as JSON
+  smurf sdkr repos list us-central1-docker.pkg.dev/my-project -o json
+`,
+}
+
+var reposDeleteCmd = &cobra.Command{
+	Use:   "delete REGISTRY [REPOSITORY...]",
+	Short: "Delete repositories of a registry, with their images",
+	Long: `Delete the named repositories of REGISTRY, or, with --created-by-smurf and no
+names, every repository smurf created there. With --created-by-smurf, a named
+repository smurf did not create is left alone. The images in a repository are
+deleted with it.
+
+The repositories are listed and confirmed before anything is deleted; without
+a terminal, --yes is required.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, names := args[0], args[1:]
+		if len(names) == 0 && !reposCreatedBySmurf {
+			return errors.New("name the repositories to delete, or pass --created-by-smurf to delete every repository smurf created")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(reposTimeout)*time.Second)
+		defer cancel()
+
+		repos, err := docker.ListRepositories(ctx, registry, false)
+		if err != nil {
+			return err
+		}
+		targets, err := selectRepositories(repos, names, reposCreatedBySmurf)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			pterm.Info.Println("No repositories to delete")
+			return nil
+		}
+		docker.PrintRepositories(targets)
+		if reposDryRun {
+			pterm.Info.Printfln("Dry run: %d repositories would be deleted", len(targets))
+			return nil
+		}
+		if err := confirmDeleteRepos(reposYes, len(targets)); err != nil {
+			return err
+		}
+		return docker.DeleteRepositories(ctx, registry, targets)
+	},
+	Example: `
+  # Preview, then delete, the repositories smurf created in an ECR registry
+  smurf sdkr repos delete 123456789012.dkr.ecr.us-east-1.amazonaws.com --created-by-smurf --dry-run
+  smurf sdkr repos delete 123456789012.dkr.ecr.us-east-1.amazonaws.com --created-by-smurf --yes
+
+  # Delete two repositories, but only if smurf created them
+  smurf sdkr repos delete us-central1-docker.pkg.dev/my-project test-api test-worker --created-by-smurf
+`,
+}
+
+// selectRepositories picks the repositories to delete from those of the
+// registry: the named ones, which must exist, or all of them; only those
+// smurf created when createdBySmurf is set.
+func selectRepositories(repos []docker.Repository, names []string, createdBySmurf bool) ([]docker.Repository, error) {
+	byName := make(map[string]docker.Repository, len(repos))
+	for _, r := range repos {
+		byName[r.Name] = r
+	}
+	candidates := repos
+	if len(names) > 0 {
+		candidates = nil
+		var missing []string
+		for _, name := range names {
+			r, ok := byName[name]
+			if !ok {
+				missing = append(missing, name)
+				continue
+			}
+			candidates = append(candidates, r)
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no such repository: %s", strings.Join(missing, ", "))
+		}
+	}
+	if !createdBySmurf {
+		return candidates, nil
+	}
+
+	var targets []docker.Repository
+	for _, r := range candidates {
+		if !r.CreatedBySmurf {
+			if len(names) > 0 {
+				pterm.Warning.Printfln("Skipping %s: smurf did not create it (no %s=%s)", r.Name, docker.CreatedByKey, docker.CreatedByValue)
+			}
+			continue
+		}
+		targets = append(targets, r)
+	}
+	return targets, nil
+}
+
+// confirmDeleteRepos asks before deleting. Like deploy destroy it never
+// proceeds without a terminal unless --yes was given.
+func confirmDeleteRepos(skip bool, count int) error {
+	if skip {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("refusing to delete repositories without confirmation; pass --yes")
+	}
+	pterm.Warning.Printf("Delete these %d repositories and their images? [y/N]: ", count)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response != "y" && response != "Y" {
+		return errors.New("aborted by user")
+	}
+	return nil
+}
+
+func init() {
+	reposListCmd.Flags().BoolVar(&reposCreatedBySmurf, "created-by-smurf", false, "Only list the repositories smurf created ("+docker.CreatedByKey+"="+docker.CreatedByValue+")")
+	reposListCmd.Flags().StringVarP(&reposOutput, "output", "o", "table", "output format (table|json)")
+	reposDeleteCmd.Flags().BoolVar(&reposCreatedBySmurf, "created-by-smurf", false, "Only delete repositories smurf created; without names, delete all of them")
+	reposDeleteCmd.Flags().BoolVar(&reposDryRun, "dry-run", false, "List the repositories that would be deleted without deleting them")
+	reposDeleteCmd.Flags().BoolVarP(&reposYes, "yes", "y", false, "Skip the confirmation prompt")
+	for _, c := range []*cobra.Command{reposListCmd, reposDeleteCmd} {
+		c.Flags().IntVar(&reposTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for the whole command in seconds")
+		reposCmd.AddCommand(c)
+	}
+
+	sdkrCmd.AddCommand(reposCmd)
+}
@@ -0,0 +1,135 @@
+package sdkr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	retagFrom       string
+	retagTo         string
+	retagImagesFile string
+	retagDryRun     bool
+	retagForce      bool
+	retagOutput     string
+	retagTimeout    int
+)
+
+// retagCmd gives a set of images a new tag in their registries, e.g. to
+// promote a release candidate of every service of a release train.
+var retagCmd = &cobra.Command{
+	Use:   "retag [IMAGE...] --from TAG --to TAG",
+	Short: "Retag images in their registries without pulling them",
+	Long: `Point the tag --to of every image at the manifest its tag --from points at,
+in the registry itself: the manifest is put again under the new tag, so no
+image is pulled or pushed, and multi-arch images keep every platform.
+
+The images are repositories without a tag, given as arguments or one per line
+in --images-file (blank lines and # comments are skipped). A --to tag that
+already points at another manifest is skipped unless --force moves it.
+--dry-run resolves every image and prints what would be retagged.
+
+Every image is tried and reported; the command fails when any was not
+retagged. Registries are used with the same credentials as pushes.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(retagOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", retagOutput)
+		}
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			retagTimeout = configs.Timeouts.Push
+		}
+
+		images := args
+		if err := docker.CheckRetagImages(images); err != nil {
+			return err
+		}
+		if retagImagesFile != "" {
+			listed, err := docker.ReadImageList(retagImagesFile)
+			if err != nil {
+				return err
+			}
+			images = append(images, listed...)
+		}
+		if len(images) == 0 {
+			return errors.New("no images to retag: pass them as arguments or with --images-file")
+		}
+		if err := exportRegistryCredentials(); err != nil {
+			return err
+		}
+
+		if retagDryRun && retagOutput == "table" {
+			pterm.Info.Printfln("Dry run: %d images would be retagged, nothing is changed", len(images))
+		}
+		results, retagErr := docker.RetagImages(images, retagFrom, retagTo, docker.RetagOptions{
+			DryRun:  retagDryRun,
+			Force:   retagForce,
+			Timeout: time.Duration(retagTimeout) * time.Second,
+		})
+		if results == nil {
+			return retagErr
+		}
+		if retagOutput == "json" {
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			docker.PrintRetagResults(results, retagFrom, retagTo)
+		}
+		return retagErr
+	},
+	Example: `
+  # Cut release 1.8.0 from release candidate 42 of every service
+  smurf sdkr retag --from :rc-42 --to :1.8.0 --images-file list.txt --dry-run
+  smurf sdkr retag --from :rc-42 --to :1.8.0 --images-file list.txt
+
+  # Move the stable tag of two images
+  smurf sdkr retag ghcr.io/acme/api ghcr.io/acme/worker --from 1.8.0 --to stable --force
+`,
+}
+
+// exportRegistryCredentials exports the registry credentials of the config
+// file that the registry API reads from the environment, for commands that
+// talk to any registry.
+func exportRegistryCredentials() error {
+	creds, err := configs.LoadCredentials(configs.CredentialFlags{})
+	if err != nil {
+		return err
+	}
+	if err := creds.DockerHub().Export(); err != nil {
+		return err
+	}
+	if err := creds.GHCR().Export(); err != nil {
+		return err
+	}
+	if err := creds.AWS().Export(); err != nil {
+		return err
+	}
+	return creds.GCP().Export()
+}
+
+func init() {
+	retagCmd.Flags().StringVar(&retagFrom, "from", "", "Tag the images have now, e.g. :rc-42 (required)")
+	retagCmd.Flags().StringVar(&retagTo, "to", "", "Tag to give them, e.g. :1.8.0 (required)")
+	retagCmd.Flags().StringVar(&retagImagesFile, "images-file", "", "File listing the images to retag, one repository per line")
+	retagCmd.Flags().BoolVar(&retagDryRun, "dry-run", false, "Resolve the images and print what would be retagged without changing anything")
+	retagCmd.Flags().BoolVar(&retagForce, "force", false, "Move a --to tag that already points at another image")
+	retagCmd.Flags().StringVarP(&retagOutput, "output", "o", "table", "output format (table|json)")
+	retagCmd.Flags().IntVar(&retagTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for each image in seconds (overrides timeouts.push in smurf.yaml)")
+	_ = retagCmd.MarkFlagRequired("from")
+	_ = retagCmd.MarkFlagRequired("to")
+	sdkrCmd.AddCommand(retagCmd)
+}
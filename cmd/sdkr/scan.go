@@ -3,6 +3,7 @@ package sdkr
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/docker"
@@ -11,7 +12,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var scanOutputFormat string
+var (
+	scanOutputFormat string
+	scanNoCache      bool
+	scanCacheTTL     time.Duration
+)
 
 // scanCmd provides functionality to scan a Docker image for known security issues.
 // It supports both direct command-line arguments and configuration file values for the image name,
@@ -25,6 +30,9 @@ var scanCmd = &cobra.Command{
 		if !utils.ValidOutputFormat(scanOutputFormat, "table", "json") {
 			return fmt.Errorf("invalid output format %q: must be one of table, json", scanOutputFormat)
 		}
+		if err := docker.ValidScanSeverity(configs.ScanFailOn); err != nil {
+			return err
+		}
 		isTable := scanOutputFormat == "" || scanOutputFormat == "table"
 
 		var imageRef string
@@ -47,7 +55,8 @@ var scanCmd = &cobra.Command{
 		if isTable {
 			pterm.Info.Printf("Scanning Docker image %q...\n", imageRef)
 		}
-		err := docker.Trivy(imageRef, scanOutputFormat, useAI)
+		cache := docker.ScanCacheOptions{Disabled: scanNoCache, TTL: scanCacheTTL}
+		err := docker.Trivy(imageRef, scanOutputFormat, configs.ScanFailOn, cache, useAI)
 		if err != nil {
 			return err
 		}
@@ -64,11 +73,20 @@ var scanCmd = &cobra.Command{
 
  smurf sdkr scan my-image:latest -o json
  # Prints the trivy scan report as a JSON document
+
+ smurf sdkr scan my-image:latest --no-cache-scan
+ # Results are cached by image digest under ~/.smurf/scan-cache; this forces a fresh scan
+
+ smurf sdkr scan my-image:latest --fail-on high
+ # Reports only HIGH and CRITICAL vulnerabilities and fails when there is any
 `,
 }
 
 func init() {
 	scanCmd.Flags().StringVarP(&scanOutputFormat, "output", "o", "table", "output format (table|json)")
+	scanCmd.Flags().BoolVar(&scanNoCache, "no-cache-scan", false, "Always run a fresh scan instead of reusing cached results for an unchanged image")
+	scanCmd.Flags().DurationVar(&scanCacheTTL, "cache-ttl", docker.DefaultScanCacheTTL, "How long cached scan results for an unchanged image are reused")
+	addFailOnFlag(scanCmd)
 	scanCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	_ = scanCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
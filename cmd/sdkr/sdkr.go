@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +14,16 @@ var sdkrCmd = &cobra.Command{
 	Use:   "sdkr",
 	Short: "Subcommand for Docker-related actions",
 	Long:  `sdkr is a subcommand that groups various Docker-related actions under a single command.`,
+	// Every build goes through an sdkr command, so the base image policy and
+	// build cache of smurf.yaml are loaded once here.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		required, err := configs.LoadRequirePinnedBases(configs.FileName)
+		if err != nil {
+			return err
+		}
+		configs.RequirePinnedBases = required
+		return applyBuildCache(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Use 'smurf sdkr [command]' to run Docker-related actions")
 	},
@@ -21,3 +33,64 @@ var sdkrCmd = &cobra.Command{
 func init() {
 	cmd.RootCmd.AddCommand(sdkrCmd)
 }
+
+// applyTimeoutPolicy loads the timeouts section of smurf.yaml (if any) into
+// configs.Timeouts and, unless --timeout was given explicitly, uses its
+// build phase as the command's build timeout.
+func applyTimeoutPolicy(c *cobra.Command) error {
+	policy, err := configs.LoadTimeoutPolicy(configs.FileName)
+	if err != nil {
+		return err
+	}
+	configs.Timeouts = policy
+
+	if f := c.Flags().Lookup("timeout"); f != nil && !f.Changed {
+		configs.BuildTimeout = policy.Build
+	}
+	return nil
+}
+
+// imageBuilder backs the --builder flag of build and push.
+var imageBuilder string
+
+// resolveBuilder returns the builder to use: --builder, or sdkr.builder from
+// smurf.yaml when the flag is not given, or docker.
+func resolveBuilder(c *cobra.Command) (string, error) {
+	name := imageBuilder
+	if !c.Flags().Changed("builder") {
+		fromConfig, err := configs.LoadSdkrBuilder(configs.FileName)
+		if err != nil {
+			return "", err
+		}
+		if fromConfig != "" {
+			name = fromConfig
+		}
+	}
+	if err := docker.ValidBuilder(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// addRemoteBuildFlags registers the flags of builds on a buildkitd in the
+// cluster, shared by build and deploy.
+func addRemoteBuildFlags(c *cobra.Command) {
+	c.Flags().StringVar(&configs.RemoteBuild, "remote-build", "", "Build on a buildkitd pod in the current kube context instead of locally: k8s (needs buildctl and kubectl, no Docker)")
+	c.Flags().StringVar(&configs.RemoteBuildNamespace, "remote-build-namespace", docker.DefaultRemoteBuildNamespace, "Namespace of the buildkitd deployment used by --remote-build")
+	c.Flags().BoolVar(&configs.KeepRemoteBuilder, "keep-remote-builder", false, "Leave the buildkitd deployment --remote-build started running, for faster builds after it")
+}
+
+// addReproducibleFlag registers --reproducible on c.
+func addReproducibleFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&configs.Reproducible, "reproducible", false, "Build reproducibly: SOURCE_DATE_EPOCH from the environment or the last commit, normalized build context timestamps, and print the resulting digest")
+}
+
+// remoteBuildOptions returns the --remote-build settings for BuildOptions.
+func remoteBuildOptions() docker.RemoteBuildOptions {
+	return docker.RemoteBuildOptions{Namespace: configs.RemoteBuildNamespace, Keep: configs.KeepRemoteBuilder}
+}
+
+// addBuilderFlag registers --builder on c.
+func addBuilderFlag(c *cobra.Command) {
+	c.Flags().StringVar(&imageBuilder, "builder", docker.BuilderDocker, "Image builder: docker, containerd (nerdctl) or buildkitd (buildctl) (overrides sdkr.builder in smurf.yaml)")
+}
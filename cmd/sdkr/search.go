@@ -0,0 +1,112 @@
+package sdkr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchRegistries []string
+	searchLimit      int
+	searchOutput     string
+	searchTimeout    int
+)
+
+// searchCmd finds repositories in the registries smurf pushes to, to find
+// the right image path without leaving the CLI.
+var searchCmd = &cobra.Command{
+	Use:   "search QUERY",
+	Short: "Search the repositories of container registries",
+	Long: `Search the repositories of one or more registries for QUERY, a case-insensitive
+part of the repository path, and list each match with its latest tag, its size
+and when it was last pushed.
+
+A registry is a host with an optional namespace, e.g. ghcr.io/my-org or
+docker.io/library. Without --registry, the registries under
+sdkr.searchRegistries in smurf.yaml are searched. Each registry is searched with
+the credentials smurf pushes to it with:
+
+  ECR            the ECR API (AWS credentials of the environment)
+  docker.io/NS   the Docker Hub API, as DOCKER_USERNAME when it is set
+  ghcr.io/OWNER  the GitHub packages API (GITHUB_TOKEN with read:packages)
+  anything else  the registry's catalog API (ACR, Harbor, registry:2, ...)
+
+GHCR does not report sizes. A registry that can't be searched is reported and
+skipped.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(searchOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", searchOutput)
+		}
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("timeout") {
+			searchTimeout = configs.Timeouts.Push
+		}
+		registries := searchRegistries
+		if len(registries) == 0 {
+			fromConfig, err := configs.LoadSearchRegistries(configs.FileName)
+			if err != nil {
+				return err
+			}
+			registries = fromConfig
+		}
+
+		if searchOutput == "json" {
+			pterm.SetDefaultOutput(os.Stderr)
+			defer pterm.SetDefaultOutput(os.Stdout)
+		}
+		results, err := docker.SearchRegistries(args[0], docker.SearchOptions{
+			Registries: registries,
+			Limit:      searchLimit,
+			Timeout:    time.Duration(searchTimeout) * time.Second,
+		}, useAI)
+		if err != nil {
+			return err
+		}
+
+		if searchOutput == "json" {
+			if results == nil {
+				results = []docker.SearchResult{}
+			}
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		docker.PrintSearchResults(results)
+		return nil
+	},
+	Example: `
+  # Find the payments images of an organization on GHCR
+  smurf sdkr search payments --registry ghcr.io/my-org
+
+  # Search an ECR registry and Docker Hub at once, as JSON
+  smurf sdkr search api --registry 123456789012.dkr.ecr.us-east-1.amazonaws.com --registry docker.io/my-org -o json
+
+  # Search the registries listed under sdkr.searchRegistries in smurf.yaml
+  smurf sdkr search worker
+`,
+}
+
+func init() {
+	searchCmd.Flags().StringArrayVar(&searchRegistries, "registry", nil, "Registry to search, as HOST[/NAMESPACE] (repeatable; default: sdkr.searchRegistries in smurf.yaml)")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 25, "Maximum number of matching repositories to list per registry (0 = all)")
+	searchCmd.Flags().StringVarP(&searchOutput, "output", "o", "table", "output format (table|json)")
+	searchCmd.Flags().IntVar(&searchTimeout, "timeout", configs.DefaultTimeouts.Push, "Timeout for the whole search in seconds (overrides timeouts.push in smurf.yaml)")
+	searchCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	sdkrCmd.AddCommand(searchCmd)
+}
@@ -0,0 +1,36 @@
+package sdkr
+
+import (
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	smokeTestArgs    string
+	smokeTestTimeout int
+	smokeTestHealth  string
+)
+
+// addSmokeTestFlags registers the smoke test flags on a command that builds
+// an image locally and pushes it.
+func addSmokeTestFlags(c *cobra.Command) {
+	c.Flags().StringVar(&smokeTestArgs, "smoke-test", "", "Run the built image with these arguments to its entrypoint before pushing, and fail if it exits non-zero (use \"\" for the image's default command with --smoke-test-health)")
+	c.Flags().IntVar(&smokeTestTimeout, "smoke-test-timeout", 30, "Seconds the smoke test container may run")
+	c.Flags().StringVar(&smokeTestHealth, "smoke-test-health", "", "PORT[/PATH] that must answer HTTP 2xx while the smoke test container runs, like 8080/healthz")
+}
+
+// runSmokeTest smoke tests a locally built image when --smoke-test or
+// --smoke-test-health is set. It must run before the image is pushed.
+func runSmokeTest(cmd *cobra.Command, image string) error {
+	if !cmd.Flags().Changed("smoke-test") && smokeTestHealth == "" {
+		return nil
+	}
+	return docker.SmokeTest(image, docker.SmokeTestOptions{
+		Args:    strings.Fields(smokeTestArgs),
+		Timeout: time.Duration(smokeTestTimeout) * time.Second,
+		Health:  smokeTestHealth,
+	}, useAI)
+}
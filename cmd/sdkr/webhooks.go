@@ -0,0 +1,63 @@
+package sdkr
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ci"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookURLs   []string
+	webhookSecret string
+)
+
+// addWebhookFlags registers the post-push webhook flags on a command that
+// pushes an image.
+func addWebhookFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&webhookURLs, "webhook", []string{}, "URL to POST the pushed image reference and digest to (repeatable; adds to sdkr.webhooks in smurf.yaml)")
+	c.Flags().StringVar(&webhookSecret, "webhook-secret", "", "HMAC-SHA256 key used to sign --webhook calls (default $SMURF_WEBHOOK_SECRET)")
+}
+
+// pushWebhooks returns the webhooks of sdkr.webhooks in smurf.yaml followed
+// by those given with --webhook.
+func pushWebhooks() ([]configs.WebhookConfig, error) {
+	hooks, err := configs.LoadWebhooks(configs.FileName)
+	if err != nil {
+		return nil, err
+	}
+	secret := webhookSecret
+	if secret == "" {
+		secret = os.Getenv("SMURF_WEBHOOK_SECRET")
+	}
+	for _, u := range webhookURLs {
+		hooks = append(hooks, configs.WebhookConfig{URL: u, Secret: secret})
+	}
+	return hooks, nil
+}
+
+// notifyPushWebhooks tells the configured webhooks about a pushed image and,
+// in CI mode, exports it for later jobs. Like the artifacts manifest, it must
+// run before the local image is deleted.
+func notifyPushWebhooks(image string) error {
+	hooks, err := pushWebhooks()
+	if err != nil || (len(hooks) == 0 && !ci.Enabled()) {
+		return err
+	}
+
+	digest, err := docker.ImageDigest(image)
+	if err != nil {
+		// Remote builds never have the image locally; ask the registry.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		digest, _ = docker.RemoteDigest(ctx, image)
+	}
+	if err := ci.Export(ci.ImageVars(image, digest)); err != nil {
+		return err
+	}
+	return docker.NotifyWebhooks(hooks, docker.NewPushEvent(image, digest))
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"github.com/clouddrove/smurf/internal/selfupdate"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// selfUpdateCmd replaces the running smurf with a release from GitHub.
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update [VERSION]",
+	Short: "Update smurf to the latest release, or the one the repository pins",
+	Long: `Self-update downloads a smurf release from GitHub, verifies it against the
+release's checksums.txt and replaces the running binary with it.
+
+Without VERSION it installs the newest release that satisfies the
+.smurf-version of the repository smurf runs in, or the latest release when
+there is none. Set GITHUB_TOKEN to avoid GitHub's anonymous rate limit.
+
+A smurf installed with Homebrew is updated with brew upgrade smurf instead.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exe, err := selfupdate.Executable()
+		if err != nil {
+			return err
+		}
+		pin, err := selfupdate.FindPin(".")
+		if err != nil {
+			return err
+		}
+
+		var rel *selfupdate.Release
+		switch {
+		case len(args) == 1:
+			if pin != nil && !pin.Matches(args[0]) {
+				pterm.Warning.Printfln("%s does not match the version %s pins: %s", args[0], pin.Path, pin.Constraint)
+			}
+			rel, err = selfupdate.Find(args[0])
+		case pin != nil:
+			pterm.Info.Printfln("Using the version %s pins: %s", pin.Path, pin.Constraint)
+			rel, err = selfupdate.NewestMatching(pin.Constraint)
+		default:
+			rel, err = selfupdate.Latest()
+		}
+		if err != nil {
+			return err
+		}
+		if rel.Tag == version {
+			pterm.Success.Printfln("smurf %s is already installed", version)
+			return nil
+		}
+
+		spinner, _ := pterm.DefaultSpinner.Start("Installing smurf " + rel.Tag + "...")
+		if err := selfupdate.Install(rel, exe); err != nil {
+			spinner.Fail(err.Error())
+			return err
+		}
+		spinner.Success("Updated smurf " + version + " to " + rel.Tag + " at " + exe)
+		return nil
+	},
+	Example: `
+  # Update to the pinned version, or to the latest release
+  smurf self-update
+
+  # Install a specific release
+  smurf self-update v1.4.2
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(selfUpdateCmd)
+}
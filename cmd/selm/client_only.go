@@ -0,0 +1,38 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientOnly  bool
+	kubeVersion string
+	apiVersions []string
+)
+
+// addClientOnlyFlags registers the offline validation flags shared by
+// install and upgrade.
+func addClientOnlyFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&clientOnly, "client-only", false, "Validate the chart, values and rendered manifests without a cluster, and deploy nothing")
+	c.Flags().StringVar(&kubeVersion, "kube-version", "", "Kubernetes version to render for with --client-only (e.g. 1.30.0)")
+	c.Flags().StringSliceVar(&apiVersions, "api-versions", []string{}, "API versions the templates see as available with --client-only (e.g. monitoring.coreos.com/v1)")
+}
+
+// runClientOnly validates releaseName from chartPath offline, as installed
+// or, with upgrade, as upgraded.
+func runClientOnly(releaseName, chartPath string, upgrade bool) error {
+	return helm.ValidateClientOnly(releaseName, chartPath, helm.ClientOnlyOptions{
+		Namespace:        configs.Namespace,
+		RepoURL:          RepoURL,
+		Version:          Version,
+		ValuesFiles:      configs.File,
+		SetValues:        configs.Set,
+		SetLiteralValues: configs.SetLiteral,
+		KubeVersion:      kubeVersion,
+		APIVersions:      apiVersions,
+		Upgrade:          upgrade,
+		Debug:            configs.Debug,
+	}, useAI)
+}
@@ -0,0 +1,65 @@
+package selm
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareContexts []string
+	compareExitCode bool
+)
+
+// compareCmd shows how a release differs between two clusters, e.g. staging
+// and production.
+var compareCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "compare RELEASE",
+	Short:       "Compare a release's chart, values and manifests across two kube contexts",
+	Long: `Fetch the deployed revision of RELEASE from the clusters of two kube
+contexts and show what differs between them: the chart and app version,
+the user-supplied values, and every rendered resource, hooks included.
+Diffs go from the first --context to the second.
+
+With --exit-code the command fails when the release differs, for drift
+checks in CI.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(compareContexts) != 2 {
+			return fmt.Errorf("--context must be given exactly twice, got %d", len(compareContexts))
+		}
+		contexts := [2]string{compareContexts[0], compareContexts[1]}
+		c, err := helm.CompareRelease(args[0], configs.Namespace, contexts, useAI)
+		if err != nil {
+			return err
+		}
+		if err := helm.PrintReleaseComparison(c, outputFormat); err != nil {
+			return err
+		}
+		if compareExitCode && !c.Identical() {
+			return fmt.Errorf("release %s differs between %s and %s", args[0], contexts[0], contexts[1])
+		}
+		return nil
+	},
+	Example: `
+  # How production differs from staging
+  smurf selm compare my-release --context staging --context production -n apps
+
+  # As JSON, failing when they differ
+  smurf selm compare my-release --context staging --context production -o json --exit-code
+`,
+}
+
+func init() {
+	compareCmd.Flags().StringArrayVar(&compareContexts, "context", []string{}, "Kube context to fetch the release from (exactly two)")
+	compareCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "default", "Namespace of the release")
+	compareCmd.Flags().BoolVar(&compareExitCode, "exit-code", false, "Fail when the release differs between the contexts")
+	compareCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	_ = compareCmd.MarkFlagRequired("context")
+	_ = compareCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(compareCmd)
+}
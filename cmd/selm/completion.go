@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/helm"
 	"github.com/spf13/cobra"
 )
@@ -35,6 +36,7 @@ func completeReleaseNames(cmd *cobra.Command, args []string, toComplete string)
 
 	namespace, _ := cmd.Flags().GetString("namespace")
 
+	helm.SetKubeTarget(configs.KubeConfig, configs.KubeContext)
 	names, err := helm.ListReleaseNames(namespace, completionTimeout)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
@@ -51,6 +53,7 @@ func completeNamespaces(cmd *cobra.Command, args []string, toComplete string) ([
 	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
 	defer cancel()
 
+	helm.SetKubeTarget(configs.KubeConfig, configs.KubeContext)
 	names, err := helm.ListNamespaces(ctx)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
@@ -58,3 +61,57 @@ func completeNamespaces(cmd *cobra.Command, args []string, toComplete string) ([
 
 	return names, cobra.ShellCompDirectiveNoFileComp
 }
+
+// completeRepoNames is a cobra ValidArgsFunction that suggests the chart
+// repository names configured in Helm's repositories file, for `repo update`.
+// Names already given on the command line are left out, since repo update
+// accepts several repositories at once. Like the other completion functions
+// it never prints, and degrades to no completions on any error.
+func completeRepoNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	helmConfigDir, _ := cmd.Flags().GetString("helm-config")
+
+	names, err := helm.ListRepoNames(helmConfigDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	given := make(map[string]bool, len(args))
+	for _, a := range args {
+		given[a] = true
+	}
+
+	suggestions := make([]string, 0, len(names))
+	for _, name := range names {
+		if !given[name] {
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeChartRepoPrefixes is a cobra ValidArgsFunction for commands that
+// take a REPO/CHART reference (pull). It suggests "<repo>/" for every
+// configured repository without a trailing space, so the user can go on to
+// type the chart name. Local paths and URLs are still valid arguments, so on
+// any error it falls back to the shell's default file completion instead of
+// suppressing it.
+func completeChartRepoPrefixes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	helmConfigDir, _ := cmd.Flags().GetString("helm-config")
+
+	names, err := helm.ListRepoNames(helmConfigDir)
+	if err != nil || len(names) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	prefixes := make([]string, 0, len(names))
+	for _, name := range names {
+		prefixes = append(prefixes, name+"/")
+	}
+
+	return prefixes, cobra.ShellCompDirectiveNoSpace
+}
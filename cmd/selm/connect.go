@@ -0,0 +1,107 @@
+package selm
+
+import (
+	"errors"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	connectEKS            string
+	connectGKE            string
+	connectAKS            string
+	connectRegion         string
+	connectProject        string
+	connectResourceGroup  string
+	connectSubscriptionID string
+	connectContext        string
+	connectNamespace      string
+)
+
+// connectCmd fetches the credentials of a managed Kubernetes cluster from its
+// cloud provider and writes them as the current kubeconfig context, so the
+// other selm commands and deploy can target clusters by their cloud name.
+var connectCmd = &cobra.Command{
+	Use:   "connect",
+	Short: "Add a managed EKS, GKE or AKS cluster to the kubeconfig and switch to it",
+	Long: `Connect fetches the endpoint and credentials of a managed Kubernetes cluster
+through the cloud provider's API, writes them into the kubeconfig as a new
+context and makes it the current context.
+
+Authentication uses each provider's default credential chain (AWS shared
+config and environment, Google application default credentials, Azure
+DefaultAzureCredential). EKS and GKE contexts authenticate through the
+'aws' CLI and 'gke-gcloud-auth-plugin' respectively, which must be on PATH
+when the cluster is used.
+
+Without flags, the cluster is read from the selm.cluster section of smurf.yaml.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := configs.ClusterConfig{
+			Region:         connectRegion,
+			Project:        connectProject,
+			ResourceGroup:  connectResourceGroup,
+			SubscriptionID: connectSubscriptionID,
+			Context:        connectContext,
+		}
+		switch {
+		case connectEKS != "":
+			target.Provider, target.Name = "eks", connectEKS
+		case connectGKE != "":
+			target.Provider, target.Name = "gke", connectGKE
+		case connectAKS != "":
+			target.Provider, target.Name = "aks", connectAKS
+		default:
+			data, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+			if data.Selm.Cluster.Name == "" {
+				return errors.New("a cluster must be provided with --eks, --gke or --aks, or in selm.cluster in smurf.yaml")
+			}
+			target = data.Selm.Cluster
+			if connectContext != "" {
+				target.Context = connectContext
+			}
+			if connectNamespace == "" {
+				connectNamespace = data.Selm.Namespace
+			}
+		}
+
+		_, err := helm.ConnectCluster(target, connectNamespace)
+		return err
+	},
+	Example: `
+  # Connect to an EKS cluster
+  smurf selm connect --eks my-cluster --region us-east-1
+
+  # Connect to a GKE cluster (region or zone)
+  smurf selm connect --gke my-cluster --project my-project --region europe-west1
+
+  # Connect to an AKS cluster under a custom context name
+  smurf selm connect --aks my-cluster --resource-group my-rg --subscription-id <SUBSCRIPTION_ID> --context prod
+
+  # Connect to the cluster configured in selm.cluster in smurf.yaml
+  smurf selm connect
+`,
+}
+
+func init() {
+	connectCmd.Flags().StringVar(&connectEKS, "eks", "", "Name of the EKS cluster to connect to")
+	connectCmd.Flags().StringVar(&connectGKE, "gke", "", "Name of the GKE cluster to connect to")
+	connectCmd.Flags().StringVar(&connectAKS, "aks", "", "Name of the AKS cluster to connect to")
+	connectCmd.Flags().StringVar(&connectRegion, "region", "", "AWS region of the EKS cluster, or location (region or zone) of the GKE cluster")
+	connectCmd.Flags().StringVar(&connectProject, "project", "", "GCP project of the GKE cluster")
+	connectCmd.Flags().StringVar(&connectResourceGroup, "resource-group", "", "Azure resource group of the AKS cluster")
+	connectCmd.Flags().StringVar(&connectSubscriptionID, "subscription-id", "", "Azure subscription ID of the AKS cluster")
+	connectCmd.Flags().StringVar(&connectContext, "context", "", "Name of the kubeconfig context to write (default: <provider>_<location>_<name>)")
+	connectCmd.Flags().StringVarP(&connectNamespace, "namespace", "n", "", "Default namespace of the new context")
+	connectCmd.MarkFlagsMutuallyExclusive("eks", "gke", "aks")
+
+	_ = connectCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+
+	selmCmd.AddCommand(connectCmd)
+}
@@ -2,6 +2,7 @@ package selm
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/helm"
@@ -9,6 +10,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// createScaffold and scaffoldOpts make create also write a smurf.yaml and a
+// CI workflow that deploy the new chart.
+var (
+	createScaffold bool
+	scaffoldOpts   helm.ScaffoldOptions
+)
+
 // createChartCmd is a subcommand that creates a new Helm chart in a specified (or default) directory.
 // If no chart name is provided as an argument, it attempts to load a default name from the config file.
 // It also supports specifying additional values via YAML files. Usage examples are provided below,
@@ -19,6 +27,14 @@ var createChartCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if !createScaffold {
+			for _, f := range []string{"registry", "ci", "image", "namespace"} {
+				if cmd.Flags().Changed(f) {
+					return fmt.Errorf("--%s requires --scaffold", f)
+				}
+			}
+		}
+
 		var name string
 
 		if len(args) >= 1 {
@@ -43,7 +59,12 @@ var createChartCmd = &cobra.Command{
 			pterm.Info.Printfln("Using values files: %v\n", configs.File)
 		}
 
-		err := helm.CreateChart(name, configs.Directory)
+		var err error
+		if createScaffold {
+			err = helm.CreateScaffold(name, configs.Directory, scaffoldOpts)
+		} else {
+			err = helm.CreateChart(name, configs.Directory)
+		}
 		if err != nil {
 			return err
 		}
@@ -54,11 +75,24 @@ smurf selm create mychart
 # In this example, it will create 'mychart' in the current directory
 smurf selm create
 # In this example, it will create a chart with the name specified in the config in the current directory
+smurf selm create my-api --scaffold --registry ghcr --image ghcr.io/my-org/my-api --ci github
+# In this example, it will also write a smurf.yaml and a GitHub Actions workflow that deploy 'my-api' with smurf deploy
 `,
 }
 
 func init() {
 	createChartCmd.Flags().StringArrayVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file")
 	createChartCmd.Flags().StringVarP(&configs.Directory, "directory", "d", ".", "Specify the directory to create the Helm chart in")
+	createChartCmd.Flags().BoolVar(&createScaffold, "scaffold", false, "Also write a smurf.yaml and a CI workflow that deploy the chart with smurf deploy")
+	createChartCmd.Flags().StringVar(&scaffoldOpts.Registry, "registry", "hub", "Registry the scaffold pushes to (hub|ecr|ghcr|gcp)")
+	createChartCmd.Flags().StringVar(&scaffoldOpts.CI, "ci", "github", "CI workflow the scaffold writes (github|gitlab|none)")
+	createChartCmd.Flags().StringVar(&scaffoldOpts.Image, "image", "", "Image repository the scaffold deploys, without a tag (default: a placeholder for --registry)")
+	createChartCmd.Flags().StringVarP(&scaffoldOpts.Namespace, "namespace", "n", "default", "Namespace the scaffold deploys the release to")
+	_ = createChartCmd.RegisterFlagCompletionFunc("registry", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"hub", "ecr", "ghcr", "gcp"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = createChartCmd.RegisterFlagCompletionFunc("ci", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"github", "gitlab", "none"}, cobra.ShellCompDirectiveNoFileComp
+	})
 	selmCmd.AddCommand(createChartCmd)
 }
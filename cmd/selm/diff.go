@@ -0,0 +1,106 @@
+package selm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var diffExitCode bool
+
+// diffCmd shows what an upgrade would change in a release's manifest,
+// without upgrading it.
+var diffCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "diff [RELEASE] [CHART]",
+	Short:       "Show the manifest changes an upgrade of a release would make",
+	Long: `Render CHART with the merged values as 'selm upgrade' would, without
+changing anything, and show a colored unified diff of every resource against
+the manifest of the deployed revision, hooks included. A release that isn't
+installed yet shows every resource as added.
+
+RELEASE and CHART default to selm.releaseName and selm.chartName from
+smurf.yaml. With --exit-code the command fails when the upgrade would change
+something, for checks in CI.`,
+	Args:         cobra.MaximumNArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+
+		var releaseName, chartPath string
+		if len(args) >= 1 {
+			releaseName = args[0]
+		}
+		if len(args) >= 2 {
+			chartPath = args[1]
+		}
+		if releaseName == "" || chartPath == "" {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+			if releaseName == "" {
+				releaseName = data.Selm.ReleaseName
+				if releaseName == "" {
+					releaseName = filepath.Base(data.Selm.ChartName)
+				}
+			}
+			if chartPath == "" {
+				chartPath = data.Selm.ChartName
+			}
+			if releaseName == "" || chartPath == "" {
+				return errors.New("RELEASE and CHART must be provided either as arguments or in the config")
+			}
+			if configs.Namespace == "default" && data.Selm.Namespace != "" {
+				configs.Namespace = data.Selm.Namespace
+			}
+		}
+
+		d, err := helm.DiffRelease(releaseName, chartPath, configs.Namespace, configs.File, configs.Set, configs.SetLiteral,
+			RepoURL, Version, configs.Debug, useAI)
+		if err != nil {
+			return err
+		}
+		if err := helm.PrintReleaseDiff(d, outputFormat); err != nil {
+			return err
+		}
+		if diffExitCode && !d.Empty() {
+			return fmt.Errorf("upgrading %s would change %d resources", releaseName, len(d.Resources))
+		}
+		return nil
+	},
+	Example: `
+  # What upgrading my-release to the local chart would change
+  smurf selm diff my-release ./mychart -f values.yaml -n apps
+
+  # The release and chart of smurf.yaml, with a value override
+  smurf selm diff --set image.tag=v2
+
+  # As JSON, failing when the upgrade would change something
+  smurf selm diff my-release ./mychart -o json --exit-code
+`,
+}
+
+func init() {
+	diffCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	diffCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line (values are always treated as strings)")
+	diffCmd.Flags().StringSliceVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
+	diffCmd.Flags().StringArrayVar(&configs.ValuesFrom, "values-from", []string{}, "Merge values stored in the cluster, as configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY (repeatable; applied after --values)")
+	diffCmd.Flags().BoolVar(&configs.NoNamespaceDefaults, "no-namespace-defaults", false, "Ignore the default values of the smurf-defaults ConfigMap in the release namespace")
+	diffCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "default", "Namespace of the release")
+	diffCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
+	diffCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	diffCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Fail when the upgrade would change the release")
+	diffCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	diffCmd.ValidArgsFunction = completeReleaseNames
+	_ = diffCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(diffCmd)
+}
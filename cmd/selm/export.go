@@ -0,0 +1,116 @@
+package selm
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var exportOpts helm.ExportOptions
+
+// exportCmd renders a release and commits it to a GitOps repository
+// instead of applying it, for Argo CD or Flux to deploy.
+var exportCmd = &cobra.Command{
+	Use:   "export [RELEASE] [CHART]",
+	Short: "Render a release and commit its manifests to a GitOps repository",
+	Long: `Export renders RELEASE from CHART with the given values and commits the
+manifests and values to --path of the --git-repo repository, instead of
+applying them to a cluster. Argo CD or Flux then apply the commit.
+
+The path is replaced as a whole and holds manifests.yaml, values.yaml and a
+kustomization.yaml listing the manifests. With --pr the commit goes to a new
+branch and a pull request is opened against --branch with the gh CLI.`,
+	Args:         cobra.MaximumNArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+
+		var releaseName, chartPath string
+		if len(args) >= 1 {
+			releaseName = args[0]
+		}
+		if len(args) >= 2 {
+			chartPath = args[1]
+		}
+
+		if releaseName == "" || chartPath == "" {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+
+			if releaseName == "" {
+				releaseName = data.Selm.ReleaseName
+				if releaseName == "" {
+					releaseName = filepath.Base(data.Selm.ChartName)
+				}
+			}
+			if chartPath == "" {
+				chartPath = data.Selm.ChartName
+			}
+
+			if releaseName == "" || chartPath == "" {
+				pterm.Error.Printfln("RELEASE and CHART must be provided either as arguments or in the config")
+				return errors.New("RELEASE and CHART must be provided either as arguments or in the config")
+			}
+
+			if configs.Namespace == "" && data.Selm.Namespace != "" {
+				configs.Namespace = data.Selm.Namespace
+			}
+			if len(configs.File) == 0 && data.Selm.FileName != "" {
+				configs.File = []string{data.Selm.FileName}
+			}
+		}
+
+		if configs.Namespace == "" {
+			configs.Namespace = "default"
+		}
+
+		exportOpts.Namespace = configs.Namespace
+		exportOpts.ValuesFiles = configs.File
+		exportOpts.SetValues = configs.Set
+		exportOpts.SetLiteralValues = configs.SetLiteral
+		exportOpts.RepoURL = RepoURL
+		exportOpts.Version = Version
+		exportOpts.Debug = configs.Debug
+		return helm.ExportRelease(releaseName, chartPath, exportOpts, useAI)
+	},
+	Example: `
+  # Commit the rendered release to apps/api on the default branch
+  smurf selm export api ./charts/api -f values-prod.yaml \
+    --git-repo git@github.com:acme/env-config.git --path apps/api
+
+  # Open a pull request against the prod branch instead of pushing to it
+  smurf selm export api ./charts/api --set image.tag=v1.4.2 \
+    --git-repo git@github.com:acme/env-config.git --path apps/api --branch prod --pr
+`,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportOpts.GitRepo, "git-repo", "", "URL of the GitOps repository to commit to")
+	exportCmd.Flags().StringVar(&exportOpts.Path, "path", "", "Directory in the repository to write the release to")
+	exportCmd.Flags().StringVar(&exportOpts.Branch, "branch", "", "Branch to commit to, or the base of the pull request with --pr (default: the repository's default branch)")
+	exportCmd.Flags().BoolVar(&exportOpts.PullRequest, "pr", false, "Push to a new branch and open a pull request with the gh CLI")
+	exportCmd.Flags().StringVarP(&exportOpts.Message, "message", "m", "", "Commit message (default: \"Export RELEASE to PATH\")")
+	exportCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Namespace to render the release for")
+	exportCmd.Flags().StringSliceVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
+	exportCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	exportCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line (values are always treated as strings)")
+	exportCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
+	exportCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	exportCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
+	exportCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	exportCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = exportCmd.MarkFlagRequired("git-repo")
+	_ = exportCmd.MarkFlagRequired("path")
+	_ = exportCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+
+	selmCmd.AddCommand(exportCmd)
+}
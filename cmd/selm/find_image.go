@@ -0,0 +1,51 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var findImageNamespace string
+
+// findImageCmd lists the deployed releases that run a given image, e.g. to
+// know what to redeploy after a CVE.
+var findImageCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "find-image IMAGE[:TAG|@DIGEST]",
+	Short:       "List the releases whose manifests reference an image",
+	Long: `Scan the manifests of the deployed releases, hooks included, for containers,
+init containers and ephemeral containers running IMAGE, and list the releases
+that do.
+
+IMAGE without a tag matches every tag of the repository; with a tag or digest
+only that one. A bare digest (sha256:...) matches it in any repository.
+Names are normalized, so nginx matches docker.io/library/nginx. All
+namespaces are scanned unless --namespace is given.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usages, err := helm.FindImage(args[0], findImageNamespace, useAI)
+		if err != nil {
+			return err
+		}
+		return helm.PrintImageUsage(usages, args[0], outputFormat)
+	},
+	Example: `
+  # Every release running any tag of the image
+  smurf selm find-image ghcr.io/acme/api
+
+  # Only a vulnerable tag, in one namespace
+  smurf selm find-image openssl-base:3.0.1 -n apps
+
+  # A digest reported by a scanner, as JSON
+  smurf selm find-image sha256:4f3a... -o json
+`,
+}
+
+func init() {
+	findImageCmd.Flags().StringVarP(&findImageNamespace, "namespace", "n", "", "only scan releases in this namespace (default: all namespaces)")
+	findImageCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = findImageCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(findImageCmd)
+}
@@ -0,0 +1,80 @@
+package selm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcDryRun bool
+	gcYes    bool
+)
+
+// gcCmd uninstalls the ephemeral releases whose TTL has passed.
+var gcCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "gc",
+	Short:       "Uninstall ephemeral releases whose TTL has passed",
+	Long: `Uninstall the releases installed with 'smurf selm install --ephemeral' whose
+--ttl has passed, in every namespace or only in --namespace. A namespace the
+ephemeral install created is deleted too, once no release is left in it.
+
+The TTL is kept in labels of the release record, so gc needs no state of its
+own: run it on a schedule, or at the start of every CI pipeline that creates
+test environments.
+
+  --dry-run   only list the ephemeral releases and when they expire`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		rels, err := helm.FindEphemeralReleases(configs.Namespace, now, useAI)
+		if err != nil {
+			return err
+		}
+		if err := helm.PrintEphemeralReleases(rels, outputFormat, now); err != nil {
+			return err
+		}
+
+		expired := 0
+		for _, r := range rels {
+			if r.Expired {
+				expired++
+			}
+		}
+		if gcDryRun || expired == 0 {
+			return nil
+		}
+		if err := confirmAction(fmt.Sprintf("Uninstall %d expired release(s)?", expired), gcYes); err != nil {
+			return err
+		}
+		if err := helm.CollectEphemeralReleases(rels, configs.Timeouts.HelmWaitTimeout(), useAI); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Uninstalled %d expired release(s)", expired)
+		return nil
+	},
+	Example: `
+  # Uninstall every expired ephemeral release in the cluster
+  smurf selm gc --yes
+
+  # List the ephemeral releases of one namespace and when they expire
+  smurf selm gc -n integration --dry-run
+`,
+}
+
+func init() {
+	gcCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Only collect releases in this namespace (default: all namespaces)")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "List the ephemeral releases without uninstalling any")
+	gcCmd.Flags().BoolVarP(&gcYes, "yes", "y", false, "Do not ask for confirmation")
+	gcCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = gcCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+
+	selmCmd.AddCommand(gcCmd)
+}
@@ -0,0 +1,68 @@
+package selm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+
+// graphCmd draws how the resources of a release own, select and route to
+// each other.
+var graphCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "graph RELEASE",
+	Short:       "Draw the resource topology of a release as a Mermaid or Graphviz graph",
+	Long: `Draw how the resources of a release relate in the cluster: workloads owning
+their ReplicaSets, Jobs and pods (Deployment → ReplicaSet → Pod), Services
+selecting pods and Ingresses routing to Services.
+
+The graph is printed as a Mermaid flowchart (--format mermaid), which renders
+in GitHub and GitLab markdown, or a Graphviz digraph (--format dot). Pods
+that are not ready, and workloads, Services and Ingresses of the release
+missing from the cluster, are drawn in red. Other resources of the release
+are drawn without edges. -o json or yaml prints the nodes and edges instead.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.Contains(helm.GraphFormats, graphFormat) {
+			return fmt.Errorf("invalid graph format %q: must be one of %s", graphFormat, strings.Join(helm.GraphFormats, ", "))
+		}
+		namespace := configs.Namespace
+		if namespace == "" {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err == nil {
+				namespace = data.Selm.Namespace
+			}
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		return helm.HelmGraph(args[0], namespace, graphFormat, outputFormat, useAI)
+	},
+	Example: `
+  # Mermaid flowchart, e.g. for an incident doc or a PR comment
+  smurf selm graph my-release -n apps
+
+  # Graphviz image
+  smurf selm graph my-release -n apps --format dot | dot -Tsvg > my-release.svg
+`,
+}
+
+func init() {
+	graphCmd.Flags().StringVar(&graphFormat, "format", "mermaid", "Graph format (mermaid|dot)")
+	graphCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "namespace of the release")
+	graphCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	graphCmd.ValidArgsFunction = completeReleaseNames
+	_ = graphCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	_ = graphCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return helm.GraphFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	selmCmd.AddCommand(graphCmd)
+}
@@ -0,0 +1,52 @@
+package selm
+
+import (
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	healthURLs          []string
+	healthStatus        int
+	healthBody          string
+	healthTimeout       int
+	rollbackOnUnhealthy bool
+)
+
+// addHealthFlags registers the post-deploy health gate flags shared by
+// install and upgrade.
+func addHealthFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&healthURLs, "health-url", []string{}, "HTTP(S) endpoint that must answer before the release counts as deployed (repeatable)")
+	c.Flags().IntVar(&healthStatus, "health-status", 200, "Status code the --health-url endpoints must answer with")
+	c.Flags().StringVar(&healthBody, "health-body", "", "Regular expression the --health-url response bodies must match")
+	c.Flags().IntVar(&healthTimeout, "health-timeout", configs.DefaultTimeouts.Readiness, "Time in seconds for the --health-url endpoints to pass (overrides timeouts.readiness in smurf.yaml)")
+	c.Flags().BoolVar(&rollbackOnUnhealthy, "rollback-on-unhealthy", false, "Roll back to the previous revision when the health checks don't pass")
+}
+
+// healthChecksFromFlags returns the health checks given with --health-url,
+// validated before anything is deployed.
+func healthChecksFromFlags() ([]configs.HealthCheck, error) {
+	checks := make([]configs.HealthCheck, len(healthURLs))
+	for i, u := range healthURLs {
+		checks[i] = configs.HealthCheck{URL: u, Status: healthStatus, Body: healthBody}
+		if err := checks[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+// runHealthGate polls the health checks of a release that was just deployed.
+func runHealthGate(c *cobra.Command, releaseName string, checks []configs.HealthCheck) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	timeout := healthTimeout
+	if !c.Flags().Changed("health-timeout") {
+		timeout = configs.Timeouts.Readiness
+	}
+	return helm.HealthGate(releaseName, configs.Namespace, checks, time.Duration(timeout)*time.Second, rollbackOnUnhealthy, configs.Debug)
+}
@@ -0,0 +1,77 @@
+package selm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+// helmCompatCmd takes the most common helm CLI invocations as they are and
+// runs them as the selm commands that do the same, so scripts moving from
+// helm to smurf only need `helm` replaced by `smurf helm`.
+var helmCompatCmd = &cobra.Command{
+	Use:   "helm",
+	Short: "Run helm install, upgrade, list and uninstall invocations with selm",
+	Long: `Run the most common helm CLI invocations unchanged, with smurf's selm
+implementation: 'smurf helm upgrade --install app ./chart -n prod --timeout 5m'
+runs 'smurf selm upgrade' with the same meaning.
+
+The helm flags are translated into selm's: --timeout durations become seconds,
+--set-string becomes --set-literal, and 'install' does not wait unless --wait
+is given, as helm does. A helm flag selm has no counterpart for is an error
+that names what to use instead; it is never silently dropped. smurf.yaml
+applies as it does to the selm commands.`,
+	Example: `
+  smurf helm install my-release ./mychart -n my-namespace -f values.yaml --set image.tag=v2
+  smurf helm upgrade --install my-release ./mychart --atomic --timeout 10m
+  smurf helm list -A -o json
+  smurf helm uninstall my-release -n my-namespace
+`,
+}
+
+// newHelmCompatCommand returns the `smurf helm` command for the helm
+// command use, which parses the helm flags itself.
+func newHelmCompatCommand(use, short string, aliases ...string) *cobra.Command {
+	name, _, _ := strings.Cut(use, " ")
+	return &cobra.Command{
+		Use:     use,
+		Aliases: aliases,
+		Short:   short,
+		Long: fmt.Sprintf("%s.\n\nAccepted helm flags: %s",
+			short, strings.Join(helm.HelmCLIFlags(name), ", ")),
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		// The selm command prints its own errors.
+		SilenceErrors: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			flags := args
+			if i := slices.Index(args, "--"); i >= 0 {
+				flags = args[:i]
+			}
+			if slices.Contains(flags, "-h") || slices.Contains(flags, "--help") {
+				return c.Help()
+			}
+			selmArgs, err := helm.TranslateHelmCLIArgs(c.Name(), args)
+			if err != nil {
+				c.PrintErrln("Error:", err)
+				return err
+			}
+			cmd.RootCmd.SetArgs(append([]string{"selm"}, selmArgs...))
+			return cmd.RootCmd.Execute()
+		},
+	}
+}
+
+func init() {
+	helmCompatCmd.AddCommand(
+		newHelmCompatCommand("install [NAME] [CHART] [flags]", "Install a chart, as helm install does, with smurf selm install"),
+		newHelmCompatCommand("upgrade [RELEASE] [CHART] [flags]", "Upgrade a release, as helm upgrade does, with smurf selm upgrade"),
+		newHelmCompatCommand("list [flags]", "List releases, as helm list does, with smurf selm list", "ls"),
+		newHelmCompatCommand("uninstall RELEASE_NAME [flags]", "Uninstall a release, as helm uninstall does, with smurf selm uninstall", "delete", "del", "un"),
+	)
+	cmd.RootCmd.AddCommand(helmCompatCmd)
+}
@@ -0,0 +1,77 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var hooksReleaseName string
+
+// hooksCmd lists the hooks of a chart in the order helm runs them, to debug
+// charts whose hooks misbehave.
+var hooksCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "hooks CHART",
+	Short:       "List the hooks of a chart with their events, weights and delete policies",
+	Long: `Render CHART with the given values, without a cluster, and list its hooks
+in the order helm runs them: by event (pre-install, post-install,
+pre-upgrade, ...), then by weight, then by name. Each hook shows its kind,
+name, delete policies and the template it comes from.
+
+To control which hooks run, install and upgrade take --no-hooks, which runs
+none, and --hooks-only EVENT,..., which runs only the hooks of those events.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+		return helm.ListHooks(hooksReleaseName, args[0], helm.HooksOptions{
+			Namespace:        configs.Namespace,
+			RepoURL:          RepoURL,
+			Version:          Version,
+			ValuesFiles:      configs.File,
+			SetValues:        configs.Set,
+			SetLiteralValues: configs.SetLiteral,
+			Debug:            configs.Debug,
+		}, outputFormat, useAI)
+	},
+	Example: `
+  # The hooks of a local chart
+  smurf selm hooks ./mychart
+
+  # With the values of an environment, as JSON
+  smurf selm hooks ./mychart -f values-prod.yaml -o json
+
+  # Then upgrade running only the pre-upgrade hooks
+  smurf selm upgrade my-release ./mychart --hooks-only pre-upgrade
+`,
+}
+
+// addHookFlags registers the hook execution flags shared by install and
+// upgrade.
+func addHookFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&configs.NoHooks, "no-hooks", false, "Run none of the chart's hooks")
+	c.Flags().StringSliceVar(&configs.HooksOnly, "hooks-only", []string{}, "Run only the hooks of these events, e.g. pre-upgrade (test hooks are kept for helm test)")
+	c.MarkFlagsMutuallyExclusive("no-hooks", "hooks-only")
+	_ = c.RegisterFlagCompletionFunc("hooks-only", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"pre-install", "post-install", "pre-upgrade", "post-upgrade"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func init() {
+	hooksCmd.Flags().StringVar(&hooksReleaseName, "release-name", "release-name", "Release name to render the chart with")
+	hooksCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Namespace to render the chart for")
+	hooksCmd.Flags().StringSliceVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
+	hooksCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	hooksCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line (values are always treated as strings)")
+	hooksCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
+	hooksCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	hooksCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
+	hooksCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	hooksCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = hooksCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(hooksCmd)
+}
@@ -3,6 +3,7 @@ package selm
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/clouddrove/smurf/configs"
@@ -13,6 +14,8 @@ import (
 
 var RepoURL string
 var Version string
+var installEphemeral bool
+var installGenerateName bool
 
 var installCmd = &cobra.Command{
 	Use:          "install [RELEASE] [CHART]",
@@ -20,12 +23,45 @@ var installCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(2),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+		if err := applyValuesSchema(cmd); err != nil {
+			return err
+		}
+		if err := helm.ValidateHookEvents(configs.HooksOnly); err != nil {
+			return err
+		}
+		healthChecks, err := healthChecksFromFlags()
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("ttl") {
+			installEphemeral = true
+		}
+		if installEphemeral && configs.EphemeralTTL <= 0 {
+			return fmt.Errorf("--ttl must be positive")
+		}
+		if !installEphemeral {
+			configs.EphemeralTTL = 0
+		}
+
 		var releaseName, chartPath string
-		if len(args) >= 1 {
+		switch {
+		case installGenerateName && len(args) == 2:
+			return fmt.Errorf("--generate-name takes only CHART; the release name is generated")
+		case installGenerateName && len(args) == 1:
+			chartPath = args[0]
+			releaseName = strings.TrimSuffix(filepath.Base(chartPath), ".tgz")
+		case len(args) >= 1:
 			releaseName = args[0]
-		}
-		if len(args) >= 2 {
-			chartPath = args[1]
+			if len(args) >= 2 {
+				chartPath = args[1]
+			}
 		}
 
 		if releaseName == "" || chartPath == "" {
@@ -53,6 +89,11 @@ var installCmd = &cobra.Command{
 			}
 		}
 
+		if installGenerateName {
+			releaseName = helm.GenerateReleaseName(releaseName)
+			pterm.Info.Printfln("Generated release name: %s", releaseName)
+		}
+
 		timeoutDuration := time.Duration(configs.Timeout) * time.Second
 
 		if configs.Namespace == "" {
@@ -74,9 +115,13 @@ var installCmd = &cobra.Command{
 			pterm.Debug.Printfln("  Wait: %v", configs.Wait)
 		}
 
+		if clientOnly {
+			return runClientOnly(releaseName, chartPath, false)
+		}
+
 		pterm.Println(fmt.Sprintf("🚀 Installing release '%s' in namespace '%s'\n", releaseName, configs.Namespace))
 
-		err := helm.HelmInstall(
+		err = helm.HelmInstall(
 			releaseName,
 			chartPath,
 			configs.Namespace,
@@ -94,18 +139,25 @@ var installCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return nil
+		return runHealthGate(cmd, releaseName, healthChecks)
 	},
 	Example: `
   smurf selm install my-release ./mychart
   smurf selm install my-release ./mychart -n my-namespace
   smurf selm install my-release ./mychart -f values.yaml
   smurf selm install my-release ./mychart --timeout=600
+  smurf selm install my-release ./mychart --values-from configmap/platform/env-config:values.yaml --values-from secret/platform/app-secrets:values.yaml
   smurf selm install prometheus-11 prometheus --repo https://prometheus-community.github.io/helm-charts --version 13.0.0
   smurf selm install prometheus prometheus-community/prometheus
+  smurf selm install my-release oci://ghcr.io/my-org/charts/mychart --version 1.2.0 --verify  # OCI chart, provenance verified
   smurf selm install my-release ./mychart --set key1=val1 --set key2=val2
   smurf selm install my-release ./mychart --set-literal myPassword='MySecurePass!'
+  smurf selm install my-release ./mychart --health-url https://my-app.example.com/healthz
   smurf selm install --wait  # Wait for resources to be ready
+  smurf selm install my-release ./mychart -f values.yaml --client-only --kube-version 1.30.0  # Validate offline, without a cluster
+  smurf selm install my-release ./mychart --hooks-only pre-install  # Skip the post-install hooks
+  smurf selm install my-release ./mychart -f values.yaml -f values-prod.yaml --explain-values  # Show where each value comes from
+  smurf selm install ./mychart --generate-name --ephemeral --ttl 2h -n pr-123  # Per-run test environment, removed by 'smurf selm gc'
   smurf selm install
   # In the last example, it will read RELEASE and CHART from the config file
   `,
@@ -113,15 +165,27 @@ var installCmd = &cobra.Command{
 
 func init() {
 	installCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Specify the namespace to install the Helm chart")
-	installCmd.Flags().IntVar(&configs.Timeout, "timeout", 600, "Specify the timeout in seconds to wait for any individual Kubernetes operation")
+	installCmd.Flags().IntVar(&configs.Timeout, "timeout", configs.DefaultTimeouts.HelmWait, "Specify the timeout in seconds to wait for any individual Kubernetes operation (overrides timeouts.helmWait in smurf.yaml)")
 	installCmd.Flags().StringArrayVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file")
+	installCmd.Flags().StringArrayVar(&configs.ValuesFrom, "values-from", []string{}, "Merge values stored in the cluster, as configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY (repeatable; applied after --values)")
+	installCmd.Flags().BoolVar(&configs.NoNamespaceDefaults, "no-namespace-defaults", false, "Ignore the default values of the smurf-defaults ConfigMap in the release namespace")
+	installCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
 	installCmd.Flags().BoolVar(&configs.Atomic, "atomic", false, "If set, installation process purges chart on fail")
 	installCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
 	installCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line")
 	installCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line")
 	installCmd.Flags().StringVar(&RepoURL, "repo", "", "Specify the chart repository URL for remote charts")
 	installCmd.Flags().StringVar(&Version, "version", "", "Specify the chart version to install")
+	addVerifyFlags(installCmd)
 	installCmd.Flags().BoolVar(&configs.Wait, "wait", true, "Wait for all resources to be ready before marking the release as successful")
+	addHealthFlags(installCmd)
+	addClientOnlyFlags(installCmd)
+	addHookFlags(installCmd)
+	addSchemaFlags(installCmd)
+	installCmd.Flags().BoolVar(&configs.ExplainValues, "explain-values", false, "Print the source of every effective value: chart default, namespace defaults, values file, --values-from or --set")
+	installCmd.Flags().BoolVar(&installEphemeral, "ephemeral", false, "Label the release for 'smurf selm gc' to uninstall once --ttl has passed")
+	installCmd.Flags().DurationVar(&configs.EphemeralTTL, "ttl", helm.DefaultEphemeralTTL, "How long an --ephemeral release lives (implies --ephemeral)")
+	installCmd.Flags().BoolVar(&installGenerateName, "generate-name", false, "Name the release after the chart (or RELEASE) with a random suffix; then the only argument is CHART")
 	installCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	_ = installCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
@@ -18,6 +18,10 @@ var lintCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+
 		var chartPath string
 
 		if len(args) == 1 {
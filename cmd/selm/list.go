@@ -8,14 +8,14 @@ import (
 
 var (
 	allNamespaces bool
-	outputFormat  string
 	namespace     string
 )
 
 var listCmd = &cobra.Command{
-	Use:     "list",
-	Aliases: []string{"ls"},
-	Short:   "List Helm releases",
+	Annotations: structuredOutput,
+	Use:         "list",
+	Aliases:     []string{"ls"},
+	Short:       "List Helm releases",
 	Long: `List Helm releases across namespaces with various output formats.
 Defaults to showing releases in the default namespace unless specified.`,
 	Args:         cobra.NoArgs,
@@ -52,13 +52,9 @@ Defaults to showing releases in the default namespace unless specified.`,
 func init() {
 	listCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "list across all namespaces")
 	listCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace scope for listing")
-	listCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "output format (table|json|yaml)")
 	listCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	// Register completion functions
-	listCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"table", "json", "yaml"}, cobra.ShellCompDirectiveDefault
-	})
 	_ = listCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
 
 	selmCmd.AddCommand(listCmd)
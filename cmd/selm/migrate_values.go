@@ -0,0 +1,78 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+// migrateValuesCmd previews what upgrade --migrate-values does to the stored
+// values of a release.
+var migrateValuesCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "migrate-values RELEASE CHART",
+	Short:       "Preview the migration of a release's values to a new chart version",
+	Long: `Apply the values migrations between the chart version of the deployed release
+and the version of CHART to the release's stored values, and print the
+migrations and the resulting values diff without upgrading.
+
+Migrations come from ` + helm.ValuesMigrationsFile + ` at the root of the chart and from
+--values-migrations. Each names the chart version that needs it and lists
+rules that rename a key, move a value to another path, keep an old default
+the chart changed, or delete a key:
+
+  migrations:
+    - version: 2.0.0
+      description: image.name is now image.repository
+      rules:
+        - rename: image.name
+          to: repository
+        - move: ingress.host
+          to: ingress.hosts.default
+        - default: service.port
+          value: 80
+        - delete: legacy.enabled
+
+A migration applies when the deployed version is below its version and
+CHART's is at or above it, oldest first. upgrade --migrate-values applies
+the same migrations and upgrades from the migrated values.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace := configs.Namespace
+		if namespace == "" {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err == nil {
+				namespace = data.Selm.Namespace
+			}
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		p, err := helm.PreviewValuesMigration(args[0], args[1], namespace, RepoURL, Version, configs.Debug, useAI)
+		if err != nil {
+			return err
+		}
+		return helm.PrintValuesMigrationPreview(p, outputFormat)
+	},
+	Example: `
+  # What migrating my-release to chart 2.1.0 changes in its values
+  smurf selm migrate-values my-release ./mychart -n apps
+
+  # With migrations of your own, as JSON
+  smurf selm migrate-values my-release myrepo/mychart --version 3.0.0 --values-migrations migrations.yaml -o json
+`,
+}
+
+func init() {
+	migrateValuesCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "namespace of the release")
+	migrateValuesCmd.Flags().StringVar(&configs.ValuesMigrations, "values-migrations", "", "Values migrations file applied after those of the chart")
+	migrateValuesCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
+	migrateValuesCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	migrateValuesCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	migrateValuesCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	migrateValuesCmd.ValidArgsFunction = completeReleaseNames
+	_ = migrateValuesCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(migrateValuesCmd)
+}
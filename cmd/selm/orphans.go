@@ -0,0 +1,117 @@
+package selm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	orphansRelease string
+	orphansDelete  bool
+	orphansAdopt   string
+	orphansYes     bool
+)
+
+// orphansCmd lists the resources of a namespace that Helm labeled for a
+// release which no longer exists, and optionally deletes them or hands them
+// to a release so its next install or upgrade takes them over.
+var orphansCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "orphans",
+	Short:       "Find resources left behind by Helm releases that no longer exist",
+	Long: `Scan a namespace for Kubernetes resources managed by Helm (labeled
+app.kubernetes.io/managed-by=Helm) whose release is no longer in release storage.
+Failed uninstalls and interrupted cleanups leave such resources behind, and they
+make the next install of the same chart fail with "resource already exists".
+
+Releases in any state (deployed, failed, pending) count as existing. Resources
+owned by another object, such as a Deployment's ReplicaSets, are not listed;
+they are removed with their owner.
+
+  --delete        delete the orphaned resources
+  --adopt NAME    point them at release NAME, so the next 'selm install' or
+                  'selm upgrade' of NAME in this namespace adopts them`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if orphansDelete && orphansAdopt != "" {
+			return errors.New("--delete and --adopt cannot be used together")
+		}
+
+		namespace := configs.Namespace
+		if namespace == "" {
+			if data, err := configs.LoadConfig(configs.FileName); err == nil {
+				namespace = data.Selm.Namespace
+			}
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+
+		orphans, err := helm.FindOrphans(namespace, orphansRelease, useAI)
+		if err != nil {
+			return err
+		}
+		if err := helm.PrintOrphans(orphans, outputFormat); err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+
+		switch {
+		case orphansDelete:
+			if err := confirmAction(fmt.Sprintf("Delete %d orphaned resource(s) from namespace %s?", len(orphans), namespace), orphansYes); err != nil {
+				return err
+			}
+			if err := helm.DeleteOrphans(orphans, useAI); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("Deleted %d orphaned resource(s)", len(orphans))
+		case orphansAdopt != "":
+			if err := confirmAction(fmt.Sprintf("Hand %d orphaned resource(s) to release %s?", len(orphans), orphansAdopt), orphansYes); err != nil {
+				return err
+			}
+			if err := helm.AdoptOrphans(orphans, orphansAdopt, useAI); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("Release %s will adopt %d resource(s) on its next install or upgrade in namespace %s", orphansAdopt, len(orphans), namespace)
+		case outputFormat == "table":
+			pterm.Info.Println("Run again with --delete to remove them, or --adopt RELEASE to hand them to a release.")
+		}
+		return nil
+	},
+	Example: `
+  # List orphaned release resources in a namespace
+  smurf selm orphans -n my-namespace
+
+  # Only the leftovers of one release, as JSON
+  smurf selm orphans -n my-namespace --release my-app -o json
+
+  # Delete them without prompting
+  smurf selm orphans -n my-namespace --release my-app --delete --yes
+
+  # Let the next install of my-app take them over
+  smurf selm orphans -n my-namespace --release my-app --adopt my-app
+`,
+}
+
+func init() {
+	orphansCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Namespace to scan (defaults to selm.namespace in smurf.yaml, then default)")
+	orphansCmd.Flags().StringVar(&orphansRelease, "release", "", "Only show resources of this release")
+	orphansCmd.Flags().BoolVar(&orphansDelete, "delete", false, "Delete the orphaned resources")
+	orphansCmd.Flags().StringVar(&orphansAdopt, "adopt", "", "Hand the orphaned resources to this release so its next install or upgrade adopts them")
+	orphansCmd.Flags().BoolVarP(&orphansYes, "yes", "y", false, "Do not ask for confirmation")
+	orphansCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = orphansCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	_ = orphansCmd.RegisterFlagCompletionFunc("release", completeReleaseNames)
+	_ = orphansCmd.RegisterFlagCompletionFunc("adopt", completeReleaseNames)
+
+	selmCmd.AddCommand(orphansCmd)
+}
@@ -0,0 +1,74 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outdatedOCI    []string
+	outdatedDevel  bool
+	outdatedAll    bool
+	outdatedUpdate bool
+)
+
+// outdatedCmd reports the deployed releases whose chart has a newer version
+// in the configured repositories.
+var outdatedCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "outdated",
+	Short:       "List deployed releases with a newer chart version available",
+	Long: `Compare the chart version of each deployed release with the newest version
+of that chart in the Helm repositories added with 'selm repo add' and in the
+OCI registries given with --oci, and list the upgrade candidates with a link
+to their release notes.
+
+Repositories are read from their local index, as of the last 'selm repo
+update'; pass --update to refresh them first. Pre-release versions are only
+offered with --devel, or when the installed version is a pre-release itself.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if allNamespaces {
+			namespace = ""
+		}
+
+		if outdatedUpdate {
+			if err := helm.Repo_Update(nil, "", useAI); err != nil {
+				return err
+			}
+		}
+
+		updates, err := helm.FindOutdated(namespace, outdatedOCI, outdatedDevel, useAI)
+		if err != nil {
+			return err
+		}
+		return helm.PrintOutdated(updates, outputFormat, outdatedAll)
+	},
+	Example: `
+  # Upgrade candidates in the default namespace
+  smurf selm outdated
+
+  # Across all namespaces, refreshing the repository indexes first
+  smurf selm outdated -A --update
+
+  # Also look up charts in an OCI registry
+  smurf selm outdated -n apps --oci oci://registry.example.com/charts
+
+  # Every release, up to date or not, as JSON
+  smurf selm outdated -A --all -o json
+`,
+}
+
+func init() {
+	outdatedCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "check releases across all namespaces")
+	outdatedCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the releases to check")
+	outdatedCmd.Flags().StringSliceVar(&outdatedOCI, "oci", nil, "OCI registry path holding charts (oci://host/path), repeatable")
+	outdatedCmd.Flags().BoolVar(&outdatedDevel, "devel", false, "consider pre-release chart versions")
+	outdatedCmd.Flags().BoolVar(&outdatedAll, "all", false, "also list releases that are up to date or whose chart was not found")
+	outdatedCmd.Flags().BoolVar(&outdatedUpdate, "update", false, "update the repository indexes before checking")
+	outdatedCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = outdatedCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(outdatedCmd)
+}
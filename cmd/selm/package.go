@@ -0,0 +1,83 @@
+package selm
+
+import (
+	"errors"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packageOpts helm.PackageOptions
+	packagePush string
+)
+
+// packageCmd archives a chart directory, optionally signing it and pushing
+// it to an OCI registry.
+var packageCmd = &cobra.Command{
+	Use:   "package [CHART_DIR]",
+	Short: "Package a chart directory into a versioned chart archive",
+	Long: `Package a chart directory into NAME-VERSION.tgz, like helm package.
+
+--version and --app-version override the chart's version and appVersion,
+e.g. with the version of the release being built. --sign writes a
+provenance file that install, upgrade and pull check with --verify.
+--push uploads the archive to an OCI registry afterwards, as selm push does.
+Without CHART_DIR, selm.chartName of smurf.yaml is packaged.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var chartDir string
+		if len(args) == 1 {
+			chartDir = args[0]
+		} else {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+			chartDir = data.Selm.ChartName
+			if chartDir == "" {
+				pterm.Error.Println("CHART_DIR must be provided either as an argument or in the config")
+				return errors.New("CHART_DIR must be provided either as an argument or in the config")
+			}
+		}
+
+		path, err := helm.Package(chartDir, packageOpts, configs.Debug, useAI)
+		if err != nil {
+			return err
+		}
+		if packagePush != "" {
+			_, err = helm.Push(path, packagePush, pushOpts, configs.Debug, useAI)
+		}
+		return err
+	},
+	Example: `
+  # Package ./charts/web as web-<version>.tgz in the current directory
+  smurf selm package ./charts/web
+
+  # Stamp the build's version and push the archive
+  smurf selm package ./charts/web --version 1.4.0 --app-version "$GIT_SHA" --push oci://ghcr.io/acme/charts
+
+  # Sign the archive with a key of the keyring
+  smurf selm package ./charts/web --sign --key "Release Bot" --keyring ~/.gnupg/secring.gpg --passphrase-file -
+`,
+}
+
+func init() {
+	packageCmd.Flags().StringVar(&packageOpts.Version, "version", "", "Set the version of the chart to this semver version")
+	packageCmd.Flags().StringVar(&packageOpts.AppVersion, "app-version", "", "Set the appVersion of the chart to this version")
+	packageCmd.Flags().StringVarP(&packageOpts.Destination, "destination", "d", ".", "Location to write the chart archive")
+	packageCmd.Flags().BoolVarP(&packageOpts.DependencyUpdate, "dependency-update", "u", false, `Update the dependencies of Chart.yaml into "charts/" before packaging`)
+	packageCmd.Flags().BoolVar(&packageOpts.Sign, "sign", false, "Sign the archive with a PGP key, writing a provenance file next to it")
+	packageCmd.Flags().StringVar(&packageOpts.Key, "key", "", "Name of the key to sign with")
+	packageCmd.Flags().StringVar(&packageOpts.Keyring, "keyring", defaultKeyring(), "Location of the keyring with the signing key")
+	packageCmd.Flags().Lookup("keyring").DefValue = "~/.gnupg/pubring.gpg"
+	packageCmd.Flags().StringVar(&packageOpts.PassphraseFile, "passphrase-file", "", `File with the passphrase of the signing key, or "-" for stdin`)
+	addPushFlags(packageCmd)
+	packageCmd.Flags().StringVar(&packagePush, "push", "", "Push the archive to this OCI registry path (oci://HOST/PATH) after packaging")
+	packageCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	packageCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	selmCmd.AddCommand(packageCmd)
+}
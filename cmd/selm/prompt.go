@@ -0,0 +1,30 @@
+package selm
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+// confirmAction asks the user to confirm a change to the cluster, in the same
+// way sdkr's confirmPush does: it returns nil right away when skip is set
+// (--yes) or stdin is not a TTY, so CI runs never hang, and an error when the
+// user answers anything other than "y"/"Y".
+func confirmAction(question string, skip bool) error {
+	if skip || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+
+	pterm.Info.Printf("%s [y/N]: ", question)
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response != "y" && response != "Y" {
+		return errors.New("aborted by user")
+	}
+	return nil
+}
@@ -10,13 +10,23 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var provisionParallelValidate bool
+
 // provisionCmd is a subcommand that orchestrates a more comprehensive Helm workflow
 // by combining multiple steps like install, upgrade, lint, and template generation.
 // It supports configurable arguments or fallback to values specified in the config file,
 // as well as an optional custom namespace.
 var provisionCmd = &cobra.Command{
-	Use:          "provision [RELEASE] [CHART]",
-	Short:        "Combination of install, upgrade, lint, and template for Helm",
+	Use:   "provision [RELEASE] [CHART]",
+	Short: "Combination of install, upgrade, lint, and template for Helm",
+	Long: `Lint the chart, render its templates, and install the release, or upgrade it
+when it exists. The stages run in that order and a failed stage stops the
+ones after it, so a chart with lint errors or templates that don't render is
+never deployed. Lint warnings are reported without failing. A table of the
+result and duration of every stage is printed at the end.
+
+  --parallel-validate   lint and render at the same time; their results are
+                        printed in stage order once both are done`,
 	Args:         cobra.MaximumNArgs(2),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -65,22 +75,36 @@ var provisionCmd = &cobra.Command{
 			configs.Namespace = "default"
 		}
 
-		err := helm.HelmProvision(releaseName, chartPath, configs.Namespace, useAI)
-		if err != nil {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if err := applyInterpolation(); err != nil {
 			return err
 		}
-		return nil
+		if err := applyValuesSchema(cmd); err != nil {
+			return err
+		}
+
+		_, err := helm.HelmProvision(helm.ProvisionOptions{
+			ReleaseName:      releaseName,
+			ChartPath:        chartPath,
+			Namespace:        configs.Namespace,
+			ParallelValidate: provisionParallelValidate,
+		}, useAI)
+		return err
 	},
 	Example: `
 smurf selm provision my-release ./mychart
 smurf selm provision
 # In this example, it will read RELEASE and CHART from the config file
 smurf selm provision my-release ./mychart -n custom-namespace
+smurf selm provision my-release ./mychart --parallel-validate
 `,
 }
 
 func init() {
 	provisionCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Specify the namespace to provision the Helm chart")
+	provisionCmd.Flags().BoolVar(&provisionParallelValidate, "parallel-validate", false, "Run the lint and template stages concurrently before installing")
 	provisionCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	_ = provisionCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
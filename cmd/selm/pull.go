@@ -74,6 +74,9 @@ func init() {
 	pullCmd.Flags().StringVar(&configs.HelmConfigDir, "helm-config", "", "Helm configuration directory")
 	pullCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
+	pullCmd.ValidArgsFunction = completeChartRepoPrefixes
+	_ = pullCmd.RegisterFlagCompletionFunc("version", cobra.NoFileCompletions)
+
 	// Add to selm command
 	selmCmd.AddCommand(pullCmd)
 }
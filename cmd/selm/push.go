@@ -0,0 +1,50 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var pushOpts helm.PushOptions
+
+// pushCmd uploads a chart archive to an OCI registry.
+var pushCmd = &cobra.Command{
+	Use:   "push CHART.tgz oci://HOST/PATH",
+	Short: "Push a chart archive to an OCI registry",
+	Long: `Push a chart archive to an OCI registry, like helm push. The chart is
+stored as oci://HOST/PATH/NAME:VERSION, with its provenance file when
+CHART.tgz.prov exists.
+
+The registry login is resolved as for image pushes (ECR, Artifact Registry,
+GHCR and Docker Hub credentials from the environment, then the docker
+config) or taken from selm registry login. With --cert-file, --key-file,
+--ca-file or --insecure-skip-tls-verify only selm registry login logins
+apply.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := helm.Push(args[0], args[1], pushOpts, configs.Debug, useAI)
+		return err
+	},
+	Example: `
+  smurf selm push web-1.4.0.tgz oci://ghcr.io/acme/charts
+  smurf selm push web-1.4.0.tgz oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/charts
+`,
+}
+
+// addPushFlags registers the TLS flags of a chart push on c.
+func addPushFlags(c *cobra.Command) {
+	c.Flags().StringVar(&pushOpts.CertFile, "cert-file", "", "Identify the registry client using this SSL certificate file")
+	c.Flags().StringVar(&pushOpts.KeyFile, "key-file", "", "Identify the registry client using this SSL key file")
+	c.Flags().StringVar(&pushOpts.CAFile, "ca-file", "", "Verify certificates of HTTPS-enabled registries using this CA bundle")
+	c.Flags().BoolVar(&pushOpts.Insecure, "insecure-skip-tls-verify", false, "Skip tls certificate checks for the chart upload")
+	c.Flags().BoolVar(&pushOpts.PlainHTTP, "plain-http", false, "Use HTTP instead of HTTPS for the chart upload")
+}
+
+func init() {
+	addPushFlags(pushCmd)
+	pushCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	pushCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	selmCmd.AddCommand(pushCmd)
+}
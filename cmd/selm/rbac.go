@@ -0,0 +1,70 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var rbacReleaseName string
+
+// rbacCmd reports the permissions a chart asks for, for a security review
+// before the chart is approved.
+var rbacCmd = &cobra.Command{
+	Annotations: structuredOutput,
+	Use:         "rbac CHART",
+	Short:       "Report the ServiceAccounts, Roles and bindings of a chart as a permission matrix",
+	Long: `Render CHART with the given values, without a cluster, hooks included, and
+extract its ServiceAccounts, Roles, ClusterRoles, RoleBindings and
+ClusterRoleBindings into one permissions report:
+
+  - the service accounts the chart creates
+  - each binding with its role and subjects
+  - a matrix of every resource the roles grant access to by verb (get, list,
+    watch, create, update, patch, delete, deletecollection, other verbs),
+    scoped to the cluster or the namespace, with the roles that grant it
+  - findings for a reviewer: wildcard verbs or resources, reading Secrets,
+    escalate, bind and impersonate, exec into pods, cluster-admin bindings,
+    and bindings to roles the chart doesn't define
+
+-o json or yaml prints the report, rules included, as one document.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+		return helm.RBACReportFor(rbacReleaseName, args[0], helm.RBACOptions{
+			Namespace:        configs.Namespace,
+			RepoURL:          RepoURL,
+			Version:          Version,
+			ValuesFiles:      configs.File,
+			SetValues:        configs.Set,
+			SetLiteralValues: configs.SetLiteral,
+			Debug:            configs.Debug,
+		}, outputFormat, useAI)
+	},
+	Example: `
+  # The permissions a local chart asks for
+  smurf selm rbac ./mychart
+
+  # A published chart with production values, as JSON for the review ticket
+  smurf selm rbac ingress-nginx --repo-url https://kubernetes.github.io/ingress-nginx --version 4.11.0 -f values-prod.yaml -o json
+`,
+}
+
+func init() {
+	rbacCmd.Flags().StringVar(&rbacReleaseName, "release-name", "release-name", "Release name to render the chart with")
+	rbacCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Namespace to render the chart for")
+	rbacCmd.Flags().StringSliceVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
+	rbacCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	rbacCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line (values are always treated as strings)")
+	rbacCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
+	rbacCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	rbacCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
+	rbacCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	rbacCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = rbacCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	selmCmd.AddCommand(rbacCmd)
+}
@@ -0,0 +1,100 @@
+package selm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	registryLogin         helm.RegistryLoginOptions
+	registryPasswordStdin bool
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Log in to or out of OCI registries that hold charts",
+	Long: `Log in to or out of OCI registries, for charts referenced as
+oci://HOST/PATH/CHART by install, upgrade, template and pull.
+
+Logins are saved in Helm's registry config, where the helm CLI finds them
+too. ECR, Artifact Registry and GHCR logins are also resolved the way smurf
+pushes images (AWS and GCP credentials, GITHUB_TOKEN), without a login.`,
+	SilenceUsage: true,
+}
+
+var registryLoginCmd = &cobra.Command{
+	Use:          "login HOST",
+	Short:        "Log in to an OCI registry",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if registryPasswordStdin {
+			if registryLogin.Password != "" {
+				return errors.New("--password and --password-stdin are mutually exclusive")
+			}
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			registryLogin.Password = strings.TrimRight(string(data), "\r\n")
+		}
+		if registryLogin.Username == "" || registryLogin.Password == "" {
+			return errors.New("--username and --password (or --password-stdin) are required")
+		}
+		if err := helm.RegistryLogin(args[0], registryLogin); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Logged in to %s", args[0])
+		return nil
+	},
+	Example: `
+  # Log in to a registry, reading the password from stdin
+  echo "$TOKEN" | smurf selm registry login ghcr.io -u my-user --password-stdin
+
+  # Then install a chart from it
+  smurf selm install my-release oci://ghcr.io/my-org/charts/my-chart --version 1.2.0
+`,
+}
+
+var registryLogoutCmd = &cobra.Command{
+	Use:          "logout HOST",
+	Short:        "Log out of an OCI registry",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := helm.RegistryLogout(args[0]); err != nil {
+			return err
+		}
+		pterm.Success.Printfln("Logged out of %s", args[0])
+		return nil
+	},
+}
+
+// addVerifyFlags registers the flags that make a command verify the
+// provenance of the chart it pulls.
+func addVerifyFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&configs.Verify, "verify", false, "Verify the chart against its provenance file before using it")
+	c.Flags().StringVar(&configs.Keyring, "keyring", defaultKeyring(), "Location of public keys used for verification")
+	c.Flags().Lookup("keyring").DefValue = "~/.gnupg/pubring.gpg"
+}
+
+func init() {
+	registryLoginCmd.Flags().StringVarP(&registryLogin.Username, "username", "u", "", "Registry username")
+	registryLoginCmd.Flags().StringVarP(&registryLogin.Password, "password", "p", "", "Registry password or identity token")
+	registryLoginCmd.Flags().BoolVar(&registryPasswordStdin, "password-stdin", false, "Read the password or identity token from stdin")
+	registryLoginCmd.Flags().StringVar(&registryLogin.CertFile, "cert-file", "", "Identify the registry client using this SSL certificate file")
+	registryLoginCmd.Flags().StringVar(&registryLogin.KeyFile, "key-file", "", "Identify the registry client using this SSL key file")
+	registryLoginCmd.Flags().StringVar(&registryLogin.CAFile, "ca-file", "", "Verify certificates of HTTPS-enabled registries using this CA bundle")
+	registryLoginCmd.Flags().BoolVar(&registryLogin.Insecure, "insecure", false, "Allow connections to TLS registries without certificate checks")
+	registryLoginCmd.Flags().BoolVar(&registryLogin.PlainHTTP, "plain-http", false, "Use HTTP instead of HTTPS for the registry")
+
+	registryCmd.AddCommand(registryLoginCmd, registryLogoutCmd)
+	selmCmd.AddCommand(registryCmd)
+}
@@ -6,11 +6,21 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var repoAddAuth helm.RepoAuth
+
 var repoAddCmd = &cobra.Command{
 	Use:   "add [NAME] [URL]",
 	Short: "Add a chart repository",
 	Long: `Add a chart repository to your local repository list.
-The repository can be accessed by its name in other commands.`,
+The repository can be accessed by its name in other commands.
+
+Besides a username and password or a client certificate, a repository behind
+token auth can send a bearer token: --token, --token-env (read on each use) or
+--token-command (rerun whenever the repository rejects the token, so SSO
+tokens are refreshed). --credential-helper resolves the login for the
+repository's host like smurf's registry pushes do, from the docker config and
+its credential helpers. These are saved in repository-auth.yaml next to
+repositories.yaml and apply to repo update, install and upgrade.`,
 	Args:         cobra.ExactArgs(2),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -21,7 +31,7 @@ The repository can be accessed by its name in other commands.`,
 		keyFile = configs.KeyFile
 		caFile = configs.CaFile
 		helmConfigDir = configs.HelmConfigDir
-		return helm.Repo_Add(args, username, password, certFile, keyFile, caFile, helmConfigDir, useAI)
+		return helm.Repo_Add(args, username, password, certFile, keyFile, caFile, helmConfigDir, repoAddAuth, useAI)
 	},
 	Example: `
   # Add a chart repository
@@ -30,6 +40,12 @@ The repository can be accessed by its name in other commands.`,
   # Add a private chart repository with auth
   smurf selm repo add myrepo https://charts.example.com --username myuser --password mypass
   
+  # Add a repository behind SSO, fetching a fresh token whenever it expires
+  smurf selm repo add internal https://charts.internal.example.com --token-command "vault read -field=token auth/charts/token"
+
+  # Add a repository using the docker login / credential helper for its host
+  smurf selm repo add myrepo https://charts.example.com --credential-helper --cert-file client.crt --key-file client.key
+
   # Add a repository with custom Helm config directory
   smurf selm repo add myrepo https://charts.example.com --helm-config /custom/path`,
 }
@@ -41,6 +57,10 @@ func init() {
 	repoAddCmd.Flags().StringVar(&configs.CertFile, "cert-file", "", "Identify HTTPS client using this SSL certificate file")
 	repoAddCmd.Flags().StringVar(&configs.KeyFile, "key-file", "", "Identify HTTPS client using this SSL key file")
 	repoAddCmd.Flags().StringVar(&configs.CaFile, "ca-file", "", "Verify certificates of HTTPS-enabled servers using this CA bundle")
+	repoAddCmd.Flags().StringVar(&repoAddAuth.Token, "token", "", "Bearer token to send to the chart repository")
+	repoAddCmd.Flags().StringVar(&repoAddAuth.TokenEnv, "token-env", "", "Environment variable to read the bearer token from on each use")
+	repoAddCmd.Flags().StringVar(&repoAddAuth.TokenCommand, "token-command", "", "Shell command that prints a bearer token; rerun when the repository rejects the token")
+	repoAddCmd.Flags().BoolVar(&repoAddAuth.CredentialHelper, "credential-helper", false, "Resolve the repository login from the docker config and its credential helpers, like registry pushes")
 	repoAddCmd.Flags().StringVar(&configs.HelmConfigDir, "helm-config", "", "Helm configuration directory (default: $HELM_HOME or ~/.config/helm)")
 	repoAddCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
@@ -12,19 +12,34 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	rollbackDryRun bool
+	rollbackYes    bool
+)
+
 // rollbackCmd facilitates rolling back a Helm release to a specified previous revision.
 // It either takes both RELEASE and REVISION as command-line arguments or reads them
 // from a config file if none are provided. The user can also configure namespace, timeout,
 // debug, and force options. If the release or revision is invalid, an error is returned.
+// Before rolling back it shows what the rollback changes and asks for confirmation;
+// --dry-run stops after the preview.
 var rollbackCmd = &cobra.Command{
 	Use:   "rollback [RELEASE] [REVISION]",
 	Short: "Roll back a release to a previous revision",
 	Long: `Roll back a release to a previous revision.
-The first argument is the name of the release to roll back, and the second is the revision number to roll back to.`,
+The first argument is the name of the release to roll back, and the second is the revision number to roll back to.
+
+Before rolling back, the differences between the deployed revision and the
+target revision are shown: the chart version, the user-supplied values and a
+diff for every resource that changes, is added or is removed. The rollback then
+asks for confirmation unless --yes is set or stdin is not a terminal. With
+--dry-run, only the preview is shown and nothing is changed.`,
 	Example: `
       smurf selm rollback nginx 2
       smurf selm rollback nginx 2 --namespace mynamespace --debug
       smurf selm rollback nginx 2 --force --timeout 600
+      smurf selm rollback nginx 2 --dry-run
+      smurf selm rollback nginx 2 --yes
       smurf selm rollback
 	  smurf selm rollback --history-max 5
       # In this example, it will read RELEASE and REVISION from the config file
@@ -51,6 +66,10 @@ The first argument is the name of the release to roll back, and the second is th
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+
 		var releaseName string
 		var revision int
 
@@ -88,6 +107,19 @@ The first argument is the name of the release to roll back, and the second is th
 			configs.Namespace = "default"
 		}
 
+		preview, err := helm.PreviewRollback(releaseName, revision, configs.Namespace, useAI)
+		if err != nil {
+			return err
+		}
+		helm.PrintRollbackPreview(preview)
+		if rollbackDryRun {
+			pterm.Info.Println("Dry run: release not rolled back.")
+			return nil
+		}
+		if err := confirmAction(fmt.Sprintf("Roll back release %s to revision %d?", releaseName, revision), rollbackYes); err != nil {
+			return err
+		}
+
 		rollbackOpts := helm.RollbackOptions{
 			Namespace: configs.Namespace,
 			Debug:     configs.Debug,
@@ -96,8 +128,7 @@ The first argument is the name of the release to roll back, and the second is th
 			Wait:      configs.Wait,
 		}
 
-		err := helm.HelmRollback(releaseName, revision, rollbackOpts, historyMax, useAI)
-		if err != nil {
+		if err := helm.HelmRollback(releaseName, revision, rollbackOpts, historyMax, useAI); err != nil {
 			return err
 		}
 		pterm.Success.Printfln("Successfully rolled back release '%v' to revision '%v'", releaseName, revision)
@@ -109,9 +140,11 @@ func init() {
 	rollbackCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "default", "Namespace of the release")
 	rollbackCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable debug logging")
 	rollbackCmd.Flags().BoolVar(&configs.Force, "force", false, "Force rollback even if there are conflicts")
-	rollbackCmd.Flags().IntVar(&configs.Timeout, "timeout", 300, "Timeout for the rollback operation in seconds")
+	rollbackCmd.Flags().IntVar(&configs.Timeout, "timeout", configs.DefaultTimeouts.HelmWait, "Timeout for the rollback operation in seconds (overrides timeouts.helmWait in smurf.yaml)")
 	rollbackCmd.Flags().BoolVar(&configs.Wait, "wait", true, "Wait until all resources are rolled back successfully")
 	rollbackCmd.Flags().IntVar(&historyMax, "history-max", 10, "Limit the maximum number of revisions saved per release")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "Show what the rollback would change without rolling back")
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Roll back without confirmation")
 	rollbackCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	rollbackCmd.ValidArgsFunction = completeReleaseNames
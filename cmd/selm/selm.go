@@ -1,16 +1,65 @@
 package selm
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
+// outputFormat is selm's --output flag, shared by every command.
+var outputFormat string
+
+// structuredOutputAnnotation marks the commands that can print --output json
+// or yaml; the others only print tables and reject it.
+const structuredOutputAnnotation = "smurf.structured-output"
+
+var structuredOutput = map[string]string{structuredOutputAnnotation: "true"}
+
 // selmCmd represents the 'selm' subcommand command
 var selmCmd = &cobra.Command{
 	Use:   "selm",
 	Short: "Subcommand for Helm-related actions",
 	Long:  `selm is a subcommand that groups various Helm-related actions under a single command.`,
+	// Values are printed by several selm commands, so the value keys
+	// smurf.yaml masks in them are loaded once here, as are the scheduling
+	// overlay and list merge strategies of every command that renders a
+	// chart, and every command targets the cluster of --kubeconfig and
+	// --kube-context.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(outputFormat, helm.OutputFormats...) {
+			return fmt.Errorf("invalid output format %q: must be one of %s", outputFormat, strings.Join(helm.OutputFormats, ", "))
+		}
+		if outputFormat != helm.OutputTable && cmd.Annotations[structuredOutputAnnotation] == "" {
+			return fmt.Errorf("%s does not support --output %s", cmd.CommandPath(), outputFormat)
+		}
+		if outputFormat != helm.OutputTable {
+			// Keep stdout for the document alone.
+			pterm.SetDefaultOutput(os.Stderr)
+		}
+		helm.SetKubeTarget(configs.KubeConfig, configs.KubeContext)
+		keys, err := configs.LoadSelmRedactKeys(configs.FileName)
+		if err != nil {
+			return err
+		}
+		configs.RedactKeys = keys
+		configs.Scheduling, err = configs.LoadSelmScheduling(configs.FileName)
+		if err != nil {
+			return err
+		}
+		configs.ReleaseBackup, err = configs.LoadSelmBackup(configs.FileName)
+		if err != nil {
+			return err
+		}
+		configs.ListMergePaths, err = configs.LoadSelmListMerge(configs.FileName)
+		return err
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		pterm.FgBlue.Printfln("Use 'smurf selm [command]' to run Helm-related actions")
 	},
@@ -18,5 +67,39 @@ var selmCmd = &cobra.Command{
 }
 
 func init() {
+	selmCmd.PersistentFlags().StringVar(&configs.KubeConfig, "kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	selmCmd.PersistentFlags().StringVar(&configs.KubeContext, "kube-context", "", "Name of the kubeconfig context to use (default: $KUBECONTEXT or the current context)")
+	selmCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", helm.OutputTable, "Output format (table|json|yaml); json and yaml print one document on stdout for CI")
+	_ = selmCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return helm.OutputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	selmCmd.PersistentFlags().BoolVar(&configs.ShowSecrets, "show-secrets", false, "Print the values of keys matching selm.redactKeys instead of masking them, for local debugging")
 	cmd.RootCmd.AddCommand(selmCmd)
 }
+
+// applyTimeoutPolicy loads the timeouts section of smurf.yaml (if any) into
+// configs.Timeouts and, unless --timeout was given explicitly, uses its
+// helmWait phase as the command's Helm wait timeout.
+func applyTimeoutPolicy(c *cobra.Command) error {
+	policy, err := configs.LoadTimeoutPolicy(configs.FileName)
+	if err != nil {
+		return err
+	}
+	configs.Timeouts = policy
+
+	if f := c.Flags().Lookup("timeout"); f != nil && !f.Changed {
+		configs.Timeout = policy.HelmWait
+	}
+	return nil
+}
+
+// applyInterpolation turns on ${VAR} and {{ .Git.* }} interpolation of values
+// files and --set strings when selm.interpolate is set in smurf.yaml.
+func applyInterpolation() error {
+	enabled, err := configs.LoadSelmInterpolate(configs.FileName)
+	if err != nil {
+		return err
+	}
+	configs.Interpolate = enabled
+	return nil
+}
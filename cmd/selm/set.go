@@ -0,0 +1,75 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	setStringValues []string
+	setWait         bool
+	setHistoryMax   int
+)
+
+// setCmd upgrades a release with a few of its values changed, reusing the
+// chart and values already stored in the cluster.
+var setCmd = &cobra.Command{
+	Use:   "set RELEASE KEY=VALUE [KEY=VALUE...]",
+	Short: "Patch values of a deployed Helm release without its chart or values files.",
+	Long: `Set upgrades a release in place with only the given keys changed. The chart
+and the user-supplied values of the release's current revision are taken from
+the cluster, so no chart path or values files are needed locally.
+
+Values use --set syntax (a.b=c, list[0]=x, several keys separated by commas);
+use --set-string to force string values.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+
+		if configs.Namespace == "" {
+			configs.Namespace = "default"
+		}
+
+		return helm.HelmSetValues(args[0], args[1:], setStringValues, helm.SetValuesOptions{
+			Namespace:  configs.Namespace,
+			Timeout:    configs.Timeout,
+			Wait:       setWait,
+			Atomic:     configs.Atomic,
+			Debug:      configs.Debug,
+			HistoryMax: setHistoryMax,
+		}, useAI)
+	},
+	Example: `
+  # Scale a release to 5 replicas
+  smurf selm set my-release replicaCount=5 -n prod
+
+  # Bump the image tag and wait for the rollout
+  smurf selm set my-release image.tag=v1.4.2 --wait
+
+  # Force a string value
+  smurf selm set my-release --set-string podAnnotations.build=0012
+`,
+}
+
+func init() {
+	setCmd.Flags().StringArrayVar(&setStringValues, "set-string", []string{}, "Set a STRING value (KEY=VALUE, can be repeated)")
+	setCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "default", "Namespace of the release")
+	setCmd.Flags().IntVar(&configs.Timeout, "timeout", configs.DefaultTimeouts.HelmWait, "Time to wait for any individual Kubernetes operation (overrides timeouts.helmWait in smurf.yaml)")
+	setCmd.Flags().BoolVar(&setWait, "wait", false, "Wait until all resources are ready before marking the upgrade successful")
+	setCmd.Flags().BoolVar(&configs.Atomic, "atomic", false, "Roll back the change if the upgrade fails")
+	setCmd.Flags().IntVar(&setHistoryMax, "history-max", 10, "Limit the maximum number of revisions saved per release")
+	setCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
+	setCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	setCmd.ValidArgsFunction = completeReleaseNames
+	_ = setCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+
+	selmCmd.AddCommand(setCmd)
+}
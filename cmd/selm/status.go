@@ -2,12 +2,11 @@ package selm
 
 import (
 	"errors"
-	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/helm"
-	"github.com/clouddrove/smurf/internal/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -16,14 +15,23 @@ import (
 // It accepts an optional release name as a command argument or falls back to
 // values in the config file. If neither is provided, it returns an error.
 // Additionally, a custom namespace can be specified via a flag.
+var (
+	statusWatch        bool
+	statusWatchTimeout int
+)
+
 var statusCmd = &cobra.Command{
+	Annotations:  structuredOutput,
 	Use:          "status [NAME]",
 	Short:        "Status of a Helm release.",
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if !utils.ValidOutputFormat(outputFormat, "table", "json", "yaml") {
-			return fmt.Errorf("invalid output format %q: must be one of table, json, yaml", outputFormat)
+		if statusWatch && outputFormat != "table" {
+			return errors.New("--watch only works with the table output")
+		}
+		if !statusWatch && cmd.Flags().Changed("watch-timeout") {
+			return errors.New("--watch-timeout requires --watch")
 		}
 
 		var releaseName string
@@ -57,6 +65,10 @@ var statusCmd = &cobra.Command{
 			configs.Namespace = "default"
 		}
 
+		if statusWatch {
+			return helm.WatchStatus(releaseName, configs.Namespace, time.Duration(statusWatchTimeout)*time.Second, useAI)
+		}
+
 		err := helm.HelmStatus(releaseName, configs.Namespace, outputFormat, useAI)
 		if err != nil {
 			return err
@@ -75,19 +87,19 @@ var statusCmd = &cobra.Command{
 
 	smurf selm status my-release -o json
 	# In this example, it will print the status as a JSON document to stdout
+
+	smurf selm status my-release --watch --watch-timeout 600
+	# In this example, it will keep the status updating until all resources are ready, for at most 10 minutes
 	`,
 }
 
 func init() {
 	statusCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Specify the namespace to get status of the Helm chart")
-	statusCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "output format (table|json|yaml)")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Keep the status updating until the release and all its workloads are ready, then print a summary")
+	statusCmd.Flags().IntVar(&statusWatchTimeout, "watch-timeout", 0, "Time in seconds to keep watching before giving up (0 watches until interrupted)")
 	statusCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	statusCmd.ValidArgsFunction = completeReleaseNames
 	_ = statusCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
-	_ = statusCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"table", "json", "yaml"}, cobra.ShellCompDirectiveDefault
-	})
-
 	selmCmd.AddCommand(statusCmd)
 }
@@ -19,6 +19,10 @@ var templateCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(2),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+
 		var releaseName, chartPath string
 
 		if len(args) >= 1 {
@@ -58,7 +62,7 @@ var templateCmd = &cobra.Command{
 			configs.Namespace = "default"
 		}
 
-		err := helm.HelmTemplate(releaseName, chartPath, configs.Namespace, repoURL, configs.File, useAI)
+		err := helm.HelmTemplate(releaseName, chartPath, configs.Namespace, repoURL, Version, configs.File, useAI)
 		if err != nil {
 			return err
 		}
@@ -73,6 +77,9 @@ smurf selm template my-release mychart --repo https://charts.clouddrove.com/
 
 smurf selm template my-release ./mychart -n my-namespace -f values.yaml
 # In this example, it will render templates for 'my-release' in './mychart' within 'my-namespace' using specified values files
+
+smurf selm template my-release oci://ghcr.io/my-org/charts/mychart --version 1.2.0 --verify
+# This will pull the chart from an OCI registry, verify its provenance and render it.
 `,
 }
 
@@ -80,6 +87,9 @@ func init() {
 	templateCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "", "Specify the namespace to template the Helm chart")
 	templateCmd.Flags().StringArrayVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file")
 	templateCmd.Flags().StringVarP(&repoURL, "repo", "r", "", "Specify Helm chart repository URL")
+	templateCmd.Flags().StringVar(&Version, "version", "", "Specify the chart version to render")
+	addVerifyFlags(templateCmd)
+	templateCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
 	templateCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
 	_ = templateCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
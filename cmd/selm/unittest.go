@@ -0,0 +1,69 @@
+package selm
+
+import (
+	"errors"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var unitTestOpts helm.UnitTestOptions
+
+// unittestCmd runs helm-unittest compatible test suites against a chart's
+// rendered templates.
+var unittestCmd = &cobra.Command{
+	Use:   "unittest [CHART]",
+	Short: "Run helm-unittest compatible unit tests for a Helm chart.",
+	Long: `Unittest renders a chart locally and checks the rendered templates against the
+test suites in the chart's tests/ directory (tests/*_test.yaml by default).
+Suites use the helm-unittest format: a suite lists templates, values and set
+overrides, and each test ("it") holds assertions such as equal, notEqual,
+isKind, hasDocuments, contains, matchRegex, exists, isNull, isEmpty,
+isSubset, lengthEqual and failedTemplate.
+
+No cluster is needed. Use --junit to write a JUnit XML report for CI test
+reporting.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var chartPath string
+
+		if len(args) == 1 {
+			chartPath = args[0]
+		} else {
+			data, err := configs.LoadConfig(configs.FileName)
+			if err != nil {
+				return err
+			}
+
+			chartPath = data.Selm.ChartName
+			if chartPath == "" {
+				pterm.Error.Println("CHART must be provided either as an argument or in the config")
+				return errors.New("CHART must be provided either as an argument or in the config")
+			}
+		}
+
+		return helm.HelmUnitTest(chartPath, unitTestOpts, useAI)
+	},
+	Example: `
+  # Run tests/*_test.yaml of a chart
+  smurf selm unittest ./mychart
+
+  # Write a JUnit report for CI
+  smurf selm unittest ./mychart --junit reports/helm-unittest.xml
+
+  # Run selected suites with extra values
+  smurf selm unittest ./mychart --file 'tests/deployment_*_test.yaml' -f ci-values.yaml
+`,
+}
+
+func init() {
+	unittestCmd.Flags().StringArrayVar(&unitTestOpts.TestFiles, "file", []string{}, "Glob of test suite files relative to the chart (repeatable, default tests/*_test.yaml)")
+	unittestCmd.Flags().StringArrayVarP(&unitTestOpts.Values, "values", "f", []string{}, "Values file applied to every test (repeatable)")
+	unittestCmd.Flags().StringVar(&unitTestOpts.JUnitFile, "junit", "", "Write a JUnit XML report to this file")
+	unittestCmd.Flags().BoolVar(&unitTestOpts.FailFast, "fail-fast", false, "Stop after the first failing suite")
+	unittestCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	selmCmd.AddCommand(unittestCmd)
+}
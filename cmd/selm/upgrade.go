@@ -19,6 +19,7 @@ var (
 	historyMax          int
 	useAI               bool
 	forceUpgrade        bool
+	upgradeDiff         bool
 )
 
 // upgradeCmd facilitates upgrading an existing Helm release or installing it if it's not present
@@ -28,6 +29,23 @@ var upgradeCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(2),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTimeoutPolicy(cmd); err != nil {
+			return err
+		}
+		if err := applyInterpolation(); err != nil {
+			return err
+		}
+		if err := applyValuesSchema(cmd); err != nil {
+			return err
+		}
+		if err := helm.ValidateHookEvents(configs.HooksOnly); err != nil {
+			return err
+		}
+		healthChecks, err := healthChecksFromFlags()
+		if err != nil {
+			return err
+		}
+
 		if configs.Debug {
 			pterm.EnableDebugMessages()
 			pterm.Println("=== DEBUG MODE ENABLED ===")
@@ -111,12 +129,27 @@ var upgradeCmd = &cobra.Command{
 			pterm.Printf("  - History Max: %d\n", historyMax)
 		}
 
+		if clientOnly {
+			return runClientOnly(releaseName, chartPath, true)
+		}
+
 		// Check if release exists
 		exists, err := helm.HelmReleaseExists(releaseName, configs.Namespace, configs.Debug, useAI)
 		if err != nil {
 			return err
 		}
 
+		if upgradeDiff && (exists || installIfNotPresent) {
+			d, err := helm.DiffRelease(releaseName, chartPath, configs.Namespace, configs.File, configs.Set, configs.SetLiteral,
+				RepoURL, Version, configs.Debug, useAI)
+			if err != nil {
+				return err
+			}
+			if err := helm.PrintReleaseDiff(d, "table"); err != nil {
+				return err
+			}
+		}
+
 		if !exists {
 			if installIfNotPresent {
 				if configs.Debug {
@@ -128,7 +161,7 @@ var upgradeCmd = &cobra.Command{
 				if configs.Debug {
 					pterm.Println("Installation completed successfully")
 				}
-				return nil
+				return runHealthGate(cmd, releaseName, healthChecks)
 			} else {
 				return fmt.Errorf("release %s not found in namespace %s. Use --install flag to install it", releaseName, configs.Namespace)
 			}
@@ -171,7 +204,7 @@ var upgradeCmd = &cobra.Command{
 			return err
 		}
 
-		return nil
+		return runHealthGate(cmd, releaseName, healthChecks)
 	},
 	Example: `
 			# Upgrade without waiting (default behavior)
@@ -183,6 +216,9 @@ var upgradeCmd = &cobra.Command{
 			# Upgrade with custom timeout and waiting
 			smurf selm upgrade my-release ./mychart --timeout 600 --wait
 
+			# Merge environment values kept in the cluster
+			smurf selm upgrade my-release ./mychart --values-from configmap/platform/env-config:values.yaml
+
 			# Install if not present without waiting (default)
 			smurf selm upgrade my-release ./mychart --install
 
@@ -195,8 +231,28 @@ var upgradeCmd = &cobra.Command{
 			# Upgrade with all options
 			smurf selm upgrade my-release ./mychart --wait --timeout 300 --history-max 3 --atomic
 
+			# Show the manifest diff against the deployed revision before upgrading
+			# (smurf selm diff shows it without upgrading)
+			smurf selm upgrade my-release ./mychart -f values.yaml --diff
+
 			# Force upgrade (Helm native behavior - forces delete/recreate)
 			smurf selm upgrade my-release ./mychart --force
+
+			# Roll back when the app doesn't answer its health endpoint
+			smurf selm upgrade my-release ./mychart --health-url https://my-app.example.com/healthz --rollback-on-unhealthy
+
+			# Validate the upgrade in a CI job without cluster credentials
+			smurf selm upgrade my-release ./mychart -f values.yaml --client-only --kube-version 1.30.0
+
+			# Run only the pre-upgrade hooks (see smurf selm hooks), or none at all
+			smurf selm upgrade my-release ./mychart --hooks-only pre-upgrade
+			smurf selm upgrade my-release ./mychart --no-hooks
+
+			# Carry the stored values across a breaking chart version
+			smurf selm upgrade my-release ./mychart --migrate-values --diff
+
+			# Show which values file, --set or chart default each value comes from
+			smurf selm upgrade my-release ./mychart -f values.yaml -f values-prod.yaml --explain-values --client-only
 	`,
 }
 
@@ -204,19 +260,33 @@ func init() {
 	upgradeCmd.Flags().StringSliceVar(&configs.Set, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
 	upgradeCmd.Flags().StringSliceVar(&configs.SetLiteral, "set-literal", []string{}, "Set literal values on the command line (values are always treated as strings)")
 	upgradeCmd.Flags().StringSliceVarP(&configs.File, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
+	upgradeCmd.Flags().StringArrayVar(&configs.ValuesFrom, "values-from", []string{}, "Merge values stored in the cluster, as configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY (repeatable; applied after --values)")
+	upgradeCmd.Flags().BoolVar(&configs.NoNamespaceDefaults, "no-namespace-defaults", false, "Ignore the default values of the smurf-defaults ConfigMap in the release namespace")
+	upgradeCmd.Flags().StringArrayVar(&configs.DependencyPaths, "dependency-path", []string{}, "Use the chart at PATH for the dependency NAME instead of its vendored or published version, as NAME=PATH (repeatable)")
 	upgradeCmd.Flags().StringVarP(&configs.Namespace, "namespace", "n", "default", "Specify the namespace to install the release into")
 	upgradeCmd.Flags().BoolVar(&createNamespace, "create-namespace", false, "Create the namespace if it does not exist")
 	upgradeCmd.Flags().BoolVar(&configs.Atomic, "atomic", false, "If set, the installation process purges the chart on fail, the upgrade process rolls back changes, and the upgrade process waits for the resources to be ready")
-	upgradeCmd.Flags().IntVar(&configs.Timeout, "timeout", 120, "Time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	upgradeCmd.Flags().IntVar(&configs.Timeout, "timeout", configs.DefaultTimeouts.HelmWait, "Time to wait for any individual Kubernetes operation (like Jobs for hooks) (overrides timeouts.helmWait in smurf.yaml)")
 	upgradeCmd.Flags().BoolVar(&configs.Debug, "debug", false, "Enable verbose output")
 	upgradeCmd.Flags().BoolVar(&installIfNotPresent, "install", false, "Install the chart if it is not already installed")
 	upgradeCmd.Flags().BoolVar(&forceUpgrade, "force", false, "Force resource updates through delete/recreate if needed")
 	upgradeCmd.Flags().StringVar(&RepoURL, "repo-url", "", "Helm repository URL")
 	upgradeCmd.Flags().StringVar(&Version, "version", "", "Helm chart version")
+	addVerifyFlags(upgradeCmd)
 	upgradeCmd.Flags().BoolVar(&wait, "wait", false, "Wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment are ready before marking success")
+	upgradeCmd.Flags().BoolVar(&upgradeDiff, "diff", false, "Print a colored diff of every resource against the deployed revision before upgrading")
 	upgradeCmd.Flags().IntVar(&historyMax, "history-max", 10, "Limit the maximum number of revisions saved per release")
+	addHealthFlags(upgradeCmd)
+	addClientOnlyFlags(upgradeCmd)
+	addHookFlags(upgradeCmd)
+	addSchemaFlags(upgradeCmd)
+	upgradeCmd.Flags().BoolVar(&configs.MigrateValues, "migrate-values", false, "Upgrade from the release's stored values, migrated to the new chart version (see smurf selm migrate-values); --values and --set apply on top")
+	upgradeCmd.Flags().StringVar(&configs.ValuesMigrations, "values-migrations", "", "Values migrations file applied after those of the chart, with --migrate-values")
+	upgradeCmd.Flags().BoolVar(&configs.ExplainValues, "explain-values", false, "Print the source of every effective value: chart default, namespace defaults, values file, --values-from or --set")
 	upgradeCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 
+	upgradeCmd.MarkFlagsMutuallyExclusive("migrate-values", "client-only")
+
 	upgradeCmd.ValidArgsFunction = completeReleaseNames
 	_ = upgradeCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
 
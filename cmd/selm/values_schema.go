@@ -0,0 +1,28 @@
+package selm
+
+import (
+	"github.com/clouddrove/smurf/configs"
+	"github.com/spf13/cobra"
+)
+
+// addSchemaFlags registers the values schema flags shared by install and
+// upgrade.
+func addSchemaFlags(c *cobra.Command) {
+	c.Flags().StringVar(&configs.ValuesSchema, "values-schema", "", "JSON Schema file the values must meet on top of the chart's values.schema.json (default: selm.valuesSchema of smurf.yaml)")
+	c.Flags().BoolVar(&configs.SkipSchemaValidation, "skip-schema-validation", false, "Check the values against no schema, neither the chart's nor --values-schema")
+	c.MarkFlagsMutuallyExclusive("values-schema", "skip-schema-validation")
+}
+
+// applyValuesSchema uses selm.valuesSchema of smurf.yaml when --values-schema
+// is not given.
+func applyValuesSchema(c *cobra.Command) error {
+	if c.Flags().Changed("values-schema") {
+		return nil
+	}
+	schema, err := configs.LoadSelmValuesSchema(configs.FileName)
+	if err != nil {
+		return err
+	}
+	configs.ValuesSchema = schema
+	return nil
+}
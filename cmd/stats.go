@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/clouddrove/smurf/internal/stats"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsOutput string
+	statsLast   int
+)
+
+// statsCmd shows the duration trends smurf deploy records in the local
+// deploy history.
+var statsCmd = &cobra.Command{
+	Use:   "stats [SERVICE]",
+	Short: "Show how long deploys take, phase by phase, and how that changes",
+	Long: `Stats shows the durations smurf deploy has recorded for each service: per
+phase (build, push, helm) the number of successful runs, the last, median
+and 90th percentile duration, and the trend of the median of the recent
+runs against the ones before them.
+
+Given a service, it also lists its most recent runs. A service is the
+image deploy builds (sdkr.imageName), or the release it deploys when it
+builds none.
+
+The history is kept in ~/.smurf/history.db (under $SMURF_HOME when set),
+with the last 200 runs of each service. deploy warns when a phase takes
+more than deploy.slowdownThreshold (default 1.5) times its median over the
+last 20 successful runs, and at least 30s longer.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(statsOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", statsOutput)
+		}
+		path, err := stats.HistoryPath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return stats.PrintTrends(nil, statsOutput)
+		}
+		h, err := stats.Open(path)
+		if err != nil {
+			return err
+		}
+		defer h.Close()
+
+		services := args
+		if len(services) == 0 {
+			if services, err = h.Services(); err != nil {
+				return err
+			}
+		}
+		var trends []stats.ServiceTrends
+		var runs []stats.Run
+		for _, service := range services {
+			if runs, err = h.Runs(service); err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				return fmt.Errorf("no deploys of %s recorded in %s", service, path)
+			}
+			trends = append(trends, stats.Summarize(service, runs))
+		}
+		if err := stats.PrintTrends(trends, statsOutput); err != nil {
+			return err
+		}
+		if len(args) == 1 && statsOutput == "table" {
+			fmt.Println()
+			return stats.PrintRuns(runs, statsLast)
+		}
+		return nil
+	},
+	Example: `
+  # Show the duration trends of every service deployed from this machine
+  smurf stats
+
+  # Show one service and its last 20 runs
+  smurf stats my-app --last 20
+
+  # Feed the trends to a dashboard
+  smurf stats -o json
+`,
+}
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsOutput, "output", "o", "table", "output format (table|json)")
+	statsCmd.Flags().IntVar(&statsLast, "last", 10, "Number of recent runs to list for a service (0 = all)")
+
+	_ = statsCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"table", "json"}, cobra.ShellCompDirectiveDefault
+	})
+
+	RootCmd.AddCommand(statsCmd)
+}
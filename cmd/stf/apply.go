@@ -1,6 +1,8 @@
 package stf
 
 import (
+	"fmt"
+
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +15,9 @@ var applyAutoApprove bool
 var applyTarget []string
 var applyState string
 var applyPlanFile string
+var applyParallelism int
+var applySlowest int
+var applyApprovedHash string
 var useAI bool
 
 // applyCmd defines a subcommand that applies the changes required to reach the desired state of Terraform Infrastructure.
@@ -32,11 +37,15 @@ var applyCmd = &cobra.Command{
 		// If we have a plan file (either from flag or positional arg), use ApplyWithPlan
 
 		if planFile != "" {
-			return terraform.ApplyWithPlan(planFile, applyVarNameValue, applyVarFile, applyLock, applyDir, applyTarget, applyState, useAI)
+			return terraform.ApplyWithPlan(planFile, applyApprovedHash, applyVarNameValue, applyVarFile, applyLock, applyDir, applyTarget, applyState, applyParallelism, applySlowest, useAI)
+		}
+
+		if applyApprovedHash != "" {
+			return fmt.Errorf("--approved-hash needs the saved plan file it approves")
 		}
 
 		// No plan file provided - use the regular apply flow with auto-approve option
-		return terraform.Apply(applyAutoApprove, applyVarNameValue, applyVarFile, applyLock, applyDir, applyTarget, applyState, useAI)
+		return terraform.Apply(applyAutoApprove, applyVarNameValue, applyVarFile, applyLock, applyDir, applyTarget, applyState, applyParallelism, applySlowest, useAI)
 	},
 	Example: `
 	# Apply command
@@ -68,6 +77,13 @@ var applyCmd = &cobra.Command{
 	# Use custom state file
 	smurf stf apply --state=/path/to/terraform.tfstate
 	smurf stf apply --state=prod.tfstate
+
+	# Apply a plan only if it is the one that was reviewed (plan --out writes its hash)
+	smurf stf apply plan.out --approved-hash 3f2a...e9
+	smurf stf apply plan.out --approved-hash plan.out.sha256
+
+	# Limit concurrent operations and list the 20 slowest resources afterwards
+	smurf stf apply --parallelism=4 --slowest=20
 	`,
 }
 
@@ -80,6 +96,9 @@ func init() {
 	applyCmd.Flags().StringArrayVar(&applyTarget, "target", []string{}, "Target specific resources, modules, or resources in modules")
 	applyCmd.Flags().StringVar(&applyState, "state", "", "Path to read and save the Terraform state")
 	applyCmd.Flags().StringVar(&applyPlanFile, "plan", "", "Path to a plan file to apply (skips approval prompt)")
+	applyCmd.Flags().StringVar(&applyApprovedHash, "approved-hash", "", "Refuse to apply unless the plan file's SHA-256 is this hash (or the one in the .sha256 file plan --out writes)")
+	applyCmd.Flags().IntVar(&applyParallelism, "parallelism", 0, "Limit the number of concurrent operations (default: terraform's 10)")
+	applyCmd.Flags().IntVar(&applySlowest, "slowest", terraform.DefaultSlowestResources, "Number of slowest resources to report after the apply (0 to disable)")
 	applyCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 	stfCmd.AddCommand(applyCmd)
 }
@@ -0,0 +1,60 @@
+package stf
+
+import (
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkDir        string
+	checkPolicyDir  string
+	checkPlanFile   string
+	checkVars       []string
+	checkVarFiles   []string
+	checkNamespaces []string
+	checkFailOnWarn bool
+)
+
+// checkCmd defines a subcommand that evaluates a Terraform plan against Rego policies
+var checkCmd = &cobra.Command{
+	Use:          "check",
+	Short:        "Check a Terraform plan against Rego policies using Conftest",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return terraform.Check(terraform.CheckOptions{
+			Dir:        checkDir,
+			PolicyDir:  checkPolicyDir,
+			PlanFile:   checkPlanFile,
+			Vars:       checkVars,
+			VarFiles:   checkVarFiles,
+			Namespaces: checkNamespaces,
+			FailOnWarn: checkFailOnWarn,
+		}, useAI)
+	},
+	Example: `
+    # Generate a plan and check it against ./policy
+    smurf stf check
+
+    # Use a custom policy directory and variables
+    smurf stf check --policy ./policies --var-file=prod.tfvars
+
+    # Check an existing plan file (binary or JSON)
+    smurf stf plan --out=tfplan
+    smurf stf check --policy ./policies --plan tfplan
+
+    # Only evaluate specific Rego packages and treat warnings as failures
+    smurf stf check --policy ./policies --namespace tags --namespace encryption --fail-on-warn
+    `,
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkDir, "dir", ".", "Specify the directory containing Terraform files")
+	checkCmd.Flags().StringVar(&checkPolicyDir, "policy", "policy", "Directory containing the Rego policies")
+	checkCmd.Flags().StringVar(&checkPlanFile, "plan", "", "Existing plan file or plan JSON to check (default: generate a new plan)")
+	checkCmd.Flags().StringArrayVar(&checkVars, "var", []string{}, "Specify a variable in 'NAME=VALUE' format when generating the plan")
+	checkCmd.Flags().StringArrayVar(&checkVarFiles, "var-file", []string{}, "Specify a file containing variables when generating the plan")
+	checkCmd.Flags().StringArrayVar(&checkNamespaces, "namespace", []string{}, "Rego package to evaluate (repeatable; default: all packages)")
+	checkCmd.Flags().BoolVar(&checkFailOnWarn, "fail-on-warn", false, "Fail when policies report warnings")
+	checkCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	stfCmd.AddCommand(checkCmd)
+}
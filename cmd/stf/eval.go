@@ -0,0 +1,65 @@
+package stf
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalDir      string
+	evalVars     []string
+	evalVarFiles []string
+	evalExpect   string
+	evalFormat   string
+)
+
+// evalCmd evaluates expressions with a non-interactive terraform console.
+var evalCmd = &cobra.Command{
+	Use:   "eval EXPRESSION [EXPRESSION...]",
+	Short: "Evaluate Terraform expressions against the configuration and state",
+	Long: `Evaluate Terraform expressions with terraform console, without an interactive
+session. A single string value is printed raw and other values as JSON, so
+the result can be used in scripts; several expressions print one
+'EXPRESSION = VALUE' line each. --expect fails the command unless the value
+of the expression equals the given string, or JSON value for numbers, bools,
+lists and objects.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(evalFormat, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", evalFormat)
+		}
+		var expect *string
+		if cmd.Flags().Changed("expect") {
+			expect = &evalExpect
+		}
+		return terraform.Eval(evalDir, args, evalVars, evalVarFiles, expect, evalFormat, useAI)
+	},
+	Example: `
+	smurf stf eval 'local.vpc_cidr'
+	smurf stf eval 'cidrsubnet(var.vpc_cidr, 8, 2)' --var-file prod.tfvars
+	smurf stf eval 'local.vpc_cidr' 'length(local.azs)' -o json
+
+	# CI: fail unless the local computes to the expected CIDR
+	smurf stf eval 'local.vpc_cidr' --expect 10.20.0.0/16 --var-file prod.tfvars
+	smurf stf eval 'length(local.private_subnets)' --expect 3
+	`,
+}
+
+func init() {
+	evalCmd.Flags().StringVar(&evalDir, "dir", ".", "Specify the Terraform directory")
+	evalCmd.Flags().StringArrayVar(&evalVars, "var", []string{}, "Specify a variable in 'NAME=VALUE' format")
+	evalCmd.Flags().StringArrayVar(&evalVarFiles, "var-file", []string{}, "Specify a file containing variables")
+	evalCmd.Flags().StringVar(&evalExpect, "expect", "", "Fail unless the value of the expression equals this (a string, or JSON for other values)")
+	evalCmd.Flags().StringVarP(&evalFormat, "output", "o", "table", "output format (table|json)")
+	evalCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = evalCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"table", "json"}, cobra.ShellCompDirectiveDefault
+	})
+
+	stfCmd.AddCommand(evalCmd)
+}
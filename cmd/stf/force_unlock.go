@@ -0,0 +1,54 @@
+package stf
+
+import (
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceUnlockDir    string
+	forceUnlockReason string
+)
+
+// forceUnlockCmd releases a stuck state lock after showing who holds it.
+var forceUnlockCmd = &cobra.Command{
+	Use:   "force-unlock [LOCK_ID]",
+	Short: "Inspect and release a stuck Terraform state lock",
+	Long: `Release a Terraform state lock that was left behind, e.g. by a crashed CI
+run, with the guardrails raw terraform force-unlock lacks: the lock's ID,
+operation, holder and age are shown first, the lock ID must be typed back to
+confirm, and every unlock is recorded with its reason in the audit log
+(~/.smurf/audit/terraform-force-unlock.jsonl, under $SMURF_HOME when set).
+
+With LOCK_ID, nothing is released unless the current lock has that ID, so a
+lock taken by a newer run is never released by mistake.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var lockID string
+		if len(args) == 1 {
+			lockID = args[0]
+		}
+		err := terraform.ForceUnlock(forceUnlockDir, lockID, forceUnlockReason, useAI)
+		if err != nil {
+			terraform.ErrorHandler(err)
+			return err
+		}
+		return nil
+	},
+	Example: `
+    # Show the lock and release it after confirming its ID
+    smurf stf force-unlock --reason "apply runner was killed"
+
+    # Only release the lock with this ID
+    smurf stf force-unlock 5e1f0c3a-8d2b-4c41-9d7e-2f6a1b0c9e44 --dir=path/to/terraform/code
+    `,
+}
+
+func init() {
+	forceUnlockCmd.Flags().StringVar(&forceUnlockDir, "dir", ".", "Specify the Terraform directory")
+	forceUnlockCmd.Flags().StringVar(&forceUnlockReason, "reason", "", "Why the lock is released, recorded in the audit log (prompted for when empty)")
+	forceUnlockCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	stfCmd.AddCommand(forceUnlockCmd)
+}
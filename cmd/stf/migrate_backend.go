@@ -0,0 +1,58 @@
+package stf
+
+import (
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateBackendDir         string
+	migrateBackendTo          string
+	migrateBackendConfig      []string
+	migrateBackendAutoApprove bool
+)
+
+// migrateBackendCmd moves the Terraform state to another backend.
+var migrateBackendCmd = &cobra.Command{
+	Use:   "migrate-backend",
+	Short: "Move the Terraform state to another backend",
+	Long: `Move the Terraform state to another backend with guardrails around
+terraform init -migrate-state: the current state is backed up locally first,
+the new backend block is written to smurf_backend_override.tf, and the
+migrated state must have the same lineage, serial and resource count as the
+original. When it doesn't, the configuration is pointed back at the old
+backend, which still holds the state.
+
+--to takes s3://BUCKET/KEY, gs://BUCKET/PREFIX, azurerm://ACCOUNT/CONTAINER/KEY
+or a local path; --backend-config adds other backend settings.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := terraform.MigrateBackend(migrateBackendDir, migrateBackendTo, migrateBackendConfig, migrateBackendAutoApprove, useAI)
+		if err != nil {
+			terraform.ErrorHandler(err)
+			return err
+		}
+		return nil
+	},
+	Example: `
+    # Move the state to S3
+    smurf stf migrate-backend --to s3://my-tf-state/prod/terraform.tfstate --backend-config region=us-east-1
+
+    # Move the state to GCS without the confirmation prompt
+    smurf stf migrate-backend --to gs://my-tf-state/prod --auto-approve
+
+    # Move the state back to a local file
+    smurf stf migrate-backend --to terraform.tfstate --dir=path/to/terraform/code
+    `,
+}
+
+func init() {
+	migrateBackendCmd.Flags().StringVar(&migrateBackendDir, "dir", ".", "Specify the Terraform directory")
+	migrateBackendCmd.Flags().StringVar(&migrateBackendTo, "to", "", "Backend to move the state to (s3://BUCKET/KEY, gs://BUCKET/PREFIX, azurerm://ACCOUNT/CONTAINER/KEY or a local path)")
+	migrateBackendCmd.Flags().StringArrayVar(&migrateBackendConfig, "backend-config", []string{}, "Additional backend setting as KEY=VALUE (can be used multiple times)")
+	migrateBackendCmd.Flags().BoolVar(&migrateBackendAutoApprove, "auto-approve", false, "Skip the confirmation prompt")
+	migrateBackendCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+	_ = migrateBackendCmd.MarkFlagRequired("to")
+
+	stfCmd.AddCommand(migrateBackendCmd)
+}
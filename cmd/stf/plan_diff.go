@@ -0,0 +1,62 @@
+package stf
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planDiffOpts   terraform.PlanDiffOptions
+	planDiffOutput string
+)
+
+// planDiffCmd compares the plan of the working tree with the plan of a base
+// ref.
+var planDiffCmd = &cobra.Command{
+	Use:   "plan-diff",
+	Short: "Show what this branch's changes add to the plan of a base ref",
+	Long: `Plan the Terraform directory twice, as it is in the working tree and as it
+is at --base (checked out in a temporary git worktree), and list the resources
+the two plans change differently: changes only this branch makes, changes only
+the base makes, and resources both change in different ways.
+
+Both plans run against the same state with the same --var and --var-file
+values; var files inside the repository are read from each revision. The
+base checkout is initialized with terraform init, so pass --backend-config
+when init needs it. The working tree must be initialized already.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(planDiffOutput, "text", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of text, json", planDiffOutput)
+		}
+		d, err := terraform.DiffPlans(planDiffOpts, useAI)
+		if err != nil {
+			return err
+		}
+		return terraform.PrintPlanDiff(d, planDiffOutput)
+	},
+	Example: `
+    # What this branch changes in the plan, relative to main
+    smurf stf plan-diff --base main
+
+    # A module directory, with variables, as JSON for a PR comment bot
+    smurf stf plan-diff --base origin/main --dir infra/prod --var-file infra/prod/prod.tfvars -o json
+    `,
+}
+
+func init() {
+	planDiffCmd.Flags().StringVar(&planDiffOpts.Base, "base", "main", "Git ref to compare the working tree with")
+	planDiffCmd.Flags().StringVar(&planDiffOpts.Dir, "dir", ".", "Specify the directory containing Terraform files")
+	planDiffCmd.Flags().StringArrayVar(&planDiffOpts.Vars, "var", []string{}, "Specify a variable in 'NAME=VALUE' format, for both plans")
+	planDiffCmd.Flags().StringArrayVar(&planDiffOpts.VarFiles, "var-file", []string{}, "Specify a file containing variables, for both plans")
+	planDiffCmd.Flags().StringArrayVar(&planDiffOpts.BackendConfig, "backend-config", []string{}, "Backend configuration for terraform init of the base checkout")
+	planDiffCmd.Flags().BoolVar(&planDiffOpts.Refresh, "refresh", true, "Update state prior to checking for differences")
+	planDiffCmd.Flags().StringVarP(&planDiffOutput, "output", "o", "text", "output format (text|json)")
+	planDiffCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	stfCmd.AddCommand(planDiffCmd)
+}
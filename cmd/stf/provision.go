@@ -12,6 +12,8 @@ var varFile []string
 var lock bool
 var upgrade bool
 var provisionDir string
+var provisionParallelism int
+var provisionSlowest int
 
 var provisionCmd = &cobra.Command{
 	Use:          "provision",
@@ -29,7 +31,7 @@ var provisionCmd = &cobra.Command{
 			return err
 		}
 
-		if err := terraform.Apply(autoApprove, varNameValue, varFile, lock, provisionDir, applyTarget, applyState, useAI); err != nil {
+		if err := terraform.Apply(autoApprove, varNameValue, varFile, lock, provisionDir, applyTarget, applyState, provisionParallelism, provisionSlowest, useAI); err != nil {
 			return err
 		}
 
@@ -58,6 +60,8 @@ func init() {
 	provisionCmd.Flags().BoolVar(&upgrade, "upgrade", false, "Upgrade the Terraform modules and plugins to the latest versions")
 	provisionCmd.Flags().StringVar(&provisionDir, "dir", "", "Specify the directory for Terraform operations")
 	provisionCmd.Flags().StringVar(&planOut, "out", "", "Path to save the generated execution plan")
+	provisionCmd.Flags().IntVar(&provisionParallelism, "parallelism", 0, "Limit the number of concurrent operations (default: terraform's 10)")
+	provisionCmd.Flags().IntVar(&provisionSlowest, "slowest", terraform.DefaultSlowestResources, "Number of slowest resources to report after the apply (0 to disable)")
 	provisionCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
 	stfCmd.AddCommand(provisionCmd)
 }
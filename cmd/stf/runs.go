@@ -0,0 +1,57 @@
+package stf
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runsLimit  int
+	runsOutput string
+)
+
+// runsCmd lists the applies recorded in the run log.
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "List past applies recorded in the run log",
+	Long: `Every 'stf apply' and 'stf provision' that applies changes records who ran
+it, when, the terraform version and workspace, the number of changes and a
+hash of the planned change set, and whether it succeeded. 'stf runs' lists
+them, newest last: a lightweight audit trail without Terraform Cloud.
+
+The run log is stf.runLog in smurf.yaml: s3://BUCKET/PREFIX or
+gs://BUCKET/PREFIX (one object per apply), dynamodb://TABLE (one item per
+apply; the table's partition key must be the string attribute "id") or a
+local file. It defaults to ~/.smurf/terraform-runs.jsonl (under $SMURF_HOME
+when set), so point it at shared storage for a team.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(runsOutput, "table", "json") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json", runsOutput)
+		}
+		records, err := terraform.ListRuns(runsLimit)
+		if err != nil {
+			terraform.ErrorHandler(err)
+			return err
+		}
+		return terraform.PrintRuns(records, runsOutput)
+	},
+	Example: `
+    # The last 20 applies
+    smurf stf runs
+
+    # Every apply, as JSON
+    smurf stf runs --limit 0 -o json
+    `,
+}
+
+func init() {
+	runsCmd.Flags().IntVar(&runsLimit, "limit", 20, "Number of most recent applies to list (0 for all)")
+	runsCmd.Flags().StringVarP(&runsOutput, "output", "o", "table", "output format (table|json)")
+
+	stfCmd.AddCommand(runsCmd)
+}
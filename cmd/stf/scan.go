@@ -0,0 +1,80 @@
+package stf
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanDir       string
+	scanPlanFile  string
+	scanScanners  []string
+	scanFailOn    string
+	scanOutput    string
+	scanSARIFFile string
+)
+
+// scanCmd runs IaC security scanners over a Terraform configuration
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scan a Terraform configuration and plan for security issues with checkov, trivy or tfsec",
+	Long: `Run IaC security scanners over the Terraform configuration in --dir, and over
+the plan of --plan when it is given, and report their findings in one table
+with the severity, rule, resource and location of each.
+
+--scanner picks checkov, trivy or tfsec (repeatable); without it the first of
+them found in PATH runs. tfsec can't read plans, so it only scans the
+configuration. Checkov reports severities only with a Prisma Cloud API key;
+without one its findings are UNKNOWN, which no gate fails on.
+
+--fail-on fails the command on findings of that severity or higher, as
+'smurf sdkr scan --fail-on' does for images. -o sarif prints the findings as
+a SARIF log, and --sarif writes one next to any output, for code scanning
+dashboards.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.ValidOutputFormat(scanOutput, "table", "json", "sarif") {
+			return fmt.Errorf("invalid output format %q: must be one of table, json, sarif", scanOutput)
+		}
+		return terraform.Scan(terraform.ScanOptions{
+			Dir:       scanDir,
+			PlanFile:  scanPlanFile,
+			Scanners:  scanScanners,
+			FailOn:    scanFailOn,
+			Output:    scanOutput,
+			SARIFFile: scanSARIFFile,
+		}, useAI)
+	},
+	Example: `
+    # Scan the configuration with the first scanner installed
+    smurf stf scan
+
+    # Scan the configuration and a saved plan with checkov, failing on HIGH or CRITICAL findings
+    smurf stf plan --out=tfplan
+    smurf stf scan --scanner checkov --plan tfplan --fail-on high
+
+    # Run trivy and tfsec and upload the SARIF log to code scanning
+    smurf stf scan --scanner trivy --scanner tfsec --sarif results.sarif
+    `,
+}
+
+func init() {
+	scanCmd.Flags().StringVar(&scanDir, "dir", ".", "Specify the directory containing Terraform files")
+	scanCmd.Flags().StringVar(&scanPlanFile, "plan", "", "Plan file or plan JSON to scan as well")
+	scanCmd.Flags().StringArrayVar(&scanScanners, "scanner", []string{}, "Scanner to run: checkov, trivy or tfsec (repeatable; default: the first one installed)")
+	scanCmd.Flags().StringVar(&scanFailOn, "fail-on", "", "Fail when the scan finds issues of this severity or higher (low|medium|high|critical)")
+	scanCmd.Flags().StringVarP(&scanOutput, "output", "o", "table", "output format (table|json|sarif)")
+	scanCmd.Flags().StringVar(&scanSARIFFile, "sarif", "", "Also write the findings as a SARIF log to this file")
+	scanCmd.Flags().BoolVar(&useAI, "ai", false, "To enable AI help mode, export the OPENAI_API_KEY environment variable with your OpenAI API key.")
+
+	_ = scanCmd.RegisterFlagCompletionFunc("scanner", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"checkov", "trivy", "tfsec"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = scanCmd.RegisterFlagCompletionFunc("fail-on", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"low", "medium", "high", "critical"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	stfCmd.AddCommand(scanCmd)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/configs"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +14,16 @@ var stfCmd = &cobra.Command{
 	Short:         "Subcommand for Terraform-related actions",
 	Long:          `stf is a subcommand that groups various Terraform-related actions under a single command.`,
 	SilenceErrors: true,
+	// Every stf command runs terraform, so the stf section of smurf.yaml
+	// (run isolation) is loaded once here.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		stf, err := configs.LoadStfConfig(configs.FileName)
+		if err != nil {
+			return err
+		}
+		configs.Stf = stf
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Use 'smurf stf [command]' to run Terraform-related actions")
 	},
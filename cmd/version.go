@@ -2,19 +2,40 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 
+	"github.com/clouddrove/smurf/internal/selfupdate"
 	"github.com/spf13/cobra"
 )
 
+var versionCheck bool
+
 // versionCmd represents subcommand for version.
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print detailed version information",
-	Long:  `Print the version number of Smurf CLI along with build information`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Print the version number of Smurf CLI along with build information.
+
+With --check it also looks up the latest release on GitHub and the version
+the repository pins in .smurf-version, and reports whether this binary is
+outdated or does not match the pin.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		printVersion()
+		if !versionCheck {
+			return nil
+		}
+		fmt.Println()
+		return checkVersion()
 	},
+	Example: `
+  # Print the version and build information
+  smurf version
+
+  # Also check for a newer release and the repository's .smurf-version
+  smurf version --check
+`,
 }
 
 // print smurf version, git commit, build data
@@ -26,3 +47,69 @@ func printVersion() {
 	fmt.Printf("Go version: %s\n", runtime.Version())
 	fmt.Printf("OS/Arch:    %s/%s\n", runtime.GOOS, runtime.GOARCH)
 }
+
+// checkVersion prints the latest release and the repository's pin against
+// this binary's version.
+func checkVersion() error {
+	latest, err := selfupdate.Latest()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Latest:     %s (%s)\n", latest.Tag, latest.URL)
+
+	pin, err := selfupdate.FindPin(".")
+	if err != nil {
+		return err
+	}
+	if pin != nil {
+		fmt.Printf("Pinned:     %s (%s)\n", pin.Constraint, pin.Path)
+	}
+	fmt.Println()
+
+	switch {
+	case pin != nil && !pin.Matches(version):
+		fmt.Printf("smurf %s does not match the pinned %s; run smurf self-update to install it\n", version, pin.Constraint)
+	case selfupdate.Newer(latest.Tag, version) && pin != nil:
+		fmt.Printf("smurf %s matches the pin; %s is available but not pinned\n", version, latest.Tag)
+	case selfupdate.Newer(latest.Tag, version):
+		fmt.Printf("smurf %s is available; run smurf self-update to install it\n", latest.Tag)
+	default:
+		fmt.Printf("smurf %s is up to date\n", version)
+	}
+	return nil
+}
+
+// checkPinnedVersion compares this binary with the .smurf-version of the
+// repository smurf runs in before any command that is not about the smurf
+// version itself: it warns on a mismatch, or fails with
+// SMURF_VERSION_CHECK=block.
+func checkPinnedVersion(args []string) error {
+	c, _, err := RootCmd.Find(args)
+	if err != nil || c == RootCmd || c == versionCmd || c == selfUpdateCmd || c == docsCmd || c.Name() == "help" {
+		return nil
+	}
+	mode, err := selfupdate.CheckMode()
+	if err != nil || mode == selfupdate.ModeOff {
+		return err
+	}
+	var msg string
+	pin, err := selfupdate.FindPin(".")
+	switch {
+	case err != nil:
+		msg = err.Error()
+	case pin == nil || pin.Matches(version):
+		return nil
+	default:
+		msg = fmt.Sprintf("smurf %s does not match the version %s pins: %s; run smurf self-update to install it",
+			version, pin.Path, pin.Constraint)
+	}
+	if mode == selfupdate.ModeBlock {
+		return fmt.Errorf("%s (SMURF_VERSION_CHECK=block)", msg)
+	}
+	fmt.Fprintln(os.Stderr, "Warning: "+msg)
+	return nil
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "Check for a newer release and the version pinned in .smurf-version")
+}
@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BackupLocation is a BackupConfig.Location split into its parts.
+type BackupLocation struct {
+	Scheme  string // s3, gs or azblob
+	Account string // storage account, for azblob
+	Bucket  string // bucket, or blob container for azblob
+	Prefix  string // object name prefix, without surrounding slashes
+}
+
+// Parse splits the location of b.
+func (b BackupConfig) Parse() (BackupLocation, error) {
+	scheme, rest, found := strings.Cut(b.Location, "://")
+	invalid := fmt.Errorf("invalid selm.backup.location %q: must be s3://BUCKET/PREFIX, gs://BUCKET/PREFIX or azblob://ACCOUNT/CONTAINER/PREFIX", b.Location)
+	if !found {
+		return BackupLocation{}, invalid
+	}
+	loc := BackupLocation{Scheme: scheme}
+	switch scheme {
+	case "s3", "gs":
+		loc.Bucket, loc.Prefix, _ = strings.Cut(rest, "/")
+	case "azblob":
+		var container string
+		loc.Account, container, _ = strings.Cut(rest, "/")
+		loc.Bucket, loc.Prefix, _ = strings.Cut(container, "/")
+		if loc.Account == "" {
+			return BackupLocation{}, invalid
+		}
+	default:
+		return BackupLocation{}, invalid
+	}
+	if loc.Bucket == "" {
+		return BackupLocation{}, invalid
+	}
+	loc.Prefix = strings.Trim(loc.Prefix, "/")
+	return loc, nil
+}
+
+// Validate checks the location of b, when one is set.
+func (b BackupConfig) Validate() error {
+	if b.Location == "" {
+		return nil
+	}
+	_, err := b.Parse()
+	return err
+}
@@ -0,0 +1,56 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupConfigParse(t *testing.T) {
+	cases := []struct {
+		location string
+		want     BackupLocation
+		wantErr  bool
+	}{
+		{"s3://backups/helm/", BackupLocation{Scheme: "s3", Bucket: "backups", Prefix: "helm"}, false},
+		{"gs://backups", BackupLocation{Scheme: "gs", Bucket: "backups"}, false},
+		{"azblob://acct/releases/prod/eu", BackupLocation{Scheme: "azblob", Account: "acct", Bucket: "releases", Prefix: "prod/eu"}, false},
+		{"azblob://acct", BackupLocation{}, true},
+		{"s3://", BackupLocation{}, true},
+		{"ftp://host/dir", BackupLocation{}, true},
+		{"/var/backups", BackupLocation{}, true},
+	}
+	for _, c := range cases {
+		got, err := BackupConfig{Location: c.location}.Parse()
+		if (err != nil) != c.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", c.location, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.location, got, c.want)
+		}
+	}
+
+	if err := (BackupConfig{}).Validate(); err != nil {
+		t.Errorf("an unset location must be valid: %v", err)
+	}
+}
+
+func TestLoadSelmBackup(t *testing.T) {
+	t.Setenv("BACKUP_BUCKET", "release-backups")
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte("selm:\n  backup:\n    location: s3://${BACKUP_BUCKET}/helm\n    required: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := LoadSelmBackup(path)
+	if err != nil {
+		t.Fatalf("LoadSelmBackup: %v", err)
+	}
+	if backup.Location != "s3://release-backups/helm" || !backup.Required {
+		t.Errorf("LoadSelmBackup = %+v", backup)
+	}
+
+	if backup, err := LoadSelmBackup(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || backup.Location != "" {
+		t.Errorf("a missing file must mean no backup, got %+v, %v", backup, err)
+	}
+}
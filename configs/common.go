@@ -103,6 +103,52 @@ func ParseEcrImageRef(imageRef string) (string, string, string, string, error) {
 	return accountID, region, repository, tag, nil
 }
 
+// GcpImageRepository returns the GCP repository an image is pushed to. A
+// repository already on gcr.io (or a regional gcr.io host) or Artifact
+// Registry (LOCATION-docker.pkg.dev) is returned as is. Any other name is
+// placed in the Artifact Registry repository of region and repository, as
+// REGION-docker.pkg.dev/PROJECT/REPOSITORY/NAME, or in gcr.io/PROJECT/NAME
+// when neither is set.
+func GcpImageRepository(name, projectID, region, repository string) (string, error) {
+	host, _, _ := strings.Cut(name, "/")
+	if host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev") {
+		return name, nil
+	}
+	if projectID == "" {
+		return "", fmt.Errorf("GCP project ID is required to push %s: set sdkr.provisionGcrProjectID or GOOGLE_CLOUD_PROJECT, or give the full gcr.io or pkg.dev image name", name)
+	}
+	switch {
+	case region != "" && repository != "":
+		return fmt.Sprintf("%s-docker.pkg.dev/%s/%s/%s", region, projectID, repository, name), nil
+	case region != "" || repository != "":
+		return "", errors.New("sdkr.gcpRegion and sdkr.gcpRepository must be set together")
+	}
+	return fmt.Sprintf("gcr.io/%s/%s", projectID, name), nil
+}
+
+// RegistryTargets are the images `sdkr push-multi` pushes source to for the
+// entries of sdkr.registries: an entry naming a repository gets the tag of
+// source ("latest" when it has none), one with a tag or digest is kept.
+func RegistryTargets(entries []string, source string) []string {
+	tag := "latest"
+	if name := source[strings.LastIndex(source, "/")+1:]; strings.Contains(name, ":") {
+		tag = name[strings.LastIndex(name, ":")+1:]
+	}
+	targets := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.ContainsAny(entry[strings.LastIndex(entry, "/")+1:], ":@") {
+			targets = append(targets, entry)
+			continue
+		}
+		targets = append(targets, entry+":"+tag)
+	}
+	return targets
+}
+
 // ParseBuildArgs converts CLI --build-arg values into a key/value map.
 // Each flag value can be a single key=value pair or comma-separated pairs:
 // --build-arg NODE_ENV=production,API_URL=https://example.com
@@ -1,6 +1,7 @@
 package configs
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -168,3 +169,54 @@ func TestParseGhcrImageRef(t *testing.T) {
 		})
 	}
 }
+
+func TestGcpImageRepository(t *testing.T) {
+	cases := []struct {
+		name, image, project, region, repository string
+		want                                     string
+		wantErr                                  bool
+	}{
+		{"gcr.io kept", "gcr.io/other/app", "proj", "", "", "gcr.io/other/app", false},
+		{"regional gcr.io kept", "eu.gcr.io/other/app", "", "", "", "eu.gcr.io/other/app", false},
+		{"artifact registry kept", "us-central1-docker.pkg.dev/p/r/app", "proj", "europe-west1", "images", "us-central1-docker.pkg.dev/p/r/app", false},
+		{"bare name to artifact registry", "app", "proj", "europe-west1", "images", "europe-west1-docker.pkg.dev/proj/images/app", false},
+		{"bare name to gcr.io", "app", "proj", "", "", "gcr.io/proj/app", false},
+		{"no project", "app", "", "europe-west1", "images", "", true},
+		{"region without repository", "app", "proj", "europe-west1", "", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := GcpImageRepository(c.image, c.project, c.region, c.repository)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("GcpImageRepository() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("GcpImageRepository() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRegistryTargets(t *testing.T) {
+	entries := []string{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp",
+		"ghcr.io/my-org/myapp:stable",
+		" ",
+		"localhost:5000/myapp",
+		"ghcr.io/my-org/myapp@sha256:abc",
+	}
+	got := RegistryTargets(entries, "registry.local:5000/myapp:v1.4.0")
+	want := []string{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.4.0",
+		"ghcr.io/my-org/myapp:stable",
+		"localhost:5000/myapp:v1.4.0",
+		"ghcr.io/my-org/myapp@sha256:abc",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RegistryTargets() = %v, want %v", got, want)
+	}
+
+	if got := RegistryTargets([]string{"ghcr.io/my-org/myapp"}, "localhost:5000/myapp"); got[0] != "ghcr.io/my-org/myapp:latest" {
+		t.Errorf("RegistryTargets() of an untagged source = %v, want the latest tag", got)
+	}
+}
@@ -24,6 +24,15 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("unable to read the file %v", err)
 	}
 
+	data, err = resolveValueFrom(data, filePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err = withUserDefaults(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var config Config
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
@@ -31,6 +40,25 @@ func LoadConfig(filePath string) (*Config, error) {
 	}
 
 	expandConfigEnv(&config)
+	if err := config.Stf.expandEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Timeouts.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := config.Selm.DeployReleases(); err != nil {
+		return nil, err
+	}
+	if err := config.Selm.validateHealthChecks(); err != nil {
+		return nil, err
+	}
+	if err := config.Selm.Backup.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Deploy.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -66,17 +94,45 @@ func expandConfigEnv(config *Config) {
 	config.Sdkr.ProvisionAcrSubscriptionID = expandBracedEnv(config.Sdkr.ProvisionAcrSubscriptionID)
 	config.Sdkr.ProvisionGcrProjectID = expandBracedEnv(config.Sdkr.ProvisionGcrProjectID)
 	config.Sdkr.GoogleApplicationCredentials = expandBracedEnv(config.Sdkr.GoogleApplicationCredentials)
+	config.Sdkr.GCPRegion = expandBracedEnv(config.Sdkr.GCPRegion)
+	config.Sdkr.GCPRepository = expandBracedEnv(config.Sdkr.GCPRepository)
+	for i, registry := range config.Sdkr.Registries {
+		config.Sdkr.Registries[i] = expandBracedEnv(registry)
+	}
+	expandEach(config.Sdkr.CacheFrom)
+	expandEach(config.Sdkr.CacheTo)
 	config.Sdkr.ImageName = expandBracedEnv(config.Sdkr.ImageName)
 	config.Sdkr.TargetImageTag = expandBracedEnv(config.Sdkr.TargetImageTag)
 	config.Sdkr.AwsAccessKey = expandBracedEnv(config.Sdkr.AwsAccessKey)
 	config.Sdkr.AwsSecretKey = expandBracedEnv(config.Sdkr.AwsSecretKey)
 	config.Sdkr.AwsRegion = expandBracedEnv(config.Sdkr.AwsRegion)
 	config.Sdkr.Dockerfile = expandBracedEnv(config.Sdkr.Dockerfile)
+	expandWebhooksEnv(config.Sdkr.Webhooks)
 
 	config.Selm.ReleaseName = expandBracedEnv(config.Selm.ReleaseName)
 	config.Selm.Namespace = expandBracedEnv(config.Selm.Namespace)
 	config.Selm.ChartName = expandBracedEnv(config.Selm.ChartName)
 	config.Selm.FileName = expandBracedEnv(config.Selm.FileName)
+	config.Selm.Backup.Location = expandBracedEnv(config.Selm.Backup.Location)
+	for i := range config.Selm.Releases {
+		r := &config.Selm.Releases[i]
+		r.Name = expandBracedEnv(r.Name)
+		r.ChartName = expandBracedEnv(r.ChartName)
+		r.Namespace = expandBracedEnv(r.Namespace)
+		r.FileName = expandBracedEnv(r.FileName)
+		expandHealthChecksEnv(r.HealthChecks)
+	}
+	expandHealthChecksEnv(config.Selm.HealthChecks)
+
+	config.Deploy.Environment = expandBracedEnv(config.Deploy.Environment)
+	for k, v := range config.Deploy.Links {
+		config.Deploy.Links[k] = expandBracedEnv(v)
+	}
+	expandNotificationsEnv(config.Deploy.Notifications)
+
+	config.Stf.Isolation.DataDir = expandBracedEnv(config.Stf.Isolation.DataDir)
+	config.Stf.Isolation.PluginCacheDir = expandBracedEnv(config.Stf.Isolation.PluginCacheDir)
+	config.Stf.RunLog = expandBracedEnv(config.Stf.RunLog)
 }
 
 // Set the Environment Variable for the usage in the internal functions
@@ -95,3 +151,282 @@ func ExportEnvironmentVariables(vars map[string]string) error {
 
 	return nil
 }
+
+// LoadSelmInterpolate reads selm.interpolate from smurf.yaml. A missing file
+// means interpolation stays off.
+func LoadSelmInterpolate(filePath string) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			Interpolate bool `yaml:"interpolate"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return false, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Selm.Interpolate, nil
+}
+
+// LoadSelmValuesSchema reads selm.valuesSchema from smurf.yaml. A missing
+// file means only the chart's schema applies.
+func LoadSelmValuesSchema(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			ValuesSchema string `yaml:"valuesSchema"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Selm.ValuesSchema, nil
+}
+
+// LoadSelmRedactKeys reads selm.redactKeys from smurf.yaml. A missing file
+// means no value is masked.
+func LoadSelmRedactKeys(filePath string) ([]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			RedactKeys []string `yaml:"redactKeys"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Selm.RedactKeys, nil
+}
+
+// LoadSelmBackup reads selm.backup from smurf.yaml. A missing file means no
+// backup.
+func LoadSelmBackup(filePath string) (BackupConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BackupConfig{}, nil
+		}
+		return BackupConfig{}, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			Backup BackupConfig `yaml:"backup"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return BackupConfig{}, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	backup := config.Selm.Backup
+	backup.Location = expandBracedEnv(backup.Location)
+	return backup, backup.Validate()
+}
+
+// LoadSelmScheduling reads selm.scheduling from smurf.yaml. A missing file
+// means nothing is injected.
+func LoadSelmScheduling(filePath string) (SchedulingConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SchedulingConfig{}, nil
+		}
+		return SchedulingConfig{}, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			Scheduling SchedulingConfig `yaml:"scheduling"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return SchedulingConfig{}, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Selm.Scheduling, nil
+}
+
+// LoadSdkrBuilder reads sdkr.builder from smurf.yaml. A missing file means
+// the default builder.
+func LoadSdkrBuilder(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Sdkr struct {
+			Builder string `yaml:"builder"`
+		} `yaml:"sdkr"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Sdkr.Builder, nil
+}
+
+// LoadSearchRegistries reads sdkr.searchRegistries from smurf.yaml. A
+// missing file means none.
+func LoadSearchRegistries(filePath string) ([]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Sdkr struct {
+			SearchRegistries []string `yaml:"searchRegistries"`
+		} `yaml:"sdkr"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Sdkr.SearchRegistries, nil
+}
+
+// LoadSdkrBuildCache reads sdkr.cacheFrom and sdkr.cacheTo from smurf.yaml,
+// with ${VAR} expanded. A missing file means no build cache.
+func LoadSdkrBuildCache(filePath string) (cacheFrom, cacheTo []string, err error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Sdkr struct {
+			CacheFrom []string `yaml:"cacheFrom"`
+			CacheTo   []string `yaml:"cacheTo"`
+		} `yaml:"sdkr"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	expandEach(config.Sdkr.CacheFrom)
+	expandEach(config.Sdkr.CacheTo)
+	return config.Sdkr.CacheFrom, config.Sdkr.CacheTo, nil
+}
+
+// expandEach expands ${VAR} in every string of values, in place.
+func expandEach(values []string) {
+	for i, v := range values {
+		values[i] = expandBracedEnv(v)
+	}
+}
+
+// LoadRequirePinnedBases reads sdkr.requirePinnedBases from the config file
+// at filePath. A missing file leaves it off.
+func LoadRequirePinnedBases(filePath string) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Sdkr struct {
+			RequirePinnedBases bool `yaml:"requirePinnedBases"`
+		} `yaml:"sdkr"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return false, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Sdkr.RequirePinnedBases, nil
+}
+
+// expandWebhooksEnv expands ${VAR} references in the webhook URLs, secrets
+// and headers, which usually carry tokens.
+func expandWebhooksEnv(hooks []WebhookConfig) {
+	for i := range hooks {
+		hooks[i].URL = expandBracedEnv(hooks[i].URL)
+		hooks[i].Secret = expandBracedEnv(hooks[i].Secret)
+		for k, v := range hooks[i].Headers {
+			hooks[i].Headers[k] = expandBracedEnv(v)
+		}
+	}
+}
+
+// expandNotificationsEnv expands ${VAR} references in the addresses and
+// credentials of notification sinks, e.g. a Slack webhook URL kept in a CI
+// secret.
+func expandNotificationsEnv(notifications []NotificationConfig) {
+	for _, n := range notifications {
+		if n.Slack != nil {
+			n.Slack.WebhookURL = expandBracedEnv(n.Slack.WebhookURL)
+		}
+		if n.Webhook != nil {
+			n.Webhook.URL = expandBracedEnv(n.Webhook.URL)
+			n.Webhook.Secret = expandBracedEnv(n.Webhook.Secret)
+			for k, v := range n.Webhook.Headers {
+				n.Webhook.Headers[k] = expandBracedEnv(v)
+			}
+		}
+		if n.Email != nil {
+			n.Email.Host = expandBracedEnv(n.Email.Host)
+			n.Email.Username = expandBracedEnv(n.Email.Username)
+			n.Email.Password = expandBracedEnv(n.Email.Password)
+		}
+	}
+}
+
+// expandHealthChecksEnv expands ${VAR} references in health check URLs and
+// headers, e.g. a per-environment host or a bearer token.
+func expandHealthChecksEnv(checks []HealthCheck) {
+	for i := range checks {
+		checks[i].URL = expandBracedEnv(checks[i].URL)
+		for k, v := range checks[i].Headers {
+			checks[i].Headers[k] = expandBracedEnv(v)
+		}
+	}
+}
+
+// LoadWebhooks reads sdkr.webhooks from smurf.yaml. A missing file means no
+// webhooks are configured.
+func LoadWebhooks(filePath string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Sdkr struct {
+			Webhooks []WebhookConfig `yaml:"webhooks"`
+		} `yaml:"sdkr"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	expandWebhooksEnv(config.Sdkr.Webhooks)
+	return config.Sdkr.Webhooks, nil
+}
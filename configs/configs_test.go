@@ -157,3 +157,26 @@ func TestExpandBracedEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadSdkrBuildCache(t *testing.T) {
+	t.Setenv("CACHE_REPO", "registry.example.com/app")
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	data := "sdkr:\n  cacheFrom:\n    - type=registry,ref=${CACHE_REPO}:cache\n  cacheTo:\n    - type=registry,ref=${CACHE_REPO}:cache,mode=max\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	from, to, err := LoadSdkrBuildCache(path)
+	if err != nil {
+		t.Fatalf("LoadSdkrBuildCache: %v", err)
+	}
+	if len(from) != 1 || from[0] != "type=registry,ref=registry.example.com/app:cache" {
+		t.Errorf("cacheFrom = %q", from)
+	}
+	if len(to) != 1 || to[0] != "type=registry,ref=registry.example.com/app:cache,mode=max" {
+		t.Errorf("cacheTo = %q", to)
+	}
+
+	if from, to, err := LoadSdkrBuildCache(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || from != nil || to != nil {
+		t.Errorf("a missing file must mean no build cache, got %q, %q, %v", from, to, err)
+	}
+}
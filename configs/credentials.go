@@ -0,0 +1,309 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CredentialSource is where a credential was found.
+type CredentialSource string
+
+const (
+	SourceNone     CredentialSource = ""
+	SourceFlag     CredentialSource = "flag"
+	SourceEnv      CredentialSource = "environment"
+	SourceConfig   CredentialSource = "config file"
+	SourcePlatform CredentialSource = "platform default"
+)
+
+// Credentials resolves the credentials of the registry providers. Each value
+// is taken from the first of these that has it:
+//
+//  1. a command-line flag
+//  2. the environment (DOCKER_USERNAME, GITHUB_TOKEN, AWS_ACCESS_KEY_ID, ...)
+//  3. the config file: smurf.yaml over the user defaults in ~/.smurf/config.yaml
+//  4. the platform default: the provider's own credential chain, such as the
+//     AWS shared config, gcloud application default credentials or az login
+//
+// A username and its password, or an access key and its secret, are taken
+// from the same place: a pair is never put together from halves found in
+// different places.
+type Credentials struct {
+	Flags  CredentialFlags
+	Config *SdkrConfig // nil without a config file
+}
+
+// CredentialFlags are the flags of the current command that override the
+// environment and the config file. Commands set the ones they have.
+type CredentialFlags struct {
+	AWSRegion           string // the region of the ECR image reference
+	GCPProjectID        string // --project-id
+	AzureSubscriptionID string // --subscription-id
+	AzureResourceGroup  string // --resource-group
+	AzureRegistryName   string // --registry-name
+}
+
+// NewCredentials returns the resolver of flags over the environment over cfg,
+// the sdkr section of an already loaded config file. cfg may be nil.
+func NewCredentials(flags CredentialFlags, cfg *SdkrConfig) *Credentials {
+	return &Credentials{Flags: flags, Config: cfg}
+}
+
+// LoadCredentials returns the resolver of flags over the environment over
+// smurf.yaml. Without smurf.yaml the user defaults alone are the config file.
+func LoadCredentials(flags CredentialFlags) (*Credentials, error) {
+	if _, err := os.Stat(FileName); err == nil {
+		cfg, err := LoadConfig(FileName)
+		if err != nil {
+			return nil, err
+		}
+		return NewCredentials(flags, &cfg.Sdkr), nil
+	}
+	user, err := LoadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	expandConfigEnv(&user.Config)
+	return NewCredentials(flags, &user.Sdkr), nil
+}
+
+func (c *Credentials) config() SdkrConfig {
+	if c == nil || c.Config == nil {
+		return SdkrConfig{}
+	}
+	return *c.Config
+}
+
+// first returns the first non-empty value and its source, trying the flag,
+// the environment variables in order, then the config file.
+func first(flag string, envs []string, config string) (string, CredentialSource) {
+	if flag != "" {
+		return flag, SourceFlag
+	}
+	for _, name := range envs {
+		if v := os.Getenv(name); v != "" {
+			return v, SourceEnv
+		}
+	}
+	if config != "" {
+		return config, SourceConfig
+	}
+	return "", SourceNone
+}
+
+// pair returns the first complete pair of the environment and the config
+// file. When neither is complete, it returns the first one that has a half,
+// so the caller can tell which half is missing and where.
+func pair(userEnv, secretEnv, configUser, configSecret string) (string, string, CredentialSource) {
+	envUser, envSecret := os.Getenv(userEnv), os.Getenv(secretEnv)
+	switch {
+	case envUser != "" && envSecret != "":
+		return envUser, envSecret, SourceEnv
+	case configUser != "" && configSecret != "":
+		return configUser, configSecret, SourceConfig
+	case envUser != "" || envSecret != "":
+		return envUser, envSecret, SourceEnv
+	case configUser != "" || configSecret != "":
+		return configUser, configSecret, SourceConfig
+	}
+	return "", "", SourceNone
+}
+
+// exportFrom sets vars in the environment, for the SDKs and registry clients
+// that read their credentials from it, unless they were found there.
+func exportFrom(source CredentialSource, vars map[string]string) error {
+	if source == SourceEnv || source == SourceNone {
+		return nil
+	}
+	set := map[string]string{}
+	for k, v := range vars {
+		if v != "" {
+			set[k] = v
+		}
+	}
+	return ExportEnvironmentVariables(set)
+}
+
+// missingPair reports the halves of a pair that are not set.
+func missingPair(provider string, source CredentialSource, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if source == SourceNone {
+		return fmt.Errorf("missing required %s credentials", provider)
+	}
+	return fmt.Errorf("missing required %s credentials: %v not set in the %s", provider, names, source)
+}
+
+// DockerHubCredentials authenticate to Docker Hub: DOCKER_USERNAME and
+// DOCKER_PASSWORD, or sdkr.docker_username and sdkr.docker_password.
+type DockerHubCredentials struct {
+	Username string
+	Password string
+	Source   CredentialSource
+}
+
+// DockerHub resolves the Docker Hub credentials.
+func (c *Credentials) DockerHub() DockerHubCredentials {
+	cfg := c.config()
+	user, pass, source := pair("DOCKER_USERNAME", "DOCKER_PASSWORD", cfg.DockerUsername, cfg.DockerPassword)
+	return DockerHubCredentials{Username: user, Password: pass, Source: source}
+}
+
+// Validate reports a missing username or password.
+func (d DockerHubCredentials) Validate() error {
+	var missing []string
+	if d.Username == "" {
+		missing = append(missing, "username")
+	}
+	if d.Password == "" {
+		missing = append(missing, "password")
+	}
+	return missingPair("Docker Hub", d.Source, missing...)
+}
+
+// Export sets DOCKER_USERNAME and DOCKER_PASSWORD, which the push reads.
+func (d DockerHubCredentials) Export() error {
+	return exportFrom(d.Source, map[string]string{"DOCKER_USERNAME": d.Username, "DOCKER_PASSWORD": d.Password})
+}
+
+// GHCRCredentials authenticate to GitHub Container Registry: GITHUB_USERNAME
+// and GITHUB_TOKEN, or sdkr.github_username and sdkr.github_token.
+type GHCRCredentials struct {
+	Username string
+	Token    string
+	Source   CredentialSource
+}
+
+// GHCR resolves the GitHub Container Registry credentials.
+func (c *Credentials) GHCR() GHCRCredentials {
+	cfg := c.config()
+	user, token, source := pair("GITHUB_USERNAME", "GITHUB_TOKEN", cfg.GithubUsername, cfg.GithubToken)
+	return GHCRCredentials{Username: user, Token: token, Source: source}
+}
+
+// Validate reports a missing username or token.
+func (g GHCRCredentials) Validate() error {
+	var missing []string
+	if g.Username == "" {
+		missing = append(missing, "username")
+	}
+	if g.Token == "" {
+		missing = append(missing, "token")
+	}
+	return missingPair("GitHub Container Registry", g.Source, missing...)
+}
+
+// Export sets GITHUB_USERNAME and GITHUB_TOKEN, which the push reads.
+func (g GHCRCredentials) Export() error {
+	return exportFrom(g.Source, map[string]string{"GITHUB_USERNAME": g.Username, "GITHUB_TOKEN": g.Token})
+}
+
+// AWSCredentials authenticate to AWS. Without an access key in the
+// environment or the config file (sdkr.awsAccessKey and sdkr.awsSecretKey),
+// the AWS SDK's default chain is used: AWS_PROFILE, the shared config, SSO,
+// or the instance or task role.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	Source          CredentialSource // of the access key
+}
+
+// AWS resolves the AWS credentials. The region is the flag's, AWS_REGION or
+// AWS_DEFAULT_REGION, or sdkr.awsRegion.
+func (c *Credentials) AWS() AWSCredentials {
+	cfg := c.config()
+	a := AWSCredentials{}
+	a.Region, _ = first(c.Flags.AWSRegion, []string{"AWS_REGION", "AWS_DEFAULT_REGION"}, cfg.AwsRegion)
+	if os.Getenv("AWS_PROFILE") != "" {
+		// A profile chosen in the environment wins over keys in the config file.
+		a.Source = SourceEnv
+		return a
+	}
+	a.AccessKeyID, a.SecretAccessKey, a.Source = pair("AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", cfg.AwsAccessKey, cfg.AwsSecretKey)
+	if a.Source == SourceNone {
+		a.Source = SourcePlatform
+	}
+	return a
+}
+
+// Validate reports an access key without its secret, or the other way round.
+func (a AWSCredentials) Validate() error {
+	switch {
+	case a.AccessKeyID != "" && a.SecretAccessKey == "":
+		return missingPair("AWS", a.Source, "secret access key")
+	case a.AccessKeyID == "" && a.SecretAccessKey != "":
+		return missingPair("AWS", a.Source, "access key ID")
+	}
+	return nil
+}
+
+// Export sets AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY from the config
+// file, for the AWS SDK.
+func (a AWSCredentials) Export() error {
+	return exportFrom(a.Source, map[string]string{"AWS_ACCESS_KEY_ID": a.AccessKeyID, "AWS_SECRET_ACCESS_KEY": a.SecretAccessKey})
+}
+
+// GCPCredentials authenticate to Google Cloud with a service account key
+// file, GOOGLE_APPLICATION_CREDENTIALS or sdkr.google_application_credentials.
+// Without one, the application default credentials of gcloud are used.
+type GCPCredentials struct {
+	ProjectID       string
+	CredentialsFile string
+	Source          CredentialSource // of the key file
+}
+
+// GCP resolves the Google Cloud credentials. The project is the flag's,
+// GOOGLE_CLOUD_PROJECT, or sdkr.provisionGcrProjectID.
+func (c *Credentials) GCP() GCPCredentials {
+	cfg := c.config()
+	g := GCPCredentials{}
+	g.ProjectID, _ = first(c.Flags.GCPProjectID, []string{"GOOGLE_CLOUD_PROJECT"}, cfg.ProvisionGcrProjectID)
+	g.CredentialsFile, g.Source = first("", []string{"GOOGLE_APPLICATION_CREDENTIALS"}, cfg.GoogleApplicationCredentials)
+	if g.Source == SourceNone {
+		g.Source = SourcePlatform
+	}
+	return g
+}
+
+// Export sets GOOGLE_APPLICATION_CREDENTIALS from the config file, for the
+// Google Cloud clients.
+func (g GCPCredentials) Export() error {
+	return exportFrom(g.Source, map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": g.CredentialsFile})
+}
+
+// AzureCredentials locate an Azure Container Registry. Authentication is
+// Azure's default chain: AZURE_CLIENT_ID and the other service principal
+// variables, a managed identity, or az login.
+type AzureCredentials struct {
+	SubscriptionID string
+	ResourceGroup  string
+	RegistryName   string
+	Source         CredentialSource // of the identity
+}
+
+// Azure resolves the Azure registry. The subscription is the flag's,
+// AZURE_SUBSCRIPTION_ID, or sdkr.provisionAcrSubscriptionID; the resource
+// group and registry are the flags' or sdkr.provisionAcrResourceGroup and
+// sdkr.provisionAcrRegistryName.
+func (c *Credentials) Azure() AzureCredentials {
+	cfg := c.config()
+	a := AzureCredentials{Source: SourcePlatform}
+	if os.Getenv("AZURE_CLIENT_ID") != "" {
+		a.Source = SourceEnv
+	}
+	a.SubscriptionID, _ = first(c.Flags.AzureSubscriptionID, []string{"AZURE_SUBSCRIPTION_ID"}, cfg.ProvisionAcrSubscriptionID)
+	a.ResourceGroup, _ = first(c.Flags.AzureResourceGroup, nil, cfg.ProvisionAcrResourceGroup)
+	a.RegistryName, _ = first(c.Flags.AzureRegistryName, nil, cfg.ProvisionAcrRegistryName)
+	return a
+}
+
+// Validate reports a missing subscription, resource group or registry.
+func (a AzureCredentials) Validate() error {
+	if a.SubscriptionID == "" || a.ResourceGroup == "" || a.RegistryName == "" {
+		return errors.New("missing required ACR parameters: subscription ID, resource group and registry name")
+	}
+	return nil
+}
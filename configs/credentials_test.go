@@ -0,0 +1,131 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// clearCredentialEnv unsets the variables the resolver reads, for the test.
+func clearCredentialEnv(t *testing.T) {
+	for _, name := range []string{
+		"DOCKER_USERNAME", "DOCKER_PASSWORD", "GITHUB_USERNAME", "GITHUB_TOKEN",
+		"AWS_PROFILE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"GOOGLE_APPLICATION_CREDENTIALS", "GOOGLE_CLOUD_PROJECT", "AZURE_CLIENT_ID", "AZURE_SUBSCRIPTION_ID",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestCredentialsPairPrecedence(t *testing.T) {
+	cfg := &SdkrConfig{DockerUsername: "cfg-user", DockerPassword: "cfg-pass"}
+	tests := []struct {
+		name       string
+		env        map[string]string
+		config     *SdkrConfig
+		wantUser   string
+		wantPass   string
+		wantSource CredentialSource
+		wantErr    bool
+	}{
+		{name: "environment over config", env: map[string]string{"DOCKER_USERNAME": "env-user", "DOCKER_PASSWORD": "env-pass"}, config: cfg,
+			wantUser: "env-user", wantPass: "env-pass", wantSource: SourceEnv},
+		{name: "config", config: cfg, wantUser: "cfg-user", wantPass: "cfg-pass", wantSource: SourceConfig},
+		{name: "half pair in the environment falls back to the config", env: map[string]string{"DOCKER_USERNAME": "env-user"}, config: cfg,
+			wantUser: "cfg-user", wantPass: "cfg-pass", wantSource: SourceConfig},
+		{name: "half pair only", env: map[string]string{"DOCKER_USERNAME": "env-user"},
+			wantUser: "env-user", wantSource: SourceEnv, wantErr: true},
+		{name: "none", wantSource: SourceNone, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCredentialEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got := NewCredentials(CredentialFlags{}, tt.config).DockerHub()
+			if got.Username != tt.wantUser || got.Password != tt.wantPass || got.Source != tt.wantSource {
+				t.Errorf("DockerHub() = %+v, want %s/%s from %q", got, tt.wantUser, tt.wantPass, tt.wantSource)
+			}
+			if err := got.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredentialsExport(t *testing.T) {
+	clearCredentialEnv(t)
+	ghcr := NewCredentials(CredentialFlags{}, &SdkrConfig{GithubUsername: "octo", GithubToken: "tok"}).GHCR()
+	if err := ghcr.Export(); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("GITHUB_USERNAME") != "octo" || os.Getenv("GITHUB_TOKEN") != "tok" {
+		t.Errorf("GITHUB_USERNAME, GITHUB_TOKEN = %q, %q, want the config's", os.Getenv("GITHUB_USERNAME"), os.Getenv("GITHUB_TOKEN"))
+	}
+}
+
+func TestCredentialsAWS(t *testing.T) {
+	cfg := &SdkrConfig{AwsAccessKey: "AKIA", AwsSecretKey: "secret", AwsRegion: "eu-west-1"}
+
+	clearCredentialEnv(t)
+	aws := NewCredentials(CredentialFlags{AWSRegion: "us-east-1"}, cfg).AWS()
+	if aws.Region != "us-east-1" || aws.AccessKeyID != "AKIA" || aws.Source != SourceConfig {
+		t.Errorf("AWS() = %+v, want the flag's region and the config's key", aws)
+	}
+
+	t.Setenv("AWS_PROFILE", "prod")
+	if aws := NewCredentials(CredentialFlags{}, cfg).AWS(); aws.AccessKeyID != "" || aws.Source != SourceEnv || aws.Region != "eu-west-1" {
+		t.Errorf("AWS() with AWS_PROFILE = %+v, want the profile over the config's key", aws)
+	}
+
+	clearCredentialEnv(t)
+	if aws := NewCredentials(CredentialFlags{}, nil).AWS(); aws.Source != SourcePlatform || aws.Validate() != nil {
+		t.Errorf("AWS() without keys = %+v, want the platform default", aws)
+	}
+}
+
+func TestCredentialsGCPAndAzure(t *testing.T) {
+	clearCredentialEnv(t)
+	cfg := &SdkrConfig{
+		ProvisionGcrProjectID:        "cfg-project",
+		GoogleApplicationCredentials: "/keys/sa.json",
+		ProvisionAcrSubscriptionID:   "cfg-sub",
+		ProvisionAcrResourceGroup:    "rg",
+		ProvisionAcrRegistryName:     "reg",
+	}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+	gcp := NewCredentials(CredentialFlags{}, cfg).GCP()
+	if gcp.ProjectID != "env-project" || gcp.CredentialsFile != "/keys/sa.json" || gcp.Source != SourceConfig {
+		t.Errorf("GCP() = %+v, want the environment's project and the config's key file", gcp)
+	}
+
+	az := NewCredentials(CredentialFlags{AzureSubscriptionID: "flag-sub"}, cfg).Azure()
+	if az.SubscriptionID != "flag-sub" || az.ResourceGroup != "rg" || az.RegistryName != "reg" || az.Source != SourcePlatform {
+		t.Errorf("Azure() = %+v, want the flag's subscription and the config's registry", az)
+	}
+	if err := NewCredentials(CredentialFlags{}, nil).Azure().Validate(); err == nil {
+		t.Error("Azure().Validate() without a registry = nil, want an error")
+	}
+}
+
+func TestLoadCredentialsUserDefaults(t *testing.T) {
+	clearCredentialEnv(t)
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	t.Setenv("HUB_TOKEN", "from-env")
+	user := "sdkr:\n  docker_username: me\n  docker_password: ${HUB_TOKEN}\n"
+	if err := os.WriteFile(filepath.Join(home, UserConfigFile), []byte(user), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+
+	creds, err := LoadCredentials(CredentialFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hub := creds.DockerHub(); hub.Username != "me" || hub.Password != "from-env" || hub.Source != SourceConfig {
+		t.Errorf("DockerHub() = %+v, want the user defaults without smurf.yaml", hub)
+	}
+}
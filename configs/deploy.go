@@ -0,0 +1,100 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Validate reports a deploy policy smurf could not enforce: signed images
+// required without anyone to trust, or an identity that is neither a key
+// nor a complete keyless identity.
+func (d DeployConfig) Validate() error {
+	if d.RequireSignedImages && len(d.TrustedIdentities) == 0 {
+		return errors.New("deploy.requireSignedImages needs at least one deploy.trustedIdentities entry")
+	}
+	for i, id := range d.TrustedIdentities {
+		if err := id.Validate(); err != nil {
+			return fmt.Errorf("deploy.trustedIdentities[%d]: %w", i, err)
+		}
+	}
+	if !slices.Contains(CapacityCheckModes, d.CapacityCheck) && d.CapacityCheck != "" {
+		return fmt.Errorf("invalid deploy.capacityCheck %q: must be one of warn, fail, off", d.CapacityCheck)
+	}
+	if d.SlowdownThreshold != 0 && d.SlowdownThreshold <= 1 {
+		return fmt.Errorf("invalid deploy.slowdownThreshold %v: must be greater than 1", d.SlowdownThreshold)
+	}
+	for i, n := range d.Notifications {
+		if err := n.Validate(); err != nil {
+			return fmt.Errorf("deploy.notifications[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// DeployEvents are the deploy events notifications are sent on.
+var DeployEvents = []string{"started", "succeeded", "failed"}
+
+// Validate reports a notification with an unknown event, without a sink,
+// or with a sink that is missing its address.
+func (n NotificationConfig) Validate() error {
+	for _, e := range n.Events {
+		if !slices.Contains(DeployEvents, e) {
+			return fmt.Errorf("unknown event %q: must be one of %s", e, strings.Join(DeployEvents, ", "))
+		}
+	}
+	for e := range n.Templates {
+		if !slices.Contains(DeployEvents, e) {
+			return fmt.Errorf("template for unknown event %q: must be one of %s", e, strings.Join(DeployEvents, ", "))
+		}
+	}
+	if n.Slack == nil && n.Webhook == nil && n.Email == nil {
+		return errors.New("no sink: set slack, webhook or email")
+	}
+	if n.Slack != nil && n.Slack.WebhookURL == "" {
+		return errors.New("slack.webhookUrl is required")
+	}
+	if n.Webhook != nil && n.Webhook.URL == "" {
+		return errors.New("webhook.url is required")
+	}
+	if n.Email != nil && (n.Email.Host == "" || n.Email.From == "" || len(n.Email.To) == 0) {
+		return errors.New("email needs host, from and at least one to address")
+	}
+	return nil
+}
+
+// Sends reports whether the notification is sent on event.
+func (n NotificationConfig) Sends(event string) bool {
+	return len(n.Events) == 0 || slices.Contains(n.Events, event)
+}
+
+// CapacityCheckModes are the values of deploy.capacityCheck.
+var CapacityCheckModes = []string{"warn", "fail", "off"}
+
+// CapacityCheckMode returns deploy.capacityCheck, warn when it is not set.
+func (d DeployConfig) CapacityCheckMode() string {
+	if d.CapacityCheck == "" {
+		return "warn"
+	}
+	return d.CapacityCheck
+}
+
+// Validate reports an identity that is not exactly one of a key or an
+// issuer with a subject pattern.
+func (t TrustedIdentity) Validate() error {
+	keyless := t.Issuer != "" || t.Subject != ""
+	switch {
+	case t.Key != "" && keyless:
+		return errors.New("set either key or issuer and subject, not both")
+	case t.Key == "" && (t.Issuer == "" || t.Subject == ""):
+		return errors.New("set key, or both issuer and subject")
+	}
+	if t.Subject != "" {
+		if _, err := regexp.Compile(t.Subject); err != nil {
+			return fmt.Errorf("invalid subject pattern: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeployConfigValidate(t *testing.T) {
+	keyless := TrustedIdentity{Issuer: "https://token.actions.githubusercontent.com", Subject: "^https://github.com/my-org/"}
+	tests := []struct {
+		name   string
+		deploy DeployConfig
+		want   string
+	}{
+		{"off", DeployConfig{}, ""},
+		{"key", DeployConfig{RequireSignedImages: true, TrustedIdentities: []TrustedIdentity{{Key: "cosign.pub"}}}, ""},
+		{"keyless", DeployConfig{RequireSignedImages: true, TrustedIdentities: []TrustedIdentity{keyless}}, ""},
+		{"nobody trusted", DeployConfig{RequireSignedImages: true}, "at least one deploy.trustedIdentities"},
+		{"both", DeployConfig{TrustedIdentities: []TrustedIdentity{{Key: "cosign.pub", Issuer: keyless.Issuer}}}, "not both"},
+		{"no subject", DeployConfig{TrustedIdentities: []TrustedIdentity{{Issuer: keyless.Issuer}}}, "both issuer and subject"},
+		{"bad subject", DeployConfig{TrustedIdentities: []TrustedIdentity{{Issuer: keyless.Issuer, Subject: "("}}}, "invalid subject pattern"},
+		{"capacity check", DeployConfig{CapacityCheck: "fail"}, ""},
+		{"bad capacity check", DeployConfig{CapacityCheck: "strict"}, "invalid deploy.capacityCheck"},
+		{"notification", DeployConfig{Notifications: []NotificationConfig{{Events: []string{"failed"}, Slack: &SlackSink{WebhookURL: "https://hooks.slack.com/x"}}}}, ""},
+		{"notification without sink", DeployConfig{Notifications: []NotificationConfig{{Name: "team"}}}, "deploy.notifications[0]: no sink"},
+		{"notification event", DeployConfig{Notifications: []NotificationConfig{{Events: []string{"done"}, Webhook: &WebhookSink{URL: "https://cd.example"}}}}, `unknown event "done"`},
+		{"notification template", DeployConfig{Notifications: []NotificationConfig{{Templates: map[string]string{"done": "x"}, Webhook: &WebhookSink{URL: "https://cd.example"}}}}, `template for unknown event "done"`},
+		{"notification email", DeployConfig{Notifications: []NotificationConfig{{Email: &EmailSink{Host: "smtp.example.com"}}}}, "email needs host, from"},
+	}
+	for _, tt := range tests {
+		err := tt.deploy.Validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate() = %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCapacityCheckMode(t *testing.T) {
+	if got := (DeployConfig{}).CapacityCheckMode(); got != "warn" {
+		t.Errorf("default CapacityCheckMode() = %q, want warn", got)
+	}
+	if got := (DeployConfig{CapacityCheck: "off"}).CapacityCheckMode(); got != "off" {
+		t.Errorf("CapacityCheckMode() = %q, want off", got)
+	}
+}
@@ -0,0 +1,43 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// Validate reports a health check smurf could never pass: a URL that is not
+// http(s), an impossible status code or a body pattern that doesn't compile.
+func (c HealthCheck) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("health check %q: url must be an http:// or https:// URL", c.URL)
+	}
+	if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+		return fmt.Errorf("health check %s: invalid status %d", c.URL, c.Status)
+	}
+	if c.Body != "" {
+		if _, err := regexp.Compile(c.Body); err != nil {
+			return fmt.Errorf("health check %s: invalid body pattern: %w", c.URL, err)
+		}
+	}
+	return nil
+}
+
+// validateHealthChecks validates selm.healthChecks and the health checks of
+// selm.releases.
+func (s SelmConfig) validateHealthChecks() error {
+	for _, c := range s.HealthChecks {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("selm.healthChecks: %w", err)
+		}
+	}
+	for _, r := range s.Releases {
+		for _, c := range r.HealthChecks {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("selm.releases %s: %w", r.Name, err)
+			}
+		}
+	}
+	return nil
+}
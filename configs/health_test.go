@@ -0,0 +1,31 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHealthCheckValidate(t *testing.T) {
+	tests := []struct {
+		check HealthCheck
+		want  string
+	}{
+		{HealthCheck{URL: "https://app.example.com/healthz", Status: 204, Body: "ok|up"}, ""},
+		{HealthCheck{URL: "app.example.com/healthz"}, "must be an http:// or https:// URL"},
+		{HealthCheck{URL: "ftp://app.example.com"}, "must be an http:// or https:// URL"},
+		{HealthCheck{URL: "http://app", Status: 42}, "invalid status 42"},
+		{HealthCheck{URL: "http://app", Body: "("}, "invalid body pattern"},
+	}
+	for _, tt := range tests {
+		err := tt.check.Validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v, want nil", tt.check, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v) = %v, want it to contain %q", tt.check, err, tt.want)
+		}
+	}
+}
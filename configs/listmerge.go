@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Strategies of selm.listMerge for combining a list of a values layer with
+// the list at the same path in the layers before it.
+const (
+	// ListMergeReplace keeps only the later list, as Helm does.
+	ListMergeReplace = "replace"
+	// ListMergeAppend adds the later items after the earlier ones, skipping
+	// items already in the list.
+	ListMergeAppend = "append"
+	// ListMergeByName merges items with the same name key, the later one's
+	// keys winning, and appends items whose name is new.
+	ListMergeByName = "merge"
+)
+
+// ListMergeStrategies are the values of selm.listMerge.
+var ListMergeStrategies = []string{ListMergeReplace, ListMergeAppend, ListMergeByName}
+
+// ListMerge maps dotted values paths, e.g. env or app.extraVolumes, to the
+// strategy for the list at that path. A * segment matches any key.
+type ListMerge map[string]string
+
+// paths returns the paths of l in a stable order.
+func (l ListMerge) paths() []string {
+	paths := make([]string, 0, len(l))
+	for path := range l {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Validate checks every path has a known strategy.
+func (l ListMerge) Validate() error {
+	for _, path := range l.paths() {
+		if path == "" || slices.Contains(strings.Split(path, "."), "") {
+			return fmt.Errorf("invalid selm.listMerge path %q", path)
+		}
+		if !slices.Contains(ListMergeStrategies, l[path]) {
+			return fmt.Errorf("invalid selm.listMerge strategy %q for %s: must be one of %s",
+				l[path], path, strings.Join(ListMergeStrategies, ", "))
+		}
+	}
+	return nil
+}
+
+// Strategy returns the strategy of the list at path, replace when none
+// matches. A path without wildcards wins over one with them; of several
+// wildcard paths, the first in sorted order wins.
+func (l ListMerge) Strategy(path []string) string {
+	if s, ok := l[strings.Join(path, ".")]; ok {
+		return s
+	}
+	for _, pattern := range l.paths() {
+		segments := strings.Split(pattern, ".")
+		if len(segments) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range segments {
+			if seg != "*" && seg != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return l[pattern]
+		}
+	}
+	return ListMergeReplace
+}
+
+// LoadSelmListMerge reads selm.listMerge from smurf.yaml. A missing file
+// means lists are replaced, as Helm does.
+func LoadSelmListMerge(filePath string) (ListMerge, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Selm struct {
+			ListMerge ListMerge `yaml:"listMerge"`
+		} `yaml:"selm"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return config.Selm.ListMerge, config.Selm.ListMerge.Validate()
+}
@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListMergeStrategy(t *testing.T) {
+	l := ListMerge{"env": ListMergeByName, "*.extraVolumes": ListMergeAppend, "app.extraVolumes": ListMergeReplace}
+	for path, want := range map[string]string{
+		"env":                 ListMergeByName,
+		"worker.extraVolumes": ListMergeAppend,
+		"app.extraVolumes":    ListMergeReplace,
+		"extraVolumes":        ListMergeReplace,
+		"app.env":             ListMergeReplace,
+	} {
+		if got := l.Strategy(strings.Split(path, ".")); got != want {
+			t.Errorf("Strategy(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestLoadSelmListMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte("selm:\n  listMerge:\n    env: merge\n    extraVolumes: prepend\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSelmListMerge(path); err == nil {
+		t.Error("LoadSelmListMerge accepted the unknown strategy prepend")
+	}
+
+	if l, err := LoadSelmListMerge(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || l != nil {
+		t.Errorf("LoadSelmListMerge(missing file) = %v, %v, want nil, nil", l, err)
+	}
+}
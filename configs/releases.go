@@ -0,0 +1,107 @@
+package configs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DeployReleases returns the releases `smurf deploy` manages, in the order
+// they are deployed: every release comes after the releases it depends on,
+// and otherwise keeps its place in selm.releases. Without selm.releases it
+// is the single release of selm.releaseName (defaulting to the chart's base
+// name), which gets the pushed image. Namespaces default to selm.namespace,
+// then "default".
+func (s SelmConfig) DeployReleases() ([]ReleaseConfig, error) {
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	if len(s.Releases) == 0 {
+		name := s.ReleaseName
+		if name == "" && s.ChartName != "" {
+			name = filepath.Base(s.ChartName)
+		}
+		return []ReleaseConfig{{
+			Name:      name,
+			ChartName: s.ChartName,
+			Namespace: namespace,
+			FileName:  s.FileName,
+			Image:     true,
+
+			HealthChecks: s.HealthChecks,
+		}}, nil
+	}
+
+	byName := make(map[string]int, len(s.Releases))
+	releases := make([]ReleaseConfig, len(s.Releases))
+	for i, r := range s.Releases {
+		if r.Name == "" || r.ChartName == "" {
+			return nil, fmt.Errorf("selm.releases[%d]: name and chartName are required", i)
+		}
+		if _, dup := byName[r.Name]; dup {
+			return nil, fmt.Errorf("selm.releases: release %q is defined twice", r.Name)
+		}
+		if r.Namespace == "" {
+			r.Namespace = namespace
+		}
+		byName[r.Name] = i
+		releases[i] = r
+	}
+	for _, r := range releases {
+		for _, dep := range r.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("selm.releases: %s depends on unknown release %q", r.Name, dep)
+			}
+		}
+	}
+
+	// Depth-first over declaration order, so the result is stable and a
+	// release moves only as far forward as its dependencies require.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(releases))
+	ordered := make([]ReleaseConfig, 0, len(releases))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("selm.releases: dependency cycle %s -> %s", strings.Join(path, " -> "), releases[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, releases[i].Name)
+		for _, dep := range releases[i].DependsOn {
+			if err := visit(byName[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		ordered = append(ordered, releases[i])
+		return nil
+	}
+	for i := range releases {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// HasDependents reports whether any of releases depends on the release name.
+func HasDependents(releases []ReleaseConfig, name string) bool {
+	for _, r := range releases {
+		for _, dep := range r.DependsOn {
+			if dep == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func releaseNames(releases []ReleaseConfig) string {
+	names := make([]string, len(releases))
+	for i, r := range releases {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestDeployReleases_Single(t *testing.T) {
+	releases, err := SelmConfig{ChartName: "./charts/web"}.DeployReleases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 {
+		t.Fatalf("got %d releases, want 1", len(releases))
+	}
+	r := releases[0]
+	if r.Name != "web" || r.Namespace != "default" || !r.Image {
+		t.Errorf("release = %+v, want web in default with the image", r)
+	}
+}
+
+func TestDeployReleases_Order(t *testing.T) {
+	selm := SelmConfig{
+		Namespace: "prod",
+		Releases: []ReleaseConfig{
+			{Name: "app", ChartName: "./charts/app", DependsOn: []string{"migrate", "ingress"}},
+			{Name: "ingress", ChartName: "ingress-nginx/ingress-nginx", Namespace: "ingress"},
+			{Name: "migrate", ChartName: "./charts/migrate", DependsOn: []string{"db"}},
+			{Name: "db", ChartName: "bitnami/postgresql"},
+			{Name: "docs", ChartName: "./charts/docs"},
+		},
+	}
+	releases, err := selm.DeployReleases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := releaseNames(releases), "db,migrate,ingress,app,docs"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+	if releases[0].Namespace != "prod" || releases[2].Namespace != "ingress" {
+		t.Errorf("namespaces = %s, %s; want prod, ingress", releases[0].Namespace, releases[2].Namespace)
+	}
+	if !HasDependents(releases, "db") || HasDependents(releases, "app") {
+		t.Error("HasDependents: want db depended on and app not")
+	}
+}
+
+func TestDeployReleases_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases []ReleaseConfig
+		want     string
+	}{
+		{"cycle", []ReleaseConfig{
+			{Name: "a", ChartName: "c", DependsOn: []string{"b"}},
+			{Name: "b", ChartName: "c", DependsOn: []string{"a"}},
+		}, "dependency cycle a -> b -> a"},
+		{"unknown", []ReleaseConfig{{Name: "a", ChartName: "c", DependsOn: []string{"x"}}}, `unknown release "x"`},
+		{"duplicate", []ReleaseConfig{{Name: "a", ChartName: "c"}, {Name: "a", ChartName: "d"}}, "defined twice"},
+		{"no chart", []ReleaseConfig{{Name: "a"}}, "chartName are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SelmConfig{Releases: tt.releases}.DeployReleases()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
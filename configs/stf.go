@@ -0,0 +1,105 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// StfConfig is the `stf` section of smurf.yaml.
+type StfConfig struct {
+	// TerraformVersion pins the terraform version (or constraint, e.g.
+	// "~> 1.9") stf runs with, instead of the required_version of the
+	// configuration. See terraform.terraformBinary.
+	TerraformVersion string       `yaml:"terraformVersion"`
+	Isolation        RunIsolation `yaml:"isolation"`
+	// RunLog is where every apply is recorded for 'stf runs':
+	// s3://BUCKET/PREFIX, gs://BUCKET/PREFIX, dynamodb://TABLE or a local
+	// file. See terraform.openRunStore.
+	RunLog string `yaml:"runLog"`
+	// Env sets terraform variables per workspace, as TF_VAR_NAME: value.
+	// The "*" block applies to every workspace; the block of the selected
+	// workspace overrides it. See terraform.workspaceVars.
+	Env map[string]map[string]string `yaml:"env"`
+	// EnvSecrets are the values of Env that came from ${VAR} references or
+	// whose names look secret, for the logs to mask.
+	EnvSecrets []string `yaml:"-"`
+}
+
+// RunIsolation controls the environment terraform runs in, so several stf
+// runs against the same directory (parallel CI jobs in a monorepo) don't
+// share or clobber one .terraform directory.
+//
+// DataDir becomes TF_DATA_DIR; a relative path is resolved against the
+// terraform working directory. PluginCacheDir becomes TF_PLUGIN_CACHE_DIR and
+// is typically shared by every run on the machine. When EnvAllowlist is set,
+// only environment variables matching one of its patterns (e.g. AWS_*) plus
+// the few variables every process needs (PATH, HOME, ...) reach terraform.
+type RunIsolation struct {
+	DataDir        string   `yaml:"dataDir"`
+	PluginCacheDir string   `yaml:"pluginCacheDir"`
+	EnvAllowlist   []string `yaml:"envAllowlist"`
+}
+
+// Stf is the stf configuration in effect for the running command. The stf
+// commands replace it via LoadStfConfig before doing any work.
+var Stf StfConfig
+
+// LoadStfConfig reads the stf section of smurf.yaml. A missing file is not an
+// error and yields an empty configuration; ${VAR} references in the paths are
+// expanded so each CI job can get its own data directory.
+func LoadStfConfig(filePath string) (StfConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return StfConfig{}, nil
+		}
+		return StfConfig{}, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Stf StfConfig `yaml:"stf"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return StfConfig{}, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+
+	config.Stf.Isolation.DataDir = expandBracedEnv(config.Stf.Isolation.DataDir)
+	config.Stf.Isolation.PluginCacheDir = expandBracedEnv(config.Stf.Isolation.PluginCacheDir)
+	config.Stf.RunLog = expandBracedEnv(config.Stf.RunLog)
+	if err := config.Stf.expandEnv(); err != nil {
+		return StfConfig{}, err
+	}
+	return config.Stf, nil
+}
+
+// secretVarPattern matches variable names whose values are masked in logs
+// even when written in smurf.yaml directly.
+var secretVarPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|key|credential)`)
+
+// expandEnv checks that every stf.env entry is a TF_VAR_ variable, expands
+// ${VAR} references in the values and records the values to mask.
+func (c *StfConfig) expandEnv() error {
+	workspaces := make([]string, 0, len(c.Env))
+	for ws := range c.Env {
+		workspaces = append(workspaces, ws)
+	}
+	sort.Strings(workspaces)
+	for _, ws := range workspaces {
+		for name, value := range c.Env[ws] {
+			if !strings.HasPrefix(name, "TF_VAR_") || name == "TF_VAR_" {
+				return fmt.Errorf("stf.env.%s.%s: only TF_VAR_NAME variables can be set", ws, name)
+			}
+			expanded := expandBracedEnv(value)
+			if expanded != value || secretVarPattern.MatchString(name) {
+				c.EnvSecrets = append(c.EnvSecrets, expanded)
+			}
+			c.Env[ws][name] = expanded
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStfConfig(t *testing.T) {
+	cfg, err := LoadStfConfig(filepath.Join(t.TempDir(), "smurf.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error for a missing file: %v", err)
+	}
+	if cfg.Isolation.DataDir != "" || len(cfg.Isolation.EnvAllowlist) != 0 {
+		t.Errorf("config = %+v, want empty", cfg)
+	}
+
+	t.Setenv("TEST_SMURF_JOB_ID", "42")
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	content := `
+stf:
+  isolation:
+    dataDir: .terraform-${TEST_SMURF_JOB_ID}
+    pluginCacheDir: /var/cache/terraform
+    envAllowlist: ["AWS_*", "TF_TOKEN_*"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err = LoadStfConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Isolation.DataDir != ".terraform-42" {
+		t.Errorf("dataDir = %q, want .terraform-42", cfg.Isolation.DataDir)
+	}
+	if cfg.Isolation.PluginCacheDir != "/var/cache/terraform" || len(cfg.Isolation.EnvAllowlist) != 2 {
+		t.Errorf("config = %+v", cfg.Isolation)
+	}
+}
+
+func TestLoadStfConfigEnv(t *testing.T) {
+	t.Setenv("TEST_SMURF_DB_PASSWORD", "from-ci")
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	content := `
+stf:
+  env:
+    "*":
+      TF_VAR_region: us-east-1
+    prod:
+      TF_VAR_db_password: ${TEST_SMURF_DB_PASSWORD}
+      TF_VAR_api_token: literal-token
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadStfConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Env["prod"]["TF_VAR_db_password"]; got != "from-ci" {
+		t.Errorf("db_password = %q, want from-ci", got)
+	}
+	if len(cfg.EnvSecrets) != 2 {
+		t.Errorf("EnvSecrets = %v, want the expanded password and the token", cfg.EnvSecrets)
+	}
+
+	content = "stf:\n  env:\n    prod:\n      AWS_PROFILE: prod\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStfConfig(path); err == nil {
+		t.Error("LoadStfConfig accepted a variable that isn't TF_VAR_")
+	}
+}
@@ -0,0 +1,144 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TimeoutPolicy holds the per-phase timeouts, in seconds, used across sdkr,
+// selm and deploy. It is read from the top-level `timeouts` section of
+// smurf.yaml; any phase left unset (or 0) falls back to DefaultTimeouts, and
+// an explicit --timeout flag on a command still wins over both.
+type TimeoutPolicy struct {
+	Build     int `yaml:"build"`     // docker image builds
+	Push      int `yaml:"push"`      // registry pushes
+	HelmWait  int `yaml:"helmWait"`  // helm install/upgrade/rollback waits
+	Readiness int `yaml:"readiness"` // post-deploy workload readiness checks
+
+	// ProgressDeadlines overrides Readiness for the workloads of one kind.
+	ProgressDeadlines ProgressDeadlines `yaml:"progressDeadlines"`
+}
+
+// ProgressDeadlines are per-kind readiness timeouts, in seconds, so a slow
+// kind (a database StatefulSet, a migration Job) can be given longer
+// without raising the readiness timeout of everything else. A kind left
+// unset (or 0) uses the readiness timeout.
+type ProgressDeadlines struct {
+	Deployment  int `yaml:"deployment"`
+	StatefulSet int `yaml:"statefulSet"`
+	DaemonSet   int `yaml:"daemonSet"`
+	Job         int `yaml:"job"`
+}
+
+// DefaultTimeouts is the policy used when smurf.yaml doesn't override a phase.
+var DefaultTimeouts = TimeoutPolicy{
+	Build:     1500,
+	Push:      600,
+	HelmWait:  600,
+	Readiness: 300,
+}
+
+// Timeouts is the policy in effect for the running command. Commands that
+// honor smurf.yaml replace it via LoadTimeoutPolicy before doing any work.
+var Timeouts = DefaultTimeouts
+
+// WithDefaults returns p with every unset phase taken from defaults.
+func (p TimeoutPolicy) WithDefaults(defaults TimeoutPolicy) TimeoutPolicy {
+	if p.Build <= 0 {
+		p.Build = defaults.Build
+	}
+	if p.Push <= 0 {
+		p.Push = defaults.Push
+	}
+	if p.HelmWait <= 0 {
+		p.HelmWait = defaults.HelmWait
+	}
+	if p.Readiness <= 0 {
+		p.Readiness = defaults.Readiness
+	}
+	return p
+}
+
+// Validate rejects negative timeouts, which are always a typo.
+func (p TimeoutPolicy) Validate() error {
+	phases := []struct {
+		name  string
+		value int
+	}{
+		{"build", p.Build},
+		{"push", p.Push},
+		{"helmWait", p.HelmWait},
+		{"readiness", p.Readiness},
+		{"progressDeadlines.deployment", p.ProgressDeadlines.Deployment},
+		{"progressDeadlines.statefulSet", p.ProgressDeadlines.StatefulSet},
+		{"progressDeadlines.daemonSet", p.ProgressDeadlines.DaemonSet},
+		{"progressDeadlines.job", p.ProgressDeadlines.Job},
+	}
+	for _, phase := range phases {
+		if phase.value < 0 {
+			return fmt.Errorf("timeouts.%s must not be negative, got %d", phase.name, phase.value)
+		}
+	}
+	return nil
+}
+
+func (p TimeoutPolicy) BuildTimeout() time.Duration {
+	return time.Duration(p.Build) * time.Second
+}
+
+func (p TimeoutPolicy) PushTimeout() time.Duration {
+	return time.Duration(p.Push) * time.Second
+}
+
+func (p TimeoutPolicy) HelmWaitTimeout() time.Duration {
+	return time.Duration(p.HelmWait) * time.Second
+}
+
+func (p TimeoutPolicy) ReadinessTimeout() time.Duration {
+	return time.Duration(p.Readiness) * time.Second
+}
+
+// ProgressDeadline returns the readiness timeout for workloads of kind
+// (Deployment, StatefulSet, DaemonSet or Job), or fallback when
+// progressDeadlines doesn't set one for it.
+func (p TimeoutPolicy) ProgressDeadline(kind string, fallback time.Duration) time.Duration {
+	seconds := map[string]int{
+		"Deployment":  p.ProgressDeadlines.Deployment,
+		"StatefulSet": p.ProgressDeadlines.StatefulSet,
+		"DaemonSet":   p.ProgressDeadlines.DaemonSet,
+		"Job":         p.ProgressDeadlines.Job,
+	}[kind]
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// LoadTimeoutPolicy reads the `timeouts` section of the given smurf.yaml and
+// returns it merged over DefaultTimeouts. A missing file simply yields the
+// defaults, since most commands work without smurf.yaml; a file that exists
+// but can't be parsed or holds invalid timeouts is an error.
+func LoadTimeoutPolicy(filePath string) (TimeoutPolicy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultTimeouts, nil
+		}
+		return DefaultTimeouts, fmt.Errorf("unable to read the file %v", err)
+	}
+
+	var config struct {
+		Timeouts TimeoutPolicy `yaml:"timeouts"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return DefaultTimeouts, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	if err := config.Timeouts.Validate(); err != nil {
+		return DefaultTimeouts, err
+	}
+
+	return config.Timeouts.WithDefaults(DefaultTimeouts), nil
+}
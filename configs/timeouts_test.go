@@ -0,0 +1,95 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTimeoutPolicy_MissingFile(t *testing.T) {
+	p, err := LoadTimeoutPolicy(filepath.Join(t.TempDir(), "smurf.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p != DefaultTimeouts {
+		t.Errorf("policy = %+v, want defaults %+v", p, DefaultTimeouts)
+	}
+}
+
+func TestLoadTimeoutPolicy_PartialOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	content := `
+sdkr:
+  imageName: app:v1
+timeouts:
+  push: 900
+  readiness: 120
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadTimeoutPolicy(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := TimeoutPolicy{Build: DefaultTimeouts.Build, Push: 900, HelmWait: DefaultTimeouts.HelmWait, Readiness: 120}
+	if p != want {
+		t.Errorf("policy = %+v, want %+v", p, want)
+	}
+	if p.PushTimeout() != 15*time.Minute || p.ReadinessTimeout() != 2*time.Minute {
+		t.Errorf("durations = %v/%v, want 15m/2m", p.PushTimeout(), p.ReadinessTimeout())
+	}
+}
+
+func TestLoadTimeoutPolicy_Negative(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte("timeouts:\n  helmWait: -5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTimeoutPolicy(path); err == nil || !strings.Contains(err.Error(), "timeouts.helmWait") {
+		t.Errorf("err = %v, want a timeouts.helmWait error", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig must reject negative timeouts too")
+	}
+}
+
+func TestProgressDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	content := `
+timeouts:
+  readiness: 300
+  progressDeadlines:
+    statefulSet: 900
+    job: 1800
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadTimeoutPolicy(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fallback := p.ReadinessTimeout()
+	for kind, want := range map[string]time.Duration{
+		"Deployment":  5 * time.Minute,
+		"StatefulSet": 15 * time.Minute,
+		"Job":         30 * time.Minute,
+		"Pod":         5 * time.Minute,
+	} {
+		if got := p.ProgressDeadline(kind, fallback); got != want {
+			t.Errorf("ProgressDeadline(%s) = %v, want %v", kind, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("timeouts:\n  progressDeadlines:\n    job: -1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTimeoutPolicy(path); err == nil || !strings.Contains(err.Error(), "timeouts.progressDeadlines.job") {
+		t.Errorf("err = %v, want a timeouts.progressDeadlines.job error", err)
+	}
+}
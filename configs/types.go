@@ -1,5 +1,7 @@
 package configs
 
+import "time"
+
 // types for SDKR
 var (
 	DockerfilePath   string
@@ -18,6 +20,24 @@ var (
 	Region           string
 	Repository       string
 	UseGCR           bool
+
+	RequirePinnedBases bool // sdkr.requirePinnedBases: refuse to build on base images not pinned to a digest
+
+	RemoteBuild          string // --remote-build: k8s builds on a buildkitd in the cluster
+	RemoteBuildNamespace string // --remote-build-namespace: where that buildkitd runs
+	KeepRemoteBuilder    bool   // --keep-remote-builder: leave the buildkitd running after the build
+	Reproducible         bool   // --reproducible: build from SOURCE_DATE_EPOCH with normalized timestamps
+
+	BuildxPush bool     // --push: build with buildx and push from the builder
+	CacheFrom  []string // --cache-from or sdkr.cacheFrom: build cache sources to import
+	CacheTo    []string // --cache-to or sdkr.cacheTo: build cache destinations to export to
+
+	EcrScanFindings bool          // --scan-findings: wait for ECR's scan on push and report its findings
+	EcrScanTimeout  time.Duration // --scan-timeout: how long to wait for that scan
+	ScanFailOn      string        // --fail-on: fail on scan findings of this severity or higher
+
+	ScanBaselineDigest    string // digest of the previously deployed image, whose findings count as pre-existing
+	ScanFailOnNewCritical bool   // --fail-on-new-critical: fail on CRITICAL findings the baseline image doesn't have
 )
 
 // types for SELM
@@ -50,6 +70,26 @@ var (
 	PassCredentials bool
 	Devel           bool
 	Prov            bool
+	Interpolate     bool     // selm.interpolate: expand ${VAR} and {{ .Git.* }} in values
+	ValuesFrom      []string // --values-from: configmap|secret/NAMESPACE/NAME:KEY values documents
+	DependencyPaths []string // --dependency-path: NAME=PATH local chart dependency overrides
+
+	NoNamespaceDefaults  bool             // --no-namespace-defaults: ignore the namespace's smurf-defaults ConfigMap
+	NoHooks              bool             // --no-hooks: run no chart hooks on install/upgrade
+	HooksOnly            []string         // --hooks-only: run only the hooks of these events on install/upgrade
+	RedactKeys           []string         // selm.redactKeys: patterns of value keys masked in output
+	ExplainValues        bool             // --explain-values: report the source of every effective value
+	MigrateValues        bool             // --migrate-values: upgrade from the stored values, migrated to the new chart version
+	ValuesMigrations     string           // --values-migrations: migrations file applied after the chart's values-migrations.yaml
+	ValuesSchema         string           // --values-schema: JSON Schema the effective values must also meet
+	SkipSchemaValidation bool             // --skip-schema-validation: check the values against no schema
+	ShowSecrets          bool             // --show-secrets: print the values RedactKeys would mask
+	EphemeralTTL         time.Duration    // --ephemeral/--ttl: label the installed release for selm gc after this long
+	KubeConfig           string           // --kubeconfig: kubeconfig file of the selm commands
+	KubeContext          string           // --kube-context: context of the kubeconfig the selm commands target
+	Scheduling           SchedulingConfig // selm.scheduling: placement injected into every workload
+	ListMergePaths       ListMerge        // selm.listMerge: how values layers combine the lists at these paths
+	ReleaseBackup        BackupConfig     // selm.backup: where upgrades upload the release they replace
 )
 
 // Config struct to hold the configuration for the SDKR and SELM
@@ -57,8 +97,92 @@ var FileName = "smurf.yaml"
 
 // Config struct to hold the configuration for the SDKR and SELM
 type Config struct {
-	Sdkr SdkrConfig `yaml:"sdkr"`
-	Selm SelmConfig `yaml:"selm"`
+	Sdkr     SdkrConfig    `yaml:"sdkr"`
+	Selm     SelmConfig    `yaml:"selm"`
+	Stf      StfConfig     `yaml:"stf"`
+	Deploy   DeployConfig  `yaml:"deploy"`
+	Timeouts TimeoutPolicy `yaml:"timeouts"`
+}
+
+// DeployConfig holds the policies `smurf deploy` enforces before it hands a
+// release to Helm.
+type DeployConfig struct {
+	// RequireSignedImages makes deploy verify the cosign signature of every
+	// image the rendered charts reference, not only the image smurf built,
+	// against TrustedIdentities.
+	RequireSignedImages bool              `yaml:"requireSignedImages"`
+	TrustedIdentities   []TrustedIdentity `yaml:"trustedIdentities"`
+	AttestationType     string            `yaml:"attestationType"` // e.g. slsaprovenance; also require an attestation of this type
+	// CapacityCheck is what deploy does when the CPU and memory a rendered
+	// release requests don't fit in the cluster or the namespace quota:
+	// warn (the default), fail, or off to skip the check.
+	CapacityCheck string `yaml:"capacityCheck"`
+	// SlowdownThreshold is how many times the median of its recent runs a
+	// phase may take before deploy warns about a duration regression
+	// (default 1.5).
+	SlowdownThreshold float64 `yaml:"slowdownThreshold"`
+	// RollbackOnFailure undoes a run whose helm phase fails: the values
+	// files it wrote the image to are restored and the releases it touched
+	// are rolled back, or uninstalled when it installed them.
+	RollbackOnFailure bool `yaml:"rollbackOnFailure"`
+	// DeletePushedTag also deletes the tag the run pushed from the registry
+	// when it is rolled back. It implies RollbackOnFailure.
+	DeletePushedTag bool `yaml:"deletePushedTag"`
+	// Environment names the target of the deploy in notifications, e.g.
+	// production; the namespace of the release when it is not set.
+	Environment string `yaml:"environment"`
+	// Links are extra links, e.g. a dashboard, notification templates can
+	// use as {{.Links.NAME}}, next to the CI run's {{.Links.run}}.
+	Links map[string]string `yaml:"links"`
+	// Notifications are sent when a deploy starts, succeeds or fails.
+	Notifications []NotificationConfig `yaml:"notifications"`
+}
+
+// NotificationConfig sends a message to one or more sinks on some deploy
+// events. Messages are Go templates over the deploy's context.
+type NotificationConfig struct {
+	Name   string   `yaml:"name"`
+	Events []string `yaml:"events"` // started, succeeded, failed; all when empty
+	// Templates are the message templates per event; events without one
+	// get the built-in message.
+	Templates   map[string]string `yaml:"templates"`
+	Slack       *SlackSink        `yaml:"slack"`
+	Webhook     *WebhookSink      `yaml:"webhook"`
+	Email       *EmailSink        `yaml:"email"`
+	FailOnError bool              `yaml:"failOnError"` // fail the deploy when the notification can't be sent
+}
+
+// SlackSink posts notifications to a Slack incoming webhook.
+type SlackSink struct {
+	WebhookURL string `yaml:"webhookUrl"`
+	Channel    string `yaml:"channel"` // overrides the webhook's channel, where Slack allows it
+}
+
+// WebhookSink POSTs notifications as JSON, signed like sdkr.webhooks.
+type WebhookSink struct {
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret"` // HMAC-SHA256 key for X-Smurf-Signature-256
+	Headers map[string]string `yaml:"headers"`
+}
+
+// EmailSink mails notifications through an SMTP server.
+type EmailSink struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"` // 587 when not set
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"` // template; "[smurf] deploy EVENT: IMAGE" when not set
+}
+
+// TrustedIdentity is a signer whose cosign signatures deploy accepts: a
+// public key, or a keyless identity given by its OIDC issuer and a regular
+// expression for the certificate subject.
+type TrustedIdentity struct {
+	Key     string `yaml:"key"`     // cosign.pub path or KMS URI
+	Issuer  string `yaml:"issuer"`  // e.g. https://token.actions.githubusercontent.com
+	Subject string `yaml:"subject"` // e.g. ^https://github.com/my-org/
 }
 
 // types for SDKR in the config file
@@ -78,20 +202,154 @@ type SdkrConfig struct {
 	AwsSecretKey                 string `yaml:"awsSecretKey"`
 	AwsRegion                    string `yaml:"awsRegion"`
 	Dockerfile                   string `yaml:"dockerfile"`
+	Builder                      string `yaml:"builder"`            // docker (default), containerd or buildkitd
+	RequirePinnedBases           bool   `yaml:"requirePinnedBases"` // fail builds whose FROM images are not pinned to a digest
 	AwsECR                       bool   `yaml:"awsECR"`
 	DockerHub                    bool   `yaml:"dockerHub"`
 	GHCRRepo                     bool   `yaml:"ghcrRepo"`
 	GCPRepo                      bool   `yaml:"gcpRepo"`
+	GCPRegion                    string `yaml:"gcpRegion"`     // Artifact Registry location of gcpRepo pushes
+	GCPRepository                string `yaml:"gcpRepository"` // Artifact Registry repository of gcpRepo pushes
+	AzureACR                     bool   `yaml:"azureACR"`      // push to the provisionAcr* registry
+
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+	// SearchRegistries are the registries `sdkr search` searches without
+	// --registry, each a host with an optional namespace (ghcr.io/my-org).
+	SearchRegistries []string `yaml:"searchRegistries"`
+	// Registries are the repositories `sdkr push-multi` pushes the image to
+	// besides its --to targets (ghcr.io/my-org/myapp); an entry with a tag
+	// is pushed as is.
+	Registries []string `yaml:"registries"`
+	// CacheFrom and CacheTo are the build cache to import and export
+	// without --cache-from and --cache-to, as buildx cache specifications
+	// (type=registry,ref=REPO:buildcache).
+	CacheFrom []string `yaml:"cacheFrom"`
+	CacheTo   []string `yaml:"cacheTo"`
+}
+
+// WebhookConfig is a post-push trigger: after every successful push smurf
+// POSTs the image reference and digest to URL, so CD systems (Argo CD Image
+// Updater, Jenkins, ...) can react without extra pipeline glue.
+type WebhookConfig struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Secret      string            `yaml:"secret"`      // HMAC-SHA256 key for X-Smurf-Signature-256
+	Format      string            `yaml:"format"`      // smurf (default) or dockerhub
+	Headers     map[string]string `yaml:"headers"`     // extra request headers, e.g. Authorization
+	FailOnError bool              `yaml:"failOnError"` // fail the command when the call fails
 }
 
 // types for SELM in the config file
 type SelmConfig struct {
-	HelmDeploy  bool   `yaml:"deployHelm"`
-	ReleaseName string `yaml:"releaseName"`
-	Namespace   string `yaml:"namespace"`
-	ChartName   string `yaml:"chartName"`
-	FileName    string `yaml:"fileName"`
-	Revision    int    `yaml:"revision"`
+	HelmDeploy      bool     `yaml:"deployHelm"`
+	ReleaseName     string   `yaml:"releaseName"`
+	Namespace       string   `yaml:"namespace"`
+	ChartName       string   `yaml:"chartName"`
+	FileName        string   `yaml:"fileName"`
+	Revision        int      `yaml:"revision"`
+	Interpolate     bool     `yaml:"interpolate"`
+	ImagePullSecret string   `yaml:"imagePullSecret"` // pull secret deploy maintains from the push credentials
+	ValuesFrom      []string `yaml:"valuesFrom"`      // in-cluster values documents, as for --values-from
+	DependencyPaths []string `yaml:"dependencyPaths"` // local chart dependency overrides, as for --dependency-path
+	RedactKeys      []string `yaml:"redactKeys"`      // value keys masked in printed values, diffs and debug output
+
+	// ValuesSchema is a JSON Schema file the values of every release must
+	// meet on top of the chart's values.schema.json, as for --values-schema.
+	ValuesSchema string `yaml:"valuesSchema"`
+
+	// ListMerge picks, per values path, how a list in a later values file
+	// combines with the one before it instead of replacing it.
+	ListMerge ListMerge `yaml:"listMerge"`
+
+	// Scheduling is placement injected into every workload the charts
+	// render, for rules the charts don't parameterize.
+	Scheduling SchedulingConfig `yaml:"scheduling"`
+
+	// Backup uploads the values and manifest of a release to object storage
+	// before every upgrade replaces it.
+	Backup BackupConfig `yaml:"backup"`
+
+	// HealthChecks must pass after the release is deployed before the deploy
+	// counts as successful.
+	HealthChecks []HealthCheck `yaml:"healthChecks"`
+
+	Cluster ClusterConfig `yaml:"cluster"`
+
+	// Releases replaces the single release above when a deploy manages
+	// several, e.g. an app, its migration job and an ingress controller.
+	Releases []ReleaseConfig `yaml:"releases"`
+}
+
+// BackupConfig is where upgrades upload the release they are about to
+// replace: its values, manifest and chart, a copy off the cluster for when
+// the release history is lost or the cluster dies mid-upgrade.
+type BackupConfig struct {
+	// Location is s3://BUCKET/PREFIX, gs://BUCKET/PREFIX or
+	// azblob://ACCOUNT/CONTAINER/PREFIX. Nothing is uploaded when it is
+	// empty.
+	Location string `yaml:"location"`
+	// Required fails the upgrade when the backup cannot be uploaded, instead
+	// of warning and upgrading anyway.
+	Required bool `yaml:"required"`
+}
+
+// SchedulingConfig is the placement a platform enforces on every workload
+// smurf deploys. It is applied to the rendered manifests after Helm renders
+// them, so it reaches charts that expose no values for it. Tolerations and
+// topology spread constraints are written as in a pod spec.
+type SchedulingConfig struct {
+	// NodeSelector labels are set on every pod template, replacing the
+	// chart's value for the same label.
+	NodeSelector map[string]string `yaml:"nodeSelector"`
+	// Tolerations are added to those of the chart.
+	Tolerations []map[string]interface{} `yaml:"tolerations"`
+	// TopologySpreadConstraints replace the chart's constraint on the same
+	// topologyKey. One without a labelSelector spreads the pods of its
+	// workload, selected by the workload's own selector.
+	TopologySpreadConstraints []map[string]interface{} `yaml:"topologySpreadConstraints"`
+}
+
+// IsZero reports whether s injects nothing.
+func (s SchedulingConfig) IsZero() bool {
+	return len(s.NodeSelector) == 0 && len(s.Tolerations) == 0 && len(s.TopologySpreadConstraints) == 0
+}
+
+// ReleaseConfig is one release of a multi-release deploy. Releases are
+// installed or upgraded after the releases they depend on are ready, and
+// removed in the reverse order by `smurf deploy destroy`.
+type ReleaseConfig struct {
+	Name      string   `yaml:"name"`
+	ChartName string   `yaml:"chartName"`
+	Namespace string   `yaml:"namespace"` // defaults to selm.namespace
+	FileName  string   `yaml:"fileName"`  // values file, as selm.fileName
+	Set       []string `yaml:"set"`       // --set style overrides for this release
+	DependsOn []string `yaml:"dependsOn"` // names of releases to deploy first
+	Image     bool     `yaml:"image"`     // write the pushed image into this release's values
+
+	HealthChecks []HealthCheck `yaml:"healthChecks"` // as selm.healthChecks, for this release
+}
+
+// HealthCheck is an HTTP(S) endpoint polled after a deploy. It passes once
+// it answers with the expected status and, when Body is set, a body that
+// matches it; Kubernetes readiness alone misses an app that is up but broken.
+type HealthCheck struct {
+	URL     string            `yaml:"url"`
+	Status  int               `yaml:"status"`  // expected status code, 200 when unset
+	Body    string            `yaml:"body"`    // regular expression the response body must match
+	Headers map[string]string `yaml:"headers"` // extra request headers, e.g. Authorization
+}
+
+// ClusterConfig names a managed Kubernetes cluster by its cloud identity.
+// When set, `smurf deploy` fetches its credentials and switches the
+// kubeconfig to it before deploying, as `smurf selm connect` does.
+type ClusterConfig struct {
+	Provider       string `yaml:"provider"` // eks, gke or aks
+	Name           string `yaml:"name"`
+	Region         string `yaml:"region"`         // AWS region or GKE location
+	Project        string `yaml:"project"`        // GCP project (gke)
+	ResourceGroup  string `yaml:"resourceGroup"`  // Azure resource group (aks)
+	SubscriptionID string `yaml:"subscriptionId"` // Azure subscription (aks)
+	Context        string `yaml:"context"`        // kubeconfig context name to write
 }
 
 // InitOptions represents all options for Terraform init
@@ -0,0 +1,300 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/utils"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// UserConfigFile is the name of the user configuration file in the smurf
+// home directory (~/.smurf, or $SMURF_HOME).
+const UserConfigFile = "config.yaml"
+
+// UserConfig is the user configuration. Its smurf.yaml sections (sdkr, selm,
+// stf, deploy, timeouts) are defaults merged beneath the smurf.yaml of every
+// project; the other settings only make sense per user.
+type UserConfig struct {
+	Config `yaml:",inline"`
+	AI     UserAIConfig `yaml:"ai"`
+	// Color is when output is colored: auto (only on a terminal), always
+	// or never. Unset leaves it colored, as without a user configuration.
+	Color string `yaml:"color"`
+	// PluginPaths are the directories Helm plugins are looked up in and
+	// installed to (HELM_PLUGINS), unless HELM_PLUGINS is set.
+	PluginPaths []string `yaml:"pluginPaths"`
+	// Telemetry opts in to anonymous usage reporting. smurf reports nothing
+	// today; the setting is kept for when it does, and defaults to off.
+	Telemetry bool `yaml:"telemetry"`
+}
+
+// UserAIConfig selects the AI provider and model --ai explains errors with.
+type UserAIConfig struct {
+	Provider string `yaml:"provider"` // openai, the only one for now
+	Model    string `yaml:"model"`    // OPENAI_MODEL, unless it is set
+}
+
+// ColorModes are the values of color in the user configuration.
+var ColorModes = []string{"auto", "always", "never"}
+
+// AIProviders are the values of ai.provider in the user configuration.
+var AIProviders = []string{"openai"}
+
+// Validate reports a user configuration smurf could not apply.
+func (u *UserConfig) Validate() error {
+	if u.Color != "" && !slices.Contains(ColorModes, u.Color) {
+		return fmt.Errorf("invalid color %q: must be one of %s", u.Color, strings.Join(ColorModes, ", "))
+	}
+	if u.AI.Provider != "" && !slices.Contains(AIProviders, u.AI.Provider) {
+		return fmt.Errorf("invalid ai.provider %q: must be one of %s", u.AI.Provider, strings.Join(AIProviders, ", "))
+	}
+	if err := u.Timeouts.Validate(); err != nil {
+		return err
+	}
+	return u.Deploy.Validate()
+}
+
+// UserConfigPath returns the path of the user configuration file.
+func UserConfigPath() (string, error) {
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, UserConfigFile), nil
+}
+
+// readUserConfig returns the content of the user configuration file, or nil
+// when there is none.
+func readUserConfig() ([]byte, string, error) {
+	path, err := UserConfigPath()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, path, nil
+		}
+		return nil, path, fmt.Errorf("unable to read the file %v", err)
+	}
+	return data, path, nil
+}
+
+// LoadUserConfig reads the user configuration. A missing file is an empty
+// configuration; a key smurf doesn't know is an error, so that a typo is
+// not silently ignored.
+func LoadUserConfig() (*UserConfig, error) {
+	data, path, err := readUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	return parseUserConfig(data, path)
+}
+
+func parseUserConfig(data []byte, path string) (*UserConfig, error) {
+	var u UserConfig
+	if err := yaml.UnmarshalStrict(data, &u); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %s: %v", path, err)
+	}
+	if err := u.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &u, nil
+}
+
+// withUserDefaults merges the project configuration data over the user
+// configuration: a key set in the project wins, maps are merged key by key,
+// and lists are replaced whole.
+func withUserDefaults(data []byte) ([]byte, error) {
+	user, path, err := readUserConfig()
+	if err != nil || len(bytes.TrimSpace(user)) == 0 {
+		return data, err
+	}
+	if _, err := parseUserConfig(user, path); err != nil {
+		return nil, err
+	}
+	var base, project map[interface{}]interface{}
+	if err := yaml.Unmarshal(user, &base); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	return yaml.Marshal(mergeYAMLMaps(base, project))
+}
+
+// mergeYAMLMaps returns base with over merged into it.
+func mergeYAMLMaps(base, over map[interface{}]interface{}) map[interface{}]interface{} {
+	merged := make(map[interface{}]interface{}, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		if sub, ok := v.(map[interface{}]interface{}); ok {
+			if baseSub, ok := merged[k].(map[interface{}]interface{}); ok {
+				merged[k] = mergeYAMLMaps(baseSub, sub)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// UserConfigValue returns the value of key, a dotted path such as
+// selm.namespace, in the user configuration as YAML, and whether it is set.
+// An empty key returns the whole file.
+func UserConfigValue(key string) (string, bool, error) {
+	data, _, err := readUserConfig()
+	if err != nil {
+		return "", false, err
+	}
+	if key == "" {
+		return strings.TrimSuffix(string(data), "\n"), len(data) > 0, nil
+	}
+	doc, err := userConfigNode(data)
+	if err != nil {
+		return "", false, err
+	}
+	node := doc.Content[0]
+	for _, name := range strings.Split(key, ".") {
+		_, node = mappingEntry(node, name)
+		if node == nil {
+			return "", false, nil
+		}
+	}
+	if node.Kind == yamlv3.ScalarNode {
+		return node.Value, true, nil
+	}
+	out, err := yamlv3.Marshal(node)
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+// SetUserConfigValue sets key, a dotted path such as selm.namespace, to
+// value in the user configuration file, creating the file when needed.
+// value is parsed as YAML, so true is a boolean and [a, b] a list. The
+// other keys, and the comments of the file, are kept. The result must be a
+// valid user configuration.
+func SetUserConfigValue(key, value string) error {
+	return editUserConfig(key, func(parent *yamlv3.Node, name string) error {
+		var parsed yamlv3.Node
+		if err := yamlv3.Unmarshal([]byte(value), &parsed); err != nil || len(parsed.Content) == 0 {
+			parsed = yamlv3.Node{Content: []*yamlv3.Node{{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: value}}}
+		}
+		if i, _ := mappingEntry(parent, name); i >= 0 {
+			parent.Content[i+1] = parsed.Content[0]
+			return nil
+		}
+		parent.Content = append(parent.Content,
+			&yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: name}, parsed.Content[0])
+		return nil
+	})
+}
+
+// UnsetUserConfigValue removes key from the user configuration file, and
+// the sections it leaves empty.
+func UnsetUserConfigValue(key string) error {
+	return editUserConfig(key, func(parent *yamlv3.Node, name string) error {
+		i, _ := mappingEntry(parent, name)
+		if i < 0 {
+			return fmt.Errorf("%s is not set", key)
+		}
+		parent.Content = slices.Delete(parent.Content, i, i+2)
+		return nil
+	})
+}
+
+// editUserConfig applies edit to the mapping that holds the last name of
+// key, creating the mappings on the way, then validates and writes the
+// file. The file is only readable by its owner, since it may hold
+// credentials.
+func editUserConfig(key string, edit func(parent *yamlv3.Node, name string) error) error {
+	names := strings.Split(key, ".")
+	if slices.Contains(names, "") {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	data, path, err := readUserConfig()
+	if err != nil {
+		return err
+	}
+	doc, err := userConfigNode(data)
+	if err != nil {
+		return err
+	}
+
+	parents := []*yamlv3.Node{doc.Content[0]}
+	for _, name := range names[:len(names)-1] {
+		parent := parents[len(parents)-1]
+		_, node := mappingEntry(parent, name)
+		if node == nil {
+			node = &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+			parent.Content = append(parent.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: name}, node)
+		} else if node.Kind != yamlv3.MappingNode {
+			return fmt.Errorf("%s is not a section", name)
+		}
+		parents = append(parents, node)
+	}
+	if err := edit(parents[len(parents)-1], names[len(names)-1]); err != nil {
+		return err
+	}
+	for i := len(parents) - 1; i > 0; i-- {
+		if len(parents[i].Content) == 0 {
+			j, _ := mappingEntry(parents[i-1], names[i-1])
+			parents[i-1].Content = slices.Delete(parents[i-1].Content, j, j+2)
+		}
+	}
+
+	var out bytes.Buffer
+	enc := yamlv3.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if _, err := parseUserConfig(out.Bytes(), path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o600)
+}
+
+// userConfigNode parses the user configuration file into a document whose
+// content is a mapping, empty for an empty file.
+func userConfigNode(data []byte) (*yamlv3.Node, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yamlv3.MappingNode {
+		return nil, errors.New("the user configuration is not a YAML mapping")
+	}
+	return &doc, nil
+}
+
+// mappingEntry returns the index of the key name in the mapping node and
+// its value, or -1 and nil.
+func mappingEntry(node *yamlv3.Node, name string) (int, *yamlv3.Node) {
+	if node.Kind != yamlv3.MappingNode {
+		return -1, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return i, node.Content[i+1]
+		}
+	}
+	return -1, nil
+}
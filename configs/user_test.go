@@ -0,0 +1,100 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigUserDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	user := `
+sdkr:
+  awsECR: true
+  awsRegion: eu-west-1
+selm:
+  namespace: staging
+  releaseName: from-user
+color: never
+`
+	if err := os.WriteFile(filepath.Join(home, UserConfigFile), []byte(user), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte("sdkr:\n  awsECR: false\n  imageName: api\nselm:\n  releaseName: api\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Sdkr.AwsECR || cfg.Sdkr.AwsRegion != "eu-west-1" || cfg.Sdkr.ImageName != "api" {
+		t.Errorf("sdkr = %+v, want the project's awsECR and imageName over the user's awsRegion", cfg.Sdkr)
+	}
+	if cfg.Selm.Namespace != "staging" || cfg.Selm.ReleaseName != "api" {
+		t.Errorf("selm namespace, release = %q, %q, want staging, api", cfg.Selm.Namespace, cfg.Selm.ReleaseName)
+	}
+}
+
+func TestLoadConfigInvalidUserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	if err := os.WriteFile(filepath.Join(home, UserConfigFile), []byte("selm:\n  nmespace: staging\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte("selm:\n  releaseName: api\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "nmespace") {
+		t.Errorf("LoadConfig() error = %v, want the unknown key reported", err)
+	}
+}
+
+func TestSetUserConfigValue(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	file := filepath.Join(home, UserConfigFile)
+	if err := os.WriteFile(file, []byte("# my defaults\ncolor: auto\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range map[string]string{"selm.namespace": "staging", "sdkr.awsECR": "true", "pluginPaths": "[/opt/helm-plugins]"} {
+		if err := SetUserConfigValue(key, value); err != nil {
+			t.Fatalf("SetUserConfigValue(%s) = %v", key, err)
+		}
+	}
+	if err := SetUserConfigValue("selm.nmespace", "x"); err == nil {
+		t.Error("SetUserConfigValue(unknown key): want an error")
+	}
+	if err := SetUserConfigValue("color", "blue"); err == nil {
+		t.Error("SetUserConfigValue(color blue): want an error")
+	}
+
+	u, err := LoadUserConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Selm.Namespace != "staging" || !u.Sdkr.AwsECR || u.Color != "auto" || len(u.PluginPaths) != 1 {
+		t.Errorf("user config = %+v", u)
+	}
+	if data, _ := os.ReadFile(file); !strings.HasPrefix(string(data), "# my defaults\n") {
+		t.Errorf("the comment of the file was lost:\n%s", data)
+	}
+	if v, ok, err := UserConfigValue("selm.namespace"); err != nil || !ok || v != "staging" {
+		t.Errorf("UserConfigValue(selm.namespace) = %q, %v, %v", v, ok, err)
+	}
+
+	if err := UnsetUserConfigValue("selm.namespace"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := UserConfigValue("selm"); ok {
+		t.Error("unsetting the last key of selm left the section behind")
+	}
+	if err := UnsetUserConfigValue("selm.namespace"); err == nil {
+		t.Error("UnsetUserConfigValue(unset key): want an error")
+	}
+}
@@ -0,0 +1,90 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// valueFromFilePrefix selects the file a valueFrom reference reads.
+const valueFromFilePrefix = "file:"
+
+// resolveValueFrom replaces every field of smurf.yaml written as
+//
+//	docker_password:
+//	  valueFrom: file:/run/secrets/docker_password
+//
+// with the content of the file, so credentials can be mounted as files (CI
+// secrets, Kubernetes and Docker secrets) instead of written into the file.
+// A relative path is relative to the directory of smurf.yaml, ${VAR} in the
+// path is expanded and one trailing newline is dropped from the content.
+// data is returned unchanged when it has no valueFrom reference.
+func resolveValueFrom(data []byte, configPath string) ([]byte, error) {
+	if !strings.Contains(string(data), "valueFrom") {
+		return data, nil
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the yaml file %v", err)
+	}
+	resolved, err := resolveValueFromNode(doc, "", filepath.Dir(configPath))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(resolved)
+}
+
+func resolveValueFromNode(node interface{}, key, baseDir string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		if ref, ok := n["valueFrom"]; ok && len(n) == 1 {
+			return readValueFrom(ref, key, baseDir)
+		}
+		for k, v := range n {
+			resolved, err := resolveValueFromNode(v, joinKey(key, fmt.Sprint(k)), baseDir)
+			if err != nil {
+				return nil, err
+			}
+			n[k] = resolved
+		}
+	case []interface{}:
+		for i, v := range n {
+			resolved, err := resolveValueFromNode(v, fmt.Sprintf("%s[%d]", key, i), baseDir)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = resolved
+		}
+	}
+	return node, nil
+}
+
+func readValueFrom(ref interface{}, key, baseDir string) (string, error) {
+	source, ok := ref.(string)
+	if !ok || !strings.HasPrefix(source, valueFromFilePrefix) {
+		return "", fmt.Errorf("%s: invalid valueFrom %v: must be file:PATH", key, ref)
+	}
+	path := expandBracedEnv(strings.TrimPrefix(source, valueFromFilePrefix))
+	if path == "" {
+		return "", fmt.Errorf("%s: valueFrom needs a file path", key)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: unable to read valueFrom file: %w", key, err)
+	}
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
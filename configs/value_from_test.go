@@ -0,0 +1,73 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ValueFromFile(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.Mkdir(secrets, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(secrets, "docker"), []byte("P@ss$word123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(secrets, "github"), []byte("ghp_token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SMURF_SECRETS", secrets)
+
+	path := filepath.Join(dir, "smurf.yaml")
+	content := `
+sdkr:
+  docker_username: deployer
+  docker_password:
+    valueFrom: file:secrets/docker
+  github_token:
+    valueFrom: file:${TEST_SMURF_SECRETS}/github
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading config: %v", err)
+	}
+	if cfg.Sdkr.DockerPassword != "P@ss$word123" {
+		t.Errorf("Sdkr.DockerPassword = %q, want %q", cfg.Sdkr.DockerPassword, "P@ss$word123")
+	}
+	if cfg.Sdkr.GithubToken != "ghp_token" {
+		t.Errorf("Sdkr.GithubToken = %q, want %q", cfg.Sdkr.GithubToken, "ghp_token")
+	}
+	if cfg.Sdkr.DockerUsername != "deployer" {
+		t.Errorf("Sdkr.DockerUsername = %q, want %q", cfg.Sdkr.DockerUsername, "deployer")
+	}
+}
+
+func TestLoadConfig_ValueFromErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"missing file", "sdkr:\n  github_token:\n    valueFrom: file:missing\n", "sdkr.github_token: unable to read valueFrom file"},
+		{"unknown source", "sdkr:\n  github_token:\n    valueFrom: env:TOKEN\n", "sdkr.github_token: invalid valueFrom env:TOKEN"},
+		{"empty path", "sdkr:\n  github_token:\n    valueFrom: \"file:\"\n", "valueFrom needs a file path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "smurf.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/utils"
+)
+
+// Offline makes AIExplainError serve explanations from the cache only, never
+// calling the AI provider (--ai-offline). It needs no OPENAI_API_KEY.
+var Offline bool
+
+// DefaultCacheTTL is how long a cached explanation is served. Set
+// SMURF_AI_CACHE_TTL (e.g. "24h") to change it, or to "0" to disable the cache.
+const DefaultCacheTTL = 7 * 24 * time.Hour
+
+// ErrNotCached is returned in offline mode when no cached explanation exists.
+var ErrNotCached = errors.New("no cached explanation for this error")
+
+// cacheLockWait bounds how long a run waits for another process that is
+// already asking about the same error.
+const cacheLockWait = aiRequestTimeout + 5*time.Second
+
+// cacheMu serializes cache use within the process; lock files do the same
+// across processes, e.g. parallel CI jobs failing the same way.
+var cacheMu sync.Mutex
+
+// cacheEntry is the on-disk form of one cached explanation.
+type cacheEntry struct {
+	Signature string    `json:"signature"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"createdAt"`
+	Response  string    `json:"response"`
+}
+
+// volatilePatterns match the parts of an error message that differ between
+// otherwise identical failures, with what errorSignature replaces them by.
+var volatilePatterns = []struct {
+	re   *regexp.Regexp
+	with string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<time>"},
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}\b`), "<time>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`(?i)\b(sha256:)?[0-9a-f]{12,}\b`), "<hash>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	// Generated pod and ReplicaSet name suffixes, e.g. web-5d9c8b7f4-x7k2p.
+	{regexp.MustCompile(`-[bcdfghjklmnpqrstvwxz2-9]{6,10}-[bcdfghjklmnpqrstvwxz2-9]{5}\b`), "-<pod>"},
+	{regexp.MustCompile(`(/tmp|/var/folders)/[^\s'"]+`), "<tmp>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// errorSignature identifies an error message regardless of timestamps, IDs,
+// addresses and generated names, so repeats of a failure share a cache entry.
+func errorSignature(errText, model string) string {
+	normalized := errText
+	for _, p := range volatilePatterns {
+		normalized = p.re.ReplaceAllString(normalized, p.with)
+	}
+	sum := sha256.Sum256([]byte(model + "\n" + strings.TrimSpace(normalized)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func cacheTTL() time.Duration {
+	if v := os.Getenv("SMURF_AI_CACHE_TTL"); v != "" {
+		if ttl, err := time.ParseDuration(v); err == nil {
+			return ttl
+		}
+	}
+	return DefaultCacheTTL
+}
+
+func cacheDir() (string, error) {
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "ai-cache"), nil
+}
+
+// cachedAnswer returns the explanation of errText from ~/.smurf/ai-cache, or
+// asks for it with ask and caches the answer. A run that finds another
+// process asking about the same error waits for its answer instead of
+// spending tokens on it again. In offline mode ask is never called.
+func cachedAnswer(errText string, ask func() (string, error)) (string, error) {
+	ttl := cacheTTL()
+	if !Offline && (ttl <= 0 || os.Getenv("OPENAI_API_KEY") == "") {
+		return ask()
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sig := errorSignature(errText, modelFromEnv())
+	path := filepath.Join(dir, sig+".json")
+	if entry, ok := loadCacheEntry(path, ttl); ok {
+		return entry.Response, nil
+	}
+	if Offline {
+		return "", ErrNotCached
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create AI cache directory: %w", err)
+	}
+	unlock, locked := lockCacheEntry(path + ".lock")
+	if !locked {
+		// The other process has answered or given up; use its answer if any.
+		if entry, ok := loadCacheEntry(path, ttl); ok {
+			return entry.Response, nil
+		}
+	}
+	defer unlock()
+
+	response, err := ask()
+	if err != nil {
+		return "", err
+	}
+	entry := cacheEntry{Signature: sig, Model: modelFromEnv(), CreatedAt: time.Now().UTC(), Response: response}
+	if err := saveCacheEntry(path, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache AI explanation: %v\n", err)
+	}
+	return response, nil
+}
+
+func loadCacheEntry(path string, ttl time.Duration) (*cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == "" {
+		return nil, false
+	}
+	if time.Since(entry.CreatedAt) > ttl {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// saveCacheEntry writes an entry through a temporary file, so readers never
+// see a partial one.
+func saveCacheEntry(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockCacheEntry takes the lock file of a cache entry. When another process
+// holds it, lockCacheEntry waits for its release (up to cacheLockWait) and
+// reports locked false; a lock older than cacheLockWait is considered
+// abandoned and taken over.
+func lockCacheEntry(lockPath string) (unlock func(), locked bool) {
+	release := func() { os.Remove(lockPath) }
+	deadline := time.Now().Add(cacheLockWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return release, true
+		}
+		if !os.IsExist(err) {
+			return func() {}, false
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > cacheLockWait {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return func() {}, false
+		}
+		time.Sleep(250 * time.Millisecond)
+		if _, err := os.Stat(lockPath); os.IsNotExist(err) {
+			return func() {}, false
+		}
+	}
+}
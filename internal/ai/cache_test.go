@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestErrorSignature(t *testing.T) {
+	a := `2026-01-02T10:00:01Z pod web-5d9c8b7f4-x7k2p failed: dial tcp 10.0.3.17:5432: connection refused (request 3f2a9c1e-8b7d-4e6f-9a0b-1c2d3e4f5a6b)`
+	b := `2026-03-04T22:15:59Z pod web-7c6b9d8f5-q2wzn failed: dial tcp 10.0.9.201:5432: connection refused (request 0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d)`
+	if errorSignature(a, "m") != errorSignature(b, "m") {
+		t.Errorf("signatures differ for the same failure:\n%s\n%s", a, b)
+	}
+	if errorSignature(a, "m") == errorSignature(a, "other-model") {
+		t.Error("signature does not depend on the model")
+	}
+	if errorSignature(a, "m") == errorSignature("image pull back-off for web:1.2", "m") {
+		t.Error("different failures share a signature")
+	}
+}
+
+func TestCachedAnswer(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("SMURF_AI_CACHE_TTL", "")
+
+	calls := 0
+	ask := func() (string, error) {
+		calls++
+		return "ROOT CAUSE:\n- disk full", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := cachedAnswer("write /var/lib/data: no space left on device at 12:00:01", ask)
+		if err != nil || got != "ROOT CAUSE:\n- disk full" {
+			t.Fatalf("cachedAnswer = %q, %v", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("provider asked %d times, want 1", calls)
+	}
+	if _, err := cachedAnswer("write /var/lib/data: no space left on device at 18:45:13", ask); err != nil || calls != 1 {
+		t.Errorf("same failure at another time: err = %v, calls = %d; want a cache hit", err, calls)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(home, "ai-cache", "*.lock")); len(matches) != 0 {
+		t.Errorf("lock files left behind: %v", matches)
+	}
+
+	Offline = true
+	t.Cleanup(func() { Offline = false })
+	if _, err := cachedAnswer("a failure never seen before", ask); !errors.Is(err, ErrNotCached) {
+		t.Errorf("offline miss: err = %v, want ErrNotCached", err)
+	}
+	if calls != 1 {
+		t.Errorf("offline mode asked the provider")
+	}
+	if got, err := cachedAnswer("write /var/lib/data: no space left on device at 09:00:00", ask); err != nil || got == "" {
+		t.Errorf("offline hit = %q, %v; want the cached answer", got, err)
+	}
+	Offline = false
+
+	t.Setenv("SMURF_AI_CACHE_TTL", "1ns")
+	time.Sleep(time.Millisecond)
+	if _, err := cachedAnswer("write /var/lib/data: no space left on device at 12:00:01", ask); err != nil || calls != 2 {
+		t.Errorf("expired entry: err = %v, calls = %d; want the provider asked again", err, calls)
+	}
+}
+
+func TestLockCacheEntryWaitsForRelease(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "entry.json.lock")
+	if err := os.WriteFile(lock, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		os.Remove(lock)
+	}()
+
+	start := time.Now()
+	unlock, locked := lockCacheEntry(lock)
+	defer unlock()
+	if locked {
+		t.Error("lockCacheEntry took a lock held by another process")
+	}
+	if waited := time.Since(start); waited < 250*time.Millisecond || waited > 5*time.Second {
+		t.Errorf("lockCacheEntry returned after %s, want it to wait for the release", waited)
+	}
+}
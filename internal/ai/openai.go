@@ -40,7 +40,8 @@ func IsEnabled() bool {
 	return true
 }
 
-// Explain error in human readable format using AI
+// Explain error in human readable format using AI. Explanations are cached
+// per error signature; see cachedAnswer.
 func ExplainError(errText string) (string, error) {
 	errText = Redact(errText)
 	prompt := fmt.Sprintf(`
@@ -80,7 +81,7 @@ STYLE RULES:
 Error to analyze: %s
 `, errText)
 
-	response, err := AskAI(prompt)
+	response, err := cachedAnswer(errText, func() (string, error) { return AskAI(prompt) })
 	if err != nil {
 		return "", err
 	}
@@ -279,14 +280,21 @@ func formatFallbackResponse(response string) string {
 	return output.String()
 }
 
+// AIExplainError prints an AI explanation of a failure when useAI is set, or
+// a cached one when Offline is.
 func AIExplainError(useAI bool, errTest string) {
-	if useAI && IsEnabled() {
-		fmt.Println("\n🤖 Smurf AI Analysis...")
-		answer, err := ExplainError(errTest)
-		if err != nil {
-			pterm.Error.Printf("AI analysis failed: %v\n", err)
-			return
-		}
-		fmt.Println(answer)
+	if !Offline && (!useAI || !IsEnabled()) {
+		return
+	}
+	fmt.Println("\n🤖 Smurf AI Analysis...")
+	answer, err := ExplainError(errTest)
+	if errors.Is(err, ErrNotCached) {
+		pterm.Info.Println("No cached AI explanation for this error (--ai-offline).")
+		return
+	}
+	if err != nil {
+		pterm.Error.Printf("AI analysis failed: %v\n", err)
+		return
 	}
+	fmt.Println(answer)
 }
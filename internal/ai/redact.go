@@ -1,6 +1,12 @@
 package ai
 
-import "regexp"
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // redactionPatterns matches common secret shapes so they are never sent to the
 // AI provider. The set is intentionally conservative (GitHub tokens, AWS access
@@ -21,10 +27,41 @@ var redactionPatterns = []*regexp.Regexp{
 
 const redactedPlaceholder = "[REDACTED]"
 
-// Redact masks substrings in s that look like common secrets (GitHub tokens,
-// AWS access key IDs, Bearer tokens, password=... values) before the text is
-// sent to an external AI provider.
+// minSecretLength is the shortest registered secret that is redacted; shorter
+// values (a sensitive "1" or "on") would mangle unrelated text.
+const minSecretLength = 4
+
+// secrets holds values known to be secret, such as terraform outputs and
+// attributes marked sensitive, longest first so a secret containing another
+// is replaced whole.
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// RegisterSecrets adds values that Redact must mask wherever they appear,
+// in addition to the common secret shapes it recognizes on its own.
+func RegisterSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range values {
+		if len(v) < minSecretLength || slices.Contains(secrets.values, v) {
+			continue
+		}
+		secrets.values = append(secrets.values, v)
+	}
+	sort.Slice(secrets.values, func(i, j int) bool { return len(secrets.values[i]) > len(secrets.values[j]) })
+}
+
+// Redact masks registered secrets and substrings in s that look like common
+// secrets (GitHub tokens, AWS access key IDs, Bearer tokens, password=...
+// values) before the text is sent to an external AI provider or logged.
 func Redact(s string) string {
+	secrets.RLock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, redactedPlaceholder)
+	}
+	secrets.RUnlock()
 	for _, re := range redactionPatterns {
 		s = re.ReplaceAllString(s, redactedPlaceholder)
 	}
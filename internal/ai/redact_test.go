@@ -66,3 +66,16 @@ func TestRedact_LeavesNormalTextAlone(t *testing.T) {
 		t.Errorf("Redact(%q) = %q, want unchanged text", input, got)
 	}
 }
+
+func TestRedact_RegisteredSecrets(t *testing.T) {
+	defer func() { secrets.values = nil }()
+	RegisterSecrets("s3cr3t-db-pass", "s3cr3t", "on")
+
+	got := Redact("login with s3cr3t-db-pass failed; s3cr3t rejected; feature on")
+	if strings.Contains(got, "s3cr3t") {
+		t.Errorf("registered secret not redacted: %q", got)
+	}
+	if !strings.Contains(got, "feature on") {
+		t.Errorf("values shorter than %d characters must not be redacted: %q", minSecretLength, got)
+	}
+}
@@ -0,0 +1,257 @@
+// Package ci adapts smurf's output and authentication to the CI system it
+// runs in, selected with the global --ci flag. GitLab is the one supported
+// today: smurf writes what later jobs need to a dotenv artifact, folds its
+// log into collapsible sections and authenticates with the job's OIDC token.
+package ci
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitLab is the --ci value of GitLab CI/CD.
+const GitLab = "gitlab"
+
+// Modes lists the values --ci accepts.
+var Modes = []string{GitLab}
+
+// DefaultDotenv is the dotenv artifact written when --ci-dotenv is not set.
+const DefaultDotenv = "smurf.env"
+
+// Mode is the CI integration in use, "" when there is none. It implements
+// pflag.Value so that --ci rejects an unknown system before any command
+// runs.
+type Mode string
+
+// Current is the mode chosen with --ci, or by $SMURF_CI when the flag is not
+// given.
+var Current = Mode(os.Getenv("SMURF_CI"))
+
+// DotenvPath is the dotenv artifact later jobs read the exported variables
+// from, set with --ci-dotenv.
+var DotenvPath = DefaultDotenv
+
+// Output is where section markers are written; the tests swap it.
+var Output io.Writer = os.Stdout
+
+func (m *Mode) String() string { return string(*m) }
+
+func (m *Mode) Type() string { return "string" }
+
+func (m *Mode) Set(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s != "" && s != GitLab {
+		return fmt.Errorf("unsupported CI system %q: must be one of %s", s, strings.Join(Modes, ", "))
+	}
+	*m = Mode(s)
+	return nil
+}
+
+// Enabled reports whether smurf runs in GitLab CI mode.
+func Enabled() bool {
+	return Current == GitLab
+}
+
+var sectionNameInvalid = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// SectionName turns s, e.g. a command path, into a GitLab section name,
+// which may only hold lowercase letters, digits, '_', '.' and '-'.
+func SectionName(s string) string {
+	return strings.Trim(sectionNameInvalid.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// Section opens a GitLab log section titled header and returns the function
+// that closes it. A collapsed section is folded in the job log until it is
+// clicked. Outside GitLab CI mode both are no-ops.
+func Section(name, header string, collapsed bool) (end func()) {
+	if !Enabled() {
+		return func() {}
+	}
+	name = SectionName(name)
+	options := ""
+	if collapsed {
+		options = "[collapsed=true]"
+	}
+	fmt.Fprintf(Output, "\x1b[0Ksection_start:%d:%s%s\r\x1b[0K%s\n", time.Now().Unix(), name, options, header)
+	return func() {
+		fmt.Fprintf(Output, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), name)
+	}
+}
+
+// Export records vars in the dotenv artifact, replacing the earlier value of
+// a variable it already holds, so a job running several smurf commands ends
+// up with one artifact. Outside GitLab CI mode it does nothing.
+//
+// GitLab passes the variables to the jobs that need this one when the job
+// declares the file under artifacts:reports:dotenv.
+func Export(vars map[string]string) error {
+	if !Enabled() || len(vars) == 0 {
+		return nil
+	}
+	current, err := readDotenv(DotenvPath)
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("dotenv variable %s: multi-line values are not supported", k)
+		}
+		current[k] = v
+	}
+
+	keys := make([]string, 0, len(current))
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, current[k])
+	}
+	if dir := filepath.Dir(DotenvPath); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create the dotenv directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(DotenvPath, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write the dotenv artifact %s: %w", DotenvPath, err)
+	}
+	return nil
+}
+
+// ImageVars are the dotenv variables of a pushed image: SMURF_IMAGE and
+// SMURF_IMAGE_DIGEST, the digest later jobs should deploy by.
+func ImageVars(image, digest string) map[string]string {
+	vars := map[string]string{"SMURF_IMAGE": image}
+	if digest != "" {
+		vars["SMURF_IMAGE_DIGEST"] = digest
+	}
+	return vars
+}
+
+// ReleaseVars are the dotenv variables of an installed or upgraded Helm
+// release: SMURF_RELEASE, SMURF_RELEASE_NAMESPACE and
+// SMURF_RELEASE_REVISION.
+func ReleaseVars(name, namespace string, revision int) map[string]string {
+	return map[string]string{
+		"SMURF_RELEASE":           name,
+		"SMURF_RELEASE_NAMESPACE": namespace,
+		"SMURF_RELEASE_REVISION":  fmt.Sprint(revision),
+	}
+}
+
+// readDotenv reads the KEY=value lines of path; a missing file is empty.
+func readDotenv(path string) (map[string]string, error) {
+	vars := map[string]string{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the dotenv artifact %s: %w", path, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			vars[strings.TrimSpace(k)] = v
+		}
+	}
+	return vars, scanner.Err()
+}
+
+// idTokenVars are the variables an id_tokens entry of the job may name for
+// smurf to find the GitLab OIDC token in.
+var idTokenVars = []string{"SMURF_ID_TOKEN", "GITLAB_OIDC_TOKEN"}
+
+// SetupOIDC lets the AWS, Azure and Google Cloud SDKs authenticate with the
+// job's GitLab OIDC token, so no long-lived cloud keys need to be stored in
+// CI variables. The token is written to a file and each cloud the job
+// configures is pointed at it:
+//
+//	AWS     AWS_ROLE_ARN                     -> AWS_WEB_IDENTITY_TOKEN_FILE
+//	Azure   AZURE_CLIENT_ID, AZURE_TENANT_ID -> AZURE_FEDERATED_TOKEN_FILE
+//	GCP     GCP_WORKLOAD_IDENTITY_PROVIDER   -> GOOGLE_APPLICATION_CREDENTIALS
+//
+// GCP_SERVICE_ACCOUNT, when set, is impersonated with the federated
+// identity. Settings the job already made are left alone. It returns the
+// clouds it configured.
+func SetupOIDC() ([]string, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	var token string
+	for _, name := range idTokenVars {
+		if token = os.Getenv(name); token != "" {
+			break
+		}
+	}
+	if token == "" {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "smurf-oidc-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store the GitLab OIDC token: %w", err)
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to store the GitLab OIDC token: %w", err)
+	}
+
+	var clouds []string
+	if os.Getenv("AWS_ROLE_ARN") != "" && os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") == "" {
+		os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+		if os.Getenv("AWS_ROLE_SESSION_NAME") == "" {
+			os.Setenv("AWS_ROLE_SESSION_NAME", "smurf-gitlab-"+os.Getenv("CI_JOB_ID"))
+		}
+		clouds = append(clouds, "AWS")
+	}
+	if os.Getenv("AZURE_CLIENT_ID") != "" && os.Getenv("AZURE_TENANT_ID") != "" && os.Getenv("AZURE_FEDERATED_TOKEN_FILE") == "" {
+		os.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+		clouds = append(clouds, "Azure")
+	}
+	if provider := os.Getenv("GCP_WORKLOAD_IDENTITY_PROVIDER"); provider != "" && os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+		data, err := json.MarshalIndent(gcpExternalAccount(provider, os.Getenv("GCP_SERVICE_ACCOUNT"), tokenFile), "", "  ")
+		if err != nil {
+			return clouds, err
+		}
+		credFile := filepath.Join(dir, "gcp-credentials.json")
+		if err := os.WriteFile(credFile, data, 0o600); err != nil {
+			return clouds, fmt.Errorf("failed to write the Google Cloud credentials: %w", err)
+		}
+		os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credFile)
+		clouds = append(clouds, "GCP")
+	}
+	return clouds, nil
+}
+
+// gcpExternalAccount is the workload identity federation credential that
+// exchanges the token in tokenFile for Google Cloud credentials. provider
+// is the full resource name of the pool provider, projects/N/locations/
+// global/workloadIdentityPools/POOL/providers/PROVIDER.
+func gcpExternalAccount(provider, serviceAccount, tokenFile string) map[string]any {
+	cred := map[string]any{
+		"type":               "external_account",
+		"audience":           "//iam.googleapis.com/" + strings.TrimPrefix(provider, "//iam.googleapis.com/"),
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source":  map[string]string{"file": tokenFile},
+	}
+	if serviceAccount != "" {
+		cred["service_account_impersonation_url"] = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/" + serviceAccount + ":generateAccessToken"
+	}
+	return cred
+}
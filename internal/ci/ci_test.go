@@ -0,0 +1,131 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func enableGitLab(t *testing.T) {
+	t.Helper()
+	old := Current
+	Current = GitLab
+	t.Cleanup(func() { Current = old })
+}
+
+func TestModeSet(t *testing.T) {
+	var m Mode
+	if err := m.Set("GitLab"); err != nil || m != GitLab {
+		t.Errorf("Set(GitLab) = %q, %v", m, err)
+	}
+	if err := m.Set("jenkins"); err == nil {
+		t.Error("Set(jenkins): want an error")
+	}
+}
+
+func TestSection(t *testing.T) {
+	var out bytes.Buffer
+	old := Output
+	Output = &out
+	defer func() { Output = old }()
+
+	Section("smurf selm upgrade", "Upgrade", true)()
+	if out.Len() != 0 {
+		t.Fatalf("Section outside CI mode wrote %q", out.String())
+	}
+
+	enableGitLab(t)
+	Section("smurf selm upgrade", "Upgrade", true)()
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "\x1b[0Ksection_start:") || !strings.HasSuffix(lines[0], ":smurf_selm_upgrade[collapsed=true]\r\x1b[0KUpgrade") ||
+		!strings.HasPrefix(lines[1], "\x1b[0Ksection_end:") || !strings.HasSuffix(lines[1], ":smurf_selm_upgrade\r\x1b[0K") {
+		t.Errorf("section markers = %q", out.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	old := DotenvPath
+	DotenvPath = filepath.Join(t.TempDir(), "out", "smurf.env")
+	defer func() { DotenvPath = old }()
+
+	if err := Export(ImageVars("ghcr.io/acme/api:v1", "sha256:aaa")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(DotenvPath); !os.IsNotExist(err) {
+		t.Fatal("Export outside CI mode wrote the dotenv artifact")
+	}
+
+	enableGitLab(t)
+	if err := Export(ImageVars("ghcr.io/acme/api:v1", "sha256:aaa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Export(ImageVars("ghcr.io/acme/api:v2", "sha256:bbb")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Export(ReleaseVars("api", "apps", 7)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(DotenvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SMURF_IMAGE=ghcr.io/acme/api:v2\nSMURF_IMAGE_DIGEST=sha256:bbb\n" +
+		"SMURF_RELEASE=api\nSMURF_RELEASE_NAMESPACE=apps\nSMURF_RELEASE_REVISION=7\n"
+	if string(data) != want {
+		t.Errorf("dotenv artifact =\n%s\nwant\n%s", data, want)
+	}
+
+	if err := Export(map[string]string{"NOTES": "a\nb"}); err == nil {
+		t.Error("exporting a multi-line value: want an error")
+	}
+}
+
+func TestSetupOIDC(t *testing.T) {
+	enableGitLab(t)
+	for _, k := range []string{"AWS_WEB_IDENTITY_TOKEN_FILE", "AZURE_FEDERATED_TOKEN_FILE", "GOOGLE_APPLICATION_CREDENTIALS", "GITLAB_OIDC_TOKEN"} {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+	t.Setenv("SMURF_ID_TOKEN", "header.payload.signature")
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/deploy")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "ci")
+	t.Setenv("AZURE_CLIENT_ID", "")
+	t.Setenv("GCP_WORKLOAD_IDENTITY_PROVIDER", "projects/42/locations/global/workloadIdentityPools/gitlab/providers/gitlab")
+	t.Setenv("GCP_SERVICE_ACCOUNT", "deploy@acme.iam.gserviceaccount.com")
+
+	clouds, err := SetupOIDC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(clouds, ",") != "AWS,GCP" {
+		t.Errorf("SetupOIDC configured %v, want AWS and GCP", clouds)
+	}
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil || string(token) != "header.payload.signature" {
+		t.Errorf("AWS token file = %q, %v", token, err)
+	}
+
+	data, err := os.ReadFile(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cred struct {
+		Type             string            `json:"type"`
+		Audience         string            `json:"audience"`
+		CredentialSource map[string]string `json:"credential_source"`
+		Impersonation    string            `json:"service_account_impersonation_url"`
+	}
+	if err := json.Unmarshal(data, &cred); err != nil {
+		t.Fatal(err)
+	}
+	if cred.Type != "external_account" ||
+		cred.Audience != "//iam.googleapis.com/projects/42/locations/global/workloadIdentityPools/gitlab/providers/gitlab" ||
+		cred.CredentialSource["file"] != os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") ||
+		!strings.Contains(cred.Impersonation, "deploy@acme.iam.gserviceaccount.com:generateAccessToken") {
+		t.Errorf("GCP credentials = %+v", cred)
+	}
+}
@@ -0,0 +1,330 @@
+package docker
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+)
+
+const (
+	// ACR Tasks accepts run timeouts between 5 minutes and 8 hours.
+	acrTaskMinTimeout = 300
+	acrTaskMaxTimeout = 28800
+
+	acrLogPollInterval = 2 * time.Second
+)
+
+// BuildImageWithACRTask builds imageName from opts.ContextDir on Azure ACR
+// Tasks instead of the local Docker daemon. The build context is packed and
+// uploaded to the registry's build source storage, a docker build run is
+// scheduled that pushes the result into the registry, and the run log is
+// streamed back until the run finishes.
+func BuildImageWithACRTask(subscriptionID, resourceGroupName, registryName, imageName string, opts BuildOptions, useAI bool) error {
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		// Leave time for the upload and the log tail on top of the run itself.
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout+5*time.Minute)
+		defer cancel()
+	}
+
+	platform, err := acrPlatform(opts.Platform)
+	if err != nil {
+		return err
+	}
+
+	dockerfile, err := filepath.Rel(opts.ContextDir, opts.DockerfilePath)
+	if err != nil || strings.HasPrefix(dockerfile, "..") {
+		return fmt.Errorf("dockerfile %s must be inside the build context %s", opts.DockerfilePath, opts.ContextDir)
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start("Authenticating with Azure...")
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		spinner.Fail("Failed to authenticate with Azure\n")
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to authenticate with Azure : %w", err)
+	}
+	spinner.Success("Authenticated with Azure\n")
+
+	clientFactory, err := armcontainerregistry.NewClientFactory(subscriptionID, cred, nil)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to create registry client : %w", err)
+	}
+	registryClient := clientFactory.NewRegistriesClient()
+	runsClient := clientFactory.NewRunsClient()
+
+	spinner, _ = pterm.DefaultSpinner.Start("Packing build context...")
+	archive, err := packACRBuildContext(opts.ContextDir, opts.Excludes)
+	if err != nil {
+		spinner.Fail("Failed to pack build context\n")
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	defer os.Remove(archive)
+	spinner.Success("Build context packed\n")
+
+	spinner, _ = pterm.DefaultSpinner.Start("Uploading build context to ACR...")
+	uploadResp, err := registryClient.GetBuildSourceUploadURL(ctx, resourceGroupName, registryName, nil)
+	if err != nil {
+		spinner.Fail("Failed to get build source upload URL\n")
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to get build source upload URL : %w", err)
+	}
+	if uploadResp.UploadURL == nil || uploadResp.RelativePath == nil {
+		spinner.Fail("Registry returned no upload location\n")
+		return errors.New("registry returned no build source upload location")
+	}
+	if err := uploadBlob(ctx, *uploadResp.UploadURL, archive); err != nil {
+		spinner.Fail("Failed to upload build context\n")
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	spinner.Success("Build context uploaded\n")
+
+	request := &armcontainerregistry.DockerBuildRequest{
+		Type:           to.Ptr("DockerBuildRequest"),
+		SourceLocation: uploadResp.RelativePath,
+		DockerFilePath: to.Ptr(filepath.ToSlash(dockerfile)),
+		ImageNames:     []*string{to.Ptr(imageName)},
+		IsPushEnabled:  to.Ptr(true),
+		NoCache:        to.Ptr(opts.NoCache),
+		Platform:       platform,
+		Timeout:        to.Ptr(acrTaskTimeout(opts.Timeout)),
+	}
+	if opts.Target != "" {
+		request.Target = to.Ptr(opts.Target)
+	}
+	for name, value := range opts.BuildArgs {
+		request.Arguments = append(request.Arguments, &armcontainerregistry.Argument{
+			Name:     to.Ptr(name),
+			Value:    to.Ptr(value),
+			IsSecret: to.Ptr(false),
+		})
+	}
+
+	spinner, _ = pterm.DefaultSpinner.Start("Scheduling ACR build run...")
+	poller, err := registryClient.BeginScheduleRun(ctx, resourceGroupName, registryName, request, nil)
+	if err != nil {
+		spinner.Fail("Failed to schedule ACR build run\n")
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to schedule ACR build run : %w", err)
+	}
+	scheduled, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		spinner.Fail("Failed to schedule ACR build run\n")
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to schedule ACR build run : %w", err)
+	}
+	if scheduled.Properties == nil || scheduled.Properties.RunID == nil {
+		spinner.Fail("ACR did not return a run ID\n")
+		return errors.New("ACR did not return a run ID")
+	}
+	runID := *scheduled.Properties.RunID
+	spinner.Success(fmt.Sprintf("ACR build run %s scheduled\n", runID))
+
+	status, err := streamACRRunLog(ctx, runsClient, resourceGroupName, registryName, runID)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	if status != armcontainerregistry.RunStatusSucceeded {
+		err := fmt.Errorf("ACR build run %s finished with status %s", runID, status)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	pterm.Success.Printfln("Built and pushed %s.azurecr.io/%s with ACR Tasks (run %s)", registryName, imageName, runID)
+	return nil
+}
+
+// streamACRRunLog tails the log blob of an ACR run to stdout until the run
+// reaches a terminal status, and returns that status.
+func streamACRRunLog(ctx context.Context, runsClient *armcontainerregistry.RunsClient, resourceGroupName, registryName, runID string) (armcontainerregistry.RunStatus, error) {
+	logResp, err := runsClient.GetLogSasURL(ctx, resourceGroupName, registryName, runID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get log URL for run %s : %w", runID, err)
+	}
+	if logResp.LogLink == nil {
+		return "", fmt.Errorf("ACR returned no log URL for run %s", runID)
+	}
+
+	var offset int64
+	for {
+		run, err := runsClient.Get(ctx, resourceGroupName, registryName, runID, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get status of run %s : %w", runID, err)
+		}
+		var status armcontainerregistry.RunStatus
+		if run.Properties != nil && run.Properties.Status != nil {
+			status = *run.Properties.Status
+		}
+
+		// Read the log after the status, so a terminal status guarantees the
+		// final read sees the complete log.
+		n, err := copyBlobFrom(ctx, *logResp.LogLink, offset, os.Stdout)
+		if err != nil {
+			return "", err
+		}
+		offset += n
+
+		if acrRunFinished(status) {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for run %s : %w", runID, ctx.Err())
+		case <-time.After(acrLogPollInterval):
+		}
+	}
+}
+
+func acrRunFinished(status armcontainerregistry.RunStatus) bool {
+	switch status {
+	case armcontainerregistry.RunStatusSucceeded,
+		armcontainerregistry.RunStatusFailed,
+		armcontainerregistry.RunStatusCanceled,
+		armcontainerregistry.RunStatusError,
+		armcontainerregistry.RunStatusTimeout:
+		return true
+	}
+	return false
+}
+
+// copyBlobFrom writes the bytes of the blob at url from offset onwards to w.
+// A blob that doesn't exist yet or has no new bytes copies nothing.
+func copyBlobFrom(ctx context.Context, url string, offset int64, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read run log : %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return io.Copy(w, resp.Body)
+	case http.StatusNotFound, http.StatusRequestedRangeNotSatisfiable:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("failed to read run log : %s", resp.Status)
+	}
+}
+
+// uploadBlob uploads the file at path as a block blob to a SAS url.
+func uploadBlob(ctx context.Context, url, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload build context : %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload build context : %s", resp.Status)
+	}
+	return nil
+}
+
+// packACRBuildContext writes the build context as a gzipped tarball, the
+// format ACR Tasks expects, to a temporary file and returns its path.
+func packACRBuildContext(contextDir string, excludes []string) (string, error) {
+	tarball, err := createTarball(contextDir, excludes)
+	if err != nil {
+		return "", err
+	}
+	defer tarball.Close()
+
+	f, err := os.CreateTemp("", "smurf-acr-context-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build context archive: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := io.Copy(gz, tarball); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to finalize build context archive: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// acrPlatform converts a docker style platform ("linux/arm64/v8") into ACR
+// run platform properties. An empty platform means linux/amd64.
+func acrPlatform(platform string) (*armcontainerregistry.PlatformProperties, error) {
+	if platform == "" {
+		platform = "linux/amd64"
+	}
+
+	parts := strings.Split(strings.ToLower(platform), "/")
+	props := &armcontainerregistry.PlatformProperties{}
+	switch parts[0] {
+	case "linux":
+		props.OS = to.Ptr(armcontainerregistry.OSLinux)
+	case "windows":
+		props.OS = to.Ptr(armcontainerregistry.OSWindows)
+	default:
+		return nil, fmt.Errorf("unsupported ACR build platform %q: OS must be linux or windows", platform)
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		props.Architecture = to.Ptr(armcontainerregistry.Architecture(parts[1]))
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		props.Variant = to.Ptr(armcontainerregistry.Variant(parts[2]))
+	}
+	return props, nil
+}
+
+// acrTaskTimeout converts a build timeout into the run timeout in seconds,
+// clamped to the range ACR Tasks accepts.
+func acrTaskTimeout(timeout time.Duration) int32 {
+	seconds := int64(timeout / time.Second)
+	if seconds < acrTaskMinTimeout {
+		return acrTaskMinTimeout
+	}
+	if seconds > acrTaskMaxTimeout {
+		return acrTaskMaxTimeout
+	}
+	return int32(seconds)
+}
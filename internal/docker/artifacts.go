@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// ArtifactsManifestType is the media and artifact type of the artifacts
+// manifest when it is attached to an image as an OCI referrer.
+const ArtifactsManifestType = "application/vnd.clouddrove.smurf.artifacts.v1+json"
+
+// ArtifactsManifest is the single document describing everything a build
+// and push produced, for downstream compliance tooling.
+type ArtifactsManifest struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Image         string    `json:"image"`
+	Digest        string    `json:"digest,omitempty"`
+	SBOM          string    `json:"sbom,omitempty"`
+	ScanReport    string    `json:"scanReport,omitempty"`
+	Signatures    []string  `json:"signatures,omitempty"`
+}
+
+// ArtifactsOptions controls where the artifacts manifest is written, which
+// files and references it points to, and whether it is attached to the image.
+type ArtifactsOptions struct {
+	Path       string
+	SBOM       string
+	ScanReport string
+	Signatures []string
+	Attach     bool
+	Digest     string // resolved from the local Docker daemon when empty
+}
+
+// WriteArtifactsManifest writes the artifacts manifest for a pushed image to
+// opts.Path and, with opts.Attach, attaches it to the image in its registry
+// as an OCI referrers artifact using the oras CLI.
+func WriteArtifactsManifest(image string, opts ArtifactsOptions) (*ArtifactsManifest, error) {
+	manifest, err := buildArtifactsManifest(image, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.Digest = opts.Digest
+	if manifest.Digest == "" {
+		digest, err := ImageDigest(image)
+		if err != nil {
+			pterm.Warning.Printfln("Could not resolve digest of %s, writing the artifacts manifest without it: %v", image, err)
+		}
+		manifest.Digest = digest
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode artifacts manifest: %w", err)
+	}
+	if dir := filepath.Dir(opts.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create artifacts manifest directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(opts.Path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write artifacts manifest: %w", err)
+	}
+	pterm.Success.Printfln("Artifacts manifest written to %s", opts.Path)
+
+	if opts.Attach {
+		if err := attachArtifactsManifest(image, manifest.Digest, opts.Path); err != nil {
+			return manifest, err
+		}
+	}
+	return manifest, nil
+}
+
+// buildArtifactsManifest assembles the manifest fields that don't need the
+// Docker daemon, checking that the referenced files exist.
+func buildArtifactsManifest(image string, opts ArtifactsOptions) (*ArtifactsManifest, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("artifacts manifest path is required")
+	}
+
+	manifest := &ArtifactsManifest{
+		SchemaVersion: 1,
+		CreatedAt:     time.Now().UTC(),
+		Image:         image,
+		Signatures:    opts.Signatures,
+	}
+
+	for _, f := range []struct {
+		flag string
+		path string
+		dst  *string
+	}{
+		{"SBOM", opts.SBOM, &manifest.SBOM},
+		{"scan report", opts.ScanReport, &manifest.ScanReport},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", f.flag, f.path, err)
+		}
+		abs, err := filepath.Abs(f.path)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = abs
+	}
+	return manifest, nil
+}
+
+// attachArtifactsManifest pushes the manifest file as an OCI referrer of
+// image@digest.
+func attachArtifactsManifest(image, digest, path string) error {
+	if digest == "" {
+		return fmt.Errorf("cannot attach artifacts manifest: digest of %s is unknown", image)
+	}
+	if _, err := exec.LookPath("oras"); err != nil {
+		return fmt.Errorf("attaching the artifacts manifest requires the oras CLI (https://oras.land): %w", err)
+	}
+
+	subject := imageRepository(image) + "@" + digest
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// oras records the file name as the layer title, so run it next to the
+	// file and pass the bare name.
+	cmd := exec.CommandContext(ctx, "oras", "attach",
+		"--artifact-type", ArtifactsManifestType,
+		subject,
+		filepath.Base(path)+":application/json",
+	)
+	cmd.Dir = filepath.Dir(path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	pterm.Info.Printfln("Attaching artifacts manifest to %s...", subject)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to attach artifacts manifest: %v: %s", err, stderr.String())
+	}
+	pterm.Success.Printfln("Artifacts manifest attached to %s", subject)
+	return nil
+}
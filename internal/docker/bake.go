@@ -0,0 +1,214 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+)
+
+// BakeOptions selects the bake files, targets and overrides of a
+// `docker buildx bake` run.
+type BakeOptions struct {
+	Files   []string // bake files; buildx looks for docker-bake.hcl and friends when empty
+	Targets []string // targets or groups; the "default" group when empty
+	Set     []string // target.field=value overrides, as bake's --set
+	Push    bool
+	NoCache bool
+	Timeout time.Duration
+}
+
+// BakeTarget is the part of a resolved bake target smurf reports on.
+type BakeTarget struct {
+	Context    string            `json:"context,omitempty"`
+	Dockerfile string            `json:"dockerfile,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Platforms  []string          `json:"platforms,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Target     string            `json:"target,omitempty"`
+}
+
+// BakeDefinition is the definition `docker buildx bake --print` resolves
+// from the bake files: groups expanded to the targets they build.
+type BakeDefinition struct {
+	Group  map[string]BakeGroup  `json:"group,omitempty"`
+	Target map[string]BakeTarget `json:"target"`
+}
+
+// BakeGroup lists the targets (or nested groups) of a bake group.
+type BakeGroup struct {
+	Targets []string `json:"targets"`
+}
+
+// BakeResult is what one bake target produced.
+type BakeResult struct {
+	Target    string   `json:"target"`
+	Tags      []string `json:"tags,omitempty"`
+	Platforms []string `json:"platforms,omitempty"`
+	Digest    string   `json:"digest,omitempty"`
+}
+
+// ResolveBake returns the targets the bake files define for opts, without
+// building anything.
+func ResolveBake(ctx context.Context, opts BakeOptions) (*BakeDefinition, error) {
+	args := append([]string{"buildx", "bake", "--print"}, bakeFileArgs(opts)...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to resolve bake definition: %s", msg)
+		}
+		return nil, fmt.Errorf("failed to resolve bake definition: %w", err)
+	}
+	var def BakeDefinition
+	if err := json.Unmarshal(out, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse bake definition: %w", err)
+	}
+	if len(def.Target) == 0 {
+		return nil, errors.New("the bake definition has no targets to build")
+	}
+	return &def, nil
+}
+
+// Bake builds the targets of opts with `docker buildx bake` and returns the
+// tags and digest each produced. Without opts.Push, single-platform images
+// are loaded into the local Docker daemon, like `sdkr build`; multi-platform
+// images can only stay in the build cache.
+func Bake(opts BakeOptions, useAI bool) ([]BakeResult, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, errors.New("docker CLI not found in PATH; bake requires docker with the buildx plugin")
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	def, err := ResolveBake(ctx, opts)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, err
+	}
+
+	load := !opts.Push
+	if load && def.multiPlatform() {
+		pterm.Warning.Println("Multi-platform targets cannot be loaded into the local Docker daemon; pass --push to publish them")
+		load = false
+	}
+
+	metadata, err := os.CreateTemp("", "smurf-bake-metadata-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bake metadata file: %w", err)
+	}
+	metadata.Close()
+	defer os.Remove(metadata.Name())
+
+	pterm.Info.Printfln("Baking %d target(s): %s", len(def.Target), strings.Join(def.targetNames(), ", "))
+	cmd := exec.CommandContext(ctx, "docker", bakeArgs(opts, load, metadata.Name())...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("bake timed out after %s", opts.Timeout)
+		}
+		err = fmt.Errorf("bake failed: %w", err)
+		ai.AIExplainError(useAI, err.Error())
+		return nil, err
+	}
+
+	data, err := os.ReadFile(metadata.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bake metadata: %w", err)
+	}
+	return bakeResults(def, data)
+}
+
+// bakeFileArgs are the arguments selecting what to bake, shared by the print
+// and build invocations so both see the same definition.
+func bakeFileArgs(opts BakeOptions) []string {
+	var args []string
+	for _, f := range opts.Files {
+		args = append(args, "--file", f)
+	}
+	for _, s := range opts.Set {
+		args = append(args, "--set", s)
+	}
+	return append(args, opts.Targets...)
+}
+
+func bakeArgs(opts BakeOptions, load bool, metadataFile string) []string {
+	args := []string{"buildx", "bake", "--progress=plain", "--metadata-file", metadataFile}
+	if opts.Push {
+		args = append(args, "--push")
+	} else if load {
+		args = append(args, "--load")
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	return append(args, bakeFileArgs(opts)...)
+}
+
+// bakeResults combines the resolved definition with the metadata file bake
+// wrote, which holds the image digest of every target.
+func bakeResults(def *BakeDefinition, metadata []byte) ([]BakeResult, error) {
+	var meta map[string]json.RawMessage
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse bake metadata: %w", err)
+		}
+	}
+
+	var results []BakeResult
+	for _, name := range def.targetNames() {
+		t := def.Target[name]
+		result := BakeResult{Target: name, Tags: t.Tags, Platforms: t.Platforms}
+		var entry struct {
+			Digest string `json:"containerimage.digest"`
+		}
+		if raw, ok := meta[name]; ok && json.Unmarshal(raw, &entry) == nil {
+			result.Digest = entry.Digest
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (d *BakeDefinition) targetNames() []string {
+	names := make([]string, 0, len(d.Target))
+	for name := range d.Target {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *BakeDefinition) multiPlatform() bool {
+	for _, t := range d.Target {
+		if len(t.Platforms) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintBakeResults renders results as a table.
+func PrintBakeResults(results []BakeResult) error {
+	data := pterm.TableData{{"TARGET", "TAGS", "PLATFORMS", "DIGEST"}}
+	for _, r := range results {
+		data = append(data, []string{r.Target, strings.Join(r.Tags, "\n"), strings.Join(r.Platforms, ", "), r.Digest})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithBoxed().WithData(data).Render()
+}
@@ -1,7 +1,6 @@
 package docker
 
 import (
-	"archive/tar"
 	"bufio"
 	"context"
 	"encoding/json"
@@ -18,6 +17,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pterm/pterm"
 )
 
 // Color functions
@@ -91,6 +91,31 @@ func printBuildSummary(inspect types.ImageInspect, fullImageName string) {
 }
 
 func Build(imageName, tag string, opts BuildOptions, useAI bool) error {
+	if err := checkBasePolicy(opts); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	var epoch *time.Time
+	if opts.Reproducible {
+		sec, err := prepareReproducible(&opts)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(sec, 0).UTC()
+		epoch = &t
+		if !opts.BuildKit && !opts.UsesBuildx() {
+			pterm.Warning.Println("The classic Docker builder stamps the build time into the image, so its ID differs on every build; use --buildkit or --builder buildkitd for identical digests")
+		}
+	}
+	if opts.UsesBuildx() {
+		var sec *int64
+		if epoch != nil {
+			s := epoch.Unix()
+			sec = &s
+		}
+		_, err := Buildx(imageName+":"+tag, opts, sec, useAI)
+		return err
+	}
 	tracker := newStepTracker(3)
 
 	tracker.logStep("Initializing build...")
@@ -120,33 +145,16 @@ func Build(imageName, tag string, opts BuildOptions, useAI bool) error {
 		fmt.Printf("%s Excluding: %s\n", blue("ℹ"), strings.Join(opts.Excludes, ", "))
 	}
 
-	buildCtx, err := createTarball(opts.ContextDir, opts.Excludes)
+	scanned, err := scanContext(opts.ContextDir, opts.Excludes)
 	if err != nil {
 		tracker.completeStep(false, fmt.Sprintf("Context creation failed: %v", err))
 		ai.AIExplainError(useAI, err.Error())
 		return fmt.Errorf("%w", err)
 	}
+	tracker.completeStep(true, fmt.Sprintf("Build context created [%s]", describeContext(scanned)))
+	buildCtx := scanned.tarball(epoch, true)
 	defer buildCtx.Close()
 
-	// Get context size
-	tmpFile, err := os.CreateTemp("", "docker-build-context-")
-	if err == nil {
-		defer os.Remove(tmpFile.Name())
-		size, err := io.Copy(tmpFile, buildCtx)
-		if err == nil {
-			tracker.completeStep(true, fmt.Sprintf("Build context created [%.1f MB]", float64(size)/1024/1024))
-			buildCtx, err = createTarball(opts.ContextDir, opts.Excludes)
-			if err != nil {
-				tracker.completeStep(false, fmt.Sprintf("Failed to recreate build context: %v", err))
-				ai.AIExplainError(useAI, err.Error())
-				return fmt.Errorf("%w", err)
-			}
-			defer buildCtx.Close()
-		}
-	} else {
-		tracker.completeStep(true, "Build context created")
-	}
-
 	relDockerfilePath, err := filepath.Rel(opts.ContextDir, opts.DockerfilePath)
 	if err != nil {
 		tracker.completeStep(false, fmt.Sprintf("Invalid Dockerfile path: %v", err))
@@ -201,8 +209,8 @@ func Build(imageName, tag string, opts BuildOptions, useAI bool) error {
 		if relDockerfilePath != "" {
 			args = append(args, "--file", relDockerfilePath)
 		}
-		for k, v := range opts.BuildArgs {
-			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, v))
+		for _, k := range sortedKeys(opts.BuildArgs) {
+			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, opts.BuildArgs[k]))
 		}
 		if opts.Target != "" {
 			args = append(args, "--target", opts.Target)
@@ -214,6 +222,9 @@ func Build(imageName, tag string, opts BuildOptions, useAI bool) error {
 
 		cmd := exec.Command("docker", args...)
 		cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+		if epoch != nil {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("SOURCE_DATE_EPOCH=%d", epoch.Unix()))
+		}
 
 		stdoutPipe, _ := cmd.StdoutPipe()
 		stderrPipe, _ := cmd.StderrPipe()
@@ -308,74 +319,25 @@ func Build(imageName, tag string, opts BuildOptions, useAI bool) error {
 
 	// tracker.completeStep(true, "Image inspection complete")
 	printBuildSummary(inspect, fullImageName)
+	if opts.Reproducible {
+		printReproducibleDigest(fullImageName, inspect.ID)
+	}
 	return nil
 }
 
 func createTarball(srcDir string, excludePatterns []string) (io.ReadCloser, error) {
-	pr, pw := io.Pipe()
-	tw := tar.NewWriter(pw)
-
-	go func() {
-		walkErr := filepath.Walk(srcDir, func(file string, fi os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			relPath, err := filepath.Rel(srcDir, file)
-			if err != nil {
-				return err
-			}
-
-			for _, pattern := range excludePatterns {
-				if matched, _ := filepath.Match(pattern, relPath); matched {
-					if fi.IsDir() {
-						return filepath.SkipDir
-					}
-					return nil
-				}
-			}
-
-			if relPath == "." {
-				return nil
-			}
-
-			hdr, err := tar.FileInfoHeader(fi, relPath)
-			if err != nil {
-				return err
-			}
-			hdr.Name = relPath
-
-			if err := tw.WriteHeader(hdr); err != nil {
-				return err
-			}
-
-			if fi.Mode().IsRegular() {
-				f, err := os.Open(file)
-				if err != nil {
-					return err
-				}
-				defer f.Close()
-
-				if _, err := io.Copy(tw, f); err != nil {
-					return err
-				}
-			}
-
-			return nil
-		})
-
-		if walkErr != nil {
-			pw.CloseWithError(fmt.Errorf("failed to build tarball from %s: %w", srcDir, walkErr))
-			return
-		}
-
-		if err := tw.Close(); err != nil {
-			pw.CloseWithError(fmt.Errorf("failed to finalize tarball: %w", err))
-			return
-		}
-
-		pw.Close()
-	}()
+	return contextTarball(srcDir, excludePatterns, nil)
+}
 
-	return pr, nil
+// contextTarball streams srcDir as a build context. The context is scanned
+// first, so an unreadable entry is an error here rather than a broken
+// stream. The entries are in lexical order, as filepath.Walk visits them;
+// with epoch set, their timestamps and owners are normalized too, so the
+// same sources give the same tarball on every machine.
+func contextTarball(srcDir string, excludePatterns []string, epoch *time.Time) (io.ReadCloser, error) {
+	c, err := scanContext(srcDir, excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return c.tarball(epoch, false), nil
 }
@@ -0,0 +1,437 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// Builders smurf can build and push images with. docker uses the Docker
+// Engine API. containerd builds with nerdctl into the containerd image store,
+// as on k3s nodes or CI images without Docker Engine. buildkitd builds with
+// buildctl against a standalone buildkitd (BUILDKIT_HOST) and keeps the
+// image as an OCI archive in smurf's image store. Images built by either of
+// the last two are pushed by smurf itself, without a daemon.
+const (
+	BuilderDocker     = "docker"
+	BuilderContainerd = "containerd"
+	BuilderBuildkitd  = "buildkitd"
+)
+
+// containerdImageName is the annotation containerd and nerdctl write to an
+// exported index with the full name of each image.
+const containerdImageName = "io.containerd.image.name"
+
+// ValidBuilder reports an unknown --builder.
+func ValidBuilder(builder string) error {
+	switch builder {
+	case BuilderDocker, BuilderContainerd, BuilderBuildkitd:
+		return nil
+	}
+	return fmt.Errorf("invalid builder %q: must be one of docker, containerd, buildkitd", builder)
+}
+
+// BuildWith builds imageName:tag with builder. The docker builder is Build.
+// A remote build (opts.RemoteBuild) runs buildctl against a buildkitd in
+// the cluster, so it keeps the image like the buildkitd builder does.
+func BuildWith(builder, imageName, tag string, opts BuildOptions, useAI bool) error {
+	if opts.RemoteBuild != "" {
+		if err := ValidRemoteBuild(opts.RemoteBuild); err != nil {
+			return err
+		}
+		if builder == BuilderContainerd {
+			return fmt.Errorf("--remote-build builds with buildkitd; it can't be combined with the containerd builder")
+		}
+		builder = BuilderBuildkitd
+	}
+	if builder == "" || builder == BuilderDocker {
+		return Build(imageName, tag, opts, useAI)
+	}
+	if err := ValidBuilder(builder); err != nil {
+		return err
+	}
+	if opts.MultiPlatform() || opts.Push {
+		return fmt.Errorf("multi-platform builds and --push use docker buildx; they can't be combined with the %s builder", builder)
+	}
+	if err := checkBasePolicy(opts); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	var epoch int64
+	if opts.Reproducible {
+		var err error
+		if epoch, err = prepareReproducible(&opts); err != nil {
+			return err
+		}
+	}
+
+	ref := imageName + ":" + tag
+	var name string
+	var args []string
+	switch builder {
+	case BuilderContainerd:
+		name, args = "nerdctl", nerdctlBuildArgs(ref, opts)
+	case BuilderBuildkitd:
+		dest, err := imageArchivePath(ref)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return fmt.Errorf("failed to create image store: %w", err)
+		}
+		name, args = "buildctl", buildctlArgs(ref, dest, opts)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s not found in PATH; the %s builder requires it", name, builder)
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var env []string
+	if opts.Reproducible {
+		env = append(os.Environ(), fmt.Sprintf("SOURCE_DATE_EPOCH=%d", epoch))
+	}
+	if opts.RemoteBuild == RemoteBuildK8s {
+		host, stop, err := startRemoteBuilder(ctx, opts.Remote)
+		if err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return err
+		}
+		defer stop()
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(env, "BUILDKIT_HOST="+host)
+	}
+
+	pterm.Info.Printfln("Building %s with %s...", ref, name)
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("build timed out after %s", opts.Timeout)
+		}
+		err = fmt.Errorf("%s build failed: %w", name, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	pterm.Success.Printfln("Built %s with %s in %s", ref, name, time.Since(start).Round(time.Second))
+	if opts.Reproducible {
+		digest, err := builtImageID(builder, ref)
+		if err != nil {
+			return fmt.Errorf("failed to read the digest of %s: %w", ref, err)
+		}
+		printReproducibleDigest(ref, digest)
+	}
+	return nil
+}
+
+// nerdctlBuildArgs are the arguments of `nerdctl build`, which mirrors
+// `docker build`.
+func nerdctlBuildArgs(ref string, opts BuildOptions) []string {
+	args := []string{"build", "-t", ref}
+	if opts.DockerfilePath != "" {
+		args = append(args, "-f", opts.DockerfilePath)
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	for _, k := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--build-arg", k+"="+opts.BuildArgs[k])
+	}
+	for _, k := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", k+"="+opts.Labels[k])
+	}
+	for _, from := range opts.CacheFrom {
+		args = append(args, "--cache-from", from)
+	}
+	for _, to := range opts.CacheTo {
+		args = append(args, "--cache-to", to)
+	}
+	return append(args, contextDir(opts))
+}
+
+// buildctlArgs are the arguments of a `buildctl build` with the Dockerfile
+// frontend that writes the image to dest as an OCI archive.
+func buildctlArgs(ref, dest string, opts BuildOptions) []string {
+	dockerfile := opts.DockerfilePath
+	if dockerfile == "" {
+		dockerfile = filepath.Join(contextDir(opts), "Dockerfile")
+	}
+	args := []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + contextDir(opts),
+		"--local", "dockerfile=" + filepath.Dir(dockerfile),
+		"--opt", "filename=" + filepath.Base(dockerfile),
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	if opts.Target != "" {
+		args = append(args, "--opt", "target="+opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--opt", "platform="+opts.Platform)
+	}
+	for _, k := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--opt", "build-arg:"+k+"="+opts.BuildArgs[k])
+	}
+	for _, k := range sortedKeys(opts.Labels) {
+		args = append(args, "--opt", "label:"+k+"="+opts.Labels[k])
+	}
+	// buildctl takes the cache specifications of buildx under other names.
+	for _, from := range opts.CacheFrom {
+		args = append(args, "--import-cache", from)
+	}
+	for _, to := range opts.CacheTo {
+		args = append(args, "--export-cache", to)
+	}
+	output := "type=oci,dest=" + dest + ",name=" + ref
+	if opts.Reproducible {
+		// Clamp the timestamps of the files in the layers to
+		// SOURCE_DATE_EPOCH as well (BuildKit 0.13+).
+		output += ",rewrite-timestamp=true"
+	}
+	return append(args, "--output", output)
+}
+
+func contextDir(opts BuildOptions) string {
+	if opts.ContextDir == "" {
+		return "."
+	}
+	return opts.ContextDir
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// imageArchivePath returns where the buildkitd builder keeps the OCI archive
+// of ref: $SMURF_IMAGE_STORE, or smurf/images in the user cache directory.
+func imageArchivePath(ref string) (string, error) {
+	dir := os.Getenv("SMURF_IMAGE_STORE")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate the image store: %w", err)
+		}
+		dir = filepath.Join(cache, "smurf", "images")
+	}
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		ref += ":latest"
+	}
+	name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref)
+	return filepath.Join(dir, name+".tar"), nil
+}
+
+// PushBuilt pushes source, built by a daemonless builder, to the registry as
+// target and returns the digest pushed. target may name another repository
+// or tag, as a docker tag followed by a push would. Credentials are resolved
+// per registry as for the other pushes.
+func PushBuilt(builder, source, target string, timeout time.Duration) (string, error) {
+	return pushBuilt(builder, source, target, timeout, true)
+}
+
+// pushBuilt is PushBuilt, with a spinner while it pushes when progress is
+// set. Concurrent pushes go without, as their spinners would overwrite each
+// other.
+func pushBuilt(builder, source, target string, timeout time.Duration, progress bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var archive string
+	switch builder {
+	case BuilderContainerd:
+		if _, err := exec.LookPath("nerdctl"); err != nil {
+			return "", errors.New("nerdctl not found in PATH; the containerd builder requires it")
+		}
+		tmp, err := os.CreateTemp("", "smurf-image-*.tar")
+		if err != nil {
+			return "", fmt.Errorf("failed to create image archive: %w", err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		out, err := exec.CommandContext(ctx, "nerdctl", "save", "-o", tmp.Name(), source).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("failed to export %s from containerd: %w: %s", source, err, strings.TrimSpace(string(out)))
+		}
+		archive = tmp.Name()
+	case BuilderBuildkitd:
+		path, err := imageArchivePath(source)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("image %s was not built with the buildkitd builder (no archive at %s)", source, path)
+		}
+		archive = path
+	default:
+		return "", fmt.Errorf("builder %q does not push from an image store", builder)
+	}
+	return pushOCIArchive(ctx, archive, source, target, progress)
+}
+
+// builtImageID identifies the local image source as built with builder, so a
+// retried push can tell whether it was rebuilt in between: the image ID for
+// docker and containerd, the manifest digest for buildkitd.
+func builtImageID(builder, source string) (string, error) {
+	switch builder {
+	case "", BuilderDocker:
+		return localImageID(source)
+	case BuilderContainerd:
+		out, err := exec.Command("nerdctl", "image", "inspect", "--format", "{{.ID}}", source).Output()
+		if err != nil {
+			return "", fmt.Errorf("nerdctl image inspect: %w", err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	case BuilderBuildkitd:
+		path, err := imageArchivePath(source)
+		if err != nil {
+			return "", err
+		}
+		desc, err := archiveManifest(path, source)
+		if err != nil {
+			return "", err
+		}
+		return desc.Digest.String(), nil
+	}
+	return "", ValidBuilder(builder)
+}
+
+// pushOCIArchive copies the image source from an OCI archive to target,
+// showing a spinner meanwhile when progress is set.
+func pushOCIArchive(ctx context.Context, archive, source, target string, progress bool) (string, error) {
+	desc, err := archiveManifest(archive, source)
+	if err != nil {
+		return "", err
+	}
+	store, err := oci.NewFromTar(ctx, archive)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image archive %s: %w", archive, err)
+	}
+
+	img, err := parseRemoteImage(target)
+	if err != nil {
+		return "", err
+	}
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return "", err
+	}
+
+	var spinner *pterm.SpinnerPrinter
+	if progress {
+		spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Pushing %s...", target))
+	}
+	if err := oras.CopyGraph(ctx, store, repo, desc, oras.DefaultCopyGraphOptions); err != nil {
+		if spinner != nil {
+			spinner.Fail(fmt.Sprintf("Failed to push %s", target))
+		}
+		return "", fmt.Errorf("failed to push %s: %w", target, err)
+	}
+	if err := repo.Tag(ctx, desc, img.Tag); err != nil {
+		if spinner != nil {
+			spinner.Fail(fmt.Sprintf("Failed to tag %s", target))
+		}
+		return "", fmt.Errorf("failed to tag %s: %w", target, err)
+	}
+	if spinner != nil {
+		spinner.Success(fmt.Sprintf("Pushed %s (%s)", target, shortDigest(desc.Digest.String())))
+	}
+	return desc.Digest.String(), nil
+}
+
+// archiveManifest returns the descriptor of the image ref in the index of an
+// OCI archive. Images are matched by the full name containerd records, then
+// by their ref.name annotation; an archive of a single image needs no match.
+func archiveManifest(archive, ref string) (ocispec.Descriptor, error) {
+	index, err := readArchiveIndex(archive)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if len(index.Manifests) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("image archive %s is empty", archive)
+	}
+
+	names := map[string]bool{ref: true}
+	if named, err := reference.ParseNormalizedNamed(ref); err == nil {
+		named = reference.TagNameOnly(named)
+		names[named.String()] = true
+		if tagged, ok := named.(reference.Tagged); ok {
+			names[tagged.Tag()] = true
+		}
+	}
+	for _, desc := range index.Manifests {
+		if names[desc.Annotations[containerdImageName]] || names[desc.Annotations[ocispec.AnnotationRefName]] {
+			return desc, nil
+		}
+	}
+	if len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("image %s not found in %s", ref, archive)
+}
+
+func readArchiveIndex(archive string) (*ocispec.Index, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image archive: %w", err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is not an OCI image archive: no %s", archive, ocispec.ImageIndexFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image archive %s: %w", archive, err)
+		}
+		if path.Clean(hdr.Name) != ocispec.ImageIndexFile {
+			continue
+		}
+		var index ocispec.Index
+		if err := json.NewDecoder(tr).Decode(&index); err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %w", ocispec.ImageIndexFile, archive, err)
+		}
+		return &index, nil
+	}
+}
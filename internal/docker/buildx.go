@@ -0,0 +1,253 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// buildxPlatforms splits a --platform value, one platform or a
+// comma-separated list of them, into its platforms.
+func buildxPlatforms(platform string) []string {
+	var platforms []string
+	for _, p := range strings.Split(platform, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// UsesBuildx reports whether opts need `docker buildx build` rather than
+// the Engine API: several platforms, a push straight from the builder, or a
+// build cache to import or export, which the Engine API only takes as
+// local images. The containerd and buildkitd builders handle a build cache
+// themselves.
+func (o BuildOptions) UsesBuildx() bool {
+	return len(buildxPlatforms(o.Platform)) > 1 || o.Push || len(o.CacheFrom) > 0 || len(o.CacheTo) > 0
+}
+
+// MultiPlatform reports whether opts build for more than one platform, an
+// image that can only leave the builder by being pushed.
+func (o BuildOptions) MultiPlatform() bool {
+	return len(buildxPlatforms(o.Platform)) > 1
+}
+
+// Buildx builds ref with `docker buildx build`. Several platforms produce a
+// manifest list. With opts.Push the builder pushes ref itself, with the
+// registry credentials smurf resolves for the other pushes, and the digest
+// it pushed is returned; otherwise a single-platform image is loaded into
+// the local Docker daemon, like Build.
+func Buildx(ref string, opts BuildOptions, epoch *int64, useAI bool) (string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", errors.New("docker CLI not found in PATH; buildx builds require docker with the buildx plugin")
+	}
+	platforms := buildxPlatforms(opts.Platform)
+	for _, p := range platforms {
+		if len(strings.Split(p, "/")) < 2 {
+			return "", fmt.Errorf("invalid platform format. Expected os/arch, got: %s", p)
+		}
+	}
+	if len(opts.Excludes) > 0 {
+		pterm.Warning.Println("buildx reads the build context's exclusions from .dockerignore; the excludes given are not applied")
+	}
+
+	load := !opts.Push
+	if load && len(platforms) > 1 {
+		pterm.Warning.Println("A multi-platform image cannot be loaded into the local Docker daemon; it stays in the build cache. Pass --push to publish it")
+		load = false
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	env := os.Environ()
+	if epoch != nil {
+		env = append(env, fmt.Sprintf("SOURCE_DATE_EPOCH=%d", *epoch))
+	}
+	if opts.Push {
+		img, err := parseRemoteImage(ref)
+		if err != nil {
+			return "", err
+		}
+		if m := ecrHostPattern.FindStringSubmatch(img.Host); m != nil {
+			if _, err := ensureECRRepository(m[2], img.Repository); err != nil {
+				return "", err
+			}
+		}
+		dir, err := pushDockerConfig(ctx, img.Host)
+		if err != nil {
+			return "", err
+		}
+		if dir != "" {
+			defer os.RemoveAll(dir)
+			env = append(env, "DOCKER_CONFIG="+dir)
+		}
+	}
+
+	metadata, err := os.CreateTemp("", "smurf-buildx-metadata-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create buildx metadata file: %w", err)
+	}
+	metadata.Close()
+	defer os.Remove(metadata.Name())
+
+	if len(platforms) > 0 {
+		pterm.Info.Printfln("Building %s for %s with buildx...", ref, strings.Join(platforms, ", "))
+	} else {
+		pterm.Info.Printfln("Building %s with buildx...", ref)
+	}
+	cmd := exec.CommandContext(ctx, "docker", buildxArgs(ref, opts, platforms, load, metadata.Name())...)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("buildx build timed out after %s", opts.Timeout)
+		}
+		err = fmt.Errorf("buildx build failed: %w", err)
+		ai.AIExplainError(useAI, err.Error())
+		return "", err
+	}
+
+	data, err := os.ReadFile(metadata.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read buildx metadata: %w", err)
+	}
+	digest, err := buildxDigest(data)
+	if err != nil {
+		return "", err
+	}
+	if !opts.Push {
+		pterm.Success.Printfln("Built %s", ref)
+		return "", nil
+	}
+	if digest != "" {
+		// The pushed image is not in the local daemon for ImageDigest to
+		// inspect.
+		upToDateDigests.Store(ref, digest)
+	}
+	if len(platforms) > 1 {
+		pterm.Success.Printfln("Pushed manifest list %s (%s) for %s", ref, shortDigest(digest), strings.Join(platforms, ", "))
+	} else {
+		pterm.Success.Printfln("Pushed %s (%s)", ref, shortDigest(digest))
+	}
+	return digest, nil
+}
+
+// buildxArgs are the arguments of `docker buildx build` for ref.
+func buildxArgs(ref string, opts BuildOptions, platforms []string, load bool, metadataFile string) []string {
+	args := []string{"buildx", "build", "--progress=plain", "--tag", ref, "--metadata-file", metadataFile}
+	if opts.DockerfilePath != "" {
+		args = append(args, "--file", opts.DockerfilePath)
+	}
+	if len(platforms) > 0 {
+		args = append(args, "--platform", strings.Join(platforms, ","))
+	}
+	if opts.Push {
+		args = append(args, "--push")
+	} else if load {
+		args = append(args, "--load")
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	for _, k := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, opts.BuildArgs[k]))
+	}
+	for _, k := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, opts.Labels[k]))
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	for _, from := range opts.CacheFrom {
+		args = append(args, "--cache-from", from)
+	}
+	for _, to := range opts.CacheTo {
+		args = append(args, "--cache-to", to)
+	}
+	return append(args, contextDir(opts))
+}
+
+// buildxDigest reads the digest of the image or manifest list built from a
+// buildx metadata file.
+func buildxDigest(metadata []byte) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+	var meta struct {
+		Digest string `json:"containerimage.digest"`
+	}
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return "", fmt.Errorf("failed to parse buildx metadata: %w", err)
+	}
+	return meta.Digest, nil
+}
+
+// pushDockerConfig writes a docker config directory holding the credentials
+// smurf resolves for host, for buildx to push with, and returns it. The
+// user's buildx builders and CLI plugins are linked into it. No directory
+// is made, and buildx uses the user's docker config, when smurf has no
+// credentials of its own for host.
+func pushDockerConfig(ctx context.Context, host string) (string, error) {
+	cred, err := registryCredential(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to get credentials for %s: %w", host, err)
+	}
+	if cred.Username == "" && cred.Password == "" && cred.RefreshToken == "" {
+		return "", nil
+	}
+
+	userDir := os.Getenv("DOCKER_CONFIG")
+	if userDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		userDir = filepath.Join(home, ".docker")
+	}
+	dir, err := os.MkdirTemp("", "smurf-docker-config-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create docker config: %w", err)
+	}
+	for _, name := range []string{"buildx", "cli-plugins", "contexts"} {
+		if _, err := os.Stat(filepath.Join(userDir, name)); err == nil {
+			if err := os.Symlink(filepath.Join(userDir, name), filepath.Join(dir, name)); err != nil {
+				os.RemoveAll(dir)
+				return "", fmt.Errorf("failed to link %s into the docker config: %w", name, err)
+			}
+		}
+	}
+
+	entry := map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))}
+	if cred.RefreshToken != "" {
+		entry["identitytoken"] = cred.RefreshToken
+	}
+	config := map[string]any{
+		"auths": map[string]any{credentials.ServerAddressFromHostname(host): entry},
+	}
+	data, err := json.Marshal(config)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "config.json"), data, 0o600)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write docker config: %w", err)
+	}
+	return dir, nil
+}
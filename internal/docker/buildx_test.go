@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildOptionsUsesBuildx(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  BuildOptions
+		want  bool
+		multi bool
+	}{
+		{name: "default", opts: BuildOptions{}},
+		{name: "one platform", opts: BuildOptions{Platform: "linux/amd64"}},
+		{name: "several platforms", opts: BuildOptions{Platform: "linux/amd64, linux/arm64"}, want: true, multi: true},
+		{name: "trailing comma", opts: BuildOptions{Platform: "linux/arm64,"}},
+		{name: "push", opts: BuildOptions{Push: true}, want: true},
+		{name: "cache from", opts: BuildOptions{CacheFrom: []string{"type=gha"}}, want: true},
+		{name: "cache to", opts: BuildOptions{CacheTo: []string{"type=gha,mode=max"}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.UsesBuildx(); got != tt.want {
+				t.Errorf("UsesBuildx() = %v, want %v", got, tt.want)
+			}
+			if got := tt.opts.MultiPlatform(); got != tt.multi {
+				t.Errorf("MultiPlatform() = %v, want %v", got, tt.multi)
+			}
+		})
+	}
+}
+
+func TestBuildxArgs(t *testing.T) {
+	opts := BuildOptions{
+		ContextDir:     "/src",
+		DockerfilePath: "/src/Dockerfile",
+		BuildArgs:      map[string]string{"B": "2", "A": "1"},
+		Labels:         map[string]string{"team": "platform"},
+		Target:         "runtime",
+		NoCache:        true,
+		Push:           true,
+		CacheFrom:      []string{"type=registry,ref=repo:cache"},
+		CacheTo:        []string{"type=registry,ref=repo:cache,mode=max"},
+	}
+	got := buildxArgs("repo:v1", opts, []string{"linux/amd64", "linux/arm64"}, false, "/tmp/meta.json")
+	want := []string{
+		"buildx", "build", "--progress=plain", "--tag", "repo:v1", "--metadata-file", "/tmp/meta.json",
+		"--file", "/src/Dockerfile",
+		"--platform", "linux/amd64,linux/arm64",
+		"--push",
+		"--no-cache",
+		"--build-arg", "A=1", "--build-arg", "B=2",
+		"--label", "team=platform",
+		"--target", "runtime",
+		"--cache-from", "type=registry,ref=repo:cache",
+		"--cache-to", "type=registry,ref=repo:cache,mode=max",
+		"/src",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("buildxArgs() =\n%q\nwant\n%q", got, want)
+	}
+
+	got = buildxArgs("repo:v1", BuildOptions{ContextDir: "/src"}, nil, true, "/tmp/meta.json")
+	if !slices.Contains(got, "--load") || slices.Contains(got, "--push") || slices.Contains(got, "--platform") {
+		t.Errorf("buildxArgs() without push = %q, want --load and no --push or --platform", got)
+	}
+}
+
+func TestBuildCacheArgs(t *testing.T) {
+	opts := BuildOptions{
+		ContextDir: "/src",
+		CacheFrom:  []string{"type=registry,ref=repo:cache"},
+		CacheTo:    []string{"type=registry,ref=repo:cache,mode=max"},
+	}
+	nerdctl := strings.Join(nerdctlBuildArgs("repo:v1", opts), " ")
+	if want := "--cache-from type=registry,ref=repo:cache --cache-to type=registry,ref=repo:cache,mode=max /src"; !strings.HasSuffix(nerdctl, want) {
+		t.Errorf("nerdctl args %q do not end with %q", nerdctl, want)
+	}
+	buildctl := strings.Join(buildctlArgs("repo:v1", "/store/repo_v1.tar", opts), " ")
+	if want := "--import-cache type=registry,ref=repo:cache --export-cache type=registry,ref=repo:cache,mode=max"; !strings.Contains(buildctl, want) {
+		t.Errorf("buildctl args %q do not contain %q", buildctl, want)
+	}
+
+	err := BuildWith(BuilderContainerd, "repo", "v1", BuildOptions{ContextDir: "/src", Push: true}, false)
+	if err == nil || !strings.Contains(err.Error(), "buildx") {
+		t.Errorf("BuildWith(containerd, --push) = %v, want a buildx error", err)
+	}
+}
+
+func TestBuildxDigest(t *testing.T) {
+	digest, err := buildxDigest([]byte(`{"containerimage.digest":"sha256:abc","image.name":"repo:v1"}`))
+	if err != nil || digest != "sha256:abc" {
+		t.Errorf("buildxDigest() = %q, %v; want sha256:abc", digest, err)
+	}
+	if digest, err := buildxDigest(nil); err != nil || digest != "" {
+		t.Errorf("buildxDigest(empty) = %q, %v; want no digest", digest, err)
+	}
+	if _, err := buildxDigest([]byte("{")); err == nil {
+		t.Error("buildxDigest() of invalid metadata returned no error")
+	}
+}
+
+func TestPushDockerConfig(t *testing.T) {
+	userDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(userDir, "buildx"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", userDir)
+	t.Setenv("DOCKER_USERNAME", "user")
+	t.Setenv("DOCKER_PASSWORD", "secret")
+
+	dir, err := pushDockerConfig(context.Background(), dockerHubRegistry)
+	if err != nil {
+		t.Fatalf("pushDockerConfig() error = %v", err)
+	}
+	if dir == "" {
+		t.Fatal("pushDockerConfig() made no config for env credentials")
+	}
+	defer os.RemoveAll(dir)
+
+	if target, err := os.Readlink(filepath.Join(dir, "buildx")); err != nil || target != filepath.Join(userDir, "buildx") {
+		t.Errorf("buildx link = %q, %v; want %s", target, err, filepath.Join(userDir, "buildx"))
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "cli-plugins")); !os.IsNotExist(err) {
+		t.Errorf("cli-plugins linked though the user has none: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := config.Auths["https://index.docker.io/v1/"]
+	if !ok {
+		t.Fatalf("config.json auths = %v, want Docker Hub's server address", config.Auths)
+	}
+	if want := base64.StdEncoding.EncodeToString([]byte("user:secret")); entry.Auth != want {
+		t.Errorf("auth = %q, want %q", entry.Auth, want)
+	}
+}
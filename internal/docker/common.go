@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 // encodeAuthToBase64 encodes the given registry.AuthConfig as a base64-encoded string.
@@ -89,6 +90,9 @@ func decodePushStream(r io.Reader) (layerOrder []string, layerStatus map[string]
 
 // Core push logic shared between GHCR and other registries
 func pushImage(cli *client.Client, ctx context.Context, imageName, authStr string) error {
+	if skipUpToDatePush(ctx, cli, imageName, imageName, auth.EmptyCredential) {
+		return nil
+	}
 	fmt.Printf("Pushing image: %s\n", imageName)
 	fmt.Println("─────────────────────────────────────────────────────────────")
 
@@ -111,6 +115,33 @@ func pushImage(cli *client.Client, ctx context.Context, imageName, authStr strin
 	return nil
 }
 
+// pushImageQuiet pushes imageName as pushImage does, without the layer
+// progress, which concurrent pushes would interleave.
+func pushImageQuiet(cli *client.Client, ctx context.Context, imageName, authStr string, cred auth.Credential) error {
+	if skipUpToDatePush(ctx, cli, imageName, imageName, cred) {
+		return nil
+	}
+	pushResp, err := cli.ImagePush(ctx, imageName, image.PushOptions{RegistryAuth: authStr})
+	if err != nil {
+		return fmt.Errorf("failed to push image: %w", err)
+	}
+	defer pushResp.Close()
+
+	decoder := json.NewDecoder(pushResp)
+	for {
+		var msg jsonMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("decoding push response: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("push error: %s", msg.Error)
+		}
+	}
+}
+
 // Displays formatted layer progression
 func displayLayerProgress(layerOrder []string, layerStatus map[string][]string) {
 	for i, layerID := range layerOrder {
@@ -0,0 +1,288 @@
+package docker
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+)
+
+// contextWalkers bounds how many directories of a build context are listed
+// at once.
+const contextWalkers = 8
+
+// largeContextSize is the size of the files in a build context from which
+// sending it shows a progress bar.
+const largeContextSize = 64 << 20
+
+// contextEntry is one file, directory or symlink of a build context.
+type contextEntry struct {
+	path string // on disk
+	rel  string // slash-separated, relative to the context directory
+	info os.FileInfo
+	link string // the target of a symlink
+}
+
+// buildContext is a build context scanned before it is sent: its entries,
+// in the order filepath.Walk would visit them, and their size.
+type buildContext struct {
+	entries []contextEntry
+	files   int
+	size    int64 // the bytes of its regular files
+	sparse  int   // how many of those files have holes
+}
+
+// scanContext lists srcDir, without the entries matching excludePatterns,
+// listing its directories concurrently. Every entry that cannot be read is
+// an error, returned before anything is sent.
+func scanContext(srcDir string, excludePatterns []string) (*buildContext, error) {
+	root, err := os.Lstat(srcDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build context %s: %w", srcDir, err)
+	}
+	if !root.IsDir() {
+		return nil, fmt.Errorf("build context %s is not a directory", srcDir)
+	}
+
+	var (
+		mu      sync.Mutex
+		entries []contextEntry
+		errs    []error
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, contextWalkers)
+	)
+	var walk func(dir, rel string)
+	walk = func(dir, rel string) {
+		defer wg.Done()
+		sem <- struct{}{}
+		found, subdirs, err := listContextDir(dir, rel, excludePatterns)
+		<-sem
+
+		mu.Lock()
+		entries = append(entries, found...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		mu.Unlock()
+		// The semaphore is released before descending, so a deep tree
+		// cannot hold every slot while waiting for its children.
+		for _, sub := range subdirs {
+			wg.Add(1)
+			go walk(sub.path, sub.rel)
+		}
+	}
+	wg.Add(1)
+	go walk(srcDir, "")
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to build tarball from %s: %w", srcDir, errors.Join(errs...))
+	}
+
+	slices.SortFunc(entries, func(a, b contextEntry) int {
+		return slices.Compare(strings.Split(a.rel, "/"), strings.Split(b.rel, "/"))
+	})
+	c := &buildContext{entries: entries}
+	for _, e := range entries {
+		if e.info.Mode().IsRegular() {
+			c.files++
+			c.size += e.info.Size()
+			if isSparse(e.info) {
+				c.sparse++
+			}
+		}
+	}
+	return c, nil
+}
+
+// listContextDir lists the entries of dir, a directory of a build context
+// at rel, and returns them with the subdirectories to descend into.
+func listContextDir(dir, rel string, excludePatterns []string) ([]contextEntry, []contextEntry, error) {
+	dirents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entries, subdirs []contextEntry
+	var errs []error
+	for _, d := range dirents {
+		e := contextEntry{path: filepath.Join(dir, d.Name()), rel: d.Name()}
+		if rel != "" {
+			e.rel = rel + "/" + d.Name()
+		}
+		if excludedFromContext(e.rel, excludePatterns) {
+			continue
+		}
+		if e.info, err = d.Info(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Symlinks are sent as links, never followed, as docker does.
+		if e.info.Mode()&os.ModeSymlink != 0 {
+			if e.link, err = os.Readlink(e.path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		entries = append(entries, e)
+		if e.info.IsDir() {
+			subdirs = append(subdirs, e)
+		}
+	}
+	return entries, subdirs, errors.Join(errs...)
+}
+
+// excludedFromContext reports whether the entry at rel matches one of the
+// exclude patterns. An excluded directory is skipped with its contents.
+func excludedFromContext(rel string, excludePatterns []string) bool {
+	rel = filepath.FromSlash(rel)
+	for _, pattern := range excludePatterns {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// tarball streams the context as a tarball. With epoch set, the timestamps
+// and owners of its entries are normalized, so the same sources give the
+// same tarball on every machine. With progress, sending it shows a
+// progress bar.
+func (c *buildContext) tarball(epoch *time.Time, progress bool) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var w io.Writer = pw
+		if progress {
+			if bar := newContextProgress(c.size); bar != nil {
+				defer bar.stop()
+				w = io.MultiWriter(pw, bar)
+			}
+		}
+		pw.CloseWithError(c.write(w, epoch))
+	}()
+	return pr
+}
+
+// write writes the context as a tarball to w.
+func (c *buildContext) write(w io.Writer, epoch *time.Time) error {
+	tw := tar.NewWriter(w)
+	for _, e := range c.entries {
+		if err := writeContextEntry(tw, e, epoch); err != nil {
+			return fmt.Errorf("failed to add %s to the build context: %w", e.rel, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize tarball: %w", err)
+	}
+	return nil
+}
+
+func writeContextEntry(tw *tar.Writer, e contextEntry, epoch *time.Time) error {
+	hdr, err := tar.FileInfoHeader(e.info, e.link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = e.rel
+	if epoch != nil {
+		normalizeHeader(hdr, *epoch)
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The header has the size the file had when the context was scanned;
+	// exactly that much is sent even if the file changes meanwhile.
+	n, err := copyFileData(tw, f, hdr.Size)
+	if err == nil && n < hdr.Size {
+		err = fmt.Errorf("file shrank from %d to %d bytes while the build context was sent", hdr.Size, n)
+	}
+	return err
+}
+
+// zeros is the source of the holes of sparse files.
+var zeros [32 << 10]byte
+
+// writeZeros writes n zero bytes to w.
+func writeZeros(w io.Writer, n int64) (int64, error) {
+	var written int64
+	for written < n {
+		m, err := w.Write(zeros[:min(int64(len(zeros)), n-written)])
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// ignoreEOF treats a file ending early as no error; the caller compares
+// the bytes copied with the size it expected.
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// contextProgress is the progress bar of a large build context being sent,
+// in MiB.
+type contextProgress struct {
+	bar  *pterm.ProgressbarPrinter
+	sent int64
+	mib  int
+}
+
+// newContextProgress starts the progress bar of a context of size bytes,
+// or returns nil when the context is small or stdout is not a terminal.
+func newContextProgress(size int64) *contextProgress {
+	if size < largeContextSize || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+	bar, err := pterm.DefaultProgressbar.
+		WithTotal(int(size>>20) + 1).
+		WithTitle("Sending build context (MiB)").
+		WithRemoveWhenDone(true).
+		Start()
+	if err != nil {
+		return nil
+	}
+	return &contextProgress{bar: bar}
+}
+
+func (p *contextProgress) Write(b []byte) (int, error) {
+	p.sent += int64(len(b))
+	if mib := int(p.sent >> 20); mib > p.mib && mib <= p.bar.Total {
+		p.bar.Add(mib - p.mib)
+		p.mib = mib
+	}
+	return len(b), nil
+}
+
+func (p *contextProgress) stop() {
+	_, _ = p.bar.Stop()
+}
+
+// describeContext summarizes a scanned context for the build output.
+func describeContext(c *buildContext) string {
+	s := fmt.Sprintf("%.1f MB, %d files", float64(c.size)/1024/1024, c.files)
+	if c.sparse > 0 {
+		s += fmt.Sprintf(", %d sparse files sent at full size", c.sparse)
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// isSparse reports whether a regular file takes less space on disk than its
+// size, that is, has holes.
+func isSparse(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Blocks*512 < fi.Size()
+}
+
+// copyFileData copies the first size bytes of f to w. A tarball cannot
+// keep the holes of a sparse file, so they are written as zeros, but
+// without reading them: only the data regions SEEK_DATA finds are read.
+func copyFileData(w io.Writer, f *os.File, size int64) (int64, error) {
+	var n int64
+	for n < size {
+		data, err := f.Seek(n, unix.SEEK_DATA)
+		if errors.Is(err, syscall.ENXIO) {
+			// No data after n: the rest of the file is a hole.
+			data = size
+		} else if err != nil {
+			// The filesystem cannot report holes; read everything.
+			if _, err := f.Seek(n, io.SeekStart); err != nil {
+				return n, err
+			}
+			m, err := io.CopyN(w, f, size-n)
+			return n + m, ignoreEOF(err)
+		}
+		data = min(data, size)
+		if data > n {
+			m, err := writeZeros(w, data-n)
+			n += m
+			if err != nil {
+				return n, err
+			}
+		}
+		if n == size {
+			break
+		}
+		hole, err := f.Seek(n, unix.SEEK_HOLE)
+		if err != nil {
+			return n, err
+		}
+		if _, err := f.Seek(n, io.SeekStart); err != nil {
+			return n, err
+		}
+		m, err := io.CopyN(w, f, min(hole, size)-n)
+		n += m
+		if err != nil {
+			return n, ignoreEOF(err)
+		}
+	}
+	return n, nil
+}
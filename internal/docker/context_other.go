@@ -0,0 +1,18 @@
+//go:build !linux
+
+package docker
+
+import (
+	"io"
+	"os"
+)
+
+// isSparse reports whether a regular file has holes; they are only detected
+// on Linux.
+func isSparse(os.FileInfo) bool { return false }
+
+// copyFileData copies the first size bytes of f to w.
+func copyFileData(w io.Writer, f *os.File, size int64) (int64, error) {
+	n, err := io.CopyN(w, f, size)
+	return n, ignoreEOF(err)
+}
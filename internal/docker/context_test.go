@@ -0,0 +1,174 @@
+package docker
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeContextFiles creates files, by slash-separated path, under dir.
+func writeContextFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readContext reads a context tarball into its headers and file contents.
+func readContext(t *testing.T, rc io.ReadCloser) ([]*tar.Header, map[string]string) {
+	t.Helper()
+	defer rc.Close()
+	var headers []*tar.Header
+	contents := map[string]string{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return headers, contents
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, hdr)
+		if hdr.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[hdr.Name] = string(data)
+		}
+	}
+}
+
+func TestContextTarballOrderAndExcludes(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFiles(t, dir, map[string]string{
+		"Dockerfile":         "FROM scratch\n",
+		"a.txt":              "a",
+		"a/b.txt":            "b",
+		"a/c/d.txt":          "d",
+		"node_modules/x.js":  "x",
+		"z/secret.env":       "s",
+		"z/keep.txt":         "k",
+		"deep/1/2/3/4/5.txt": "5",
+	})
+	rc, err := contextTarball(dir, []string{"node_modules", "z/*.env"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, contents := readContext(t, rc)
+
+	var names []string
+	for _, h := range headers {
+		names = append(names, h.Name)
+	}
+	// filepath.Walk's order: a directory's entries right after it.
+	want := []string{
+		"Dockerfile", "a", "a/b.txt", "a/c", "a/c/d.txt", "a.txt",
+		"deep", "deep/1", "deep/1/2", "deep/1/2/3", "deep/1/2/3/4", "deep/1/2/3/4/5.txt",
+		"z", "z/keep.txt",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("entries = %q\nwant %q", names, want)
+	}
+	if contents["a/c/d.txt"] != "d" {
+		t.Errorf("a/c/d.txt = %q, want d", contents["a/c/d.txt"])
+	}
+}
+
+func TestContextTarballSymlink(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFiles(t, dir, map[string]string{"config/app.yaml": "a: 1"})
+	if err := os.Symlink("config/app.yaml", filepath.Join(dir, "app.yaml")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink("config", filepath.Join(dir, "conf")); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := contextTarball(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, _ := readContext(t, rc)
+	links := map[string]string{}
+	for _, h := range headers {
+		if h.Typeflag == tar.TypeSymlink {
+			links[h.Name] = h.Linkname
+		}
+		if strings.HasPrefix(h.Name, "conf/") {
+			t.Errorf("the symlinked directory was followed: %s", h.Name)
+		}
+	}
+	if links["app.yaml"] != "config/app.yaml" || links["conf"] != "config" {
+		t.Errorf("symlinks = %v, want app.yaml -> config/app.yaml and conf -> config", links)
+	}
+}
+
+func TestScanContextSurfacesErrors(t *testing.T) {
+	if _, err := scanContext(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("scanContext of a missing directory returned no error")
+	}
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("directory permissions are not enforced")
+	}
+	dir := t.TempDir()
+	writeContextFiles(t, dir, map[string]string{"Dockerfile": "FROM scratch\n", "locked/x": "x"})
+	if err := os.Chmod(filepath.Join(dir, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(dir, "locked"), 0o755)
+	if _, err := contextTarball(dir, nil, nil); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("contextTarball with an unreadable directory = %v, want an error naming it", err)
+	}
+}
+
+func TestScanContextSize(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFiles(t, dir, map[string]string{"a": "12345", "b/c": "123", "skip/d": "1234567"})
+	c, err := scanContext(dir, []string{"skip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.files != 2 || c.size != 8 {
+		t.Errorf("scanContext = %d files, %d bytes; want 2 files, 8 bytes", c.files, c.size)
+	}
+}
+
+func TestContextTarballSparseFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 MiB hole, 4 bytes of data, then a 1 MiB hole at the end.
+	if _, err := f.WriteAt([]byte("data"), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(2<<20 + 4); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	rc, err := contextTarball(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, contents := readContext(t, rc)
+	got := contents["disk.img"]
+	want := strings.Repeat("\x00", 1<<20) + "data" + strings.Repeat("\x00", 1<<20)
+	if got != want {
+		t.Errorf("disk.img is %d bytes with data at %d; want %d bytes with data at %d", len(got), strings.Index(got, "data"), len(want), 1<<20)
+	}
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// ImageDigest returns the registry digest ("sha256:...") of a pushed image
+// as recorded by the local Docker daemon. It prefers the repo digest that
+// belongs to imageRef's repository and falls back to the local image ID when
+// the image has not been pushed anywhere yet. It never prints, so callers can
+// use it for bookkeeping without cluttering the pipeline output. For an image
+// whose push was skipped as up to date, it is the digest found in the registry.
+func ImageDigest(imageRef string) (string, error) {
+	if digest, ok := upToDateDigests.Load(imageRef); ok {
+		return digest.(string), nil
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	inspect, err := cli.ImageInspect(ctx, imageRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+	}
+
+	if digest := repoDigestFor(inspect.RepoDigests, imageRef); digest != "" {
+		return digest, nil
+	}
+	return inspect.ID, nil
+}
+
+// repoDigestFor picks the digest out of a "repo@sha256:..." RepoDigests list
+// that matches imageRef's repository, or the first one if none match.
+func repoDigestFor(repoDigests []string, imageRef string) string {
+	repo := imageRepository(imageRef)
+
+	fallback := ""
+	for _, rd := range repoDigests {
+		name, digest, ok := strings.Cut(rd, "@")
+		if !ok {
+			continue
+		}
+		if name == repo {
+			return digest
+		}
+		if fallback == "" {
+			fallback = digest
+		}
+	}
+	return fallback
+}
+
+// imageRepository strips the tag (and digest) from an image reference,
+// keeping a registry port intact.
+func imageRepository(imageRef string) string {
+	repo, _, _ := strings.Cut(imageRef, "@")
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	return repo
+}
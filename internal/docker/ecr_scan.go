@@ -0,0 +1,340 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+)
+
+// DefaultECRScanTimeout bounds the wait for the scan ECR runs on push.
+const DefaultECRScanTimeout = 10 * time.Minute
+
+const (
+	ecrScanPollInterval = 5 * time.Second
+	// ecrScanStartGrace is how long a scan may take to show up after the
+	// push before the repository is taken not to scan on push.
+	ecrScanStartGrace = time.Minute
+)
+
+// ECRScanFinding is a vulnerability ECR's scan found in an image.
+type ECRScanFinding struct {
+	ID       string
+	Severity string
+	Package  string
+	URI      string
+}
+
+// ECRScanResult is the outcome of a completed ECR image scan.
+type ECRScanResult struct {
+	Counts   map[string]int64 // findings per severity
+	Findings []ECRScanFinding
+}
+
+// Failing returns the number of findings of severity failOn or higher.
+func (r *ECRScanResult) Failing(failOn string) int64 {
+	var n int64
+	for _, severity := range severitiesFrom(failOn) {
+		n += r.Counts[severity]
+	}
+	return n
+}
+
+// CheckECRScan is checkECRScan for an image pushed to ECR in region by
+// other means than PushImageToECR, e.g. without a Docker daemon.
+func CheckECRScan(region, repository, tag string) error {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return checkECRScan(context.Background(), ecr.New(sess), repository, tag)
+}
+
+// checkECRScan waits for the scan ECR runs when repository:tag is pushed,
+// prints its findings and, with configs.ScanFailOn set, fails when there is
+// one of that severity or higher.
+//
+// With configs.ScanBaselineDigest set, the findings are also compared with
+// the scan of that image, and with configs.ScanFailOnNewCritical the check
+// fails only on CRITICAL findings the baseline image doesn't have.
+func checkECRScan(ctx context.Context, client ecriface.ECRAPI, repository, tag string) error {
+	timeout := configs.EcrScanTimeout
+	if timeout <= 0 {
+		timeout = DefaultECRScanTimeout
+	}
+	image := repository + ":" + tag
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Waiting for the ECR scan of %s...", image))
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := waitForECRScan(ctx, client, repository, tag, ecrScanPollInterval)
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	spinner.Success(fmt.Sprintf("ECR scan of %s complete", image))
+	printECRScan(result, configs.ScanFailOn)
+
+	if configs.ScanBaselineDigest != "" || configs.ScanFailOnNewCritical {
+		var baseline *ECRScanResult
+		if configs.ScanBaselineDigest == "" {
+			pterm.Warning.Println("No previous deploy recorded an image digest; every finding counts as new")
+		} else if baseline, err = baselineECRScan(ctx, client, repository, configs.ScanBaselineDigest); err != nil {
+			pterm.Warning.Printfln("%v; every finding counts as new", err)
+		}
+		diff := diffECRScan(result, baseline)
+		printECRScanDiff(diff, configs.ScanBaselineDigest)
+
+		if n := diff.NewFailing("CRITICAL"); configs.ScanFailOnNewCritical && n > 0 {
+			return fmt.Errorf("ECR scan of %s found %d CRITICAL vulnerabilities that the previously deployed image doesn't have", image, n)
+		}
+	}
+
+	if n := result.Failing(configs.ScanFailOn); configs.ScanFailOn != "" && n > 0 {
+		return fmt.Errorf("ECR scan of %s found %d vulnerabilities of severity %s or higher",
+			image, n, strings.ToUpper(configs.ScanFailOn))
+	}
+	return nil
+}
+
+// ECRScanDiff splits the findings of a scan into those the baseline image
+// has too and those it introduces.
+type ECRScanDiff struct {
+	New      []ECRScanFinding
+	Existing []ECRScanFinding
+}
+
+// NewFailing returns the number of new findings of severity failOn or
+// higher.
+func (d ECRScanDiff) NewFailing(failOn string) int {
+	n := 0
+	for _, f := range d.New {
+		if severityRank(f.Severity) >= severityRank(failOn) {
+			n++
+		}
+	}
+	return n
+}
+
+// diffECRScan compares the findings of current with those of baseline. A
+// finding is pre-existing when the baseline has the same vulnerability in
+// the same package, whatever its version; a nil baseline makes every
+// finding new.
+func diffECRScan(current, baseline *ECRScanResult) ECRScanDiff {
+	known := map[string]bool{}
+	if baseline != nil {
+		for _, f := range baseline.Findings {
+			known[f.key()] = true
+		}
+	}
+	var diff ECRScanDiff
+	for _, f := range current.Findings {
+		if known[f.key()] {
+			diff.Existing = append(diff.Existing, f)
+		} else {
+			diff.New = append(diff.New, f)
+		}
+	}
+	return diff
+}
+
+// key identifies the vulnerability of a finding across image versions: its
+// ID and the name of the affected package, without the package version.
+func (f ECRScanFinding) key() string {
+	name, _, _ := strings.Cut(f.Package, " ")
+	return f.ID + "|" + name
+}
+
+// baselineECRScan returns the findings of the completed scan of the image
+// with digest in repository.
+func baselineECRScan(ctx context.Context, client ecriface.ECRAPI, repository, digest string) (*ECRScanResult, error) {
+	input := &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(repository),
+		ImageId:        &ecr.ImageIdentifier{ImageDigest: aws.String(digest)},
+	}
+	out, err := client.DescribeImageScanFindingsWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ECR scan findings of the previously deployed image %s: %w", digest, err)
+	}
+	status := ""
+	if out.ImageScanStatus != nil {
+		status = aws.StringValue(out.ImageScanStatus.Status)
+	}
+	if status != ecr.ScanStatusComplete && status != ecr.ScanStatusActive {
+		return nil, fmt.Errorf("the ECR scan of the previously deployed image %s is not complete (status %q)", digest, status)
+	}
+	return collectECRFindings(ctx, client, input, out)
+}
+
+// printECRScanDiff prints the findings the pushed image introduces over
+// the baseline image, and how many it shares with it.
+func printECRScanDiff(diff ECRScanDiff, baseline string) {
+	against := "the previously deployed image"
+	if baseline != "" {
+		against += " (" + shortDigest(baseline) + ")"
+	}
+	if len(diff.New) == 0 {
+		pterm.Success.Printfln("No new vulnerabilities compared with %s; %d pre-existing", against, len(diff.Existing))
+		return
+	}
+	pterm.Warning.Printfln("%d new vulnerabilities compared with %s; %d pre-existing", len(diff.New), against, len(diff.Existing))
+	tableData := pterm.TableData{{"SEVERITY", "NEW VULNERABILITY", "PACKAGE", "LINK"}}
+	for _, f := range diff.New {
+		tableData = append(tableData, []string{f.Severity, f.ID, f.Package, f.URI})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// waitForECRScan polls the scan findings of repository:tag until the scan
+// is complete, and returns them all.
+func waitForECRScan(ctx context.Context, client ecriface.ECRAPI, repository, tag string, interval time.Duration) (*ECRScanResult, error) {
+	input := &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(repository),
+		ImageId:        &ecr.ImageIdentifier{ImageTag: aws.String(tag)},
+	}
+	started := time.Now()
+	for {
+		out, err := client.DescribeImageScanFindingsWithContext(ctx, input)
+		var aerr awserr.Error
+		switch {
+		case errors.As(err, &aerr) && aerr.Code() == ecr.ErrCodeScanNotFoundException:
+			if time.Since(started) > ecrScanStartGrace {
+				return nil, fmt.Errorf("ECR did not scan %s:%s; enable scan on push for the repository", repository, tag)
+			}
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("ECR scan of %s:%s did not complete in time", repository, tag)
+			}
+			return nil, fmt.Errorf("failed to get the ECR scan findings of %s:%s: %w", repository, tag, err)
+		default:
+			status, description := "", ""
+			if out.ImageScanStatus != nil {
+				status, description = aws.StringValue(out.ImageScanStatus.Status), aws.StringValue(out.ImageScanStatus.Description)
+			}
+			switch status {
+			case ecr.ScanStatusComplete, ecr.ScanStatusActive:
+				return collectECRFindings(ctx, client, input, out)
+			case ecr.ScanStatusInProgress, ecr.ScanStatusPending, "":
+			default:
+				return nil, fmt.Errorf("ECR scan of %s:%s ended with status %s: %s", repository, tag, status, description)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("ECR scan of %s:%s did not complete in time", repository, tag)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// collectECRFindings gathers the findings of a completed scan from first
+// and the pages after it.
+func collectECRFindings(ctx context.Context, client ecriface.ECRAPI, input *ecr.DescribeImageScanFindingsInput, first *ecr.DescribeImageScanFindingsOutput) (*ECRScanResult, error) {
+	result := &ECRScanResult{Counts: map[string]int64{}}
+	out := first
+	for {
+		if f := out.ImageScanFindings; f != nil {
+			if len(result.Counts) == 0 {
+				for severity, n := range f.FindingSeverityCounts {
+					result.Counts[severity] = aws.Int64Value(n)
+				}
+			}
+			for _, finding := range f.Findings {
+				result.Findings = append(result.Findings, basicECRFinding(finding))
+			}
+			for _, finding := range f.EnhancedFindings {
+				result.Findings = append(result.Findings, enhancedECRFinding(finding))
+			}
+		}
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		next := *input
+		next.NextToken = out.NextToken
+		var err error
+		if out, err = client.DescribeImageScanFindingsWithContext(ctx, &next); err != nil {
+			return nil, fmt.Errorf("failed to get the ECR scan findings: %w", err)
+		}
+	}
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := severityRank(result.Findings[i].Severity), severityRank(result.Findings[j].Severity)
+		if a != b {
+			return a > b
+		}
+		return result.Findings[i].ID < result.Findings[j].ID
+	})
+	return result, nil
+}
+
+func basicECRFinding(f *ecr.ImageScanFinding) ECRScanFinding {
+	finding := ECRScanFinding{ID: aws.StringValue(f.Name), Severity: aws.StringValue(f.Severity), URI: aws.StringValue(f.Uri)}
+	var name, version string
+	for _, a := range f.Attributes {
+		switch aws.StringValue(a.Key) {
+		case "package_name":
+			name = aws.StringValue(a.Value)
+		case "package_version":
+			version = aws.StringValue(a.Value)
+		}
+	}
+	finding.Package = strings.TrimSuffix(name+" "+version, " ")
+	return finding
+}
+
+func enhancedECRFinding(f *ecr.EnhancedImageScanFinding) ECRScanFinding {
+	finding := ECRScanFinding{Severity: aws.StringValue(f.Severity)}
+	if d := f.PackageVulnerabilityDetails; d != nil {
+		finding.ID = aws.StringValue(d.VulnerabilityId)
+		finding.URI = aws.StringValue(d.SourceUrl)
+		if len(d.VulnerablePackages) > 0 {
+			p := d.VulnerablePackages[0]
+			finding.Package = strings.TrimSuffix(aws.StringValue(p.Name)+" "+aws.StringValue(p.Version), " ")
+		}
+	}
+	if finding.ID == "" {
+		finding.ID = aws.StringValue(f.Title)
+	}
+	return finding
+}
+
+// printECRScan prints the finding counts per severity and the findings of
+// severity failOn or higher, or all of them when failOn is empty.
+func printECRScan(result *ECRScanResult, failOn string) {
+	if len(result.Counts) == 0 {
+		pterm.Success.Println("ECR scan found no vulnerabilities")
+		return
+	}
+	var counts []string
+	for i := len(scanSeverities) - 1; i >= 0; i-- {
+		if n := result.Counts[scanSeverities[i]]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", scanSeverities[i], n))
+		}
+	}
+	for _, other := range []string{ecr.FindingSeverityInformational, ecr.FindingSeverityUndefined} {
+		if n := result.Counts[other]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", other, n))
+		}
+	}
+	pterm.Info.Printfln("ECR scan findings: %s", strings.Join(counts, ", "))
+
+	tableData := pterm.TableData{{"SEVERITY", "VULNERABILITY", "PACKAGE", "LINK"}}
+	for _, f := range result.Findings {
+		if failOn != "" && severityRank(f.Severity) < severityRank(failOn) {
+			continue
+		}
+		tableData = append(tableData, []string{f.Severity, f.ID, f.Package, f.URI})
+	}
+	if len(tableData) > 1 {
+		_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
+}
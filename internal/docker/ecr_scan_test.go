@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/clouddrove/smurf/configs"
+)
+
+// fakeScanECR answers DescribeImageScanFindings with its responses in turn,
+// repeating the last one.
+type fakeScanECR struct {
+	ecriface.ECRAPI
+	responses []*ecr.DescribeImageScanFindingsOutput
+	errs      []error
+	calls     int
+}
+
+func (f *fakeScanECR) DescribeImageScanFindingsWithContext(_ aws.Context, in *ecr.DescribeImageScanFindingsInput, _ ...request.Option) (*ecr.DescribeImageScanFindingsOutput, error) {
+	i := f.calls
+	if i >= len(f.responses) {
+		i = len(f.responses) - 1
+	}
+	f.calls++
+	return f.responses[i], f.errs[i]
+}
+
+func scanOutput(status string, counts map[string]int64, next string, findings ...*ecr.ImageScanFinding) *ecr.DescribeImageScanFindingsOutput {
+	out := &ecr.DescribeImageScanFindingsOutput{
+		ImageScanStatus: &ecr.ImageScanStatus{Status: aws.String(status)},
+	}
+	if status == ecr.ScanStatusComplete {
+		out.ImageScanFindings = &ecr.ImageScanFindings{FindingSeverityCounts: aws.Int64Map(counts), Findings: findings}
+	}
+	if next != "" {
+		out.NextToken = aws.String(next)
+	}
+	return out
+}
+
+func finding(id, severity, pkg string) *ecr.ImageScanFinding {
+	return &ecr.ImageScanFinding{
+		Name:     aws.String(id),
+		Severity: aws.String(severity),
+		Attributes: []*ecr.Attribute{
+			{Key: aws.String("package_name"), Value: aws.String(pkg)},
+			{Key: aws.String("package_version"), Value: aws.String("1.0")},
+		},
+	}
+}
+
+func TestWaitForECRScan(t *testing.T) {
+	counts := map[string]int64{"CRITICAL": 1, "LOW": 1}
+	client := &fakeScanECR{
+		responses: []*ecr.DescribeImageScanFindingsOutput{
+			nil,
+			scanOutput(ecr.ScanStatusInProgress, nil, ""),
+			scanOutput(ecr.ScanStatusComplete, counts, "page2", finding("CVE-2", "LOW", "zlib")),
+			scanOutput(ecr.ScanStatusComplete, counts, "", finding("CVE-1", "CRITICAL", "openssl")),
+		},
+		errs: []error{awserr.New(ecr.ErrCodeScanNotFoundException, "not yet", nil), nil, nil, nil},
+	}
+
+	result, err := waitForECRScan(context.Background(), client, "app", "v1", time.Millisecond)
+	if err != nil {
+		t.Fatalf("waitForECRScan: %v", err)
+	}
+	if len(result.Findings) != 2 || result.Findings[0].ID != "CVE-1" || result.Findings[0].Package != "openssl 1.0" {
+		t.Errorf("findings = %+v, want CVE-1 (openssl 1.0) first", result.Findings)
+	}
+	if n := result.Failing("high"); n != 1 {
+		t.Errorf("Failing(high) = %d, want 1", n)
+	}
+	if n := result.Failing("low"); n != 2 {
+		t.Errorf("Failing(low) = %d, want 2", n)
+	}
+}
+
+func TestWaitForECRScanFailed(t *testing.T) {
+	out := scanOutput(ecr.ScanStatusUnsupportedImage, nil, "")
+	out.ImageScanStatus.Description = aws.String("The operating system is not supported")
+	client := &fakeScanECR{responses: []*ecr.DescribeImageScanFindingsOutput{out}, errs: []error{nil}}
+
+	_, err := waitForECRScan(context.Background(), client, "app", "v1", time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "UNSUPPORTED_IMAGE") {
+		t.Errorf("err = %v, want the UNSUPPORTED_IMAGE status", err)
+	}
+}
+
+func TestWaitForECRScanTimeout(t *testing.T) {
+	client := &fakeScanECR{
+		responses: []*ecr.DescribeImageScanFindingsOutput{scanOutput(ecr.ScanStatusInProgress, nil, "")},
+		errs:      []error{nil},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := waitForECRScan(ctx, client, "app", "v1", time.Millisecond); err == nil || !strings.Contains(err.Error(), "in time") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
+
+func TestCheckECRScanAgainstBaseline(t *testing.T) {
+	oldBaseline, oldGate, oldTimeout := configs.ScanBaselineDigest, configs.ScanFailOnNewCritical, configs.EcrScanTimeout
+	defer func() {
+		configs.ScanBaselineDigest, configs.ScanFailOnNewCritical, configs.EcrScanTimeout = oldBaseline, oldGate, oldTimeout
+	}()
+	configs.ScanBaselineDigest, configs.ScanFailOnNewCritical, configs.EcrScanTimeout = "sha256:aaa", true, time.Second
+
+	current := scanOutput(ecr.ScanStatusComplete, map[string]int64{"CRITICAL": 2}, "",
+		finding("CVE-1", "CRITICAL", "openssl"), finding("CVE-3", "CRITICAL", "glibc"))
+	legacy := scanOutput(ecr.ScanStatusComplete, map[string]int64{"CRITICAL": 1}, "", finding("CVE-1", "CRITICAL", "openssl"))
+
+	// The baseline has the critical CVE-1 too: it doesn't block the deploy.
+	client := &fakeScanECR{responses: []*ecr.DescribeImageScanFindingsOutput{legacy, legacy}, errs: []error{nil, nil}}
+	if err := checkECRScan(context.Background(), client, "app", "v2"); err != nil {
+		t.Errorf("checkECRScan with only pre-existing criticals = %v", err)
+	}
+
+	client = &fakeScanECR{responses: []*ecr.DescribeImageScanFindingsOutput{current, legacy}, errs: []error{nil, nil}}
+	err := checkECRScan(context.Background(), client, "app", "v2")
+	if err == nil || !strings.Contains(err.Error(), "1 CRITICAL") {
+		t.Errorf("checkECRScan with a new critical = %v, want it to fail on CVE-3 alone", err)
+	}
+}
+
+func TestDiffECRScan(t *testing.T) {
+	current := &ECRScanResult{Findings: []ECRScanFinding{
+		{ID: "CVE-1", Severity: "CRITICAL", Package: "openssl 3.0.2"},
+		{ID: "CVE-2", Severity: "HIGH", Package: "zlib 1.2"},
+		{ID: "CVE-1", Severity: "CRITICAL", Package: "libssl 3.0.2"},
+	}}
+	baseline := &ECRScanResult{Findings: []ECRScanFinding{
+		{ID: "CVE-1", Severity: "CRITICAL", Package: "openssl 3.0.1"},
+		{ID: "CVE-9", Severity: "LOW", Package: "bash 5"},
+	}}
+
+	diff := diffECRScan(current, baseline)
+	if len(diff.Existing) != 1 || diff.Existing[0].Package != "openssl 3.0.2" {
+		t.Errorf("pre-existing = %+v, want CVE-1 in openssl whatever its version", diff.Existing)
+	}
+	if len(diff.New) != 2 || diff.NewFailing("critical") != 1 || diff.NewFailing("high") != 2 {
+		t.Errorf("new = %+v, want CVE-2 in zlib and CVE-1 in libssl", diff.New)
+	}
+	if all := diffECRScan(current, nil); len(all.New) != 3 {
+		t.Errorf("without a baseline, new = %+v, want every finding", all.New)
+	}
+}
+
+func TestValidScanSeverity(t *testing.T) {
+	for _, s := range []string{"", "low", "HIGH", "Critical"} {
+		if err := ValidScanSeverity(s); err != nil {
+			t.Errorf("ValidScanSeverity(%q) = %v", s, err)
+		}
+	}
+	if err := ValidScanSeverity("severe"); err == nil {
+		t.Error("ValidScanSeverity(severe) = nil, want an error")
+	}
+	if got := strings.Join(severitiesFrom("medium"), ","); got != "MEDIUM,HIGH,CRITICAL" {
+		t.Errorf("severitiesFrom(medium) = %s", got)
+	}
+}
@@ -1,15 +1,28 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/clouddrove/smurf/configs"
 	"github.com/docker/docker/api/types/registry"
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // captureStdout runs fn with os.Stdout redirected to a pipe and returns what fn wrote.
@@ -250,3 +263,794 @@ func TestIsSafeBinary(t *testing.T) {
 		}
 	})
 }
+
+func TestRepoDigestFor(t *testing.T) {
+	digests := []string{
+		"org/web@sha256:aaa",
+		"123.dkr.ecr.us-east-1.amazonaws.com/web@sha256:bbb",
+	}
+	cases := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"matching repo with tag", "123.dkr.ecr.us-east-1.amazonaws.com/web:v1", "sha256:bbb"},
+		{"matching repo without tag", "org/web", "sha256:aaa"},
+		{"registry with port", "localhost:5000/web:v1", "sha256:aaa"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := repoDigestFor(digests, c.ref); got != c.want {
+				t.Errorf("repoDigestFor(%q) = %q, want %q", c.ref, got, c.want)
+			}
+		})
+	}
+
+	if got := repoDigestFor(nil, "org/web:v1"); got != "" {
+		t.Errorf("repoDigestFor(nil) = %q, want empty", got)
+	}
+}
+
+func TestScanCache(t *testing.T) {
+	dir := t.TempDir()
+	digest := "sha256:0123abcd"
+
+	if _, ok := loadScanCache(dir, digest, "json", time.Hour); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	entry := scanCacheEntry{Image: "app:v1", Digest: digest, Format: "json", ScannedAt: time.Now(), Output: `{"Results":[]}`}
+	if err := saveScanCache(dir, entry); err != nil {
+		t.Fatalf("saveScanCache: %v", err)
+	}
+
+	got, ok := loadScanCache(dir, digest, "json", time.Hour)
+	if !ok || got.Output != entry.Output {
+		t.Fatalf("loadScanCache = %+v, %v; want cached output", got, ok)
+	}
+
+	if _, ok := loadScanCache(dir, digest, "table", time.Hour); ok {
+		t.Error("reports must be cached per format")
+	}
+	if _, ok := loadScanCache(dir, "sha256:ffff", "json", time.Hour); ok {
+		t.Error("a different digest must miss")
+	}
+
+	entry.ScannedAt = time.Now().Add(-2 * time.Hour)
+	if err := saveScanCache(dir, entry); err != nil {
+		t.Fatalf("saveScanCache: %v", err)
+	}
+	if _, ok := loadScanCache(dir, digest, "json", time.Hour); ok {
+		t.Error("entries older than the TTL must miss")
+	}
+}
+
+func TestAcrPlatform(t *testing.T) {
+	got, err := acrPlatform("")
+	if err != nil || *got.OS != "Linux" || *got.Architecture != "amd64" {
+		t.Fatalf("acrPlatform(\"\") = %+v, %v; want linux/amd64", got, err)
+	}
+
+	got, err = acrPlatform("linux/arm64/v8")
+	if err != nil || *got.Architecture != "arm64" || *got.Variant != "v8" {
+		t.Fatalf("acrPlatform(linux/arm64/v8) = %+v, %v", got, err)
+	}
+
+	if _, err := acrPlatform("darwin/arm64"); err == nil {
+		t.Error("expected an error for an OS ACR Tasks can't build for")
+	}
+}
+
+func TestAcrTaskTimeout(t *testing.T) {
+	cases := map[time.Duration]int32{
+		0:                acrTaskMinTimeout,
+		25 * time.Minute: 1500,
+		24 * time.Hour:   acrTaskMaxTimeout,
+	}
+	for in, want := range cases {
+		if got := acrTaskTimeout(in); got != want {
+			t.Errorf("acrTaskTimeout(%v) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestBuildArtifactsManifest(t *testing.T) {
+	dir := t.TempDir()
+	sbom := filepath.Join(dir, "sbom.spdx.json")
+	if err := os.WriteFile(sbom, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := buildArtifactsManifest("registry.example.com:5000/app:v1", ArtifactsOptions{
+		Path:       filepath.Join(dir, "artifacts.json"),
+		SBOM:       sbom,
+		Signatures: []string{"registry.example.com:5000/app:sha256-abc.sig"},
+	})
+	if err != nil {
+		t.Fatalf("buildArtifactsManifest: %v", err)
+	}
+	if m.SchemaVersion != 1 || m.Image != "registry.example.com:5000/app:v1" || m.SBOM != sbom || len(m.Signatures) != 1 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+
+	if _, err := buildArtifactsManifest("app:v1", ArtifactsOptions{Path: "a.json", ScanReport: filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("expected an error for a scan report that doesn't exist")
+	}
+
+	if got := imageRepository("registry.example.com:5000/app:v1"); got != "registry.example.com:5000/app" {
+		t.Errorf("imageRepository = %q", got)
+	}
+}
+
+func TestParseRemoteImage(t *testing.T) {
+	cases := map[string]remoteImage{
+		"myapp":                          {Host: dockerHubRegistry, Repository: "library/myapp", Tag: "latest"},
+		"my-org/myapp:v1":                {Host: dockerHubRegistry, Repository: "my-org/myapp", Tag: "v1"},
+		"ghcr.io/my-org/myapp:v1":        {Host: "ghcr.io", Repository: "my-org/myapp", Tag: "v1"},
+		"localhost:5000/team/myapp:rc-1": {Host: "localhost:5000", Repository: "team/myapp", Tag: "rc-1"},
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:1.0": {Host: "123456789012.dkr.ecr.us-east-1.amazonaws.com", Repository: "myapp", Tag: "1.0"},
+	}
+	for ref, want := range cases {
+		got, err := parseRemoteImage(ref)
+		if err != nil {
+			t.Errorf("parseRemoteImage(%q): %v", ref, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseRemoteImage(%q) = %+v, want %+v", ref, got, want)
+		}
+	}
+
+	if _, err := parseRemoteImage("Invalid//Ref"); err == nil {
+		t.Error("expected an error for an invalid reference")
+	}
+}
+
+func TestPushPlanRoundTrip(t *testing.T) {
+	plan := &PushPlan{
+		SchemaVersion: 1,
+		Source:        "myapp:v1",
+		SourceID:      "sha256:abc",
+		Targets: []PushTarget{
+			{Image: "ghcr.io/org/myapp:v1", Status: TargetPushed, Digest: "sha256:def"},
+			{Image: "ghcr.io/org/myapp:latest", Status: TargetFailed, Error: "denied"},
+			{Image: "ghcr.io/org/myapp:stable", Status: TargetPending},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadPushPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPushPlan: %v", err)
+	}
+	if got := loaded.remaining(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("remaining = %v, want [1 2]", got)
+	}
+	if loaded.Targets[0].Digest != "sha256:def" {
+		t.Errorf("digest not kept: %+v", loaded.Targets[0])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"schemaVersion":2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPushPlan(path); err == nil {
+		t.Error("expected an error for an unknown schema version")
+	}
+}
+
+func TestPushConcurrently(t *testing.T) {
+	var running, peak atomic.Int32
+	pushFunc = func(builder, source string, target *PushTarget, timeout time.Duration, progress bool) error {
+		if progress {
+			t.Errorf("concurrent push of %s asked for progress output", target.Image)
+		}
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if strings.HasPrefix(target.Image, "ghcr.io") {
+			return errors.New("denied")
+		}
+		target.Digest = "sha256:" + target.Image
+		return nil
+	}
+	t.Cleanup(func() { pushFunc = pushTarget })
+
+	plan := &PushPlan{
+		Source: "myapp:v1",
+		Targets: []PushTarget{
+			{Image: "123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:v1", Status: TargetPending},
+			{Image: "ghcr.io/org/myapp:v1", Status: TargetPending},
+			{Image: "docker.io/org/myapp:v1", Status: TargetFailed, Error: "timeout"},
+			{Image: "europe-docker.pkg.dev/proj/images/myapp:v1", Status: TargetPushed},
+		},
+	}
+	pushed, err := pushConcurrently(plan, MultiPushOptions{Concurrency: 2, Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 target(s) failed") || !strings.Contains(err.Error(), "ghcr.io/org/myapp:v1") {
+		t.Fatalf("pushConcurrently error = %v", err)
+	}
+	if !slices.Equal(pushed, []int{0, 2}) {
+		t.Errorf("pushed = %v, want [0 2]", pushed)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("%d pushes ran at once, want 2", got)
+	}
+
+	wantStatus := []string{TargetPushed, TargetFailed, TargetPushed, TargetPushed}
+	for i, target := range plan.Targets {
+		if target.Status != wantStatus[i] {
+			t.Errorf("target %s is %s, want %s", target.Image, target.Status, wantStatus[i])
+		}
+		if i < 3 && target.Duration == "" {
+			t.Errorf("target %s has no duration", target.Image)
+		}
+	}
+	if plan.Targets[1].Error != "denied" || plan.Targets[2].Error != "" {
+		t.Errorf("errors not recorded per target: %+v", plan.Targets)
+	}
+}
+
+func TestNewPushEvent(t *testing.T) {
+	e := NewPushEvent("ghcr.io/org/myapp:v1", "sha256:abc")
+	if e.Repository != "ghcr.io/org/myapp" || e.Tag != "v1" || e.Digest != "sha256:abc" || e.Event != "push" {
+		t.Errorf("NewPushEvent = %+v", e)
+	}
+	if e := NewPushEvent("localhost:5000/myapp", ""); e.Repository != "localhost:5000/myapp" || e.Tag != "latest" {
+		t.Errorf("untagged image: %+v", e)
+	}
+}
+
+func TestWebhookPayloadDockerHub(t *testing.T) {
+	body, err := webhookPayload(configs.WebhookConfig{Format: "dockerhub"}, NewPushEvent("docker.io/acme/myapp:v2", ""))
+	if err != nil {
+		t.Fatalf("webhookPayload: %v", err)
+	}
+	var got struct {
+		PushData   struct{ Tag string } `json:"push_data"`
+		Repository struct {
+			RepoName  string `json:"repo_name"`
+			Namespace string
+			Name      string
+		}
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PushData.Tag != "v2" || got.Repository.RepoName != "acme/myapp" || got.Repository.Namespace != "acme" || got.Repository.Name != "myapp" {
+		t.Errorf("payload = %s", body)
+	}
+
+	if _, err := webhookPayload(configs.WebhookConfig{Format: "xml"}, PushEvent{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestNotifyWebhooks(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = 2 * time.Second }()
+
+	var calls int
+	var signature string
+	var body []byte
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		signature = r.Header.Get(WebhookSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ok.Close()
+	rejected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer rejected.Close()
+
+	event := NewPushEvent("ghcr.io/org/myapp:v1", "sha256:abc")
+	hooks := []configs.WebhookConfig{
+		{URL: ok.URL, Secret: "s3cret"},
+		{Name: "best-effort", URL: rejected.URL},
+	}
+	if err := NotifyWebhooks(hooks, event); err != nil {
+		t.Fatalf("NotifyWebhooks: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want a retry after the 502", calls)
+	}
+	if want := signWebhookPayload("s3cret", body); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	var got PushEvent
+	if err := json.Unmarshal(body, &got); err != nil || got.Digest != "sha256:abc" {
+		t.Errorf("body = %s", body)
+	}
+
+	hooks[1].FailOnError = true
+	if err := NotifyWebhooks(hooks, event); err == nil || !strings.Contains(err.Error(), "best-effort") {
+		t.Errorf("expected the failOnError webhook to fail the call, got %v", err)
+	}
+}
+
+func TestBakeArgs(t *testing.T) {
+	opts := BakeOptions{
+		Files:   []string{"docker-bake.hcl"},
+		Targets: []string{"api", "worker"},
+		Set:     []string{"*.args.VERSION=1.0"},
+		NoCache: true,
+	}
+	got := strings.Join(bakeArgs(opts, true, "meta.json"), " ")
+	want := "buildx bake --progress=plain --metadata-file meta.json --load --no-cache --file docker-bake.hcl --set *.args.VERSION=1.0 api worker"
+	if got != want {
+		t.Errorf("bakeArgs = %q, want %q", got, want)
+	}
+
+	opts.Push = true
+	if got := bakeArgs(opts, true, "meta.json"); !slices.Contains(got, "--push") || slices.Contains(got, "--load") {
+		t.Errorf("push bakeArgs = %v, want --push without --load", got)
+	}
+}
+
+func TestBakeResults(t *testing.T) {
+	def := &BakeDefinition{Target: map[string]BakeTarget{
+		"worker": {Tags: []string{"repo/worker:1"}},
+		"api":    {Tags: []string{"repo/api:1", "repo/api:latest"}, Platforms: []string{"linux/amd64", "linux/arm64"}},
+	}}
+	metadata := []byte(`{
+  "api": {"containerimage.digest": "sha256:aaa", "image.name": "repo/api:1,repo/api:latest"},
+  "worker": {"buildx.build.ref": "builder/ref"}
+}`)
+
+	results, err := bakeResults(def, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Target != "api" || results[1].Target != "worker" {
+		t.Fatalf("results = %+v, want api then worker", results)
+	}
+	if results[0].Digest != "sha256:aaa" || len(results[0].Tags) != 2 || !def.multiPlatform() {
+		t.Errorf("api result = %+v", results[0])
+	}
+	if results[1].Digest != "" {
+		t.Errorf("worker digest = %q, want none", results[1].Digest)
+	}
+	if _, err := bakeResults(def, []byte("{")); err == nil {
+		t.Error("expected an error for malformed metadata")
+	}
+}
+
+func TestPullCredential(t *testing.T) {
+	t.Setenv("DOCKER_USERNAME", "hubuser")
+	t.Setenv("DOCKER_PASSWORD", "hubpass")
+
+	server, username, password, err := PullCredential(context.Background(), "myorg/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server != "https://index.docker.io/v1/" || username != "hubuser" || password != "hubpass" {
+		t.Errorf("PullCredential = %q %q %q", server, username, password)
+	}
+}
+
+func TestSplitArtifactFile(t *testing.T) {
+	tests := []struct {
+		arg, path, mediaType string
+	}{
+		{"sbom.json", "sbom.json", DefaultArtifactMediaType},
+		{"sbom.json:application/spdx+json", "sbom.json", "application/spdx+json"},
+		{`C:\charts\app.tgz`, `C:\charts\app.tgz`, DefaultArtifactMediaType},
+		{`C:\charts\app.tgz:application/x-tar`, `C:\charts\app.tgz`, "application/x-tar"},
+	}
+	for _, tt := range tests {
+		path, mediaType := splitArtifactFile(tt.arg)
+		if path != tt.path || mediaType != tt.mediaType {
+			t.Errorf("splitArtifactFile(%q) = %q, %q; want %q, %q", tt.arg, path, mediaType, tt.path, tt.mediaType)
+		}
+	}
+}
+
+func TestArtifactManifestInfo(t *testing.T) {
+	m := ocispec.Manifest{
+		Config: ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{
+			{MediaType: DefaultArtifactMediaType, Annotations: map[string]string{ocispec.AnnotationTitle: "a.txt"}},
+			{MediaType: DefaultArtifactMediaType},
+			{MediaType: DefaultArtifactMediaType, Annotations: map[string]string{ocispec.AnnotationTitle: "bundle"}},
+		},
+	}
+	if got := layerTitles(m); !slices.Equal(got, []string{"a.txt", "bundle"}) {
+		t.Errorf("layerTitles = %v", got)
+	}
+	if got := artifactTypeOf(m); got != "" {
+		t.Errorf("artifactTypeOf(empty config) = %q, want none", got)
+	}
+	m.Config = ocispec.Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json"}
+	if got := artifactTypeOf(m); got != "application/vnd.cncf.helm.config.v1+json" {
+		t.Errorf("artifactTypeOf(helm chart) = %q", got)
+	}
+	m.ArtifactType = "application/spdx+json"
+	if got := artifactTypeOf(m); got != "application/spdx+json" {
+		t.Errorf("artifactTypeOf = %q", got)
+	}
+}
+
+// layerTar builds an uncompressed layer from name → content pairs; a nil
+// content is a directory.
+func layerTar(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		data := files[name]
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if data == nil {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageFileDiff(t *testing.T) {
+	read := func(data []byte) []layerOp {
+		ops, err := layerOps(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ops
+	}
+	base := read(layerTar(t, map[string][]byte{
+		"etc/":            nil,
+		"etc/os-release":  []byte("debian"),
+		"var/cache/":      nil,
+		"var/cache/a.deb": []byte("aaaa"),
+		"var/cache/b.deb": []byte("bb"),
+	}))
+	app1 := read(layerTar(t, map[string][]byte{
+		"app/bin":    []byte("v1"),
+		"app/config": []byte("same"),
+	}))
+
+	// The second image's top layer is gzip-compressed, as in a registry, and
+	// removes the apt cache with an opaque whiteout and a file whiteout.
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(layerTar(t, map[string][]byte{
+		"app/bin":                []byte("v2-bigger"),
+		"app/config":             []byte("same"),
+		"app/README":             []byte("new"),
+		"var/cache/.wh..wh..opq": {},
+		"etc/.wh.os-release":     {},
+	}))
+	zw.Close()
+	app2 := read(gz.Bytes())
+
+	from := applyLayers([][]layerOp{base, app1})
+	to := applyLayers([][]layerOp{base, app2})
+	got := diffTrees(from, to)
+	want := []FileChange{
+		{Path: "/app/README", Change: "added", NewSize: 3},
+		{Path: "/app/bin", Change: "changed", OldSize: 2, NewSize: 9},
+		{Path: "/etc/os-release", Change: "removed", OldSize: 6},
+		{Path: "/var/cache/a.deb", Change: "removed", OldSize: 4},
+		{Path: "/var/cache/b.deb", Change: "removed", OldSize: 2},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diffTrees =\n%+v\nwant\n%+v", got, want)
+	}
+	if treeSize(from) != 18 || treeSize(to) != 16 {
+		t.Errorf("sizes = %d, %d; want 18, 16", treeSize(from), treeSize(to))
+	}
+}
+
+func TestDiffLayers(t *testing.T) {
+	layer := func(id string) imageLayer { return imageLayer{LayerInfo: LayerInfo{DiffID: id}} }
+	shared, removed, added := diffLayers(
+		[]imageLayer{layer("a"), layer("b"), layer("c")},
+		[]imageLayer{layer("a"), layer("x"), layer("c"), layer("d")},
+	)
+	if shared != 1 || len(removed) != 2 || len(added) != 3 {
+		t.Errorf("diffLayers = %d shared, %v removed, %v added", shared, removed, added)
+	}
+}
+
+func TestSelectPlatform(t *testing.T) {
+	index := ocispec.Index{Manifests: []ocispec.Descriptor{
+		{Digest: "sha256:amd", Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{Digest: "sha256:arm", Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+	}}
+	if d, err := selectPlatform(index, "linux/arm64"); err != nil || d.Digest != "sha256:arm" {
+		t.Errorf("selectPlatform(linux/arm64) = %v, %v", d.Digest, err)
+	}
+	if _, err := selectPlatform(index, "windows/amd64"); err == nil || !strings.Contains(err.Error(), "linux/amd64, linux/arm64") {
+		t.Errorf("selectPlatform(windows/amd64) error = %v", err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{512: "512B", 1500: "1.5kB", 2_300_000: "2.3MB", -1500: "-1.5kB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestBuilderArgs(t *testing.T) {
+	opts := BuildOptions{
+		ContextDir:     "/src",
+		DockerfilePath: "/src/docker/Dockerfile.prod",
+		BuildArgs:      map[string]string{"B": "2", "A": "1"},
+		Target:         "runtime",
+		Platform:       "linux/arm64",
+		NoCache:        true,
+	}
+
+	got := strings.Join(nerdctlBuildArgs("app:v1", opts), " ")
+	want := "build -t app:v1 -f /src/docker/Dockerfile.prod --no-cache --target runtime --platform linux/arm64 --build-arg A=1 --build-arg B=2 /src"
+	if got != want {
+		t.Errorf("nerdctl args =\n  %s\nwant\n  %s", got, want)
+	}
+
+	got = strings.Join(buildctlArgs("app:v1", "/store/app_v1.tar", opts), " ")
+	want = "build --frontend dockerfile.v0 --local context=/src --local dockerfile=/src/docker --opt filename=Dockerfile.prod --no-cache" +
+		" --opt target=runtime --opt platform=linux/arm64 --opt build-arg:A=1 --opt build-arg:B=2 --output type=oci,dest=/store/app_v1.tar,name=app:v1"
+	if got != want {
+		t.Errorf("buildctl args =\n  %s\nwant\n  %s", got, want)
+	}
+
+	if err := ValidBuilder("podman"); err == nil {
+		t.Error("ValidBuilder(podman): want an error")
+	}
+}
+
+func TestImageArchivePath(t *testing.T) {
+	t.Setenv("SMURF_IMAGE_STORE", "/store")
+	for ref, want := range map[string]string{
+		"app":                     "/store/app_latest.tar",
+		"app:v1":                  "/store/app_v1.tar",
+		"localhost:5000/team/app": "/store/localhost_5000_team_app_latest.tar",
+	} {
+		got, err := imageArchivePath(ref)
+		if err != nil || got != want {
+			t.Errorf("imageArchivePath(%s) = %s, %v; want %s", ref, got, err, want)
+		}
+	}
+}
+
+func TestArchiveManifest(t *testing.T) {
+	manifest := func(digest string, annotations map[string]string) ocispec.Descriptor {
+		return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: godigest.Digest("sha256:" + digest), Annotations: annotations}
+	}
+	writeArchive := func(manifests ...ocispec.Descriptor) string {
+		index, err := json.Marshal(ocispec.Index{Manifests: manifests})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "image.tar")
+		data := layerTar(t, map[string][]byte{"oci-layout": []byte(`{"imageLayoutVersion":"1.0.0"}`), "./index.json": index})
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// nerdctl save: full names in io.containerd.image.name, tags in ref.name.
+	saved := writeArchive(
+		manifest("aaa", map[string]string{containerdImageName: "docker.io/library/app:v1", ocispec.AnnotationRefName: "v1"}),
+		manifest("bbb", map[string]string{containerdImageName: "docker.io/library/app:v2", ocispec.AnnotationRefName: "v2"}),
+	)
+	for ref, want := range map[string]string{"app:v2": "sha256:bbb", "docker.io/library/app:v1": "sha256:aaa"} {
+		desc, err := archiveManifest(saved, ref)
+		if err != nil || desc.Digest.String() != want {
+			t.Errorf("archiveManifest(%s) = %s, %v; want %s", ref, desc.Digest, err, want)
+		}
+	}
+	if _, err := archiveManifest(saved, "app:v3"); err == nil {
+		t.Error("archiveManifest(app:v3): want an error")
+	}
+
+	// A buildctl archive of one image matches whatever it is called.
+	single := writeArchive(manifest("ccc", nil))
+	if desc, err := archiveManifest(single, "app:v1"); err != nil || desc.Digest != "sha256:ccc" {
+		t.Errorf("archiveManifest(single) = %s, %v; want sha256:ccc", desc.Digest, err)
+	}
+}
+
+func TestCosignVerifyArgs(t *testing.T) {
+	keyless := configs.TrustedIdentity{Issuer: "https://token.actions.githubusercontent.com", Subject: "^https://github.com/my-org/"}
+	tests := []struct {
+		subcommand, attestation string
+		id                      configs.TrustedIdentity
+		want                    string
+	}{
+		{"verify", "", configs.TrustedIdentity{Key: "cosign.pub"}, "verify --key cosign.pub nginx:1.27"},
+		{"verify", "", keyless, "verify --certificate-oidc-issuer https://token.actions.githubusercontent.com --certificate-identity-regexp ^https://github.com/my-org/ nginx:1.27"},
+		{"verify-attestation", "slsaprovenance", configs.TrustedIdentity{Key: "awskms:///alias/cosign"}, "verify-attestation --type slsaprovenance --key awskms:///alias/cosign nginx:1.27"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(cosignVerifyArgs(tt.subcommand, tt.attestation, "nginx:1.27", tt.id), " ")
+		if got != tt.want {
+			t.Errorf("cosignVerifyArgs = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseDockerfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Dockerfile")
+	content := `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.26
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+ARG VERSION
+RUN go build \
+    -ldflags "-X main.version=${VERSION}" \
+    -o /app .
+RUN <<EOF
+echo heredoc lines are not instructions
+EOF
+FROM gcr.io/distroless/static AS runtime
+COPY --from=build /app /app
+FROM build AS test
+RUNN go test ./...
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	df, err := parseDockerfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"build", "runtime", "test"}; !slices.Equal(df.stages, want) {
+		t.Errorf("stages = %v, want %v", df.stages, want)
+	}
+	if want := []string{"golang:${GO_VERSION}", "gcr.io/distroless/static"}; !slices.Equal(df.baseImages, want) {
+		t.Errorf("base images = %v, want %v", df.baseImages, want)
+	}
+	if !df.args["GO_VERSION"] || !df.args["VERSION"] {
+		t.Errorf("args = %v, want GO_VERSION and VERSION", df.args)
+	}
+	if len(df.problems) != 1 || !strings.Contains(df.problems[0], "Dockerfile:14: unknown instruction RUNN") {
+		t.Errorf("problems = %v, want only the unknown RUNN on line 14", df.problems)
+	}
+}
+
+func TestPlanBuild(t *testing.T) {
+	t.Setenv("DOCKER_USERNAME", "user")
+	t.Setenv("DOCKER_PASSWORD", "pass")
+	dir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile": "FROM alpine:3.20 AS base\nARG VERSION\n",
+		"main.go":    "package main\n",
+		"notes.md":   "excluded\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := BuildOptions{
+		ContextDir:     dir,
+		DockerfilePath: filepath.Join(dir, "Dockerfile"),
+		Excludes:       []string{"*.md"},
+		BuildArgs:      map[string]string{"VERSION": "1.0", "NPM_TOKEN": "s3cr3t"},
+	}
+
+	plan := PlanBuild("user/app:1.0", "hub", opts)
+	if len(plan.Problems) != 0 {
+		t.Fatalf("problems = %v", plan.Problems)
+	}
+	if !plan.Credentials.Resolved || plan.ContextFiles != 2 || plan.ContextBytes == 0 {
+		t.Errorf("credentials = %+v, context = %d files, %d bytes; want resolved, 2 files", plan.Credentials, plan.ContextFiles, plan.ContextBytes)
+	}
+	if plan.BuildArgs["VERSION"] != "1.0" || plan.BuildArgs["NPM_TOKEN"] != "[REDACTED]" {
+		t.Errorf("build args = %v, want VERSION shown and NPM_TOKEN redacted", plan.BuildArgs)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "NPM_TOKEN") {
+		t.Errorf("warnings = %v, want the undeclared NPM_TOKEN", plan.Warnings)
+	}
+
+	t.Setenv("DOCKER_PASSWORD", "")
+	opts.Target = "release"
+	plan = PlanBuild("user/app:1.0", "hub", opts)
+	var out bytes.Buffer
+	err := PrintBuildPlan(&out, plan)
+	if err == nil || !strings.Contains(err.Error(), "2 problem(s)") {
+		t.Fatalf("PrintBuildPlan = %v, want missing credentials and target", err)
+	}
+	var decoded BuildPlan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Image != "user/app:1.0" {
+		t.Errorf("plan JSON = %s, %v", out.String(), err)
+	}
+}
+
+func TestParseHealthCheck(t *testing.T) {
+	port, path, err := parseHealthCheck("8080/healthz")
+	if err != nil || port != "8080/tcp" || path != "/healthz" {
+		t.Errorf("parseHealthCheck(8080/healthz) = %q, %q, %v", port, path, err)
+	}
+	port, path, err = parseHealthCheck("3000")
+	if err != nil || port != "3000/tcp" || path != "/" {
+		t.Errorf("parseHealthCheck(3000) = %q, %q, %v", port, path, err)
+	}
+	if port, _, err := parseHealthCheck(""); err != nil || port != "" {
+		t.Errorf("parseHealthCheck(\"\") = %q, %v, want no check", port, err)
+	}
+	for _, bad := range []string{"http/healthz", "0", "70000/x"} {
+		if _, _, err := parseHealthCheck(bad); err == nil {
+			t.Errorf("parseHealthCheck(%q) succeeded, want an error", bad)
+		}
+	}
+
+	err = smokeError("container exited with code 1", "\nexec: \"/app\": not found\n")
+	if err == nil || !strings.HasSuffix(err.Error(), "last output:\nexec: \"/app\": not found") {
+		t.Errorf("smokeError = %v", err)
+	}
+}
+
+func TestFindBaseImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Dockerfile")
+	dockerfile := `ARG GO_VERSION=1.22
+FROM golang:${GO_VERSION} AS build
+RUN go build ./...
+FROM --platform=linux/amd64 gcr.io/distroless/static@sha256:` + strings.Repeat("a", 64) + `
+COPY --from=build /app /app
+FROM build AS test
+FROM scratch
+FROM golang:${GO_VERSION}
+`
+	if err := os.WriteFile(path, []byte(dockerfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bases, err := FindBaseImages(path, map[string]string{"GO_VERSION": "1.23"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bases) != 2 {
+		t.Fatalf("FindBaseImages = %+v, want golang and distroless once each", bases)
+	}
+	if bases[0].Image != "golang:1.23" || bases[0].Line != 2 || bases[0].Pinned() {
+		t.Errorf("bases[0] = %+v, want golang:1.23 on line 2, unpinned", bases[0])
+	}
+	if !bases[1].Pinned() {
+		t.Errorf("bases[1] = %+v, want pinned", bases[1])
+	}
+
+	configs.RequirePinnedBases = true
+	defer func() { configs.RequirePinnedBases = false }()
+	err = checkBasePolicy(BuildOptions{DockerfilePath: path})
+	if err == nil || !strings.Contains(err.Error(), "golang:1.22") {
+		t.Errorf("checkBasePolicy = %v, want golang:1.22 reported as unpinned", err)
+	}
+}
+
+func TestSameRepository(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("b", 64)
+	repoDigests := []string{"alpine@" + digest, "ghcr.io/org/app@sha256:" + strings.Repeat("c", 64)}
+	if !sameRepository(repoDigests, "docker.io/library/alpine:3.20", digest) {
+		t.Error("docker.io/library/alpine does not match the alpine repo digest")
+	}
+	if sameRepository(repoDigests, "ghcr.io/org/app:v1", digest) {
+		t.Error("ghcr.io/org/app matched the digest of another repository")
+	}
+}
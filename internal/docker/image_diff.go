@@ -0,0 +1,553 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/content"
+)
+
+// ImageDiffOptions configures DiffImages.
+type ImageDiffOptions struct {
+	Platform string // os/arch to pick from multi-platform images; default linux/amd64
+	Remote   bool   // read both images from their registries even if they are local
+	Timeout  time.Duration
+}
+
+// LayerInfo identifies one layer by the digest of its uncompressed content.
+type LayerInfo struct {
+	DiffID string `json:"diffId"`
+	Size   int64  `json:"size"` // as stored: compressed in a registry, a tar locally
+}
+
+// FileChange is one path that differs between two images.
+type FileChange struct {
+	Path    string `json:"path"`
+	Change  string `json:"change"` // added, removed or changed
+	OldSize int64  `json:"oldSize"`
+	NewSize int64  `json:"newSize"`
+}
+
+// ImageDiff is the difference between the file systems of two images.
+type ImageDiff struct {
+	From          string       `json:"from"`
+	To            string       `json:"to"`
+	SharedLayers  int          `json:"sharedLayers"`
+	RemovedLayers []LayerInfo  `json:"removedLayers"`
+	AddedLayers   []LayerInfo  `json:"addedLayers"`
+	Files         []FileChange `json:"files"`
+	FromSize      int64        `json:"fromSize"` // total size of the files in each image
+	ToSize        int64        `json:"toSize"`
+}
+
+// imageLayer is a layer of an image being diffed; open returns its tar
+// stream, gzip-compressed or not.
+type imageLayer struct {
+	LayerInfo
+	open func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// fileEntry is what is compared of a path in an image's file system.
+type fileEntry struct {
+	Type   byte
+	Mode   int64
+	Size   int64
+	Digest string // content digest of regular files
+	Link   string
+}
+
+// layerOp is one change a layer makes: setting a path, deleting it (a
+// whiteout), or clearing a directory of the lower layers (an opaque whiteout).
+type layerOp struct {
+	Path   string
+	Entry  fileEntry
+	Delete bool
+	Opaque bool
+}
+
+// DiffImages compares the layers and file trees of two images. Each image is
+// read from the local Docker daemon when it is there (unless opts.Remote),
+// and otherwise from its registry with the push credentials. Layers the two
+// images share are only read once. to may be just ":TAG", for another tag of
+// from's repository.
+func DiffImages(from, to string, opts ImageDiffOptions, useAI bool) (*ImageDiff, error) {
+	if strings.HasPrefix(to, ":") {
+		to = imageRepository(from) + to
+	}
+	if opts.Platform == "" {
+		opts.Platform = "linux/amd64"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	tmp, err := os.MkdirTemp("", "smurf-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	var layers [2][]imageLayer
+	for i, ref := range []string{from, to} {
+		layers[i], err = loadImageLayers(ctx, ref, opts, filepath.Join(tmp, fmt.Sprint(i)))
+		if err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return nil, err
+		}
+	}
+
+	diff := &ImageDiff{From: from, To: to}
+	diff.SharedLayers, diff.RemovedLayers, diff.AddedLayers = diffLayers(layers[0], layers[1])
+
+	parsed := map[string][]layerOp{}
+	var trees [2]map[string]fileEntry
+	for i := range layers {
+		var ops [][]layerOp
+		for _, l := range layers[i] {
+			if _, ok := parsed[l.DiffID]; !ok {
+				spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Reading layer %s (%s)...", shortDigest(l.DiffID), formatSize(l.Size)))
+				layerOps, err := readLayer(ctx, l)
+				if err != nil {
+					spinner.Fail(err.Error())
+					ai.AIExplainError(useAI, err.Error())
+					return nil, err
+				}
+				spinner.Success(fmt.Sprintf("Read layer %s", shortDigest(l.DiffID)))
+				parsed[l.DiffID] = layerOps
+			}
+			ops = append(ops, parsed[l.DiffID])
+		}
+		trees[i] = applyLayers(ops)
+	}
+	diff.Files = diffTrees(trees[0], trees[1])
+	diff.FromSize, diff.ToSize = treeSize(trees[0]), treeSize(trees[1])
+	return diff, nil
+}
+
+// loadImageLayers returns the layers of ref, from the local daemon (saved
+// under dir) or from the registry.
+func loadImageLayers(ctx context.Context, ref string, opts ImageDiffOptions, dir string) ([]imageLayer, error) {
+	if !opts.Remote {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err == nil {
+			defer cli.Close()
+			if _, err := cli.ImageInspect(ctx, ref); err == nil {
+				pterm.Info.Printfln("Reading %s from the local Docker daemon", ref)
+				return localImageLayers(ctx, cli, ref, dir)
+			}
+		}
+	}
+	pterm.Info.Printfln("Reading %s from its registry", ref)
+	return registryImageLayers(ctx, ref, opts.Platform)
+}
+
+// localImageLayers saves ref from the daemon into dir and returns its
+// layers, in the order of the image config's diff_ids.
+func localImageLayers(ctx context.Context, cli *client.Client, ref, dir string) ([]imageLayer, error) {
+	rc, err := cli.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image %s: %w", ref, err)
+	}
+	defer rc.Close()
+	if err := extractTar(rc, dir); err != nil {
+		return nil, fmt.Errorf("failed to read saved image %s: %w", ref, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("saved image %s has no manifest.json: %w", ref, err)
+	}
+	var manifests []struct {
+		Config string
+		Layers []string
+	}
+	if err := json.Unmarshal(data, &manifests); err != nil || len(manifests) == 0 {
+		return nil, fmt.Errorf("saved image %s has an invalid manifest.json", ref)
+	}
+	m := manifests[0]
+	configData, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(m.Config)))
+	if err != nil {
+		return nil, err
+	}
+	diffIDs, err := configDiffIDs(configData, len(m.Layers))
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", ref, err)
+	}
+
+	layers := make([]imageLayer, len(m.Layers))
+	for i, p := range m.Layers {
+		file := filepath.Join(dir, filepath.FromSlash(p))
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = imageLayer{
+			LayerInfo: LayerInfo{DiffID: diffIDs[i], Size: info.Size()},
+			open: func(context.Context) (io.ReadCloser, error) {
+				return os.Open(file)
+			},
+		}
+	}
+	return layers, nil
+}
+
+// registryImageLayers resolves ref in its registry, picking platform from an
+// index, and returns layers that are streamed from the registry when read.
+func registryImageLayers(ctx context.Context, ref, platform string) ([]imageLayer, error) {
+	repo, target, err := artifactRepository(ref)
+	if err != nil {
+		return nil, err
+	}
+	desc, data, err := fetchManifest(ctx, repo, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", ref, err)
+	}
+	if isIndex(desc.MediaType) {
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("invalid image index for %s: %w", ref, err)
+		}
+		chosen, err := selectPlatform(index, platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		if data, err = content.FetchAll(ctx, repo, chosen); err != nil {
+			return nil, fmt.Errorf("failed to fetch the %s manifest of %s: %w", platform, ref, err)
+		}
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %w", ref, err)
+	}
+	configData, err := content.FetchAll(ctx, repo, manifest.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the config of %s: %w", ref, err)
+	}
+	diffIDs, err := configDiffIDs(configData, len(manifest.Layers))
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", ref, err)
+	}
+
+	layers := make([]imageLayer, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		desc := l
+		if strings.Contains(desc.MediaType, "zstd") {
+			return nil, fmt.Errorf("image %s: zstd-compressed layers are not supported", ref)
+		}
+		layers[i] = imageLayer{
+			LayerInfo: LayerInfo{DiffID: diffIDs[i], Size: desc.Size},
+			open: func(ctx context.Context) (io.ReadCloser, error) {
+				return repo.Fetch(ctx, desc)
+			},
+		}
+	}
+	return layers, nil
+}
+
+func fetchManifest(ctx context.Context, repo interface {
+	content.Resolver
+	content.Fetcher
+}, target string) (ocispec.Descriptor, []byte, error) {
+	desc, err := repo.Resolve(ctx, target)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	data, err := content.FetchAll(ctx, repo, desc)
+	return desc, data, err
+}
+
+func isIndex(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex ||
+		mediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+// selectPlatform picks the manifest for platform (os/arch[/variant]) from an
+// image index.
+func selectPlatform(index ocispec.Index, platform string) (ocispec.Descriptor, error) {
+	parts := strings.SplitN(platform, "/", 3)
+	if len(parts) < 2 {
+		return ocispec.Descriptor{}, fmt.Errorf("invalid platform %q: want os/arch[/variant]", platform)
+	}
+	var available []string
+	for _, m := range index.Manifests {
+		p := m.Platform
+		if p == nil {
+			continue
+		}
+		available = append(available, p.OS+"/"+p.Architecture)
+		if p.OS == parts[0] && p.Architecture == parts[1] && (len(parts) < 3 || p.Variant == parts[2]) {
+			return m, nil
+		}
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("no %s image (available: %s)", platform, strings.Join(available, ", "))
+}
+
+// configDiffIDs returns the rootfs.diff_ids of an image config, checking
+// that there is one per layer.
+func configDiffIDs(data []byte, layers int) ([]string, error) {
+	var config ocispec.Image
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid image config: %w", err)
+	}
+	if len(config.RootFS.DiffIDs) != layers {
+		return nil, fmt.Errorf("image config lists %d layers, manifest %d", len(config.RootFS.DiffIDs), layers)
+	}
+	ids := make([]string, layers)
+	for i, d := range config.RootFS.DiffIDs {
+		ids[i] = d.String()
+	}
+	return ids, nil
+}
+
+// diffLayers compares two layer stacks: layers are shared up to the first
+// one that differs, since a layer's content depends on those below it.
+func diffLayers(from, to []imageLayer) (shared int, removed, added []LayerInfo) {
+	for shared < len(from) && shared < len(to) && from[shared].DiffID == to[shared].DiffID {
+		shared++
+	}
+	for _, l := range from[shared:] {
+		removed = append(removed, l.LayerInfo)
+	}
+	for _, l := range to[shared:] {
+		added = append(added, l.LayerInfo)
+	}
+	return shared, removed, added
+}
+
+// readLayer lists the changes a layer makes, hashing regular files.
+func readLayer(ctx context.Context, l imageLayer) ([]layerOp, error) {
+	rc, err := l.open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layer %s: %w", shortDigest(l.DiffID), err)
+	}
+	defer rc.Close()
+	ops, err := layerOps(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer %s: %w", shortDigest(l.DiffID), err)
+	}
+	return ops, nil
+}
+
+// layerOps reads a layer tar, gzip-compressed or not.
+func layerOps(r io.Reader) ([]layerOp, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var ops []layerOp
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(p)
+		switch {
+		case base == ".wh..wh..opq":
+			ops = append(ops, layerOp{Path: path.Clean(dir), Opaque: true})
+			continue
+		case strings.HasPrefix(base, ".wh."):
+			ops = append(ops, layerOp{Path: path.Join(dir, strings.TrimPrefix(base, ".wh.")), Delete: true})
+			continue
+		}
+
+		entry := fileEntry{Type: hdr.Typeflag, Mode: hdr.Mode, Link: hdr.Linkname}
+		if hdr.Typeflag == tar.TypeReg {
+			h := sha256.New()
+			n, err := io.Copy(h, tr)
+			if err != nil {
+				return nil, err
+			}
+			entry.Size = n
+			entry.Digest = hex.EncodeToString(h.Sum(nil))
+		}
+		ops = append(ops, layerOp{Path: p, Entry: entry})
+	}
+}
+
+// applyLayers builds the file system of an image from its layers' changes,
+// bottom layer first.
+func applyLayers(layers [][]layerOp) map[string]fileEntry {
+	tree := map[string]fileEntry{}
+	for _, ops := range layers {
+		// A whiteout only hides what the layers below provide.
+		added := map[string]bool{}
+		for _, op := range ops {
+			switch {
+			case op.Opaque:
+				for p := range tree {
+					if strings.HasPrefix(p, op.Path+"/") && !added[p] {
+						delete(tree, p)
+					}
+				}
+			case op.Delete:
+				for p := range tree {
+					if (p == op.Path || strings.HasPrefix(p, op.Path+"/")) && !added[p] {
+						delete(tree, p)
+					}
+				}
+			default:
+				tree[op.Path] = op.Entry
+				added[op.Path] = true
+			}
+		}
+	}
+	delete(tree, "/")
+	return tree
+}
+
+// diffTrees lists the paths that were added, removed or changed between two
+// file systems, sorted by path. Modification times are ignored, so a rebuild
+// that produced identical files shows no changes.
+func diffTrees(from, to map[string]fileEntry) []FileChange {
+	var changes []FileChange
+	for p, old := range from {
+		cur, ok := to[p]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: p, Change: "removed", OldSize: old.Size})
+		case old != cur:
+			changes = append(changes, FileChange{Path: p, Change: "changed", OldSize: old.Size, NewSize: cur.Size})
+		}
+	}
+	for p, cur := range to {
+		if _, ok := from[p]; !ok {
+			changes = append(changes, FileChange{Path: p, Change: "added", NewSize: cur.Size})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func treeSize(tree map[string]fileEntry) int64 {
+	var total int64
+	for _, e := range tree {
+		total += e.Size
+	}
+	return total
+}
+
+// extractTar writes the regular files of a tar stream under dir.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+hdr.Name)))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// formatSize renders a byte count like docker does (1.5MB).
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < 0 {
+		return "-" + formatSize(-n)
+	}
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func signedSize(n int64) string {
+	if n > 0 {
+		return "+" + formatSize(n)
+	}
+	return formatSize(n)
+}
+
+// PrintImageDiff prints a diff as a summary and a table of up to max file
+// changes (0 for all).
+func PrintImageDiff(d *ImageDiff, max int) {
+	pterm.DefaultSection.Printfln("%s → %s", d.From, d.To)
+	pterm.Info.Printfln("Layers: %d shared, %d removed, %d added", d.SharedLayers, len(d.RemovedLayers), len(d.AddedLayers))
+
+	counts := map[string]int{}
+	for _, f := range d.Files {
+		counts[f.Change]++
+	}
+	pterm.Info.Printfln("Files: %d added, %d removed, %d changed", counts["added"], counts["removed"], counts["changed"])
+	pterm.Info.Printfln("Size: %s → %s (%s)", formatSize(d.FromSize), formatSize(d.ToSize), signedSize(d.ToSize-d.FromSize))
+
+	if len(d.Files) == 0 {
+		pterm.Success.Println("The file systems of the two images are identical")
+		return
+	}
+	files := d.Files
+	if max > 0 && len(files) > max {
+		files = files[:max]
+	}
+	data := [][]string{{"CHANGE", "PATH", "SIZE"}}
+	for _, f := range files {
+		change, size := f.Change, ""
+		switch f.Change {
+		case "added":
+			change, size = pterm.Green("+ added"), formatSize(f.NewSize)
+		case "removed":
+			change, size = pterm.Red("- removed"), formatSize(f.OldSize)
+		default:
+			change, size = pterm.Yellow("~ changed"), signedSize(f.NewSize-f.OldSize)
+		}
+		data = append(data, []string{change, f.Path, size})
+	}
+	_ = pterm.DefaultTable.WithHasHeader(true).WithData(data).Render()
+	if len(files) < len(d.Files) {
+		pterm.Info.Printfln("%d more changes not shown; use --max 0 to list all", len(d.Files)-len(files))
+	}
+}
@@ -0,0 +1,415 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/docker/docker/client"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Statuses of a target in a push plan.
+const (
+	TargetPending        = "pending"
+	TargetPushed         = "pushed"
+	TargetFailed         = "failed"
+	TargetRolledBack     = "rolled-back"
+	TargetRollbackFailed = "rollback-failed"
+)
+
+// Failure modes of PushToTargets.
+const (
+	OnFailurePlan     = "plan"
+	OnFailureRollback = "rollback"
+)
+
+// PushPlan records one local image pushed to several tags or registries and
+// how far the push got. It is written when a push fails part-way, and
+// `smurf sdkr push --retry-plan` resumes it.
+type PushPlan struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	Source        string       `json:"source"`
+	SourceID      string       `json:"sourceId"`
+	Builder       string       `json:"builder,omitempty"` // builder the source was built with; docker when empty
+	Targets       []PushTarget `json:"targets"`
+}
+
+// PushTarget is one destination of a push plan. PreviousDigest is what the
+// tag pointed to before smurf pushed it ("" for a new tag), which is what a
+// rollback restores.
+type PushTarget struct {
+	Image          string `json:"image"`
+	Status         string `json:"status"`
+	Digest         string `json:"digest,omitempty"`
+	PreviousDigest string `json:"previousDigest,omitempty"`
+	Error          string `json:"error,omitempty"`
+	Duration       string `json:"duration,omitempty"` // of the last push attempt
+}
+
+// MultiPushOptions controls PushToTargets.
+type MultiPushOptions struct {
+	OnFailure string        // OnFailurePlan (default) or OnFailureRollback
+	PlanFile  string        // where the plan is written when the push fails
+	Timeout   time.Duration // per-target push timeout
+	// Concurrency is how many targets are pushed at once, each with its own
+	// registry credentials, every one of them attempted. 0 pushes them in
+	// order and stops at the first failure.
+	Concurrency int
+}
+
+// NewPushPlan creates a plan pushing the local image source, built with
+// builder, to every target.
+func NewPushPlan(source string, targets []string, builder string) (*PushPlan, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("at least one target is required")
+	}
+	if builder == BuilderDocker {
+		builder = ""
+	}
+	id, err := builtImageID(builder, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect local image %s: %w", source, err)
+	}
+
+	plan := &PushPlan{
+		SchemaVersion: 1,
+		CreatedAt:     time.Now().UTC(),
+		Source:        source,
+		SourceID:      id,
+		Builder:       builder,
+	}
+	seen := map[string]bool{}
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		if _, err := parseRemoteImage(target); err != nil {
+			return nil, err
+		}
+		plan.Targets = append(plan.Targets, PushTarget{Image: target, Status: TargetPending})
+	}
+	return plan, nil
+}
+
+// LoadPushPlan reads a plan written by a failed push.
+func LoadPushPlan(path string) (*PushPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push plan: %w", err)
+	}
+	var plan PushPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse push plan %s: %w", path, err)
+	}
+	if plan.SchemaVersion != 1 {
+		return nil, fmt.Errorf("unsupported push plan schema version %d", plan.SchemaVersion)
+	}
+	if plan.Source == "" || len(plan.Targets) == 0 {
+		return nil, fmt.Errorf("push plan %s has no source image or targets", path)
+	}
+	return &plan, nil
+}
+
+// Save writes the plan as indented JSON.
+func (p *PushPlan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode push plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write push plan: %w", err)
+	}
+	return nil
+}
+
+// remaining returns the indexes of the targets that still need a push.
+func (p *PushPlan) remaining() []int {
+	var idx []int
+	for i, t := range p.Targets {
+		if t.Status != TargetPushed {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// PushToTargets pushes the plan's source image to each target that is not
+// pushed yet, in order, and stops at the first failure. With
+// opts.Concurrency set the targets are pushed concurrently instead and every
+// one of them is attempted. The local image must still be the one the
+// plan was created for.
+//
+// On failure the already pushed targets are either left in place
+// (OnFailurePlan) or rolled back (OnFailureRollback): a tag that existed
+// before is pointed back at its previous digest and a new tag is deleted.
+// Either way the plan, with the status of every target, is written to
+// opts.PlanFile so the push can be retried with the same targets.
+func PushToTargets(plan *PushPlan, opts MultiPushOptions, useAI bool) error {
+	id, err := builtImageID(plan.Builder, plan.Source)
+	if err != nil {
+		err = fmt.Errorf("failed to inspect local image %s: %w", plan.Source, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	if id != plan.SourceID {
+		err := fmt.Errorf("local image %s is %s, but the plan was made for %s; rebuild or retag it before retrying", plan.Source, shortDigest(id), shortDigest(plan.SourceID))
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	var pushed []int
+	var failure error
+	if opts.Concurrency > 0 {
+		pushed, failure = pushConcurrently(plan, opts)
+	} else {
+		pushed, failure = pushInOrder(plan, opts)
+	}
+
+	if failure != nil && opts.OnFailure == OnFailureRollback {
+		rollbackTargets(plan, pushed)
+	}
+
+	printPushPlan(plan)
+
+	if failure == nil {
+		return nil
+	}
+
+	if opts.PlanFile != "" {
+		if err := plan.Save(opts.PlanFile); err != nil {
+			pterm.Error.Println(err)
+		} else {
+			pterm.Info.Printfln("Retry the remaining targets with: smurf sdkr push --retry-plan %s", opts.PlanFile)
+		}
+	}
+	ai.AIExplainError(useAI, failure.Error())
+	return failure
+}
+
+// pushFunc pushes one target; the tests swap it.
+var pushFunc = pushTarget
+
+// pushInOrder pushes the remaining targets one after another until one
+// fails, and returns the indexes of those it pushed.
+func pushInOrder(plan *PushPlan, opts MultiPushOptions) ([]int, error) {
+	var pushed []int
+	for _, i := range plan.remaining() {
+		target := &plan.Targets[i]
+		pterm.Info.Printfln("Pushing %s to %s...", plan.Source, target.Image)
+		if err := pushTimed(plan, target, opts.Timeout, true); err != nil {
+			return pushed, fmt.Errorf("push to %s failed: %w", target.Image, err)
+		}
+		pushed = append(pushed, i)
+		pterm.Success.Printfln("Pushed %s", target.Image)
+	}
+	return pushed, nil
+}
+
+// pushConcurrently pushes the remaining targets, opts.Concurrency at a
+// time, and returns the indexes of those it pushed with the failures of the
+// others joined.
+func pushConcurrently(plan *PushPlan, opts MultiPushOptions) ([]int, error) {
+	remaining := plan.remaining()
+	workers := min(opts.Concurrency, len(remaining))
+	pterm.Info.Printfln("Pushing %s to %d target(s), %d at a time...", plan.Source, len(remaining), workers)
+
+	errs := make([]error, len(plan.Targets))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, i := range remaining {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			target := &plan.Targets[i]
+			if errs[i] = pushTimed(plan, target, opts.Timeout, false); errs[i] != nil {
+				pterm.Error.Printfln("Push to %s failed after %s: %v", target.Image, target.Duration, errs[i])
+				return
+			}
+			pterm.Success.Printfln("Pushed %s in %s", target.Image, target.Duration)
+		}()
+	}
+	wg.Wait()
+
+	var pushed []int
+	var failures []error
+	for _, i := range remaining {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("push to %s failed: %w", plan.Targets[i].Image, errs[i]))
+			continue
+		}
+		pushed = append(pushed, i)
+	}
+	if len(failures) > 0 {
+		return pushed, fmt.Errorf("%d of %d target(s) failed: %w", len(failures), len(remaining), errors.Join(failures...))
+	}
+	return pushed, nil
+}
+
+// pushTimed pushes target and records its status, error and duration.
+func pushTimed(plan *PushPlan, target *PushTarget, timeout time.Duration, progress bool) error {
+	start := time.Now()
+	err := pushFunc(plan.Builder, plan.Source, target, timeout, progress)
+	target.Duration = time.Since(start).Round(100 * time.Millisecond).String()
+	if err != nil {
+		target.Status, target.Error = TargetFailed, err.Error()
+		return err
+	}
+	target.Status, target.Error = TargetPushed, ""
+	return nil
+}
+
+// pushTarget tags the source image as target locally and pushes it,
+// recording what the tag pointed to before and the digest pushed. Images of
+// a daemonless builder are copied to target from its image store instead.
+// Without progress nothing is printed while the push runs.
+func pushTarget(builder, source string, target *PushTarget, timeout time.Duration, progress bool) error {
+	img, err := parseRemoteImage(target.Image)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if target.PreviousDigest == "" {
+		previous, err := RemoteDigest(ctx, target.Image)
+		if err != nil {
+			pterm.Warning.Printfln("Could not read the current digest of %s; a rollback will not be able to restore it: %v", target.Image, err)
+		}
+		target.PreviousDigest = previous
+	}
+
+	if builder != "" && builder != BuilderDocker {
+		digest, err := pushBuilt(builder, source, target.Image, timeout, progress)
+		if err != nil {
+			return err
+		}
+		target.Digest = digest
+		return nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	if err := cli.ImageTag(ctx, source, target.Image); err != nil {
+		return fmt.Errorf("failed to tag %s as %s: %w", source, target.Image, err)
+	}
+
+	if m := ecrHostPattern.FindStringSubmatch(img.Host); m != nil && progress {
+		// PushImageToECR also creates the repository when it is missing.
+		if err := PushImageToECR(target.Image, m[2], img.Repository, false); err != nil {
+			return err
+		}
+	} else if m != nil {
+		if err := pushECRQuiet(ctx, cli, target.Image, img, m[2]); err != nil {
+			return err
+		}
+	} else {
+		cred, err := registryCredential(ctx, img.Host)
+		if err != nil {
+			return fmt.Errorf("failed to get credentials for %s: %w", img.Host, err)
+		}
+		authStr, err := prepareAuth(cred.Username, cred.Password, img.Host)
+		if err != nil {
+			return err
+		}
+		if progress {
+			err = pushImage(cli, ctx, target.Image, authStr)
+		} else {
+			err = pushImageQuiet(cli, ctx, target.Image, authStr, auth.EmptyCredential)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	digest, err := ImageDigest(target.Image)
+	if err != nil {
+		return err
+	}
+	target.Digest = digest
+	return nil
+}
+
+// pushECRQuiet pushes image, img in the ECR registry of region, creating
+// its repository when it is missing, without PushImageToECR's output.
+func pushECRQuiet(ctx context.Context, cli *client.Client, image string, img remoteImage, region string) error {
+	ecrClient, err := ensureECRRepository(region, img.Repository)
+	if err != nil {
+		return err
+	}
+	username, password, err := ecrCredential(region)
+	if err != nil {
+		return err
+	}
+	authStr, err := prepareAuth(username, password, img.Host)
+	if err != nil {
+		return err
+	}
+	cred := auth.Credential{Username: username, Password: password}
+	if err := pushImageQuiet(cli, ctx, image, authStr, cred); err != nil {
+		return err
+	}
+	if configs.EcrScanFindings {
+		return checkECRScan(ctx, ecrClient, img.Repository, img.Tag)
+	}
+	return nil
+}
+
+// rollbackTargets undoes the pushes of the given targets, newest first.
+func rollbackTargets(plan *PushPlan, pushed []int) {
+	if len(pushed) == 0 {
+		return
+	}
+	pterm.Warning.Printfln("Rolling back %d pushed target(s)...", len(pushed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), configs.Timeouts.PushTimeout())
+	defer cancel()
+
+	for j := len(pushed) - 1; j >= 0; j-- {
+		target := &plan.Targets[pushed[j]]
+		var err error
+		if target.PreviousDigest != "" {
+			err = RestoreRemoteTag(ctx, target.Image, target.PreviousDigest)
+		} else {
+			err = DeleteRemoteTag(ctx, target.Image, target.Digest)
+		}
+		if err != nil {
+			target.Status, target.Error = TargetRollbackFailed, err.Error()
+			pterm.Error.Printfln("Could not roll back %s: %v", target.Image, err)
+			continue
+		}
+		target.Status, target.Error = TargetRolledBack, ""
+		pterm.Success.Printfln("Rolled back %s", target.Image)
+	}
+}
+
+func printPushPlan(plan *PushPlan) {
+	data := pterm.TableData{{"TARGET", "STATUS", "DIGEST", "DURATION", "ERROR"}}
+	for _, t := range plan.Targets {
+		data = append(data, []string{t.Image, t.Status, shortDigest(t.Digest), t.Duration, t.Error})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 19 {
+		return digest[:19]
+	}
+	return digest
+}
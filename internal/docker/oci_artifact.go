@@ -0,0 +1,220 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// DefaultArtifactMediaType is the media type of a pushed file when none is
+// given, as with the oras CLI.
+const DefaultArtifactMediaType = "application/vnd.oci.image.layer.v1.tar"
+
+// ArtifactPushOptions describes a generic OCI artifact to push.
+type ArtifactPushOptions struct {
+	// Files are the files or directories to push, each optionally suffixed
+	// with :MEDIA_TYPE. Directories are pushed as a tarball and unpacked
+	// again on pull.
+	Files        []string
+	ArtifactType string            // defaults to application/vnd.unknown.artifact.v1
+	Annotations  map[string]string // manifest annotations
+	Timeout      time.Duration
+}
+
+// ArtifactResult is what an artifact push or pull transferred.
+type ArtifactResult struct {
+	Reference    string   `json:"reference"`
+	Digest       string   `json:"digest"`
+	ArtifactType string   `json:"artifactType,omitempty"`
+	Files        []string `json:"files"`
+}
+
+// artifactRepository returns the registry client for ref, authenticated like
+// the image push commands, and the tag or digest ref names.
+func artifactRepository(ref string) (*remote.Repository, string, error) {
+	img, err := parseRemoteImage(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	named, _ := reference.ParseNormalizedNamed(ref)
+	target := img.Tag
+	if digested, ok := named.(reference.Digested); ok {
+		target = digested.Digest().String()
+	}
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return nil, "", err
+	}
+	return repo, target, nil
+}
+
+// splitArtifactFile splits a PATH[:MEDIA_TYPE] argument. A colon that is
+// part of a Windows drive letter is not a separator.
+func splitArtifactFile(arg string) (path, mediaType string) {
+	i := strings.LastIndex(arg, ":")
+	if i <= 1 {
+		return arg, DefaultArtifactMediaType
+	}
+	return arg[:i], arg[i+1:]
+}
+
+// PushArtifact pushes files to ref as one OCI artifact, with each file as a
+// layer titled by its base name.
+func PushArtifact(ref string, opts ArtifactPushOptions, useAI bool) (*ArtifactResult, error) {
+	if len(opts.Files) == 0 {
+		return nil, fmt.Errorf("no files to push")
+	}
+	if opts.ArtifactType == "" {
+		opts.ArtifactType = oras.MediaTypeUnknownArtifact
+	}
+	repo, tag, err := artifactRepository(ref)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(tag, ":") {
+		return nil, fmt.Errorf("artifact push needs a tag, not a digest: %s", ref)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	store, err := file.New("")
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	result := &ArtifactResult{Reference: ref, ArtifactType: opts.ArtifactType}
+	var layers []ocispec.Descriptor
+	for _, arg := range opts.Files {
+		path, mediaType := splitArtifactFile(arg)
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("artifact file %s: %w", path, err)
+		}
+		desc, err := store.Add(ctx, filepath.Base(abs), mediaType, abs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", path, err)
+		}
+		layers = append(layers, desc)
+		result.Files = append(result.Files, filepath.Base(abs))
+	}
+
+	manifest, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, opts.ArtifactType, oras.PackManifestOptions{
+		Layers:              layers,
+		ManifestAnnotations: opts.Annotations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack artifact manifest: %w", err)
+	}
+	if err := store.Tag(ctx, manifest, tag); err != nil {
+		return nil, err
+	}
+
+	pterm.Info.Printfln("Pushing %d file(s) to %s...", len(layers), ref)
+	desc, err := oras.Copy(ctx, store, tag, repo, tag, oras.DefaultCopyOptions)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to push artifact %s: %w", ref, err)
+	}
+	result.Digest = desc.Digest.String()
+	pterm.Success.Printfln("Pushed %s@%s", ref, result.Digest)
+	return result, nil
+}
+
+// PullArtifact downloads the files of the OCI artifact ref into dir. Layers
+// without a title annotation, which are not files, are skipped.
+func PullArtifact(ref, dir string, timeout time.Duration, useAI bool) (*ArtifactResult, error) {
+	repo, target, err := artifactRepository(ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	store, err := file.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	pterm.Info.Printfln("Pulling %s into %s...", ref, dir)
+	desc, err := oras.Copy(ctx, repo, target, store, target, oras.DefaultCopyOptions)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to pull artifact %s: %w", ref, err)
+	}
+
+	result := &ArtifactResult{Reference: ref, Digest: desc.Digest.String(), ArtifactType: desc.ArtifactType}
+	if desc.MediaType == ocispec.MediaTypeImageManifest {
+		data, err := content.FetchAll(ctx, store, desc)
+		if err != nil {
+			return nil, err
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest for %s: %w", ref, err)
+		}
+		result.ArtifactType = artifactTypeOf(manifest)
+		result.Files = layerTitles(manifest)
+	}
+	pterm.Success.Printfln("Pulled %s@%s (%d file(s))", ref, result.Digest, len(result.Files))
+	return result, nil
+}
+
+// artifactTypeOf is the artifact type of a manifest: its artifactType field,
+// or the config media type of manifests that predate it.
+func artifactTypeOf(m ocispec.Manifest) string {
+	if m.ArtifactType != "" {
+		return m.ArtifactType
+	}
+	if m.Config.MediaType != ocispec.MediaTypeEmptyJSON {
+		return m.Config.MediaType
+	}
+	return ""
+}
+
+func layerTitles(m ocispec.Manifest) []string {
+	var titles []string
+	for _, layer := range m.Layers {
+		if title := layer.Annotations[ocispec.AnnotationTitle]; title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// PrintArtifactResult prints the files of a pushed or pulled artifact.
+func PrintArtifactResult(r *ArtifactResult) {
+	data := [][]string{{"FILE"}}
+	for _, f := range r.Files {
+		data = append(data, []string{f})
+	}
+	if len(data) > 1 {
+		_ = pterm.DefaultTable.WithHasHeader(true).WithData(data).Render()
+	}
+	if r.ArtifactType != "" {
+		pterm.Info.Printfln("Artifact type: %s", r.ArtifactType)
+	}
+	pterm.Info.Printfln("Digest: %s", r.Digest)
+}
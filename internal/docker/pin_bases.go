@@ -0,0 +1,278 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/distribution/reference"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/yaml"
+)
+
+// BaseImage is the image a FROM instruction of a Dockerfile builds on.
+type BaseImage struct {
+	Line   int    // 1-based line of the FROM instruction
+	Ref    string // the reference as written, like node:20-alpine or ${BASE}
+	Image  string // Ref with build arguments substituted
+	Digest string // the digest Image is pinned to, if any
+}
+
+// Pinned reports whether the base is pinned to a digest in the Dockerfile.
+func (b BaseImage) Pinned() bool { return b.Digest != "" }
+
+// PinBasesOptions configures PinBases.
+type PinBasesOptions struct {
+	BuildArgs map[string]string // values for ARGs used in FROM, over the ARG defaults
+	LockFile  string            // record digests here instead of rewriting the Dockerfile
+	Check     bool              // change nothing; fail when a base is not pinned (or, with LockFile, has moved)
+}
+
+// BaseLock is the lock file PinBases writes with a LockFile: the digest each
+// base image reference resolved to when it was pinned.
+type BaseLock struct {
+	Bases map[string]string `json:"bases"`
+}
+
+var (
+	fromPattern = regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)(.*)$`)
+	argPattern  = regexp.MustCompile(`(?i)^\s*ARG\s+([A-Za-z_][A-Za-z0-9_]*)(?:=(\S*))?`)
+	varPattern  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// FindBaseImages returns the base images of the Dockerfile at path, in
+// order and once each. Build arguments in FROM are replaced by buildArgs or
+// the defaults of the ARGs declared before the first FROM. Earlier stages
+// and scratch are not base images.
+func FindBaseImages(path string, buildArgs map[string]string) ([]BaseImage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read Dockerfile: %w", err)
+	}
+	args := map[string]string{}
+	stages := map[string]bool{}
+	seen := map[string]bool{}
+	sawFrom := false
+	var bases []BaseImage
+	for i, line := range strings.Split(string(data), "\n") {
+		if m := argPattern.FindStringSubmatch(line); m != nil && !sawFrom {
+			args[m[1]] = strings.Trim(m[2], `"'`)
+			continue
+		}
+		m := fromPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		sawFrom = true
+		ref := m[2]
+		image := varPattern.ReplaceAllStringFunc(ref, func(v string) string {
+			name := strings.Trim(v, "${}")
+			if val, ok := buildArgs[name]; ok {
+				return val
+			}
+			return args[name]
+		})
+		isStage := stages[strings.ToLower(image)]
+		if rest := strings.Fields(m[3]); len(rest) == 2 && strings.EqualFold(rest[0], "AS") {
+			stages[strings.ToLower(rest[1])] = true
+		}
+		if isStage || strings.EqualFold(image, "scratch") || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		base := BaseImage{Line: i + 1, Ref: ref, Image: image}
+		if named, err := reference.ParseNormalizedNamed(image); err == nil {
+			if digested, ok := named.(reference.Digested); ok {
+				base.Digest = digested.Digest().String()
+			}
+		}
+		bases = append(bases, base)
+	}
+	return bases, nil
+}
+
+// PinBases resolves the digest each unpinned base image of the Dockerfile
+// at path currently points to and rewrites its FROM as IMAGE:TAG@DIGEST,
+// or records the digests in opts.LockFile. With opts.Check it changes
+// nothing and fails when a base is unpinned, or, with a lock file, when a
+// base no longer resolves to its locked digest.
+func PinBases(path string, opts PinBasesOptions, useAI bool) error {
+	err := pinBases(path, opts)
+	if err != nil {
+		pterm.Error.Println(err)
+		ai.AIExplainError(useAI, err.Error())
+	}
+	return err
+}
+
+func pinBases(path string, opts PinBasesOptions) error {
+	bases, err := FindBaseImages(path, opts.BuildArgs)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		pterm.Info.Printfln("%s has no base images to pin", path)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if opts.LockFile != "" {
+		return lockBases(ctx, path, bases, opts)
+	}
+	if opts.Check {
+		return checkPinned(path, bases)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read Dockerfile: %w", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	pinned := 0
+	for _, b := range bases {
+		if b.Pinned() {
+			pterm.Info.Printfln("%s is already pinned", b.Image)
+			continue
+		}
+		if b.Ref != b.Image {
+			pterm.Warning.Printfln("Line %d: %s comes from a build argument; pin the argument's value instead", b.Line, b.Ref)
+			continue
+		}
+		digest, err := resolveBaseDigest(ctx, b.Image)
+		if err != nil {
+			return err
+		}
+		// Every FROM of the same image is pinned, not only the first.
+		for i, line := range lines {
+			if m := fromPattern.FindStringSubmatch(line); m != nil && m[2] == b.Ref {
+				lines[i] = m[1] + b.Image + "@" + digest + m[3]
+			}
+		}
+		pterm.Success.Printfln("%s → %s", b.Image, digest)
+		pinned++
+	}
+	if pinned == 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	pterm.Success.Printfln("Pinned %d base image(s) in %s", pinned, path)
+	return nil
+}
+
+// lockBases writes the digest of each base to the lock file or, in check
+// mode, compares the bases with the digests locked there.
+func lockBases(ctx context.Context, path string, bases []BaseImage, opts PinBasesOptions) error {
+	lock := BaseLock{Bases: map[string]string{}}
+	if data, err := os.ReadFile(opts.LockFile); err == nil {
+		if err := yaml.Unmarshal(data, &lock); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", opts.LockFile, err)
+		}
+		if lock.Bases == nil {
+			lock.Bases = map[string]string{}
+		}
+	} else if !os.IsNotExist(err) || opts.Check {
+		return fmt.Errorf("cannot read lock file: %w", err)
+	}
+
+	var problems []string
+	for _, b := range bases {
+		digest := b.Digest
+		if !b.Pinned() {
+			var err error
+			if digest, err = resolveBaseDigest(ctx, b.Image); err != nil {
+				return err
+			}
+		}
+		locked, ok := lock.Bases[b.Image]
+		switch {
+		case !opts.Check:
+			lock.Bases[b.Image] = digest
+			pterm.Success.Printfln("%s → %s", b.Image, digest)
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s (line %d) is not in %s", b.Image, b.Line, opts.LockFile))
+		case locked != digest:
+			problems = append(problems, fmt.Sprintf("%s (line %d) now resolves to %s, locked at %s", b.Image, b.Line, digest, locked))
+		}
+	}
+	if opts.Check {
+		if len(problems) > 0 {
+			return fmt.Errorf("base images of %s differ from %s:\n  %s", path, opts.LockFile, strings.Join(problems, "\n  "))
+		}
+		pterm.Success.Printfln("All %d base image(s) of %s match %s", len(bases), path, opts.LockFile)
+		return nil
+	}
+
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("# Base image digests of %s, written by smurf sdkr pin-bases.\n", path)
+	if err := os.WriteFile(opts.LockFile, append([]byte(header), data...), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.LockFile, err)
+	}
+	pterm.Success.Printfln("Locked %d base image(s) in %s", len(bases), opts.LockFile)
+	return nil
+}
+
+// checkPinned fails when a base is not pinned to a digest in the Dockerfile.
+func checkPinned(path string, bases []BaseImage) error {
+	var unpinned []string
+	for _, b := range bases {
+		if !b.Pinned() {
+			unpinned = append(unpinned, fmt.Sprintf("%s (line %d)", b.Image, b.Line))
+		}
+	}
+	if len(unpinned) > 0 {
+		sort.Strings(unpinned)
+		return fmt.Errorf("%s has unpinned base images (run smurf sdkr pin-bases):\n  %s", path, strings.Join(unpinned, "\n  "))
+	}
+	pterm.Success.Printfln("All %d base image(s) of %s are pinned", len(bases), path)
+	return nil
+}
+
+// checkBasePolicy enforces sdkr.requirePinnedBases before a build.
+func checkBasePolicy(opts BuildOptions) error {
+	if !configs.RequirePinnedBases {
+		return nil
+	}
+	bases, err := FindBaseImages(opts.DockerfilePath, opts.BuildArgs)
+	if err != nil {
+		return err
+	}
+	var unpinned []string
+	for _, b := range bases {
+		if !b.Pinned() {
+			unpinned = append(unpinned, b.Image)
+		}
+	}
+	if len(unpinned) > 0 {
+		return fmt.Errorf("sdkr.requirePinnedBases is set, but %s builds on unpinned base images: %s (run smurf sdkr pin-bases)",
+			opts.DockerfilePath, strings.Join(unpinned, ", "))
+	}
+	return nil
+}
+
+func resolveBaseDigest(ctx context.Context, image string) (string, error) {
+	digest, err := RemoteDigest(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		return "", errors.New("base image " + image + " does not exist in its registry")
+	}
+	return digest, nil
+}
@@ -0,0 +1,375 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/clouddrove/smurf/internal/ai"
+	"golang.org/x/oauth2/google"
+)
+
+// BuildPlan is what a provision command would build and push, computed by
+// PlanBuild without building or pushing anything.
+type BuildPlan struct {
+	Image        string            `json:"image"`
+	Registry     string            `json:"registry"`
+	Credentials  CredentialsPlan   `json:"credentials"`
+	ContextDir   string            `json:"contextDir"`
+	ContextBytes int64             `json:"contextBytes"`
+	ContextFiles int               `json:"contextFiles"`
+	Excludes     []string          `json:"excludes,omitempty"`
+	Dockerfile   string            `json:"dockerfile"`
+	Stages       []string          `json:"stages,omitempty"`
+	BaseImages   []string          `json:"baseImages,omitempty"`
+	Target       string            `json:"target,omitempty"`
+	Platform     string            `json:"platform,omitempty"`
+	NoCache      bool              `json:"noCache"`
+	CacheFrom    []string          `json:"cacheFrom,omitempty"`
+	CacheTo      []string          `json:"cacheTo,omitempty"`
+	BuildArgs    map[string]string `json:"buildArgs"`
+	Warnings     []string          `json:"warnings,omitempty"`
+	Problems     []string          `json:"problems,omitempty"`
+}
+
+// CredentialsPlan says where the registry credentials of a plan come from.
+type CredentialsPlan struct {
+	Source   string `json:"source,omitempty"`
+	Resolved bool   `json:"resolved"`
+	Error    string `json:"error,omitempty"`
+}
+
+// planCredentialsTimeout bounds the credential lookups of a plan, which may
+// reach a metadata endpoint or token service.
+const planCredentialsTimeout = 15 * time.Second
+
+// dockerfileInstructions are the instructions a Dockerfile may contain.
+var dockerfileInstructions = map[string]bool{
+	"ADD": true, "ARG": true, "CMD": true, "COPY": true, "ENTRYPOINT": true, "ENV": true,
+	"EXPOSE": true, "FROM": true, "HEALTHCHECK": true, "LABEL": true, "MAINTAINER": true,
+	"ONBUILD": true, "RUN": true, "SHELL": true, "STOPSIGNAL": true, "USER": true,
+	"VOLUME": true, "WORKDIR": true,
+}
+
+// heredocPattern matches the start of a heredoc (<<EOF, <<-"EOF") and
+// captures its terminator.
+var heredocPattern = regexp.MustCompile(`<<-?["']?([A-Za-z_][A-Za-z0-9_]*)["']?`)
+
+// secretArgPattern matches build arg names whose values are masked in a
+// plan, since plans end up in CI logs.
+var secretArgPattern = regexp.MustCompile(`(?i)(token|secret|password|passwd|key|credential)`)
+
+// PlanBuild resolves the credentials for registry (hub, ghcr, ecr, gcp or
+// acr), measures the build context and validates the Dockerfile of opts for
+// building image. Everything that would make the build or push fail is
+// collected in Problems rather than returned, so one plan lists them all.
+func PlanBuild(image, registry string, opts BuildOptions) *BuildPlan {
+	plan := &BuildPlan{
+		Image:      image,
+		Registry:   registry,
+		ContextDir: opts.ContextDir,
+		Excludes:   opts.Excludes,
+		Dockerfile: opts.DockerfilePath,
+		Target:     opts.Target,
+		Platform:   opts.Platform,
+		NoCache:    opts.NoCache,
+		CacheFrom:  opts.CacheFrom,
+		CacheTo:    opts.CacheTo,
+		BuildArgs:  map[string]string{},
+	}
+	for k, v := range opts.BuildArgs {
+		if secretArgPattern.MatchString(k) {
+			v = "[REDACTED]"
+		}
+		plan.BuildArgs[k] = ai.Redact(v)
+	}
+
+	plan.Credentials = resolveRegistryCredentials(registry)
+	if !plan.Credentials.Resolved {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("%s credentials: %s", registry, plan.Credentials.Error))
+	}
+
+	if opts.Platform != "" && len(strings.Split(opts.Platform, "/")) < 2 {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("invalid platform %q: expected os/arch", opts.Platform))
+	}
+
+	if info, err := os.Stat(opts.ContextDir); err != nil || !info.IsDir() {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("build context %s is not a directory", opts.ContextDir))
+	} else if files, size, err := measureContext(opts.ContextDir, opts.Excludes); err != nil {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("build context: %v", err))
+	} else {
+		plan.ContextFiles, plan.ContextBytes = files, size
+	}
+
+	if rel, err := filepath.Rel(opts.ContextDir, opts.DockerfilePath); err != nil || strings.HasPrefix(rel, "..") {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("Dockerfile %s is outside the build context %s", opts.DockerfilePath, opts.ContextDir))
+	}
+	df, err := parseDockerfile(opts.DockerfilePath)
+	if err != nil {
+		plan.Problems = append(plan.Problems, err.Error())
+		return plan
+	}
+	plan.Stages, plan.BaseImages = df.stages, df.baseImages
+	plan.Problems = append(plan.Problems, df.problems...)
+	if opts.Target != "" && !containsFold(df.stages, opts.Target) {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("target stage %q is not defined in %s", opts.Target, opts.DockerfilePath))
+	}
+	for _, k := range slices.Sorted(maps.Keys(opts.BuildArgs)) {
+		if !df.args[k] {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("build arg %s is not declared by an ARG instruction and will be ignored", k))
+		}
+	}
+	return plan
+}
+
+// PrintBuildPlan writes plan to w as indented JSON and fails when the plan
+// has problems, so a dry run in a pipeline fails where the real run would.
+func PrintBuildPlan(w io.Writer, plan *BuildPlan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(plan); err != nil {
+		return err
+	}
+	if len(plan.Problems) > 0 {
+		return fmt.Errorf("dry run found %d problem(s): %s", len(plan.Problems), strings.Join(plan.Problems, "; "))
+	}
+	return nil
+}
+
+// resolveRegistryCredentials finds the credentials a push to registry would
+// use, without logging in.
+func resolveRegistryCredentials(registry string) CredentialsPlan {
+	ctx, cancel := context.WithTimeout(context.Background(), planCredentialsTimeout)
+	defer cancel()
+
+	envPair := func(user, secret string) CredentialsPlan {
+		if os.Getenv(user) == "" || os.Getenv(secret) == "" {
+			return CredentialsPlan{Error: fmt.Sprintf("%s and %s are not set", user, secret)}
+		}
+		return CredentialsPlan{Source: fmt.Sprintf("environment (%s, %s)", user, secret), Resolved: true}
+	}
+
+	switch registry {
+	case "hub":
+		return envPair("DOCKER_USERNAME", "DOCKER_PASSWORD")
+	case "ghcr":
+		return envPair("GITHUB_USERNAME", "GITHUB_TOKEN")
+	case "ecr":
+		sess, err := session.NewSession(&aws.Config{})
+		if err != nil {
+			return CredentialsPlan{Error: err.Error()}
+		}
+		creds, err := sess.Config.Credentials.GetWithContext(ctx)
+		if err != nil {
+			return CredentialsPlan{Error: err.Error()}
+		}
+		return CredentialsPlan{Source: "AWS " + creds.ProviderName, Resolved: true}
+	case "gcp":
+		if _, err := google.FindDefaultCredentials(ctx, GoogleCloudPlatformScope); err == nil {
+			source := "application default credentials"
+			if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+				source = "GOOGLE_APPLICATION_CREDENTIALS (" + path + ")"
+			}
+			return CredentialsPlan{Source: source, Resolved: true}
+		}
+		if _, err := exec.LookPath("gcloud"); err == nil {
+			return CredentialsPlan{Source: "gcloud CLI", Resolved: true}
+		}
+		return CredentialsPlan{Error: "no application default credentials and no gcloud CLI on PATH"}
+	case "acr":
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return CredentialsPlan{Error: err.Error()}
+		}
+		if _, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}}); err != nil {
+			return CredentialsPlan{Error: err.Error()}
+		}
+		return CredentialsPlan{Source: "Azure default credential chain", Resolved: true}
+	}
+	return CredentialsPlan{Error: fmt.Sprintf("unknown registry %q", registry)}
+}
+
+// measureContext returns the number of files and the size in bytes of the
+// build context the build would send, with the same excludes.
+func measureContext(dir string, excludes []string) (int, int64, error) {
+	rc, err := createTarball(dir, excludes)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
+
+	counter := &countingReader{r: rc}
+	tr := tar.NewReader(counter)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			files++
+		}
+	}
+	// Drain the tar trailer so the size matches what is sent.
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return 0, 0, err
+	}
+	return files, counter.n, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// dockerfile is what PlanBuild needs to know about a Dockerfile.
+type dockerfile struct {
+	stages     []string
+	baseImages []string
+	args       map[string]bool
+	problems   []string
+}
+
+// parseDockerfile reads the instructions of a Dockerfile, joining
+// continuation lines and skipping heredoc bodies, and records its stages,
+// base images and ARGs along with any syntax problems.
+func parseDockerfile(path string) (*dockerfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read Dockerfile: %w", err)
+	}
+	defer f.Close()
+
+	df := &dockerfile{args: map[string]bool{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo, startLine := 0, 0
+	var instruction strings.Builder
+	heredoc := ""
+	sawFrom := false
+
+	handle := func(line string, at int) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+		keyword := strings.ToUpper(fields[0])
+		if !dockerfileInstructions[keyword] {
+			df.problems = append(df.problems, fmt.Sprintf("%s:%d: unknown instruction %s", filepath.Base(path), at, fields[0]))
+			return
+		}
+		if !sawFrom && keyword != "FROM" && keyword != "ARG" {
+			df.problems = append(df.problems, fmt.Sprintf("%s:%d: %s before the first FROM", filepath.Base(path), at, keyword))
+		}
+		switch keyword {
+		case "FROM":
+			sawFrom = true
+			df.addStage(fields[1:], at, filepath.Base(path))
+		case "ARG":
+			for _, arg := range fields[1:] {
+				df.args[strings.SplitN(arg, "=", 2)[0]] = true
+			}
+		}
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if heredoc != "" {
+			if strings.TrimSpace(line) == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if instruction.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "#")) {
+			continue
+		}
+		if instruction.Len() == 0 {
+			startLine = lineNo
+		} else if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasSuffix(trimmed, "\\") {
+			instruction.WriteString(strings.TrimSuffix(trimmed, "\\") + " ")
+			continue
+		}
+		instruction.WriteString(trimmed)
+		full := instruction.String()
+		instruction.Reset()
+		handle(full, startLine)
+		if m := heredocPattern.FindStringSubmatch(full); m != nil {
+			heredoc = m[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read Dockerfile: %w", err)
+	}
+	if instruction.Len() > 0 {
+		handle(instruction.String(), startLine)
+	}
+	if !sawFrom {
+		df.problems = append(df.problems, fmt.Sprintf("%s: no FROM instruction", filepath.Base(path)))
+	}
+	return df, nil
+}
+
+// addStage records a FROM [--platform=...] IMAGE [AS NAME] instruction. An
+// image naming an earlier stage is not a base image.
+func (df *dockerfile) addStage(args []string, at int, file string) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		df.problems = append(df.problems, fmt.Sprintf("%s:%d: FROM without an image", file, at))
+		return
+	}
+	image := args[0]
+	name := ""
+	if len(args) == 3 && strings.EqualFold(args[1], "AS") {
+		name = args[2]
+	} else if len(args) != 1 {
+		df.problems = append(df.problems, fmt.Sprintf("%s:%d: FROM must be IMAGE [AS NAME]", file, at))
+	}
+	if name == "" {
+		name = fmt.Sprint(len(df.stages))
+	}
+	if !containsFold(df.stages, image) && !slices.Contains(df.baseImages, image) {
+		df.baseImages = append(df.baseImages, image)
+	}
+	df.stages = append(df.stages, name)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 // PushImageToACR pushes the specified Docker image to the specified Azure Container Registry.
@@ -92,6 +93,10 @@ func PushImageToACR(subscriptionID, resourceGroupName, registryName, imageName s
 	}
 	spinner.Success("Image tagged\n")
 
+	if skipUpToDatePush(ctx, dockerClient, taggedImage, taggedImage, auth.Credential{Username: username, Password: password}) {
+		return nil
+	}
+
 	spinner, _ = pterm.DefaultSpinner.Start("Pushing the image to ACR...")
 	authConfig := registry.AuthConfig{
 		Username:      username,
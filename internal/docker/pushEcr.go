@@ -18,6 +18,7 @@ import (
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 type ECRLogger struct {
@@ -101,6 +102,10 @@ func PushImageToECR(imageName, region, repositoryName string, useAI bool) error
 			// Create repository if it doesn't exist
 			createRepositoryInput := &ecr.CreateRepositoryInput{
 				RepositoryName: aws.String(repositoryName),
+				Tags:           createdBySmurfTags(),
+			}
+			if configs.EcrScanFindings {
+				createRepositoryInput.ImageScanningConfiguration = &ecr.ImageScanningConfiguration{ScanOnPush: aws.Bool(true)}
 			}
 			_, err = ecrClient.CreateRepository(createRepositoryInput)
 			if err != nil {
@@ -176,6 +181,13 @@ func PushImageToECR(imageName, region, repositoryName string, useAI bool) error
 	}
 	logger.logSuccess(fmt.Sprintf("Tagged image: %s%s%s", colorCyan, ecrImage, colorReset))
 
+	if skipUpToDatePush(ctx, cli, ecrImage, ecrImage, auth.Credential{Username: credentials[0], Password: credentials[1]}) {
+		if configs.EcrScanFindings {
+			return checkECRScan(ctx, ecrClient, repositoryName, tag)
+		}
+		return nil
+	}
+
 	// Push image
 	pushResponse, err := cli.ImagePush(ctx, ecrImage, image.PushOptions{
 		RegistryAuth: authStr,
@@ -223,5 +235,41 @@ func PushImageToECR(imageName, region, repositoryName string, useAI bool) error
 		logger.logSuccess(fmt.Sprintf("Image reference: %s%s%s", colorCyan, ecrImage, colorReset))
 	}
 
+	if configs.EcrScanFindings {
+		return checkECRScan(ctx, ecrClient, repositoryName, tag)
+	}
 	return nil
 }
+
+// ensureECRRepository creates repositoryName in region when it is missing,
+// as PushImageToECR does, without its step-by-step output. It returns the
+// client it used.
+func ensureECRRepository(region, repositoryName string) (*ecr.ECR, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	ecrClient := ecr.New(sess)
+
+	_, err = ecrClient.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RepositoryNames: []*string{aws.String(repositoryName)},
+	})
+	if err == nil {
+		return ecrClient, nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ecr.ErrCodeRepositoryNotFoundException {
+		return nil, fmt.Errorf("failed to describe ECR repositories: %w", err)
+	}
+
+	input := &ecr.CreateRepositoryInput{
+		RepositoryName: aws.String(repositoryName),
+		Tags:           createdBySmurfTags(),
+	}
+	if configs.EcrScanFindings {
+		input.ImageScanningConfiguration = &ecr.ImageScanningConfiguration{ScanOnPush: aws.Bool(true)}
+	}
+	if _, err := ecrClient.CreateRepository(input); err != nil {
+		return nil, fmt.Errorf("failed to create ECR repository: %w", err)
+	}
+	return ecrClient, nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"golang.org/x/oauth2/google"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 // Constants
@@ -571,10 +572,22 @@ func PushImageToGCR(projectID, imageNameWithTag string, useAI bool) error {
 		return fmt.Errorf("%sauth encoding failed%s: %w", colorRed, colorReset, err)
 	}
 
+	created, err := ensureARRepository(ctx, targetImage)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	if created {
+		logger.logSuccess(fmt.Sprintf("Created Artifact Registry repository for %s%s%s", colorCyan, targetImage, colorReset))
+	}
+
 	return pushImageGCP(ctx, dockerClient, targetImage, encodedAuth, logger)
 }
 
 func pushImageGCP(ctx context.Context, dockerClient *client.Client, imageName, encodedAuth string, logger *ColorfulLogger) error {
+	if skipUpToDatePush(ctx, dockerClient, imageName, imageName, auth.EmptyCredential) {
+		return nil
+	}
 	pushResponse, err := dockerClient.ImagePush(ctx, imageName, image.PushOptions{
 		RegistryAuth: encodedAuth,
 	})
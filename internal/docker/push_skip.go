@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// upToDateDigests holds the registry digest of each image whose push was
+// skipped because its tag already pointed to the same content, or that
+// buildx pushed from the builder, for ImageDigest: the local image may not
+// record that digest, or not exist at all.
+var upToDateDigests sync.Map
+
+// skipUpToDatePush reports whether the push of the local image source to
+// target can be skipped because target's tag already points to exactly the
+// same image, and says so. cred authenticates the lookup; an empty one uses
+// the same credentials as RemoteDigest. Any failure to tell means the image
+// is pushed as usual.
+func skipUpToDatePush(ctx context.Context, cli *client.Client, source, target string, cred auth.Credential) bool {
+	digest, err := remoteUpToDate(ctx, cli, source, target, cred)
+	if err != nil {
+		pterm.Debug.Printfln("Could not compare %s with its registry, pushing: %v", target, err)
+		return false
+	}
+	if digest == "" {
+		return false
+	}
+	upToDateDigests.Store(target, digest)
+	pterm.Success.Printfln("%s is up to date (%s); skipping push", target, shortDigest(digest))
+	return true
+}
+
+// remoteUpToDate returns the digest target's tag points to in its registry
+// when that is the local image source, or "" when the tag is missing or
+// points to something else. The image is the same when the local image
+// records that digest for target's repository (it was pulled or pushed
+// from there), when the local image ID is the manifest digest (containerd
+// image store), or when the manifest's config is the local image's (the
+// image ID of the classic store). An index never matches a single local
+// image, since pushing would replace it.
+func remoteUpToDate(ctx context.Context, cli *client.Client, source, target string, cred auth.Credential) (string, error) {
+	inspect, err := cli.ImageInspect(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	img, err := parseRemoteImage(target)
+	if err != nil {
+		return "", err
+	}
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return "", err
+	}
+	if cred != auth.EmptyCredential {
+		repo.Client.(*auth.Client).Credential = auth.StaticCredential(img.Host, cred)
+	}
+	desc, data, err := fetchManifest(ctx, repo, img.Tag)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	remote := desc.Digest.String()
+
+	if sameRepository(inspect.RepoDigests, target, remote) || inspect.ID == remote {
+		return remote, nil
+	}
+	if isIndex(desc.MediaType) {
+		return "", nil
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", err
+	}
+	if manifest.Config.Digest.String() == inspect.ID {
+		return remote, nil
+	}
+	return "", nil
+}
+
+// sameRepository reports whether repoDigests ("repo@sha256:...") holds
+// digest for the repository of target, comparing normalized names so
+// myapp and docker.io/library/myapp match.
+func sameRepository(repoDigests []string, target, digest string) bool {
+	want, err := reference.ParseNormalizedNamed(imageRepository(target))
+	if err != nil {
+		return false
+	}
+	for _, rd := range repoDigests {
+		name, d, ok := strings.Cut(rd, "@")
+		if !ok || d != digest {
+			continue
+		}
+		if named, err := reference.ParseNormalizedNamed(name); err == nil && named.Name() == want.Name() {
+			return true
+		}
+	}
+	return false
+}
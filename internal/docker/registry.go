@@ -0,0 +1,267 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/distribution/reference"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// dockerHubRegistry is the host Docker Hub's registry API is served from;
+// image references name it docker.io.
+const dockerHubRegistry = "registry-1.docker.io"
+
+var ecrHostPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// remoteImage is an image reference split into the parts the registry API
+// needs: the API host, the repository path and the tag.
+type remoteImage struct {
+	Host       string
+	Repository string
+	Tag        string
+}
+
+// parseRemoteImage normalizes imageRef the way docker does (myapp:v1 is
+// docker.io/library/myapp:v1) and defaults the tag to latest.
+func parseRemoteImage(imageRef string) (remoteImage, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return remoteImage{}, fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+
+	img := remoteImage{
+		Host:       reference.Domain(named),
+		Repository: reference.Path(named),
+		Tag:        "latest",
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		img.Tag = tagged.Tag()
+	}
+	if img.Host == "docker.io" {
+		img.Host = dockerHubRegistry
+	}
+	return img, nil
+}
+
+// remoteRepository returns a registry API client for the repository of img,
+// authenticated with registryCredential.
+func remoteRepository(img remoteImage) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(img.Host + "/" + img.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository %s/%s: %w", img.Host, img.Repository, err)
+	}
+	repo.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: registryCredential,
+	}
+	return repo, nil
+}
+
+// registryCredential resolves credentials for a registry host the same way
+// the matching push command does: the ECR API for ECR, GITHUB_USERNAME /
+// GITHUB_TOKEN for GHCR, the Google auth chain for GCR and Artifact Registry,
+// DOCKER_USERNAME / DOCKER_PASSWORD for Docker Hub, and the job's
+// CI_REGISTRY_USER / CI_REGISTRY_PASSWORD for the GitLab container registry
+// of a CI job (CI_REGISTRY). Anything else (and any of those without
+// credentials) falls back to the docker config and its credential helpers.
+func registryCredential(ctx context.Context, host string) (auth.Credential, error) {
+	switch {
+	case ecrHostPattern.MatchString(host):
+		username, password, err := ecrCredential(ecrHostPattern.FindStringSubmatch(host)[2])
+		if err != nil {
+			return auth.EmptyCredential, err
+		}
+		return auth.Credential{Username: username, Password: password}, nil
+	case host == "ghcr.io":
+		if os.Getenv("GITHUB_USERNAME") != "" && os.Getenv("GITHUB_TOKEN") != "" {
+			return auth.Credential{Username: os.Getenv("GITHUB_USERNAME"), Password: os.Getenv("GITHUB_TOKEN")}, nil
+		}
+	case isGCPRegistry(host):
+		if cfg, err := NewAuthProvider().getAuthConfig(host); err == nil {
+			return auth.Credential{Username: cfg.Username, Password: cfg.Password}, nil
+		}
+	case host == dockerHubRegistry:
+		if os.Getenv("DOCKER_USERNAME") != "" && os.Getenv("DOCKER_PASSWORD") != "" {
+			return auth.Credential{Username: os.Getenv("DOCKER_USERNAME"), Password: os.Getenv("DOCKER_PASSWORD")}, nil
+		}
+	case host == os.Getenv("CI_REGISTRY"):
+		if os.Getenv("CI_REGISTRY_USER") != "" && os.Getenv("CI_REGISTRY_PASSWORD") != "" {
+			return auth.Credential{Username: os.Getenv("CI_REGISTRY_USER"), Password: os.Getenv("CI_REGISTRY_PASSWORD")}, nil
+		}
+	}
+
+	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return auth.EmptyCredential, nil
+	}
+	return store.Get(ctx, credentials.ServerAddressFromHostname(host))
+}
+
+// RegistryCredential resolves credentials for a registry host the way
+// smurf's own pushes do, for clients outside this package such as Helm's OCI
+// registry and chart repository clients. ECR and GCP credentials are minted
+// on every call, so calling it again refreshes an expired token.
+func RegistryCredential(ctx context.Context, host string) (auth.Credential, error) {
+	if host == "docker.io" {
+		host = dockerHubRegistry
+	}
+	return registryCredential(ctx, host)
+}
+
+// PullCredential returns the registry server of imageRef and the credentials
+// smurf pushes to it with, for a kubernetes.io/dockerconfigjson Secret that
+// lets the cluster pull the image. Docker Hub is keyed by its index URL, as
+// docker login writes it.
+func PullCredential(ctx context.Context, imageRef string) (server, username, password string, err error) {
+	img, err := parseRemoteImage(imageRef)
+	if err != nil {
+		return "", "", "", err
+	}
+	cred, err := registryCredential(ctx, img.Host)
+	if err != nil {
+		return "", "", "", err
+	}
+	if cred.Username == "" || cred.Password == "" {
+		return "", "", "", fmt.Errorf("no username and password found for %s; log in with docker login or set the registry's credentials", img.Host)
+	}
+
+	server = img.Host
+	if server == dockerHubRegistry {
+		server = "https://index.docker.io/v1/"
+	}
+	return server, cred.Username, cred.Password, nil
+}
+
+func isGCPRegistry(host string) bool {
+	return host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev")
+}
+
+// ecrCredential exchanges the AWS credentials of the environment for an ECR
+// registry login in region.
+func ecrCredential(region string) (string, string, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	out, err := ecr.New(sess).GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get ECR authorization token: %w", err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return "", "", fmt.Errorf("no authorization data received from ECR")
+	}
+	token, err := base64.StdEncoding.DecodeString(aws.StringValue(out.AuthorizationData[0].AuthorizationToken))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode authorization token: %w", err)
+	}
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid authorization token format")
+	}
+	return username, password, nil
+}
+
+// RemoteDigest returns the digest imageRef's tag currently points to in its
+// registry, or "" when the tag does not exist.
+func RemoteDigest(ctx context.Context, imageRef string) (string, error) {
+	img, err := parseRemoteImage(imageRef)
+	if err != nil {
+		return "", err
+	}
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return "", err
+	}
+	desc, err := repo.Resolve(ctx, img.Tag)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", imageRef, err)
+	}
+	return desc.Digest.String(), nil
+}
+
+// RestoreRemoteTag points imageRef's tag back at digest, a manifest that is
+// still stored in the same repository. No image data is pulled or pushed.
+func RestoreRemoteTag(ctx context.Context, imageRef, digest string) error {
+	img, err := parseRemoteImage(imageRef)
+	if err != nil {
+		return err
+	}
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return err
+	}
+	desc, err := repo.Resolve(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s@%s: %w", img.Repository, digest, err)
+	}
+	if err := repo.Tag(ctx, desc, img.Tag); err != nil {
+		return fmt.Errorf("failed to tag %s@%s as %s: %w", img.Repository, digest, img.Tag, err)
+	}
+	return nil
+}
+
+// DeleteRemoteTag removes imageRef's tag from its registry. ECR can untag
+// directly; other registries only support deleting the manifest itself, so
+// the tag is removed by deleting the manifest it points to, which must be
+// digest. Registries that refuse deletes (Docker Hub, GHCR) return an error.
+func DeleteRemoteTag(ctx context.Context, imageRef, digest string) error {
+	img, err := parseRemoteImage(imageRef)
+	if err != nil {
+		return err
+	}
+
+	if m := ecrHostPattern.FindStringSubmatch(img.Host); m != nil {
+		sess, err := session.NewSession(&aws.Config{Region: aws.String(m[2])})
+		if err != nil {
+			return fmt.Errorf("failed to create AWS session: %w", err)
+		}
+		out, err := ecr.New(sess).BatchDeleteImageWithContext(ctx, &ecr.BatchDeleteImageInput{
+			RegistryId:     aws.String(m[1]),
+			RepositoryName: aws.String(img.Repository),
+			ImageIds:       []*ecr.ImageIdentifier{{ImageTag: aws.String(img.Tag)}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete ECR tag %s: %w", img.Tag, err)
+		}
+		if len(out.Failures) > 0 {
+			return fmt.Errorf("failed to delete ECR tag %s: %s", img.Tag, aws.StringValue(out.Failures[0].FailureReason))
+		}
+		return nil
+	}
+
+	repo, err := remoteRepository(img)
+	if err != nil {
+		return err
+	}
+	desc, err := repo.Resolve(ctx, img.Tag)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to resolve %s: %w", imageRef, err)
+	}
+	if desc.Digest.String() != digest {
+		return fmt.Errorf("%s now points to %s, not the pushed %s; leaving it in place", imageRef, desc.Digest, digest)
+	}
+	if err := repo.Delete(ctx, desc); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", imageRef, err)
+	}
+	return nil
+}
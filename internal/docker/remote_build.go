@@ -0,0 +1,198 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"time"
+
+	"github.com/pterm/pterm"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// RemoteBuildK8s builds on a buildkitd running in the current kube context
+// instead of on this machine.
+const RemoteBuildK8s = "k8s"
+
+// Defaults of the buildkitd deployment remote builds run on.
+const (
+	DefaultRemoteBuildNamespace = "smurf-build"
+	remoteBuilderName           = "smurf-buildkitd"
+	remoteBuilderImage          = "moby/buildkit:v0.16.0"
+	remoteBuilderReadyTimeout   = 3 * time.Minute
+)
+
+// RemoteBuildOptions configures a build on a buildkitd in the cluster.
+type RemoteBuildOptions struct {
+	Namespace string // where the buildkitd deployment runs; DefaultRemoteBuildNamespace when empty
+	Keep      bool   // leave a buildkitd smurf started running for the next build
+}
+
+// ValidRemoteBuild reports an unknown --remote-build.
+func ValidRemoteBuild(target string) error {
+	if target == "" || target == RemoteBuildK8s {
+		return nil
+	}
+	return fmt.Errorf("invalid remote build %q: must be k8s", target)
+}
+
+// startRemoteBuilder makes sure a buildkitd deployment runs in the cluster
+// of the current kube context and returns the BUILDKIT_HOST that reaches it
+// through kubectl exec, so the build context is streamed from this machine
+// and no daemon runs here. An existing deployment is reused and left alone;
+// one started here is deleted by stop unless opts.Keep is set.
+func startRemoteBuilder(ctx context.Context, opts RemoteBuildOptions) (host string, stop func(), err error) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return "", nil, fmt.Errorf("kubectl not found in PATH; buildctl connects to the remote buildkitd through it")
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = DefaultRemoteBuildNamespace
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load the kube config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Starting buildkitd in namespace %s...", namespace))
+	created, err := ensureRemoteBuilder(ctx, client, namespace)
+	if err != nil {
+		spinner.Fail(err.Error())
+		return "", nil, err
+	}
+	stop = func() {
+		if !created || opts.Keep {
+			return
+		}
+		policy := metav1.DeletePropagationForeground
+		err := client.AppsV1().Deployments(namespace).Delete(context.Background(), remoteBuilderName,
+			metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil && !apierrors.IsNotFound(err) {
+			pterm.Warning.Printfln("Failed to remove the buildkitd deployment %s/%s: %v", namespace, remoteBuilderName, err)
+			return
+		}
+		pterm.Info.Printfln("Removed the buildkitd deployment %s/%s", namespace, remoteBuilderName)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, remoteBuilderReadyTimeout)
+	defer cancel()
+	pod, err := readyBuilderPod(waitCtx, client, namespace)
+	if err != nil {
+		spinner.Fail(err.Error())
+		stop()
+		return "", nil, err
+	}
+	if created {
+		spinner.Success(fmt.Sprintf("Started buildkitd %s/%s", namespace, pod))
+	} else {
+		spinner.Success(fmt.Sprintf("Reusing buildkitd %s/%s", namespace, pod))
+	}
+	return remoteBuildkitHost(namespace, pod), stop, nil
+}
+
+// ensureRemoteBuilder creates the namespace and the buildkitd deployment
+// when missing, and reports whether it created the deployment.
+func ensureRemoteBuilder(ctx context.Context, client kubernetes.Interface, namespace string) (bool, error) {
+	_, err := client.AppsV1().Deployments(namespace).Get(ctx, remoteBuilderName, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to look up the buildkitd deployment: %w", err)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if _, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+	_, err = client.AppsV1().Deployments(namespace).Create(ctx, remoteBuilderDeployment(namespace), metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// Another build started it in the meantime; it owns the teardown.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create the buildkitd deployment: %w", err)
+	}
+	return true, nil
+}
+
+// remoteBuilderDeployment is a single buildkitd. It runs privileged, as
+// buildkitd needs to create the mounts and namespaces of build steps.
+func remoteBuilderDeployment(namespace string) *appsv1.Deployment {
+	labels := map[string]string{
+		"app.kubernetes.io/name":       remoteBuilderName,
+		"app.kubernetes.io/managed-by": "smurf",
+	}
+	replicas := int32(1)
+	privileged := true
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: remoteBuilderName, Namespace: namespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:            "buildkitd",
+						Image:           remoteBuilderImage,
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{Command: []string{"buildctl", "debug", "workers"}},
+							},
+							PeriodSeconds: 2,
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// readyBuilderPod waits for a ready, not terminating buildkitd pod and
+// returns its name.
+func readyBuilderPod(ctx context.Context, client kubernetes.Interface, namespace string) (string, error) {
+	selector := "app.kubernetes.io/name=" + remoteBuilderName
+	for {
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return "", fmt.Errorf("failed to list buildkitd pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp == nil && podReady(pod) {
+				return pod.Name, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("buildkitd in namespace %s did not become ready within %s", namespace, remoteBuilderReadyTimeout)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// remoteBuildkitHost is the buildctl address of a buildkitd pod, reached
+// with kubectl exec in the current kube context.
+func remoteBuildkitHost(namespace, pod string) string {
+	return "kube-pod://" + pod + "?" + url.Values{"namespace": {namespace}}.Encode()
+}
@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEnsureRemoteBuilder(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	created, err := ensureRemoteBuilder(ctx, client, "ci")
+	if err != nil || !created {
+		t.Fatalf("ensureRemoteBuilder = %v, %v, want the deployment created", created, err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, "ci", metav1.GetOptions{}); err != nil {
+		t.Errorf("namespace not created: %v", err)
+	}
+	created, err = ensureRemoteBuilder(ctx, client, "ci")
+	if err != nil || created {
+		t.Errorf("second ensureRemoteBuilder = %v, %v, want the deployment reused", created, err)
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "smurf-buildkitd-abc", Namespace: "ci",
+			Labels: map[string]string{"app.kubernetes.io/name": remoteBuilderName}},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+	}
+	if _, err := client.CoreV1().Pods("ci").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	name, err := readyBuilderPod(waitCtx, client, "ci")
+	if err != nil || name != "smurf-buildkitd-abc" {
+		t.Errorf("readyBuilderPod = %q, %v", name, err)
+	}
+
+	if got, want := remoteBuildkitHost("ci", name), "kube-pod://smurf-buildkitd-abc?namespace=ci"; got != want {
+		t.Errorf("remoteBuildkitHost = %q, want %q", got, want)
+	}
+	if err := ValidRemoteBuild("ssh"); err == nil {
+		t.Error("ValidRemoteBuild accepted ssh")
+	}
+}
@@ -0,0 +1,425 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/pterm/pterm"
+	"golang.org/x/oauth2/google"
+)
+
+// The tag (ECR) or label (Artifact Registry) smurf sets on the repositories
+// it creates when an image is pushed to one that doesn't exist.
+const (
+	CreatedByKey   = "created-by"
+	CreatedByValue = "smurf"
+)
+
+// artifactRegistryAPI is the Artifact Registry REST endpoint; a variable so
+// tests can point it at a fake.
+var artifactRegistryAPI = "https://artifactregistry.googleapis.com/v1"
+
+// googleAccessToken returns an OAuth token for the Google APIs, from gcloud
+// or the application default credentials; a variable so tests can stub it.
+var googleAccessToken = func(ctx context.Context) (string, error) {
+	if token, err := NewAuthProvider().getGcloudAccessToken(); err == nil {
+		return token, nil
+	}
+	creds, err := google.FindDefaultCredentials(ctx, GoogleCloudPlatformScope)
+	if err != nil {
+		return "", fmt.Errorf("no Google credentials: %w", err)
+	}
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+var arHostPattern = regexp.MustCompile(`^([a-z0-9-]+)-docker\.pkg\.dev$`)
+
+// Repository is a repository of an ECR or Artifact Registry registry.
+type Repository struct {
+	Registry       string    `json:"registry"`
+	Name           string    `json:"name"`
+	URI            string    `json:"uri"`
+	Created        time.Time `json:"created"`
+	CreatedBySmurf bool      `json:"createdBySmurf"`
+
+	// id is what deleting the repository takes: its ECR registry ID or
+	// its Artifact Registry resource name.
+	id string
+}
+
+// repoRegistry is a registry whose repositories can be listed and deleted:
+// an ECR registry (ACCOUNT.dkr.ecr.REGION.amazonaws.com) or the Artifact
+// Registry repositories of a project (LOCATION-docker.pkg.dev/PROJECT).
+type repoRegistry struct {
+	host     string
+	ecrID    string
+	region   string
+	location string
+	project  string
+}
+
+func parseRepoRegistry(registry string) (repoRegistry, error) {
+	host, namespace, _ := strings.Cut(strings.TrimSuffix(registry, "/"), "/")
+	if m := ecrHostPattern.FindStringSubmatch(host); m != nil && namespace == "" {
+		return repoRegistry{host: host, ecrID: m[1], region: m[2]}, nil
+	}
+	if m := arHostPattern.FindStringSubmatch(host); m != nil && namespace != "" && !strings.Contains(namespace, "/") {
+		return repoRegistry{host: host, location: m[1], project: namespace}, nil
+	}
+	return repoRegistry{}, fmt.Errorf("unsupported registry %q: want ACCOUNT.dkr.ecr.REGION.amazonaws.com or LOCATION-docker.pkg.dev/PROJECT", registry)
+}
+
+func (r repoRegistry) String() string {
+	if r.ecrID != "" {
+		return r.host
+	}
+	return r.host + "/" + r.project
+}
+
+// ListRepositories lists the repositories of registry, only those tagged or
+// labelled created-by=smurf when createdBySmurf is set.
+func ListRepositories(ctx context.Context, registry string, createdBySmurf bool) ([]Repository, error) {
+	reg, err := parseRepoRegistry(registry)
+	if err != nil {
+		return nil, err
+	}
+	var repos []Repository
+	if reg.ecrID != "" {
+		client, err := newECRClient(reg.region)
+		if err != nil {
+			return nil, err
+		}
+		repos, err = listECRRepositories(ctx, client, reg)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		repos, err = listARRepositories(ctx, reg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if createdBySmurf {
+		owned := []Repository{}
+		for _, r := range repos {
+			if r.CreatedBySmurf {
+				owned = append(owned, r)
+			}
+		}
+		repos = owned
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos, nil
+}
+
+// DeleteRepositories deletes repos, listed by ListRepositories, with the
+// images in them. It goes on past a repository it fails to delete and
+// returns an error naming every failure.
+func DeleteRepositories(ctx context.Context, registry string, repos []Repository) error {
+	reg, err := parseRepoRegistry(registry)
+	if err != nil {
+		return err
+	}
+	var client ecriface.ECRAPI
+	if reg.ecrID != "" {
+		if client, err = newECRClient(reg.region); err != nil {
+			return err
+		}
+	}
+
+	var failed []string
+	for _, r := range repos {
+		if reg.ecrID != "" {
+			err = deleteECRRepository(ctx, client, r)
+		} else {
+			err = deleteARRepository(ctx, r)
+		}
+		if err != nil {
+			pterm.Error.Printfln("Failed to delete %s: %v", r.URI, err)
+			failed = append(failed, r.Name)
+			continue
+		}
+		pterm.Success.Printfln("Deleted %s", r.URI)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d of %d repositories: %s", len(failed), len(repos), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func newECRClient(region string) (ecriface.ECRAPI, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return ecr.New(sess), nil
+}
+
+// createdBySmurfTags are the tags of the ECR repositories smurf creates.
+func createdBySmurfTags() []*ecr.Tag {
+	return []*ecr.Tag{{Key: aws.String(CreatedByKey), Value: aws.String(CreatedByValue)}}
+}
+
+// listECRRepositories lists the repositories of an ECR registry. ECR
+// doesn't return tags with the repositories, so each one's are read.
+func listECRRepositories(ctx context.Context, client ecriface.ECRAPI, reg repoRegistry) ([]Repository, error) {
+	var repos []Repository
+	var arns []string
+	err := client.DescribeRepositoriesPagesWithContext(ctx, &ecr.DescribeRepositoriesInput{RegistryId: aws.String(reg.ecrID)},
+		func(page *ecr.DescribeRepositoriesOutput, _ bool) bool {
+			for _, r := range page.Repositories {
+				repos = append(repos, Repository{
+					Registry: reg.String(),
+					Name:     aws.StringValue(r.RepositoryName),
+					URI:      aws.StringValue(r.RepositoryUri),
+					Created:  aws.TimeValue(r.CreatedAt),
+					id:       aws.StringValue(r.RegistryId),
+				})
+				arns = append(arns, aws.StringValue(r.RepositoryArn))
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ECR repositories: %w", err)
+	}
+
+	for i, arn := range arns {
+		out, err := client.ListTagsForResourceWithContext(ctx, &ecr.ListTagsForResourceInput{ResourceArn: aws.String(arn)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tags of %s: %w", repos[i].Name, err)
+		}
+		for _, tag := range out.Tags {
+			if aws.StringValue(tag.Key) == CreatedByKey && aws.StringValue(tag.Value) == CreatedByValue {
+				repos[i].CreatedBySmurf = true
+			}
+		}
+	}
+	return repos, nil
+}
+
+func deleteECRRepository(ctx context.Context, client ecriface.ECRAPI, r Repository) error {
+	_, err := client.DeleteRepositoryWithContext(ctx, &ecr.DeleteRepositoryInput{
+		RegistryId:     aws.String(r.id),
+		RepositoryName: aws.String(r.Name),
+		Force:          aws.Bool(true),
+	})
+	return err
+}
+
+// arRepository is a repository as the Artifact Registry API returns it.
+type arRepository struct {
+	Name       string            `json:"name"`
+	Format     string            `json:"format"`
+	Labels     map[string]string `json:"labels"`
+	CreateTime time.Time         `json:"createTime"`
+}
+
+// arOperation is the long-running operation of a create or delete.
+type arOperation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// listARRepositories lists the Docker repositories of a project in one
+// Artifact Registry location.
+func listARRepositories(ctx context.Context, reg repoRegistry) ([]Repository, error) {
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parent := fmt.Sprintf("projects/%s/locations/%s", reg.project, reg.location)
+	var repos []Repository
+	pageToken := ""
+	for {
+		endpoint := fmt.Sprintf("%s/%s/repositories?pageSize=100", artifactRegistryAPI, parent)
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		var page struct {
+			Repositories  []arRepository `json:"repositories"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		if err := callGoogleAPI(ctx, http.MethodGet, endpoint, token, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list Artifact Registry repositories: %w", err)
+		}
+		for _, r := range page.Repositories {
+			if r.Format != "DOCKER" {
+				continue
+			}
+			name := r.Name[strings.LastIndex(r.Name, "/")+1:]
+			repos = append(repos, Repository{
+				Registry:       reg.String(),
+				Name:           name,
+				URI:            reg.String() + "/" + name,
+				Created:        r.CreateTime,
+				CreatedBySmurf: r.Labels[CreatedByKey] == CreatedByValue,
+				id:             r.Name,
+			})
+		}
+		if page.NextPageToken == "" {
+			return repos, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func deleteARRepository(ctx context.Context, r Repository) error {
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var op arOperation
+	if err := callGoogleAPI(ctx, http.MethodDelete, artifactRegistryAPI+"/"+r.id, token, nil, &op); err != nil {
+		return err
+	}
+	return waitForAROperation(ctx, token, op)
+}
+
+// ensureARRepository creates the Docker repository image is pushed to, with
+// the created-by=smurf label, when the Artifact Registry repository doesn't
+// exist yet. image is LOCATION-docker.pkg.dev/PROJECT/REPOSITORY/NAME[:TAG];
+// any other reference is left alone. The push reports a missing repository
+// that couldn't be looked up, so only a failed create is an error.
+func ensureARRepository(ctx context.Context, image string) (bool, error) {
+	parts := strings.SplitN(image, "/", 4)
+	if len(parts) < 4 {
+		return false, nil
+	}
+	m := arHostPattern.FindStringSubmatch(parts[0])
+	if m == nil {
+		return false, nil
+	}
+	location, project, repository := m[1], parts[1], parts[2]
+
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		pterm.Warning.Printfln("Not checking that repository %s exists: %v", repository, err)
+		return false, nil
+	}
+	parent := fmt.Sprintf("%s/projects/%s/locations/%s/repositories", artifactRegistryAPI, project, location)
+	var existing arRepository
+	err = callGoogleAPI(ctx, http.MethodGet, parent+"/"+repository, token, nil, &existing)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, errAPINotFound) {
+		pterm.Warning.Printfln("Not checking that repository %s exists: %v", repository, err)
+		return false, nil
+	}
+
+	body := map[string]interface{}{"format": "DOCKER", "labels": map[string]string{CreatedByKey: CreatedByValue}}
+	var op arOperation
+	if err := callGoogleAPI(ctx, http.MethodPost, parent+"?repositoryId="+url.QueryEscape(repository), token, body, &op); err != nil {
+		return false, fmt.Errorf("failed to create Artifact Registry repository %s: %w", repository, err)
+	}
+	if err := waitForAROperation(ctx, token, op); err != nil {
+		return false, fmt.Errorf("failed to create Artifact Registry repository %s: %w", repository, err)
+	}
+	return true, nil
+}
+
+// waitForAROperation polls op until it is done.
+func waitForAROperation(ctx context.Context, token string, op arOperation) error {
+	deadline := time.Now().Add(2 * time.Minute)
+	for !op.Done {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("operation %s did not finish in time", op.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if err := callGoogleAPI(ctx, http.MethodGet, artifactRegistryAPI+"/"+op.Name, token, nil, &op); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return errors.New(op.Error.Message)
+	}
+	return nil
+}
+
+// callGoogleAPI sends body, when not nil, as JSON to a Google API endpoint
+// and decodes the JSON response into v.
+func callGoogleAPI(ctx context.Context, method, endpoint, token string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", endpoint, errAPINotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return fmt.Errorf("%s: %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// PrintRepositories prints repositories as a table.
+func PrintRepositories(repos []Repository) {
+	if len(repos) == 0 {
+		pterm.Info.Println("No repositories found")
+		return
+	}
+	tableData := pterm.TableData{{"REPOSITORY", "CREATED", "CREATED BY SMURF"}}
+	for _, r := range repos {
+		created, owned := "-", "no"
+		if !r.Created.IsZero() {
+			created = r.Created.Local().Format("2006-01-02 15:04")
+		}
+		if r.CreatedBySmurf {
+			owned = "yes"
+		}
+		tableData = append(tableData, []string{r.URI, created, owned})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
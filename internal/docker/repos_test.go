@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+// fakeReposECR has two repositories, one of them created by smurf.
+type fakeReposECR struct {
+	ecriface.ECRAPI
+}
+
+func (fakeReposECR) DescribeRepositoriesPagesWithContext(_ aws.Context, _ *ecr.DescribeRepositoriesInput, fn func(*ecr.DescribeRepositoriesOutput, bool) bool, _ ...request.Option) error {
+	fn(&ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{
+		{RepositoryName: aws.String("api"), RepositoryArn: aws.String("arn:api"), RegistryId: aws.String("123456789012"),
+			RepositoryUri: aws.String("123456789012.dkr.ecr.us-east-1.amazonaws.com/api")},
+	}}, false)
+	fn(&ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{
+		{RepositoryName: aws.String("test-web"), RepositoryArn: aws.String("arn:test-web"), RegistryId: aws.String("123456789012"),
+			RepositoryUri: aws.String("123456789012.dkr.ecr.us-east-1.amazonaws.com/test-web")},
+	}}, true)
+	return nil
+}
+
+func (fakeReposECR) ListTagsForResourceWithContext(_ aws.Context, in *ecr.ListTagsForResourceInput, _ ...request.Option) (*ecr.ListTagsForResourceOutput, error) {
+	if aws.StringValue(in.ResourceArn) == "arn:test-web" {
+		return &ecr.ListTagsForResourceOutput{Tags: createdBySmurfTags()}, nil
+	}
+	return &ecr.ListTagsForResourceOutput{Tags: []*ecr.Tag{{Key: aws.String("team"), Value: aws.String("payments")}}}, nil
+}
+
+func TestListECRRepositories(t *testing.T) {
+	reg, err := parseRepoRegistry("123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := listECRRepositories(context.Background(), fakeReposECR{}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].CreatedBySmurf || !got[1].CreatedBySmurf || got[1].id != "123456789012" {
+		t.Errorf("listECRRepositories() = %+v, want api and test-web, created by smurf", got)
+	}
+}
+
+func TestParseRepoRegistry(t *testing.T) {
+	for _, registry := range []string{"ghcr.io/my-org", "us-central1-docker.pkg.dev", "us-central1-docker.pkg.dev/p/repo", "123456789012.dkr.ecr.us-east-1.amazonaws.com/api"} {
+		if _, err := parseRepoRegistry(registry); err == nil {
+			t.Errorf("parseRepoRegistry(%q): want an error", registry)
+		}
+	}
+	reg, err := parseRepoRegistry("europe-west1-docker.pkg.dev/my-project/")
+	if err != nil || reg.location != "europe-west1" || reg.project != "my-project" {
+		t.Errorf("parseRepoRegistry(AR) = %+v, %v", reg, err)
+	}
+}
+
+// fakeArtifactRegistry serves the Artifact Registry API for my-project in
+// us-central1, where the repository api exists.
+func fakeArtifactRegistry(t *testing.T, created *map[string]interface{}) {
+	const parent = "/projects/my-project/locations/us-central1/repositories"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gcp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == parent && r.URL.Query().Get("pageToken") == "":
+			_, _ = w.Write([]byte(`{"repositories":[
+				{"name":"projects/my-project/locations/us-central1/repositories/api","format":"DOCKER","createTime":"2026-09-01T10:00:00Z"},
+				{"name":"projects/my-project/locations/us-central1/repositories/charts","format":"MAVEN"}],
+				"nextPageToken":"2"}`))
+		case r.Method == http.MethodGet && r.URL.Path == parent:
+			_, _ = w.Write([]byte(`{"repositories":[
+				{"name":"projects/my-project/locations/us-central1/repositories/test-web","format":"DOCKER","labels":{"created-by":"smurf"}}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == parent+"/api":
+			_, _ = w.Write([]byte(`{"name":"projects/my-project/locations/us-central1/repositories/api","format":"DOCKER"}`))
+		case r.Method == http.MethodPost && r.URL.Path == parent && r.URL.Query().Get("repositoryId") == "test-web":
+			if err := json.NewDecoder(r.Body).Decode(created); err != nil {
+				t.Error(err)
+			}
+			_, _ = w.Write([]byte(`{"name":"projects/my-project/locations/us-central1/operations/op-1","done":false}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/projects/my-project/locations/us-central1/operations/op-1":
+			_, _ = w.Write([]byte(`{"name":"projects/my-project/locations/us-central1/operations/op-1","done":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	oldAPI, oldToken := artifactRegistryAPI, googleAccessToken
+	artifactRegistryAPI = srv.URL
+	googleAccessToken = func(context.Context) (string, error) { return "gcp-token", nil }
+	t.Cleanup(func() { artifactRegistryAPI, googleAccessToken = oldAPI, oldToken })
+}
+
+func TestListARRepositories(t *testing.T) {
+	fakeArtifactRegistry(t, nil)
+
+	got, err := ListRepositories(context.Background(), "us-central1-docker.pkg.dev/my-project", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "api" || got[0].CreatedBySmurf || got[0].Created.IsZero() ||
+		got[1].URI != "us-central1-docker.pkg.dev/my-project/test-web" || !got[1].CreatedBySmurf {
+		t.Errorf("ListRepositories(AR) = %+v, want the Docker repositories api and test-web", got)
+	}
+
+	owned, err := ListRepositories(context.Background(), "us-central1-docker.pkg.dev/my-project", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || owned[0].Name != "test-web" {
+		t.Errorf("ListRepositories(AR, created by smurf) = %+v, want test-web", owned)
+	}
+}
+
+func TestEnsureARRepository(t *testing.T) {
+	var body map[string]interface{}
+	fakeArtifactRegistry(t, &body)
+	ctx := context.Background()
+
+	if created, err := ensureARRepository(ctx, "us-central1-docker.pkg.dev/my-project/api/api:v1"); err != nil || created {
+		t.Errorf("ensureARRepository(existing) = %v, %v, want false", created, err)
+	}
+	if created, err := ensureARRepository(ctx, "gcr.io/my-project/api:v1"); err != nil || created {
+		t.Errorf("ensureARRepository(gcr.io) = %v, %v, want it left alone", created, err)
+	}
+
+	created, err := ensureARRepository(ctx, "us-central1-docker.pkg.dev/my-project/test-web/web:v1")
+	if err != nil || !created {
+		t.Fatalf("ensureARRepository(missing) = %v, %v, want it created", created, err)
+	}
+	labels, _ := body["labels"].(map[string]interface{})
+	if body["format"] != "DOCKER" || labels[CreatedByKey] != CreatedByValue {
+		t.Errorf("created repository = %v, want a Docker repository labelled %s=%s", body, CreatedByKey, CreatedByValue)
+	}
+
+	if _, err := ensureARRepository(ctx, "us-central1-docker.pkg.dev/other-project/web/web:v1"); err == nil ||
+		!strings.Contains(err.Error(), "failed to create") {
+		t.Errorf("ensureARRepository(create fails) = %v, want an error", err)
+	}
+}
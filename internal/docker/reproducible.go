@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// sourceDateEpoch returns the SOURCE_DATE_EPOCH of a reproducible build of
+// dir: the one in the environment, else the commit time of the checked out
+// git revision, else 0, so the same sources build at the same time on every
+// machine.
+func sourceDateEpoch(dir string) (int64, error) {
+	if v := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")); v != "" {
+		epoch, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || epoch < 0 {
+			return 0, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be Unix seconds", v)
+		}
+		return epoch, nil
+	}
+	out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%ct").Output()
+	if err != nil {
+		return 0, nil
+	}
+	epoch, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return epoch, nil
+}
+
+// prepareReproducible resolves the SOURCE_DATE_EPOCH of a reproducible
+// build and passes it to the build as a build argument, which BuildKit
+// uses for the image's creation time and the timestamps of its files.
+func prepareReproducible(opts *BuildOptions) (int64, error) {
+	epoch, err := sourceDateEpoch(contextDir(*opts))
+	if err != nil {
+		return 0, err
+	}
+	args := make(map[string]string, len(opts.BuildArgs)+1)
+	for k, v := range opts.BuildArgs {
+		args[k] = v
+	}
+	args["SOURCE_DATE_EPOCH"] = strconv.FormatInt(epoch, 10)
+	opts.BuildArgs = args
+	pterm.Info.Printfln("Reproducible build: SOURCE_DATE_EPOCH=%d (%s)", epoch, time.Unix(epoch, 0).UTC().Format(time.RFC3339))
+	return epoch, nil
+}
+
+// normalizeHeader strips what differs between machines from a build
+// context entry: its timestamps, which become epoch, and its owner.
+func normalizeHeader(hdr *tar.Header, epoch time.Time) {
+	hdr.ModTime = epoch
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+	hdr.Format = tar.FormatPAX
+}
+
+// printReproducibleDigest prints the digest a reproducible build produced,
+// to compare with the digest of the same sources built elsewhere.
+func printReproducibleDigest(ref, digest string) {
+	pterm.Info.Printfln("Reproducible build digest of %s: %s", ref, digest)
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if epoch, err := sourceDateEpoch(t.TempDir()); err != nil || epoch != 1700000000 {
+		t.Errorf("sourceDateEpoch = %d, %v; want 1700000000", epoch, err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := sourceDateEpoch(t.TempDir()); err == nil {
+		t.Error("sourceDateEpoch(yesterday): want an error")
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if epoch, err := sourceDateEpoch(t.TempDir()); err != nil || epoch != 0 {
+		t.Errorf("sourceDateEpoch outside git = %d, %v; want 0", epoch, err)
+	}
+}
+
+func TestContextTarballReproducible(t *testing.T) {
+	tarball := func(mtime time.Time) []byte {
+		dir := t.TempDir()
+		files := map[string]string{"Dockerfile": "FROM scratch\n", "b/z.txt": "z", "a.txt": "a", "b/c/d.txt": "d"}
+		for name, data := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		epoch := time.Unix(1700000000, 0).UTC()
+		rc, err := contextTarball(dir, nil, &epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := tarball(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	second := tarball(time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC))
+	if !bytes.Equal(first, second) {
+		t.Error("the contexts of the same sources with different mtimes differ")
+	}
+}
+
+func TestBuildctlArgsReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	opts := BuildOptions{ContextDir: "/src", BuildArgs: map[string]string{"A": "1"}, Reproducible: true}
+	if _, err := prepareReproducible(&opts); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(buildctlArgs("app:v1", "/store/app_v1.tar", opts), " ")
+	for _, want := range []string{"--opt build-arg:A=1 --opt build-arg:SOURCE_DATE_EPOCH=1700000000", ",rewrite-timestamp=true"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("buildctl args %q do not contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// Results of a retag.
+const (
+	RetagDone      = "retagged"
+	RetagUnchanged = "unchanged"
+	RetagPlanned   = "would retag"
+	RetagSkipped   = "skipped"
+	RetagFailed    = "failed"
+)
+
+// RetagOptions configures RetagImages.
+type RetagOptions struct {
+	DryRun  bool          // resolve and report, without tagging
+	Force   bool          // move a target tag that points at another image
+	Timeout time.Duration // per image
+}
+
+// RetagResult is what became of one image of a retag.
+type RetagResult struct {
+	Image    string `json:"image"`              // repository, without a tag
+	Digest   string `json:"digest,omitempty"`   // the source tag's manifest
+	Previous string `json:"previous,omitempty"` // what the target tag pointed at before
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+}
+
+// tagStore is the part of a registry repository a retag uses.
+type tagStore interface {
+	Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error)
+	Tag(ctx context.Context, desc ocispec.Descriptor, reference string) error
+}
+
+// retagStore returns the repository of img; tests swap it for an in-memory
+// store.
+var retagStore = func(img remoteImage) (tagStore, error) {
+	return remoteRepository(img)
+}
+
+// RetagImages points the tag to of every image at the manifest its tag from
+// points at, in the registry: the manifest is put again under the new tag,
+// so no layers are pulled or pushed. from and to may start with a colon
+// (:rc-42). A target tag that already points at another manifest is left
+// alone, unless opts.Force moves it. Every image is tried; the results say
+// which were retagged, and the error is set when any failed.
+func RetagImages(images []string, from, to string, opts RetagOptions) ([]RetagResult, error) {
+	from, to = strings.TrimPrefix(from, ":"), strings.TrimPrefix(to, ":")
+	if from == "" || to == "" {
+		return nil, errors.New("both --from and --to tags are required")
+	}
+	if from == to {
+		return nil, fmt.Errorf("--from and --to are the same tag %s", from)
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no images to retag")
+	}
+
+	results := make([]RetagResult, 0, len(images))
+	failed := 0
+	for _, image := range images {
+		r := retagImage(image, from, to, opts)
+		if r.Result == RetagFailed || r.Result == RetagSkipped {
+			failed++
+		}
+		results = append(results, r)
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d images not retagged", failed, len(images))
+	}
+	return results, nil
+}
+
+// retagImage retags one image of RetagImages.
+func retagImage(image, from, to string, opts RetagOptions) RetagResult {
+	r := RetagResult{Image: image}
+	fail := func(err error) RetagResult {
+		r.Result, r.Error = RetagFailed, err.Error()
+		return r
+	}
+
+	img, err := parseRemoteImage(image)
+	if err != nil {
+		return fail(err)
+	}
+	store, err := retagStore(img)
+	if err != nil {
+		return fail(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	desc, err := store.Resolve(ctx, from)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return fail(fmt.Errorf("tag %s not found", from))
+		}
+		return fail(fmt.Errorf("failed to resolve %s:%s: %w", image, from, err))
+	}
+	r.Digest = desc.Digest.String()
+
+	current, err := store.Resolve(ctx, to)
+	switch {
+	case err == nil:
+		r.Previous = current.Digest.String()
+	case !errors.Is(err, errdef.ErrNotFound):
+		return fail(fmt.Errorf("failed to resolve %s:%s: %w", image, to, err))
+	}
+	if r.Previous == r.Digest {
+		r.Result = RetagUnchanged
+		return r
+	}
+	if r.Previous != "" && !opts.Force {
+		r.Result, r.Error = RetagSkipped, fmt.Sprintf("tag %s already points at %s (--force moves it)", to, shortDigest(r.Previous))
+		return r
+	}
+	if opts.DryRun {
+		r.Result = RetagPlanned
+		return r
+	}
+	if err := store.Tag(ctx, desc, to); err != nil {
+		return fail(fmt.Errorf("failed to tag %s@%s as %s: %w", image, shortDigest(r.Digest), to, err))
+	}
+	r.Result = RetagDone
+	return r
+}
+
+// ReadImageList reads the images of an images file: one repository per
+// line, without a tag. Blank lines and lines starting with # are skipped.
+func ReadImageList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var images []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		image := strings.TrimSpace(scanner.Text())
+		if image == "" || strings.HasPrefix(image, "#") {
+			continue
+		}
+		if err := checkUntagged(image); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		images = append(images, image)
+	}
+	return images, scanner.Err()
+}
+
+// checkUntagged reports an image that is not a repository without a tag or
+// digest, which --from and --to supply.
+func checkUntagged(image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("invalid image %s: %w", image, err)
+	}
+	if _, ok := named.(reference.Tagged); ok {
+		return fmt.Errorf("%s has a tag; list repositories only, --from and --to give the tags", image)
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return fmt.Errorf("%s has a digest; list repositories only, --from and --to give the tags", image)
+	}
+	return nil
+}
+
+// CheckRetagImages validates images given on the command line as
+// ReadImageList validates those of a file.
+func CheckRetagImages(images []string) error {
+	for _, image := range images {
+		if err := checkUntagged(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintRetagResults prints a table of the results of a retag.
+func PrintRetagResults(results []RetagResult, from, to string) {
+	from, to = strings.TrimPrefix(from, ":"), strings.TrimPrefix(to, ":")
+	data := pterm.TableData{{"IMAGE", "FROM", "TO", "DIGEST", "RESULT"}}
+	for _, r := range results {
+		result := r.Result
+		switch r.Result {
+		case RetagDone, RetagPlanned:
+			if r.Previous != "" {
+				result += " (moved from " + shortDigest(r.Previous) + ")"
+			}
+			result = pterm.Green(result)
+		case RetagSkipped, RetagFailed:
+			result = pterm.Red(result + ": " + r.Error)
+		}
+		digest := shortDigest(r.Digest)
+		if digest == "" {
+			digest = "-"
+		}
+		data = append(data, []string{r.Image, from, to, digest, result})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// pushManifest stores a manifest with the given body in store and returns
+// its descriptor.
+func pushManifest(t *testing.T, store *memory.Store, body string) ocispec.Descriptor {
+	t.Helper()
+	data := []byte(body)
+	desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := store.Push(context.Background(), desc, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+func TestRetagImages(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]*memory.Store{}
+	for _, repo := range []string{"acme/api", "acme/web", "acme/worker", "acme/db"} {
+		stores[repo] = memory.New()
+	}
+	rc := pushManifest(t, stores["acme/api"], `{"api":"rc"}`)
+	_ = stores["acme/api"].Tag(ctx, rc, "rc-42")
+	web := pushManifest(t, stores["acme/web"], `{"web":"rc"}`)
+	_ = stores["acme/web"].Tag(ctx, web, "rc-42")
+	_ = stores["acme/web"].Tag(ctx, web, "1.8.0")
+	worker := pushManifest(t, stores["acme/worker"], `{"worker":"rc"}`)
+	old := pushManifest(t, stores["acme/worker"], `{"worker":"old"}`)
+	_ = stores["acme/worker"].Tag(ctx, worker, "rc-42")
+	_ = stores["acme/worker"].Tag(ctx, old, "1.8.0")
+
+	defer func(orig func(remoteImage) (tagStore, error)) { retagStore = orig }(retagStore)
+	retagStore = func(img remoteImage) (tagStore, error) { return stores[img.Repository], nil }
+
+	images := []string{"ghcr.io/acme/api", "ghcr.io/acme/web", "ghcr.io/acme/worker", "ghcr.io/acme/db"}
+	results, err := RetagImages(images, ":rc-42", ":1.8.0", RetagOptions{Timeout: time.Minute})
+	if err == nil || err.Error() != "2 of 4 images not retagged" {
+		t.Errorf("RetagImages() error = %v, want 2 of 4 images not retagged", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Result)
+	}
+	if want := []string{RetagDone, RetagUnchanged, RetagSkipped, RetagFailed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results = %q, want %q", got, want)
+	}
+	if desc, err := stores["acme/api"].Resolve(ctx, "1.8.0"); err != nil || desc.Digest != rc.Digest {
+		t.Errorf("api:1.8.0 = %v, %v, want %s", desc.Digest, err, rc.Digest)
+	}
+	if desc, _ := stores["acme/worker"].Resolve(ctx, "1.8.0"); desc.Digest != old.Digest {
+		t.Errorf("worker:1.8.0 moved to %s without --force", desc.Digest)
+	}
+
+	// A dry run plans the move; --force then makes it.
+	results, err = RetagImages(images[2:3], "rc-42", "1.8.0", RetagOptions{DryRun: true, Force: true, Timeout: time.Minute})
+	if err != nil || results[0].Result != RetagPlanned || results[0].Previous != old.Digest.String() {
+		t.Errorf("dry run = %+v, %v, want a planned move from %s", results, err, old.Digest)
+	}
+	if desc, _ := stores["acme/worker"].Resolve(ctx, "1.8.0"); desc.Digest != old.Digest {
+		t.Errorf("dry run moved worker:1.8.0 to %s", desc.Digest)
+	}
+	if _, err := RetagImages(images[2:3], "rc-42", "1.8.0", RetagOptions{Force: true, Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if desc, _ := stores["acme/worker"].Resolve(ctx, "1.8.0"); desc.Digest != worker.Digest {
+		t.Errorf("worker:1.8.0 = %s after --force, want %s", desc.Digest, worker.Digest)
+	}
+}
+
+func TestReadImageList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte("# release train\nghcr.io/acme/api\n\n  ghcr.io/acme/web  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	images, err := ReadImageList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ghcr.io/acme/api", "ghcr.io/acme/web"}; !reflect.DeepEqual(images, want) {
+		t.Errorf("ReadImageList() = %q, want %q", images, want)
+	}
+
+	if err := os.WriteFile(path, []byte("ghcr.io/acme/api\nghcr.io/acme/web:1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadImageList(path); err == nil {
+		t.Error("ReadImageList() accepted a tagged image")
+	}
+}
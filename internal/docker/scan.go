@@ -3,13 +3,53 @@ package docker
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 )
 
+// scanSeverities are the vulnerability severities a scan can be gated on,
+// lowest first. Trivy and ECR both use these names.
+var scanSeverities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// trivyFindingsExitCode is the exit code trivy is asked to use when it finds
+// vulnerabilities at the --fail-on threshold, distinct from the 1 of its own
+// errors.
+const trivyFindingsExitCode = 4
+
+// ValidScanSeverity reports a --fail-on severity that is not one of LOW,
+// MEDIUM, HIGH or CRITICAL. An empty severity turns the gate off.
+func ValidScanSeverity(severity string) error {
+	if severity == "" || severityRank(severity) >= 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid severity %q: must be one of %s", severity, strings.Join(scanSeverities, ", "))
+}
+
+// severityRank is the position of severity in scanSeverities, or -1 for
+// severities no gate fails on (INFORMATIONAL, UNKNOWN, UNDEFINED).
+func severityRank(severity string) int {
+	for i, s := range scanSeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// severitiesFrom returns threshold and the severities above it.
+func severitiesFrom(threshold string) []string {
+	if i := severityRank(threshold); i >= 0 {
+		return scanSeverities[i:]
+	}
+	return nil
+}
+
 // Trivy runs 'trivy image' to scan a Docker image for vulnerabilities
 // and displays the results. It's a simplified version that accepts just the image name and tag.
 //
@@ -17,7 +57,15 @@ import (
 // pterm-wrapped human output; "json" asks trivy itself for JSON (via its own
 // --format flag) and prints that document, and nothing else, to stdout, so
 // pipelines consuming stdout only ever see it.
-func Trivy(dockerImage, format string, useAI bool) error {
+//
+// Successful reports are cached by the image's content digest (see
+// ScanCacheOptions), so scanning an unchanged image again within the TTL
+// replays the cached report instead of re-running trivy. Images that are not
+// in the local daemon are always scanned.
+//
+// With failOn set to a severity, only vulnerabilities of that severity or
+// higher are reported and the scan fails when there is any.
+func Trivy(dockerImage, format, failOn string, cache ScanCacheOptions, useAI bool) error {
 	isTable := format == "" || format == "table"
 
 	ctx := context.Background()
@@ -25,7 +73,40 @@ func Trivy(dockerImage, format string, useAI bool) error {
 	if !isTable {
 		trivyFormat = "json"
 	}
+	// A gated report only holds the gated severities, so it is cached apart.
+	cacheFormat := trivyFormat
+	if failOn != "" {
+		cacheFormat += "-" + strings.ToLower(failOn)
+	}
+
+	var cacheDir, digest string
+	if !cache.Disabled {
+		if dir, err := cache.dir(); err == nil {
+			if id, err := localImageID(dockerImage); err == nil {
+				cacheDir, digest = dir, id
+			}
+		}
+	}
+	if digest != "" {
+		if entry, ok := loadScanCache(cacheDir, digest, cacheFormat, cache.TTL); ok {
+			if !isTable {
+				fmt.Println(entry.Output)
+				return nil
+			}
+			pterm.Info.Printfln("Using cached scan results for %s (digest %s, scanned %s ago). Pass --no-cache-scan to rescan.",
+				dockerImage, digest, time.Since(entry.ScannedAt).Round(time.Minute))
+			if entry.Output != "" {
+				pterm.Info.Println("Trivy scan results : ", entry.Output)
+			}
+			return nil
+		}
+	}
+
 	args := []string{"image", dockerImage, "--format", trivyFormat}
+	if failOn != "" {
+		args = append(args, "--severity", strings.Join(severitiesFrom(failOn), ","),
+			"--exit-code", fmt.Sprint(trivyFindingsExitCode))
+	}
 
 	cmd := exec.CommandContext(ctx, "trivy", args...)
 	var stdoutBuf, stderrBuf bytes.Buffer
@@ -41,6 +122,16 @@ func Trivy(dockerImage, format string, useAI bool) error {
 	outStr := stdoutBuf.String()
 	errStr := stderrBuf.String()
 
+	var exitErr *exec.ExitError
+	if failOn != "" && errors.As(err, &exitErr) && exitErr.ExitCode() == trivyFindingsExitCode {
+		if !isTable {
+			fmt.Println(outStr)
+		} else {
+			pterm.Info.Println("Trivy scan results : ", outStr)
+			pterm.Error.Printfln("%s has vulnerabilities of severity %s or higher", dockerImage, strings.ToUpper(failOn))
+		}
+		return fmt.Errorf("%s has vulnerabilities of severity %s or higher", dockerImage, strings.ToUpper(failOn))
+	}
 	if err != nil {
 		if isTable {
 			pterm.Error.Println("Error running 'trivy image':", err)
@@ -53,6 +144,19 @@ func Trivy(dockerImage, format string, useAI bool) error {
 		return fmt.Errorf("failed to run 'trivy image : %v", err)
 	}
 
+	if digest != "" {
+		entry := scanCacheEntry{
+			Image:     dockerImage,
+			Digest:    digest,
+			Format:    cacheFormat,
+			ScannedAt: time.Now(),
+			Output:    outStr,
+		}
+		if err := saveScanCache(cacheDir, entry); err != nil && isTable {
+			pterm.Warning.Printfln("Could not cache scan results: %v", err)
+		}
+	}
+
 	if !isTable {
 		fmt.Println(outStr)
 		return nil
@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/docker/docker/client"
+)
+
+// ScanCacheOptions controls reuse of earlier scan results for an image
+// whose content hasn't changed.
+type ScanCacheOptions struct {
+	Disabled bool
+	TTL      time.Duration
+	Dir      string // defaults to <smurf home>/scan-cache
+}
+
+// scanCacheEntry is the on-disk form of one cached scan report.
+type scanCacheEntry struct {
+	Image     string    `json:"image"`
+	Digest    string    `json:"digest"`
+	Format    string    `json:"format"`
+	ScannedAt time.Time `json:"scannedAt"`
+	Output    string    `json:"output"`
+}
+
+// DefaultScanCacheTTL is how long a cached scan report is reused. Scanner
+// vulnerability databases update daily, so older reports may miss new CVEs.
+const DefaultScanCacheTTL = 24 * time.Hour
+
+func (o ScanCacheOptions) dir() (string, error) {
+	if o.Dir != "" {
+		return o.Dir, nil
+	}
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "scan-cache"), nil
+}
+
+// scanCachePath returns the cache file for an image digest and report format.
+func scanCachePath(dir, digest, format string) string {
+	name := strings.ReplaceAll(digest, ":", "-") + "." + format + ".json"
+	return filepath.Join(dir, name)
+}
+
+// loadScanCache returns the cached report for digest/format if there is one
+// younger than ttl.
+func loadScanCache(dir, digest, format string, ttl time.Duration) (*scanCacheEntry, bool) {
+	data, err := os.ReadFile(scanCachePath(dir, digest, format))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry scanCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Digest != digest || time.Since(entry.ScannedAt) > ttl {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// saveScanCache stores a scan report for digest/format.
+func saveScanCache(dir string, entry scanCacheEntry) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create scan cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan cache entry: %w", err)
+	}
+
+	if err := os.WriteFile(scanCachePath(dir, entry.Digest, entry.Format), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write scan cache entry: %w", err)
+	}
+	return nil
+}
+
+// localImageID returns the content-addressed ID ("sha256:...") of an image
+// in the local Docker daemon. It changes whenever the image content does,
+// which makes it the cache key for scan results.
+func localImageID(imageRef string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	inspect, err := cli.ImageInspect(ctx, imageRef)
+	if err != nil {
+		return "", err
+	}
+	return inspect.ID, nil
+}
@@ -0,0 +1,504 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/hashicorp/go-version"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// API endpoints of the registries that have no catalog of their own;
+// variables so tests can point them at a fake.
+var (
+	dockerHubAPI = "https://hub.docker.com"
+	githubAPI    = "https://api.github.com"
+)
+
+// SearchOptions configures SearchRegistries.
+type SearchOptions struct {
+	// Registries are the registries to search, each a host with an
+	// optional namespace, e.g. ghcr.io/my-org or docker.io/library.
+	Registries []string
+	// Limit is how many matching repositories of each registry are
+	// looked up and returned (0 for all).
+	Limit   int
+	Timeout time.Duration
+}
+
+// SearchResult is a repository that matched a search, with its most
+// recently pushed tag. Size and Updated are zero when the registry does
+// not report them.
+type SearchResult struct {
+	Registry   string    `json:"registry"`
+	Repository string    `json:"repository"`
+	Image      string    `json:"image"`
+	LatestTag  string    `json:"latestTag,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// SearchRegistries searches the repositories of every registry in opts for
+// query, a case-insensitive substring of the repository path. ECR, Docker
+// Hub and GHCR are searched through their own APIs; any other registry
+// through the OCI catalog API, which ACR, Harbor and most self-hosted
+// registries serve. A registry that can't be searched is reported and
+// skipped; it is an error only when none could be.
+func SearchRegistries(query string, opts SearchOptions, useAI bool) ([]SearchResult, error) {
+	if len(opts.Registries) == 0 {
+		return nil, errors.New("no registry to search: pass --registry or set sdkr.searchRegistries in smurf.yaml")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	var results []SearchResult
+	var firstErr error
+	failed := 0
+	for _, registry := range opts.Registries {
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Searching %s for %q...", registry, query))
+		found, err := searchRegistry(ctx, registry, query, opts.Limit)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to search %s: %v", registry, err))
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		spinner.Success(fmt.Sprintf("%s: %d matching", registry, len(found)))
+		results = append(results, found...)
+	}
+	if failed == len(opts.Registries) {
+		ai.AIExplainError(useAI, firstErr.Error())
+		return nil, fmt.Errorf("search failed: %w", firstErr)
+	}
+	return results, nil
+}
+
+// searchRegistry searches one registry, given as HOST[/NAMESPACE].
+func searchRegistry(ctx context.Context, registry, query string, limit int) ([]SearchResult, error) {
+	host, namespace, _ := strings.Cut(strings.TrimSuffix(registry, "/"), "/")
+	var (
+		found []SearchResult
+		err   error
+	)
+	switch {
+	case ecrHostPattern.MatchString(host):
+		found, err = searchECR(ctx, host, namespace, query, limit)
+	case host == "docker.io" || host == "index.docker.io" || host == dockerHubRegistry || host == "hub.docker.com":
+		host = "docker.io"
+		found, err = searchDockerHub(ctx, namespace, query, limit)
+	case host == "ghcr.io":
+		found, err = searchGHCR(ctx, namespace, query, limit)
+	default:
+		found, err = searchCatalog(ctx, host, namespace, query, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range found {
+		found[i].Registry = host
+		found[i].Image = host + "/" + found[i].Repository
+	}
+	return found, nil
+}
+
+// matchRepositories returns the repositories under namespace whose path
+// contains query, sorted, and at most limit of them (0 for all).
+func matchRepositories(repos []string, namespace, query string, limit int) []string {
+	query = strings.ToLower(query)
+	var matched []string
+	for _, repo := range repos {
+		if namespace != "" && !strings.HasPrefix(repo, namespace+"/") {
+			continue
+		}
+		if strings.Contains(strings.ToLower(repo), query) {
+			matched = append(matched, repo)
+		}
+	}
+	sort.Strings(matched)
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+// searchECR searches the repositories of an ECR registry and reads the
+// most recently pushed tagged image of each match.
+func searchECR(ctx context.Context, host, namespace, query string, limit int) ([]SearchResult, error) {
+	m := ecrHostPattern.FindStringSubmatch(host)
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(m[2])})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	client := ecr.New(sess)
+
+	var repos []string
+	err = client.DescribeRepositoriesPagesWithContext(ctx, &ecr.DescribeRepositoriesInput{RegistryId: aws.String(m[1])},
+		func(page *ecr.DescribeRepositoriesOutput, _ bool) bool {
+			for _, r := range page.Repositories {
+				repos = append(repos, aws.StringValue(r.RepositoryName))
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ECR repositories: %w", err)
+	}
+
+	var results []SearchResult
+	for _, repo := range matchRepositories(repos, namespace, query, limit) {
+		result := SearchResult{Repository: repo}
+		err := client.DescribeImagesPagesWithContext(ctx, &ecr.DescribeImagesInput{
+			RegistryId:     aws.String(m[1]),
+			RepositoryName: aws.String(repo),
+			Filter:         &ecr.DescribeImagesFilter{TagStatus: aws.String(ecr.TagStatusTagged)},
+		}, func(page *ecr.DescribeImagesOutput, _ bool) bool {
+			for _, img := range page.ImageDetails {
+				pushed := aws.TimeValue(img.ImagePushedAt)
+				if len(img.ImageTags) == 0 || !pushed.After(result.Updated) {
+					continue
+				}
+				result.LatestTag = aws.StringValue(img.ImageTags[0])
+				result.Size = aws.Int64Value(img.ImageSizeInBytes)
+				result.Updated = pushed
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the images of %s: %w", repo, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// searchDockerHub searches the repositories of a Docker Hub namespace,
+// logged in with DOCKER_USERNAME / DOCKER_PASSWORD when they are set so
+// private repositories are found too.
+func searchDockerHub(ctx context.Context, namespace, query string, limit int) ([]SearchResult, error) {
+	if namespace == "" {
+		return nil, errors.New("Docker Hub is searched per namespace: use docker.io/NAMESPACE, e.g. docker.io/library")
+	}
+	token, err := dockerHubToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []string
+	next := fmt.Sprintf("%s/v2/repositories/%s/?page_size=100", dockerHubAPI, url.PathEscape(namespace))
+	for next != "" {
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				Name string `json:"name"`
+			} `json:"results"`
+		}
+		if err := getAPI(ctx, next, "JWT "+token, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			repos = append(repos, namespace+"/"+r.Name)
+		}
+		next = page.Next
+	}
+
+	var results []SearchResult
+	for _, repo := range matchRepositories(repos, namespace, query, limit) {
+		var tags struct {
+			Results []struct {
+				Name        string    `json:"name"`
+				FullSize    int64     `json:"full_size"`
+				LastUpdated time.Time `json:"last_updated"`
+			} `json:"results"`
+		}
+		tagsURL := fmt.Sprintf("%s/v2/repositories/%s/tags/?page_size=1&ordering=last_updated", dockerHubAPI, repo)
+		if err := getAPI(ctx, tagsURL, "JWT "+token, &tags); err != nil {
+			return nil, err
+		}
+		result := SearchResult{Repository: repo}
+		if len(tags.Results) > 0 {
+			result.LatestTag = tags.Results[0].Name
+			result.Size = tags.Results[0].FullSize
+			result.Updated = tags.Results[0].LastUpdated
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// dockerHubToken logs in to the Docker Hub API with DOCKER_USERNAME /
+// DOCKER_PASSWORD, or returns "" to search anonymously.
+func dockerHubToken(ctx context.Context) (string, error) {
+	username, password := os.Getenv("DOCKER_USERNAME"), os.Getenv("DOCKER_PASSWORD")
+	if username == "" || password == "" {
+		return "", nil
+	}
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dockerHubAPI+"/v2/users/login", strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to Docker Hub: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to log in to Docker Hub as %s: %s", username, resp.Status)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", fmt.Errorf("invalid Docker Hub login response: %w", err)
+	}
+	return login.Token, nil
+}
+
+// searchGHCR searches the container packages of a GitHub organization or
+// user through the GitHub API, which needs GITHUB_TOKEN with read:packages.
+// GHCR does not report image sizes.
+func searchGHCR(ctx context.Context, owner, query string, limit int) ([]SearchResult, error) {
+	if owner == "" {
+		return nil, errors.New("GHCR is searched per owner: use ghcr.io/ORG or ghcr.io/USER")
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return nil, errors.New("searching GHCR needs GITHUB_TOKEN with the read:packages scope")
+	}
+
+	packagesURL := func(kind string) string {
+		return fmt.Sprintf("%s/%s/%s/packages?package_type=container&per_page=100", githubAPI, kind, url.PathEscape(owner))
+	}
+	kind := "orgs"
+	var repos []string
+	for page := 1; ; page++ {
+		var packages []struct {
+			Name string `json:"name"`
+		}
+		err := getAPI(ctx, fmt.Sprintf("%s&page=%d", packagesURL(kind), page), "Bearer "+token, &packages)
+		if errors.Is(err, errAPINotFound) && kind == "orgs" && page == 1 {
+			kind = "users"
+			page = 0
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packages {
+			repos = append(repos, owner+"/"+p.Name)
+		}
+		if len(packages) < 100 {
+			break
+		}
+	}
+
+	var results []SearchResult
+	for _, repo := range matchRepositories(repos, owner, query, limit) {
+		name := strings.TrimPrefix(repo, owner+"/")
+		var versions []struct {
+			UpdatedAt time.Time `json:"updated_at"`
+			Metadata  struct {
+				Container struct {
+					Tags []string `json:"tags"`
+				} `json:"container"`
+			} `json:"metadata"`
+		}
+		versionsURL := fmt.Sprintf("%s/%s/%s/packages/container/%s/versions?per_page=20", githubAPI, kind, url.PathEscape(owner), url.PathEscape(name))
+		if err := getAPI(ctx, versionsURL, "Bearer "+token, &versions); err != nil {
+			return nil, err
+		}
+		result := SearchResult{Repository: repo}
+		// Versions come newest first; untagged ones are build cache or
+		// the per-platform manifests of an index.
+		for _, v := range versions {
+			if len(v.Metadata.Container.Tags) > 0 {
+				result.LatestTag = v.Metadata.Container.Tags[0]
+				result.Updated = v.UpdatedAt
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+var errAPINotFound = errors.New("not found")
+
+// getAPI GETs a JSON API endpoint into v, with authorization when it is
+// not just a scheme.
+func getAPI(ctx context.Context, endpoint, authorization string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if _, credential, _ := strings.Cut(authorization, " "); credential != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", endpoint, errAPINotFound)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// searchCatalog searches a registry through the OCI catalog API, with the
+// credentials smurf pushes to it with. The latest tag of a match is latest
+// when it exists, else its highest version; its size and creation time are
+// read from its manifest and config (linux/amd64 of a multi-platform image).
+func searchCatalog(ctx context.Context, host, namespace, query string, limit int) ([]SearchResult, error) {
+	reg, err := remote.NewRegistry(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry %s: %w", host, err)
+	}
+	reg.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: registryCredential,
+	}
+	var repos []string
+	err = reg.Repositories(ctx, "", func(page []string) error {
+		repos = append(repos, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the catalog of %s (the registry may not serve it): %w", host, err)
+	}
+
+	var results []SearchResult
+	for _, repoName := range matchRepositories(repos, namespace, query, limit) {
+		result := SearchResult{Repository: repoName}
+		repo, err := remoteRepository(remoteImage{Host: host, Repository: repoName})
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		err = repo.Tags(ctx, "", func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the tags of %s: %w", repoName, err)
+		}
+		if result.LatestTag = latestTag(tags); result.LatestTag != "" {
+			result.Size, result.Updated = imageSizeAndCreated(ctx, repo, result.LatestTag)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// latestTag picks the tag a catalog search reports: latest when it exists,
+// else the highest version, else the last tag in lexical order.
+func latestTag(tags []string) string {
+	var best *version.Version
+	bestTag := ""
+	for _, tag := range tags {
+		if tag == "latest" {
+			return tag
+		}
+		v, err := version.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, bestTag = v, tag
+		}
+	}
+	if bestTag != "" || len(tags) == 0 {
+		return bestTag
+	}
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	return sorted[len(sorted)-1]
+}
+
+// imageSizeAndCreated returns the compressed size and creation time of the
+// image tag points to, or zeros when they can't be read.
+func imageSizeAndCreated(ctx context.Context, repo *remote.Repository, tag string) (int64, time.Time) {
+	desc, data, err := fetchManifest(ctx, repo, tag)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	if isIndex(desc.MediaType) {
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return 0, time.Time{}
+		}
+		chosen, err := selectPlatform(index, "linux/amd64")
+		if err != nil {
+			if len(index.Manifests) == 0 {
+				return 0, time.Time{}
+			}
+			chosen = index.Manifests[0]
+		}
+		if data, err = content.FetchAll(ctx, repo, chosen); err != nil {
+			return 0, time.Time{}
+		}
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, time.Time{}
+	}
+	size := manifest.Config.Size
+	for _, l := range manifest.Layers {
+		size += l.Size
+	}
+	var config ocispec.Image
+	if configData, err := content.FetchAll(ctx, repo, manifest.Config); err == nil && json.Unmarshal(configData, &config) == nil && config.Created != nil {
+		return size, *config.Created
+	}
+	return size, time.Time{}
+}
+
+// PrintSearchResults prints the results of a search as a table.
+func PrintSearchResults(results []SearchResult) {
+	if len(results) == 0 {
+		pterm.Info.Println("No matching repositories found")
+		return
+	}
+	tableData := pterm.TableData{{"IMAGE", "LATEST TAG", "SIZE", "UPDATED"}}
+	for _, r := range results {
+		tag, size, updated := r.LatestTag, "-", "-"
+		if tag == "" {
+			tag = "-"
+		}
+		if r.Size > 0 {
+			size = formatSize(r.Size)
+		}
+		if !r.Updated.IsZero() {
+			updated = r.Updated.Local().Format("2006-01-02 15:04")
+		}
+		tableData = append(tableData, []string{r.Image, tag, size, updated})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
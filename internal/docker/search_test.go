@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchRepositories(t *testing.T) {
+	repos := []string{"org/payments-api", "org/web", "other/payments", "org/Payments-worker"}
+	got := matchRepositories(repos, "org", "payments", 0)
+	if want := []string{"org/Payments-worker", "org/payments-api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matchRepositories = %v, want %v", got, want)
+	}
+	if got := matchRepositories(repos, "", "payments", 2); len(got) != 2 {
+		t.Errorf("matchRepositories with limit 2 = %v", got)
+	}
+}
+
+func TestLatestTag(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"v1.2.0", "latest", "v1.10.0"}, "latest"},
+		{[]string{"v1.2.0", "v1.10.0", "main"}, "v1.10.0"},
+		{[]string{"main", "dev"}, "main"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := latestTag(tt.tags); got != tt.want {
+			t.Errorf("latestTag(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestSearchDockerHub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/repositories/acme/" && r.URL.Query().Get("page") == "":
+			_, _ = w.Write([]byte(`{"next":"` + "http://" + r.Host + `/v2/repositories/acme/?page=2","results":[{"name":"api"},{"name":"web"}]}`))
+		case r.URL.Path == "/v2/repositories/acme/":
+			_, _ = w.Write([]byte(`{"next":null,"results":[{"name":"api-worker"}]}`))
+		case strings.HasPrefix(r.URL.Path, "/v2/repositories/acme/api/tags/"):
+			_, _ = w.Write([]byte(`{"results":[{"name":"v2","full_size":1500000,"last_updated":"2026-10-01T10:00:00Z"}]}`))
+		case strings.HasPrefix(r.URL.Path, "/v2/repositories/acme/api-worker/tags/"):
+			_, _ = w.Write([]byte(`{"results":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	old := dockerHubAPI
+	dockerHubAPI = srv.URL
+	defer func() { dockerHubAPI = old }()
+	t.Setenv("DOCKER_USERNAME", "")
+
+	got, err := searchRegistry(context.Background(), "docker.io/acme", "api", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Image != "docker.io/acme/api" || got[0].LatestTag != "v2" || got[0].Size != 1500000 || got[0].Updated.IsZero() {
+		t.Errorf("searchRegistry(docker.io/acme) = %+v", got)
+	}
+	if got[1].Repository != "acme/api-worker" || got[1].LatestTag != "" {
+		t.Errorf("a repository without tags = %+v", got[1])
+	}
+
+	if _, err := searchRegistry(context.Background(), "docker.io", "api", 0); err == nil {
+		t.Error("searching Docker Hub without a namespace: want an error")
+	}
+}
+
+func TestSearchGHCR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users/octo/packages":
+			_, _ = w.Write([]byte(`[{"name":"tools/payments"},{"name":"site"}]`))
+		case "/users/octo/packages/container/tools%2Fpayments/versions", "/users/octo/packages/container/tools/payments/versions":
+			_, _ = w.Write([]byte(`[{"updated_at":"2026-10-02T08:00:00Z","metadata":{"container":{"tags":[]}}},
+				{"updated_at":"2026-10-01T08:00:00Z","metadata":{"container":{"tags":["1.4.0","latest"]}}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	old := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = old }()
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+
+	got, err := searchRegistry(context.Background(), "ghcr.io/octo", "pay", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Image != "ghcr.io/octo/tools/payments" || got[0].LatestTag != "1.4.0" || got[0].Updated.Day() != 1 {
+		t.Errorf("searchRegistry(ghcr.io/octo) = %+v", got)
+	}
+}
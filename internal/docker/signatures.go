@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+)
+
+// VerifyImageSignatures checks with cosign that every image is signed by
+// one of the trusted identities of policy and, when policy names an
+// attestation type, has an attestation of that type from the same signer.
+// It checks all images before failing, so one run lists every unsigned one.
+func VerifyImageSignatures(images []string, policy configs.DeployConfig) error {
+	if len(images) == 0 {
+		return nil
+	}
+	cosign, err := exec.LookPath("cosign")
+	if err != nil {
+		return errors.New("deploy.requireSignedImages needs the cosign CLI on PATH")
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Verifying the signatures of %d image(s)...", len(images)))
+	var unsigned []string
+	for _, image := range images {
+		spinner.UpdateText(fmt.Sprintf("Verifying %s...", image))
+		if err := verifyImageSignature(cosign, image, policy); err != nil {
+			pterm.Error.Printfln("%s: %v", image, err)
+			unsigned = append(unsigned, image)
+			continue
+		}
+		pterm.Success.Printfln("Signature verified: %s", image)
+	}
+	if len(unsigned) > 0 {
+		spinner.Fail(fmt.Sprintf("%d of %d image(s) are not signed by a trusted identity", len(unsigned), len(images)))
+		return fmt.Errorf("images without a trusted signature: %s", strings.Join(unsigned, ", "))
+	}
+	spinner.Success(fmt.Sprintf("All %d image(s) are signed by a trusted identity", len(images)))
+	return nil
+}
+
+// verifyImageSignature tries the trusted identities in turn and returns the
+// failure of the last one when none of them verifies image.
+func verifyImageSignature(cosign, image string, policy configs.DeployConfig) error {
+	var err error
+	for _, id := range policy.TrustedIdentities {
+		if err = runCosign(cosign, cosignVerifyArgs("verify", "", image, id)); err != nil {
+			continue
+		}
+		if policy.AttestationType != "" {
+			if err = runCosign(cosign, cosignVerifyArgs("verify-attestation", policy.AttestationType, image, id)); err != nil {
+				err = fmt.Errorf("no %s attestation: %w", policy.AttestationType, err)
+				continue
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// cosignVerifyArgs is the cosign command line that checks image against id.
+func cosignVerifyArgs(subcommand, attestationType, image string, id configs.TrustedIdentity) []string {
+	args := []string{subcommand}
+	if attestationType != "" {
+		args = append(args, "--type", attestationType)
+	}
+	if id.Key != "" {
+		args = append(args, "--key", id.Key)
+	} else {
+		args = append(args, "--certificate-oidc-issuer", id.Issuer, "--certificate-identity-regexp", id.Subject)
+	}
+	return append(args, image)
+}
+
+// runCosign runs cosign and turns a failure into the last line cosign
+// printed, which says why the verification failed.
+func runCosign(cosign string, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(cosign, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if msg := strings.TrimSpace(lines[len(lines)-1]); msg != "" {
+			return errors.New(strings.TrimPrefix(msg, "Error: "))
+		}
+		return err
+	}
+	return nil
+}
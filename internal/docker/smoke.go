@@ -0,0 +1,198 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/pterm/pterm"
+)
+
+// SmokeTestOptions configures SmokeTest.
+type SmokeTestOptions struct {
+	Args    []string      // arguments passed to the image's entrypoint, like docker run IMAGE ARGS
+	Timeout time.Duration // how long the container may run before the test fails
+	Health  string        // PORT[/PATH] polled over HTTP while the container runs; empty to wait for exit
+}
+
+// smokeLogLines is how much of the container's output a failed smoke test
+// shows.
+const smokeLogLines = 20
+
+// SmokeTest runs image locally before it is pushed, so an image with a
+// broken entrypoint never reaches the registry. Without a health check the
+// container must exit 0 within the timeout. With one, it is a server: it
+// must keep running and answer GET PORT/PATH with a 2xx status within the
+// timeout. The container is removed either way, and the tail of its output
+// is part of the error when the test fails.
+func SmokeTest(image string, opts SmokeTestOptions, useAI bool) error {
+	port, path, err := parseHealthCheck(opts.Health)
+	if err != nil {
+		return err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to initialize Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Smoke testing %s...", image))
+	err = smokeTest(cli, image, opts, port, path)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Smoke test of %s failed", image))
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	spinner.Success(fmt.Sprintf("Smoke test of %s passed", image))
+	return nil
+}
+
+func smokeTest(cli *client.Client, image string, opts SmokeTestOptions, port nat.Port, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	config := &container.Config{Image: image, Cmd: opts.Args}
+	hostConfig := &container.HostConfig{}
+	if port != "" {
+		config.ExposedPorts = nat.PortSet{port: struct{}{}}
+		hostConfig.PortBindings = nat.PortMap{port: {{HostIP: "127.0.0.1"}}}
+	}
+	created, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create the smoke test container: %w", err)
+	}
+	defer func() {
+		// ctx may have expired; removal must still happen.
+		_ = cli.ContainerRemove(context.Background(), created.ID, container.RemoveOptions{Force: true})
+	}()
+
+	waitCh, waitErrCh := cli.ContainerWait(ctx, created.ID, container.WaitConditionNextExit)
+	if err := cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start the smoke test container: %w", err)
+	}
+
+	var healthCh <-chan error
+	if port != "" {
+		inspect, err := cli.ContainerInspect(ctx, created.ID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect the smoke test container: %w", err)
+		}
+		bindings := inspect.NetworkSettings.Ports[port]
+		if len(bindings) == 0 {
+			return fmt.Errorf("port %s of the smoke test container was not published", port)
+		}
+		url := fmt.Sprintf("http://127.0.0.1:%s%s", bindings[0].HostPort, path)
+		healthCh = pollHealth(ctx, url)
+	}
+
+	select {
+	case res := <-waitCh:
+		if res.Error != nil {
+			return fmt.Errorf("smoke test container failed: %s", res.Error.Message)
+		}
+		if res.StatusCode != 0 {
+			return smokeFailure(cli, created.ID, fmt.Sprintf("container exited with code %d", res.StatusCode))
+		}
+		if port != "" {
+			return smokeFailure(cli, created.ID, fmt.Sprintf("container exited before port %s became healthy", port))
+		}
+		return nil
+	case err := <-waitErrCh:
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return smokeFailure(cli, created.ID, fmt.Sprintf("container still running after %v", opts.Timeout))
+		}
+		return fmt.Errorf("failed to wait for the smoke test container: %w", err)
+	case err := <-healthCh:
+		if err != nil {
+			return smokeFailure(cli, created.ID, fmt.Sprintf("health check failed within %v: %v", opts.Timeout, err))
+		}
+		return nil
+	}
+}
+
+// pollHealth GETs url every second until it answers with a 2xx status or
+// ctx ends, and sends nil or the last error on the returned channel.
+func pollHealth(ctx context.Context, url string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		var last error = errors.New("no response")
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+					done <- nil
+					return
+				}
+				last = fmt.Errorf("GET %s returned %s", url, resp.Status)
+			} else if ctx.Err() == nil {
+				last = err
+			}
+			select {
+			case <-ctx.Done():
+				done <- last
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// smokeFailure returns an error with reason and the last lines the
+// container wrote.
+func smokeFailure(cli *client.Client, id, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logs, err := cli.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(smokeLogLines),
+	})
+	if err != nil {
+		return fmt.Errorf("smoke test failed: %s", reason)
+	}
+	defer logs.Close()
+	var out bytes.Buffer
+	_, _ = stdcopy.StdCopy(&out, &out, logs)
+	return smokeError(reason, out.String())
+}
+
+func smokeError(reason, output string) error {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return fmt.Errorf("smoke test failed: %s (no output)", reason)
+	}
+	return fmt.Errorf("smoke test failed: %s; last output:\n%s", reason, output)
+}
+
+// parseHealthCheck parses a --smoke-test-health value, PORT or PORT/PATH,
+// into the container port and the request path.
+func parseHealthCheck(health string) (nat.Port, string, error) {
+	if health == "" {
+		return "", "", nil
+	}
+	portText, path, _ := strings.Cut(health, "/")
+	port, err := strconv.Atoi(portText)
+	if err != nil || port < 1 || port > 65535 {
+		return "", "", fmt.Errorf("invalid health check %q: must be PORT or PORT/PATH, like 8080/healthz", health)
+	}
+	return nat.Port(strconv.Itoa(port) + "/tcp"), "/" + path, nil
+}
@@ -8,12 +8,18 @@ type BuildOptions struct {
 	DockerfilePath string
 	BuildArgs      map[string]string
 	Target         string
-	Platform       string
+	Platform       string // os/arch, or a comma-separated list of them for a multi-platform build with buildx
 	NoCache        bool
 	BuildKit       bool
 	Timeout        time.Duration
 	Excludes       []string
 	Labels         map[string]string
+	RemoteBuild    string             // RemoteBuildK8s builds on a buildkitd in the cluster
+	Remote         RemoteBuildOptions // the cluster builder, with RemoteBuild
+	Reproducible   bool               // build from SOURCE_DATE_EPOCH with normalized timestamps, and print the digest
+	Push           bool               // push from buildx as part of the build; required to publish a multi-platform image
+	CacheFrom      []string           // buildx --cache-from sources, e.g. type=registry,ref=REPO:buildcache
+	CacheTo        []string           // buildx --cache-to destinations, e.g. type=registry,ref=REPO:buildcache,mode=max
 }
 
 // ImageInfo struct to hold information about a Docker image
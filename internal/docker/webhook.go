@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the webhook's secret, as "sha256=<hex>".
+const WebhookSignatureHeader = "X-Smurf-Signature-256"
+
+// webhookAttempts and webhookBackoff bound the retries of a webhook call
+// that fails with a network error or a 5xx response.
+var (
+	webhookAttempts = 3
+	webhookBackoff  = 2 * time.Second
+)
+
+// PushEvent is the payload sent to webhooks after an image push.
+type PushEvent struct {
+	Event      string    `json:"event"`
+	Image      string    `json:"image"`
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	Digest     string    `json:"digest,omitempty"`
+	PushedAt   time.Time `json:"pushedAt"`
+}
+
+// NewPushEvent describes the push of image, whose registry digest is digest.
+func NewPushEvent(image, digest string) PushEvent {
+	repo := imageRepository(image)
+	tag := strings.TrimPrefix(strings.TrimPrefix(image, repo), ":")
+	if tag == "" || strings.HasPrefix(tag, "@") {
+		tag = "latest"
+	}
+	return PushEvent{
+		Event:      "push",
+		Image:      image,
+		Repository: repo,
+		Tag:        tag,
+		Digest:     digest,
+		PushedAt:   time.Now().UTC(),
+	}
+}
+
+// NotifyWebhooks delivers event to every webhook. A failed call is reported
+// as a warning, since the push itself succeeded; only webhooks marked
+// failOnError turn their failure into an error.
+func NotifyWebhooks(hooks []configs.WebhookConfig, event PushEvent) error {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	var errs []error
+	for _, hook := range hooks {
+		name := webhookName(hook)
+		body, err := webhookPayload(hook, event)
+		if err == nil {
+			err = sendWebhook(client, hook, body)
+		}
+		if err != nil {
+			pterm.Warning.Printfln("Webhook %s failed: %v", name, err)
+			if hook.FailOnError {
+				errs = append(errs, fmt.Errorf("webhook %s: %w", name, err))
+			}
+			continue
+		}
+		pterm.Success.Printfln("Webhook %s notified of %s", name, event.Image)
+	}
+	return errors.Join(errs...)
+}
+
+// webhookName identifies a webhook in output without leaking credentials
+// that may be part of its URL.
+func webhookName(hook configs.WebhookConfig) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	u, err := url.Parse(hook.URL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Host + u.Path
+}
+
+// webhookPayload renders event in the webhook's format: smurf's own
+// PushEvent, or Docker Hub's push webhook body, which receivers such as Argo
+// CD Image Updater already understand.
+func webhookPayload(hook configs.WebhookConfig, event PushEvent) ([]byte, error) {
+	switch hook.Format {
+	case "", "smurf":
+		return json.Marshal(event)
+	case "dockerhub":
+		repoName := event.Repository
+		if img, err := parseRemoteImage(event.Image); err == nil && img.Host == dockerHubRegistry {
+			repoName = strings.TrimPrefix(img.Repository, "library/")
+		}
+		namespace, name := "", repoName
+		if i := strings.LastIndex(repoName, "/"); i >= 0 {
+			namespace, name = repoName[:i], repoName[i+1:]
+		}
+		return json.Marshal(map[string]interface{}{
+			"push_data": map[string]interface{}{
+				"pushed_at": event.PushedAt.Unix(),
+				"pusher":    "smurf",
+				"tag":       event.Tag,
+			},
+			"repository": map[string]interface{}{
+				"repo_name": repoName,
+				"name":      name,
+				"namespace": namespace,
+			},
+		})
+	}
+	return nil, fmt.Errorf("unsupported format %q (use smurf or dockerhub)", hook.Format)
+}
+
+// signWebhookPayload returns the signature header value for body.
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(client *http.Client, hook configs.WebhookConfig, body []byte) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL: must be an http(s) URL")
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * webhookBackoff)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "smurf")
+		req.Header.Set("X-Smurf-Event", "push")
+		if hook.Secret != "" {
+			req.Header.Set(WebhookSignatureHeader, signWebhookPayload(hook.Secret, body))
+		}
+		for k, v := range hook.Headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			// The error may quote the URL, and with it any token in it.
+			lastErr = fmt.Errorf("request failed: %w", errors.Unwrap(err))
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("unexpected response %s", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
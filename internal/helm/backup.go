@@ -0,0 +1,244 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/yaml"
+)
+
+// Files of a release backup, in its own directory of the backup location.
+const (
+	backupValuesFile   = "values.yaml"
+	backupManifestFile = "manifest.yaml"
+	backupReleaseFile  = "release.json"
+)
+
+// backupObject is one file of a release backup.
+type backupObject struct {
+	Name        string
+	Body        []byte
+	ContentType string
+}
+
+// releaseBackupInfo is release.json of a backup: the release as it was when
+// it was backed up.
+type releaseBackupInfo struct {
+	Release      string    `json:"release"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status,omitempty"`
+	Chart        string    `json:"chart,omitempty"`
+	ChartVersion string    `json:"chartVersion,omitempty"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	TakenAt      time.Time `json:"takenAt"`
+}
+
+// backupStore uploads the objects of a backup.
+type backupStore interface {
+	put(name string, obj backupObject) error
+	// url is how a user finds the object name in the store.
+	url(name string) string
+}
+
+// openBackupStore opens the store of a backup location; the tests swap it.
+var openBackupStore = func(loc configs.BackupLocation) backupStore {
+	switch loc.Scheme {
+	case "s3":
+		return s3BackupStore{bucket: loc.Bucket}
+	case "gs":
+		return gcsBackupStore{bucket: loc.Bucket}
+	}
+	return azblobBackupStore{account: loc.Account, container: loc.Bucket}
+}
+
+// backupRelease uploads the values and manifest of rel to
+// configs.ReleaseBackup before an upgrade replaces it. A failed upload is a
+// warning unless the backup is required.
+func backupRelease(rel *release.Release) error {
+	backup := configs.ReleaseBackup
+	if backup.Location == "" || rel == nil {
+		return nil
+	}
+	dir, err := uploadReleaseBackup(backup, rel, time.Now())
+	if err != nil {
+		err = fmt.Errorf("failed to back up release %s (revision %d): %w", rel.Name, rel.Version, err)
+		if backup.Required {
+			return err
+		}
+		pterm.Warning.Printfln("%v; upgrading without a backup", err)
+		return nil
+	}
+	pterm.Success.Printfln("Backed up release %s (revision %d) to %s", rel.Name, rel.Version, dir)
+	return nil
+}
+
+// uploadReleaseBackup uploads the backup of rel to the location of backup
+// and returns the URL of its directory.
+func uploadReleaseBackup(backup configs.BackupConfig, rel *release.Release, takenAt time.Time) (string, error) {
+	loc, err := backup.Parse()
+	if err != nil {
+		return "", err
+	}
+	objects, err := backupObjects(rel, takenAt)
+	if err != nil {
+		return "", err
+	}
+	store := openBackupStore(loc)
+	dir := backupDir(loc.Prefix, rel, takenAt)
+	for _, obj := range objects {
+		if err := store.put(path.Join(dir, obj.Name), obj); err != nil {
+			return "", fmt.Errorf("%s: %w", store.url(path.Join(dir, obj.Name)), err)
+		}
+	}
+	return store.url(dir), nil
+}
+
+// backupDir is the directory of a backup of rel taken at takenAt:
+// PREFIX/NAMESPACE/RELEASE/TIMESTAMP-rREVISION, so the backups of a release
+// list in time order.
+func backupDir(prefix string, rel *release.Release, takenAt time.Time) string {
+	name := fmt.Sprintf("%s-r%d", takenAt.UTC().Format("20060102T150405Z"), rel.Version)
+	return path.Join(prefix, rel.Namespace, rel.Name, name)
+}
+
+// backupObjects are the files of a backup of rel: the user-supplied values
+// it was installed with, its manifest with the hooks, and release.json.
+func backupObjects(rel *release.Release, takenAt time.Time) ([]backupObject, error) {
+	values, err := yaml.Marshal(rel.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	manifest := rel.Manifest
+	for _, hook := range rel.Hooks {
+		manifest += fmt.Sprintf("\n---\n# Source: %s\n%s", hook.Path, hook.Manifest)
+	}
+
+	info := releaseBackupInfo{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		TakenAt:   takenAt.UTC(),
+	}
+	if rel.Info != nil {
+		info.Status = rel.Info.Status.String()
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		info.Chart = rel.Chart.Metadata.Name
+		info.ChartVersion = rel.Chart.Metadata.Version
+		info.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	meta, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []backupObject{
+		{Name: backupValuesFile, Body: values, ContentType: "application/yaml"},
+		{Name: backupManifestFile, Body: []byte(manifest), ContentType: "application/yaml"},
+		{Name: backupReleaseFile, Body: append(meta, '\n'), ContentType: "application/json"},
+	}, nil
+}
+
+// s3BackupStore uploads to an S3 bucket with the AWS credentials of the
+// environment.
+type s3BackupStore struct {
+	bucket string
+}
+
+func (s s3BackupStore) url(name string) string {
+	return "s3://" + s.bucket + "/" + name
+}
+
+func (s s3BackupStore) put(name string, obj backupObject) error {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	_, err = s3.New(sess).PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(name),
+		Body:        bytes.NewReader(obj.Body),
+		ContentType: aws.String(obj.ContentType),
+	})
+	return err
+}
+
+// gcsBackupStore uploads to a Cloud Storage bucket through the gcloud CLI
+// and its credentials.
+type gcsBackupStore struct {
+	bucket string
+}
+
+func (s gcsBackupStore) url(name string) string {
+	return "gs://" + s.bucket + "/" + name
+}
+
+func (s gcsBackupStore) put(name string, obj backupObject) error {
+	return runStorageCLI("gcloud", bytes.NewReader(obj.Body),
+		"storage", "cp", "--content-type", obj.ContentType, "-", s.url(name))
+}
+
+// azblobBackupStore uploads to an Azure Blob Storage container through the
+// az CLI, signed in with the identity of `az login`.
+type azblobBackupStore struct {
+	account, container string
+}
+
+func (s azblobBackupStore) url(name string) string {
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", s.account, s.container, name)
+}
+
+func (s azblobBackupStore) put(name string, obj backupObject) error {
+	tmp, err := os.CreateTemp("", "smurf-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(obj.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return runStorageCLI("az", nil, "storage", "blob", "upload",
+		"--auth-mode", "login", "--only-show-errors", "--overwrite",
+		"--account-name", s.account, "--container-name", s.container,
+		"--name", name, "--file", tmp.Name(), "--content-type", obj.ContentType)
+}
+
+// runStorageCLI runs a cloud CLI upload, with its error output as the error
+// when it fails.
+func runStorageCLI(name string, stdin *bytes.Reader, args ...string) error {
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("uploading the backup needs the %s CLI on PATH", name)
+	}
+	cmd := exec.Command(bin, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
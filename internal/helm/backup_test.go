@@ -0,0 +1,118 @@
+package helm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// memoryBackupStore records the objects put to it, or fails every put with
+// err.
+type memoryBackupStore struct {
+	objects map[string]backupObject
+	err     error
+}
+
+func (s *memoryBackupStore) put(name string, obj backupObject) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.objects[name] = obj
+	return nil
+}
+
+func (s *memoryBackupStore) url(name string) string {
+	return "mem://" + name
+}
+
+func backupTestRelease() *release.Release {
+	return &release.Release{
+		Name:      "web",
+		Namespace: "apps",
+		Version:   7,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.2.0", AppVersion: "2.0"}},
+		Config:    map[string]interface{}{"image": map[string]interface{}{"tag": "v2"}},
+		Manifest:  "kind: Deployment\n",
+		Hooks:     []*release.Hook{{Path: "web/templates/migrate.yaml", Manifest: "kind: Job\n"}},
+	}
+}
+
+func TestBackupObjects(t *testing.T) {
+	takenAt := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	rel := backupTestRelease()
+
+	if got := backupDir("helm", rel, takenAt); got != "helm/apps/web/20261017T093000Z-r7" {
+		t.Errorf("backupDir = %s", got)
+	}
+	if got := backupDir("", rel, takenAt); got != "apps/web/20261017T093000Z-r7" {
+		t.Errorf("backupDir without a prefix = %s", got)
+	}
+
+	objects, err := backupObjects(rel, takenAt)
+	if err != nil {
+		t.Fatalf("backupObjects: %v", err)
+	}
+	byName := map[string]string{}
+	for _, obj := range objects {
+		byName[obj.Name] = string(obj.Body)
+	}
+	if byName[backupValuesFile] != "image:\n  tag: v2\n" {
+		t.Errorf("values.yaml = %q", byName[backupValuesFile])
+	}
+	if !strings.Contains(byName[backupManifestFile], "kind: Deployment") || !strings.Contains(byName[backupManifestFile], "# Source: web/templates/migrate.yaml\nkind: Job") {
+		t.Errorf("manifest.yaml misses the manifest or hooks: %q", byName[backupManifestFile])
+	}
+	var info releaseBackupInfo
+	if err := json.Unmarshal([]byte(byName[backupReleaseFile]), &info); err != nil {
+		t.Fatalf("release.json: %v", err)
+	}
+	if info.Revision != 7 || info.Status != "deployed" || info.ChartVersion != "1.2.0" || !info.TakenAt.Equal(takenAt) {
+		t.Errorf("release.json = %+v", info)
+	}
+}
+
+func TestBackupRelease(t *testing.T) {
+	store := &memoryBackupStore{objects: map[string]backupObject{}}
+	prevOpen, prevBackup := openBackupStore, configs.ReleaseBackup
+	openBackupStore = func(loc configs.BackupLocation) backupStore {
+		if loc.Scheme != "s3" || loc.Bucket != "backups" || loc.Prefix != "helm" {
+			t.Errorf("store opened for %+v", loc)
+		}
+		return store
+	}
+	t.Cleanup(func() { openBackupStore, configs.ReleaseBackup = prevOpen, prevBackup })
+
+	configs.ReleaseBackup = configs.BackupConfig{}
+	if err := backupRelease(backupTestRelease()); err != nil || len(store.objects) != 0 {
+		t.Fatalf("no location must mean no backup: %v, %v", err, store.objects)
+	}
+
+	configs.ReleaseBackup = configs.BackupConfig{Location: "s3://backups/helm"}
+	if err := backupRelease(backupTestRelease()); err != nil {
+		t.Fatalf("backupRelease: %v", err)
+	}
+	if len(store.objects) != 3 {
+		t.Fatalf("uploaded %d objects, want 3", len(store.objects))
+	}
+	for name := range store.objects {
+		if !strings.HasPrefix(name, "helm/apps/web/") {
+			t.Errorf("object %s outside the release's directory", name)
+		}
+	}
+
+	store.err = errors.New("access denied")
+	if err := backupRelease(backupTestRelease()); err != nil {
+		t.Errorf("a failed optional backup must not fail the upgrade: %v", err)
+	}
+	configs.ReleaseBackup.Required = true
+	if err := backupRelease(backupTestRelease()); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("a failed required backup must fail the upgrade, got %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// resourceAmount is an amount of CPU, in millicores, and memory, in bytes.
+type resourceAmount struct {
+	cpu, memory int64
+}
+
+func (a *resourceAmount) add(b resourceAmount) {
+	a.cpu += b.cpu
+	a.memory += b.memory
+}
+
+func (a *resourceAmount) sub(b resourceAmount) {
+	a.cpu -= b.cpu
+	a.memory -= b.memory
+}
+
+func (a resourceAmount) fits(free resourceAmount) bool {
+	return a.cpu <= free.cpu && a.memory <= free.memory
+}
+
+func (a resourceAmount) String() string {
+	return fmt.Sprintf("%s CPU, %s memory",
+		resource.NewMilliQuantity(a.cpu, resource.DecimalSI), resource.NewQuantity(a.memory, resource.BinarySI))
+}
+
+// podDemand is what replicas pods of one workload of a manifest request.
+// perNode marks a DaemonSet, which runs one pod on every node.
+type podDemand struct {
+	workload string
+	request  resourceAmount
+	replicas int
+	perNode  bool
+}
+
+// CapacityReport is the outcome of a capacity preflight: what the rendered
+// release requests, what is free for it, and why it would not fit.
+type CapacityReport struct {
+	Requested string
+	Free      string
+	Problems  []string
+}
+
+// CheckCapacity compares the CPU and memory the workloads of manifest
+// request with the allocatable capacity left on the ready, schedulable
+// nodes and with the ResourceQuota headroom of namespace, so a deploy that
+// would leave pods Pending is caught before it starts. Pods of
+// releaseName already running are counted as free, since the release
+// replaces them.
+func CheckCapacity(releaseName, namespace, manifest string) (*CapacityReport, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return checkCapacity(context.Background(), clientset, releaseName, namespace, manifest)
+}
+
+func checkCapacity(ctx context.Context, clientset kubernetes.Interface, releaseName, namespace, manifest string) (*CapacityReport, error) {
+	demands, err := manifestPodDemands(manifest)
+	if err != nil {
+		return nil, err
+	}
+	report := &CapacityReport{}
+	if len(demands) == 0 {
+		return report, nil
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	// Free capacity of every candidate node, without the release's own pods.
+	free := map[string]*resourceAmount{}
+	for _, node := range nodes.Items {
+		if !nodeSchedulable(node) {
+			continue
+		}
+		free[node.Name] = &resourceAmount{
+			cpu:    node.Status.Allocatable.Cpu().MilliValue(),
+			memory: node.Status.Allocatable.Memory().Value(),
+		}
+	}
+	var current resourceAmount
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		request := podRequest(pod.Spec)
+		if pod.Namespace == namespace && pod.Labels["app.kubernetes.io/instance"] == releaseName {
+			current.add(request)
+			continue
+		}
+		if nodeFree, ok := free[pod.Spec.NodeName]; ok {
+			nodeFree.sub(request)
+		}
+	}
+	if len(free) == 0 {
+		report.Problems = append(report.Problems, "no ready, schedulable node to run the release on")
+		return report, nil
+	}
+
+	nodeNames := make([]string, 0, len(free))
+	var total, requested resourceAmount
+	for name, nodeFree := range free {
+		nodeNames = append(nodeNames, name)
+		total.add(resourceAmount{cpu: max(nodeFree.cpu, 0), memory: max(nodeFree.memory, 0)})
+	}
+	sort.Strings(nodeNames)
+	report.Free = total.String()
+
+	// DaemonSet pods go on every node first, then the other pods, largest
+	// first, each on the first node it fits on.
+	var pending []string
+	var replicas []podDemand
+	for _, d := range demands {
+		if !d.perNode {
+			for range d.replicas {
+				replicas = append(replicas, d)
+				requested.add(d.request)
+			}
+			continue
+		}
+		short := 0
+		for _, name := range nodeNames {
+			requested.add(d.request)
+			if !d.request.fits(*free[name]) {
+				short++
+			}
+			free[name].sub(d.request)
+		}
+		if short > 0 {
+			pending = append(pending, fmt.Sprintf("%s: %d of %d pods (%s each) don't fit on their node", d.workload, short, len(nodeNames), d.request))
+		}
+	}
+	sort.SliceStable(replicas, func(i, j int) bool {
+		a, b := replicas[i].request, replicas[j].request
+		return a.cpu > b.cpu || (a.cpu == b.cpu && a.memory > b.memory)
+	})
+	unplaced := map[string]int{}
+	var order []string
+	for _, d := range replicas {
+		placed := false
+		for _, name := range nodeNames {
+			if d.request.fits(*free[name]) {
+				free[name].sub(d.request)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			if unplaced[d.workload] == 0 {
+				order = append(order, d.workload)
+			}
+			unplaced[d.workload]++
+		}
+	}
+	for _, workload := range order {
+		for _, d := range demands {
+			if d.workload == workload {
+				pending = append(pending, fmt.Sprintf("%s: %d of %d pods (%s each) fit on no node", workload, unplaced[workload], d.replicas, d.request))
+				break
+			}
+		}
+	}
+	report.Requested = requested.String()
+	report.Problems = append(report.Problems, pending...)
+
+	quotaProblems, err := checkQuotaHeadroom(ctx, clientset, namespace, requested, current)
+	if err != nil {
+		return nil, err
+	}
+	report.Problems = append(report.Problems, quotaProblems...)
+	return report, nil
+}
+
+// checkQuotaHeadroom compares requested with what the ResourceQuotas of
+// namespace have left, counting current, the requests of the release's own
+// pods, as left too.
+func checkQuotaHeadroom(ctx context.Context, clientset kubernetes.Interface, namespace string, requested, current resourceAmount) ([]string, error) {
+	quotas, err := clientset.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource quotas: %w", err)
+	}
+	var problems []string
+	for _, quota := range quotas.Items {
+		for _, r := range []struct {
+			names     []corev1.ResourceName
+			requested int64
+			current   int64
+			value     func(resource.Quantity) int64
+			format    func(int64) *resource.Quantity
+		}{
+			{[]corev1.ResourceName{corev1.ResourceRequestsCPU, corev1.ResourceCPU}, requested.cpu, current.cpu,
+				func(q resource.Quantity) int64 { return q.MilliValue() },
+				func(v int64) *resource.Quantity { return resource.NewMilliQuantity(v, resource.DecimalSI) }},
+			{[]corev1.ResourceName{corev1.ResourceRequestsMemory, corev1.ResourceMemory}, requested.memory, current.memory,
+				func(q resource.Quantity) int64 { return q.Value() },
+				func(v int64) *resource.Quantity { return resource.NewQuantity(v, resource.BinarySI) }},
+		} {
+			for _, name := range r.names {
+				hard, ok := quota.Status.Hard[name]
+				if !ok {
+					hard, ok = quota.Spec.Hard[name]
+				}
+				if !ok {
+					continue
+				}
+				used := quota.Status.Used[name]
+				headroom := r.value(hard) - r.value(used) + r.current
+				if r.requested > headroom {
+					problems = append(problems, fmt.Sprintf("ResourceQuota %s: %s requests %s, %s left",
+						quota.Name, name, r.format(r.requested), r.format(max(headroom, 0))))
+				}
+				break
+			}
+		}
+	}
+	return problems, nil
+}
+
+// nodeSchedulable reports whether new pods can be scheduled on node: it is
+// Ready and not cordoned.
+func nodeSchedulable(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podRequest is what the scheduler reserves for a pod: the sum of its
+// containers' requests, or its largest init container's when that is more.
+// A container with a limit but no request requests its limit.
+func podRequest(spec corev1.PodSpec) resourceAmount {
+	var sum, initMax resourceAmount
+	for _, c := range spec.Containers {
+		sum.add(containerRequest(c))
+	}
+	for _, c := range spec.InitContainers {
+		r := containerRequest(c)
+		initMax.cpu = max(initMax.cpu, r.cpu)
+		initMax.memory = max(initMax.memory, r.memory)
+	}
+	return resourceAmount{cpu: max(sum.cpu, initMax.cpu), memory: max(sum.memory, initMax.memory)}
+}
+
+func containerRequest(c corev1.Container) resourceAmount {
+	quantity := func(name corev1.ResourceName) resource.Quantity {
+		if q, ok := c.Resources.Requests[name]; ok {
+			return q
+		}
+		return c.Resources.Limits[name]
+	}
+	cpu, memory := quantity(corev1.ResourceCPU), quantity(corev1.ResourceMemory)
+	return resourceAmount{cpu: cpu.MilliValue(), memory: memory.Value()}
+}
+
+// manifestPodDemands returns the pods the workloads of manifest run.
+// CronJobs are left out: their pods only exist while a job runs.
+func manifestPodDemands(manifest string) ([]podDemand, error) {
+	var demands []podDemand
+	docs := releaseutil.SplitManifests(manifest)
+	for _, name := range sortedKeys(docs) {
+		doc := docs[name]
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Replicas    *int32 `json:"replicas"`
+				Parallelism *int32 `json:"parallelism"`
+				Template    struct {
+					Spec corev1.PodSpec `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		d := podDemand{workload: obj.Kind + "/" + obj.Metadata.Name, replicas: 1}
+		switch obj.Kind {
+		case "Deployment", "StatefulSet", "ReplicaSet":
+			if obj.Spec.Replicas != nil {
+				d.replicas = int(*obj.Spec.Replicas)
+			}
+			d.request = podRequest(obj.Spec.Template.Spec)
+		case "Job":
+			if obj.Spec.Parallelism != nil {
+				d.replicas = int(*obj.Spec.Parallelism)
+			}
+			d.request = podRequest(obj.Spec.Template.Spec)
+		case "DaemonSet":
+			d.perNode = true
+			d.request = podRequest(obj.Spec.Template.Spec)
+		case "Pod":
+			var pod struct {
+				Spec corev1.PodSpec `json:"spec"`
+			}
+			if err := yaml.Unmarshal([]byte(doc), &pod); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			d.request = podRequest(pod.Spec)
+		default:
+			continue
+		}
+		if d.replicas > 0 && (d.request.cpu > 0 || d.request.memory > 0) {
+			demands = append(demands, d)
+		}
+	}
+	return demands, nil
+}
+
+// String lists the problems of the report.
+func (r *CapacityReport) String() string {
+	return strings.Join(r.Problems, "; ")
+}
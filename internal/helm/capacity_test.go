@@ -0,0 +1,152 @@
+package helm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const capacityManifest = `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          resources:
+            requests: {cpu: "2", memory: 128Mi}
+      containers:
+        - name: web
+          resources:
+            requests: {cpu: 500m, memory: 1Gi}
+        - name: proxy
+          resources:
+            limits: {cpu: 100m, memory: 64Mi}
+---
+# Source: app/templates/agent.yaml
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      containers:
+        - name: agent
+          resources:
+            requests: {cpu: 100m, memory: 100Mi}
+---
+# Source: app/templates/report.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  jobTemplate: {}
+`
+
+func TestManifestPodDemands(t *testing.T) {
+	demands, err := manifestPodDemands(capacityManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(demands) != 2 {
+		t.Fatalf("demands = %+v, want the Deployment and the DaemonSet", demands)
+	}
+	for _, d := range demands {
+		switch d.workload {
+		case "Deployment/web":
+			// The init container requests more CPU than the containers
+			// together; memory is the containers' sum.
+			want := resourceAmount{cpu: 2000, memory: 1088 << 20}
+			if d.replicas != 3 || d.request != want || d.perNode {
+				t.Errorf("web = %+v, want 3 replicas of %+v", d, want)
+			}
+		case "DaemonSet/agent":
+			if !d.perNode || d.request != (resourceAmount{cpu: 100, memory: 100 << 20}) {
+				t.Errorf("agent = %+v", d)
+			}
+		default:
+			t.Errorf("unexpected workload %s", d.workload)
+		}
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	node := func(name, cpu, memory string, ready bool) *corev1.Node {
+		status := corev1.ConditionTrue
+		if !ready {
+			status = corev1.ConditionFalse
+		}
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)},
+				Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			},
+		}
+	}
+	pod := func(name, namespace, release, nodeName, cpu string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app.kubernetes.io/instance": release}},
+			Spec: corev1.PodSpec{NodeName: nodeName, Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+			}}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	quota := func(cpu, used string) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "apps"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse(cpu)},
+				Used: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse(used)},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		problems []string
+	}{
+		{"fits", []runtime.Object{node("a", "8", "8Gi", true), node("b", "8", "8Gi", true)}, nil},
+		{"release pods are replaced", []runtime.Object{
+			node("a", "5", "8Gi", true), node("b", "5", "8Gi", true),
+			pod("web-1", "apps", "web", "a", "2"), pod("web-2", "apps", "web", "b", "2"),
+		}, nil},
+		{"other pods use the capacity", []runtime.Object{
+			node("a", "4", "8Gi", true), node("b", "4", "8Gi", true), node("c", "16", "8Gi", false),
+			pod("db-1", "data", "db", "a", "3"), pod("db-2", "data", "db", "b", "1"),
+		}, []string{"Deployment/web: 2 of 3 pods"}},
+		{"quota", []runtime.Object{node("a", "16", "16Gi", true), quota("8", "4")}, []string{"ResourceQuota team: requests.cpu requests 6100m, 4 left"}},
+		{"no node", []runtime.Object{node("a", "16", "16Gi", false)}, []string{"no ready, schedulable node"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := checkCapacity(context.Background(), fake.NewClientset(tt.objects...), "web", "apps", capacityManifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Problems) != len(tt.problems) {
+				t.Fatalf("problems = %q, want %q", report.Problems, tt.problems)
+			}
+			for i, want := range tt.problems {
+				if !strings.Contains(report.Problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, report.Problems[i], want)
+				}
+			}
+		})
+	}
+}
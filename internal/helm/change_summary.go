@@ -0,0 +1,273 @@
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+// maxChangedPaths bounds the generic "fields changed" details of a resource.
+const maxChangedPaths = 3
+
+// ResourceChange is the effect an upgrade had on one resource of a release.
+type ResourceChange struct {
+	Resource string   // Kind/name
+	Change   string   // added, modified, removed or unchanged
+	Details  []string // e.g. "image web: web:1.0 → web:1.1", "replicas: 2 → 3"
+}
+
+// summarizeChanges compares the manifests of two revisions resource by
+// resource, highlighting image, replica and env changes of workloads.
+func summarizeChanges(before, after string) []ResourceChange {
+	from, to := manifestResources(before), manifestResources(after)
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]ResourceChange, 0, len(names))
+	for _, name := range names {
+		old, inBefore := from[name]
+		cur, inAfter := to[name]
+		c := ResourceChange{Resource: name}
+		switch {
+		case !inBefore:
+			c.Change = "added"
+		case !inAfter:
+			c.Change = "removed"
+		case old == cur:
+			c.Change = "unchanged"
+		default:
+			c.Change = "modified"
+			c.Details = changeDetails(old, cur)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// changeDetails describes how a resource changed: its image, replica and env
+// changes when it is a workload, otherwise the fields that differ.
+func changeDetails(before, after string) []string {
+	var a, b map[string]interface{}
+	if yaml.Unmarshal([]byte(before), &a) != nil || yaml.Unmarshal([]byte(after), &b) != nil {
+		return nil
+	}
+
+	var details []string
+	if ra, rb := lookup(a, "spec", "replicas"), lookup(b, "spec", "replicas"); !reflect.DeepEqual(ra, rb) {
+		details = append(details, fmt.Sprintf("replicas: %v → %v", orNone(ra), orNone(rb)))
+	}
+	details = append(details, containerChanges(podSpec(a), podSpec(b))...)
+	if len(details) > 0 {
+		return details
+	}
+
+	paths := changedPaths(a, b, "", 2)
+	if len(paths) > maxChangedPaths {
+		paths = append(paths[:maxChangedPaths], fmt.Sprintf("+%d more", len(paths)-maxChangedPaths))
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return []string{"changed: " + strings.Join(paths, ", ")}
+}
+
+// podSpec returns the pod template spec of a workload, or nil.
+func podSpec(obj map[string]interface{}) map[string]interface{} {
+	for _, path := range [][]string{
+		{"spec", "template", "spec"},                        // Deployment, StatefulSet, DaemonSet, Job
+		{"spec", "jobTemplate", "spec", "template", "spec"}, // CronJob
+	} {
+		if spec, ok := lookup(obj, path...).(map[string]interface{}); ok {
+			return spec
+		}
+	}
+	if obj["kind"] == "Pod" {
+		spec, _ := obj["spec"].(map[string]interface{})
+		return spec
+	}
+	return nil
+}
+
+// containerChanges lists the containers added or removed between two pod
+// specs and the image and env changes of the others. Env values are not
+// shown, as they may hold credentials.
+func containerChanges(before, after map[string]interface{}) []string {
+	if before == nil && after == nil {
+		return nil
+	}
+	from, to := containersByName(before), containersByName(after)
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var details []string
+	for _, name := range names {
+		a, inBefore := from[name]
+		b, inAfter := to[name]
+		switch {
+		case !inBefore:
+			details = append(details, fmt.Sprintf("container %s added (%v)", name, b["image"]))
+			continue
+		case !inAfter:
+			details = append(details, fmt.Sprintf("container %s removed", name))
+			continue
+		}
+		if a["image"] != b["image"] {
+			details = append(details, fmt.Sprintf("image %s: %v → %v", name, orNone(a["image"]), orNone(b["image"])))
+		}
+		if env := envChanges(a["env"], b["env"]); env != "" {
+			details = append(details, fmt.Sprintf("env %s: %s", name, env))
+		}
+	}
+	return details
+}
+
+func containersByName(spec map[string]interface{}) map[string]map[string]interface{} {
+	containers := map[string]map[string]interface{}{}
+	for _, key := range []string{"initContainers", "containers"} {
+		list, _ := spec[key].([]interface{})
+		for _, item := range list {
+			if c, ok := item.(map[string]interface{}); ok {
+				name, _ := c["name"].(string)
+				containers[name] = c
+			}
+		}
+	}
+	return containers
+}
+
+// envChanges renders the env variables added (+), changed (~) and removed (-)
+// between two container env lists, e.g. "+LOG_LEVEL ~DB_HOST -DEBUG".
+func envChanges(before, after interface{}) string {
+	from, to := envByName(before), envByName(after)
+	var added, changed, removed []string
+	for name, v := range to {
+		old, ok := from[name]
+		switch {
+		case !ok:
+			added = append(added, "+"+name)
+		case !reflect.DeepEqual(old, v):
+			changed = append(changed, "~"+name)
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			removed = append(removed, "-"+name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return strings.Join(append(append(added, changed...), removed...), " ")
+}
+
+func envByName(env interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+	list, _ := env.([]interface{})
+	for _, item := range list {
+		if e, ok := item.(map[string]interface{}); ok {
+			name, _ := e["name"].(string)
+			vars[name] = e
+		}
+	}
+	return vars
+}
+
+// changedPaths lists the dotted paths, up to depth levels deep, at which two
+// documents differ.
+func changedPaths(a, b map[string]interface{}, prefix string, depth int) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var paths []string
+	for _, k := range keys {
+		if reflect.DeepEqual(a[k], b[k]) {
+			continue
+		}
+		ma, okA := a[k].(map[string]interface{})
+		mb, okB := b[k].(map[string]interface{})
+		if okA && okB && depth > 1 {
+			paths = append(paths, changedPaths(ma, mb, prefix+k+".", depth-1)...)
+			continue
+		}
+		paths = append(paths, prefix+k)
+	}
+	return paths
+}
+
+func lookup(obj map[string]interface{}, path ...string) interface{} {
+	var cur interface{} = obj
+	for _, key := range path {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return cur
+}
+
+func orNone(v interface{}) interface{} {
+	if v == nil {
+		return none
+	}
+	return v
+}
+
+// printChangeSummary prints what an upgrade added, modified and removed, one
+// row per resource, and how many resources it left unchanged.
+func printChangeSummary(changes []ResourceChange) {
+	counts := map[string]int{}
+	data := pterm.TableData{{"RESOURCE", "CHANGE", "DETAILS"}}
+	for _, c := range changes {
+		counts[c.Change]++
+		if c.Change == "unchanged" {
+			continue
+		}
+		label := c.Change
+		switch c.Change {
+		case "added":
+			label = pterm.Green(label)
+		case "modified":
+			label = pterm.Yellow(label)
+		case "removed":
+			label = pterm.Red(label)
+		}
+		data = append(data, []string{c.Resource, label, strings.Join(c.Details, "\n")})
+	}
+
+	fmt.Println()
+	pterm.DefaultSection.Println("Change summary")
+	if len(data) > 1 {
+		_ = pterm.DefaultTable.WithHasHeader(true).WithData(data).Render()
+	}
+	pterm.Info.Printfln("%d added, %d modified, %d removed, %d unchanged",
+		counts["added"], counts["modified"], counts["removed"], counts["unchanged"])
+}
@@ -0,0 +1,120 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeChanges(t *testing.T) {
+	before := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:1.0
+          env:
+            - name: DB_HOST
+              value: db
+            - name: DEBUG
+              value: "true"
+        - name: proxy
+          image: envoy:1.29
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  mode: blue
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  schedule: "0 * * * *"
+`
+	after := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:1.1
+          env:
+            - name: DB_HOST
+              value: db-replica
+            - name: LOG_LEVEL
+              value: info
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  mode: green
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+`
+
+	want := []ResourceChange{
+		{Resource: "ConfigMap/web-config", Change: "modified", Details: []string{"changed: data.mode"}},
+		{Resource: "CronJob/cleanup", Change: "removed"},
+		{Resource: "Deployment/web", Change: "modified", Details: []string{
+			"replicas: 2 → 3",
+			"container proxy removed",
+			"image web: web:1.0 → web:1.1",
+			"env web: +LOG_LEVEL ~DB_HOST -DEBUG",
+		}},
+		{Resource: "Ingress/web", Change: "added"},
+		{Resource: "Service/web", Change: "unchanged"},
+	}
+	if got := summarizeChanges(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeChanges =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	a := map[string]interface{}{
+		"data": map[string]interface{}{"a": "1", "b": "2", "c": "3", "d": "4"},
+		"spec": map[string]interface{}{"ports": []interface{}{80}},
+	}
+	b := map[string]interface{}{
+		"data": map[string]interface{}{"a": "x", "b": "y", "c": "3", "e": "5"},
+		"spec": map[string]interface{}{"ports": []interface{}{8080}},
+	}
+	want := []string{"data.a", "data.b", "data.d", "data.e", "spec.ports"}
+	if got := changedPaths(a, b, "", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("changedPaths = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package helm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// helmCLIFlag maps a flag of the helm CLI onto a flag of the selm command
+// that does the same.
+type helmCLIFlag struct {
+	selm  string // the selm flag; empty drops the flag, for what selm always does
+	value bool   // the flag takes a value
+	// convert turns the helm value into the selm values, one flag each.
+	convert func(string) ([]string, error)
+}
+
+// helmCLICommand is a helm CLI command and the selm command it runs as.
+type helmCLICommand struct {
+	selm string
+	// defaults are selm flags given before the translated ones, where
+	// selm's default differs from helm's; a flag given later wins.
+	defaults []string
+	flags    map[string]helmCLIFlag
+	// unsupported explains why a helm flag has no selm counterpart.
+	unsupported map[string]string
+}
+
+// HelmCLICommands are the helm CLI commands `smurf helm` runs, by name.
+var HelmCLICommands = []string{"install", "upgrade", "list", "uninstall"}
+
+var (
+	helmKubeFlags = map[string]helmCLIFlag{
+		"n":            {selm: "namespace", value: true},
+		"namespace":    {selm: "namespace", value: true},
+		"kube-context": {selm: "kube-context", value: true},
+		"kubeconfig":   {selm: "kubeconfig", value: true},
+	}
+	helmChartFlags = map[string]helmCLIFlag{
+		"f":                      {selm: "values", value: true},
+		"values":                 {selm: "values", value: true},
+		"set":                    {selm: "set", value: true},
+		"set-literal":            {selm: "set-literal", value: true},
+		"set-string":             {selm: "set-literal", value: true, convert: splitSetString},
+		"version":                {selm: "version", value: true},
+		"timeout":                {selm: "timeout", value: true, convert: durationSeconds},
+		"atomic":                 {selm: "atomic"},
+		"wait":                   {selm: "wait"},
+		"debug":                  {selm: "debug"},
+		"no-hooks":               {selm: "no-hooks"},
+		"verify":                 {selm: "verify"},
+		"keyring":                {selm: "keyring", value: true},
+		"skip-schema-validation": {selm: "skip-schema-validation"},
+		"o":                      {selm: "output", value: true},
+		"output":                 {selm: "output", value: true},
+	}
+	helmChartUnsupported = map[string]string{
+		"dry-run":               "use smurf selm template, or --client-only to validate without a cluster",
+		"set-file":              "put the file's content in a values file",
+		"set-json":              "put the value in a values file",
+		"description":           "selm records its own description",
+		"dependency-update":     "run helm dependency update first",
+		"wait-for-jobs":         "selm waits for the release's Jobs with --wait",
+		"post-renderer":         "post-renderers are not supported",
+		"render-subchart-notes": "subchart notes are not printed",
+	}
+)
+
+var helmCLICompat = map[string]helmCLICommand{
+	"install": {
+		selm: "install",
+		// helm install does not wait unless asked to; selm install does.
+		defaults: []string{"--wait=false"},
+		flags: mergeHelmCLI(helmKubeFlags, helmChartFlags, map[string]helmCLIFlag{
+			"repo":          {selm: "repo", value: true},
+			"g":             {selm: "generate-name"},
+			"generate-name": {selm: "generate-name"},
+			// selm install always creates the namespace.
+			"create-namespace": {},
+		}),
+		unsupported: helmChartUnsupported,
+	},
+	"upgrade": {
+		selm: "upgrade",
+		flags: mergeHelmCLI(helmKubeFlags, helmChartFlags, map[string]helmCLIFlag{
+			"repo":             {selm: "repo-url", value: true},
+			"i":                {selm: "install"},
+			"install":          {selm: "install"},
+			"force":            {selm: "force"},
+			"history-max":      {selm: "history-max", value: true},
+			"create-namespace": {selm: "create-namespace"},
+		}),
+		unsupported: mergeHelmCLI(helmChartUnsupported, map[string]string{
+			"reuse-values":            "use --migrate-values with smurf selm upgrade to start from the release's values",
+			"reset-values":            "selm upgrades from the chart's values and those given, as --reset-values does",
+			"reset-then-reuse-values": "use --migrate-values with smurf selm upgrade",
+			"cleanup-on-fail":         "use --atomic to roll back a failed upgrade",
+		}),
+	},
+	"list": {
+		selm: "list",
+		flags: mergeHelmCLI(helmKubeFlags, map[string]helmCLIFlag{
+			"A":              {selm: "all-namespaces"},
+			"all-namespaces": {selm: "all-namespaces"},
+			"o":              {selm: "output", value: true},
+			"output":         {selm: "output", value: true},
+			"debug":          {},
+		}),
+		unsupported: map[string]string{
+			"q":        "use -o json and read the names from it",
+			"short":    "use -o json and read the names from it",
+			"a":        "selm lists the releases of every status",
+			"all":      "selm lists the releases of every status",
+			"f":        "filter the -o json output instead",
+			"filter":   "filter the -o json output instead",
+			"l":        "filter the -o json output instead",
+			"selector": "filter the -o json output instead",
+		},
+	},
+	"uninstall": {
+		selm: "uninstall",
+		flags: mergeHelmCLI(helmKubeFlags, map[string]helmCLIFlag{
+			"timeout":  {selm: "timeout", value: true},
+			"no-hooks": {selm: "no-hooks"},
+			"cascade":  {selm: "cascade", value: true},
+			// selm uninstall always waits for the resources to be deleted.
+			"wait":  {},
+			"debug": {},
+		}),
+		unsupported: map[string]string{
+			"keep-history":     "selm uninstall removes the release history",
+			"dry-run":          "use smurf selm status to see what the release holds",
+			"ignore-not-found": "selm uninstall fails on a release that does not exist",
+			"description":      "selm records no uninstall description",
+		},
+	},
+}
+
+// TranslateHelmCLIArgs turns the arguments of a helm CLI command, e.g.
+// `upgrade --install app ./chart --timeout 5m`, into those of the selm
+// command that does the same: the selm command name, then its arguments.
+// Helm flags selm has no counterpart for are an error naming what to use
+// instead, rather than being ignored.
+func TranslateHelmCLIArgs(command string, args []string) ([]string, error) {
+	spec, ok := helmCLICompat[command]
+	if !ok {
+		return nil, fmt.Errorf("unsupported helm command %q: smurf helm runs %s", command, strings.Join(HelmCLICommands, ", "))
+	}
+	out := append([]string{spec.selm}, spec.defaults...)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := splitHelmFlag(arg)
+		flag, known := spec.flags[name]
+		if !known {
+			if hint, ok := spec.unsupported[name]; ok {
+				return nil, fmt.Errorf("helm %s flag %s is not supported by smurf helm: %s", command, helmFlagName(name), hint)
+			}
+			return nil, fmt.Errorf("unknown helm %s flag %s", command, helmFlagName(name))
+		}
+		if flag.value && !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("helm flag %s needs a value", helmFlagName(name))
+			}
+			i++
+			value, hasValue = args[i], true
+		}
+		if !flag.value && hasValue {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for helm flag %s: must be true or false", value, helmFlagName(name))
+			}
+		}
+		if flag.selm == "" {
+			continue
+		}
+
+		if !hasValue {
+			out = append(out, "--"+flag.selm)
+			continue
+		}
+		values := []string{value}
+		if flag.convert != nil {
+			var err error
+			if values, err = flag.convert(value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for helm flag %s: %w", value, helmFlagName(name), err)
+			}
+		}
+		for _, v := range values {
+			out = append(out, "--"+flag.selm+"="+v)
+		}
+	}
+	if len(positional) > 0 {
+		out = append(append(out, "--"), positional...)
+	}
+	return out, nil
+}
+
+// HelmCLIFlags lists the helm flags `smurf helm COMMAND` accepts, as they
+// are written on the command line.
+func HelmCLIFlags(command string) []string {
+	var flags []string
+	for name := range helmCLICompat[command].flags {
+		flags = append(flags, helmFlagName(name))
+	}
+	slices.SortFunc(flags, func(a, b string) int {
+		return strings.Compare(strings.TrimLeft(a, "-"), strings.TrimLeft(b, "-"))
+	})
+	return flags
+}
+
+// splitHelmFlag splits a flag argument into its name and value: --name,
+// --name=value, -n, -n=value or -nvalue.
+func splitHelmFlag(arg string) (name, value string, hasValue bool) {
+	if strings.HasPrefix(arg, "--") {
+		name, value, hasValue = strings.Cut(arg[2:], "=")
+		return name, value, hasValue
+	}
+	name, rest := arg[1:2], arg[2:]
+	if rest == "" {
+		return name, "", false
+	}
+	return name, strings.TrimPrefix(rest, "="), true
+}
+
+// helmFlagName is how a flag name is written on the command line.
+func helmFlagName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// durationSeconds converts helm's --timeout duration, e.g. 5m0s, into the
+// whole seconds selm takes, rounding up.
+func durationSeconds(value string) ([]string, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("must be positive")
+	}
+	return []string{strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)}, nil
+}
+
+// splitSetString splits helm's --set-string a=1,b=2 into one --set-literal
+// per pair; a comma escaped as \, stays in its value.
+func splitSetString(value string) ([]string, error) {
+	var pairs []string
+	var cur strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == ',':
+			cur.WriteByte(',')
+			i++
+		case value[i] == ',':
+			pairs = append(pairs, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(value[i])
+		}
+	}
+	pairs = append(pairs, cur.String())
+	for _, p := range pairs {
+		if !strings.Contains(p, "=") {
+			return nil, fmt.Errorf("%q is not key=value", p)
+		}
+	}
+	return pairs, nil
+}
+
+// mergeHelmCLI merges the flag tables of several helm commands.
+func mergeHelmCLI[V any](sets ...map[string]V) map[string]V {
+	merged := map[string]V{}
+	for _, set := range sets {
+		maps.Copy(merged, set)
+	}
+	return merged
+}
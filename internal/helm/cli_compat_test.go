@@ -0,0 +1,103 @@
+package helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslateHelmCLIArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		want    []string
+	}{
+		{
+			name:    "install",
+			command: "install",
+			args:    []string{"app", "./chart", "-n", "prod", "-f", "values.yaml", "--set", "image.tag=v2", "--timeout", "5m", "--create-namespace"},
+			want:    []string{"install", "--wait=false", "--namespace=prod", "--values=values.yaml", "--set=image.tag=v2", "--timeout=300", "--", "app", "./chart"},
+		},
+		{
+			name:    "install waiting, with a generated name",
+			command: "install",
+			args:    []string{"./chart", "-g", "--wait", "--repo=https://charts.example.com", "--version", "1.2.0"},
+			want:    []string{"install", "--wait=false", "--generate-name", "--wait", "--repo=https://charts.example.com", "--version=1.2.0", "--", "./chart"},
+		},
+		{
+			name:    "upgrade --install",
+			command: "upgrade",
+			args:    []string{"-i", "app", "./chart", "--atomic", "--repo", "https://charts.example.com", "--timeout=90s", "--history-max=5", "-nprod"},
+			want:    []string{"upgrade", "--install", "--atomic", "--repo-url=https://charts.example.com", "--timeout=90", "--history-max=5", "--namespace=prod", "--", "app", "./chart"},
+		},
+		{
+			name:    "set-string",
+			command: "upgrade",
+			args:    []string{"app", "./chart", `--set-string=a=1,b=x\,y`},
+			want:    []string{"upgrade", "--set-literal=a=1", "--set-literal=b=x,y", "--", "app", "./chart"},
+		},
+		{
+			name:    "list",
+			command: "list",
+			args:    []string{"-A", "-o", "json", "--debug"},
+			want:    []string{"list", "--all-namespaces", "--output=json"},
+		},
+		{
+			name:    "uninstall keeps the duration",
+			command: "uninstall",
+			args:    []string{"app", "--timeout", "2m", "--wait", "--kube-context", "staging"},
+			want:    []string{"uninstall", "--timeout=2m", "--kube-context=staging", "--", "app"},
+		},
+		{
+			name:    "arguments after --",
+			command: "uninstall",
+			args:    []string{"--", "-odd-name"},
+			want:    []string{"uninstall", "--", "-odd-name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TranslateHelmCLIArgs(tt.command, tt.args)
+			if err != nil {
+				t.Fatalf("TranslateHelmCLIArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TranslateHelmCLIArgs() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateHelmCLIArgsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		want    string
+	}{
+		{name: "unsupported command", command: "rollback", want: `unsupported helm command "rollback"`},
+		{name: "unsupported flag", command: "upgrade", args: []string{"--reuse-values"}, want: "--reuse-values is not supported by smurf helm: use --migrate-values"},
+		{name: "unknown flag", command: "list", args: []string{"--colour"}, want: "unknown helm list flag --colour"},
+		{name: "missing value", command: "install", args: []string{"app", "./chart", "-n"}, want: "-n needs a value"},
+		{name: "bad duration", command: "install", args: []string{"--timeout", "300"}, want: `invalid value "300" for helm flag --timeout`},
+		{name: "bad bool", command: "upgrade", args: []string{"--atomic=maybe"}, want: "must be true or false"},
+		{name: "bad set-string", command: "install", args: []string{"--set-string", "a=1,b"}, want: `"b" is not key=value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TranslateHelmCLIArgs(tt.command, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("TranslateHelmCLIArgs() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelmCLIFlags(t *testing.T) {
+	got := HelmCLIFlags("list")
+	want := []string{"-A", "--all-namespaces", "--debug", "--kube-context", "--kubeconfig", "-n", "--namespace", "-o", "--output"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HelmCLIFlags(list) = %q, want %q", got, want)
+	}
+}
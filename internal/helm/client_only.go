@@ -0,0 +1,198 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ClientOnlyOptions configures ValidateClientOnly.
+type ClientOnlyOptions struct {
+	Namespace        string
+	RepoURL          string
+	Version          string
+	ValuesFiles      []string
+	SetValues        []string
+	SetLiteralValues []string
+	KubeVersion      string   // Kubernetes version the chart is rendered for, like 1.30.0; helm's default when empty
+	APIVersions      []string // extra API versions the templates may check with .Capabilities.APIVersions
+	Upgrade          bool     // render as an upgrade (.Release.IsUpgrade) rather than an install
+	Debug            bool
+}
+
+// ValidateClientOnly checks what an install or upgrade of releaseName from
+// chartRef would send to the cluster, without a cluster: the chart loads
+// and is installable, its dependencies are present, the values match its
+// schema, the templates render, and every rendered document is a uniquely
+// named Kubernetes object. No kube client is constructed, so it runs in CI
+// jobs without cluster credentials.
+func ValidateClientOnly(releaseName, chartRef string, opts ClientOnlyOptions, useAI bool) error {
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Validating %s offline...", releaseName))
+	manifest, err := renderClientOnly(releaseName, chartRef, opts)
+	if err != nil {
+		spinner.Fail(err.Error())
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	counts, err := validateManifest(manifest, opts.Namespace)
+	if err != nil {
+		spinner.Fail("Rendered manifests are invalid")
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	spinner.Success(fmt.Sprintf("%s renders %d resource(s) from %s without errors", releaseName, resourceCount(counts), chartRef))
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	tableData := pterm.TableData{{"KIND", "COUNT"}}
+	for _, kind := range kinds {
+		tableData = append(tableData, []string{kind, fmt.Sprint(counts[kind])})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// renderClientOnly renders the release in memory, hooks included, the way
+// helm install --dry-run=client does.
+func renderClientOnly(releaseName, chartRef string, opts ClientOnlyOptions) (string, error) {
+	if len(configs.ValuesFrom) > 0 {
+		return "", errors.New("--values-from reads values from the cluster and can't be used in client-only mode")
+	}
+	settings := cli.New()
+	settings.SetNamespace(opts.Namespace)
+
+	client := action.NewInstall(new(action.Configuration))
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.PostRenderer = schedulingRenderer()
+	client.SkipSchemaValidation = configs.SkipSchemaValidation
+	client.IsUpgrade = opts.Upgrade
+	client.ReleaseName = releaseName
+	client.Namespace = opts.Namespace
+	client.Timeout = 5 * time.Minute
+	client.APIVersions = opts.APIVersions
+	if opts.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(opts.KubeVersion)
+		if err != nil {
+			return "", fmt.Errorf("invalid kube version %q: %w", opts.KubeVersion, err)
+		}
+		client.KubeVersion = kubeVersion
+	}
+
+	chartObj, err := LoadChart(chartRef, opts.RepoURL, opts.Version, settings)
+	if err != nil {
+		return "", err
+	}
+	if chartObj.Metadata.Type == "library" {
+		return "", fmt.Errorf("%s is a library chart and can't be installed", chartObj.Name())
+	}
+	if err := action.CheckDependencies(chartObj, chartObj.Metadata.Dependencies); err != nil {
+		return "", fmt.Errorf("%s has missing dependencies (run helm dependency update): %w", chartObj.Name(), err)
+	}
+	// No cluster, so no namespace defaults from it either.
+	vals, layers, err := loadValueLayers("", opts.ValuesFiles, opts.SetValues, opts.SetLiteralValues, opts.Debug)
+	if err != nil {
+		return "", err
+	}
+	if configs.ExplainValues {
+		if err := printValueOrigins(chartObj, vals, layers); err != nil {
+			return "", err
+		}
+	}
+	if err := checkValuesSchema(chartObj, vals); err != nil {
+		return "", err
+	}
+	rel, err := client.Run(chartObj, vals)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", chartRef, err)
+	}
+	manifest := rel.Manifest
+	for _, hook := range rel.Hooks {
+		manifest += fmt.Sprintf("\n---\n# Source: %s\n%s", hook.Path, hook.Manifest)
+	}
+	return manifest, nil
+}
+
+// validateManifest checks that every document of manifest is a Kubernetes
+// object with an apiVersion, a kind and a name, and that no two of them are
+// the same object. It returns the number of objects of each kind.
+func validateManifest(manifest, namespace string) (map[string]int, error) {
+	counts := map[string]int{}
+	seen := map[string]string{}
+	var problems []string
+	for name, doc := range releaseutil.SplitManifests(manifest) {
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		source := manifestSource(doc, name)
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: not valid YAML: %v", source, err))
+			continue
+		}
+		var missing []string
+		for field, v := range map[string]string{"apiVersion": obj.APIVersion, "kind": obj.Kind, "metadata.name": obj.Metadata.Name} {
+			if v == "" {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			problems = append(problems, fmt.Sprintf("%s: missing %s", source, strings.Join(missing, ", ")))
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		key := fmt.Sprintf("%s %s/%s", obj.Kind, ns, obj.Metadata.Name)
+		if other, ok := seen[key]; ok {
+			problems = append(problems, fmt.Sprintf("%s: %s %s is also defined in %s", source, obj.Kind, obj.Metadata.Name, other))
+			continue
+		}
+		seen[key] = source
+		counts[obj.Kind]++
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%d problem(s) in the rendered manifests:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return counts, nil
+}
+
+// manifestSource returns the template a rendered document came from, from
+// the "# Source:" comment helm puts above it, or fallback.
+func manifestSource(doc, fallback string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if src, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source: "); ok {
+			return src
+		}
+	}
+	return fallback
+}
+
+func resourceCount(counts map[string]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
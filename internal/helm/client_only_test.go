@@ -0,0 +1,68 @@
+package helm
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderClientOnly(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	dir := t.TempDir()
+	if err := CreateChart("web", dir); err != nil {
+		t.Fatal(err)
+	}
+	opts := ClientOnlyOptions{Namespace: "apps", KubeVersion: "1.30.0", SetValues: []string{"image.tag=v1"}}
+	manifest, err := renderClientOnly("web", filepath.Join(dir, "web"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := validateManifest(manifest, opts.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["Deployment"] != 1 || counts["Service"] != 1 {
+		t.Errorf("counts = %v, want a Deployment and a Service", counts)
+	}
+
+	opts.KubeVersion = "not-a-version"
+	if _, err := renderClientOnly("web", filepath.Join(dir, "web"), opts); err == nil {
+		t.Error("renderClientOnly accepted an invalid kube version")
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	manifest := `---
+# Source: web/templates/a.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+# Source: web/templates/b.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: apps
+---
+# Source: web/templates/c.yaml
+kind: Secret
+metadata:
+  name: token
+`
+	_, err := validateManifest(manifest, "apps")
+	if err == nil {
+		t.Fatal("validateManifest accepted a duplicate and an incomplete object")
+	}
+	for _, want := range []string{"ConfigMap settings is also defined in web/templates/", "web/templates/c.yaml: missing apiVersion"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	// The same name in another namespace is another object.
+	if _, err := validateManifest(manifest[:strings.Index(manifest, "---\n# Source: web/templates/c.yaml")], "default"); err != nil {
+		t.Errorf("validateManifest = %v, want objects in different namespaces accepted", err)
+	}
+}
@@ -16,13 +16,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // getKubeClient returns the shared Kubernetes clientset, built from the kubeconfig
 // file specified in settings. Initialization runs exactly once via kubeClientOnce,
-// even when called concurrently (e.g. HelmProvision's parallel lint/template/install
-// goroutines, or the upgrade monitor's poll goroutine racing the main goroutine).
+// even when called concurrently (e.g. the upgrade monitor's poll goroutine racing
+// the main goroutine).
 //
 // If initialization fails, the error is cached in kubeClientErr and returned to
 // every caller for the lifetime of the process; it is not retried. This keeps the
@@ -31,7 +30,7 @@ import (
 // transient kubeconfig problem can be fixed by simply re-running the command.
 func getKubeClient() (*kubernetes.Clientset, error) {
 	kubeClientOnce.Do(func() {
-		config, err := clientcmd.BuildConfigFromFlags("", settings.KubeConfig)
+		config, err := settings.RESTClientGetter().ToRESTConfig()
 		if err != nil {
 			pterm.Error.Println("Failed to build Kubernetes configuration: ", err)
 			kubeClientErr = fmt.Errorf("failed to build Kubernetes configuration: %v", err)
@@ -60,7 +59,7 @@ func getKubeClient() (*kubernetes.Clientset, error) {
 // regular command output but would violate the "completion functions never
 // print" rule.
 func ListNamespaces(ctx context.Context) ([]string, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", settings.KubeConfig)
+	config, err := newSettings("").RESTClientGetter().ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -153,23 +152,10 @@ func ensureNamespace(namespace string, create bool) error {
 	return fmt.Errorf("error checking namespace '%s': %v", namespace, err)
 }
 
+// mergeMaps merges b over a: maps are merged key by key and any other value
+// of b, lists included, replaces a's.
 func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
-	out := make(map[string]interface{}, len(a))
-	for k, v := range a {
-		out[k] = v
-	}
-	for k, v := range b {
-		if vMap, ok := v.(map[string]interface{}); ok {
-			if bv, ok := out[k]; ok {
-				if bvMap, ok := bv.(map[string]interface{}); ok {
-					out[k] = mergeMaps(bvMap, vMap)
-					continue
-				}
-			}
-		}
-		out[k] = v
-	}
-	return out
+	return mergeValues(a, b, nil, nil)
 }
 
 // printReleaseInfo prints detailed information about the specified Helm release.
@@ -183,13 +169,14 @@ func printReleaseInfo(rel *release.Release, debug bool) {
 		pterm.Cyan(rel.Name))
 
 	// Release information in a clean table
+	summary := releaseSummary(rel)
 	releaseTable := pterm.TableData{
-		{"NAME", rel.Name},
-		{"CHART", fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)},
-		{"NAMESPACE", rel.Namespace},
-		{"LAST DEPLOYED", rel.Info.LastDeployed.Format("2006-01-02 15:04:05")},
-		{"STATUS", string(rel.Info.Status)},
-		{"REVISION", fmt.Sprintf("%d", rel.Version)},
+		{"NAME", summary.Name},
+		{"CHART", summary.Chart},
+		{"NAMESPACE", summary.Namespace},
+		{"LAST DEPLOYED", summary.Updated},
+		{"STATUS", summary.Status},
+		{"REVISION", fmt.Sprintf("%d", summary.Revision)},
 	}
 
 	pterm.DefaultTable.
@@ -406,364 +393,3 @@ func getExternalIP(svc *corev1.Service) string {
 	}
 	return "<none>"
 }
-
-// printResourcesFromRelease prints detailed information about the Kubernetes resources created by the Helm release.
-// It fetches detailed information about the resources from the Kubernetes API and prints it to the console.
-func printResourcesFromRelease(rel *release.Release) {
-	resources, err := parseResourcesFromManifest(rel.Manifest)
-	if err != nil {
-		pterm.Error.WithShowLineNumber(false).Printfln("Error parsing manifest: %v", err)
-		return
-	}
-
-	if len(resources) == 0 {
-		pterm.Info.Println("No Kubernetes resources were created by this release.")
-		return
-	}
-
-	// Print resources section
-	pterm.DefaultSection.Println("RESOURCES")
-
-	clientset, getClientErr := getKubeClient()
-	if getClientErr != nil {
-		pterm.Error.WithShowLineNumber(false).Printfln("Error getting kube client for detailed resource info: %v", getClientErr)
-
-		// Fallback table with basic info
-		tableData := pterm.TableData{{"Kind", "Name"}}
-		for _, r := range resources {
-			tableData = append(tableData, []string{r.Kind, r.Name})
-		}
-
-		pterm.DefaultTable.WithHasHeader(true).WithData(tableData).Render()
-		return
-	}
-
-	// Print resources in tables grouped by kind
-	for _, r := range resources {
-		switch r.Kind {
-		case "Deployment":
-			dep, err := clientset.AppsV1().Deployments(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"REPLICAS", fmt.Sprint(*dep.Spec.Replicas)},
-				{"DESIRED", fmt.Sprint(dep.Status.Replicas)},
-				{"READY", fmt.Sprint(dep.Status.ReadyReplicas)},
-				{"UPDATED", fmt.Sprint(dep.Status.UpdatedReplicas)},
-				{"AVAILABLE", fmt.Sprint(dep.Status.AvailableReplicas)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "ReplicaSet":
-			rs, err := clientset.AppsV1().ReplicaSets(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"REPLICAS", fmt.Sprint(*rs.Spec.Replicas)},
-				{"DESIRED", fmt.Sprint(rs.Status.Replicas)},
-				{"READY", fmt.Sprint(rs.Status.ReadyReplicas)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "StatefulSet":
-			ss, err := clientset.AppsV1().StatefulSets(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"REPLICAS", fmt.Sprint(*ss.Spec.Replicas)},
-				{"CURRENT", fmt.Sprint(ss.Status.CurrentReplicas)},
-				{"READY", fmt.Sprint(ss.Status.ReadyReplicas)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "DaemonSet":
-			ds, err := clientset.AppsV1().DaemonSets(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"DESIRED", fmt.Sprint(ds.Status.DesiredNumberScheduled)},
-				{"CURRENT", fmt.Sprint(ds.Status.CurrentNumberScheduled)},
-				{"READY", fmt.Sprint(ds.Status.NumberReady)},
-				{"UPDATED", fmt.Sprint(ds.Status.UpdatedNumberScheduled)},
-				{"AVAILABLE", fmt.Sprint(ds.Status.NumberAvailable)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "Pod":
-			pod, err := clientset.CoreV1().Pods(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			ready := false
-			for _, cond := range pod.Status.Conditions {
-				if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
-					ready = true
-					break
-				}
-			}
-
-			podTable := pterm.TableData{
-				{"PHASE", string(pod.Status.Phase)},
-				{"READY", fmt.Sprint(ready)},
-				{"NODE", pod.Spec.NodeName},
-				{"POD IP", pod.Status.PodIP},
-				{"START TIME", pod.Status.StartTime.Format(time.RFC1123)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(podTable).Render()
-
-			// Containers table
-			if len(pod.Status.ContainerStatuses) > 0 {
-				pterm.Println()
-				pterm.Info.Println("Containers:")
-
-				for _, cs := range pod.Status.ContainerStatuses {
-					state := ""
-					if cs.State.Waiting != nil {
-						state = fmt.Sprintf("Waiting (%s)", cs.State.Waiting.Reason)
-					} else if cs.State.Terminated != nil {
-						state = fmt.Sprintf("Terminated (%s)", cs.State.Terminated.Reason)
-					} else if cs.State.Running != nil {
-						state = fmt.Sprintf("Running (since %s)", cs.State.Running.StartedAt.Format(time.RFC1123))
-					}
-
-					containerTable := pterm.TableData{
-						{"NAME", cs.Name},
-						{"READY", fmt.Sprint(cs.Ready)},
-						{"STATE", state},
-						{"RESTARTS", fmt.Sprint(cs.RestartCount)},
-						{"IMAGE", cs.Image},
-					}
-
-					pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(containerTable).Render()
-				}
-			}
-
-		case "Service":
-			svc, err := clientset.CoreV1().Services(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			serviceTable := pterm.TableData{
-				{"TYPE", string(svc.Spec.Type)},
-				{"CLUSTER-IP", svc.Spec.ClusterIP},
-				{"EXTERNAL-IP", getExternalIP(svc)},
-				{"AGE", time.Since(svc.CreationTimestamp.Time).Round(time.Second).String()},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(serviceTable).Render()
-
-			// Ports
-			if len(svc.Spec.Ports) > 0 {
-				pterm.Println()
-				pterm.Info.Println("Ports:")
-
-				for _, p := range svc.Spec.Ports {
-					nodePort := ""
-					if p.NodePort > 0 {
-						nodePort = fmt.Sprint(p.NodePort)
-					}
-
-					portsTable := pterm.TableData{
-						{"NAME", p.Name},
-						{"PORT", fmt.Sprint(p.Port)},
-						{"TARGET PORT", p.TargetPort.String()},
-						{"PROTOCOL", string(p.Protocol)},
-						{"NODE PORT", nodePort},
-					}
-
-					pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(portsTable).Render()
-				}
-			}
-
-		case "ServiceAccount":
-			sa, err := clientset.CoreV1().ServiceAccounts(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"SECRETS", fmt.Sprint(len(sa.Secrets))},
-				{"AGE", time.Since(sa.CreationTimestamp.Time).Round(time.Second).String()},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "ConfigMap":
-			cm, err := clientset.CoreV1().ConfigMaps(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"DATA", fmt.Sprint(len(cm.Data))},
-				{"BINARY DATA", fmt.Sprint(len(cm.BinaryData))},
-				{"AGE", time.Since(cm.CreationTimestamp.Time).Round(time.Second).String()},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "Secret":
-			secret, err := clientset.CoreV1().Secrets(rel.Namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"TYPE", string(secret.Type)},
-				{"DATA", fmt.Sprint(len(secret.Data))},
-				{"AGE", time.Since(secret.CreationTimestamp.Time).Round(time.Second).String()},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		case "Namespace":
-			ns, err := clientset.CoreV1().Namespaces().Get(context.Background(), r.Name, metav1.GetOptions{})
-			if err != nil {
-				pterm.Warning.Printfln("%s/%s: Failed to get details: %v", r.Kind, r.Name, err)
-				continue
-			}
-
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-
-			tableData := pterm.TableData{
-				{"STATUS", string(ns.Status.Phase)},
-				{"AGE", time.Since(ns.CreationTimestamp.Time).Round(time.Second).String()},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
-
-		default:
-			pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
-			pterm.Info.Println("No additional details available for this resource type")
-		}
-
-		pterm.Println()
-	}
-
-	// Print pods section
-	pterm.DefaultSection.Println("PODS ASSOCIATED WITH THE RELEASE")
-
-	podList, err := clientset.CoreV1().Pods(rel.Namespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("app.kubernetes.io/instance=%s", rel.Name),
-	})
-	if err != nil {
-		pterm.Error.WithShowLineNumber(false).Printfln("Error listing pods for release '%s': %v", rel.Name, err)
-	} else if len(podList.Items) == 0 {
-		pterm.Warning.Printf("No pods found for release '%s'\n", rel.Name)
-	} else {
-		for _, pod := range podList.Items {
-			pterm.DefaultSection.Printf("Pod: %s", pod.Name)
-
-			ready := false
-			for _, cond := range pod.Status.Conditions {
-				if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
-					ready = true
-					break
-				}
-			}
-
-			podTable := pterm.TableData{
-				{"PHASE", string(pod.Status.Phase)},
-				{"READY", fmt.Sprint(ready)},
-				{"NODE", pod.Spec.NodeName},
-				{"POD IP", pod.Status.PodIP},
-				{"START TIME", pod.Status.StartTime.Format(time.RFC1123)},
-			}
-
-			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(podTable).Render()
-
-			// Containers
-			if len(pod.Status.ContainerStatuses) > 0 {
-				pterm.Println()
-				pterm.Info.Println("Containers:")
-
-				for _, cs := range pod.Status.ContainerStatuses {
-					state := ""
-					if cs.State.Waiting != nil {
-						state = fmt.Sprintf("Waiting (%s)", cs.State.Waiting.Reason)
-					} else if cs.State.Terminated != nil {
-						state = fmt.Sprintf("Terminated (%s)", cs.State.Terminated.Reason)
-					} else if cs.State.Running != nil {
-						state = fmt.Sprintf("Running (since %s)", cs.State.Running.StartedAt.Format(time.RFC1123))
-					}
-
-					containerTable := pterm.TableData{
-						{"NAME", cs.Name},
-						{"READY", fmt.Sprint(cs.Ready)},
-						{"STATE", state},
-						{"RESTARTS", fmt.Sprint(cs.RestartCount)},
-						{"IMAGE", cs.Image},
-					}
-
-					pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(containerTable).Render()
-				}
-			}
-
-			// Events
-			evts, err := clientset.CoreV1().Events(rel.Namespace).List(context.Background(), metav1.ListOptions{
-				FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod.Name),
-			})
-			if err != nil {
-				pterm.Warning.Printf("Error fetching events for pod %s: %v\n", pod.Name, err)
-			} else if len(evts.Items) > 0 {
-				pterm.Println()
-				pterm.Info.Println("Events:")
-
-				for _, evt := range evts.Items {
-					eventsTable := pterm.TableData{
-						{"LAST SEEN", time.Since(evt.LastTimestamp.Time).Round(time.Second).String() + " ago"},
-						{"TYPE", evt.Type},
-						{"REASON", evt.Reason},
-						{"MESSAGE", evt.Message},
-					}
-
-					pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(eventsTable).Render()
-				}
-			}
-
-			pterm.Println()
-		}
-	}
-}
@@ -0,0 +1,158 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseComparison is how a release differs between two kube contexts:
+// its chart, its user-supplied values and each rendered resource, hooks
+// included. Diffs go from the first context to the second.
+type ReleaseComparison struct {
+	Release     string         `json:"release"`
+	Namespace   string         `json:"namespace"`
+	Contexts    [2]string      `json:"contexts"`
+	Charts      [2]string      `json:"charts"`
+	AppVersions [2]string      `json:"appVersions"`
+	Revisions   [2]int         `json:"revisions"`
+	ValuesDiff  string         `json:"valuesDiff,omitempty"`
+	Resources   []ResourceDiff `json:"resources"`
+}
+
+// Identical reports whether both contexts run the same chart version with
+// the same values and resources. Revision numbers may differ.
+func (c *ReleaseComparison) Identical() bool {
+	return c.Charts[0] == c.Charts[1] && c.AppVersions[0] == c.AppVersions[1] &&
+		c.ValuesDiff == "" && len(c.Resources) == 0
+}
+
+// CompareRelease fetches the deployed revision of releaseName in namespace
+// from the clusters of two kube contexts and compares them.
+func CompareRelease(releaseName, namespace string, contexts [2]string, useAI bool) (*ReleaseComparison, error) {
+	var rels [2]*release.Release
+	for i, kubeContext := range contexts {
+		rel, err := releaseInContext(releaseName, namespace, kubeContext)
+		if err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return nil, err
+		}
+		rels[i] = rel
+	}
+	c, err := compareReleases(rels[0], rels[1], contexts)
+	if err != nil {
+		return nil, err
+	}
+	c.Namespace = namespace
+	return c, nil
+}
+
+// releaseInContext returns the deployed revision of releaseName, or its
+// last one when none is deployed, from the cluster of kubeContext.
+func releaseInContext(releaseName, namespace, kubeContext string) (*release.Release, error) {
+	s := newSettings(namespace)
+	s.KubeContext = kubeContext
+
+	cfg := new(action.Configuration)
+	if err := cfg.Init(s.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		return nil, fmt.Errorf("helm init for context %s failed: %w", kubeContext, err)
+	}
+	rel, err := cfg.Releases.Deployed(releaseName)
+	if err != nil {
+		if rel, err = cfg.Releases.Last(releaseName); err != nil {
+			return nil, fmt.Errorf("release %s not found in namespace %s of context %s: %w", releaseName, namespace, kubeContext, err)
+		}
+	}
+	return rel, nil
+}
+
+func compareReleases(a, b *release.Release, contexts [2]string) (*ReleaseComparison, error) {
+	c := &ReleaseComparison{
+		Release:   a.Name,
+		Contexts:  contexts,
+		Charts:    [2]string{chartRef(a), chartRef(b)},
+		Revisions: [2]int{a.Version, b.Version},
+		Resources: []ResourceDiff{},
+	}
+	for i, rel := range []*release.Release{a, b} {
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			c.AppVersions[i] = rel.Chart.Metadata.AppVersion
+		}
+	}
+
+	registerSecretValues(a.Config)
+	registerSecretValues(b.Config)
+	configA, configB := redactValuesPair(a.Config, b.Config)
+	var values [2]string
+	for i, config := range []map[string]interface{}{configA, configB} {
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the values of %s in %s: %w", a.Name, contexts[i], err)
+		}
+		values[i] = string(data)
+	}
+	c.ValuesDiff = unifiedDiff(values[0], values[1], "values ("+contexts[0]+")", "values ("+contexts[1]+")")
+
+	c.Resources = diffManifests(releaseManifest(a), releaseManifest(b), contexts[0], contexts[1])
+	return c, nil
+}
+
+// releaseManifest is the manifest of rel with its hooks.
+func releaseManifest(rel *release.Release) string {
+	manifest := rel.Manifest
+	for _, hook := range rel.Hooks {
+		manifest += fmt.Sprintf("\n---\n# Source: %s\n%s", hook.Path, hook.Manifest)
+	}
+	return manifest
+}
+
+// PrintReleaseComparison prints c as colored diffs, or as JSON or YAML.
+func PrintReleaseComparison(c *ReleaseComparison, format string) error {
+	if isStructured(format) {
+		return printStructured(c, format)
+	}
+	pterm.DefaultSection.Printfln("%s in namespace %s: %s ↔ %s", c.Release, c.Namespace, c.Contexts[0], c.Contexts[1])
+	tableData := pterm.TableData{
+		{"", c.Contexts[0], c.Contexts[1]},
+		{"CHART", c.Charts[0], c.Charts[1]},
+		{"APP VERSION", c.AppVersions[0], c.AppVersions[1]},
+		{"REVISION", fmt.Sprint(c.Revisions[0]), fmt.Sprint(c.Revisions[1])},
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithData(tableData).Render(); err != nil {
+		return err
+	}
+	if c.Identical() {
+		pterm.Success.Printfln("%s is identical in %s and %s", c.Release, c.Contexts[0], c.Contexts[1])
+		return nil
+	}
+
+	if c.ValuesDiff != "" {
+		pterm.Println(pterm.Bold.Sprint("Values"))
+		printColoredDiff(c.ValuesDiff)
+	}
+	counts := map[string]int{}
+	for _, r := range c.Resources {
+		pterm.Println(pterm.Bold.Sprintf("%s (%s)", r.Resource, onlyIn(r.Change, c.Contexts)))
+		printColoredDiff(r.Diff)
+		counts[r.Change]++
+	}
+	pterm.Warning.Printfln("Resources: %d differ, %d only in %s, %d only in %s",
+		counts["changed"], counts["removed"], c.Contexts[0], counts["added"], c.Contexts[1])
+	return nil
+}
+
+// onlyIn describes a resource change between two contexts.
+func onlyIn(change string, contexts [2]string) string {
+	switch change {
+	case "removed":
+		return "only in " + contexts[0]
+	case "added":
+		return "only in " + contexts[1]
+	}
+	return "differs"
+}
@@ -0,0 +1,60 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestCompareReleases(t *testing.T) {
+	staging := testRevision(7, "1.3.0", map[string]interface{}{"replicaCount": 1},
+		"---\n# Source: web/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n")
+	staging.Hooks = []*release.Hook{{
+		Path:     "web/templates/migrate.yaml",
+		Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: web-migrate\n",
+	}}
+	production := testRevision(3, "1.2.0", map[string]interface{}{"replicaCount": 3},
+		"---\n# Source: web/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n---\n# Source: web/templates/pdb.yaml\napiVersion: policy/v1\nkind: PodDisruptionBudget\nmetadata:\n  name: web\n")
+
+	c, err := compareReleases(staging, production, [2]string{"staging", "production"})
+	if err != nil {
+		t.Fatalf("compareReleases: %v", err)
+	}
+	if c.Identical() {
+		t.Fatal("different releases compare as identical")
+	}
+	if c.Charts != [2]string{"web-1.3.0", "web-1.2.0"} || c.Revisions != [2]int{7, 3} {
+		t.Errorf("charts = %v, revisions = %v", c.Charts, c.Revisions)
+	}
+	if !strings.Contains(c.ValuesDiff, "-replicaCount: 1") || !strings.Contains(c.ValuesDiff, "+replicaCount: 3") {
+		t.Errorf("values diff missing replicaCount change:\n%s", c.ValuesDiff)
+	}
+	want := map[string]string{
+		"Deployment/web":          "changed",
+		"Job/web-migrate":         "removed",
+		"PodDisruptionBudget/web": "added",
+	}
+	if len(c.Resources) != len(want) {
+		t.Fatalf("resources = %+v, want %v", c.Resources, want)
+	}
+	for _, r := range c.Resources {
+		if want[r.Resource] != r.Change {
+			t.Errorf("%s: change = %q, want %q", r.Resource, r.Change, want[r.Resource])
+		}
+	}
+}
+
+func TestCompareReleasesIdentical(t *testing.T) {
+	manifest := "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"
+	values := map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}}
+
+	c, err := compareReleases(testRevision(4, "1.0.0", values, manifest), testRevision(9, "1.0.0", values, manifest),
+		[2]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("compareReleases: %v", err)
+	}
+	if !c.Identical() {
+		t.Errorf("releases differing only in revision are not identical: %+v", c)
+	}
+}
@@ -0,0 +1,359 @@
+package helm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const execAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+// clusterCredentials is what connecting to a managed cluster produces: the
+// endpoint and CA of the cluster and how to authenticate against it.
+type clusterCredentials struct {
+	Cluster  *clientcmdapi.Cluster
+	AuthInfo *clientcmdapi.AuthInfo
+}
+
+// ConnectCluster fetches the credentials of a managed EKS, GKE or AKS cluster
+// through the cloud provider's API, writes them as a context into the
+// kubeconfig smurf uses and makes it the current context. It returns the
+// name of the context.
+func ConnectCluster(target configs.ClusterConfig, namespace string) (string, error) {
+	if err := validateClusterConfig(target); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Fetching credentials for %s cluster %s...", target.Provider, target.Name))
+	var (
+		creds *clusterCredentials
+		err   error
+	)
+	switch target.Provider {
+	case "eks":
+		creds, err = eksCredentials(ctx, target)
+	case "gke":
+		creds, err = gkeCredentials(ctx, target)
+	case "aks":
+		creds, err = aksCredentials(ctx, target)
+	}
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to fetch credentials for cluster %s\n", target.Name))
+		return "", err
+	}
+	spinner.Success(fmt.Sprintf("Fetched credentials for cluster %s\n", target.Name))
+
+	contextName := target.Context
+	if contextName == "" {
+		contextName = defaultContextName(target)
+	}
+
+	// With a KUBECONFIG list, write to the first file, like kubectl does for
+	// new entries.
+	kubeconfigPath := filepath.SplitList(settings.KubeConfig)[0]
+	kubeconfig, err := loadKubeconfigForWrite(kubeconfigPath)
+	if err != nil {
+		return "", err
+	}
+	mergeClusterCredentials(kubeconfig, contextName, creds, namespace)
+
+	if err := os.MkdirAll(filepath.Dir(kubeconfigPath), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create kubeconfig directory: %w", err)
+	}
+	if err := clientcmd.WriteToFile(*kubeconfig, kubeconfigPath); err != nil {
+		return "", fmt.Errorf("failed to write kubeconfig %s: %w", kubeconfigPath, err)
+	}
+
+	pterm.Success.Printfln("Switched to context %q in %s", contextName, kubeconfigPath)
+	return contextName, nil
+}
+
+// validateClusterConfig checks that target names a supported provider and
+// carries the fields that provider needs to locate the cluster.
+func validateClusterConfig(target configs.ClusterConfig) error {
+	if target.Name == "" {
+		return errors.New("cluster name is required")
+	}
+
+	var missing []string
+	switch target.Provider {
+	case "eks":
+		if target.Region == "" {
+			missing = append(missing, "region")
+		}
+	case "gke":
+		if target.Project == "" {
+			missing = append(missing, "project")
+		}
+		if target.Region == "" {
+			missing = append(missing, "region (the cluster location)")
+		}
+	case "aks":
+		if target.ResourceGroup == "" {
+			missing = append(missing, "resource group")
+		}
+		if target.SubscriptionID == "" {
+			missing = append(missing, "subscription ID")
+		}
+	default:
+		return fmt.Errorf("unsupported cluster provider %q: must be one of eks, gke, aks", target.Provider)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s cluster %s is missing: %s", target.Provider, target.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// defaultContextName follows the naming of the cloud CLIs closely enough to
+// be recognisable, while staying unique per provider.
+func defaultContextName(target configs.ClusterConfig) string {
+	switch target.Provider {
+	case "eks":
+		return fmt.Sprintf("eks_%s_%s", target.Region, target.Name)
+	case "gke":
+		return fmt.Sprintf("gke_%s_%s_%s", target.Project, target.Region, target.Name)
+	default:
+		return fmt.Sprintf("aks_%s_%s", target.ResourceGroup, target.Name)
+	}
+}
+
+// loadKubeconfigForWrite loads the kubeconfig at path, or starts an empty
+// one if it doesn't exist yet.
+func loadKubeconfigForWrite(path string) (*clientcmdapi.Config, error) {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if os.IsNotExist(err) {
+		return clientcmdapi.NewConfig(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %w", path, err)
+	}
+	return kubeconfig, nil
+}
+
+// mergeClusterCredentials adds (or replaces) the cluster, user and context
+// named contextName in kubeconfig and makes it the current context.
+func mergeClusterCredentials(kubeconfig *clientcmdapi.Config, contextName string, creds *clusterCredentials, namespace string) {
+	kubeconfig.Clusters[contextName] = creds.Cluster
+	kubeconfig.AuthInfos[contextName] = creds.AuthInfo
+
+	kubeContext := clientcmdapi.NewContext()
+	kubeContext.Cluster = contextName
+	kubeContext.AuthInfo = contextName
+	kubeContext.Namespace = namespace
+	kubeconfig.Contexts[contextName] = kubeContext
+	kubeconfig.CurrentContext = contextName
+}
+
+// eksCredentials describes the EKS cluster and authenticates through
+// `aws eks get-token`, the same exec plugin `aws eks update-kubeconfig` sets up.
+func eksCredentials(ctx context.Context, target configs.ClusterConfig) (*clusterCredentials, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(target.Region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	out, err := eks.New(sess).DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(target.Name)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe EKS cluster %s: %w", target.Name, err)
+	}
+	if out.Cluster == nil || out.Cluster.Endpoint == nil || out.Cluster.CertificateAuthority == nil || out.Cluster.CertificateAuthority.Data == nil {
+		return nil, fmt.Errorf("EKS cluster %s has no endpoint yet; is it still being created?", target.Name)
+	}
+
+	ca, err := base64.StdEncoding.DecodeString(*out.Cluster.CertificateAuthority.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate authority for EKS cluster %s: %w", target.Name, err)
+	}
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = *out.Cluster.Endpoint
+	cluster.CertificateAuthorityData = ca
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Exec = &clientcmdapi.ExecConfig{
+		APIVersion:      execAPIVersion,
+		Command:         "aws",
+		Args:            []string{"eks", "get-token", "--cluster-name", target.Name, "--region", target.Region},
+		InstallHint:     "Install the AWS CLI to authenticate to EKS clusters: https://aws.amazon.com/cli/",
+		InteractiveMode: clientcmdapi.IfAvailableExecInteractiveMode,
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		authInfo.Exec.Env = []clientcmdapi.ExecEnvVar{{Name: "AWS_PROFILE", Value: profile}}
+	}
+
+	return &clusterCredentials{Cluster: cluster, AuthInfo: authInfo}, nil
+}
+
+// gkeCluster is the part of the GKE clusters.get response smurf needs.
+type gkeCluster struct {
+	Endpoint   string `json:"endpoint"`
+	MasterAuth struct {
+		ClusterCaCertificate string `json:"clusterCaCertificate"`
+	} `json:"masterAuth"`
+}
+
+// gkeCredentials reads the GKE cluster with Application Default Credentials
+// and authenticates through gke-gcloud-auth-plugin, as gcloud does.
+func gkeCredentials(ctx context.Context, target configs.ClusterConfig) (*clusterCredentials, error) {
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Google application default credentials: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s",
+		url.PathEscape(target.Project), url.PathEscape(target.Region), url.PathEscape(target.Name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := oauth2.NewClient(ctx, creds.TokenSource).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GKE cluster %s: %w", target.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get GKE cluster %s: %s: %s", target.Name, resp.Status, body)
+	}
+
+	var gke gkeCluster
+	if err := json.Unmarshal(body, &gke); err != nil {
+		return nil, fmt.Errorf("failed to parse GKE cluster %s: %w", target.Name, err)
+	}
+	if gke.Endpoint == "" {
+		return nil, fmt.Errorf("GKE cluster %s has no endpoint yet; is it still being created?", target.Name)
+	}
+	ca, err := base64.StdEncoding.DecodeString(gke.MasterAuth.ClusterCaCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate authority for GKE cluster %s: %w", target.Name, err)
+	}
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = "https://" + gke.Endpoint
+	cluster.CertificateAuthorityData = ca
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Exec = &clientcmdapi.ExecConfig{
+		APIVersion:         execAPIVersion,
+		Command:            "gke-gcloud-auth-plugin",
+		InstallHint:        "Install gke-gcloud-auth-plugin to authenticate to GKE clusters: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-access-for-kubectl#install_plugin",
+		ProvideClusterInfo: true,
+		InteractiveMode:    clientcmdapi.IfAvailableExecInteractiveMode,
+	}
+
+	return &clusterCredentials{Cluster: cluster, AuthInfo: authInfo}, nil
+}
+
+// aksCredentialResults is the response of the AKS listClusterUserCredential
+// action: one or more base64 encoded kubeconfigs.
+type aksCredentialResults struct {
+	Kubeconfigs []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"kubeconfigs"`
+}
+
+// aksCredentials fetches the cluster user kubeconfig of an AKS cluster with
+// the Azure default credential chain, as `az aks get-credentials` does.
+func aksCredentials(ctx context.Context, target configs.ClusterConfig) (*clusterCredentials, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate with Azure: %w", err)
+	}
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure management token: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("https://management.azure.com/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/listClusterUserCredential?api-version=2024-02-01",
+		url.PathEscape(target.SubscriptionID), url.PathEscape(target.ResourceGroup), url.PathEscape(target.Name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AKS credentials for %s: %w", target.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get AKS credentials for %s: %s: %s", target.Name, resp.Status, body)
+	}
+
+	return parseAKSCredentials(body)
+}
+
+// parseAKSCredentials extracts the cluster and user of the first kubeconfig
+// in an AKS credential response.
+func parseAKSCredentials(body []byte) (*clusterCredentials, error) {
+	var results aksCredentialResults
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse AKS credentials: %w", err)
+	}
+	if len(results.Kubeconfigs) == 0 {
+		return nil, errors.New("AKS returned no kubeconfig")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(results.Kubeconfigs[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AKS kubeconfig: %w", err)
+	}
+	kubeconfig, err := clientcmd.Load(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AKS kubeconfig: %w", err)
+	}
+
+	kubeContext, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if !ok {
+		return nil, errors.New("AKS kubeconfig has no current context")
+	}
+	cluster, ok := kubeconfig.Clusters[kubeContext.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("AKS kubeconfig has no cluster %q", kubeContext.Cluster)
+	}
+	authInfo, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("AKS kubeconfig has no user %q", kubeContext.AuthInfo)
+	}
+
+	return &clusterCredentials{Cluster: cluster, AuthInfo: authInfo}, nil
+}
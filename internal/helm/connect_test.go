@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestValidateClusterConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		target  configs.ClusterConfig
+		wantErr string
+	}{
+		{"eks ok", configs.ClusterConfig{Provider: "eks", Name: "c", Region: "us-east-1"}, ""},
+		{"eks no region", configs.ClusterConfig{Provider: "eks", Name: "c"}, "region"},
+		{"gke no project", configs.ClusterConfig{Provider: "gke", Name: "c", Region: "europe-west1"}, "project"},
+		{"aks ok", configs.ClusterConfig{Provider: "aks", Name: "c", ResourceGroup: "rg", SubscriptionID: "sub"}, ""},
+		{"aks no subscription", configs.ClusterConfig{Provider: "aks", Name: "c", ResourceGroup: "rg"}, "subscription ID"},
+		{"unknown provider", configs.ClusterConfig{Provider: "doks", Name: "c"}, "unsupported"},
+		{"no name", configs.ClusterConfig{Provider: "eks", Region: "us-east-1"}, "name is required"},
+	}
+	for _, tc := range cases {
+		err := validateClusterConfig(tc.target)
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: error = %v, want it to mention %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestMergeClusterCredentials(t *testing.T) {
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Contexts["existing"] = clientcmdapi.NewContext()
+	kubeconfig.CurrentContext = "existing"
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = "https://example.eks.amazonaws.com"
+	creds := &clusterCredentials{Cluster: cluster, AuthInfo: clientcmdapi.NewAuthInfo()}
+
+	name := defaultContextName(configs.ClusterConfig{Provider: "eks", Name: "prod", Region: "us-east-1"})
+	mergeClusterCredentials(kubeconfig, name, creds, "apps")
+
+	if kubeconfig.CurrentContext != "eks_us-east-1_prod" {
+		t.Fatalf("current context = %q, want eks_us-east-1_prod", kubeconfig.CurrentContext)
+	}
+	ctx := kubeconfig.Contexts[name]
+	if ctx.Cluster != name || ctx.AuthInfo != name || ctx.Namespace != "apps" {
+		t.Errorf("context = %+v, want cluster/user %q in namespace apps", ctx, name)
+	}
+	if kubeconfig.Clusters[name].Server != cluster.Server {
+		t.Errorf("cluster server = %q", kubeconfig.Clusters[name].Server)
+	}
+	if _, ok := kubeconfig.Contexts["existing"]; !ok {
+		t.Error("existing contexts must be kept")
+	}
+}
+
+func TestParseAKSCredentials(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: aks-prod
+  cluster:
+    server: https://aks-prod.hcp.westeurope.azmk8s.io:443
+contexts:
+- name: aks-prod
+  context:
+    cluster: aks-prod
+    user: clusterUser_rg_aks-prod
+current-context: aks-prod
+users:
+- name: clusterUser_rg_aks-prod
+  user:
+    token: secret
+`
+	body, _ := json.Marshal(map[string]any{
+		"kubeconfigs": []map[string]string{{"name": "clusterUser", "value": base64.StdEncoding.EncodeToString([]byte(kubeconfig))}},
+	})
+
+	creds, err := parseAKSCredentials(body)
+	if err != nil {
+		t.Fatalf("parseAKSCredentials: %v", err)
+	}
+	if creds.Cluster.Server != "https://aks-prod.hcp.westeurope.azmk8s.io:443" || creds.AuthInfo.Token != "secret" {
+		t.Errorf("creds = %+v / %+v", creds.Cluster, creds.AuthInfo)
+	}
+
+	if _, err := parseAKSCredentials([]byte(`{"kubeconfigs":[]}`)); err == nil {
+		t.Error("expected an error for an empty credential list")
+	}
+}
@@ -0,0 +1,142 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// ReleaseDiff is what upgrading a release to a chart and values would change
+// in its manifest, resource by resource. Hooks are included.
+type ReleaseDiff struct {
+	Release   string         `json:"release"`
+	Namespace string         `json:"namespace"`
+	Revision  int            `json:"revision"` // deployed revision; 0 when the release doesn't exist
+	Chart     [2]string      `json:"chart"`    // deployed and new
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// Empty reports whether the upgrade leaves every resource as it is.
+func (d *ReleaseDiff) Empty() bool {
+	return len(d.Resources) == 0
+}
+
+// DiffRelease renders chartRef with the merged values as an upgrade of
+// releaseName would, without changing anything, and compares the result
+// with the manifest of the deployed revision. A release that doesn't exist
+// yet is compared with nothing, so every resource shows as added.
+func DiffRelease(releaseName, chartRef, namespace string, valuesFiles, setValues, setLiteral []string,
+	repoURL, version string, debug, useAI bool) (*ReleaseDiff, error) {
+	actionConfig, err := initActionConfig(namespace, debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to initialize helm: %w", err)
+	}
+
+	chart, err := loadChart(chartRef, repoURL, version, debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+	vals, _, err := loadValueLayers(namespace, valuesFiles, setValues, setLiteral, debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to load values: %w", err)
+	}
+	registerSecretValues(vals)
+
+	current, err := actionConfig.Releases.Deployed(releaseName)
+	if err != nil {
+		current, err = actionConfig.Releases.Last(releaseName)
+	}
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to get release %s: %w", releaseName, err)
+	}
+	if current != nil && configs.MigrateValues {
+		migration, err := migrateForChart(current, chart)
+		if err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return nil, err
+		}
+		vals = mergeValues(migration.Values, vals, nil, configs.ListMergePaths)
+	}
+
+	var rendered *release.Release
+	if current == nil {
+		client := action.NewInstall(actionConfig)
+		client.ReleaseName = releaseName
+		client.Namespace = namespace
+		client.DryRun = true
+		client.DryRunOption = "server"
+		client.PostRenderer = schedulingRenderer()
+		rendered, err = client.Run(chart, vals)
+	} else {
+		registerSecretValues(current.Config)
+		client := action.NewUpgrade(actionConfig)
+		client.Namespace = namespace
+		client.DryRun = true
+		client.DryRunOption = "server"
+		client.PostRenderer = schedulingRenderer()
+		rendered, err = client.Run(releaseName, chart, vals)
+	}
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to render %s: %w", chartRef, err)
+	}
+
+	return diffRelease(current, rendered), nil
+}
+
+// diffRelease compares the deployed release current, or nil, with the
+// dry-run release rendered.
+func diffRelease(current, rendered *release.Release) *ReleaseDiff {
+	d := &ReleaseDiff{
+		Release:   rendered.Name,
+		Namespace: rendered.Namespace,
+		Chart:     [2]string{"", chartRef(rendered)},
+	}
+	before, from := "", "(not installed)"
+	if current != nil {
+		d.Revision = current.Version
+		d.Chart[0] = chartRef(current)
+		before, from = releaseManifest(current), fmt.Sprintf("revision %d", current.Version)
+	}
+	d.Resources = diffManifests(before, releaseManifest(rendered), from, "upgrade")
+	return d
+}
+
+// PrintReleaseDiff prints d as one colored unified diff per resource, or as
+// JSON or YAML.
+func PrintReleaseDiff(d *ReleaseDiff, format string) error {
+	if isStructured(format) {
+		return printStructured(d, format)
+	}
+	if d.Revision == 0 {
+		pterm.DefaultSection.Printfln("%s in namespace %s is not installed; %s would install", d.Release, d.Namespace, d.Chart[1])
+	} else {
+		pterm.DefaultSection.Printfln("Upgrade of %s in namespace %s from revision %d", d.Release, d.Namespace, d.Revision)
+		if d.Chart[0] != d.Chart[1] {
+			pterm.Info.Printfln("Chart: %s → %s", d.Chart[0], d.Chart[1])
+		}
+	}
+	if d.Empty() {
+		pterm.Success.Println("The upgrade doesn't change any resource.")
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, r := range d.Resources {
+		pterm.Println(pterm.Bold.Sprintf("%s (%s)", r.Resource, r.Change))
+		printColoredDiff(r.Diff)
+		counts[r.Change]++
+	}
+	pterm.Info.Printfln("Resources: %d changed, %d added, %d removed", counts["changed"], counts["added"], counts["removed"])
+	return nil
+}
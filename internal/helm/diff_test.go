@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const diffDeployedManifest = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+`
+
+const diffRenderedManifest = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/ingress.yaml
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+`
+
+func TestDiffRelease(t *testing.T) {
+	webChart := func(version string) *chart.Chart {
+		return &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: version}}
+	}
+	current := &release.Release{Name: "web", Namespace: "apps", Version: 4, Chart: webChart("1.0.0"), Manifest: diffDeployedManifest}
+	rendered := &release.Release{Name: "web", Namespace: "apps", Version: 5, Chart: webChart("1.1.0"), Manifest: diffRenderedManifest,
+		Hooks: []*release.Hook{{Path: "web/templates/migrate.yaml", Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n"}}}
+
+	d := diffRelease(current, rendered)
+	if d.Revision != 4 || d.Chart != [2]string{"web-1.0.0", "web-1.1.0"} {
+		t.Errorf("diff header = %+v", d)
+	}
+	var got []string
+	for _, r := range d.Resources {
+		got = append(got, r.Resource+" "+r.Change)
+	}
+	want := "CronJob/cleanup removed,Deployment/web changed,Ingress/web added,Job/migrate added"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("resources = %v, want %s", got, want)
+	}
+	if diff := d.Resources[1].Diff; !strings.Contains(diff, "--- revision 4") || !strings.Contains(diff, "-  replicas: 2\n+  replicas: 3") {
+		t.Errorf("Deployment diff =\n%s", diff)
+	}
+
+	fresh := diffRelease(nil, rendered)
+	if fresh.Revision != 0 || len(fresh.Resources) != 4 {
+		t.Errorf("diff of a release that isn't installed = %+v, want every resource added", fresh)
+	}
+	for _, r := range fresh.Resources {
+		if r.Change != "added" {
+			t.Errorf("%s is %s, want added", r.Resource, r.Change)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package helm
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultEphemeralTTL is how long an --ephemeral release lives without --ttl.
+const DefaultEphemeralTTL = 2 * time.Hour
+
+// Labels of the release record of an ephemeral release, which selm gc
+// selects and reads.
+const (
+	ephemeralLabel     = "smurf.clouddrove.com/ephemeral"
+	expiresLabel       = "smurf.clouddrove.com/expires" // Unix seconds
+	ttlLabel           = "smurf.clouddrove.com/ttl"
+	ownsNamespaceLabel = "smurf.clouddrove.com/owns-namespace"
+)
+
+// maxReleaseNameLen is the longest release name Helm accepts.
+const maxReleaseNameLen = 53
+
+var releaseNameUnsafe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// GenerateReleaseName returns base with a random suffix, e.g. my-app-x7k2q,
+// so every CI run gets a release of its own.
+func GenerateReleaseName(base string) string {
+	base = strings.Trim(releaseNameUnsafe.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if base == "" {
+		base = "release"
+	}
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	suffix := make([]byte, 5)
+	_, _ = rand.Read(suffix)
+	for i, b := range suffix {
+		suffix[i] = alphabet[int(b)%len(alphabet)]
+	}
+	if max := maxReleaseNameLen - len(suffix) - 1; len(base) > max {
+		base = strings.TrimRight(base[:max], "-")
+	}
+	return base + "-" + string(suffix)
+}
+
+// ephemeralLabels are the release labels that make selm gc uninstall the
+// release once ttl has passed since now, and delete its namespace too when
+// the install created it.
+func ephemeralLabels(ttl time.Duration, now time.Time, ownsNamespace bool) map[string]string {
+	labels := map[string]string{
+		ephemeralLabel: "true",
+		expiresLabel:   strconv.FormatInt(now.Add(ttl).Unix(), 10),
+		ttlLabel:       ttl.String(),
+	}
+	if ownsNamespace {
+		labels[ownsNamespaceLabel] = "true"
+	}
+	return labels
+}
+
+// namespaceMissing reports whether namespace does not exist yet. It is
+// false when that can't be told, so gc never deletes a namespace it did not
+// see created.
+func namespaceMissing(namespace string) bool {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return false
+	}
+	_, err = clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+	return apierrors.IsNotFound(err)
+}
+
+// EphemeralRelease is a release installed with --ephemeral.
+type EphemeralRelease struct {
+	Name          string    `json:"name"`
+	Namespace     string    `json:"namespace"`
+	Status        string    `json:"status"`
+	Expires       time.Time `json:"expires"`
+	Expired       bool      `json:"expired"`
+	OwnsNamespace bool      `json:"ownsNamespace"`
+}
+
+// FindEphemeralReleases lists the ephemeral releases of namespace, or of
+// every namespace when it is empty, sorted by expiry.
+func FindEphemeralReleases(namespace string, now time.Time, useAI bool) ([]EphemeralRelease, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("helm init failed: %w", err)
+	}
+	client := action.NewList(cfg)
+	client.AllNamespaces = namespace == ""
+	client.StateMask = action.ListAll
+	client.Selector = ephemeralLabel + "=true"
+	rels, err := client.Run()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("release listing failed: %w", err)
+	}
+	return ephemeralReleases(rels, now), nil
+}
+
+// ephemeralReleases reads the expiry of each ephemeral release of rels. A
+// release whose expiry can't be read counts as expired, so nothing lingers.
+func ephemeralReleases(rels []*release.Release, now time.Time) []EphemeralRelease {
+	var out []EphemeralRelease
+	for _, rel := range rels {
+		if rel.Labels[ephemeralLabel] != "true" {
+			continue
+		}
+		e := EphemeralRelease{
+			Name:          rel.Name,
+			Namespace:     rel.Namespace,
+			OwnsNamespace: rel.Labels[ownsNamespaceLabel] == "true",
+		}
+		if rel.Info != nil {
+			e.Status = rel.Info.Status.String()
+		}
+		if sec, err := strconv.ParseInt(rel.Labels[expiresLabel], 10, 64); err == nil {
+			e.Expires = time.Unix(sec, 0).UTC()
+		}
+		e.Expired = !now.Before(e.Expires)
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Expires.Equal(out[j].Expires) {
+			return out[i].Expires.Before(out[j].Expires)
+		}
+		return out[i].Namespace+"/"+out[i].Name < out[j].Namespace+"/"+out[j].Name
+	})
+	return out
+}
+
+// PrintEphemeralReleases prints rels as a table, or as JSON or YAML.
+func PrintEphemeralReleases(rels []EphemeralRelease, format string, now time.Time) error {
+	if isStructured(format) {
+		if rels == nil {
+			rels = []EphemeralRelease{}
+		}
+		return printStructured(rels, format)
+	}
+	if len(rels) == 0 {
+		pterm.Info.Println("No ephemeral releases found")
+		return nil
+	}
+	tableData := pterm.TableData{{"NAMESPACE", "RELEASE", "STATUS", "EXPIRES", ""}}
+	for _, r := range rels {
+		state := "expires in " + r.Expires.Sub(now).Round(time.Minute).String()
+		if r.Expired {
+			state = "expired"
+		}
+		tableData = append(tableData, []string{r.Namespace, r.Name, r.Status, r.Expires.Local().Format(time.RFC3339), state})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// CollectEphemeralReleases uninstalls the expired releases of rels, and
+// deletes the namespaces their installs created once no release is left in
+// them. It goes on past a failure and returns the first.
+func CollectEphemeralReleases(rels []EphemeralRelease, timeout time.Duration, useAI bool) error {
+	var firstErr error
+	namespaces := map[string]bool{}
+	for _, r := range rels {
+		if !r.Expired {
+			continue
+		}
+		err := HelmUninstall(UninstallOptions{ReleaseName: r.Name, Namespace: r.Namespace, Timeout: timeout}, useAI)
+		if err != nil {
+			pterm.Error.Printfln("Failed to uninstall %s/%s: %v", r.Namespace, r.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if r.OwnsNamespace {
+			namespaces[r.Namespace] = true
+		}
+	}
+	for namespace := range namespaces {
+		if err := deleteEmptyNamespace(namespace); err != nil {
+			pterm.Error.Printfln("Failed to delete namespace %s: %v", namespace, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// deleteEmptyNamespace deletes namespace unless a release is still in it.
+func deleteEmptyNamespace(namespace string) error {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		return fmt.Errorf("helm init failed: %w", err)
+	}
+	client := action.NewList(cfg)
+	client.StateMask = action.ListAll
+	rels, err := client.Run()
+	if err != nil {
+		return fmt.Errorf("release listing failed: %w", err)
+	}
+	if len(rels) > 0 {
+		pterm.Info.Printfln("Keeping namespace %s: %d release(s) still in it", namespace, len(rels))
+		return nil
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return err
+	}
+	err = clientset.CoreV1().Namespaces().Delete(context.Background(), namespace, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	pterm.Success.Printfln("Deleted namespace %s", namespace)
+	return nil
+}
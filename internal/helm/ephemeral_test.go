@@ -0,0 +1,68 @@
+package helm
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestGenerateReleaseName(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	tests := map[string]string{
+		"my-app":                 "my-app-",
+		"My_App":                 "my-app-",
+		"":                       "release-",
+		strings.Repeat("a", 60):  strings.Repeat("a", 47) + "-",
+		"chart-" + "x.y.z" + "-": "chart-x-y-z-",
+	}
+	for base, prefix := range tests {
+		name := GenerateReleaseName(base)
+		if !strings.HasPrefix(name, prefix) || len(name) != len(prefix)+5 {
+			t.Errorf("GenerateReleaseName(%q) = %q, want %q and a 5 character suffix", base, name, prefix)
+		}
+		if len(name) > maxReleaseNameLen || !valid.MatchString(name) {
+			t.Errorf("GenerateReleaseName(%q) = %q, not a valid release name", base, name)
+		}
+	}
+	if GenerateReleaseName("app") == GenerateReleaseName("app") {
+		t.Error("two generated names are equal")
+	}
+}
+
+func TestEphemeralReleases(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	rel := func(name string, labels map[string]string) *release.Release {
+		return &release.Release{Name: name, Namespace: "ci", Labels: labels,
+			Info: &release.Info{Status: release.StatusDeployed}}
+	}
+	rels := []*release.Release{
+		rel("fresh", ephemeralLabels(2*time.Hour, now.Add(-time.Hour), false)),
+		rel("stale", ephemeralLabels(2*time.Hour, now.Add(-3*time.Hour), true)),
+		rel("permanent", nil),
+		rel("broken", map[string]string{ephemeralLabel: "true", expiresLabel: "soon"}),
+	}
+
+	got := ephemeralReleases(rels, now)
+	if len(got) != 3 {
+		t.Fatalf("got %d ephemeral releases, want 3: %+v", len(got), got)
+	}
+	want := []struct {
+		name          string
+		expired, owns bool
+	}{
+		{"broken", true, false},
+		{"stale", true, true},
+		{"fresh", false, false},
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Expired != w.expired || got[i].OwnsNamespace != w.owns {
+			t.Errorf("release %d = %+v, want %s expired=%v ownsNamespace=%v", i, got[i], w.name, w.expired, w.owns)
+		}
+	}
+	if !got[2].Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("fresh expires %s, want %s", got[2].Expires, now.Add(time.Hour))
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/clouddrove/smurf/configs"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/release"
 	appsv1 "k8s.io/api/apps/v1"
@@ -82,13 +84,17 @@ type ConfigMapInfo struct {
 
 // ResourceChecker provides generic resource checking functionality
 type ResourceChecker struct {
-	clientset   *kubernetes.Clientset
+	clientset   kubernetes.Interface
 	namespace   string
 	releaseName string
 	debug       bool
+
+	// cache holds the list results of the current tick; see beginTick.
+	mu    sync.Mutex
+	cache map[string]*cachedList
 }
 
-func NewResourceChecker(clientset *kubernetes.Clientset, namespace, releaseName string, debug bool) *ResourceChecker {
+func NewResourceChecker(clientset kubernetes.Interface, namespace, releaseName string, debug bool) *ResourceChecker {
 	return &ResourceChecker{
 		clientset:   clientset,
 		namespace:   namespace,
@@ -98,7 +104,7 @@ func NewResourceChecker(clientset *kubernetes.Clientset, namespace, releaseName
 }
 
 // getPodFailureReason extracts the detailed reason for pod failure
-func getPodFailureReason(ctx context.Context, clientset *kubernetes.Clientset, pod *corev1.Pod) string {
+func getPodFailureReason(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod) string {
 	// Check container statuses first
 	for _, status := range pod.Status.ContainerStatuses {
 		if status.State.Waiting != nil {
@@ -675,7 +681,7 @@ func (r *ResourceChecker) checkResourceHealth(
 		fmt.Printf("🔍 Checking %s\n", resourceType)
 	}
 
-	resources, err := listFunc(ctx)
+	resources, err := r.cachedList(ctx, resourceType, listFunc)
 	if err != nil {
 		return false, fmt.Errorf("failed to list %s: %w", resourceType, err)
 	}
@@ -918,14 +924,16 @@ func verifyInstallationHealth(namespace, releaseName string, timeout time.Durati
 
 	checker := NewResourceChecker(clientset, namespace, releaseName, debug)
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// Each kind waits for its own progress deadline, so the install timeout
+	// is stretched to cover the slowest one.
+	deadlines := newProgressDeadlines(configs.Timeouts.ReadinessTimeout())
+	ctx, cancel := context.WithTimeout(context.Background(), max(timeout, deadlines.longest()))
 	defer cancel()
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	startTime := time.Now()
-	maxWaitTime := 10 * time.Minute // Maximum wait time for resources to become healthy
 
 	if debug {
 		fmt.Printf("🔍 Starting comprehensive health verification for release '%s'\n", releaseName)
@@ -937,90 +945,30 @@ func verifyInstallationHealth(namespace, releaseName string, timeout time.Durati
 			return checkFinalHealthStatus(clientset, namespace, releaseName, startTime, debug)
 		case <-ticker.C:
 			// Check all resource types
-			allHealthy, err := checkAllResourcesHealthy(checker)
+			notReady, err := checkResourcesByKind(checker)
 			if err != nil {
 				return err
 			}
-			if allHealthy {
+			if len(notReady) == 0 {
 				if debug {
 					fmt.Printf("✅ All resources are healthy!\n")
 				}
 				return nil
 			}
 
-			// Check if we've been waiting too long
-			if time.Since(startTime) > maxWaitTime {
-				return checkFinalHealthStatus(clientset, namespace, releaseName, startTime, debug)
+			// Check if a kind has been waiting longer than its deadline
+			if kind, ok := deadlines.overdue(notReady, time.Since(startTime)); ok {
+				return fmt.Errorf("%s resources exceeded their progress deadline of %v: %w",
+					kind, deadlines[kind], checkFinalHealthStatus(clientset, namespace, releaseName, startTime, debug))
 			}
 
 			if debug {
-				fmt.Printf("🔍 Still waiting for resources to become healthy... (%v elapsed)\n", time.Since(startTime).Round(time.Second))
+				fmt.Printf("🔍 Still waiting for %s to become healthy... (%v elapsed)\n", strings.Join(notReady, ", "), time.Since(startTime).Round(time.Second))
 			}
 		}
 	}
 }
 
-// checkAllResourcesHealthy checks all resource types for health using the ResourceChecker.
-// Each round of checks gets its own bounded timeout, independent of the overall
-// polling deadline in verifyInstallationHealth, so a slow API server can't wedge a
-// single tick forever.
-func checkAllResourcesHealthy(checker *ResourceChecker) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Check Deployments
-	deploymentsHealthy, err := checker.checkDeploymentsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !deploymentsHealthy {
-		return false, nil
-	}
-
-	// Check StatefulSets
-	statefulSetsHealthy, err := checker.checkStatefulSetsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !statefulSetsHealthy {
-		return false, nil
-	}
-
-	// Check DaemonSets
-	daemonSetsHealthy, err := checker.checkDaemonSetsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !daemonSetsHealthy {
-		return false, nil
-	}
-
-	// Check Jobs
-	jobsHealthy, err := checker.checkJobsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !jobsHealthy {
-		return false, nil
-	}
-
-	// Check CronJobs
-	cronJobsHealthy, err := checker.checkCronJobsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !cronJobsHealthy {
-		return false, nil
-	}
-
-	// Check Pods (as a final verification)
-	podsHealthy, err := checker.checkPodsHealthy(ctx)
-	if err != nil {
-		return false, err
-	}
-	return podsHealthy, nil
-}
-
 // getPodReadyStatus returns a string describing the pod's ready status
 func getPodReadyStatus(pod *corev1.Pod) string {
 	readyContainers := 0
@@ -0,0 +1,192 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const releaseEventPollInterval = 2 * time.Second
+
+// releaseEventWatcher prints the Warning events of a release's objects while
+// an install or upgrade runs, so image pull back-offs, failed mounts and
+// probe failures show up as they happen rather than only in the failure
+// diagnostics.
+type releaseEventWatcher struct {
+	clientset   kubernetes.Interface
+	namespace   string
+	releaseName string
+	since       time.Time
+	debug       bool
+
+	// printed maps an event to the count it was last printed with, so a
+	// repeated event is printed again only when it recurs.
+	printed map[string]int32
+	// podOfRelease caches whether a pod, matched by name, belongs to the release.
+	podOfRelease map[string]bool
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// startReleaseEventWatcher starts streaming the Warning events of release in
+// namespace from now on. It returns nil, after a debug note, when no
+// Kubernetes client is available; stop is safe to call on nil.
+func startReleaseEventWatcher(namespace, releaseName string, debug bool) *releaseEventWatcher {
+	clientset, err := getKubeClient()
+	if err != nil {
+		if debug {
+			pterm.Warning.Printf("Could not start event watcher: %v\n", err)
+		}
+		return nil
+	}
+
+	w := &releaseEventWatcher{
+		clientset:    clientset,
+		namespace:    namespace,
+		releaseName:  releaseName,
+		since:        time.Now().Truncate(time.Second), // event timestamps have second precision
+		debug:        debug,
+		printed:      make(map[string]int32),
+		podOfRelease: make(map[string]bool),
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *releaseEventWatcher) run() {
+	defer close(w.doneCh)
+
+	ticker := time.NewTicker(releaseEventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			w.poll()
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// stop prints the events that arrived since the last poll and stops the
+// watcher.
+func (w *releaseEventWatcher) stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	<-w.doneCh
+}
+
+func (w *releaseEventWatcher) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := w.clientset.CoreV1().Events(w.namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + corev1.EventTypeWarning,
+	})
+	if err != nil {
+		if w.debug {
+			pterm.Debug.Printf("event watcher: failed to list events: %v\n", err)
+		}
+		return
+	}
+
+	fresh := newReleaseWarnings(events.Items, w.since, w.printed)
+	for _, e := range fresh {
+		if !w.isReleaseObject(ctx, e.InvolvedObject) {
+			continue
+		}
+		w.printed[eventKey(e)] = eventCount(e)
+		pterm.Warning.Println(formatReleaseEvent(e))
+	}
+}
+
+// isReleaseObject reports whether the object an event is about belongs to
+// the release: its name carries the release name, as chart resources and
+// the ReplicaSets and Pods they create do, or it is a pod labeled with it.
+func (w *releaseEventWatcher) isReleaseObject(ctx context.Context, obj corev1.ObjectReference) bool {
+	if strings.Contains(strings.ToLower(obj.Name), strings.ToLower(w.releaseName)) {
+		return true
+	}
+	if obj.Kind != "Pod" {
+		return false
+	}
+	if match, ok := w.podOfRelease[obj.Name]; ok {
+		return match
+	}
+	pod, err := w.clientset.CoreV1().Pods(w.namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+	match := err == nil && isPodFromRelease(*pod, w.releaseName)
+	w.podOfRelease[obj.Name] = match
+	return match
+}
+
+// newReleaseWarnings returns the events last seen at or after since that
+// were not printed yet, or have recurred since they were, oldest first.
+func newReleaseWarnings(events []corev1.Event, since time.Time, printed map[string]int32) []corev1.Event {
+	var fresh []corev1.Event
+	for _, e := range events {
+		if e.Type != corev1.EventTypeWarning || lastSeen(e).Before(since) {
+			continue
+		}
+		if count, ok := printed[eventKey(e)]; ok && eventCount(e) <= count {
+			continue
+		}
+		fresh = append(fresh, e)
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return lastSeen(fresh[i]).Before(lastSeen(fresh[j]))
+	})
+	return fresh
+}
+
+// lastSeen is when an event last occurred; newer reporters only set
+// EventTime or the series.
+func lastSeen(e corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+func eventCount(e corev1.Event) int32 {
+	if e.Series != nil && e.Series.Count > e.Count {
+		return e.Series.Count
+	}
+	return e.Count
+}
+
+func eventKey(e corev1.Event) string {
+	if e.UID != "" {
+		return string(e.UID)
+	}
+	return e.Namespace + "/" + e.Name
+}
+
+// formatReleaseEvent renders an event as one log line, e.g.
+// "[BackOff] Pod/web-5d9c-x7k2p: Back-off pulling image "web:v2" (x3)".
+func formatReleaseEvent(e corev1.Event) string {
+	line := fmt.Sprintf("[%s] %s/%s: %s", e.Reason, e.InvolvedObject.Kind, e.InvolvedObject.Name, strings.TrimSpace(e.Message))
+	if n := eventCount(e); n > 1 {
+		line += fmt.Sprintf(" (x%d)", n)
+	}
+	return line
+}
@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func warningEvent(uid, reason, kind, name string, count int32, at time.Time) corev1.Event {
+	return corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: types.UID(uid), Namespace: "apps"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Message:        reason + " happened",
+		Count:          count,
+		LastTimestamp:  metav1.NewTime(at),
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name},
+	}
+}
+
+func TestNewReleaseWarnings(t *testing.T) {
+	since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []corev1.Event{
+		warningEvent("late", "BackOff", "Pod", "web-1", 1, since.Add(20*time.Second)),
+		warningEvent("old", "FailedMount", "Pod", "web-1", 1, since.Add(-time.Minute)),
+		warningEvent("early", "Unhealthy", "Pod", "web-1", 1, since.Add(5*time.Second)),
+		warningEvent("printed", "FailedScheduling", "Pod", "web-2", 2, since.Add(10*time.Second)),
+		warningEvent("recurred", "BackOff", "Pod", "web-3", 4, since.Add(15*time.Second)),
+	}
+	printed := map[string]int32{"printed": 2, "recurred": 3}
+
+	got := newReleaseWarnings(events, since, printed)
+	var uids []string
+	for _, e := range got {
+		uids = append(uids, string(e.UID))
+	}
+	want := []string{"early", "recurred", "late"}
+	if len(uids) != len(want) {
+		t.Fatalf("new warnings = %v, want %v", uids, want)
+	}
+	for i := range want {
+		if uids[i] != want[i] {
+			t.Errorf("new warnings = %v, want %v", uids, want)
+			break
+		}
+	}
+}
+
+func TestFormatReleaseEvent(t *testing.T) {
+	e := warningEvent("a", "BackOff", "Pod", "web-1", 3, time.Now())
+	if got, want := formatReleaseEvent(e), "[BackOff] Pod/web-1: BackOff happened (x3)"; got != want {
+		t.Errorf("formatReleaseEvent = %q, want %q", got, want)
+	}
+	e.Count = 1
+	if got, want := formatReleaseEvent(e), "[BackOff] Pod/web-1: BackOff happened"; got != want {
+		t.Errorf("formatReleaseEvent = %q, want %q", got, want)
+	}
+}
+
+func TestReleaseEventWatcherIsReleaseObject(t *testing.T) {
+	labeled := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "api-7f9c-abcde", Namespace: "apps",
+		Labels: map[string]string{"app.kubernetes.io/instance": "web"},
+	}}
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "apps"}}
+	w := &releaseEventWatcher{
+		clientset:    fake.NewClientset(labeled, other),
+		namespace:    "apps",
+		releaseName:  "web",
+		podOfRelease: map[string]bool{},
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		obj  corev1.ObjectReference
+		want bool
+	}{
+		{corev1.ObjectReference{Kind: "Deployment", Name: "web"}, true},
+		{corev1.ObjectReference{Kind: "ReplicaSet", Name: "web-5d9c"}, true},
+		{corev1.ObjectReference{Kind: "Pod", Name: "api-7f9c-abcde"}, true},
+		{corev1.ObjectReference{Kind: "Pod", Name: "db-0"}, false},
+		{corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data-db-0"}, false},
+	}
+	for _, tt := range tests {
+		if got := w.isReleaseObject(ctx, tt.obj); got != tt.want {
+			t.Errorf("isReleaseObject(%s/%s) = %v, want %v", tt.obj.Kind, tt.obj.Name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const chartDefaultSource = "chart default"
+
+// ValueOrigin is where an effective value of a release comes from: the last
+// source that set it, and the earlier ones it overrides, latest first.
+type ValueOrigin struct {
+	Key       string   `json:"key"`
+	Value     string   `json:"value"`
+	Source    string   `json:"source"`
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+// setLayer is the value layer of a single --set or --set-literal string,
+// parsed on its own with parse. Its source shows redacted values.
+func setLayer(flag, set string, parse func(string, map[string]interface{}) error) valueLayer {
+	vals := map[string]interface{}{}
+	_ = parse(set, vals) // already applied without error
+	return newValueLayer(flag+" "+redactSets([]string{set})[0], vals)
+}
+
+func newValueLayer(source string, vals map[string]interface{}) valueLayer {
+	leaves := map[string]interface{}{}
+	flattenValues("", vals, leaves)
+	return valueLayer{Source: source, Leaves: leaves}
+}
+
+// flattenValues adds the leaves of v to out by their dotted key path. Lists
+// are leaves, as a later source replaces a list as a whole.
+func flattenValues(prefix string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok || (len(m) == 0 && prefix != "") {
+		out[prefix] = v
+		return
+	}
+	for k, x := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flattenValues(key, x, out)
+	}
+}
+
+// explainValues returns the origin of every effective value of chrt with
+// the user-supplied vals merged from layers, sorted by key.
+func explainValues(chrt *chart.Chart, vals map[string]interface{}, layers []valueLayer) ([]ValueOrigin, error) {
+	defaults, err := chartutil.CoalesceValues(chrt, map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart's default values: %w", err)
+	}
+	effective, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge the values with the chart's: %w", err)
+	}
+
+	dependencies := map[string]bool{}
+	for _, dep := range chrt.Dependencies() {
+		dependencies[dep.Name()] = true
+	}
+	history := map[string][]string{}
+	set := func(key, source string) {
+		// A map replaces a scalar and a scalar a map, with its sources.
+		for k := range history {
+			if strings.HasPrefix(k, key+".") || strings.HasPrefix(key, k+".") {
+				delete(history, k)
+			}
+		}
+		history[key] = append(history[key], source)
+	}
+	chartLayer := newValueLayer(chartDefaultSource, defaults)
+	for _, key := range sortedLeafKeys(chartLayer.Leaves) {
+		source := chartDefaultSource
+		if first, _, _ := strings.Cut(key, "."); dependencies[first] {
+			source += " (" + first + ")"
+		}
+		set(key, source)
+	}
+	for _, l := range layers {
+		for _, key := range sortedLeafKeys(l.Leaves) {
+			set(key, l.Source)
+		}
+	}
+
+	leaves := map[string]interface{}{}
+	flattenValues("", map[string]interface{}(effective), leaves)
+	origins := make([]ValueOrigin, 0, len(leaves))
+	for _, key := range sortedLeafKeys(leaves) {
+		sources := history[key]
+		// Helm copies global values into every subchart.
+		if first, rest, ok := strings.Cut(key, "."); ok && dependencies[first] && strings.HasPrefix(rest, "global.") {
+			if global := history[rest]; len(global) > 0 {
+				sources = global
+			}
+		}
+		o := ValueOrigin{Key: key, Value: formatOriginValue(key, leaves[key]), Source: chartDefaultSource}
+		if len(sources) > 0 {
+			o.Source = sources[len(sources)-1]
+			for i := len(sources) - 2; i >= 0; i-- {
+				o.Overrides = append(o.Overrides, sources[i])
+			}
+		}
+		origins = append(origins, o)
+	}
+	return origins, nil
+}
+
+// formatOriginValue prints v on one line, masked when key is a redacted key.
+func formatOriginValue(key string, v interface{}) string {
+	if isSecretKey(strings.Split(key, ".")) {
+		return redactedValue
+	}
+	var s string
+	switch v.(type) {
+	case nil:
+		s = "null"
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+// printValueOrigins prints where each effective value of the release comes
+// from, for --explain-values.
+func printValueOrigins(chrt *chart.Chart, vals map[string]interface{}, layers []valueLayer) error {
+	origins, err := explainValues(chrt, vals, layers)
+	if err != nil {
+		return err
+	}
+	pterm.DefaultSection.Println("Value sources")
+	tableData := pterm.TableData{{"KEY", "VALUE", "SOURCE", "OVERRIDES"}}
+	for _, o := range origins {
+		tableData = append(tableData, []string{o.Key, o.Value, o.Source, strings.Join(o.Overrides, ", ")})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+func sortedLeafKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestExplainValues(t *testing.T) {
+	withRedactKeys(t, "password")
+	dir := t.TempDir()
+	base := filepath.Join(dir, "values.yaml")
+	prod := filepath.Join(dir, "values-prod.yaml")
+	if err := os.WriteFile(base, []byte("replicaCount: 2\nimage:\n  tag: v1\ndb:\n  password: hunter22\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prod, []byte("replicaCount: 5\nresources: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &chart.Chart{Metadata: &chart.Metadata{Name: "redis", Version: "1.0.0"}, Values: map[string]interface{}{"port": 6379}}
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"},
+		Values: map[string]interface{}{
+			"replicaCount": 1,
+			"image":        map[string]interface{}{"repository": "web", "tag": "latest"},
+		},
+	}
+	chrt.SetDependencies(sub)
+
+	vals, layers, err := loadValueLayers("", []string{base, prod}, []string{"image.tag=v2"}, nil, false)
+	if err != nil {
+		t.Fatalf("loadValueLayers: %v", err)
+	}
+	origins, err := explainValues(chrt, vals, layers)
+	if err != nil {
+		t.Fatalf("explainValues: %v", err)
+	}
+
+	byKey := map[string]ValueOrigin{}
+	for _, o := range origins {
+		byKey[o.Key] = o
+	}
+	want := map[string]struct{ value, source string }{
+		"replicaCount":     {"5", prod},
+		"image.tag":        {"v2", "--set image.tag=v2"},
+		"image.repository": {"web", "chart default"},
+		"redis.port":       {"6379", "chart default (redis)"},
+		"db.password":      {redactedValue, base},
+		"resources":        {"{}", prod},
+	}
+	for key, w := range want {
+		o, ok := byKey[key]
+		if !ok {
+			t.Errorf("%s: missing from %+v", key, origins)
+			continue
+		}
+		if o.Value != w.value || o.Source != w.source {
+			t.Errorf("%s = %q from %q, want %q from %q", key, o.Value, o.Source, w.value, w.source)
+		}
+	}
+	if o := byKey["replicaCount"]; len(o.Overrides) != 2 || o.Overrides[0] != base || o.Overrides[1] != "chart default" {
+		t.Errorf("replicaCount overrides = %v, want [%s chart default]", o.Overrides, base)
+	}
+}
+
+func TestExplainValuesReplacedMap(t *testing.T) {
+	chrt := &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"}}
+	layers := []valueLayer{
+		newValueLayer("a.yaml", map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}}),
+		newValueLayer("b.yaml", map[string]interface{}{"image": "web:v2"}),
+	}
+	origins, err := explainValues(chrt, map[string]interface{}{"image": "web:v2"}, layers)
+	if err != nil {
+		t.Fatalf("explainValues: %v", err)
+	}
+	if len(origins) != 1 || origins[0].Key != "image" || origins[0].Source != "b.yaml" || len(origins[0].Overrides) != 0 {
+		t.Errorf("origins = %+v, want image from b.yaml alone", origins)
+	}
+}
@@ -0,0 +1,208 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportOptions configures where ExportRelease commits a rendered release.
+type ExportOptions struct {
+	GitRepo          string // URL of the GitOps repository
+	Path             string // directory in the repository the release is written to
+	Branch           string // branch to commit to; the repository's default branch when empty
+	PullRequest      bool   // push to a new branch and open a pull request against Branch
+	Message          string // commit message; a default naming the release when empty
+	Namespace        string
+	RepoURL          string
+	Version          string
+	ValuesFiles      []string
+	SetValues        []string
+	SetLiteralValues []string
+	Debug            bool
+}
+
+// Files ExportRelease writes to the export path. kustomization.yaml lists
+// only the manifests, so Argo CD and Flux apply them and not the values.
+const (
+	exportManifestFile      = "manifests.yaml"
+	exportValuesFile        = "values.yaml"
+	exportKustomizationFile = "kustomization.yaml"
+)
+
+// ExportRelease renders releaseName from chartRef and commits the manifests
+// and the values they were rendered with to opts.Path of a GitOps
+// repository, for Argo CD or Flux to apply. The path is replaced as a
+// whole, so resources dropped from the chart are removed from the
+// repository too. Nothing is committed when the rendered release is
+// unchanged.
+func ExportRelease(releaseName, chartRef string, opts ExportOptions, useAI bool) error {
+	if opts.GitRepo == "" || opts.Path == "" {
+		return fmt.Errorf("a git repository and a path are required")
+	}
+	exportPath, err := cleanExportPath(opts.Path)
+	if err != nil {
+		return err
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Rendering release %s...", releaseName))
+	rel, vals, err := renderRelease(releaseName, chartRef, opts.Namespace, opts.RepoURL, opts.Version,
+		opts.ValuesFiles, opts.SetValues, opts.SetLiteralValues, opts.Debug)
+	if err != nil {
+		spinner.Fail(err.Error())
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	manifest := rel.Manifest
+	for _, hook := range rel.Hooks {
+		manifest += fmt.Sprintf("\n---\n# Source: %s\n%s", hook.Path, hook.Manifest)
+	}
+	spinner.Success(fmt.Sprintf("Rendered %s from %s", releaseName, chartRef))
+
+	workDir, err := os.MkdirTemp("", "smurf-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Cloning %s...", opts.GitRepo))
+	cloneArgs := []string{"clone", "--depth", "1"}
+	if opts.Branch != "" {
+		cloneArgs = append(cloneArgs, "--branch", opts.Branch)
+	}
+	if _, err := runGit("", append(cloneArgs, opts.GitRepo, workDir)...); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to clone %s", opts.GitRepo))
+		err = fmt.Errorf("failed to clone %s: %w", opts.GitRepo, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	base, err := runGit(workDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		spinner.Fail("Failed to read the cloned branch")
+		return err
+	}
+	spinner.Success(fmt.Sprintf("Cloned %s (%s)", opts.GitRepo, base))
+
+	if err := writeExport(filepath.Join(workDir, exportPath), manifest, vals); err != nil {
+		return err
+	}
+	if _, err := runGit(workDir, "add", "-A", "--", exportPath); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", exportPath, err)
+	}
+	changes, err := runGit(workDir, "status", "--porcelain", "--", exportPath)
+	if err != nil {
+		return err
+	}
+	if changes == "" {
+		pterm.Info.Printfln("%s in %s is up to date; nothing to commit", exportPath, opts.GitRepo)
+		return nil
+	}
+
+	branch := base
+	if opts.PullRequest {
+		branch = fmt.Sprintf("smurf/export-%s-%d", releaseName, time.Now().Unix())
+		if _, err := runGit(workDir, "checkout", "-b", branch); err != nil {
+			return fmt.Errorf("failed to create branch %s: %w", branch, err)
+		}
+	}
+	message := opts.Message
+	if message == "" {
+		message = fmt.Sprintf("Export %s to %s", releaseName, exportPath)
+	}
+	if _, err := runGit(workDir, append(commitIdentity(workDir), "commit", "-m", message)...); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Pushing %s...", branch))
+	if _, err := runGit(workDir, "push", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to push %s", branch))
+		err = fmt.Errorf("failed to push %s to %s: %w", branch, opts.GitRepo, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	spinner.Success(fmt.Sprintf("Pushed %s to %s", branch, opts.GitRepo))
+
+	if !opts.PullRequest {
+		return nil
+	}
+	url, err := openPullRequest(workDir, base, branch, message, releaseName, chartRef)
+	if err != nil {
+		pterm.Warning.Printfln("Branch %s was pushed, but the pull request could not be opened: %v", branch, err)
+		return err
+	}
+	pterm.Success.Printfln("Opened pull request %s", url)
+	return nil
+}
+
+// cleanExportPath rejects export paths that would write outside the
+// repository or over all of it.
+func cleanExportPath(path string) (string, error) {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) ||
+		clean == ".git" || strings.HasPrefix(clean, ".git"+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q: must be a directory inside the repository", path)
+	}
+	return clean, nil
+}
+
+// writeExport replaces dir with the manifests, the values and a
+// kustomization.yaml listing the manifests.
+func writeExport(dir, manifest string, vals map[string]interface{}) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	valuesYAML, err := yaml.Marshal(vals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal values: %w", err)
+	}
+	files := map[string]string{
+		exportManifestFile:      strings.TrimSpace(manifest) + "\n",
+		exportValuesFile:        string(valuesYAML),
+		exportKustomizationFile: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - " + exportManifestFile + "\n",
+	}
+	for _, name := range sortedKeys(files) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(files[name]), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// commitIdentity returns git options naming smurf as the committer when
+// the environment has no git identity, as on a fresh CI runner.
+func commitIdentity(dir string) []string {
+	if email, err := runGit(dir, "config", "user.email"); err == nil && email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=smurf", "-c", "user.email=smurf@users.noreply.github.com"}
+}
+
+// openPullRequest opens a pull request from branch into base with the gh
+// CLI and returns its URL.
+func openPullRequest(dir, base, branch, title, releaseName, chartRef string) (string, error) {
+	gh, err := exec.LookPath("gh")
+	if err != nil {
+		return "", fmt.Errorf("opening a pull request needs the gh CLI on PATH")
+	}
+	body := fmt.Sprintf("Rendered manifests of release `%s` from chart `%s`, exported by `smurf selm export`.", releaseName, chartRef)
+	cmd := exec.Command(gh, "pr", "create", "--base", base, "--head", branch, "--title", title, "--body", body)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
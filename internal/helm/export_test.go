@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportRelease(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not on PATH")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "env-config.git")
+	seed := filepath.Join(dir, "seed")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		out, err := runGit(dir, args...)
+		if err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+		return out
+	}
+	git(dir, "init", "--bare", "--initial-branch=main", remote)
+	git(dir, "init", "--initial-branch=main", seed)
+	// A stale file in the export path must be removed by the export.
+	if err := os.MkdirAll(filepath.Join(seed, "apps", "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(seed, "apps", "web", "old.yaml"), []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(seed, "add", "-A")
+	git(seed, "commit", "-m", "init")
+	git(seed, "push", remote, "main")
+
+	if err := CreateChart("web", dir); err != nil {
+		t.Fatal(err)
+	}
+	opts := ExportOptions{
+		GitRepo:   remote,
+		Path:      "apps/web",
+		Namespace: "apps",
+		SetValues: []string{"image.tag=v1.2.3"},
+	}
+	if err := ExportRelease("web", filepath.Join(dir, "web"), opts, false); err != nil {
+		t.Fatal(err)
+	}
+
+	files := git(dir, "--git-dir", remote, "ls-tree", "--name-only", "main", "apps/web/")
+	if files != "apps/web/kustomization.yaml\napps/web/manifests.yaml\napps/web/values.yaml" {
+		t.Errorf("exported files = %q", files)
+	}
+	if manifest := git(dir, "--git-dir", remote, "show", "main:apps/web/manifests.yaml"); !strings.Contains(manifest, "image: \"nginx:v1.2.3\"") {
+		t.Errorf("manifests.yaml does not use the --set image tag:\n%s", manifest)
+	}
+	if values := git(dir, "--git-dir", remote, "show", "main:apps/web/values.yaml"); !strings.Contains(values, "tag: v1.2.3") {
+		t.Errorf("values.yaml does not hold the merged values:\n%s", values)
+	}
+
+	// Exporting the same release again has nothing to commit.
+	if err := ExportRelease("web", filepath.Join(dir, "web"), opts, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := git(dir, "--git-dir", remote, "rev-list", "--count", "main"); n != "2" {
+		t.Errorf("main has %s commits after an unchanged export, want 2", n)
+	}
+}
+
+func TestCleanExportPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"apps/web":    "apps/web",
+		"./apps/web/": "apps/web",
+		"apps/../web": "web",
+	} {
+		if got, err := cleanExportPath(path); err != nil || got != want {
+			t.Errorf("cleanExportPath(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"", ".", "..", "../web", "/apps/web", ".git", ".git/hooks"} {
+		if _, err := cleanExportPath(path); err == nil {
+			t.Errorf("cleanExportPath(%q) succeeded, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/distribution/reference"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ImageUsage is a deployed release whose manifest references an image.
+type ImageUsage struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Chart     string `json:"chart"`
+	Status    string `json:"status"`
+	Image     string `json:"image"`
+}
+
+// imageQuery is what FindImage looks for: a repository, optionally pinned
+// to a tag or a digest, or a bare digest matching any repository.
+type imageQuery struct {
+	repo   string
+	tag    string
+	digest string
+}
+
+// FindImage lists the releases in namespace (all namespaces if empty) whose
+// manifest, hooks included, references image: a repository (any tag), a
+// repository with a tag or digest, or a bare sha256 digest. Names are
+// compared normalized, so nginx matches docker.io/library/nginx.
+func FindImage(image, namespace string, useAI bool) ([]ImageUsage, error) {
+	query, err := parseImageQuery(image)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("helm init failed: %w", err)
+	}
+	client := action.NewList(cfg)
+	client.AllNamespaces = namespace == ""
+	client.StateMask = action.ListDeployed | action.ListFailed | action.ListPendingInstall | action.ListPendingUpgrade | action.ListPendingRollback
+	releases, err := client.Run()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("release listing failed: %w", err)
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Scanning %d release(s) for %s...", len(releases), image))
+	usages, err := imageUsages(releases, query)
+	if err != nil {
+		spinner.Fail(err.Error())
+		return nil, err
+	}
+	spinner.Stop()
+	return usages, nil
+}
+
+// imageUsages returns an ImageUsage for every image of releases matching
+// query, sorted by namespace, release and image.
+func imageUsages(releases []*release.Release, query imageQuery) ([]ImageUsage, error) {
+	var usages []ImageUsage
+	for _, r := range releases {
+		manifest := r.Manifest
+		for _, hook := range r.Hooks {
+			manifest += "\n---\n" + hook.Manifest
+		}
+		images, err := ManifestImages(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("release %s/%s: %w", r.Namespace, r.Name, err)
+		}
+		for _, image := range images {
+			if !query.matches(image) {
+				continue
+			}
+			usage := ImageUsage{Release: r.Name, Namespace: r.Namespace, Revision: r.Version, Image: image}
+			if r.Chart != nil && r.Chart.Metadata != nil {
+				usage.Chart = r.Chart.Metadata.Name + "-" + r.Chart.Metadata.Version
+			}
+			if r.Info != nil {
+				usage.Status = r.Info.Status.String()
+			}
+			usages = append(usages, usage)
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		a, b := usages[i], usages[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Release != b.Release {
+			return a.Release < b.Release
+		}
+		return a.Image < b.Image
+	})
+	return usages, nil
+}
+
+func parseImageQuery(image string) (imageQuery, error) {
+	if strings.HasPrefix(image, "sha256:") {
+		return imageQuery{digest: image}, nil
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return imageQuery{}, fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	query := imageQuery{repo: named.Name()}
+	if tagged, ok := named.(reference.Tagged); ok {
+		query.tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		query.digest = digested.Digest().String()
+	}
+	return query, nil
+}
+
+// matches reports whether image, as written in a manifest, is the image of
+// q. An image without tag or digest is the latest tag. Images that are not
+// valid references (unrendered placeholders) never match.
+func (q imageQuery) matches(image string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	if q.repo != "" && named.Name() != q.repo {
+		return false
+	}
+	digested, hasDigest := named.(reference.Digested)
+	if q.digest != "" {
+		return hasDigest && digested.Digest().String() == q.digest
+	}
+	if q.tag != "" {
+		tag := ""
+		if tagged, ok := named.(reference.Tagged); ok {
+			tag = tagged.Tag()
+		} else if !hasDigest {
+			tag = "latest"
+		}
+		return tag == q.tag
+	}
+	return true
+}
+
+// PrintImageUsage renders usages as a table, or as JSON or YAML when format
+// asks for it.
+func PrintImageUsage(usages []ImageUsage, image, format string) error {
+	if isStructured(format) {
+		if usages == nil {
+			usages = []ImageUsage{}
+		}
+		return printStructured(usages, format)
+	}
+	if len(usages) == 0 {
+		pterm.Success.Printfln("No release references %s.", image)
+		return nil
+	}
+
+	data := pterm.TableData{{"RELEASE", "NAMESPACE", "REVISION", "CHART", "STATUS", "IMAGE"}}
+	releases := map[string]bool{}
+	for _, u := range usages {
+		releases[u.Namespace+"/"+u.Release] = true
+		data = append(data, []string{u.Release, u.Namespace, fmt.Sprint(u.Revision), u.Chart, u.Status, u.Image})
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithBoxed().WithData(data).Render(); err != nil {
+		return err
+	}
+	pterm.Info.Printfln("%d release(s) reference %s.", len(releases), image)
+	return nil
+}
@@ -0,0 +1,70 @@
+package helm
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestImageQueryMatches(t *testing.T) {
+	const digest = "sha256:4f3a7c1e2b9d8a6f5e4c3b2a1908f7e6d5c4b3a29180f7e6d5c4b3a291807f6e"
+	tests := []struct {
+		query, image string
+		want         bool
+	}{
+		{"nginx", "docker.io/library/nginx:1.25", true},
+		{"nginx", "nginx", true},
+		{"nginx", "ghcr.io/acme/nginx:1.25", false},
+		{"nginx:1.25", "nginx:1.25", true},
+		{"nginx:1.25", "nginx:1.26", false},
+		{"nginx:latest", "nginx", true},
+		{"nginx:1.25", "nginx@" + digest, false},
+		{"nginx@" + digest, "nginx:1.25@" + digest, true},
+		{"nginx@" + digest, "nginx:1.25", false},
+		{digest, "ghcr.io/acme/api@" + digest, true},
+		{"ghcr.io/acme/api", "{{ .Values.image }}", false},
+	}
+	for _, tt := range tests {
+		query, err := parseImageQuery(tt.query)
+		if err != nil {
+			t.Fatalf("parseImageQuery(%q): %v", tt.query, err)
+		}
+		if got := query.matches(tt.image); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.query, tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestImageUsages(t *testing.T) {
+	rel := func(name, namespace, manifest string, hooks ...*release.Hook) *release.Release {
+		return &release.Release{
+			Name: name, Namespace: namespace, Version: 3, Manifest: manifest, Hooks: hooks,
+			Chart: &chart.Chart{Metadata: &chart.Metadata{Name: name, Version: "1.0.0"}},
+			Info:  &release.Info{Status: release.StatusDeployed},
+		}
+	}
+	deployment := func(image string) string {
+		return "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  template:\n    spec:\n      containers:\n        - name: app\n          image: " + image + "\n"
+	}
+	releases := []*release.Release{
+		rel("web", "prod", deployment("ghcr.io/acme/web:1.0")),
+		rel("api", "prod", deployment("ghcr.io/acme/api:2.0"), &release.Hook{Manifest: deployment("ghcr.io/acme/base:1.1")}),
+		rel("api", "dev", deployment("ghcr.io/acme/base:1.1")),
+	}
+
+	query, err := parseImageQuery("ghcr.io/acme/base:1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usages, err := imageUsages(releases, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 2 || usages[0].Namespace != "dev" || usages[1].Namespace != "prod" || usages[1].Release != "api" {
+		t.Fatalf("usages = %+v, want api in dev and prod", usages)
+	}
+	if usages[0].Chart != "api-1.0.0" || usages[0].Status != "deployed" || usages[0].Revision != 3 {
+		t.Errorf("usage = %+v", usages[0])
+	}
+}
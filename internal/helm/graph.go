@@ -0,0 +1,341 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GraphFormats are the values of selm graph --format.
+var GraphFormats = []string{"mermaid", "dot"}
+
+// Relations of the edges of a ResourceGraph.
+const (
+	relationOwns    = "owns"    // the owner reference of the child
+	relationSelects = "selects" // a Service's selector matching a pod
+	relationRoutes  = "routes"  // an Ingress backend
+)
+
+// GraphNode is a resource of a ResourceGraph. Status is a pod's phase;
+// Healthy is false for a pod that is not ready or a workload, Service or
+// Ingress the release names but the cluster doesn't have.
+type GraphNode struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Status  string `json:"status,omitempty"`
+	Healthy bool   `json:"healthy"`
+}
+
+// GraphEdge is a relation from one node to another.
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// ResourceGraph is how the resources of a release own, select and route to
+// each other: Deployment → ReplicaSet → Pod, Service → Pod, Ingress →
+// Service, and likewise for StatefulSets, DaemonSets, Jobs and CronJobs.
+type ResourceGraph struct {
+	Release   string      `json:"release"`
+	Namespace string      `json:"namespace"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+
+	ids map[string]string // kind/name → node ID
+}
+
+// node returns the ID of the node of kind/name, adding it when it is new.
+func (g *ResourceGraph) node(kind, name string) string {
+	key := kind + "/" + name
+	if id, ok := g.ids[key]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(g.Nodes))
+	g.ids[key] = id
+	g.Nodes = append(g.Nodes, GraphNode{ID: id, Kind: kind, Name: name, Healthy: true})
+	return id
+}
+
+// find returns the node with id.
+func (g *ResourceGraph) find(id string) *GraphNode {
+	for i := range g.Nodes {
+		if g.Nodes[i].ID == id {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (g *ResourceGraph) edge(from, to, relation string) {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Relation == relation {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, GraphEdge{From: from, To: to, Relation: relation})
+}
+
+// HelmGraph prints the resource graph of a release as a Mermaid flowchart
+// or a Graphviz digraph (graphFormat), or as a JSON or YAML document.
+func HelmGraph(releaseName, namespace, graphFormat, format string, useAI bool) error {
+	if isStructured(format) {
+		pterm.SetDefaultOutput(os.Stderr)
+		defer pterm.SetDefaultOutput(os.Stdout)
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to initialize Helm action configuration: %v", err)
+	}
+	rel, err := action.NewGet(actionConfig).Run(releaseName)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to get release %s: %w", releaseName, err)
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return err
+	}
+
+	graph, err := buildResourceGraph(context.Background(), clientset, rel)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	if isStructured(format) {
+		return printStructured(graph, format)
+	}
+	if graphFormat == "dot" {
+		fmt.Print(renderDot(graph))
+	} else {
+		fmt.Print(renderMermaid(graph))
+	}
+	return nil
+}
+
+// buildResourceGraph reads the workloads, services and ingresses of rel
+// from the cluster and links them to the ReplicaSets, Jobs and pods they
+// own or select. Resources of other kinds are nodes without edges.
+func buildResourceGraph(ctx context.Context, clientset kubernetes.Interface, rel *release.Release) (*ResourceGraph, error) {
+	resources, err := parseResourcesFromManifest(rel.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	ns := rel.Namespace
+	g := &ResourceGraph{Release: rel.Name, Namespace: ns, Nodes: []GraphNode{}, Edges: []GraphEdge{}, ids: map[string]string{}}
+
+	list := metav1.ListOptions{}
+	pods, err := clientset.CoreV1().Pods(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	replicaSets, err := clientset.AppsV1().ReplicaSets(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replica sets: %w", err)
+	}
+	jobs, err := clientset.BatchV1().Jobs(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	// linkPods adds the pods owned by uid under the node parent.
+	linkPods := func(parent string, uid types.UID) {
+		for i := range pods.Items {
+			if ownedBy(pods.Items[i].OwnerReferences, uid) {
+				g.edge(parent, podNode(g, &pods.Items[i]), relationOwns)
+			}
+		}
+	}
+	linkJobs := func(parent string, uid types.UID) {
+		for _, job := range jobs.Items {
+			if ownedBy(job.OwnerReferences, uid) {
+				id := g.node("Job", job.Name)
+				g.edge(parent, id, relationOwns)
+				linkPods(id, job.UID)
+			}
+		}
+	}
+
+	get := metav1.GetOptions{}
+	for _, r := range resources {
+		id := g.node(r.Kind, r.Name)
+		switch r.Kind {
+		case "Deployment":
+			d, e := clientset.AppsV1().Deployments(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				for _, rs := range replicaSets.Items {
+					// Old ReplicaSets scaled to zero are history, not topology.
+					if ownedBy(rs.OwnerReferences, d.UID) && replicaCount(rs.Spec.Replicas) > 0 {
+						rsID := g.node("ReplicaSet", rs.Name)
+						g.edge(id, rsID, relationOwns)
+						linkPods(rsID, rs.UID)
+					}
+				}
+			}
+		case "ReplicaSet":
+			rs, e := clientset.AppsV1().ReplicaSets(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				linkPods(id, rs.UID)
+			}
+		case "StatefulSet":
+			ss, e := clientset.AppsV1().StatefulSets(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				linkPods(id, ss.UID)
+			}
+		case "DaemonSet":
+			ds, e := clientset.AppsV1().DaemonSets(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				linkPods(id, ds.UID)
+			}
+		case "Job":
+			job, e := clientset.BatchV1().Jobs(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				linkPods(id, job.UID)
+			}
+		case "CronJob":
+			cj, e := clientset.BatchV1().CronJobs(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				linkJobs(id, cj.UID)
+			}
+		case "Pod":
+			pod, e := clientset.CoreV1().Pods(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				podNode(g, pod)
+			}
+		case "Service":
+			svc, e := clientset.CoreV1().Services(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil && len(svc.Spec.Selector) > 0 {
+				selector := labels.SelectorFromSet(svc.Spec.Selector)
+				for i := range pods.Items {
+					if selector.Matches(labels.Set(pods.Items[i].Labels)) {
+						g.edge(id, podNode(g, &pods.Items[i]), relationSelects)
+					}
+				}
+			}
+		case "Ingress":
+			ing, e := clientset.NetworkingV1().Ingresses(ns).Get(ctx, r.Name, get)
+			if err = e; err == nil {
+				var services []string
+				if b := ing.Spec.DefaultBackend; b != nil && b.Service != nil {
+					services = append(services, b.Service.Name)
+				}
+				for _, rule := range ing.Spec.Rules {
+					if rule.HTTP == nil {
+						continue
+					}
+					for _, p := range rule.HTTP.Paths {
+						if p.Backend.Service != nil {
+							services = append(services, p.Backend.Service.Name)
+						}
+					}
+				}
+				for _, name := range services {
+					g.edge(id, g.node("Service", name), relationRoutes)
+				}
+			}
+		default:
+			err = nil
+		}
+		if err != nil {
+			n := g.find(id)
+			n.Healthy, n.Status = false, "missing"
+		}
+	}
+	return g, nil
+}
+
+// ownedBy reports whether refs name the owner uid.
+func ownedBy(refs []metav1.OwnerReference, uid types.UID) bool {
+	for _, ref := range refs {
+		if ref.UID == uid && uid != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// podNode adds pod as a node with its phase and readiness.
+func podNode(g *ResourceGraph, pod *corev1.Pod) string {
+	id := g.node("Pod", pod.Name)
+	n := g.find(id)
+	res := podResource(pod)
+	ready, _ := res.Status["ready"].(bool)
+	n.Status = string(pod.Status.Phase)
+	n.Healthy = ready || pod.Status.Phase == corev1.PodSucceeded
+	if !n.Healthy && pod.Status.Phase == corev1.PodRunning {
+		n.Status = "Running, not ready"
+	}
+	return id
+}
+
+// graphLabel is the text of a node: its kind and name, and a pod's status.
+func graphLabel(n GraphNode) string {
+	label := n.Kind + "/" + n.Name
+	if n.Status != "" {
+		label += " (" + n.Status + ")"
+	}
+	return label
+}
+
+// renderMermaid writes g as a Mermaid flowchart, unhealthy nodes in red.
+func renderMermaid(g *ResourceGraph) string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	var unhealthy []string
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.ID, strings.ReplaceAll(graphLabel(n), `"`, "#quot;"))
+		if !n.Healthy {
+			unhealthy = append(unhealthy, n.ID)
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Relation != relationOwns {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", e.From, arrow, e.Relation, e.To)
+	}
+	if len(unhealthy) > 0 {
+		sort.Strings(unhealthy)
+		b.WriteString("  classDef unhealthy fill:#fdd,stroke:#c00\n")
+		fmt.Fprintf(&b, "  class %s unhealthy\n", strings.Join(unhealthy, ","))
+	}
+	return b.String()
+}
+
+// renderDot writes g as a Graphviz digraph, unhealthy nodes in red.
+func renderDot(g *ResourceGraph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n  rankdir=LR;\n  node [shape=box];\n", g.Release)
+	for _, n := range g.Nodes {
+		attrs := fmt.Sprintf("label=%q", graphLabel(n))
+		if !n.Healthy {
+			attrs += `, color="#cc0000", style=filled, fillcolor="#ffdddd"`
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", n.ID, attrs)
+	}
+	for _, e := range g.Edges {
+		attrs := fmt.Sprintf("label=%q", e.Relation)
+		if e.Relation != relationOwns {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", e.From, e.To, attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
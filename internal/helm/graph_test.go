@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const graphManifest = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: missing
+`
+
+func TestBuildResourceGraph(t *testing.T) {
+	one, zero := int32(1), int32(0)
+	ref := func(uid string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{UID: types.UID(uid)}}
+	}
+	labels := map[string]string{"app": "web"}
+	ready := []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", UID: "dep"}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-new", Namespace: "apps", UID: "rs-new", OwnerReferences: ref("dep")},
+			Spec: appsv1.ReplicaSetSpec{Replicas: &one}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-old", Namespace: "apps", UID: "rs-old", OwnerReferences: ref("dep")},
+			Spec: appsv1.ReplicaSetSpec{Replicas: &zero}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-new-a", Namespace: "apps", Labels: labels, OwnerReferences: ref("rs-new")},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, Conditions: ready}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-new-b", Namespace: "apps", Labels: labels, OwnerReferences: ref("rs-new")},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps", Labels: map[string]string{"app": "other"}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"}, Spec: corev1.ServiceSpec{Selector: labels}},
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"}, Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+				Paths: []networkingv1.HTTPIngressPath{{Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web"}}}},
+			}}}},
+		}},
+	)
+	rel := &release.Release{Name: "web", Namespace: "apps", Manifest: graphManifest}
+
+	g, err := buildResourceGraph(context.Background(), clientset, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := map[string]bool{}
+	for _, e := range g.Edges {
+		from, to := g.find(e.From), g.find(e.To)
+		edges[from.Kind+"/"+from.Name+" "+e.Relation+" "+to.Kind+"/"+to.Name] = true
+	}
+	for _, want := range []string{
+		"Deployment/web owns ReplicaSet/web-new",
+		"ReplicaSet/web-new owns Pod/web-new-a",
+		"ReplicaSet/web-new owns Pod/web-new-b",
+		"Service/web selects Pod/web-new-a",
+		"Service/web selects Pod/web-new-b",
+		"Ingress/web routes Service/web",
+	} {
+		if !edges[want] {
+			t.Errorf("graph has no edge %s; edges: %v", want, edges)
+		}
+	}
+	if len(g.Edges) != 6 {
+		t.Errorf("graph has %d edges, want 6: %v", len(g.Edges), edges)
+	}
+
+	health := map[string]bool{}
+	for _, n := range g.Nodes {
+		health[n.Kind+"/"+n.Name] = n.Healthy
+	}
+	if _, ok := health["ReplicaSet/web-old"]; ok {
+		t.Error("a ReplicaSet scaled to zero is in the graph")
+	}
+	if !health["Pod/web-new-a"] || health["Pod/web-new-b"] || !health["ConfigMap/settings"] || health["StatefulSet/missing"] {
+		t.Errorf("node health = %v", health)
+	}
+
+	mermaid := renderMermaid(g)
+	for _, want := range []string{"flowchart LR", `["Pod/web-new-b (Running, not ready)"]`, "-.->|selects|", "class "} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid graph has no %q:\n%s", want, mermaid)
+		}
+	}
+	dot := renderDot(g)
+	for _, want := range []string{`digraph "web" {`, `[label="owns"]`, "style=dashed", `fillcolor="#ffdddd"`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot graph has no %q:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// healthPollInterval is how long the health gate waits before retrying the
+// checks that have not passed yet.
+var healthPollInterval = 5 * time.Second
+
+// healthRequestTimeout bounds a single health check request.
+const healthRequestTimeout = 10 * time.Second
+
+// HealthGate polls the health checks of a deployed release until they all
+// pass or timeout passes. When they don't pass and rollback is set, the
+// release is rolled back to its previous revision; the gate fails either way.
+func HealthGate(releaseName, namespace string, checks []configs.HealthCheck, timeout time.Duration, rollback, debug bool) error {
+	err := WaitForHealthy(checks, timeout)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("release %s/%s is unhealthy: %w", namespace, releaseName, err)
+	if !rollback {
+		return err
+	}
+
+	pterm.Warning.Printfln("Rolling back %s after the failed health checks...", releaseName)
+	revision, rbErr := rollbackToPrevious(releaseName, namespace, debug)
+	if rbErr != nil {
+		return fmt.Errorf("%w; rollback failed: %v", err, rbErr)
+	}
+	return fmt.Errorf("%w; rolled back to revision %d", err, revision)
+}
+
+// WaitForHealthy polls checks until every one has passed once or timeout
+// passes. A check that has passed is not polled again.
+func WaitForHealthy(checks []configs.HealthCheck, timeout time.Duration) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: healthRequestTimeout}
+	pending := append([]configs.HealthCheck(nil), checks...)
+	failures := make(map[string]error, len(checks))
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Waiting for %d health check(s) to pass...", len(checks)))
+	for {
+		remaining := pending[:0]
+		for _, c := range pending {
+			if err := probeHealth(ctx, client, c); err != nil {
+				// A request cut short by the deadline says less than the
+				// failure before it.
+				if ctx.Err() == nil || failures[c.URL] == nil {
+					failures[c.URL] = err
+				}
+				remaining = append(remaining, c)
+				continue
+			}
+			delete(failures, c.URL)
+			pterm.Success.Printfln("Health check passed: %s", c.URL)
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			spinner.Success("All health checks passed")
+			return nil
+		}
+		spinner.UpdateText(fmt.Sprintf("Waiting for %d of %d health check(s) to pass...", len(pending), len(checks)))
+
+		select {
+		case <-ctx.Done():
+			spinner.Fail(fmt.Sprintf("%d health check(s) did not pass within %s", len(pending), timeout))
+			msgs := make([]string, len(pending))
+			for i, c := range pending {
+				msgs[i] = fmt.Sprintf("%s: %v", c.URL, failures[c.URL])
+			}
+			return errors.New("health checks failed: " + strings.Join(msgs, "; "))
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+// probeHealth makes one GET request to c.URL and reports why it does not
+// meet the check, or nil when it does.
+func probeHealth(ctx context.Context, client *http.Client, c configs.HealthCheck) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// Drop the "Get <url>:" prefix; the URL is already reported.
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	want := c.Status
+	if want == 0 {
+		want = http.StatusOK
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("got status %d, want %d", resp.StatusCode, want)
+	}
+	if c.Body == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile(c.Body)
+	if err != nil {
+		return fmt.Errorf("invalid body pattern: %w", err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read the response: %w", err)
+	}
+	if !pattern.Match(body) {
+		return fmt.Errorf("response body does not match %q", c.Body)
+	}
+	return nil
+}
+
+// rollbackToPrevious rolls a release back to the revision deployed before
+// its current one and returns that revision.
+func rollbackToPrevious(releaseName, namespace string, debug bool) (int, error) {
+	cfg, err := initActionConfig(namespace, debug)
+	if err != nil {
+		return 0, err
+	}
+	history, err := cfg.Releases.History(releaseName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the history of %s: %w", releaseName, err)
+	}
+	revision, err := previousRevision(history)
+	if err != nil {
+		return 0, err
+	}
+	opts := RollbackOptions{
+		Namespace: namespace,
+		Debug:     debug,
+		Timeout:   configs.Timeouts.HelmWait,
+		Wait:      true,
+	}
+	if err := HelmRollback(releaseName, revision, opts, 10, false); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// previousRevision returns the newest revision before the latest one that
+// was successfully deployed, i.e. the one an unhealthy upgrade replaced.
+func previousRevision(history []*release.Release) (int, error) {
+	latest := 0
+	for _, r := range history {
+		latest = max(latest, r.Version)
+	}
+	previous := 0
+	for _, r := range history {
+		if r.Version >= latest || r.Version <= previous || r.Info == nil {
+			continue
+		}
+		if r.Info.Status == release.StatusSuperseded || r.Info.Status == release.StatusDeployed {
+			previous = r.Version
+		}
+	}
+	if previous == 0 {
+		return 0, errors.New("no earlier revision to roll back to")
+	}
+	return previous, nil
+}
@@ -0,0 +1,79 @@
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestWaitForHealthy(t *testing.T) {
+	prev := healthPollInterval
+	t.Cleanup(func() { healthPollInterval = prev })
+	healthPollInterval = 10 * time.Millisecond
+
+	// Unhealthy for the first two polls, then healthy.
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok","db":"up"}`))
+	}))
+	defer srv.Close()
+
+	check := configs.HealthCheck{URL: srv.URL, Body: `"db":"up"`, Headers: map[string]string{"Authorization": "Bearer t"}}
+	if err := WaitForHealthy([]configs.HealthCheck{check}, 5*time.Second); err != nil {
+		t.Fatalf("WaitForHealthy() = %v, want nil", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("polled %d times, want 3", calls.Load())
+	}
+
+	tests := []struct {
+		name  string
+		check configs.HealthCheck
+		want  string
+	}{
+		{"status", configs.HealthCheck{URL: srv.URL, Status: http.StatusNoContent, Headers: check.Headers}, "got status 200, want 204"},
+		{"body", configs.HealthCheck{URL: srv.URL, Body: `"db":"down"`, Headers: check.Headers}, "response body does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WaitForHealthy([]configs.HealthCheck{tt.check}, 50*time.Millisecond)
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), srv.URL) {
+				t.Errorf("err = %v, want it to name %s and contain %q", err, srv.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousRevision(t *testing.T) {
+	rev := func(version int, status release.Status) *release.Release {
+		return &release.Release{Version: version, Info: &release.Info{Status: status}}
+	}
+
+	got, err := previousRevision([]*release.Release{
+		rev(1, release.StatusSuperseded),
+		rev(2, release.StatusSuperseded),
+		rev(3, release.StatusFailed),
+		rev(4, release.StatusDeployed),
+	})
+	if err != nil || got != 2 {
+		t.Errorf("previousRevision() = %d, %v; want 2", got, err)
+	}
+
+	if _, err := previousRevision([]*release.Release{rev(1, release.StatusDeployed)}); err == nil {
+		t.Error("previousRevision() of a first install: want an error")
+	}
+}
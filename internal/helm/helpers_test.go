@@ -3,6 +3,7 @@ package helm
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -663,3 +664,32 @@ func TestIsPodUnhealthyForUpgrade(t *testing.T) {
 		})
 	}
 }
+
+func TestListRepoNames(t *testing.T) {
+	dir := t.TempDir()
+
+	names, err := ListRepoNames(dir)
+	if err != nil || len(names) != 0 {
+		t.Fatalf("ListRepoNames(missing file) = %v, %v; want no names, nil", names, err)
+	}
+
+	content := `apiVersion: ""
+generated: "0001-01-01T00:00:00Z"
+repositories:
+- name: stable
+  url: https://charts.example.com/stable
+- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+`
+	if err := os.WriteFile(filepath.Join(dir, "repositories.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err = ListRepoNames(dir)
+	if err != nil {
+		t.Fatalf("ListRepoNames: %v", err)
+	}
+	if want := []string{"bitnami", "stable"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListRepoNames = %v, want %v", names, want)
+	}
+}
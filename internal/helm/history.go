@@ -10,6 +10,7 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
 )
 
 // HelmHistory shows the revision history of a Helm release using pterm.Table.
@@ -49,78 +50,81 @@ func HelmHistory(releaseName, namespace string, max int, format string, useAI bo
 		return fmt.Errorf("failed to get release history: %v", err)
 	}
 
-	releases = sortReleasesByRevision(releases)
-
+	history := releaseHistory(releases, max)
 	if !isTable {
-		elements := historyElements(releases)
-		if format == "yaml" {
-			return printYAML(elements)
-		}
-		return printJSON(elements)
+		return printStructured(history, format)
 	}
 
-	if len(releases) == 0 {
+	if len(history) == 0 {
 		pterm.Info.Printfln("No revision history found for release %s", releaseName)
 		return nil
 	}
 
-	printHistoryTable(releases)
+	printHistoryTable(history)
 	return nil
 }
 
-// historyElements converts release revisions into plain maps for JSON/YAML
-// output, always returning a non-nil (possibly empty) slice so the JSON
-// document is "[]" rather than "null" when there is no history.
-func historyElements(releases []*release.Release) []map[string]interface{} {
-	elements := make([]map[string]interface{}, 0, len(releases))
+// RevisionSummary is one revision of a release as selm history reports it.
+// Revision is what selm rollback takes.
+type RevisionSummary struct {
+	Revision     int    `json:"revision" yaml:"revision"`
+	Updated      string `json:"updated" yaml:"updated"`
+	Status       string `json:"status" yaml:"status"`
+	Chart        string `json:"chart" yaml:"chart"`
+	ChartVersion string `json:"chart_version" yaml:"chart_version"`
+	AppVersion   string `json:"app_version" yaml:"app_version"`
+	Description  string `json:"description" yaml:"description"`
+}
+
+// releaseHistory summarizes the newest max revisions of releases, oldest
+// first, as helm history does; max <= 0 keeps them all. It never returns
+// nil, so the JSON document is "[]" rather than "null" when there is no
+// history.
+func releaseHistory(releases []*release.Release, max int) []RevisionSummary {
+	revisions := make([]*release.Release, 0, len(releases))
 	for _, r := range releases {
-		if r == nil {
-			continue
+		if r != nil {
+			revisions = append(revisions, r)
 		}
-		elements = append(elements, map[string]interface{}{
-			"revision":    safeInt(r.Version),
-			"updated":     safeTime(r.Info),
-			"status":      safeStatus(r.Info),
-			"chart":       safeChartName(r.Chart),
-			"app_version": safeAppVersion(r.Chart),
-			"description": safeDescription(r.Info),
-		})
 	}
-	return elements
-}
+	releaseutil.SortByRevision(revisions)
+	if max > 0 && len(revisions) > max {
+		revisions = revisions[len(revisions)-max:]
+	}
 
-func sortReleasesByRevision(releases []*release.Release) []*release.Release {
-	for i := 0; i < len(releases)-1; i++ {
-		for j := 0; j < len(releases)-i-1; j++ {
-			if releases[j] == nil || releases[j+1] == nil {
-				continue
-			}
-			if releases[j].Version > releases[j+1].Version {
-				releases[j], releases[j+1] = releases[j+1], releases[j]
-			}
+	history := make([]RevisionSummary, 0, len(revisions))
+	for _, r := range revisions {
+		chartVersion := "unknown"
+		if r.Chart != nil && r.Chart.Metadata != nil {
+			chartVersion = r.Chart.Metadata.Version
 		}
+		history = append(history, RevisionSummary{
+			Revision:     safeInt(r.Version),
+			Updated:      safeTime(r.Info),
+			Status:       safeStatus(r.Info),
+			Chart:        safeChartName(r.Chart),
+			ChartVersion: chartVersion,
+			AppVersion:   safeAppVersion(r.Chart),
+			Description:  safeDescription(r.Info),
+		})
 	}
-	return releases
+	return history
 }
 
-func printHistoryTable(releases []*release.Release) {
+func printHistoryTable(history []RevisionSummary) {
 	// Create table data
 	tableData := [][]string{
 		{"REVISION", "UPDATED", "STATUS", "CHART", "APP VERSION", "DESCRIPTION"},
 	}
 
-	for _, r := range releases {
-		if r == nil {
-			continue
-		}
-
+	for _, r := range history {
 		tableData = append(tableData, []string{
-			fmt.Sprintf("%d", safeInt(r.Version)),
-			safeTime(r.Info),
-			string(r.Info.Status),
-			safeChartName(r.Chart),
-			safeAppVersion(r.Chart),
-			truncateDescription(safeDescription(r.Info), 30),
+			fmt.Sprintf("%d", r.Revision),
+			r.Updated,
+			r.Status,
+			r.Chart,
+			r.AppVersion,
+			truncateDescription(r.Description, 30),
 		})
 	}
 
@@ -0,0 +1,45 @@
+package helm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseHistory(t *testing.T) {
+	rev := func(version int, status release.Status) *release.Release {
+		return &release.Release{
+			Name:    "web",
+			Version: version,
+			Info:    &release.Info{Status: status, Description: "Upgrade complete"},
+			Chart:   &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.2.0", AppVersion: "2.0"}},
+		}
+	}
+	releases := []*release.Release{
+		rev(3, release.StatusDeployed), rev(1, release.StatusSuperseded), nil,
+		rev(4, release.StatusFailed), rev(2, release.StatusSuperseded),
+	}
+
+	history := releaseHistory(releases, 2)
+	if len(history) != 2 || history[0].Revision != 3 || history[1].Revision != 4 {
+		t.Fatalf("releaseHistory(max=2) = %+v, want revisions 3 and 4", history)
+	}
+	if h := history[1]; h.Status != "failed" || h.Chart != "web-1.2.0" || h.ChartVersion != "1.2.0" || h.AppVersion != "2.0" {
+		t.Errorf("revision 4 = %+v", h)
+	}
+	if all := releaseHistory(releases, 0); len(all) != 4 || all[0].Revision != 1 {
+		t.Errorf("releaseHistory(max=0) = %+v, want all four revisions", all)
+	}
+
+	data, err := json.Marshal(releaseHistory(nil, 0))
+	if err != nil || string(data) != "[]" {
+		t.Errorf("empty history = %s, %v; want []", data, err)
+	}
+	data, _ = json.Marshal(history[0])
+	if !strings.Contains(string(data), `"chart_version":"1.2.0"`) {
+		t.Errorf("revision document = %s", data)
+	}
+}
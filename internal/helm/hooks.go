@@ -0,0 +1,249 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// hookEvents are the hook events in the order of a release's lifecycle.
+var hookEvents = []release.HookEvent{
+	release.HookPreInstall, release.HookPostInstall,
+	release.HookPreUpgrade, release.HookPostUpgrade,
+	release.HookPreRollback, release.HookPostRollback,
+	release.HookPreDelete, release.HookPostDelete,
+	release.HookTest,
+}
+
+// HookInfo describes a hook of a chart as rendered for a release.
+type HookInfo struct {
+	Name           string   `json:"name"`
+	Kind           string   `json:"kind"`
+	Events         []string `json:"events"`
+	Weight         int      `json:"weight"`
+	DeletePolicies []string `json:"deletePolicies"`
+	Source         string   `json:"source"`
+}
+
+// HooksOptions configures ListHooks.
+type HooksOptions struct {
+	Namespace        string
+	RepoURL          string
+	Version          string
+	ValuesFiles      []string
+	SetValues        []string
+	SetLiteralValues []string
+	Debug            bool
+}
+
+// ValidateHookEvents checks that every event is a helm hook event.
+func ValidateHookEvents(events []string) error {
+	for _, e := range events {
+		if hookEventIndex(release.HookEvent(e)) < 0 {
+			names := make([]string, len(hookEvents))
+			for i, h := range hookEvents {
+				names[i] = h.String()
+			}
+			return fmt.Errorf("unknown hook event %q: must be one of %s", e, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// ListHooks renders releaseName from chartRef without a cluster and prints
+// its hooks in the order helm runs them: by event, then by weight, then by
+// name. output is table, json or yaml.
+func ListHooks(releaseName, chartRef string, opts HooksOptions, output string, useAI bool) error {
+	rel, _, err := renderRelease(releaseName, chartRef, opts.Namespace, opts.RepoURL, opts.Version,
+		opts.ValuesFiles, opts.SetValues, opts.SetLiteralValues, opts.Debug)
+	if err != nil {
+		pterm.Error.Println(err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	hooks := describeHooks(rel.Hooks)
+
+	if isStructured(output) {
+		return printStructured(hooks, output)
+	}
+	if len(hooks) == 0 {
+		pterm.Info.Printfln("%s has no hooks", chartRef)
+		return nil
+	}
+	tableData := pterm.TableData{{"EVENTS", "WEIGHT", "KIND", "NAME", "DELETE POLICIES", "SOURCE"}}
+	for _, h := range hooks {
+		policies := strings.Join(h.DeletePolicies, ",")
+		if policies == "" {
+			policies = none
+		}
+		tableData = append(tableData, []string{
+			strings.Join(h.Events, ","), fmt.Sprint(h.Weight), h.Kind, h.Name, policies, h.Source,
+		})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// describeHooks returns hooks sorted by their first event in lifecycle
+// order, then by weight and name, the order helm runs them in.
+func describeHooks(hooks []*release.Hook) []HookInfo {
+	sorted := make([]*release.Hook, len(hooks))
+	copy(sorted, hooks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := firstHookEvent(sorted[i]), firstHookEvent(sorted[j])
+		if a != b {
+			return a < b
+		}
+		if sorted[i].Weight != sorted[j].Weight {
+			return sorted[i].Weight < sorted[j].Weight
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	infos := make([]HookInfo, 0, len(sorted))
+	for _, h := range sorted {
+		info := HookInfo{Name: h.Name, Kind: h.Kind, Weight: h.Weight, Source: h.Path,
+			Events: []string{}, DeletePolicies: []string{}}
+		events := append([]release.HookEvent(nil), h.Events...)
+		sort.SliceStable(events, func(i, j int) bool { return hookEventIndex(events[i]) < hookEventIndex(events[j]) })
+		for _, e := range events {
+			info.Events = append(info.Events, e.String())
+		}
+		for _, p := range h.DeletePolicies {
+			info.DeletePolicies = append(info.DeletePolicies, p.String())
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func firstHookEvent(h *release.Hook) int {
+	first := len(hookEvents)
+	for _, e := range h.Events {
+		if i := hookEventIndex(e); i >= 0 && i < first {
+			first = i
+		}
+	}
+	return first
+}
+
+func hookEventIndex(e release.HookEvent) int {
+	for i, h := range hookEvents {
+		if h == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyHookSelection keeps only the hooks of the events in
+// configs.HooksOnly when that is set: the chart is rendered as it is about
+// to be installed (or, with upgrade, upgraded) to find the templates of the
+// other hooks, and those templates are removed from chartObj. Helm hands
+// hooks to no post-renderer, so pruning the chart is the only way to skip
+// some of them. Test hooks are kept for helm test.
+func applyHookSelection(cfg *action.Configuration, chartObj *chart.Chart, releaseName, namespace string, vals map[string]interface{}, upgrade bool) error {
+	if len(configs.HooksOnly) == 0 {
+		return nil
+	}
+	if err := ValidateHookEvents(configs.HooksOnly); err != nil {
+		return err
+	}
+	client := action.NewInstall(cfg)
+	client.DryRun = true
+	client.Replace = true
+	client.IsUpgrade = upgrade
+	client.ReleaseName = releaseName
+	client.Namespace = namespace
+	client.Timeout = 5 * time.Minute
+	rel, err := client.Run(chartObj, vals)
+	if err != nil {
+		return fmt.Errorf("failed to render the hooks of %s: %w", chartObj.Name(), err)
+	}
+
+	skipped, err := hooksToSkip(rel, configs.HooksOnly)
+	if err != nil {
+		return err
+	}
+	for _, h := range skipped {
+		pterm.Info.Printfln("Skipping %s hook %s %s (%s)", strings.Join(hookEventNames(h.Events), ","), h.Kind, h.Name, h.Path)
+		removeTemplate(chartObj, chartObj.Name()+"/", h.Path)
+	}
+	return nil
+}
+
+// hooksToSkip returns the hooks of rel that have none of the events in
+// keep. A template that also renders a kept hook or a regular resource
+// can't be removed without losing it, which is an error.
+func hooksToSkip(rel *release.Release, keep []string) ([]*release.Hook, error) {
+	kept := map[string]bool{}
+	for name, doc := range releaseutil.SplitManifests(rel.Manifest) {
+		kept[manifestSource(doc, name)] = true
+	}
+	var skipped []*release.Hook
+	for _, h := range rel.Hooks {
+		if hasHookEvent(h, keep) || hasHookEvent(h, []string{release.HookTest.String()}) {
+			kept[h.Path] = true
+		} else {
+			skipped = append(skipped, h)
+		}
+	}
+	var shared []string
+	for _, h := range skipped {
+		if kept[h.Path] {
+			shared = append(shared, h.Path)
+		}
+	}
+	if len(shared) > 0 {
+		sort.Strings(shared)
+		return nil, fmt.Errorf("--hooks-only can't skip hooks rendered together with resources that must stay, from %s", strings.Join(shared, ", "))
+	}
+	return skipped, nil
+}
+
+func hasHookEvent(h *release.Hook, events []string) bool {
+	for _, e := range h.Events {
+		for _, want := range events {
+			if e.String() == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hookEventNames(events []release.HookEvent) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.String()
+	}
+	return names
+}
+
+// removeTemplate removes the template at path, as named in a rendered
+// release (CHART/templates/... or CHART/charts/SUB/templates/...), from c
+// or the subchart it belongs to. prefix is c's own prefix in such paths.
+func removeTemplate(c *chart.Chart, prefix, path string) bool {
+	if name, ok := strings.CutPrefix(path, prefix); ok {
+		for i, t := range c.Templates {
+			if t.Name == name {
+				c.Templates = append(c.Templates[:i], c.Templates[i+1:]...)
+				return true
+			}
+		}
+	}
+	for _, dep := range c.Dependencies() {
+		if removeTemplate(dep, prefix+"charts/"+dep.Name()+"/", path) {
+			return true
+		}
+	}
+	return false
+}
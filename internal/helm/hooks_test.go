@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func hookTemplate(name, events, weight string) string {
+	return `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: ` + name + `
+  annotations:
+    "helm.sh/hook": ` + events + `
+    "helm.sh/hook-weight": "` + weight + `"
+    "helm.sh/hook-delete-policy": before-hook-creation
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: run
+          image: busybox
+`
+}
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	if err := CreateChart("web", dir); err != nil {
+		t.Fatal(err)
+	}
+	templates := filepath.Join(dir, "web", "templates")
+	files := map[string]string{
+		"migrate.yaml": hookTemplate("migrate", "pre-upgrade,pre-install", "-5"),
+		"seed.yaml":    hookTemplate("seed", "post-install", "0"),
+		"warm.yaml":    hookTemplate("warm", "pre-upgrade", "1"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(templates, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rel, _, err := renderRelease("web", filepath.Join(dir, "web"), "apps", "", "", nil, nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, h := range describeHooks(rel.Hooks) {
+		order = append(order, h.Name+":"+strings.Join(h.Events, ","))
+	}
+	want := []string{"migrate:pre-install,pre-upgrade", "seed:post-install", "warm:pre-upgrade", "web-test-connection:test"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks = %v, want %v", order, want)
+	}
+
+	skipped, err := hooksToSkip(rel, []string{"pre-upgrade"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "seed" {
+		t.Fatalf("skipped = %v, want only seed", skipped)
+	}
+
+	chartObj, err := loader.Load(filepath.Join(dir, "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(chartObj.Templates)
+	if !removeTemplate(chartObj, "web/", skipped[0].Path) || len(chartObj.Templates) != before-1 {
+		t.Errorf("removeTemplate did not remove %s", skipped[0].Path)
+	}
+	if removeTemplate(chartObj, "web/", "web/templates/missing.yaml") {
+		t.Error("removeTemplate removed a template that does not exist")
+	}
+
+	// A skipped hook sharing its template with a regular resource can't go.
+	shared := hookTemplate("seed", "post-install", "0") + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: seeded\n"
+	if err := os.WriteFile(filepath.Join(templates, "seed.yaml"), []byte(shared), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rel, _, err = renderRelease("web", filepath.Join(dir, "web"), "apps", "", "", nil, nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooksToSkip(rel, []string{"pre-upgrade"}); err == nil || !strings.Contains(err.Error(), "web/templates/seed.yaml") {
+		t.Errorf("hooksToSkip = %v, want an error naming web/templates/seed.yaml", err)
+	}
+
+	if err := ValidateHookEvents([]string{"pre-upgrade", "post-deploy"}); err == nil {
+		t.Error("ValidateHookEvents accepted post-deploy")
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"os"
 	"path/filepath"
 
+	"helm.sh/helm/v3/pkg/cli"
 	"k8s.io/client-go/util/homedir"
 )
 
-// settings holds the path to the kubeconfig file.
+// settings holds the path to the kubeconfig file, and the context in it
+// when KUBECONTEXT names one.
 func init() {
 	if os.Getenv("KUBECONFIG") != "" {
 		settings.KubeConfig = os.Getenv("KUBECONFIG")
@@ -15,4 +17,33 @@ func init() {
 		home := homedir.HomeDir()
 		settings.KubeConfig = filepath.Join(home, ".kube", "config")
 	}
+	if settings.KubeContext == "" {
+		settings.KubeContext = os.Getenv("KUBECONTEXT")
+	}
+}
+
+// SetKubeTarget points every Helm action and Kubernetes client of this
+// package at the kubeContext context of the kubeconfig file, for the
+// --kubeconfig and --kube-context flags. Empty values keep the defaults
+// above. It must run before the first cluster call: the clientset is built
+// once per process.
+func SetKubeTarget(kubeconfig, kubeContext string) {
+	if kubeconfig != "" {
+		settings.KubeConfig = kubeconfig
+	}
+	if kubeContext != "" {
+		settings.KubeContext = kubeContext
+	}
+}
+
+// newSettings returns Helm settings for namespace that target the same
+// cluster as settings.
+func newSettings(namespace string) *cli.EnvSettings {
+	s := cli.New()
+	s.KubeConfig = settings.KubeConfig
+	s.KubeContext = settings.KubeContext
+	if namespace != "" {
+		s.SetNamespace(namespace)
+	}
+	return s
 }
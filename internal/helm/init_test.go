@@ -0,0 +1,19 @@
+package helm
+
+import "testing"
+
+func TestSetKubeTarget(t *testing.T) {
+	oldConfig, oldContext := settings.KubeConfig, settings.KubeContext
+	defer func() { settings.KubeConfig, settings.KubeContext = oldConfig, oldContext }()
+
+	SetKubeTarget("", "")
+	if settings.KubeConfig != oldConfig || settings.KubeContext != oldContext {
+		t.Errorf("empty flags changed the target to %s/%s", settings.KubeConfig, settings.KubeContext)
+	}
+
+	SetKubeTarget("/clusters/kubeconfig", "staging")
+	s := newSettings("apps")
+	if s.KubeConfig != "/clusters/kubeconfig" || s.KubeContext != "staging" || s.Namespace() != "apps" {
+		t.Errorf("newSettings = %s/%s/%s, want /clusters/kubeconfig/staging/apps", s.KubeConfig, s.KubeContext, s.Namespace())
+	}
+}
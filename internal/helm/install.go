@@ -9,7 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/internal/ci"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
@@ -33,6 +35,7 @@ func HelmInstall(
 	setValues, setLiteralValues []string, repoURL, version string,
 	wait bool, useAI bool,
 ) error {
+	ownsNamespace := configs.EphemeralTTL > 0 && namespaceMissing(namespace)
 	fmt.Printf("📦 Ensuring namespace '%s' exists...\n", namespace)
 	if err := ensureNamespace(namespace, true); err != nil {
 		printErrorSummary("Namespace Preparation", releaseName, namespace, chartRef, err)
@@ -40,8 +43,7 @@ func HelmInstall(
 	}
 
 	fmt.Printf("⚙️  Initializing Helm configuration...\n")
-	settings := cli.New()
-	settings.SetNamespace(namespace)
+	settings := newSettings(namespace)
 	actionConfig := new(action.Configuration)
 
 	logFn := func(format string, v ...interface{}) {
@@ -64,6 +66,12 @@ func HelmInstall(
 	client.Wait = wait
 	client.Timeout = duration
 	client.CreateNamespace = true
+	client.DisableHooks = configs.NoHooks
+	client.SkipSchemaValidation = configs.SkipSchemaValidation
+	client.PostRenderer = schedulingRenderer()
+	if configs.EphemeralTTL > 0 {
+		client.Labels = ephemeralLabels(configs.EphemeralTTL, time.Now(), ownsNamespace)
+	}
 
 	fmt.Printf("📊 Loading chart '%s'...\n", chartRef)
 	var chartObj *chart.Chart
@@ -78,14 +86,33 @@ func HelmInstall(
 
 	// Load and merge values
 	fmt.Printf("📝 Processing values and configurations...\n")
-	vals, err := loadAndMergeValuesWithSets(valuesFiles, setValues, setLiteralValues, debug)
+	vals, layers, err := loadValueLayers(namespace, valuesFiles, setValues, setLiteralValues, debug)
 	if err != nil {
 		printErrorSummary("Values Processing", releaseName, namespace, chartRef, err)
 		ai.AIExplainError(useAI, err.Error())
 		return err
 	}
+	if configs.ExplainValues {
+		if err := printValueOrigins(chartObj, vals, layers); err != nil {
+			return err
+		}
+	}
+
+	if err := checkValuesSchema(chartObj, vals); err != nil {
+		printErrorSummary("Values Schema", releaseName, namespace, chartRef, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	if err := applyHookSelection(actionConfig, chartObj, releaseName, namespace, vals, false); err != nil {
+		printErrorSummary("Hook Selection", releaseName, namespace, chartRef, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
 
 	fmt.Printf("🚀 Installing release '%s'...\n", releaseName)
+	events := startReleaseEventWatcher(namespace, releaseName, debug)
+	defer events.stop()
 
 	// Run Helm install
 	rel, err := client.Run(chartObj, vals)
@@ -106,7 +133,17 @@ func HelmInstall(
 	}
 
 	// Only if everything is healthy, print success
-	return handleInstallationSuccess(rel, namespace)
+	if err := handleInstallationSuccess(rel, namespace); err != nil {
+		return err
+	}
+	if err := ci.Export(ci.ReleaseVars(rel.Name, rel.Namespace, rel.Version)); err != nil {
+		pterm.Warning.Printfln("Could not export the release to later CI jobs: %v", err)
+	}
+	if configs.EphemeralTTL > 0 {
+		pterm.Info.Printfln("Release %s is ephemeral: smurf selm gc uninstalls it after %s",
+			releaseName, time.Now().Add(configs.EphemeralTTL).Format(time.RFC3339))
+	}
+	return nil
 }
 
 // LoadChart determines the chart source and loads it appropriately
@@ -128,58 +165,43 @@ func LoadChart(chartRef, repoURL, version string, settings *cli.EnvSettings) (*c
 	}
 
 	// Handle local chart file or directory
-	return loader.Load(chartRef)
+	return loadLocalChart(chartRef, false)
 }
 
-// LoadOCIChart loads a chart from an OCI registry
+// LoadOCIChart pulls a chart from an OCI registry into the repository cache
+// and loads it. version overrides the tag of chartRef; with configs.Verify
+// the chart must carry a provenance signed by a key in configs.Keyring.
+// When the registry client can't pull it, the helm CLI is tried, unless the
+// chart has to be verified.
 func LoadOCIChart(chartRef, version string, settings *cli.EnvSettings, debug bool) (*chart.Chart, error) {
 	if debug {
 		pterm.Printf("Loading OCI chart: %s (version: %s)\n", chartRef, version)
 	}
 
-	// Ensure cache directory exists
 	if err := ensureHelmCacheDir(settings.RepositoryCache); err != nil {
 		return nil, fmt.Errorf("failed to create helm cache directory: %w", err)
 	}
 
-	// Create registry client
-	registryClient, err := newRegistryClient(debug)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create registry client: %w", err)
-	}
-
-	// Create action configuration with registry client
-	actionConfig := &action.Configuration{
-		RegistryClient: registryClient,
-	}
-
-	// Create pull action
-	pull := action.NewPullWithOpts(action.WithConfig(actionConfig))
-	pull.Settings = settings
-	pull.Version = version
-	pull.Untar = false // Keep as .tgz file
-	pull.DestDir = settings.RepositoryCache
-
-	// Run the pull command
 	fmt.Printf("⬇️  Pulling OCI chart: %s...\n", chartRef)
-	downloadedFile, err := pull.Run(chartRef)
+	path, err := locateChart(chartRef, action.ChartPathOptions{Version: version}, settings, debug)
 	if err != nil {
-		// The error might be about the file path, not the pull itself
+		if configs.Verify {
+			return nil, fmt.Errorf("failed to pull OCI chart %s: %w", chartRef, err)
+		}
 		if debug {
-			fmt.Printf("⚠️  Pull returned error but may have succeeded: %v\n", err)
-			fmt.Printf("⚠️  Downloaded file path from pull.Run(): %s\n", downloadedFile)
+			fmt.Printf("⚠️  Registry client pull failed: %v\n", err)
 		}
-
-		// Continue to try loading the chart anyway
-		return findAndLoadChartFromCache(chartRef, settings, debug)
+		chartObj, cliErr := pullWithHelmCLI(chartRef, version, settings, debug)
+		if cliErr != nil {
+			return nil, fmt.Errorf("failed to pull OCI chart %s: %w", chartRef, err)
+		}
+		return chartObj, nil
 	}
 
 	if debug {
-		fmt.Printf("✅ Pull reported success, downloaded to: %s\n", downloadedFile)
+		fmt.Printf("✅ Pulled %s to %s\n", chartRef, path)
 	}
-
-	// Try to find and load the chart
-	return findAndLoadChartFromCache(chartRef, settings, debug)
+	return loader.Load(path)
 }
 
 // Helper function to ensure helm cache directory exists
@@ -195,96 +217,9 @@ func ensureHelmCacheDir(cacheDir string) error {
 	return nil
 }
 
-// Helper function to find and load chart from cache
-func findAndLoadChartFromCache(chartRef string, settings *cli.EnvSettings, debug bool) (*chart.Chart, error) {
-	// Ensure directory exists (double-check)
-	if err := ensureHelmCacheDir(settings.RepositoryCache); err != nil {
-		return nil, err
-	}
-
-	// List all files in cache directory
-	files, err := os.ReadDir(settings.RepositoryCache)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read cache directory %s: %w", settings.RepositoryCache, err)
-	}
-
-	if debug {
-		fmt.Printf("📁 Searching for chart in cache directory: %s\n", settings.RepositoryCache)
-		fmt.Printf("📁 Files found (%d):\n", len(files))
-		for i, file := range files {
-			info, _ := file.Info()
-			fmt.Printf("  %d. %s (size: %d)\n", i+1, file.Name(), info.Size())
-		}
-	}
-
-	// If no files found, try a different approach
-	if len(files) == 0 {
-		fmt.Println("⚠️  No files found in cache, attempting direct helm CLI pull...")
-		return pullWithHelmCLI(chartRef, settings, debug)
-	}
-
-	// Extract chart name from OCI reference
-	ref := strings.TrimPrefix(chartRef, oci)
-	baseName := filepath.Base(ref)
-
-	// Remove tag if present
-	chartName := baseName
-	if idx := strings.LastIndex(chartName, ":"); idx != -1 {
-		chartName = chartName[:idx]
-	}
-
-	if debug {
-		fmt.Printf("🔍 Looking for chart matching: %s\n", chartName)
-	}
-
-	// Look for .tgz files (most common)
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".tgz") {
-			fullPath := filepath.Join(settings.RepositoryCache, file.Name())
-
-			if debug {
-				fmt.Printf("   Trying .tgz file: %s\n", file.Name())
-			}
-
-			chartObj, err := loader.Load(fullPath)
-			if err == nil {
-				if debug {
-					fmt.Printf("✅ Successfully loaded chart from: %s\n", fullPath)
-				}
-				return chartObj, nil
-			}
-
-			if debug {
-				fmt.Printf("❌ Failed to load as chart: %v\n", err)
-			}
-		}
-	}
-
-	// Try any file (might not have .tgz extension)
-	for _, file := range files {
-		if !file.IsDir() {
-			fullPath := filepath.Join(settings.RepositoryCache, file.Name())
-
-			if debug {
-				fmt.Printf("   Trying any file: %s\n", file.Name())
-			}
-
-			chartObj, err := loader.Load(fullPath)
-			if err == nil {
-				if debug {
-					fmt.Printf("✅ Successfully loaded chart from: %s\n", fullPath)
-				}
-				return chartObj, nil
-			}
-		}
-	}
-
-	return nil, fmt.Errorf("no valid chart file found in cache directory: %s", settings.RepositoryCache)
-}
-
-// Fallback function using helm CLI directly
-// Fallback function using helm CLI directly
-func pullWithHelmCLI(chartRef string, settings *cli.EnvSettings, debug bool) (*chart.Chart, error) {
+// pullWithHelmCLI pulls chartRef with the helm CLI, as a fallback for
+// registries the registry client can't pull from.
+func pullWithHelmCLI(chartRef, version string, settings *cli.EnvSettings, debug bool) (*chart.Chart, error) {
 	fmt.Printf("🔄 Using helm CLI for OCI pull...\n")
 
 	// Ensure cache directory exists
@@ -302,17 +237,8 @@ func pullWithHelmCLI(chartRef string, settings *cli.EnvSettings, debug bool) (*c
 	// Build helm command
 	args := []string{"pull", chartRef, "--destination", tempDir}
 
-	// Add version if specified
-	if strings.Contains(chartRef, ":") {
-		// Version might be in the chartRef itself
-		fmt.Printf("📦 Chart reference includes version/tag\n")
-	} else {
-		// Parse version from chartRef or use default
-		ref := strings.TrimPrefix(chartRef, oci)
-		if idx := strings.LastIndex(ref, ":"); idx != -1 {
-			version := ref[idx+1:]
-			args = append(args, "--version", version)
-		}
+	if version != "" {
+		args = append(args, "--version", version)
 	}
 
 	if debug {
@@ -637,8 +563,9 @@ func copyFile(src, dst string) error {
 	return os.WriteFile(dst, input, 0644)
 }
 
-// newRegistryClient creates a registry client for OCI operations
-func newRegistryClient(debug bool) (*registry.Client, error) {
+// newRegistryClient creates a registry client for OCI operations. extra
+// options are applied last.
+func newRegistryClient(debug bool, extra ...registry.ClientOption) (*registry.Client, error) {
 	// Create registry client options
 	opts := []registry.ClientOption{
 		registry.ClientOptWriter(os.Stderr), // Use stderr for debug output
@@ -648,16 +575,19 @@ func newRegistryClient(debug bool) (*registry.Client, error) {
 	// Try multiple credential sources
 	helmConfig := helmHome()
 
-	// Check for Docker config in multiple locations
+	// Check for logins in multiple locations, selm registry login's first
 	possibleCredFiles := []string{
+		settings.RegistryConfig,
 		filepath.Join(helmConfig, "config.json"),
 		filepath.Join(os.Getenv("HOME"), ".docker/config.json"),
 		"/etc/docker/config.json",
 		filepath.Join(os.Getenv("HOME"), ".helm/registry/config.json"),
 	}
 
+	credentialsFile := ""
 	for _, credFile := range possibleCredFiles {
 		if _, err := os.Stat(credFile); err == nil {
+			credentialsFile = credFile
 			opts = append(opts, registry.ClientOptCredentialsFile(credFile))
 			if debug {
 				pterm.Printf("Using credentials file: %s\n", credFile)
@@ -668,9 +598,14 @@ func newRegistryClient(debug bool) (*registry.Client, error) {
 
 	// Also check for environment variables
 	if auth := os.Getenv("HELM_REGISTRY_CONFIG"); auth != "" {
+		credentialsFile = auth
 		opts = append(opts, registry.ClientOptCredentialsFile(auth))
 	}
 
+	// Resolve logins like smurf's image pushes, falling back to the file
+	opts = append(opts, registryAuthorizer(credentialsFile))
+	opts = append(opts, extra...)
+
 	// Create and return the registry client
 	client, err := registry.NewClient(opts...)
 	if err != nil {
@@ -710,31 +645,37 @@ func LoadFromLocalRepo(chartRef, version string, settings *cli.EnvSettings) (*ch
 		return nil, fmt.Errorf("failed to load repository file: %v", err)
 	}
 
-	repoURL := ""
-	for _, r := range repoFile.Repositories {
-		if r.Name == repoName {
-			repoURL = r.URL
-			break
-		}
-	}
-
-	if repoURL == "" {
+	entry := repoFile.Get(repoName)
+	if entry == nil {
 		pterm.Error.Printfln("repository %s not found in local repositories", repoName)
 		return nil, fmt.Errorf("repository %s not found in local repositories", repoName)
 	}
 
-	return LoadRemoteChart(chartName, repoURL, version, settings)
+	auths, err := loadRepoAuths(settings.RepositoryConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository auth: %v", err)
+	}
+	providers, entry, err := repoProviders(settings, entry, auths[repoName])
+	if err != nil {
+		return nil, err
+	}
+	return loadRepoChart(entry, chartName, version, settings, providers)
 }
 
 // loadRemoteChart downloads and loads a chart from a remote repository
 func LoadRemoteChart(chartName, repoURL string, version string, settings *cli.EnvSettings) (*chart.Chart, error) {
-	fmt.Printf("🔗 Connecting to repository %s...\n", repoURL)
 	repoEntry := &repo.Entry{
 		Name: "temp-repo",
 		URL:  repoURL,
 	}
+	return loadRepoChart(repoEntry, chartName, version, settings, getter.All(settings))
+}
 
-	chartRepo, err := repo.NewChartRepository(repoEntry, getter.All(settings))
+// loadRepoChart downloads and loads a chart from the repository entry,
+// fetching with providers and the entry's basic auth and TLS settings.
+func loadRepoChart(repoEntry *repo.Entry, chartName, version string, settings *cli.EnvSettings, providers getter.Providers) (*chart.Chart, error) {
+	fmt.Printf("🔗 Connecting to repository %s...\n", repoEntry.URL)
+	chartRepo, err := repo.NewChartRepository(repoEntry, providers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chart repository: %v", err)
 	}
@@ -745,7 +686,8 @@ func LoadRemoteChart(chartName, repoURL string, version string, settings *cli.En
 	}
 
 	fmt.Printf("🔍 Finding chart %s in repository...\n", chartName)
-	chartURL, err := repo.FindChartInRepoURL(repoURL, chartName, version, "", "", "", getter.All(settings))
+	chartURL, err := repo.FindChartInAuthAndTLSAndPassRepoURL(repoEntry.URL, repoEntry.Username, repoEntry.Password, chartName, version,
+		repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile, repoEntry.InsecureSkipTLSverify, repoEntry.PassCredentialsAll, providers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find chart in repository: %v", err)
 	}
@@ -753,8 +695,13 @@ func LoadRemoteChart(chartName, repoURL string, version string, settings *cli.En
 	fmt.Printf("⬇️  Downloading chart...\n")
 	chartDownloader := downloader.ChartDownloader{
 		Out:     os.Stdout,
-		Getters: getter.All(settings),
-		Options: []getter.Option{},
+		Getters: providers,
+		Options: []getter.Option{
+			getter.WithBasicAuth(repoEntry.Username, repoEntry.Password),
+			getter.WithPassCredentialsAll(repoEntry.PassCredentialsAll),
+			getter.WithTLSClientConfig(repoEntry.CertFile, repoEntry.KeyFile, repoEntry.CAFile),
+			getter.WithInsecureSkipVerifyTLS(repoEntry.InsecureSkipTLSverify),
+		},
 	}
 
 	chartPath, _, err := chartDownloader.DownloadTo(chartURL, version, settings.RepositoryCache)
@@ -0,0 +1,165 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// interpolationPattern matches the references expanded when selm.interpolate
+// is on: ${VAR}, ${VAR:-default}, and {{ .Git.Field }} / {{ .Env.VAR }}.
+// Any other {{ ... }} is left alone, since values files often carry templates
+// meant for the chart's tpl function. A $ or { doubled in front ($${VAR},
+// {{{ .Git.SHA }}) is an escape and yields the reference literally.
+var interpolationPattern = regexp.MustCompile(
+	`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}` +
+		`|\{?\{\{-?\s*\.(Git|Env)\.([A-Za-z_][A-Za-z0-9_]*)\s*-?\}\}`)
+
+// gitFields are the {{ .Git.* }} variables, each resolved with git in the
+// directory of the file that references it.
+var gitFields = map[string][]string{
+	"SHA":      {"rev-parse", "HEAD"},
+	"ShortSHA": {"rev-parse", "--short", "HEAD"},
+	"Branch":   {"rev-parse", "--abbrev-ref", "HEAD"},
+	"Tag":      {"describe", "--tags", "--abbrev=0"},
+}
+
+// interpolator expands references in values files and --set strings. Git
+// lookups are cached per directory so a values file referencing the commit
+// many times runs git once.
+type interpolator struct {
+	lookupEnv func(string) (string, bool)
+	git       func(dir string, args ...string) (string, error)
+	gitCache  map[string]string
+}
+
+func newInterpolator() *interpolator {
+	return &interpolator{
+		lookupEnv: os.LookupEnv,
+		git:       runGit,
+		gitCache:  map[string]string{},
+	}
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// expand interpolates every reference in text. source names the text in
+// errors (a file path or "--set"), and dir is where git runs. All undefined
+// references are reported together, with their line numbers.
+func (in *interpolator) expand(text, source, dir string) (string, error) {
+	var problems []string
+	lines := strings.SplitAfter(text, "\n")
+	for n, line := range lines {
+		lines[n] = interpolationPattern.ReplaceAllStringFunc(line, func(match string) string {
+			if strings.HasPrefix(match, "$$") || strings.HasPrefix(match, "{{{") {
+				return match[1:]
+			}
+			value, err := in.resolve(match, dir)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s:%d: %v", source, n+1, err))
+				return match
+			}
+			return value
+		})
+	}
+	if len(problems) > 0 {
+		return "", fmt.Errorf("values interpolation failed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return strings.Join(lines, ""), nil
+}
+
+func (in *interpolator) resolve(match, dir string) (string, error) {
+	m := interpolationPattern.FindStringSubmatch(match)
+	if m[1] != "" {
+		if value, ok := in.lookupEnv(m[1]); ok {
+			return value, nil
+		}
+		if m[2] != "" {
+			return m[3], nil
+		}
+		return "", fmt.Errorf("undefined variable %s", m[1])
+	}
+
+	if m[4] == "Env" {
+		if value, ok := in.lookupEnv(m[5]); ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("undefined variable .Env.%s", m[5])
+	}
+
+	args, ok := gitFields[m[5]]
+	if !ok {
+		return "", fmt.Errorf("unknown variable .Git.%s (available: %s)", m[5], strings.Join(sortedGitFields(), ", "))
+	}
+	key := dir + "\x00" + m[5]
+	if value, ok := in.gitCache[key]; ok {
+		return value, nil
+	}
+	value, err := in.git(dir, args...)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve .Git.%s: %v", m[5], err)
+	}
+	in.gitCache[key] = value
+	return value, nil
+}
+
+func sortedGitFields() []string {
+	names := make([]string, 0, len(gitFields))
+	for name := range gitFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readValuesFile reads a values file like chartutil.ReadValuesFile, expanding
+// references first when selm.interpolate is enabled.
+func readValuesFile(path string, in *interpolator) (chartutil.Values, error) {
+	if !configs.Interpolate {
+		return chartutil.ReadValuesFile(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := in.expand(string(data), path, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return chartutil.ReadValues([]byte(expanded))
+}
+
+// interpolateSets expands references in --set style strings when
+// selm.interpolate is enabled; git runs in the current directory.
+func interpolateSets(sets []string, in *interpolator) ([]string, error) {
+	if !configs.Interpolate || len(sets) == 0 {
+		return sets, nil
+	}
+	out := make([]string, len(sets))
+	for i, set := range sets {
+		expanded, err := in.expand(set, "--set", ".")
+		if err != nil {
+			return nil, err
+		}
+		out[i] = expanded
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+func testInterpolator(env map[string]string) (*interpolator, *int) {
+	gitCalls := 0
+	in := &interpolator{
+		lookupEnv: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+		git: func(dir string, args ...string) (string, error) {
+			gitCalls++
+			switch strings.Join(args, " ") {
+			case "rev-parse --short HEAD":
+				return "abc1234", nil
+			case "rev-parse --abbrev-ref HEAD":
+				return "main", nil
+			}
+			return "", errors.New("not a git repository")
+		},
+		gitCache: map[string]string{},
+	}
+	return in, &gitCalls
+}
+
+func TestInterpolatorExpand(t *testing.T) {
+	in, gitCalls := testInterpolator(map[string]string{"REGISTRY": "ghcr.io/org", "EMPTY": ""})
+
+	text := `image:
+  repository: ${REGISTRY}/app
+  tag: "{{ .Git.ShortSHA }}"
+  pullPolicy: ${PULL_POLICY:-IfNotPresent}
+labels:
+  branch: {{ .Git.Branch }}
+  commit: {{ .Git.ShortSHA }}
+  empty: "${EMPTY}"
+  literal: $${REGISTRY}
+  registry: "{{ .Env.REGISTRY }}"
+  chartTemplate: "{{ .Values.image.tag }}"
+`
+	want := `image:
+  repository: ghcr.io/org/app
+  tag: "abc1234"
+  pullPolicy: IfNotPresent
+labels:
+  branch: main
+  commit: abc1234
+  empty: ""
+  literal: ${REGISTRY}
+  registry: "ghcr.io/org"
+  chartTemplate: "{{ .Values.image.tag }}"
+`
+	got, err := in.expand(text, "values.yaml", ".")
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if got != want {
+		t.Errorf("expand =\n%s\nwant\n%s", got, want)
+	}
+	if *gitCalls != 2 {
+		t.Errorf("git ran %d times, want 2 (cached per field)", *gitCalls)
+	}
+}
+
+func TestInterpolatorErrors(t *testing.T) {
+	in, _ := testInterpolator(nil)
+
+	_, err := in.expand("a: ${DB_HOST}\nb: ok\nc: {{ .Git.Commit }}\nd: {{ .Git.SHA }}\n", "values.yaml", ".")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"values.yaml:1: undefined variable DB_HOST",
+		"values.yaml:3: unknown variable .Git.Commit",
+		"values.yaml:4: cannot resolve .Git.SHA",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestReadValuesFileInterpolate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte("replicas: ${TEST_SMURF_REPLICAS}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SMURF_REPLICAS", "3")
+
+	prev := configs.Interpolate
+	t.Cleanup(func() { configs.Interpolate = prev })
+
+	configs.Interpolate = false
+	vals, err := readValuesFile(path, newInterpolator())
+	if err != nil {
+		t.Fatalf("readValuesFile: %v", err)
+	}
+	if vals["replicas"] != "${TEST_SMURF_REPLICAS}" {
+		t.Errorf("replicas = %v, want the reference untouched when interpolation is off", vals["replicas"])
+	}
+
+	configs.Interpolate = true
+	vals, err = readValuesFile(path, newInterpolator())
+	if err != nil {
+		t.Fatalf("readValuesFile: %v", err)
+	}
+	if vals["replicas"] != float64(3) {
+		t.Errorf("replicas = %#v, want 3", vals["replicas"])
+	}
+}
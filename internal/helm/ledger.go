@@ -0,0 +1,256 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ledgerRecordsKey is the ConfigMap data key holding the JSON-encoded
+	// deploy records, oldest first.
+	ledgerRecordsKey = "records.json"
+
+	// ledgerMaxRecords caps how many records a release's ledger keeps, so
+	// the ConfigMap stays far below the 1MiB object size limit.
+	ledgerMaxRecords = 100
+
+	ledgerLabel = "smurf.clouddrove.com/ledger"
+)
+
+// DeployRecord is one entry in a release's deploy ledger, written by every
+// `smurf deploy` run that targets a Helm release.
+type DeployRecord struct {
+	Time         time.Time `json:"time" yaml:"time"`
+	Release      string    `json:"release" yaml:"release"`
+	Namespace    string    `json:"namespace" yaml:"namespace"`
+	Image        string    `json:"image,omitempty" yaml:"image,omitempty"`
+	ImageDigest  string    `json:"imageDigest,omitempty" yaml:"imageDigest,omitempty"`
+	Chart        string    `json:"chart,omitempty" yaml:"chart,omitempty"`
+	ChartVersion string    `json:"chartVersion,omitempty" yaml:"chartVersion,omitempty"`
+	ValuesHash   string    `json:"valuesHash,omitempty" yaml:"valuesHash,omitempty"`
+	Duration     string    `json:"duration" yaml:"duration"`
+	Result       string    `json:"result" yaml:"result"`
+	Error        string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Actor        string    `json:"actor,omitempty" yaml:"actor,omitempty"`
+}
+
+// ledgerName returns the name of the ConfigMap holding a release's ledger.
+func ledgerName(releaseName string) string {
+	return "smurf-ledger-" + releaseName
+}
+
+// RecordDeploy appends rec to the deploy ledger of rec.Release in
+// rec.Namespace, creating the ledger ConfigMap on first use. Only the most
+// recent ledgerMaxRecords entries are kept.
+func RecordDeploy(rec DeployRecord) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return appendLedgerRecord(ctx, clientset, rec)
+}
+
+// DeployHistory returns the deploy ledger of releaseName in namespace,
+// oldest first. A release that was never deployed with smurf has an empty
+// history rather than an error.
+func DeployHistory(releaseName, namespace string) ([]DeployRecord, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return readLedger(ctx, clientset, releaseName, namespace)
+}
+
+// LastDeployedDigest returns the image digest of the last successful deploy
+// of releaseName in namespace, or "" when its ledger records none.
+func LastDeployedDigest(releaseName, namespace string) (string, error) {
+	records, err := DeployHistory(releaseName, namespace)
+	if err != nil {
+		return "", err
+	}
+	return lastDeployedDigest(records), nil
+}
+
+// lastDeployedDigest returns the image digest of the newest successful
+// record that has one.
+func lastDeployedDigest(records []DeployRecord) string {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Result == "success" && records[i].ImageDigest != "" {
+			return records[i].ImageDigest
+		}
+	}
+	return ""
+}
+
+func appendLedgerRecord(ctx context.Context, clientset kubernetes.Interface, rec DeployRecord) error {
+	configMaps := clientset.CoreV1().ConfigMaps(rec.Namespace)
+	name := ledgerName(rec.Release)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			records, err := encodeLedgerRecords([]DeployRecord{rec})
+			if err != nil {
+				return err
+			}
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: rec.Namespace,
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "smurf",
+						ledgerLabel:                    rec.Release,
+					},
+				},
+				Data: map[string]string{ledgerRecordsKey: records},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Lost a race with a concurrent deploy; retry as an update.
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		existing, err := decodeLedgerRecords(cm.Data[ledgerRecordsKey])
+		if err != nil {
+			return fmt.Errorf("ledger %s/%s is corrupt: %w", rec.Namespace, name, err)
+		}
+
+		existing = append(existing, rec)
+		if len(existing) > ledgerMaxRecords {
+			existing = existing[len(existing)-ledgerMaxRecords:]
+		}
+
+		records, err := encodeLedgerRecords(existing)
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[ledgerRecordsKey] = records
+
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func readLedger(ctx context.Context, clientset kubernetes.Interface, releaseName, namespace string) ([]DeployRecord, error) {
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, ledgerName(releaseName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []DeployRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy ledger: %w", err)
+	}
+
+	return decodeLedgerRecords(cm.Data[ledgerRecordsKey])
+}
+
+func encodeLedgerRecords(records []DeployRecord) (string, error) {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode deploy ledger: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeLedgerRecords(data string) ([]DeployRecord, error) {
+	records := []DeployRecord{}
+	if data == "" {
+		return records, nil
+	}
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PrintDeployHistory renders deploy records, newest first, as a table or as
+// a JSON/YAML document. limit caps the number of records shown (0 = all).
+func PrintDeployHistory(records []DeployRecord, format string, limit int) error {
+	// Newest first, which is what an operator scanning the timeline wants.
+	ordered := make([]DeployRecord, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		ordered = append(ordered, records[i])
+	}
+	if limit > 0 && len(ordered) > limit {
+		ordered = ordered[:limit]
+	}
+
+	if isStructured(format) {
+		return printStructured(ordered, format)
+	}
+
+	if len(ordered) == 0 {
+		pterm.Info.Println("No deploy history recorded for this release.")
+		return nil
+	}
+
+	tableData := pterm.TableData{{"TIME", "RESULT", "IMAGE", "DIGEST", "CHART VERSION", "VALUES HASH", "DURATION", "ACTOR"}}
+	for _, r := range ordered {
+		result := pterm.Green(r.Result)
+		if r.Result != "success" {
+			result = pterm.Red(r.Result)
+		}
+		tableData = append(tableData, []string{
+			r.Time.Local().Format(dateTimeFormat),
+			result,
+			r.Image,
+			shortHash(r.ImageDigest),
+			r.ChartVersion,
+			shortHash(r.ValuesHash),
+			r.Duration,
+			r.Actor,
+		})
+	}
+
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// shortHash trims "sha256:<hex>" style digests to 12 hex characters for
+// table output.
+func shortHash(h string) string {
+	h = strings.TrimPrefix(h, "sha256:")
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// ChartVersion returns the version declared by a local chart directory or
+// archive, or "" when chartRef isn't a local chart (repo/OCI references are
+// resolved at install time, not here).
+func ChartVersion(chartRef string) string {
+	if _, err := os.Stat(chartRef); err != nil {
+		return ""
+	}
+	chrt, err := loader.Load(chartRef)
+	if err != nil || chrt.Metadata == nil {
+		return ""
+	}
+	return chrt.Metadata.Version
+}
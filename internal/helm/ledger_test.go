@@ -0,0 +1,80 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeployLedger(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+
+	records, err := readLedger(ctx, clientset, "web", "apps")
+	if err != nil || len(records) != 0 {
+		t.Fatalf("readLedger(no ledger) = %v, %v; want empty, nil", records, err)
+	}
+
+	for i := 0; i < ledgerMaxRecords+5; i++ {
+		rec := DeployRecord{
+			Time:      time.Unix(int64(i), 0).UTC(),
+			Release:   "web",
+			Namespace: "apps",
+			Image:     fmt.Sprintf("org/web:%d", i),
+			Result:    "success",
+		}
+		if err := appendLedgerRecord(ctx, clientset, rec); err != nil {
+			t.Fatalf("appendLedgerRecord #%d: %v", i, err)
+		}
+	}
+
+	records, err = readLedger(ctx, clientset, "web", "apps")
+	if err != nil {
+		t.Fatalf("readLedger: %v", err)
+	}
+	if len(records) != ledgerMaxRecords {
+		t.Fatalf("got %d records, want %d", len(records), ledgerMaxRecords)
+	}
+	if first := records[0].Image; first != "org/web:5" {
+		t.Errorf("oldest kept record = %q, want org/web:5", first)
+	}
+	if last := records[len(records)-1].Image; last != fmt.Sprintf("org/web:%d", ledgerMaxRecords+4) {
+		t.Errorf("newest record = %q", last)
+	}
+
+	other, err := readLedger(ctx, clientset, "api", "apps")
+	if err != nil || len(other) != 0 {
+		t.Errorf("ledgers must be per release; got %v, %v", other, err)
+	}
+}
+
+func TestShortHash(t *testing.T) {
+	cases := map[string]string{
+		"sha256:0123456789abcdef0123": "0123456789ab",
+		"abc":                         "abc",
+		"":                            "",
+	}
+	for in, want := range cases {
+		if got := shortHash(in); got != want {
+			t.Errorf("shortHash(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLastDeployedDigest(t *testing.T) {
+	records := []DeployRecord{
+		{Result: "success", ImageDigest: "sha256:aaa"},
+		{Result: "success", ImageDigest: "sha256:bbb"},
+		{Result: "failed", ImageDigest: "sha256:ccc"},
+		{Result: "success"},
+	}
+	if got := lastDeployedDigest(records); got != "sha256:bbb" {
+		t.Errorf("lastDeployedDigest = %q, want the digest of the last successful deploy with one", got)
+	}
+	if got := lastDeployedDigest(nil); got != "" {
+		t.Errorf("lastDeployedDigest(no records) = %q", got)
+	}
+}
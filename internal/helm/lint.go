@@ -6,7 +6,6 @@ import (
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 // HelmLint runs Helm's built-in linting on a specified chart directory or tarball,
@@ -20,8 +19,9 @@ func HelmLint(chartPath string, fileValues []string, useAI bool) error {
 	client := action.NewLint()
 
 	vals := make(map[string]interface{})
+	in := newInterpolator()
 	for _, f := range fileValues {
-		additionalVals, err := chartutil.ReadValuesFile(f)
+		additionalVals, err := readValuesFile(f, in)
 		if err != nil {
 			pterm.Error.Printfln("Failed to read values file '%s': %v \n", f, err)
 			ai.AIExplainError(useAI, err.Error())
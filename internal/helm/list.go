@@ -1,14 +1,12 @@
 package helm
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
-	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -91,19 +89,15 @@ func ListReleaseNames(namespace string, timeout time.Duration) ([]string, error)
 }
 
 func printOutput(releases []*release.Release, format, namespace string) error {
-	if len(releases) == 0 {
+	summaries := releaseSummaries(releases)
+	if isStructured(format) {
+		return printStructured(summaries, format)
+	}
+	if len(summaries) == 0 {
 		printNoReleasesFound(namespace)
 		return nil
 	}
-
-	switch format {
-	case "json":
-		return printJSON(convertToElements(releases))
-	case "yaml":
-		return printYAML(convertToElements(releases))
-	default:
-		printTable(releases)
-	}
+	printTable(summaries)
 	return nil
 }
 
@@ -116,43 +110,56 @@ func printNoReleasesFound(namespace string) {
 	}
 }
 
-// printJSON marshals v as indented JSON and prints it alone, so it doubles
-// as the machine-readable output helper for list, status, and history.
-func printJSON(v interface{}) error {
-	data, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return fmt.Errorf("json marshal error: %w", err)
+// ReleaseSummary is one release as selm list reports it.
+type ReleaseSummary struct {
+	Name       string `json:"name" yaml:"name"`
+	Namespace  string `json:"namespace" yaml:"namespace"`
+	Revision   int    `json:"revision" yaml:"revision"`
+	Updated    string `json:"updated" yaml:"updated"`
+	Status     string `json:"status" yaml:"status"`
+	Chart      string `json:"chart" yaml:"chart"`
+	AppVersion string `json:"app_version" yaml:"app_version"`
+}
+
+// releaseSummary summarizes rel.
+func releaseSummary(rel *release.Release) ReleaseSummary {
+	return ReleaseSummary{
+		Name:       rel.Name,
+		Namespace:  rel.Namespace,
+		Revision:   rel.Version,
+		Updated:    safeTime(rel.Info),
+		Status:     safeStatus(rel.Info),
+		Chart:      safeChartName(rel.Chart),
+		AppVersion: safeAppVersion(rel.Chart),
 	}
-	fmt.Println(string(data))
-	return nil
 }
 
-// printYAML marshals v as YAML and prints it alone, mirroring printJSON.
-func printYAML(v interface{}) error {
-	data, err := yaml.Marshal(v)
-	if err != nil {
-		return fmt.Errorf("yaml marshal error: %w", err)
+func releaseSummaries(releases []*release.Release) []ReleaseSummary {
+	summaries := make([]ReleaseSummary, 0, len(releases))
+	for _, r := range releases {
+		if r != nil {
+			summaries = append(summaries, releaseSummary(r))
+		}
 	}
-	fmt.Println(string(data))
-	return nil
+	return summaries
 }
 
 // List table
-func printTable(releases []*release.Release) {
+func printTable(summaries []ReleaseSummary) {
 	table := pterm.TableData{{
 		"NAME", "NAMESPACE", "REVISION", "UPDATED", "STATUS",
 		"CHART", "APP VERSION",
 	}}
 
-	for _, r := range releases {
+	for _, r := range summaries {
 		table = append(table, []string{
 			r.Name,
 			r.Namespace,
-			fmt.Sprintf("%d", r.Version),
-			formatTime(r.Info.LastDeployed),
-			r.Info.Status.String(),
-			fmt.Sprintf("%s-%s", r.Chart.Metadata.Name, r.Chart.Metadata.Version),
-			r.Chart.Metadata.AppVersion,
+			fmt.Sprintf("%d", r.Revision),
+			r.Updated,
+			r.Status,
+			r.Chart,
+			r.AppVersion,
 		})
 	}
 
@@ -163,22 +170,6 @@ func printTable(releases []*release.Release) {
 		Render()
 }
 
-func convertToElements(releases []*release.Release) []map[string]interface{} {
-	var elements []map[string]interface{}
-	for _, r := range releases {
-		elements = append(elements, map[string]interface{}{
-			"name":        r.Name,
-			"namespace":   r.Namespace,
-			"revision":    r.Version,
-			"updated":     formatTime(r.Info.LastDeployed),
-			"status":      r.Info.Status.String(),
-			"chart":       fmt.Sprintf("%s-%s", r.Chart.Metadata.Name, r.Chart.Metadata.Version),
-			"app_version": r.Chart.Metadata.AppVersion,
-		})
-	}
-	return elements
-}
-
 // time format
 func formatTime(t helmtime.Time) string {
 	return t.Format("2006-01-02 15:04:05")
@@ -0,0 +1,89 @@
+package helm
+
+import (
+	"reflect"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+// mergeValues merges the values layer b over a. Maps are merged key by key;
+// the lists at the paths of strategies are combined as the strategy says and
+// any other value of b replaces a's. path is where a and b sit in the values.
+func mergeValues(a, b map[string]interface{}, path []string, strategies configs.ListMerge) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		at := append(path[:len(path):len(path)], k)
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if prev, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(prev, v, at, strategies)
+				continue
+			}
+		case []interface{}:
+			if prev, ok := out[k].([]interface{}); ok && len(strategies) > 0 {
+				out[k] = mergeList(prev, v, at, strategies)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// mergeList combines the list b of a later values layer with the list a at
+// path, with the strategy selm.listMerge gives path.
+func mergeList(a, b []interface{}, path []string, strategies configs.ListMerge) []interface{} {
+	switch strategies.Strategy(path) {
+	case configs.ListMergeAppend:
+		out := append([]interface{}{}, a...)
+		for _, item := range b {
+			if !containsValue(out, item) {
+				out = append(out, item)
+			}
+		}
+		return out
+	case configs.ListMergeByName:
+		out := append([]interface{}{}, a...)
+		for _, item := range b {
+			i := indexByName(out, item)
+			if i < 0 {
+				out = append(out, item)
+				continue
+			}
+			prev, _ := out[i].(map[string]interface{})
+			// The items' own lists, e.g. a container's env, are merged
+			// by the strategy of path.key.
+			out[i] = mergeValues(prev, item.(map[string]interface{}), path, strategies)
+		}
+		return out
+	}
+	return b
+}
+
+// indexByName returns the index of the item of list with the name of item,
+// or -1 when item has no name or none matches.
+func indexByName(list []interface{}, item interface{}) int {
+	m, ok := item.(map[string]interface{})
+	if !ok || m["name"] == nil {
+		return -1
+	}
+	for i, existing := range list {
+		if e, ok := existing.(map[string]interface{}); ok && reflect.DeepEqual(e["name"], m["name"]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsValue reports whether list holds an element deeply equal to v.
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
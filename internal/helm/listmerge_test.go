@@ -0,0 +1,95 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+	"sigs.k8s.io/yaml"
+)
+
+const listMergeBase = `
+env:
+- name: LOG_LEVEL
+  value: info
+- name: REGION
+  value: us-east-1
+extraVolumes:
+- name: cache
+args: [--serve]
+containers:
+- name: web
+  env:
+  - name: A
+    value: "1"
+`
+
+const listMergeProd = `
+env:
+- name: LOG_LEVEL
+  value: warn
+- name: SENTRY_DSN
+  value: https://sentry.example.com
+extraVolumes:
+- name: cache
+- name: certs
+args: [--serve, --prod]
+containers:
+- name: web
+  env:
+  - name: B
+    value: "2"
+`
+
+func TestMergeValuesListStrategies(t *testing.T) {
+	var base, prod map[string]interface{}
+	if err := yaml.Unmarshal([]byte(listMergeBase), &base); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(listMergeProd), &prod); err != nil {
+		t.Fatal(err)
+	}
+	strategies := configs.ListMerge{
+		"env":            configs.ListMergeByName,
+		"extraVolumes":   configs.ListMergeAppend,
+		"containers":     configs.ListMergeByName,
+		"containers.env": configs.ListMergeAppend,
+	}
+	got := mergeValues(base, prod, nil, strategies)
+
+	var want map[string]interface{}
+	if err := yaml.Unmarshal([]byte(`
+env:
+- name: LOG_LEVEL
+  value: warn
+- name: REGION
+  value: us-east-1
+- name: SENTRY_DSN
+  value: https://sentry.example.com
+extraVolumes:
+- name: cache
+- name: certs
+args: [--serve, --prod]
+containers:
+- name: web
+  env:
+  - name: A
+    value: "1"
+  - name: B
+    value: "2"
+`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotYAML, _ := yaml.Marshal(got)
+		t.Errorf("merged values =\n%s", gotYAML)
+	}
+
+	// Without strategies a later list replaces the earlier one, as in Helm.
+	if env := mergeMaps(base, prod)["env"].([]interface{}); len(env) != 2 {
+		t.Errorf("mergeMaps env = %v, want prod's two items", env)
+	}
+	if len(base["env"].([]interface{})) != 2 {
+		t.Error("mergeValues modified its input")
+	}
+}
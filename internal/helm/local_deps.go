@@ -0,0 +1,143 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// loadLocalChart loads a chart from a directory or archive and resolves its
+// local dependencies, so a chart in a monorepo can be installed or templated
+// without first running `helm dependency build` or publishing the library
+// charts it uses.
+func loadLocalChart(path string, debug bool) (*chart.Chart, error) {
+	ch, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := parseDependencyPaths(configs.DependencyPaths)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveLocalDependencies(ch, path, overrides, map[string]bool{}, debug); err != nil {
+		return nil, fmt.Errorf("chart %s: %w", ch.Name(), err)
+	}
+	return ch, nil
+}
+
+// parseDependencyPaths parses --dependency-path NAME=PATH overrides. Paths
+// are relative to the working directory.
+func parseDependencyPaths(refs []string) (map[string]string, error) {
+	overrides := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		name, path, ok := strings.Cut(ref, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid --dependency-path %q: want NAME=PATH", ref)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --dependency-path %q: %w", ref, err)
+		}
+		overrides[name] = abs
+	}
+	return overrides, nil
+}
+
+// resolveLocalDependencies replaces the subcharts of ch that are declared
+// with a file:// repository, or overridden by name, with the charts at those
+// paths as they are on disk now. A copy already vendored under charts/ is
+// dropped in favour of the local one. file:// paths are relative to the
+// directory of the chart declaring them; for a packaged chart only overrides
+// apply. visiting guards against charts that depend on each other.
+func resolveLocalDependencies(ch *chart.Chart, chartPath string, overrides map[string]string, visiting map[string]bool, debug bool) error {
+	if ch.Metadata == nil || len(ch.Metadata.Dependencies) == 0 {
+		return nil
+	}
+	chartDir := ""
+	if info, err := os.Stat(chartPath); err == nil && info.IsDir() {
+		chartDir = chartPath
+	}
+	abs, _ := filepath.Abs(chartPath)
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	for _, dep := range ch.Metadata.Dependencies {
+		path, override := overrides[dep.Name]
+		if !override {
+			if !strings.HasPrefix(dep.Repository, "file://") || chartDir == "" {
+				continue
+			}
+			path = filepath.Join(chartDir, strings.TrimPrefix(dep.Repository, "file://"))
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if visiting[path] {
+			return fmt.Errorf("dependency %s: %s depends on itself", dep.Name, path)
+		}
+
+		sub, err := loader.Load(path)
+		if err != nil {
+			return fmt.Errorf("dependency %s: failed to load %s: %w", dep.Name, path, err)
+		}
+		if sub.Name() != dep.Name {
+			return fmt.Errorf("dependency %s: chart at %s is named %q", dep.Name, path, sub.Name())
+		}
+		// An override is how a library chart version that is not published
+		// yet gets tested, so only file:// dependencies must match.
+		if !override {
+			if err := checkDependencyVersion(dep, sub); err != nil {
+				return err
+			}
+		}
+		if err := resolveLocalDependencies(sub, path, overrides, visiting, debug); err != nil {
+			return err
+		}
+		if debug {
+			pterm.Printf("Using local dependency %s %s from %s\n", sub.Name(), sub.Metadata.Version, path)
+		}
+		replaceDependency(ch, sub)
+	}
+	return nil
+}
+
+// checkDependencyVersion reports a local chart whose version does not satisfy
+// the constraint its parent declares, as `helm dependency build` would.
+func checkDependencyVersion(dep *chart.Dependency, sub *chart.Chart) error {
+	if dep.Version == "" {
+		return nil
+	}
+	constraint, err := semver.NewConstraint(dep.Version)
+	if err != nil {
+		return fmt.Errorf("dependency %s: invalid version constraint %q: %w", dep.Name, dep.Version, err)
+	}
+	version, err := semver.NewVersion(sub.Metadata.Version)
+	if err != nil {
+		return fmt.Errorf("dependency %s: invalid chart version %q: %w", dep.Name, sub.Metadata.Version, err)
+	}
+	if !constraint.Check(version) {
+		return fmt.Errorf("dependency %s: local chart version %s does not satisfy %q", dep.Name, sub.Metadata.Version, dep.Version)
+	}
+	return nil
+}
+
+// replaceDependency adds sub to the subcharts of ch, replacing any with the
+// same name.
+func replaceDependency(ch *chart.Chart, sub *chart.Chart) {
+	kept := make([]*chart.Chart, 0, len(ch.Dependencies())+1)
+	for _, d := range ch.Dependencies() {
+		if d.Name() != sub.Name() {
+			kept = append(kept, d)
+		}
+	}
+	ch.SetDependencies(append(kept, sub)...)
+}
@@ -0,0 +1,99 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+// writeChart writes a minimal chart with the given Chart.yaml body and a
+// template that renders the version, so tests can see which copy was used.
+func writeChart(t *testing.T, dir, chartYAML string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(chartYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := "version: {{ .Chart.Version }}\n"
+	if err := os.WriteFile(filepath.Join(dir, "templates", "cm.yaml"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dependencyVersions(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	ch, err := loadLocalChart(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := map[string]string{}
+	for _, d := range ch.Dependencies() {
+		versions[d.Name()] = d.Metadata.Version
+		for _, dd := range d.Dependencies() {
+			versions[d.Name()+"/"+dd.Name()] = dd.Metadata.Version
+		}
+	}
+	return versions
+}
+
+func TestLoadLocalChart_FileDependencies(t *testing.T) {
+	prev := configs.DependencyPaths
+	t.Cleanup(func() { configs.DependencyPaths = prev })
+	configs.DependencyPaths = nil
+
+	root := t.TempDir()
+	writeChart(t, filepath.Join(root, "common-lib"), "apiVersion: v2\nname: common-lib\nversion: 1.2.0\ndependencies:\n  - name: helpers\n    version: 0.1.0\n    repository: file://../helpers\n")
+	writeChart(t, filepath.Join(root, "helpers"), "apiVersion: v2\nname: helpers\nversion: 0.1.0\n")
+	app := filepath.Join(root, "app")
+	writeChart(t, app, "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: common-lib\n    version: ^1.0.0\n    repository: file://../common-lib\n")
+	// A stale vendored copy must give way to the chart on disk.
+	writeChart(t, filepath.Join(app, "charts", "common-lib"), "apiVersion: v2\nname: common-lib\nversion: 1.0.0\n")
+
+	got := dependencyVersions(t, app)
+	if len(got) != 2 || got["common-lib"] != "1.2.0" || got["common-lib/helpers"] != "0.1.0" {
+		t.Errorf("dependencies = %v, want common-lib 1.2.0 with helpers 0.1.0", got)
+	}
+
+	// An override replaces the file:// path and skips the version check.
+	writeChart(t, filepath.Join(root, "next-lib"), "apiVersion: v2\nname: common-lib\nversion: 2.0.0-dev\n")
+	configs.DependencyPaths = []string{"common-lib=" + filepath.Join(root, "next-lib")}
+	if got := dependencyVersions(t, app); got["common-lib"] != "2.0.0-dev" {
+		t.Errorf("dependencies = %v, want common-lib 2.0.0-dev from the override", got)
+	}
+}
+
+func TestLoadLocalChart_Errors(t *testing.T) {
+	prev := configs.DependencyPaths
+	t.Cleanup(func() { configs.DependencyPaths = prev })
+
+	tests := []struct {
+		name  string
+		lib   string
+		paths []string
+		want  string
+	}{
+		{"version", "apiVersion: v2\nname: lib\nversion: 2.0.0\n", nil, `does not satisfy "^1.0.0"`},
+		{"name", "apiVersion: v2\nname: other\nversion: 1.0.0\n", nil, `is named "other"`},
+		{"cycle", "apiVersion: v2\nname: lib\nversion: 1.0.0\ndependencies:\n  - name: app\n    repository: file://../app\n", nil, "depends on itself"},
+		{"bad override", "apiVersion: v2\nname: lib\nversion: 1.0.0\n", []string{"lib"}, "want NAME=PATH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeChart(t, filepath.Join(root, "lib"), tt.lib)
+			app := filepath.Join(root, "app")
+			writeChart(t, app, "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: lib\n    version: ^1.0.0\n    repository: file://../lib\n")
+			configs.DependencyPaths = tt.paths
+
+			_, err := loadLocalChart(app, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// containerListKeys are the pod spec fields whose entries carry an image.
+var containerListKeys = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// RenderManifest renders a chart with the given values the way an install
+// of releaseName would, without touching the cluster, and returns the
+// manifest.
+func RenderManifest(releaseName, chartRef, namespace string, valuesFiles, setValues, setLiteralValues []string, debug bool) (string, error) {
+	rel, _, err := renderRelease(releaseName, chartRef, namespace, "", "", valuesFiles, setValues, setLiteralValues, debug)
+	if err != nil {
+		return "", err
+	}
+	return rel.Manifest, nil
+}
+
+// renderRelease does a client-only install dry run of chartRef and returns
+// the rendered release with the merged values it was rendered with.
+func renderRelease(releaseName, chartRef, namespace, repoURL, version string, valuesFiles, setValues, setLiteralValues []string, debug bool) (*release.Release, map[string]interface{}, error) {
+	settings := cli.New()
+	settings.SetNamespace(namespace)
+
+	client := action.NewInstall(new(action.Configuration))
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.PostRenderer = schedulingRenderer()
+	client.ReleaseName = releaseName
+	client.Namespace = namespace
+	client.Timeout = 5 * time.Minute
+
+	chartObj, err := LoadChart(chartRef, repoURL, version, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Client-only: the namespace defaults of the current context's cluster
+	// may not be the target's, so they are not applied.
+	vals, err := loadAndMergeValuesWithSets("", valuesFiles, setValues, setLiteralValues, debug)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, err := client.Run(chartObj, vals)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render %s: %w", chartRef, err)
+	}
+	return rel, vals, nil
+}
+
+// ManifestImages returns the images of every container, init container and
+// ephemeral container in manifest, sorted and without duplicates. Pod specs
+// are found wherever they are nested, so CronJobs and custom resources that
+// embed a pod template are covered too.
+func ManifestImages(manifest string) ([]string, error) {
+	seen := map[string]bool{}
+	for name, doc := range releaseutil.SplitManifests(manifest) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		collectImages(obj, seen)
+	}
+	images := make([]string, 0, len(seen))
+	for image := range seen {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// collectImages adds the container images found anywhere under v to seen.
+func collectImages(v interface{}, seen map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range containerListKeys {
+			containers, _ := v[key].([]interface{})
+			for _, c := range containers {
+				c, _ := c.(map[string]interface{})
+				if image, _ := c["image"].(string); image != "" {
+					seen[image] = true
+				}
+			}
+		}
+		for _, child := range v {
+			collectImages(child, seen)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectImages(child, seen)
+		}
+	}
+}
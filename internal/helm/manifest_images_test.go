@@ -0,0 +1,61 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestImages(t *testing.T) {
+	manifest := `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: registry.example.com/app:1.4.0
+      containers:
+        - name: app
+          image: registry.example.com/app:1.4.0
+        - name: proxy
+          image: envoyproxy/envoy:v1.31.0
+---
+# Source: app/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: cleanup
+              image: busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000
+---
+# Source: app/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  image: not-a-container
+`
+	got, err := ManifestImages(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		"envoyproxy/envoy:v1.31.0",
+		"registry.example.com/app:1.4.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ManifestImages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,407 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"sigs.k8s.io/yaml"
+)
+
+// ValuesMigrationsFile is the file at the root of a chart that holds the
+// migrations of its values.
+const ValuesMigrationsFile = "values-migrations.yaml"
+
+// ValuesMigrations is a values migrations file.
+type ValuesMigrations struct {
+	Migrations []ValuesMigration `json:"migrations"`
+}
+
+// ValuesMigration rewrites the values of a release for the chart version
+// that changed them. It applies when a release is upgraded from a version
+// below Version to Version or later.
+type ValuesMigration struct {
+	Version     string          `json:"version"`
+	Description string          `json:"description,omitempty"`
+	Rules       []MigrationRule `json:"rules"`
+
+	source string
+}
+
+// MigrationRule is one change of a migration; exactly one of Rename, Move,
+// Default and Delete is set, each to a dotted values path.
+//
+//	rename: image.name      to: repository      the key, in the same map
+//	move: ingress.host      to: ingress.hosts.default
+//	default: service.port   value: 80           keep the old default
+//	delete: legacy.enabled
+type MigrationRule struct {
+	Rename  string      `json:"rename,omitempty"`
+	Move    string      `json:"move,omitempty"`
+	Default string      `json:"default,omitempty"`
+	Delete  string      `json:"delete,omitempty"`
+	To      string      `json:"to,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+}
+
+// validate checks that r is exactly one well-formed operation.
+func (r MigrationRule) validate() error {
+	ops := 0
+	for _, path := range []string{r.Rename, r.Move, r.Default, r.Delete} {
+		if path != "" {
+			ops++
+		}
+	}
+	switch {
+	case ops != 1:
+		return errors.New("a rule must set exactly one of rename, move, default and delete")
+	case r.Rename != "" && (r.To == "" || strings.Contains(r.To, ".")):
+		return fmt.Errorf("rename %s: to must be the new key, without dots", r.Rename)
+	case r.Move != "" && r.To == "":
+		return fmt.Errorf("move %s: to is required", r.Move)
+	case r.Default != "" && r.Value == nil:
+		return fmt.Errorf("default %s: value is required", r.Default)
+	}
+	return nil
+}
+
+// ValuesMigrationPreview is what migrating the stored values of a release
+// to a new chart version changes.
+type ValuesMigrationPreview struct {
+	Release     string             `json:"release"`
+	Namespace   string             `json:"namespace"`
+	FromVersion string             `json:"from_version"`
+	ToVersion   string             `json:"to_version"`
+	Migrations  []AppliedMigration `json:"migrations"`
+	Diff        string             `json:"diff"`
+
+	// Values are the migrated values, the base of the upgrade.
+	Values map[string]interface{} `json:"-"`
+}
+
+// AppliedMigration is a migration with the changes it made to the values.
+type AppliedMigration struct {
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Source      string   `json:"source"`
+	Changes     []string `json:"changes"`
+}
+
+// PreviewValuesMigration migrates the values of the deployed revision of
+// releaseName to the version of chartRef without upgrading anything.
+func PreviewValuesMigration(releaseName, chartRef, namespace, repoURL, version string, debug, useAI bool) (*ValuesMigrationPreview, error) {
+	actionConfig, err := initActionConfig(namespace, debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to initialize helm: %w", err)
+	}
+	chrt, err := loadChart(chartRef, repoURL, version, debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+	p, err := migrateStoredValues(actionConfig, releaseName, chrt)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("release %s not found in namespace %s", releaseName, namespace)
+	}
+	return p, nil
+}
+
+// migrateStoredValues migrates the values of the deployed revision of
+// releaseName with the migrations of chrt and configs.ValuesMigrations
+// between the deployed chart version and chrt's. It returns nil when the
+// release doesn't exist.
+func migrateStoredValues(cfg *action.Configuration, releaseName string, chrt *chart.Chart) (*ValuesMigrationPreview, error) {
+	current, err := cfg.Releases.Deployed(releaseName)
+	if err != nil {
+		current, err = cfg.Releases.Last(releaseName)
+	}
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release %s: %w", releaseName, err)
+	}
+
+	return migrateForChart(current, chrt)
+}
+
+// migrateForChart migrates the values of rel with the migrations of chrt
+// and configs.ValuesMigrations.
+func migrateForChart(rel *release.Release, chrt *chart.Chart) (*ValuesMigrationPreview, error) {
+	migrations, err := loadValuesMigrations(chrt, configs.ValuesMigrations)
+	if err != nil {
+		return nil, err
+	}
+	return migrateReleaseValues(rel, chrt.Metadata.Version, migrations)
+}
+
+// migrateReleaseValues applies the migrations that lie between the chart
+// version of rel and toVersion to a copy of its values.
+func migrateReleaseValues(rel *release.Release, toVersion string, migrations []ValuesMigration) (*ValuesMigrationPreview, error) {
+	fromVersion := ""
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		fromVersion = rel.Chart.Metadata.Version
+	}
+	p := &ValuesMigrationPreview{
+		Release:     rel.Name,
+		Namespace:   rel.Namespace,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Migrations:  []AppliedMigration{},
+	}
+	pending, err := pendingMigrations(migrations, fromVersion, toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := copyValues(rel.Config)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range pending {
+		applied := AppliedMigration{Version: m.Version, Description: m.Description, Source: m.source, Changes: []string{}}
+		for _, rule := range m.Rules {
+			change, err := applyMigrationRule(vals, rule)
+			if err != nil {
+				return nil, fmt.Errorf("migration %s (%s): %w", m.Version, m.source, err)
+			}
+			if change != "" {
+				applied.Changes = append(applied.Changes, change)
+			}
+		}
+		p.Migrations = append(p.Migrations, applied)
+	}
+	p.Values = vals
+
+	registerSecretValues(rel.Config)
+	before, after := redactValuesPair(rel.Config, vals)
+	beforeYAML, err := yaml.Marshal(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the stored values: %w", err)
+	}
+	afterYAML, err := yaml.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the migrated values: %w", err)
+	}
+	p.Diff = unifiedDiff(string(beforeYAML), string(afterYAML),
+		fmt.Sprintf("values (%s)", fromVersion), fmt.Sprintf("values (%s)", toVersion))
+	return p, nil
+}
+
+// loadValuesMigrations reads the migrations of chrt from its
+// values-migrations.yaml and those of file, a migrations file of the user,
+// when it is set. The chart's come first.
+func loadValuesMigrations(chrt *chart.Chart, file string) ([]ValuesMigration, error) {
+	var migrations []ValuesMigration
+	for _, f := range chrt.Files {
+		if f.Name == ValuesMigrationsFile {
+			found, err := parseValuesMigrations(f.Data, chrt.Name()+"/"+ValuesMigrationsFile)
+			if err != nil {
+				return nil, err
+			}
+			migrations = append(migrations, found...)
+		}
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values migrations: %w", err)
+		}
+		found, err := parseValuesMigrations(data, file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, found...)
+	}
+	return migrations, nil
+}
+
+func parseValuesMigrations(data []byte, source string) ([]ValuesMigration, error) {
+	var doc ValuesMigrations
+	if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid values migrations %s: %w", source, err)
+	}
+	for i := range doc.Migrations {
+		m := &doc.Migrations[i]
+		m.source = source
+		if _, err := semver.NewVersion(m.Version); err != nil {
+			return nil, fmt.Errorf("invalid values migrations %s: version %q: %w", source, m.Version, err)
+		}
+		for _, rule := range m.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("invalid values migrations %s: migration %s: %w", source, m.Version, err)
+			}
+		}
+	}
+	return doc.Migrations, nil
+}
+
+// pendingMigrations returns the migrations an upgrade from chart version
+// from to version to crosses, from < version <= to, oldest first. Nothing
+// is pending on a downgrade or when a version isn't semver.
+func pendingMigrations(migrations []ValuesMigration, from, to string) ([]ValuesMigration, error) {
+	fromVersion, err := semver.NewVersion(from)
+	if err != nil {
+		return nil, fmt.Errorf("deployed chart version %q is not a semantic version: %w", from, err)
+	}
+	toVersion, err := semver.NewVersion(to)
+	if err != nil {
+		return nil, fmt.Errorf("chart version %q is not a semantic version: %w", to, err)
+	}
+	var pending []ValuesMigration
+	for _, m := range migrations {
+		v := semver.MustParse(m.Version)
+		if v.GreaterThan(fromVersion) && !v.GreaterThan(toVersion) {
+			pending = append(pending, m)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return semver.MustParse(pending[i].Version).LessThan(semver.MustParse(pending[j].Version))
+	})
+	return pending, nil
+}
+
+// applyMigrationRule applies rule to vals and describes what it changed, or
+// returns "" when the values have nothing the rule applies to.
+func applyMigrationRule(vals map[string]interface{}, rule MigrationRule) (string, error) {
+	switch {
+	case rule.Rename != "":
+		path := strings.Split(rule.Rename, ".")
+		target := strings.Join(append(path[:len(path)-1:len(path)-1], rule.To), ".")
+		return moveValue(vals, rule.Rename, target)
+	case rule.Move != "":
+		return moveValue(vals, rule.Move, rule.To)
+	case rule.Default != "":
+		if _, ok := valueAt(vals, rule.Default); ok {
+			return "", nil
+		}
+		if err := setValue(vals, rule.Default, rule.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s: set to the previous default %v", rule.Default, rule.Value), nil
+	default:
+		if _, ok := valueAt(vals, rule.Delete); !ok {
+			return "", nil
+		}
+		deleteValue(vals, rule.Delete)
+		return fmt.Sprintf("%s: deleted", rule.Delete), nil
+	}
+}
+
+// moveValue moves the value at from to to. A value already at to is an
+// error rather than overwritten.
+func moveValue(vals map[string]interface{}, from, to string) (string, error) {
+	v, ok := valueAt(vals, from)
+	if !ok {
+		return "", nil
+	}
+	if _, exists := valueAt(vals, to); exists {
+		return "", fmt.Errorf("can't move %s to %s: both are set", from, to)
+	}
+	deleteValue(vals, from)
+	if err := setValue(vals, to, v); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s → %s", from, to), nil
+}
+
+// valueAt returns the value at the dotted path in vals and whether it is
+// set, even to null.
+func valueAt(vals map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	m := vals
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	v, ok := m[keys[len(keys)-1]]
+	return v, ok
+}
+
+// setValue sets path in vals, creating the maps on the way.
+func setValue(vals map[string]interface{}, path string, v interface{}) error {
+	keys := strings.Split(path, ".")
+	m := vals
+	for i, key := range keys[:len(keys)-1] {
+		switch next := m[key].(type) {
+		case map[string]interface{}:
+			m = next
+		case nil:
+			created := map[string]interface{}{}
+			m[key] = created
+			m = created
+		default:
+			return fmt.Errorf("can't set %s: %s is not a map", path, strings.Join(keys[:i+1], "."))
+		}
+	}
+	m[keys[len(keys)-1]] = v
+	return nil
+}
+
+// deleteValue removes path from vals and the maps it leaves empty.
+func deleteValue(vals map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	parent, ok := vals, true
+	if len(keys) > 1 {
+		var v interface{}
+		v, ok = valueAt(vals, strings.Join(keys[:len(keys)-1], "."))
+		parent, _ = v.(map[string]interface{})
+	}
+	if !ok || parent == nil {
+		return
+	}
+	delete(parent, keys[len(keys)-1])
+	if len(parent) == 0 && len(keys) > 1 {
+		deleteValue(vals, strings.Join(keys[:len(keys)-1], "."))
+	}
+}
+
+// PrintValuesMigrationPreview prints the migrations applied to the values
+// of a release and the values diff, or p as JSON or YAML.
+func PrintValuesMigrationPreview(p *ValuesMigrationPreview, format string) error {
+	if isStructured(format) {
+		return printStructured(p, format)
+	}
+	pterm.DefaultSection.Printfln("Values migration of %s: chart %s → %s", p.Release, p.FromVersion, p.ToVersion)
+	if len(p.Migrations) == 0 {
+		pterm.Info.Println("No values migrations between these chart versions; the stored values are kept as they are.")
+		return nil
+	}
+	for _, m := range p.Migrations {
+		title := m.Version
+		if m.Description != "" {
+			title += ": " + m.Description
+		}
+		pterm.Println(pterm.Bold.Sprintf("%s (%s)", title, m.Source))
+		if len(m.Changes) == 0 {
+			pterm.Println("  nothing to change")
+		}
+		for _, change := range m.Changes {
+			pterm.Println("  " + change)
+		}
+	}
+	if p.Diff == "" {
+		pterm.Info.Println("The migrations don't change the stored values.")
+		return nil
+	}
+	printColoredDiff(p.Diff)
+	return nil
+}
@@ -0,0 +1,113 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const chartMigrations = `
+migrations:
+  - version: 3.0.0
+    description: ingress takes a map of hosts
+    rules:
+      - move: ingress.host
+        to: ingress.hosts.default
+  - version: 2.0.0
+    description: image.name is now image.repository
+    rules:
+      - rename: image.name
+        to: repository
+      - default: service.port
+        value: 80
+      - delete: legacy.enabled
+  - version: 4.0.0
+    rules:
+      - delete: image
+`
+
+func TestMigrateReleaseValues(t *testing.T) {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "web", Version: "3.1.0"},
+		Files:    []*chart.File{{Name: ValuesMigrationsFile, Data: []byte(chartMigrations)}},
+	}
+	userFile := filepath.Join(t.TempDir(), "migrations.yaml")
+	if err := os.WriteFile(userFile, []byte("migrations:\n  - version: 2.5.0\n    rules:\n      - delete: debug\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := loadValuesMigrations(chrt, userFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &release.Release{
+		Name:  "web",
+		Chart: &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.4.0"}},
+		Config: map[string]interface{}{
+			"image":   map[string]interface{}{"name": "web", "tag": "v1"},
+			"ingress": map[string]interface{}{"host": "web.example.com"},
+			"legacy":  map[string]interface{}{"enabled": true},
+			"debug":   true,
+		},
+	}
+	p, err := migrateReleaseValues(rel, chrt.Metadata.Version, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"image":   map[string]interface{}{"repository": "web", "tag": "v1"},
+		"ingress": map[string]interface{}{"hosts": map[string]interface{}{"default": "web.example.com"}},
+		"service": map[string]interface{}{"port": float64(80)},
+	}
+	if !reflect.DeepEqual(p.Values, want) {
+		t.Errorf("migrated values = %v, want %v", p.Values, want)
+	}
+	var versions []string
+	for _, m := range p.Migrations {
+		versions = append(versions, m.Version)
+	}
+	if strings.Join(versions, ",") != "2.0.0,2.5.0,3.0.0" {
+		t.Errorf("applied migrations = %v, want 2.0.0, 2.5.0 and 3.0.0 in order", versions)
+	}
+	if p.Migrations[0].Changes[0] != "image.name → image.repository" || p.Migrations[1].Source != userFile {
+		t.Errorf("migrations = %+v", p.Migrations)
+	}
+	if !strings.Contains(p.Diff, "-  name: web") || !strings.Contains(p.Diff, "+  repository: web") {
+		t.Errorf("diff =\n%s", p.Diff)
+	}
+	if _, ok := rel.Config["debug"]; !ok {
+		t.Error("migrating changed the stored values of the release")
+	}
+}
+
+func TestMigrationConflicts(t *testing.T) {
+	vals := map[string]interface{}{"image": map[string]interface{}{"name": "web", "repository": "web"}}
+	if _, err := applyMigrationRule(vals, MigrationRule{Rename: "image.name", To: "repository"}); err == nil {
+		t.Error("renaming onto a key that is set: want an error")
+	}
+	if change, err := applyMigrationRule(vals, MigrationRule{Move: "missing.key", To: "other"}); err != nil || change != "" {
+		t.Errorf("moving a value that isn't set = %q, %v, want no change", change, err)
+	}
+	if _, err := applyMigrationRule(vals, MigrationRule{Default: "image.name.port", Value: 80}); err == nil {
+		t.Error("setting a default under a string: want an error")
+	}
+}
+
+func TestParseValuesMigrationsInvalid(t *testing.T) {
+	for _, doc := range []string{
+		"migrations:\n  - version: two\n    rules: []\n",
+		"migrations:\n  - version: 2.0.0\n    rules:\n      - rename: a.b\n        to: c.d\n",
+		"migrations:\n  - version: 2.0.0\n    rules:\n      - move: a\n        delete: b\n",
+		"migrations:\n  - version: 2.0.0\n    rules:\n      - remove: a\n",
+	} {
+		if _, err := parseValuesMigrations([]byte(doc), "test"); err == nil {
+			t.Errorf("parseValuesMigrations(%q): want an error", doc)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// Metadata Helm (3.2+) puts on every resource it creates, and checks before it
+// adopts an existing resource into a release.
+const (
+	helmManagedBySelector      = "app.kubernetes.io/managed-by=Helm"
+	releaseNameAnnotation      = "meta.helm.sh/release-name"
+	releaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	releaseInstanceLabel       = "app.kubernetes.io/instance"
+)
+
+// OrphanedResource is a Kubernetes object labeled as managed by a Helm
+// release that no longer exists in release storage, typically left behind
+// by a failed uninstall.
+type OrphanedResource struct {
+	Release          string `json:"release"`
+	ReleaseNamespace string `json:"releaseNamespace"`
+	Kind             string `json:"kind"`
+	APIVersion       string `json:"apiVersion"`
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+
+	gvr schema.GroupVersionResource
+}
+
+// orphanReleaseOf returns the release obj claims to belong to. Objects owned
+// by another object (a Deployment's ReplicaSets and Pods) are skipped: they
+// go away with their owner.
+func orphanReleaseOf(obj *unstructured.Unstructured) (name, namespace string, ok bool) {
+	if len(obj.GetOwnerReferences()) > 0 {
+		return "", "", false
+	}
+	annotations := obj.GetAnnotations()
+	name = annotations[releaseNameAnnotation]
+	if name == "" {
+		name = obj.GetLabels()[releaseInstanceLabel]
+	}
+	if name == "" {
+		return "", "", false
+	}
+	namespace = annotations[releaseNamespaceAnnotation]
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+	return name, namespace, true
+}
+
+// FindOrphans scans every namespaced resource type in namespace for objects
+// managed by Helm whose release is gone. When release is set, only the
+// orphans of that release are returned.
+func FindOrphans(namespace, release string, useAI bool) ([]OrphanedResource, error) {
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Scanning namespace %s for orphaned release resources...", namespace))
+	orphans, err := findOrphans(namespace, release)
+	spinner.Stop()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, err
+	}
+	return orphans, nil
+}
+
+func findOrphans(namespace, release string) ([]OrphanedResource, error) {
+	dyn, err := dynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	dc, err := settings.RESTClientGetter().ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	lists, err := dc.ServerPreferredNamespacedResources()
+	if err != nil {
+		if len(lists) == 0 {
+			return nil, fmt.Errorf("failed to discover API resources: %w", err)
+		}
+		pterm.Warning.Printfln("Some API groups could not be discovered and were skipped: %v", err)
+	}
+
+	releases := map[string]map[string]bool{}
+	releaseExists := func(name, ns string) (bool, error) {
+		if _, ok := releases[ns]; !ok {
+			names, err := releaseNamesIn(ns)
+			if err != nil {
+				return false, err
+			}
+			releases[ns] = names
+		}
+		return releases[ns][name], nil
+	}
+
+	ctx := context.Background()
+	var orphans []OrphanedResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !hasVerbs(r.Verbs, "list", "delete", "patch") {
+				continue
+			}
+			gvr := gv.WithResource(r.Name)
+			objs, err := dyn.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: helmManagedBySelector})
+			if err != nil {
+				// Types the user may not list are simply not scanned.
+				if !apierrors.IsForbidden(err) && !apierrors.IsMethodNotSupported(err) {
+					pterm.Warning.Printfln("Skipping %s: %v", gvr.GroupResource(), err)
+				}
+				continue
+			}
+			for i := range objs.Items {
+				obj := &objs.Items[i]
+				name, ns, ok := orphanReleaseOf(obj)
+				if !ok || (release != "" && name != release) {
+					continue
+				}
+				exists, err := releaseExists(name, ns)
+				if err != nil {
+					return nil, err
+				}
+				if exists {
+					continue
+				}
+				orphans = append(orphans, OrphanedResource{
+					Release:          name,
+					ReleaseNamespace: ns,
+					Kind:             obj.GetKind(),
+					APIVersion:       obj.GetAPIVersion(),
+					Name:             obj.GetName(),
+					Namespace:        obj.GetNamespace(),
+					gvr:              gvr,
+				})
+			}
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if a.Release != b.Release {
+			return a.Release < b.Release
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return orphans, nil
+}
+
+// releaseNamesIn returns the releases of namespace in any state, so a
+// release that is merely failed or pending does not make its resources look
+// orphaned.
+func releaseNamesIn(namespace string) (map[string]bool, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		return nil, fmt.Errorf("helm init failed: %w", err)
+	}
+	client := action.NewList(cfg)
+	client.StateMask = action.ListAll
+	rels, err := client.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases in namespace %s: %w", namespace, err)
+	}
+	names := make(map[string]bool, len(rels))
+	for _, r := range rels {
+		names[r.Name] = true
+	}
+	return names, nil
+}
+
+func dynamicClient() (dynamic.Interface, error) {
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Kubernetes configuration: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return dyn, nil
+}
+
+func hasVerbs(verbs metav1.Verbs, want ...string) bool {
+	for _, w := range want {
+		if !slices.Contains(verbs, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// PrintOrphans renders orphans as a table, or as JSON or YAML when format
+// asks for it.
+func PrintOrphans(orphans []OrphanedResource, format string) error {
+	if isStructured(format) {
+		if orphans == nil {
+			orphans = []OrphanedResource{}
+		}
+		return printStructured(orphans, format)
+	}
+
+	if len(orphans) == 0 {
+		pterm.Success.Println("No orphaned release resources found.")
+		return nil
+	}
+	data := pterm.TableData{{"RELEASE", "KIND", "NAME", "API VERSION"}}
+	for _, o := range orphans {
+		data = append(data, []string{o.Release, o.Kind, o.Name, o.APIVersion})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// DeleteOrphans deletes orphans with background propagation. Every orphan is
+// attempted; the failures are returned together.
+func DeleteOrphans(orphans []OrphanedResource, useAI bool) error {
+	dyn, err := dynamicClient()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	policy := metav1.DeletePropagationBackground
+	var errs []error
+	for _, o := range orphans {
+		err := dyn.Resource(o.gvr).Namespace(o.Namespace).Delete(context.Background(), o.Name, metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete %s/%s: %w", o.Kind, o.Name, err))
+			continue
+		}
+		pterm.Info.Printfln("Deleted %s/%s", o.Kind, o.Name)
+	}
+	if err := errors.Join(errs...); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	return nil
+}
+
+// AdoptOrphans rewrites the ownership metadata of orphans to name release,
+// so the next install or upgrade of that release takes them over instead of
+// failing on "resource already exists".
+func AdoptOrphans(orphans []OrphanedResource, release string, useAI bool) error {
+	dyn, err := dynamicClient()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	var errs []error
+	for _, o := range orphans {
+		patch, err := adoptionPatch(release, o.Namespace)
+		if err != nil {
+			return err
+		}
+		_, err = dyn.Resource(o.gvr).Namespace(o.Namespace).Patch(context.Background(), o.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to adopt %s/%s: %w", o.Kind, o.Name, err))
+			continue
+		}
+		pterm.Info.Printfln("Adopted %s/%s into release %s", o.Kind, o.Name, release)
+	}
+	if err := errors.Join(errs...); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	return nil
+}
+
+// adoptionPatch is the merge patch that hands a resource to release, which
+// lives in namespace.
+func adoptionPatch(release, namespace string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				"app.kubernetes.io/managed-by": "Helm",
+			},
+			"annotations": map[string]string{
+				releaseNameAnnotation:      release,
+				releaseNamespaceAnnotation: namespace,
+			},
+		},
+	})
+}
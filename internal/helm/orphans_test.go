@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"encoding/json"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOrphanReleaseOf(t *testing.T) {
+	obj := func(labels, annotations map[string]string, owned bool) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetNamespace("apps")
+		u.SetLabels(labels)
+		u.SetAnnotations(annotations)
+		if owned {
+			u.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Deployment", Name: "web"}})
+		}
+		return u
+	}
+
+	tests := []struct {
+		name      string
+		obj       *unstructured.Unstructured
+		release   string
+		namespace string
+		ok        bool
+	}{
+		{"annotated", obj(nil, map[string]string{releaseNameAnnotation: "web", releaseNamespaceAnnotation: "prod"}, false), "web", "prod", true},
+		{"instance label fallback", obj(map[string]string{releaseInstanceLabel: "web"}, nil, false), "web", "apps", true},
+		{"owned object", obj(nil, map[string]string{releaseNameAnnotation: "web"}, true), "", "", false},
+		{"no release metadata", obj(map[string]string{"app": "web"}, nil, false), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, namespace, ok := orphanReleaseOf(tt.obj)
+			if release != tt.release || namespace != tt.namespace || ok != tt.ok {
+				t.Errorf("orphanReleaseOf = (%q, %q, %v), want (%q, %q, %v)", release, namespace, ok, tt.release, tt.namespace, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAdoptionPatch(t *testing.T) {
+	data, err := adoptionPatch("web", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patch struct {
+		Metadata struct {
+			Labels      map[string]string
+			Annotations map[string]string
+		}
+	}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		t.Fatal(err)
+	}
+	if patch.Metadata.Labels["app.kubernetes.io/managed-by"] != "Helm" ||
+		patch.Metadata.Annotations[releaseNameAnnotation] != "web" ||
+		patch.Metadata.Annotations[releaseNamespaceAnnotation] != "prod" {
+		t.Errorf("adoptionPatch = %s", data)
+	}
+}
+
+func TestHasVerbs(t *testing.T) {
+	verbs := metav1.Verbs{"get", "list", "delete", "patch"}
+	if !hasVerbs(verbs, "list", "delete") {
+		t.Error("expected list and delete to be supported")
+	}
+	if hasVerbs(verbs, "list", "create") {
+		t.Error("create is not in the verb list")
+	}
+}
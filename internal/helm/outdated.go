@@ -0,0 +1,268 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// ChartUpdate compares the chart of a deployed release with the newest
+// version of that chart found in the configured repositories.
+type ChartUpdate struct {
+	Release      string `json:"release"`
+	Namespace    string `json:"namespace"`
+	Chart        string `json:"chart"`
+	Installed    string `json:"installed"`
+	Latest       string `json:"latest,omitempty"`
+	Source       string `json:"source,omitempty"`
+	ChangelogURL string `json:"changelogUrl,omitempty"`
+	Outdated     bool   `json:"outdated"`
+}
+
+// chartSource is a place to look up the versions of a chart: a repository
+// from repositories.yaml, or an OCI registry path holding charts.
+type chartSource struct {
+	name  string
+	index *repo.IndexFile // nil for an OCI source
+	oci   string          // oci://host/path, charts are oci://host/path/<chart>
+}
+
+// FindOutdated compares every deployed release in namespace (all namespaces
+// if empty) with the newest version of its chart in the Helm repositories of
+// repositories.yaml, as last fetched by 'selm repo update', and in the OCI
+// registries ociRepos. Pre-release versions are only considered when
+// prerelease is set, or when the installed version is itself a pre-release.
+func FindOutdated(namespace string, ociRepos []string, prerelease, useAI bool) ([]ChartUpdate, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("helm init failed: %w", err)
+	}
+	client := action.NewList(cfg)
+	client.AllNamespaces = namespace == ""
+	client.StateMask = action.ListDeployed | action.ListFailed
+	releases, err := client.Run()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("release listing failed: %w", err)
+	}
+
+	sources, err := chartSources(ociRepos)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no chart repositories configured: add one with 'smurf selm repo add' or pass --oci")
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Checking %d release(s) for chart updates...", len(releases)))
+	var updates []ChartUpdate
+	for _, r := range releases {
+		if r.Chart == nil || r.Chart.Metadata == nil {
+			continue
+		}
+		updates = append(updates, checkChartUpdate(r.Name, r.Namespace, r.Chart.Metadata, sources, prerelease))
+	}
+	spinner.Stop()
+
+	sort.Slice(updates, func(i, j int) bool {
+		if updates[i].Namespace != updates[j].Namespace {
+			return updates[i].Namespace < updates[j].Namespace
+		}
+		return updates[i].Release < updates[j].Release
+	})
+	return updates, nil
+}
+
+// chartSources loads the cached index of every repository in
+// repositories.yaml and appends the OCI registries. A repository whose index
+// was never fetched is skipped with a warning.
+func chartSources(ociRepos []string) ([]chartSource, error) {
+	var sources []chartSource
+
+	repoFile, err := repo.LoadFile(settings.RepositoryConfig)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load repository config: %w", err)
+	}
+	if repoFile != nil {
+		for _, entry := range repoFile.Repositories {
+			path := filepath.Join(settings.RepositoryCache, helmpath.CacheIndexFile(entry.Name))
+			index, err := repo.LoadIndexFile(path)
+			if err != nil {
+				pterm.Warning.Printfln("Skipping repository %s: no usable index (run 'smurf selm repo update'): %v", entry.Name, err)
+				continue
+			}
+			sources = append(sources, chartSource{name: entry.Name, index: index})
+		}
+	}
+
+	for _, ref := range ociRepos {
+		if !strings.HasPrefix(ref, "oci://") {
+			return nil, fmt.Errorf("invalid OCI repository %q: must start with oci://", ref)
+		}
+		ref = strings.TrimSuffix(ref, "/")
+		sources = append(sources, chartSource{name: ref, oci: ref})
+	}
+	return sources, nil
+}
+
+// checkChartUpdate looks meta's chart up in every source and keeps the newest
+// version found. Repository charts whose home page differs from the
+// installed chart's are a different chart of the same name and are ignored.
+func checkChartUpdate(release, namespace string, meta *chart.Metadata, sources []chartSource, prerelease bool) ChartUpdate {
+	update := ChartUpdate{
+		Release:   release,
+		Namespace: namespace,
+		Chart:     meta.Name,
+		Installed: meta.Version,
+	}
+	if installed, err := semver.NewVersion(meta.Version); err == nil && installed.Prerelease() != "" {
+		prerelease = true
+	}
+
+	var latest *semver.Version
+	var latestMeta *chart.Metadata
+	for _, src := range sources {
+		var versions []string
+		metaOf := map[string]*chart.Metadata{}
+		if src.index != nil {
+			for _, cv := range src.index.Entries[meta.Name] {
+				if cv.Metadata == nil || (meta.Home != "" && cv.Home != "" && cv.Home != meta.Home) {
+					continue
+				}
+				versions = append(versions, cv.Version)
+				metaOf[cv.Version] = cv.Metadata
+			}
+		} else {
+			tags, err := ociChartTags(src.oci + "/" + meta.Name)
+			if err != nil {
+				pterm.Debug.Printfln("No chart %s in %s: %v", meta.Name, src.oci, err)
+				continue
+			}
+			versions = tags
+		}
+
+		v, raw := newestVersion(versions, prerelease)
+		if v == nil || (latest != nil && !v.GreaterThan(latest)) {
+			continue
+		}
+		latest, latestMeta = v, metaOf[raw]
+		update.Latest, update.Source = raw, src.name
+	}
+
+	if latest == nil {
+		return update
+	}
+	if installed, err := semver.NewVersion(meta.Version); err == nil {
+		update.Outdated = latest.GreaterThan(installed)
+	}
+	if latestMeta == nil {
+		latestMeta = meta
+	}
+	update.ChangelogURL = changelogURL(latestMeta)
+	return update
+}
+
+// ociChartTags returns the versions pushed for the chart at ref, an
+// oci://host/path/<chart> reference.
+func ociChartTags(ref string) ([]string, error) {
+	client, err := newRegistryClient(settings.Debug)
+	if err != nil {
+		return nil, err
+	}
+	return client.Tags(strings.TrimPrefix(ref, "oci://"))
+}
+
+// newestVersion returns the highest semantic version in versions, and its
+// spelling there. Versions that do not parse are ignored, as are
+// pre-releases unless prerelease is set.
+func newestVersion(versions []string, prerelease bool) (*semver.Version, string) {
+	var newest *semver.Version
+	var raw string
+	for _, s := range versions {
+		v, err := semver.NewVersion(s)
+		if err != nil || (!prerelease && v.Prerelease() != "") {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest, raw = v, s
+		}
+	}
+	return newest, raw
+}
+
+// changelogURL points at the release notes of a chart: the releases page of
+// its GitHub source, or its home page.
+func changelogURL(meta *chart.Metadata) string {
+	for _, src := range meta.Sources {
+		src = strings.TrimSuffix(strings.TrimSuffix(src, "/"), ".git")
+		rest, ok := strings.CutPrefix(src, "https://github.com/")
+		if !ok {
+			continue
+		}
+		// Keep only owner/repo of deeper links such as .../tree/main/charts/x.
+		if parts := strings.SplitN(rest, "/", 3); len(parts) >= 2 {
+			return "https://github.com/" + parts[0] + "/" + parts[1] + "/releases"
+		}
+	}
+	if meta.Home != "" {
+		return meta.Home
+	}
+	if len(meta.Sources) > 0 {
+		return meta.Sources[0]
+	}
+	return ""
+}
+
+// PrintOutdated renders the upgrade candidates in updates as a table, or all
+// of updates as JSON or YAML when format asks for it. With all set, the table also lists
+// releases that are up to date or whose chart was not found.
+func PrintOutdated(updates []ChartUpdate, format string, all bool) error {
+	if isStructured(format) {
+		if updates == nil {
+			updates = []ChartUpdate{}
+		}
+		return printStructured(updates, format)
+	}
+
+	data := pterm.TableData{{"RELEASE", "NAMESPACE", "CHART", "INSTALLED", "LATEST", "SOURCE", "CHANGELOG"}}
+	outdated := 0
+	for _, u := range updates {
+		if u.Outdated {
+			outdated++
+		} else if !all {
+			continue
+		}
+		latest := u.Latest
+		switch {
+		case latest == "":
+			latest = pterm.Gray("not found")
+		case u.Outdated:
+			latest = pterm.Yellow(latest)
+		}
+		data = append(data, []string{u.Release, u.Namespace, u.Chart, u.Installed, latest, u.Source, u.ChangelogURL})
+	}
+
+	if len(data) > 1 {
+		if err := pterm.DefaultTable.WithHasHeader().WithBoxed().WithData(data).Render(); err != nil {
+			return err
+		}
+	}
+	if outdated == 0 {
+		pterm.Success.Printfln("No chart updates found for %d release(s).", len(updates))
+	} else {
+		pterm.Info.Printfln("%d of %d release(s) have a newer chart version available.", outdated, len(updates))
+	}
+	return nil
+}
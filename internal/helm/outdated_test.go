@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestNewestVersion(t *testing.T) {
+	versions := []string{"1.2.0", "v1.10.0", "1.9.3", "2.0.0-rc.1", "not-a-version"}
+
+	if _, raw := newestVersion(versions, false); raw != "v1.10.0" {
+		t.Errorf("newest stable = %q, want v1.10.0", raw)
+	}
+	if _, raw := newestVersion(versions, true); raw != "2.0.0-rc.1" {
+		t.Errorf("newest with pre-releases = %q, want 2.0.0-rc.1", raw)
+	}
+	if v, raw := newestVersion(nil, false); v != nil || raw != "" {
+		t.Errorf("newest of nothing = %v %q, want nil", v, raw)
+	}
+}
+
+func TestChangelogURL(t *testing.T) {
+	tests := []struct {
+		meta chart.Metadata
+		want string
+	}{
+		{chart.Metadata{Sources: []string{"https://github.com/org/charts/tree/main/charts/web"}, Home: "https://web.example.com"}, "https://github.com/org/charts/releases"},
+		{chart.Metadata{Sources: []string{"https://github.com/org/web.git"}}, "https://github.com/org/web/releases"},
+		{chart.Metadata{Sources: []string{"https://gitlab.com/org/web"}, Home: "https://web.example.com"}, "https://web.example.com"},
+		{chart.Metadata{Sources: []string{"https://gitlab.com/org/web"}}, "https://gitlab.com/org/web"},
+		{chart.Metadata{}, ""},
+	}
+	for _, tt := range tests {
+		if got := changelogURL(&tt.meta); got != tt.want {
+			t.Errorf("changelogURL(%v) = %q, want %q", tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestCheckChartUpdate(t *testing.T) {
+	entry := func(version, home string) *repo.ChartVersion {
+		return &repo.ChartVersion{Metadata: &chart.Metadata{Name: "web", Version: version, Home: home}}
+	}
+	sources := []chartSource{
+		{name: "stable", index: &repo.IndexFile{Entries: map[string]repo.ChartVersions{
+			"web": {entry("1.4.0", "https://web.example.com"), entry("1.3.0", "https://web.example.com"), entry("2.0.0-beta.1", "https://web.example.com")},
+		}}},
+		// A different chart that happens to share the name.
+		{name: "other", index: &repo.IndexFile{Entries: map[string]repo.ChartVersions{
+			"web": {entry("9.0.0", "https://other.example.com")},
+		}}},
+	}
+	installed := &chart.Metadata{Name: "web", Version: "1.3.0", Home: "https://web.example.com"}
+
+	got := checkChartUpdate("web", "apps", installed, sources, false)
+	if !got.Outdated || got.Latest != "1.4.0" || got.Source != "stable" {
+		t.Errorf("update = %+v, want 1.4.0 from stable", got)
+	}
+	if got.ChangelogURL != "https://web.example.com" {
+		t.Errorf("changelog = %q, want home page", got.ChangelogURL)
+	}
+
+	current := &chart.Metadata{Name: "web", Version: "1.4.0", Home: "https://web.example.com"}
+	if got := checkChartUpdate("web", "apps", current, sources, false); got.Outdated || got.Latest != "1.4.0" {
+		t.Errorf("up to date release = %+v, want not outdated", got)
+	}
+
+	missing := &chart.Metadata{Name: "api", Version: "0.1.0"}
+	if got := checkChartUpdate("api", "apps", missing, sources, false); got.Outdated || got.Latest != "" {
+		t.Errorf("unknown chart = %+v, want no latest version", got)
+	}
+}
@@ -0,0 +1,55 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The values of selm's --output flag.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// OutputFormats lists the values --output accepts.
+var OutputFormats = []string{OutputTable, OutputJSON, OutputYAML}
+
+// isStructured reports whether format asks for a machine-readable document
+// rather than the table.
+func isStructured(format string) bool {
+	return format == OutputJSON || format == OutputYAML
+}
+
+// printStructured prints v alone as the JSON or YAML document format asks
+// for.
+func printStructured(v interface{}, format string) error {
+	if format == OutputYAML {
+		return printYAML(v)
+	}
+	return printJSON(v)
+}
+
+// printJSON marshals v as indented JSON and prints it alone, so it doubles
+// as the machine-readable output helper for list, status, and history.
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json marshal error: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printYAML prints v alone as YAML, mirroring printJSON. It marshals through
+// JSON so the keys are the json tags of v, the same as in printJSON.
+func printYAML(v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("yaml marshal error: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
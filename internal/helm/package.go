@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// PackageOptions are the settings of a chart package.
+type PackageOptions struct {
+	Version          string // overrides the chart's version
+	AppVersion       string // overrides the chart's appVersion
+	Destination      string // directory of the archive
+	DependencyUpdate bool   // update charts/ from Chart.yaml first
+	Sign             bool   // write a provenance file signed with Key
+	Key              string // name of the signing key in Keyring
+	Keyring          string
+	PassphraseFile   string // passphrase of the key; "-" reads stdin
+}
+
+// Package archives the chart in chartDir as NAME-VERSION.tgz in
+// opts.Destination, like `helm package`, and returns the archive's path.
+// With opts.Sign the archive gets a NAME-VERSION.tgz.prov provenance file
+// that `--verify` checks.
+func Package(chartDir string, opts PackageOptions, debug, useAI bool) (string, error) {
+	if opts.Sign && opts.Key == "" {
+		return "", fmt.Errorf("--sign needs --key, the name of the signing key in the keyring")
+	}
+	if opts.Destination == "" {
+		opts.Destination = "."
+	}
+	if err := os.MkdirAll(opts.Destination, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	if opts.DependencyUpdate {
+		if err := updateDependencies(chartDir, opts.Keyring, debug); err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return "", fmt.Errorf("failed to update the dependencies of %s: %w", chartDir, err)
+		}
+	}
+
+	client := action.NewPackage()
+	client.Version = opts.Version
+	client.AppVersion = opts.AppVersion
+	client.Destination = opts.Destination
+	client.Sign = opts.Sign
+	client.Key = opts.Key
+	client.Keyring = opts.Keyring
+	client.PassphraseFile = opts.PassphraseFile
+
+	path, err := client.Run(chartDir, nil)
+	if err != nil {
+		pterm.Error.Printfln("Failed to package %s: %v", chartDir, err)
+		ai.AIExplainError(useAI, err.Error())
+		return "", fmt.Errorf("failed to package %s: %w", chartDir, err)
+	}
+	pterm.Success.Printfln("Packaged %s", path)
+	if opts.Sign {
+		pterm.Success.Printfln("Signed %s.prov with %q", filepath.Base(path), opts.Key)
+	}
+	return path, nil
+}
+
+// updateDependencies downloads the dependencies of Chart.yaml into the
+// charts/ directory of chartDir, like `helm dependency update`.
+func updateDependencies(chartDir, keyring string, debug bool) error {
+	client, err := newRegistryClient(debug)
+	if err != nil {
+		return err
+	}
+	out := io.Discard
+	if debug {
+		out = os.Stderr
+	}
+	manager := &downloader.Manager{
+		Out:              out,
+		ChartPath:        chartDir,
+		Keyring:          keyring,
+		Getters:          getter.All(settings),
+		Debug:            debug,
+		RegistryClient:   client,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	return manager.Update()
+}
@@ -0,0 +1,47 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func TestPackage(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "web")
+	if err := os.MkdirAll(filepath.Join(chartDir, "templates"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	chartYAML := "apiVersion: v2\nname: web\nversion: 0.1.0\nappVersion: \"1.0\"\n"
+	if err := os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "dist")
+
+	path, err := Package(chartDir, PackageOptions{Version: "1.4.0", AppVersion: "abc123", Destination: dest}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dest, "web-1.4.0.tgz"); path != want {
+		t.Errorf("Package() = %s, want %s", path, want)
+	}
+	ch, err := loader.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Metadata.Version != "1.4.0" || ch.Metadata.AppVersion != "abc123" {
+		t.Errorf("packaged chart version %s, appVersion %s", ch.Metadata.Version, ch.Metadata.AppVersion)
+	}
+
+	if _, err := Package(chartDir, PackageOptions{Version: "not-semver", Destination: dest}, false, false); err == nil {
+		t.Error("Package accepted a version that is not semver")
+	}
+	if _, err := Package(chartDir, PackageOptions{Sign: true, Destination: dest}, false, false); err == nil || !strings.Contains(err.Error(), "--key") {
+		t.Errorf("Package(--sign without --key): err = %v", err)
+	}
+	if _, err := Push(path, "ghcr.io/acme/charts", PushOptions{}, false, false); err == nil || !strings.Contains(err.Error(), "oci://") {
+		t.Errorf("Push to a remote without oci://: err = %v", err)
+	}
+}
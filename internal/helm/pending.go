@@ -0,0 +1,174 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// Ways RecoverPendingRelease cleans up a pending release.
+const (
+	RecoveredUninstall = "uninstalled"
+	RecoveredRollback  = "rolled back"
+)
+
+// RecoverPendingRelease cleans up a release left pending by an install,
+// upgrade or rollback that was interrupted, which Helm otherwise refuses to
+// touch with "another operation is in progress". A pending install is
+// uninstalled, removing its release record and what it had created; a
+// pending upgrade or rollback is rolled back to the revision deployed before
+// it. It returns what was done, or "" when the release is not pending or
+// not installed.
+func RecoverPendingRelease(releaseName, namespace string, debug bool) (string, error) {
+	cfg, err := initActionConfig(namespace, debug)
+	if err != nil {
+		return "", err
+	}
+	history, err := cfg.Releases.History(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get the history of %s: %w", releaseName, err)
+	}
+
+	switch pendingRecovery(history) {
+	case RecoveredUninstall:
+		pterm.Warning.Printfln("Release %s/%s is pending install; uninstalling it...", namespace, releaseName)
+		err := HelmUninstall(UninstallOptions{
+			ReleaseName: releaseName,
+			Namespace:   namespace,
+			Timeout:     configs.Timeouts.HelmWaitTimeout(),
+		}, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to uninstall pending release %s: %w", releaseName, err)
+		}
+		return RecoveredUninstall, nil
+	case RecoveredRollback:
+		revision, err := previousRevision(history)
+		if err != nil {
+			return "", fmt.Errorf("release %s is pending, but %w", releaseName, err)
+		}
+		pterm.Warning.Printfln("Release %s/%s has a pending operation; rolling back to revision %d...", namespace, releaseName, revision)
+		opts := RollbackOptions{
+			Namespace: namespace,
+			Debug:     debug,
+			Timeout:   configs.Timeouts.HelmWait,
+			Wait:      true,
+		}
+		if err := HelmRollback(releaseName, revision, opts, 10, false); err != nil {
+			return "", fmt.Errorf("failed to roll back pending release %s: %w", releaseName, err)
+		}
+		return RecoveredRollback, nil
+	}
+	return "", nil
+}
+
+// pendingRecovery returns how to clean up the release of history: uninstall
+// it when its only revisions never finished installing, roll it back when
+// its latest revision is a pending upgrade or rollback, or "" when it is not
+// pending.
+func pendingRecovery(history []*release.Release) string {
+	var latest *release.Release
+	for _, r := range history {
+		if r.Info != nil && (latest == nil || r.Version > latest.Version) {
+			latest = r
+		}
+	}
+	if latest == nil || !latest.Info.Status.IsPending() {
+		return ""
+	}
+	for _, r := range history {
+		if r.Info != nil && (r.Info.Status == release.StatusDeployed || r.Info.Status == release.StatusSuperseded) {
+			return RecoveredRollback
+		}
+	}
+	return RecoveredUninstall
+}
+
+// DeployedRevision returns the latest revision of releaseName, or 0 when it
+// is not installed, to be handed to RevertRelease after a failed deploy.
+func DeployedRevision(releaseName, namespace string, debug bool) (int, error) {
+	cfg, err := initActionConfig(namespace, debug)
+	if err != nil {
+		return 0, err
+	}
+	history, err := cfg.Releases.History(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get the history of %s: %w", releaseName, err)
+	}
+	latest := 0
+	for _, r := range history {
+		latest = max(latest, r.Version)
+	}
+	return latest, nil
+}
+
+// RevertRelease puts releaseName back at revision, the one DeployedRevision
+// returned before a deploy that failed: the release is rolled back to it
+// when the deploy added revisions, or uninstalled when it was not installed
+// before (revision 0). Like RecoverPendingRelease, it returns what was done,
+// or "" when the deploy left the release as it was.
+func RevertRelease(releaseName, namespace string, revision int, debug bool) (string, error) {
+	cfg, err := initActionConfig(namespace, debug)
+	if err != nil {
+		return "", err
+	}
+	history, err := cfg.Releases.History(releaseName)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", fmt.Errorf("failed to get the history of %s: %w", releaseName, err)
+	}
+
+	switch revertAction(history, revision) {
+	case RecoveredUninstall:
+		pterm.Warning.Printfln("Uninstalling %s/%s, which the failed deploy installed...", namespace, releaseName)
+		err := HelmUninstall(UninstallOptions{
+			ReleaseName: releaseName,
+			Namespace:   namespace,
+			Timeout:     configs.Timeouts.HelmWaitTimeout(),
+		}, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to uninstall %s: %w", releaseName, err)
+		}
+		return RecoveredUninstall, nil
+	case RecoveredRollback:
+		pterm.Warning.Printfln("Rolling %s/%s back to revision %d...", namespace, releaseName, revision)
+		opts := RollbackOptions{
+			Namespace: namespace,
+			Debug:     debug,
+			Timeout:   configs.Timeouts.HelmWait,
+			Wait:      true,
+		}
+		if err := HelmRollback(releaseName, revision, opts, 10, false); err != nil {
+			return "", fmt.Errorf("failed to roll back %s to revision %d: %w", releaseName, revision, err)
+		}
+		return RecoveredRollback, nil
+	}
+	return "", nil
+}
+
+// revertAction returns how to put the release of history back at revision:
+// uninstall it when it didn't exist before, roll it back when it has newer
+// revisions, or "" when there is nothing to undo.
+func revertAction(history []*release.Release, revision int) string {
+	latest := 0
+	for _, r := range history {
+		latest = max(latest, r.Version)
+	}
+	switch {
+	case latest == 0:
+		return ""
+	case revision == 0:
+		return RecoveredUninstall
+	case latest > revision:
+		return RecoveredRollback
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package helm
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestPendingRecovery(t *testing.T) {
+	rev := func(version int, status release.Status) *release.Release {
+		return &release.Release{Version: version, Info: &release.Info{Status: status}}
+	}
+	tests := []struct {
+		name    string
+		history []*release.Release
+		want    string
+	}{
+		{"deployed", []*release.Release{rev(1, release.StatusSuperseded), rev(2, release.StatusDeployed)}, ""},
+		{"failed", []*release.Release{rev(1, release.StatusDeployed), rev(2, release.StatusFailed)}, ""},
+		{"pending install", []*release.Release{rev(1, release.StatusPendingInstall)}, RecoveredUninstall},
+		{"pending upgrade", []*release.Release{rev(2, release.StatusPendingUpgrade), rev(1, release.StatusDeployed)}, RecoveredRollback},
+		{"pending rollback", []*release.Release{rev(1, release.StatusSuperseded), rev(2, release.StatusFailed), rev(3, release.StatusPendingRollback)}, RecoveredRollback},
+		{"pending upgrade of a failed install", []*release.Release{rev(1, release.StatusFailed), rev(2, release.StatusPendingUpgrade)}, RecoveredUninstall},
+		{"no history", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pendingRecovery(tt.history); got != tt.want {
+				t.Errorf("pendingRecovery = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevertAction(t *testing.T) {
+	rev := func(version int, status release.Status) *release.Release {
+		return &release.Release{Version: version, Info: &release.Info{Status: status}}
+	}
+	tests := []struct {
+		name     string
+		history  []*release.Release
+		revision int
+		want     string
+	}{
+		{"failed upgrade", []*release.Release{rev(1, release.StatusDeployed), rev(2, release.StatusFailed)}, 1, RecoveredRollback},
+		{"atomic upgrade rolled back", []*release.Release{rev(1, release.StatusSuperseded), rev(2, release.StatusFailed), rev(3, release.StatusDeployed)}, 1, RecoveredRollback},
+		{"failed install", []*release.Release{rev(1, release.StatusFailed)}, 0, RecoveredUninstall},
+		{"untouched", []*release.Release{rev(1, release.StatusSuperseded), rev(2, release.StatusDeployed)}, 2, ""},
+		{"install never started", nil, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := revertAction(tt.history, tt.revision); got != tt.want {
+				t.Errorf("revertAction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
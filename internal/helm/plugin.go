@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"os"
 	"os/exec"
 	"strings"
 
@@ -42,3 +43,11 @@ func HelmPluginInstall(plugins string) error {
 	}
 	return nil
 }
+
+// UsePluginPaths makes Helm look plugins up in, and install them to, the
+// directories of paths, as HELM_PLUGINS does for the helm CLI.
+func UsePluginPaths(paths []string) {
+	dirs := strings.Join(paths, string(os.PathListSeparator))
+	_ = os.Setenv("HELM_PLUGINS", dirs)
+	settings.PluginsDirectory = dirs
+}
@@ -1,128 +1,290 @@
 package helm
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/lint/support"
+	"helm.sh/helm/v3/pkg/releaseutil"
 )
 
-// HelmProvision orchestrates a "provisioning" workflow that checks whether a specified Helm release
-// already exists in the cluster and chooses either to install or upgrade. In parallel, it also runs
-// linting and template rendering for the chart. If any step fails, a consolidated error is returned.
-// Otherwise, a success message is printed.
-func HelmProvision(releaseName, chartPath, namespace string, useAI bool) error {
-	actionConfig := new(action.Configuration)
-	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
-		pterm.Error.Printfln("Failed to initialize Helm action configuration: %v \n", err)
-		return err
-	}
+// Stages of HelmProvision, in the order they run.
+const (
+	StageLint     = "lint"
+	StageTemplate = "template"
+	StageInstall  = "install"
+	StageUpgrade  = "upgrade"
+)
+
+// ProvisionOptions configures HelmProvision.
+type ProvisionOptions struct {
+	ReleaseName string
+	ChartPath   string
+	Namespace   string
+	// ParallelValidate runs the lint and template stages concurrently.
+	ParallelValidate bool
+}
+
+// StageResult is the outcome of one stage of HelmProvision.
+type StageResult struct {
+	Stage    string
+	Duration time.Duration
+	Skipped  bool
+	Err      error
+	// Notes are what the stage found that did not fail it, e.g. lint
+	// warnings or the number of rendered resources.
+	Notes []string
+}
 
-	// Check if release exists
-	exists, err := checkReleaseExists(actionConfig, releaseName)
+// HelmProvision installs or upgrades a release in stages: the chart is
+// linted, then rendered, and only a chart that passes both is installed, or
+// upgraded when the release exists. A failed stage skips the ones after it.
+// With ParallelValidate, lint and template run at the same time, each with
+// Helm settings of its own, print nothing until both are done, and the
+// first failure cancels the other. It returns the result of every stage and the first error.
+func HelmProvision(opts ProvisionOptions, useAI bool) ([]StageResult, error) {
+	settings := cli.New()
+	settings.SetNamespace(opts.Namespace)
+	located, err := locateChart(opts.ChartPath, action.ChartPathOptions{}, settings, false)
 	if err != nil {
-		return err
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to locate chart %s: %w", opts.ChartPath, err)
 	}
 
-	// Run lint and template in parallel
-	var wg sync.WaitGroup
-	var lintErr, templateErr error
+	var results []StageResult
+	if opts.ParallelValidate {
+		pterm.Info.Printfln("Validating chart %s (lint and template in parallel)...", opts.ChartPath)
+		results = validateParallel(located, opts)
+	} else {
+		lint := lintStage(located)
+		printStageResult(lint)
+		tmpl := StageResult{Stage: StageTemplate, Skipped: true}
+		if lint.Err == nil {
+			tmpl = templateStage(context.Background(), located, opts)
+		}
+		printStageResult(tmpl)
+		results = []StageResult{lint, tmpl}
+	}
+	if failed := firstFailure(results); failed != nil {
+		results = append(results, StageResult{Stage: StageInstall, Skipped: true})
+		printStageSummary(results)
+		ai.AIExplainError(useAI, failed.Err.Error())
+		return results, fmt.Errorf("%s stage failed: %w", failed.Stage, failed.Err)
+	}
+
+	r := deployStage(opts, useAI)
+	results = append(results, r)
+	printStageSummary(results)
+	if r.Err != nil {
+		return results, fmt.Errorf("%s stage failed: %w", r.Stage, r.Err)
+	}
+	pterm.Success.Printfln("Provisioning completed successfully for %s in namespace %s", opts.ReleaseName, opts.Namespace)
+	return results, nil
+}
+
+// validateParallel runs the lint and template stages concurrently and
+// prints their results in stage order once both are done, so their output
+// never interleaves.
+func validateParallel(chartPath string, opts ProvisionOptions) []StageResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	results := make([]StageResult, 2)
+	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		lintErr = HelmLint(chartPath, nil, useAI)
+		results[0] = lintStage(chartPath)
+		if results[0].Err != nil {
+			cancel()
+		}
 	}()
 	go func() {
 		defer wg.Done()
-		templateErr = HelmTemplate(releaseName, chartPath, namespace, "", []string{}, useAI)
+		results[1] = templateStage(ctx, chartPath, opts)
+		if results[1].Err != nil {
+			cancel()
+		}
 	}()
+	wg.Wait()
 
-	// Perform install/upgrade after lint/template
-	var operationErr error
-	if exists {
-		pterm.Info.Printfln("Release %s exists, performing upgrade...", releaseName)
-
-		// First do a dry-run
-		dryRunErr := HelmUpgrade(
-			releaseName,
-			chartPath,
-			namespace,
-			nil,           // setValues
-			nil,           // valuesFiles
-			nil,           // setLiteral
-			false,         // createNamespace
-			true,          // atomic
-			5*time.Minute, // timeout
-			true,          // dry-run
-			"",            // repoURL
-			"",            // version
-			true,
-			5,
-			useAI,
-			false,
-		)
+	for _, r := range results {
+		printStageResult(r)
+	}
+	return results
+}
 
-		if dryRunErr != nil {
-			return fmt.Errorf("upgrade dry-run failed: %v", dryRunErr)
+// lintStage lints the chart at chartPath. Lint errors fail the stage;
+// warnings and info messages become its notes.
+func lintStage(chartPath string) StageResult {
+	start := time.Now()
+	r := StageResult{Stage: StageLint}
+	result := action.NewLint().Run([]string{chartPath}, map[string]interface{}{})
+
+	var errs []string
+	for _, msg := range result.Messages {
+		if msg.Severity >= support.ErrorSev {
+			errs = append(errs, msg.Error())
+			continue
+		}
+		r.Notes = append(r.Notes, msg.Error())
+	}
+	if len(errs) == 0 {
+		for _, err := range result.Errors {
+			errs = append(errs, err.Error())
 		}
+	}
+	if len(errs) > 0 {
+		r.Err = fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	r.Duration = time.Since(start)
+	return r
+}
+
+// templateStage renders the chart at chartPath without a cluster, as the
+// release would be installed. It uses Helm settings of its own, so it is
+// safe to run next to the other stages.
+func templateStage(ctx context.Context, chartPath string, opts ProvisionOptions) StageResult {
+	start := time.Now()
+	r := StageResult{Stage: StageTemplate}
+
+	ch, err := loadLocalChart(chartPath, false)
+	if err != nil {
+		r.Err = fmt.Errorf("failed to load chart: %w", err)
+		r.Duration = time.Since(start)
+		return r
+	}
+	client := action.NewInstall(new(action.Configuration))
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.PostRenderer = schedulingRenderer()
+	client.ReleaseName = opts.ReleaseName
+	client.Namespace = opts.Namespace
+	rel, err := client.RunWithContext(ctx, ch, map[string]interface{}{})
+	if err != nil {
+		r.Err = fmt.Errorf("failed to render templates: %w", err)
+		r.Duration = time.Since(start)
+		return r
+	}
+	docs := releaseutil.SplitManifests(rel.Manifest)
+	r.Notes = append(r.Notes, fmt.Sprintf("rendered %d %s", len(docs), pluralize(len(docs), "manifest", "manifests")))
+	r.Duration = time.Since(start)
+	return r
+}
+
+// deployStage installs the release, or upgrades it when it exists.
+func deployStage(opts ProvisionOptions, useAI bool) StageResult {
+	start := time.Now()
+	r := StageResult{Stage: StageInstall}
 
-		// Actual upgrade
-		operationErr = HelmUpgrade(
-			releaseName,
-			chartPath,
-			namespace,
-			nil,           // setValues
-			nil,           // valuesFiles
-			nil,           // setLiteral
-			false,         // createNamespace
-			true,          // atomic
-			5*time.Minute, // timeout
-			false,         // dry-run
-			"",            // repoURL
-			"",            // version
-			true,
-			5,
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(settings.RESTClientGetter(), opts.Namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		r.Err = fmt.Errorf("failed to initialize Helm action configuration: %w", err)
+		r.Duration = time.Since(start)
+		return r
+	}
+	exists, err := checkReleaseExists(actionConfig, opts.ReleaseName)
+	if err != nil {
+		r.Err = err
+		r.Duration = time.Since(start)
+		return r
+	}
+
+	helmWait := configs.Timeouts.HelmWaitTimeout()
+	if exists {
+		r.Stage = StageUpgrade
+		pterm.Info.Printfln("Release %s exists, performing upgrade...", opts.ReleaseName)
+		r.Err = HelmUpgrade(
+			opts.ReleaseName,
+			opts.ChartPath,
+			opts.Namespace,
+			nil,      // setValues
+			nil,      // valuesFiles
+			nil,      // setLiteral
+			false,    // createNamespace
+			true,     // atomic
+			helmWait, // timeout
+			false,    // debug
+			"",       // repoURL
+			"",       // version
+			true,     // wait
+			5,        // historyMax
 			useAI,
-			false,
+			false, // force
 		)
 	} else {
-		pterm.Info.Printfln("Release %s does not exist, performing install...", releaseName)
-		operationErr = HelmInstall(
-			releaseName,
-			chartPath,
-			namespace,
-			[]string{},
-			5*time.Minute,
-			true, // createNamespace
-			true, // atomic
-			[]string{},
-			[]string{},
-			"",
-			"",
-			true,
+		pterm.Info.Printfln("Release %s does not exist, performing install...", opts.ReleaseName)
+		r.Err = HelmInstall(
+			opts.ReleaseName,
+			opts.ChartPath,
+			opts.Namespace,
+			nil,      // valuesFiles
+			helmWait, // duration
+			true,     // atomic
+			false,    // debug
+			nil,      // setValues
+			nil,      // setLiteralValues
+			"",       // repoURL
+			"",       // version
+			true,     // wait
 			useAI,
 		)
 	}
-	wg.Wait()
+	r.Duration = time.Since(start)
+	return r
+}
 
-	// Check all errors
-	if operationErr != nil {
-		pterm.Error.Printfln("Operation failed: %v \n", operationErr)
-		return operationErr
+// firstFailure returns the first failed stage of results, or nil.
+func firstFailure(results []StageResult) *StageResult {
+	for i := range results {
+		if results[i].Err != nil {
+			return &results[i]
+		}
 	}
-	if lintErr != nil {
-		pterm.Warning.Printfln("Lint warnings: %v \n", lintErr)
+	return nil
+}
+
+// printStageResult prints the outcome of one validation stage and its notes.
+func printStageResult(r StageResult) {
+	switch {
+	case r.Skipped:
+		pterm.Info.Printfln("%s: skipped", r.Stage)
+	case r.Err != nil:
+		pterm.Error.Printfln("%s: failed in %s: %v", r.Stage, r.Duration.Round(time.Millisecond), r.Err)
+	default:
+		pterm.Success.Printfln("%s: passed in %s", r.Stage, r.Duration.Round(time.Millisecond))
 	}
-	if templateErr != nil {
-		pterm.Warning.Printfln("Template warnings: %v \n", templateErr)
+	for _, note := range r.Notes {
+		pterm.FgYellow.Printfln("  %s", note)
 	}
+}
 
-	pterm.Success.Printfln("Provisioning completed successfully for %s in namespace %s", releaseName, namespace)
-	return nil
+// printStageSummary prints a table of the results of every stage.
+func printStageSummary(results []StageResult) {
+	tableData := pterm.TableData{{"STAGE", "RESULT", "DURATION"}}
+	for _, r := range results {
+		result, duration := "passed", r.Duration.Round(time.Millisecond).String()
+		switch {
+		case r.Skipped:
+			result, duration = "skipped", "-"
+		case r.Err != nil:
+			result = "failed"
+		case len(r.Notes) > 0 && r.Stage == StageLint:
+			result = fmt.Sprintf("passed (%d %s)", len(r.Notes), pluralize(len(r.Notes), "warning", "warnings"))
+		}
+		tableData = append(tableData, []string{r.Stage, result, duration})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
 }
 
 func checkReleaseExists(actionConfig *action.Configuration, releaseName string) (bool, error) {
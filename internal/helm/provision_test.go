@@ -0,0 +1,54 @@
+package helm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvisionValidationStages(t *testing.T) {
+	dir := writeTestChart(t, "suite: none\ntests: []\n")
+	deployment := filepath.Join(dir, "templates", "deployment.yaml")
+	tmpl, err := os.ReadFile(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl = []byte(strings.Replace(string(tmpl), "  template:\n", "  selector:\n    matchLabels:\n      app.kubernetes.io/name: app\n  template:\n", 1))
+	if err := os.WriteFile(deployment, tmpl, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := ProvisionOptions{ReleaseName: "web", ChartPath: dir, Namespace: "apps"}
+
+	if r := lintStage(dir); r.Err != nil || r.Stage != StageLint {
+		t.Errorf("lintStage = %+v, want a passed lint stage", r)
+	}
+	r := templateStage(context.Background(), dir, opts)
+	if r.Err != nil || len(r.Notes) != 1 || r.Notes[0] != "rendered 1 manifest" {
+		t.Errorf("templateStage = %+v, want one rendered manifest", r)
+	}
+
+	results := validateParallel(dir, opts)
+	if len(results) != 2 || results[0].Stage != StageLint || results[1].Stage != StageTemplate || firstFailure(results) != nil {
+		t.Errorf("validateParallel = %+v, want passed lint and template stages in order", results)
+	}
+
+	if err := os.WriteFile(deployment, []byte("apiVersion: apps/v1\nkind: Deployment\nspec: {{ .Values.missing.key }}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r := lintStage(dir); r.Err == nil {
+		t.Errorf("lintStage of a broken template = %+v, want a failure", r)
+	}
+
+	if err := os.WriteFile(deployment, tmpl, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("image:\n  repository: \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results = validateParallel(dir, opts)
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "image.repository is required") {
+		t.Errorf("validateParallel of a failing chart = %+v, want the template failure", results)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
 )
 
 func Pull(chartRef, version, destination string, untar bool, untarDir string,
@@ -42,6 +43,27 @@ func Pull(chartRef, version, destination string, untar bool, untarDir string,
 		return fmt.Errorf("failed to initialize Helm action config: %v", err)
 	}
 
+	// OCI charts are pulled with a registry client: the --username login
+	// when given, otherwise the saved and cloud registry logins.
+	if registry.IsOCI(chartRef) {
+		var (
+			client *registry.Client
+			err    error
+		)
+		if username != "" {
+			client, err = newLoginRegistryClient(username, password, plainHttp)
+		} else if plainHttp {
+			client, err = newRegistryClient(false, registry.ClientOptPlainHTTP())
+		} else {
+			client, err = newRegistryClient(false)
+		}
+		if err != nil {
+			pterm.Error.Printfln("✗ %v", err)
+			return err
+		}
+		actionConfig.RegistryClient = client
+	}
+
 	// Create pull action
 	pull := action.NewPullWithOpts(action.WithConfig(actionConfig))
 	pull.Settings = settings
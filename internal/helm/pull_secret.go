@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ImagePullSecretValue is the --set value that points a chart's pod specs at
+// an image pull secret, in the imagePullSecrets shape most charts (and the
+// 'helm create' scaffold) use.
+func ImagePullSecretValue(name string) string {
+	return "imagePullSecrets[0].name=" + name
+}
+
+// EnsureImagePullSecret creates the kubernetes.io/dockerconfigjson Secret
+// name in namespace, creating the namespace if needed, or updates it with
+// the given registry login. A Secret of another type is left alone.
+func EnsureImagePullSecret(namespace, name, server, username, password string) error {
+	if err := ensureNamespace(namespace, true); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return err
+	}
+	return ensureImagePullSecret(context.Background(), clientset, namespace, name, server, username, password)
+}
+
+func ensureImagePullSecret(ctx context.Context, clientset kubernetes.Interface, namespace, name, server, username, password string) error {
+	config, err := dockerConfigJSON(server, username, password)
+	if err != nil {
+		return err
+	}
+
+	secrets := clientset.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "smurf"},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: config},
+		}
+		if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create image pull secret %s: %w", name, err)
+		}
+		pterm.Success.Printfln("Created image pull secret %s/%s for %s", namespace, name, server)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get image pull secret %s: %w", name, err)
+	}
+
+	if existing.Type != corev1.SecretTypeDockerConfigJson {
+		return fmt.Errorf("secret %s/%s exists with type %s, not %s; choose another image pull secret name",
+			namespace, name, existing.Type, corev1.SecretTypeDockerConfigJson)
+	}
+	existing.Data = map[string][]byte{corev1.DockerConfigJsonKey: config}
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update image pull secret %s: %w", name, err)
+	}
+	pterm.Success.Printfln("Updated image pull secret %s/%s for %s", namespace, name, server)
+	return nil
+}
+
+// dockerConfigJSON renders a registry login as the .dockerconfigjson
+// document kubelet reads.
+func dockerConfigJSON(server, username, password string) ([]byte, error) {
+	type authEntry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	return json.Marshal(map[string]map[string]authEntry{
+		"auths": {
+			server: {
+				Username: username,
+				Password: password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDockerConfigJSON(t *testing.T) {
+	data, err := dockerConfigJSON("ghcr.io", "bot", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := config.Auths["ghcr.io"]
+	if !ok || entry.Username != "bot" || entry.Password != "token" || entry.Auth != "Ym90OnRva2Vu" {
+		t.Errorf("dockerconfigjson = %s", data)
+	}
+}
+
+func TestEnsureImagePullSecret(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "apps"},
+		Type:       corev1.SecretTypeOpaque,
+	})
+
+	if err := ensureImagePullSecret(ctx, clientset, "apps", "regcred", "ghcr.io", "bot", "old"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := ensureImagePullSecret(ctx, clientset, "apps", "regcred", "ghcr.io", "bot", "new"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	secret, err := clientset.CoreV1().Secrets("apps").Get(ctx, "regcred", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := dockerConfigJSON("ghcr.io", "bot", "new")
+	if secret.Type != corev1.SecretTypeDockerConfigJson || string(secret.Data[corev1.DockerConfigJsonKey]) != string(want) {
+		t.Errorf("secret = %s %s, want updated dockerconfigjson", secret.Type, secret.Data[corev1.DockerConfigJsonKey])
+	}
+
+	if err := ensureImagePullSecret(ctx, clientset, "apps", "app-config", "ghcr.io", "bot", "new"); err == nil {
+		t.Error("expected an error for an existing secret of another type")
+	}
+}
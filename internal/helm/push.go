@@ -0,0 +1,69 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// PushOptions are the TLS settings of a chart push.
+type PushOptions struct {
+	CertFile  string
+	KeyFile   string
+	CAFile    string
+	Insecure  bool
+	PlainHTTP bool
+}
+
+// Push uploads the chart archive chartPath to the OCI registry path remote
+// (oci://HOST/PATH) as remote/NAME:VERSION, with its provenance file when
+// one sits next to it, and returns the pushed reference. Registry logins
+// are resolved as for pulls; with TLS options only `selm registry login`
+// logins apply.
+func Push(chartPath, remote string, opts PushOptions, debug, useAI bool) (string, error) {
+	if !registry.IsOCI(remote) {
+		return "", fmt.Errorf("invalid remote %q: charts are pushed to oci://HOST/PATH", remote)
+	}
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return "", fmt.Errorf("failed to load %s: %w", chartPath, err)
+	}
+	ref := fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(remote, "/"), ch.Metadata.Name, ch.Metadata.Version)
+
+	cfg := new(action.Configuration)
+	if opts.CertFile == "" && opts.KeyFile == "" && opts.CAFile == "" && !opts.Insecure {
+		var clientOpts []registry.ClientOption
+		if opts.PlainHTTP {
+			clientOpts = append(clientOpts, registry.ClientOptPlainHTTP())
+		}
+		if cfg.RegistryClient, err = newRegistryClient(debug, clientOpts...); err != nil {
+			return "", err
+		}
+	}
+	push := action.NewPushWithOpts(action.WithPushConfig(cfg),
+		action.WithTLSClientConfig(opts.CertFile, opts.KeyFile, opts.CAFile),
+		action.WithInsecureSkipTLSVerify(opts.Insecure),
+		action.WithPlainHTTP(opts.PlainHTTP),
+		action.WithPushOptWriter(os.Stderr))
+	push.Settings = settings
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Pushing %s to %s", filepath.Base(chartPath), ref))
+	if _, err := push.Run(chartPath, remote); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to push %s", filepath.Base(chartPath)))
+		ai.AIExplainError(useAI, err.Error())
+		return "", fmt.Errorf("failed to push %s to %s: %w", chartPath, remote, err)
+	}
+	spinner.Success(fmt.Sprintf("Pushed %s", ref))
+	if _, err := os.Stat(chartPath + ".prov"); err == nil {
+		pterm.Info.Printfln("Pushed with its provenance file %s.prov", filepath.Base(chartPath))
+	}
+	return ref, nil
+}
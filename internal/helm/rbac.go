@@ -0,0 +1,412 @@
+package helm
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacVerbs are the verbs of the permission matrix, one column each. Other
+// verbs (escalate, bind, impersonate, use, ...) share a last column.
+var rbacVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete", "deletecollection"}
+
+// RBACOptions configures RBACReportFor.
+type RBACOptions struct {
+	Namespace        string
+	RepoURL          string
+	Version          string
+	ValuesFiles      []string
+	SetValues        []string
+	SetLiteralValues []string
+	Debug            bool
+}
+
+// RBACReport is the access a chart asks for: its ServiceAccounts, Roles,
+// ClusterRoles and their bindings, the permissions they grant as a matrix
+// of resources by verbs, and the grants a security review should look at.
+type RBACReport struct {
+	Chart           string           `json:"chart"`
+	ServiceAccounts []RBACObject     `json:"serviceAccounts"`
+	Roles           []RBACRole       `json:"roles"`
+	Bindings        []RBACBinding    `json:"bindings"`
+	Permissions     []RBACPermission `json:"permissions"`
+	Findings        []string         `json:"findings"`
+}
+
+// RBACObject is a namespaced object of the report; Namespace is empty for
+// cluster-scoped ones.
+type RBACObject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// RBACRole is a Role or ClusterRole with its rules.
+type RBACRole struct {
+	RBACObject
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+// RBACBinding is a RoleBinding or ClusterRoleBinding: the role it grants
+// and who to.
+type RBACBinding struct {
+	RBACObject
+	Role     string   `json:"role"`     // Kind/name of the role referenced
+	Subjects []string `json:"subjects"` // Kind/[namespace/]name
+}
+
+// RBACPermission is a row of the permission matrix: the verbs granted on a
+// resource, or a non-resource URL, and the roles that grant them. Scope is
+// cluster when a ClusterRole grants it cluster-wide, and namespace otherwise.
+type RBACPermission struct {
+	Resource  string   `json:"resource"`
+	Scope     string   `json:"scope"`
+	Verbs     []string `json:"verbs"`
+	GrantedBy []string `json:"grantedBy"`
+}
+
+// RBACReportFor renders releaseName from chartRef without a cluster, hooks
+// included, and prints the RBAC objects it contains with the permissions
+// they grant. output is table, json or yaml.
+func RBACReportFor(releaseName, chartRef string, opts RBACOptions, output string, useAI bool) error {
+	rel, _, err := renderRelease(releaseName, chartRef, opts.Namespace, opts.RepoURL, opts.Version,
+		opts.ValuesFiles, opts.SetValues, opts.SetLiteralValues, opts.Debug)
+	if err != nil {
+		pterm.Error.Println(err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	manifests := []string{rel.Manifest}
+	for _, h := range rel.Hooks {
+		manifests = append(manifests, h.Manifest)
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	report, err := buildRBACReport(chartRef, namespace, strings.Join(manifests, "\n---\n"))
+	if err != nil {
+		return err
+	}
+
+	if isStructured(output) {
+		return printStructured(report, output)
+	}
+	return printRBACReport(report)
+}
+
+// rbacDoc is the part of a manifest the report reads.
+type rbacDoc struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Rules    []rbacv1.PolicyRule `json:"rules"`
+	RoleRef  rbacv1.RoleRef      `json:"roleRef"`
+	Subjects []rbacv1.Subject    `json:"subjects"`
+}
+
+// buildRBACReport extracts the RBAC objects of manifest, whose namespaced
+// objects without a namespace are in namespace.
+func buildRBACReport(chart, namespace, manifest string) (*RBACReport, error) {
+	report := &RBACReport{
+		Chart:           chart,
+		ServiceAccounts: []RBACObject{},
+		Roles:           []RBACRole{},
+		Bindings:        []RBACBinding{},
+		Findings:        []string{},
+	}
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	for _, k := range keys {
+		var doc rbacDoc
+		if err := yaml.Unmarshal([]byte(docs[k]), &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", k, err)
+		}
+		obj := RBACObject{Kind: doc.Kind, Name: doc.Metadata.Name, Namespace: doc.Metadata.Namespace}
+		if obj.Namespace == "" {
+			obj.Namespace = namespace
+		}
+		switch doc.Kind {
+		case "ServiceAccount":
+			report.ServiceAccounts = append(report.ServiceAccounts, obj)
+		case "Role", "ClusterRole":
+			if doc.Kind == "ClusterRole" {
+				obj.Namespace = ""
+			}
+			rules := doc.Rules
+			if rules == nil {
+				rules = []rbacv1.PolicyRule{}
+			}
+			report.Roles = append(report.Roles, RBACRole{RBACObject: obj, Rules: rules})
+		case "RoleBinding", "ClusterRoleBinding":
+			if doc.Kind == "ClusterRoleBinding" {
+				obj.Namespace = ""
+			}
+			b := RBACBinding{RBACObject: obj, Role: doc.RoleRef.Kind + "/" + doc.RoleRef.Name, Subjects: []string{}}
+			for _, s := range doc.Subjects {
+				subject := s.Kind + "/" + s.Name
+				if s.Namespace != "" {
+					subject = s.Kind + "/" + s.Namespace + "/" + s.Name
+				}
+				b.Subjects = append(b.Subjects, subject)
+			}
+			report.Bindings = append(report.Bindings, b)
+		}
+	}
+
+	report.Permissions = rbacMatrix(report.Roles, report.Bindings)
+	report.Findings = rbacFindings(report)
+	return report, nil
+}
+
+// rbacMatrix merges the rules of roles into one row per resource, with the
+// verbs granted on it across roles. A ClusterRole grants cluster-wide unless
+// the chart only binds it with RoleBindings.
+func rbacMatrix(roles []RBACRole, bindings []RBACBinding) []RBACPermission {
+	clusterBound, namespaceBound := map[string]bool{}, map[string]bool{}
+	for _, b := range bindings {
+		if b.Kind == "ClusterRoleBinding" {
+			clusterBound[b.Role] = true
+		} else {
+			namespaceBound[b.Role] = true
+		}
+	}
+
+	rows := map[string]*RBACPermission{}
+	var order []string
+	grant := func(resource, scope, role string, verbs []string) {
+		row, ok := rows[resource]
+		if !ok {
+			row = &RBACPermission{Resource: resource, Scope: scope, Verbs: []string{}, GrantedBy: []string{}}
+			rows[resource] = row
+			order = append(order, resource)
+		}
+		if scope == "cluster" {
+			row.Scope = scope
+		}
+		for _, v := range verbs {
+			if !slices.Contains(row.Verbs, v) {
+				row.Verbs = append(row.Verbs, v)
+			}
+		}
+		if !slices.Contains(row.GrantedBy, role) {
+			row.GrantedBy = append(row.GrantedBy, role)
+		}
+	}
+
+	for _, r := range roles {
+		scope, role := "namespace", r.Kind+"/"+r.Name
+		if r.Kind == "ClusterRole" && (clusterBound[role] || !namespaceBound[role]) {
+			scope = "cluster"
+		}
+		for _, rule := range r.Rules {
+			for _, res := range ruleResources(rule) {
+				grant(res, scope, role, rule.Verbs)
+			}
+			for _, url := range rule.NonResourceURLs {
+				grant(url, "cluster", role, rule.Verbs)
+			}
+		}
+	}
+
+	sort.Strings(order)
+	matrix := make([]RBACPermission, 0, len(order))
+	for _, k := range order {
+		row := rows[k]
+		sort.Slice(row.Verbs, func(i, j int) bool { return verbOrder(row.Verbs[i]) < verbOrder(row.Verbs[j]) })
+		matrix = append(matrix, *row)
+	}
+	return matrix
+}
+
+// ruleResources names the resources of rule as kubectl does, resource.group
+// (pods, deployments.apps), followed by the resource names it is limited
+// to, if any.
+func ruleResources(rule rbacv1.PolicyRule) []string {
+	groups := rule.APIGroups
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	var out []string
+	for _, g := range groups {
+		for _, r := range rule.Resources {
+			name := r
+			if g != "" {
+				name = r + "." + g
+			}
+			if len(rule.ResourceNames) > 0 {
+				name += " [" + strings.Join(rule.ResourceNames, ",") + "]"
+			}
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// verbOrder sorts the matrix columns first, the wildcard before them, and
+// other verbs after them by name.
+func verbOrder(verb string) string {
+	if verb == "*" {
+		return "0"
+	}
+	if i := slices.Index(rbacVerbs, verb); i >= 0 {
+		return fmt.Sprintf("1%02d", i)
+	}
+	return "2" + verb
+}
+
+// rbacFindings lists the grants of report that a reviewer should look at:
+// wildcards, reading Secrets, privilege escalation, exec into pods, binding
+// cluster-admin, and bindings to roles the chart doesn't define.
+func rbacFindings(report *RBACReport) []string {
+	findings := []string{}
+	for _, r := range report.Roles {
+		role := r.Kind + "/" + r.Name
+		for _, rule := range r.Rules {
+			resources := ruleResources(rule)
+			if slices.Contains(rule.Verbs, "*") {
+				findings = append(findings, fmt.Sprintf("%s grants every verb (*) on %s", role, strings.Join(append(resources, rule.NonResourceURLs...), ", ")))
+			}
+			if slices.Contains(rule.Resources, "*") {
+				findings = append(findings, fmt.Sprintf("%s grants %s on every resource (*)", role, strings.Join(rule.Verbs, ",")))
+			}
+			if ruleCovers(rule, "", "secrets") && hasAnyVerb(rule, "get", "list", "watch") {
+				findings = append(findings, fmt.Sprintf("%s can read Secrets", role))
+			}
+			for _, v := range []string{"escalate", "bind", "impersonate"} {
+				if slices.Contains(rule.Verbs, v) {
+					findings = append(findings, fmt.Sprintf("%s grants %s", role, v))
+				}
+			}
+			if ruleCovers(rule, "", "pods/exec") && hasAnyVerb(rule, "create") {
+				findings = append(findings, fmt.Sprintf("%s can exec into pods", role))
+			}
+		}
+	}
+
+	defined := map[string]bool{}
+	for _, r := range report.Roles {
+		defined[r.Kind+"/"+r.Name] = true
+	}
+	for _, b := range report.Bindings {
+		binding := b.Kind + "/" + b.Name
+		if b.Role == "ClusterRole/cluster-admin" {
+			findings = append(findings, fmt.Sprintf("%s binds cluster-admin to %s", binding, strings.Join(b.Subjects, ", ")))
+		} else if !defined[b.Role] {
+			findings = append(findings, fmt.Sprintf("%s binds %s, which the chart doesn't define; its permissions are not in the matrix", binding, b.Role))
+		}
+	}
+	return findings
+}
+
+// ruleCovers reports whether rule applies to resource in group, wildcards
+// included.
+func ruleCovers(rule rbacv1.PolicyRule, group, resource string) bool {
+	groups := rule.APIGroups
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	return (slices.Contains(groups, group) || slices.Contains(groups, "*")) &&
+		(slices.Contains(rule.Resources, resource) || slices.Contains(rule.Resources, "*"))
+}
+
+// hasAnyVerb reports whether rule grants one of verbs, or every verb.
+func hasAnyVerb(rule rbacv1.PolicyRule, verbs ...string) bool {
+	for _, v := range rule.Verbs {
+		if v == "*" || slices.Contains(verbs, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// printRBACReport prints the report as tables: service accounts, roles,
+// bindings, then the permission matrix, with a ✓ for each verb granted and
+// * for all of them, and the findings last.
+func printRBACReport(r *RBACReport) error {
+	if len(r.ServiceAccounts)+len(r.Roles)+len(r.Bindings) == 0 {
+		pterm.Info.Printfln("%s creates no ServiceAccounts, Roles or bindings", r.Chart)
+		return nil
+	}
+
+	if len(r.ServiceAccounts) > 0 {
+		pterm.DefaultSection.Println("Service accounts")
+		data := pterm.TableData{{"NAMESPACE", "NAME"}}
+		for _, sa := range r.ServiceAccounts {
+			data = append(data, []string{sa.Namespace, sa.Name})
+		}
+		if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Bindings) > 0 {
+		pterm.DefaultSection.Println("Bindings")
+		data := pterm.TableData{{"KIND", "NAMESPACE", "NAME", "ROLE", "SUBJECTS"}}
+		for _, b := range r.Bindings {
+			ns := b.Namespace
+			if ns == "" {
+				ns = none
+			}
+			subjects := strings.Join(b.Subjects, ", ")
+			if subjects == "" {
+				subjects = none
+			}
+			data = append(data, []string{b.Kind, ns, b.Name, b.Role, subjects})
+		}
+		if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Permissions) > 0 {
+		pterm.DefaultSection.Println("Permissions")
+		header := append([]string{"RESOURCE", "SCOPE"}, rbacVerbs...)
+		header = append(header, "OTHER", "GRANTED BY")
+		data := pterm.TableData{header}
+		for _, p := range r.Permissions {
+			row := []string{p.Resource, p.Scope}
+			all := slices.Contains(p.Verbs, "*")
+			for _, v := range rbacVerbs {
+				switch {
+				case all:
+					row = append(row, "*")
+				case slices.Contains(p.Verbs, v):
+					row = append(row, "✓")
+				default:
+					row = append(row, "")
+				}
+			}
+			var other []string
+			for _, v := range p.Verbs {
+				if v != "*" && !slices.Contains(rbacVerbs, v) {
+					other = append(other, v)
+				}
+			}
+			row = append(row, strings.Join(other, ","), strings.Join(p.GrantedBy, ", "))
+			data = append(data, row)
+		}
+		if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range r.Findings {
+		pterm.Warning.Println(f)
+	}
+	return nil
+}
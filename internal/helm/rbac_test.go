@@ -0,0 +1,133 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const rbacManifest = `---
+# Source: app/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+---
+# Source: app/templates/role.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: app
+rules:
+  - apiGroups: [""]
+    resources: ["configmaps", "secrets"]
+    verbs: ["get", "list", "watch"]
+  - apiGroups: ["apps"]
+    resources: ["deployments"]
+    verbs: ["get", "patch"]
+---
+# Source: app/templates/clusterrole.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: app-nodes
+rules:
+  - apiGroups: [""]
+    resources: ["nodes"]
+    verbs: ["*"]
+  - nonResourceURLs: ["/metrics"]
+    verbs: ["get"]
+---
+# Source: app/templates/rolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: app
+  namespace: apps
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: app
+subjects:
+  - kind: ServiceAccount
+    name: app
+    namespace: apps
+---
+# Source: app/templates/clusterrolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: app-view
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: view
+subjects:
+  - kind: ServiceAccount
+    name: app
+    namespace: apps
+---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`
+
+func TestBuildRBACReport(t *testing.T) {
+	r, err := buildRBACReport("./app", "apps", rbacManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []RBACObject{{Kind: "ServiceAccount", Name: "app", Namespace: "apps"}}; !reflect.DeepEqual(r.ServiceAccounts, want) {
+		t.Errorf("ServiceAccounts = %+v, want %+v", r.ServiceAccounts, want)
+	}
+	if len(r.Roles) != 2 || len(r.Bindings) != 2 {
+		t.Fatalf("got %d roles and %d bindings, want 2 and 2", len(r.Roles), len(r.Bindings))
+	}
+	if b := r.Bindings[1]; b.Role != "ClusterRole/view" || b.Namespace != "" || !reflect.DeepEqual(b.Subjects, []string{"ServiceAccount/apps/app"}) {
+		t.Errorf("second binding = %+v, want the ClusterRoleBinding to view", b)
+	}
+
+	got := map[string]RBACPermission{}
+	for _, p := range r.Permissions {
+		got[p.Resource] = p
+	}
+	want := map[string]RBACPermission{
+		"/metrics":         {Resource: "/metrics", Scope: "cluster", Verbs: []string{"get"}, GrantedBy: []string{"ClusterRole/app-nodes"}},
+		"configmaps":       {Resource: "configmaps", Scope: "namespace", Verbs: []string{"get", "list", "watch"}, GrantedBy: []string{"Role/app"}},
+		"deployments.apps": {Resource: "deployments.apps", Scope: "namespace", Verbs: []string{"get", "patch"}, GrantedBy: []string{"Role/app"}},
+		"nodes":            {Resource: "nodes", Scope: "cluster", Verbs: []string{"*"}, GrantedBy: []string{"ClusterRole/app-nodes"}},
+		"secrets":          {Resource: "secrets", Scope: "namespace", Verbs: []string{"get", "list", "watch"}, GrantedBy: []string{"Role/app"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Permissions = %+v, want %+v", got, want)
+	}
+
+	wantFindings := []string{
+		"Role/app can read Secrets",
+		"ClusterRole/app-nodes grants every verb (*) on nodes",
+		"ClusterRoleBinding/app-view binds ClusterRole/view, which the chart doesn't define; its permissions are not in the matrix",
+	}
+	if !reflect.DeepEqual(r.Findings, wantFindings) {
+		t.Errorf("Findings = %q, want %q", r.Findings, wantFindings)
+	}
+}
+
+func TestRBACMatrixScope(t *testing.T) {
+	roles := []RBACRole{{
+		RBACObject: RBACObject{Kind: "ClusterRole", Name: "reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "get"}}},
+	}}
+	bindings := []RBACBinding{{RBACObject: RBACObject{Kind: "RoleBinding", Name: "reader", Namespace: "apps"}, Role: "ClusterRole/reader"}}
+
+	m := rbacMatrix(roles, bindings)
+	if len(m) != 1 || m[0].Scope != "namespace" || !reflect.DeepEqual(m[0].Verbs, []string{"get", "list"}) {
+		t.Errorf("rbacMatrix() = %+v, want pods get,list in the namespace of the RoleBinding", m)
+	}
+	if m := rbacMatrix(roles, nil); m[0].Scope != "cluster" {
+		t.Errorf("unbound ClusterRole scope = %q, want cluster", m[0].Scope)
+	}
+}
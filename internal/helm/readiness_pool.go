@@ -0,0 +1,171 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+// readinessWorkers bounds how many per-kind list calls one readiness tick
+// issues at once, so a release with many kinds is checked quickly without
+// flooding the API server.
+const readinessWorkers = 4
+
+// readinessTickTimeout bounds one round of readiness checks, independent of
+// the overall polling deadline, so a slow API server can't wedge a tick.
+const readinessTickTimeout = 30 * time.Second
+
+// cachedList is one list call shared by every check of a tick that needs it.
+type cachedList struct {
+	once sync.Once
+	val  interface{}
+	err  error
+}
+
+// readinessCheck is one per-kind check of a readiness tick.
+type readinessCheck func(*ResourceChecker, context.Context) (bool, error)
+
+// readinessChecks are the per-kind checks of a tick. Their order is the
+// order failures are reported in.
+var readinessChecks = []struct {
+	kind  string
+	check readinessCheck
+}{
+	{"Deployment", (*ResourceChecker).checkDeploymentsHealthy},
+	{"StatefulSet", (*ResourceChecker).checkStatefulSetsHealthy},
+	{"DaemonSet", (*ResourceChecker).checkDaemonSetsHealthy},
+	{"Job", (*ResourceChecker).checkJobsHealthy},
+	{"CronJob", (*ResourceChecker).checkCronJobsHealthy},
+	{"Pod", (*ResourceChecker).checkPodsHealthy},
+}
+
+// progressDeadlines is how long each kind of a release may take to become
+// ready: timeouts.progressDeadlines of smurf.yaml, or the fallback for the
+// kinds it doesn't set. Pods and CronJobs belong to the other kinds, so
+// they get the longest deadline of all.
+type progressDeadlines map[string]time.Duration
+
+func newProgressDeadlines(fallback time.Duration) progressDeadlines {
+	d := progressDeadlines{}
+	longest := fallback
+	for _, kind := range []string{"Deployment", "StatefulSet", "DaemonSet", "Job"} {
+		d[kind] = configs.Timeouts.ProgressDeadline(kind, fallback)
+		longest = max(longest, d[kind])
+	}
+	d["CronJob"], d["Pod"] = longest, longest
+	return d
+}
+
+// longest returns the deadline of the slowest kind, after which nothing of
+// the release is left to wait for.
+func (d progressDeadlines) longest() time.Duration {
+	var longest time.Duration
+	for _, deadline := range d {
+		longest = max(longest, deadline)
+	}
+	return longest
+}
+
+// overdue returns the first of the not-ready kinds that has been waited on
+// past its deadline.
+func (d progressDeadlines) overdue(notReady []string, elapsed time.Duration) (string, bool) {
+	for _, kind := range notReady {
+		if deadline, ok := d[kind]; ok && elapsed > deadline {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// beginTick drops the list results of the previous tick.
+func (r *ResourceChecker) beginTick() {
+	r.mu.Lock()
+	r.cache = map[string]*cachedList{}
+	r.mu.Unlock()
+}
+
+// cachedList lists resourceType once per tick: checks running concurrently
+// that need the same list wait for the one request in flight.
+func (r *ResourceChecker) cachedList(ctx context.Context, resourceType string, listFunc func(context.Context) (interface{}, error)) (interface{}, error) {
+	r.mu.Lock()
+	if r.cache == nil {
+		r.mu.Unlock()
+		return listFunc(ctx)
+	}
+	entry, ok := r.cache[resourceType]
+	if !ok {
+		entry = &cachedList{}
+		r.cache[resourceType] = entry
+	}
+	r.mu.Unlock()
+
+	entry.once.Do(func() { entry.val, entry.err = listFunc(ctx) })
+	return entry.val, entry.err
+}
+
+// checkAllResourcesHealthy runs one tick of the readiness checks and
+// reports whether every kind is ready.
+func checkAllResourcesHealthy(checker *ResourceChecker) (bool, error) {
+	notReady, err := checkResourcesByKind(checker)
+	return err == nil && len(notReady) == 0, err
+}
+
+// checkResourcesByKind runs one tick of the readiness checks and returns
+// the kinds that are not ready yet. The per-kind checks run concurrently on
+// at most readinessWorkers workers and share their list results; the first
+// failure, in the order of readinessChecks, cancels the checks still
+// running and is returned.
+func checkResourcesByKind(checker *ResourceChecker) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTickTimeout)
+	defer cancel()
+	checker.beginTick()
+
+	healthy := make([]bool, len(readinessChecks))
+	errs := make([]error, len(readinessChecks))
+	sem := make(chan struct{}, readinessWorkers)
+	var wg sync.WaitGroup
+	for i, c := range readinessChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if errs[i] = ctx.Err(); errs[i] != nil {
+				return
+			}
+			healthy[i], errs[i] = c.check(checker, ctx)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Checks cut short by the cancel fail with context.Canceled; report the
+	// failure that caused it instead.
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if canceled == nil {
+			canceled = err
+		}
+	}
+	if canceled != nil {
+		return nil, canceled
+	}
+	var notReady []string
+	for i, ok := range healthy {
+		if !ok {
+			notReady = append(notReady, readinessChecks[i].kind)
+		}
+	}
+	return notReady, nil
+}
@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func releaseMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: "apps", Labels: map[string]string{"app.kubernetes.io/instance": "web"}}
+}
+
+func readyPod(name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: releaseMeta(name), Status: corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+	}}
+}
+
+func TestCheckAllResourcesHealthy(t *testing.T) {
+	deployment := func(available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: releaseMeta("web"), Status: appsv1.DeploymentStatus{Replicas: 2, AvailableReplicas: available}}
+	}
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		healthy bool
+		err     string
+	}{
+		{"ready", []runtime.Object{deployment(2), readyPod("web-1")}, true, ""},
+		{"rolling out", []runtime.Object{deployment(1), readyPod("web-1")}, false, ""},
+		{"failed job", []runtime.Object{
+			deployment(1),
+			&batchv1.Job{ObjectMeta: releaseMeta("migrate"), Status: batchv1.JobStatus{Failed: 1}},
+		}, false, "job migrate has failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewResourceChecker(fake.NewClientset(tt.objects...), "apps", "web", false)
+			healthy, err := checkAllResourcesHealthy(checker)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || healthy != tt.healthy {
+				t.Errorf("checkAllResourcesHealthy = %v, %v; want %v, nil", healthy, err, tt.healthy)
+			}
+		})
+	}
+}
+
+func TestCachedListOncePerTick(t *testing.T) {
+	checker := NewResourceChecker(fake.NewClientset(), "apps", "web", false)
+	var calls atomic.Int32
+	list := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		return "pods", nil
+	}
+
+	checker.beginTick()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := checker.cachedList(context.Background(), "pods", list); v != "pods" || err != nil {
+				t.Errorf("cachedList = %v, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("list called %d times in one tick, want 1", got)
+	}
+
+	checker.beginTick()
+	_, _ = checker.cachedList(context.Background(), "pods", list)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("list called %d times over two ticks, want 2", got)
+	}
+}
+
+func TestCheckResourcesByKind(t *testing.T) {
+	checker := NewResourceChecker(fake.NewClientset(
+		&appsv1.Deployment{ObjectMeta: releaseMeta("web"), Status: appsv1.DeploymentStatus{Replicas: 2, AvailableReplicas: 2}},
+		&appsv1.StatefulSet{ObjectMeta: releaseMeta("db"), Status: appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 1}},
+		readyPod("web-1"),
+	), "apps", "web", false)
+	notReady, err := checkResourcesByKind(checker)
+	if err != nil || !slices.Equal(notReady, []string{"StatefulSet"}) {
+		t.Errorf("checkResourcesByKind = %v, %v; want [StatefulSet], nil", notReady, err)
+	}
+}
+
+func TestProgressDeadlines(t *testing.T) {
+	saved := configs.Timeouts
+	t.Cleanup(func() { configs.Timeouts = saved })
+	configs.Timeouts.ProgressDeadlines = configs.ProgressDeadlines{StatefulSet: 900, Job: 1800}
+
+	d := newProgressDeadlines(5 * time.Minute)
+	for kind, want := range map[string]time.Duration{
+		"Deployment":  5 * time.Minute,
+		"StatefulSet": 15 * time.Minute,
+		"Job":         30 * time.Minute,
+		"Pod":         30 * time.Minute,
+	} {
+		if d[kind] != want {
+			t.Errorf("deadline of %s = %v, want %v", kind, d[kind], want)
+		}
+	}
+	if got := d.longest(); got != 30*time.Minute {
+		t.Errorf("longest = %v, want 30m", got)
+	}
+
+	if kind, ok := d.overdue([]string{"StatefulSet", "Pod"}, 10*time.Minute); ok {
+		t.Errorf("overdue after 10m = %s, want none", kind)
+	}
+	if kind, ok := d.overdue([]string{"Deployment", "StatefulSet"}, 10*time.Minute); !ok || kind != "Deployment" {
+		t.Errorf("overdue after 10m = %q, %v; want Deployment", kind, ok)
+	}
+}
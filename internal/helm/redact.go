@@ -0,0 +1,176 @@
+package helm
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+)
+
+const (
+	redactedValue        = "[REDACTED]"
+	redactedChangedValue = "[REDACTED] (changed)"
+)
+
+var listIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// isSecretKey reports whether the value at keys, the path of map keys from
+// the top of the values, matches a pattern of configs.RedactKeys. A pattern
+// is a dotted key path whose parts are globs, matched case-insensitively
+// against the end of keys: password matches every key named password,
+// *.secret every secret key below another, and *token* any key containing
+// token. List items take the path of the list.
+func isSecretKey(keys []string) bool {
+	if configs.ShowSecrets || len(keys) == 0 {
+		return false
+	}
+	for _, pattern := range configs.RedactKeys {
+		parts := strings.Split(strings.ToLower(pattern), ".")
+		if len(parts) > len(keys) {
+			continue
+		}
+		tail := keys[len(keys)-len(parts):]
+		matched := true
+		for i, part := range parts {
+			if ok, _ := path.Match(part, strings.ToLower(tail[i])); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretSetKey is isSecretKey for a --set key such as db.password or
+// users[0].token.
+func isSecretSetKey(key string) bool {
+	return isSecretKey(strings.Split(listIndexPattern.ReplaceAllString(key, ""), "."))
+}
+
+// redactValuesPair masks the secret values of two versions of the same
+// values for a diff. A secret that differs between them is masked with a
+// different placeholder in b, so the diff still shows that it changed.
+func redactValuesPair(a, b map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if configs.ShowSecrets || len(configs.RedactKeys) == 0 {
+		return a, b
+	}
+	ra, _ := redactValue(a, nil, a, redactedValue).(map[string]interface{})
+	rb, _ := redactValue(b, nil, a, redactedChangedValue).(map[string]interface{})
+	return ra, rb
+}
+
+// redactValue masks the secret keys below keys in v. other is the value at
+// the same keys in the values v is compared with: a secret equal to it is
+// masked with redactedValue, one that differs with changed.
+func redactValue(v interface{}, keys []string, other interface{}, changed string) interface{} {
+	if isSecretKey(keys) {
+		if reflect.DeepEqual(v, other) {
+			return redactedValue
+		}
+		return changed
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		om, _ := other.(map[string]interface{})
+		out := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			out[k] = redactValue(x, append(keys[:len(keys):len(keys)], k), om[k], changed)
+		}
+		return out
+	case []interface{}:
+		ol, _ := other.([]interface{})
+		out := make([]interface{}, len(t))
+		for i, x := range t {
+			var o interface{}
+			if i < len(ol) {
+				o = ol[i]
+			}
+			out[i] = redactValue(x, keys, o, changed)
+		}
+		return out
+	}
+	return v
+}
+
+// redactSets masks the values of secret keys in --set style key=value
+// strings, for debug output.
+func redactSets(sets []string) []string {
+	if configs.ShowSecrets || len(configs.RedactKeys) == 0 {
+		return sets
+	}
+	out := make([]string, len(sets))
+	for i, s := range sets {
+		pairs := strings.Split(s, ",")
+		secret := false
+		for j, pair := range pairs {
+			key, _, ok := strings.Cut(pair, "=")
+			if ok {
+				secret = isSecretSetKey(key)
+			}
+			switch {
+			case secret && ok:
+				pairs[j] = key + "=" + redactedValue
+			case secret:
+				// The rest of a {a,b} list or of a value with an escaped comma.
+				pairs[j] = ""
+			}
+		}
+		out[i] = strings.Join(compactStrings(pairs), ",")
+	}
+	return out
+}
+
+func compactStrings(s []string) []string {
+	out := s[:0]
+	for _, v := range s {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// registerSecretValues hands the scalar values of the secret keys in vals
+// to ai.Redact, so they are also masked where a chart renders them into a
+// manifest, e.g. in a manifest diff.
+func registerSecretValues(vals map[string]interface{}) {
+	if configs.ShowSecrets || len(configs.RedactKeys) == 0 {
+		return
+	}
+	var walk func(v interface{}, keys []string, secret bool)
+	walk = func(v interface{}, keys []string, secret bool) {
+		secret = secret || isSecretKey(keys)
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, x := range t {
+				walk(x, append(keys[:len(keys):len(keys)], k), secret)
+			}
+		case []interface{}:
+			for _, x := range t {
+				walk(x, keys, secret)
+			}
+		case nil:
+		default:
+			if secret {
+				ai.RegisterSecrets(fmt.Sprint(t))
+			}
+		}
+	}
+	walk(vals, nil, false)
+}
+
+// redactManifestDiff masks the secret values registered from the values of
+// the releases a manifest diff is made from.
+func redactManifestDiff(diff string) string {
+	if configs.ShowSecrets || len(configs.RedactKeys) == 0 {
+		return diff
+	}
+	return ai.Redact(diff)
+}
@@ -0,0 +1,100 @@
+package helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+func withRedactKeys(t *testing.T, keys ...string) {
+	t.Helper()
+	saved, savedShow := configs.RedactKeys, configs.ShowSecrets
+	configs.RedactKeys, configs.ShowSecrets = keys, false
+	t.Cleanup(func() { configs.RedactKeys, configs.ShowSecrets = saved, savedShow })
+}
+
+func TestIsSecretKey(t *testing.T) {
+	withRedactKeys(t, "password", "*.secret", "*token*")
+	cases := map[string]bool{
+		"password":          true,
+		"db.Password":       true,
+		"secret":            false,
+		"auth.secret":       true,
+		"a.b.secret":        true,
+		"slack.apiToken":    true,
+		"image.tag":         false,
+		"db.passwordPolicy": false,
+	}
+	for key, want := range cases {
+		if got := isSecretSetKey(key); got != want {
+			t.Errorf("isSecretSetKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if !isSecretSetKey("users[0].token") {
+		t.Error("list index in a --set key not ignored")
+	}
+
+	configs.ShowSecrets = true
+	if isSecretSetKey("db.password") {
+		t.Error("--show-secrets still masks")
+	}
+}
+
+func TestRedactValuesPair(t *testing.T) {
+	withRedactKeys(t, "password")
+	a := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "db1", "password": "hunter22"},
+		"users": []interface{}{map[string]interface{}{"name": "x", "password": "same"}},
+	}
+	b := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "db2", "password": "hunter23"},
+		"users": []interface{}{map[string]interface{}{"name": "x", "password": "same"}},
+	}
+
+	ra, rb := redactValuesPair(a, b)
+	wantA := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "db1", "password": redactedValue},
+		"users": []interface{}{map[string]interface{}{"name": "x", "password": redactedValue}},
+	}
+	wantB := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "db2", "password": redactedChangedValue},
+		"users": []interface{}{map[string]interface{}{"name": "x", "password": redactedValue}},
+	}
+	if !reflect.DeepEqual(ra, wantA) {
+		t.Errorf("a = %v, want %v", ra, wantA)
+	}
+	if !reflect.DeepEqual(rb, wantB) {
+		t.Errorf("b = %v, want %v", rb, wantB)
+	}
+	if a["db"].(map[string]interface{})["password"] != "hunter22" {
+		t.Error("redaction modified the original values")
+	}
+}
+
+func TestRedactSets(t *testing.T) {
+	withRedactKeys(t, "*.password")
+	got := redactSets([]string{"db.password=s3cr3t,image.tag=v1", "db.host=x", "db.password={a,b},replicas=2"})
+	want := []string{"db.password=[REDACTED],image.tag=v1", "db.host=x", "db.password=[REDACTED],replicas=2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redactSets = %q, want %q", got, want)
+	}
+}
+
+func TestCompareReleasesRedacts(t *testing.T) {
+	withRedactKeys(t, "apiKey")
+	manifest := "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  key: "
+	a := testRevision(1, "1.0.0", map[string]interface{}{"apiKey": "key-aaaa"}, manifest+"key-aaaa\n")
+	b := testRevision(1, "1.0.0", map[string]interface{}{"apiKey": "key-bbbb"}, manifest+"key-bbbb\n")
+
+	c, err := compareReleases(a, b, [2]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("compareReleases: %v", err)
+	}
+	for _, s := range []string{c.ValuesDiff, c.Resources[0].Diff} {
+		if s == "" || strings.Contains(s, "key-aaaa") || strings.Contains(s, "key-bbbb") {
+			t.Errorf("secret shown or change hidden:\n%s", s)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// RegistryLoginOptions are the TLS settings and credentials of a registry
+// login.
+type RegistryLoginOptions struct {
+	Username  string
+	Password  string
+	CertFile  string
+	KeyFile   string
+	CAFile    string
+	Insecure  bool
+	PlainHTTP bool
+}
+
+// locateChart is ChartPathOptions.LocateChart with a registry client, so
+// oci:// references resolve like local and repository charts. With
+// configs.Verify the chart must carry a provenance signed by a key in
+// configs.Keyring.
+func locateChart(chartRef string, opts action.ChartPathOptions, settings *cli.EnvSettings, debug bool) (string, error) {
+	install := action.NewInstall(new(action.Configuration))
+	install.ChartPathOptions = opts
+	if configs.Verify {
+		install.Verify = true
+		install.Keyring = configs.Keyring
+	}
+	if registry.IsOCI(chartRef) {
+		client, err := newRegistryClient(debug)
+		if err != nil {
+			return "", err
+		}
+		install.SetRegistryClient(client)
+	}
+	return install.ChartPathOptions.LocateChart(chartRef, settings)
+}
+
+// RegistryLogin checks credentials against an OCI registry and saves them in
+// Helm's registry config, where chart pulls and pushes find them.
+func RegistryLogin(host string, opts RegistryLoginOptions) error {
+	client, err := newLoginRegistryClient(opts.Username, opts.Password, opts.PlainHTTP)
+	if err != nil {
+		return err
+	}
+	login := action.NewRegistryLogin(&action.Configuration{RegistryClient: client})
+	return login.Run(os.Stderr, host, opts.Username, opts.Password,
+		action.WithCertFile(opts.CertFile),
+		action.WithKeyFile(opts.KeyFile),
+		action.WithCAFile(opts.CAFile),
+		action.WithInsecure(opts.Insecure),
+		action.WithPlainHTTPLogin(opts.PlainHTTP))
+}
+
+// RegistryLogout removes the credentials of an OCI registry from Helm's
+// registry config.
+func RegistryLogout(host string) error {
+	client, err := newLoginRegistryClient("", "", false)
+	if err != nil {
+		return err
+	}
+	return action.NewRegistryLogout(&action.Configuration{RegistryClient: client}).Run(os.Stderr, host)
+}
+
+// newLoginRegistryClient returns a registry client that authenticates with
+// username and password and stores logins in Helm's registry config.
+func newLoginRegistryClient(username, password string, plainHTTP bool) (*registry.Client, error) {
+	opts := []registry.ClientOption{
+		registry.ClientOptWriter(os.Stderr),
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptBasicAuth(username, password),
+	}
+	if plainHTTP {
+		opts = append(opts, registry.ClientOptPlainHTTP())
+	}
+	client, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,31 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+)
+
+func TestLocateChartVerify(t *testing.T) {
+	archive, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: "v2", Name: "web", Version: "1.0.0"}}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldVerify, oldKeyring := configs.Verify, configs.Keyring
+	defer func() { configs.Verify, configs.Keyring = oldVerify, oldKeyring }()
+
+	configs.Verify = false
+	if path, err := locateChart(archive, action.ChartPathOptions{}, cli.New(), false); err != nil || path != archive {
+		t.Errorf("locateChart = %q, %v; want the archive", path, err)
+	}
+
+	configs.Verify, configs.Keyring = true, "testdata/missing.gpg"
+	if _, err := locateChart(archive, action.ChartPathOptions{}, cli.New(), false); err == nil || !strings.Contains(err.Error(), "prov") {
+		t.Errorf("locateChart of an unsigned chart with --verify = %v, want a provenance error", err)
+	}
+}
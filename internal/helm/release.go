@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/cli"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -22,8 +22,7 @@ func HelmReleaseExists(releaseName, namespace string, debug, useAI bool) (bool,
 		pterm.Printf("Release: %s, Namespace: %s\n", releaseName, namespace)
 	}
 
-	settings := cli.New()
-	settings.SetNamespace(namespace)
+	settings := newSettings(namespace)
 
 	actionConfig := new(action.Configuration)
 	err := actionConfig.Init(
@@ -114,3 +113,16 @@ func HelmReleaseExists(releaseName, namespace string, debug, useAI bool) (bool,
 
 	return false, nil
 }
+
+// WaitForRelease blocks until the workloads and pods of a release are ready,
+// as the readiness check after an upgrade does, or timeout passes. Multi-
+// release deploys use it as the gate before deploying dependent releases.
+func WaitForRelease(releaseName, namespace string, timeout time.Duration, debug bool) error {
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Waiting for release %s to be ready...", releaseName))
+	if err := verifyFinalReadiness(namespace, releaseName, timeout, debug); err != nil {
+		spinner.Fail(fmt.Sprintf("Release %s is not ready", releaseName))
+		return fmt.Errorf("release %s/%s is not ready: %w", namespace, releaseName, err)
+	}
+	spinner.Success(fmt.Sprintf("Release %s is ready", releaseName))
+	return nil
+}
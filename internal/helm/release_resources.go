@@ -0,0 +1,347 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReleaseResource is a resource of a release with the live state selm
+// status reports for its kind. Status holds the kind's fields, listed in
+// resourceStatusFields; resources of other kinds have none.
+type ReleaseResource struct {
+	Kind       string            `json:"kind" yaml:"kind"`
+	Name       string            `json:"name" yaml:"name"`
+	Status     map[string]any    `json:"status,omitempty" yaml:"status,omitempty"`
+	Containers []ContainerDetail `json:"containers,omitempty" yaml:"containers,omitempty"`
+	Ports      []PortDetail      `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Events     []EventDetail     `json:"events,omitempty" yaml:"events,omitempty"`
+	// Error is why the resource's state could not be read.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ContainerDetail is the state of one container of a pod.
+type ContainerDetail struct {
+	Name     string `json:"name" yaml:"name"`
+	Ready    bool   `json:"ready" yaml:"ready"`
+	State    string `json:"state" yaml:"state"`
+	Restarts int32  `json:"restarts" yaml:"restarts"`
+	Image    string `json:"image" yaml:"image"`
+}
+
+// PortDetail is one port of a service.
+type PortDetail struct {
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	Port       int32  `json:"port" yaml:"port"`
+	TargetPort string `json:"target_port" yaml:"target_port"`
+	Protocol   string `json:"protocol" yaml:"protocol"`
+	NodePort   int32  `json:"node_port,omitempty" yaml:"node_port,omitempty"`
+}
+
+// EventDetail is one event of a pod.
+type EventDetail struct {
+	LastSeen time.Time `json:"last_seen" yaml:"last_seen"`
+	Type     string    `json:"type" yaml:"type"`
+	Reason   string    `json:"reason" yaml:"reason"`
+	Message  string    `json:"message" yaml:"message"`
+}
+
+// ReleaseResources is what a release runs: the resources of its manifest
+// and the pods that carry its instance label.
+type ReleaseResources struct {
+	Resources []ReleaseResource `json:"resources" yaml:"resources"`
+	Pods      []ReleaseResource `json:"pods" yaml:"pods"`
+	// PodsError is why the pods could not be listed.
+	PodsError string `json:"pods_error,omitempty" yaml:"pods_error,omitempty"`
+}
+
+// resourceStatusFields are the Status fields of each kind, in the order
+// the table shows them.
+var resourceStatusFields = map[string][]string{
+	"Deployment":     {"replicas", "desired", "ready", "updated", "available"},
+	"ReplicaSet":     {"replicas", "desired", "ready"},
+	"StatefulSet":    {"replicas", "current", "ready"},
+	"DaemonSet":      {"desired", "current", "ready", "updated", "available"},
+	"Pod":            {"phase", "ready", "node", "pod_ip", "start_time"},
+	"Service":        {"type", "cluster_ip", "external_ip", "age"},
+	"ServiceAccount": {"secrets", "age"},
+	"ConfigMap":      {"data", "binary_data", "age"},
+	"Secret":         {"type", "data", "age"},
+	"Namespace":      {"status", "age"},
+}
+
+// collectReleaseResources reads the live state of the resources of rel and
+// of its pods. A resource that can't be read carries the error instead.
+func collectReleaseResources(ctx context.Context, clientset kubernetes.Interface, rel *release.Release) (ReleaseResources, error) {
+	resources, err := parseResourcesFromManifest(rel.Manifest)
+	if err != nil {
+		return ReleaseResources{}, err
+	}
+	out := ReleaseResources{Resources: []ReleaseResource{}, Pods: []ReleaseResource{}}
+	for _, r := range resources {
+		out.Resources = append(out.Resources, describeReleaseResource(ctx, clientset, rel.Namespace, r))
+	}
+
+	pods, err := clientset.CoreV1().Pods(rel.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf(appKubernets, rel.Name),
+	})
+	if err != nil {
+		out.PodsError = err.Error()
+		return out, nil
+	}
+	for i := range pods.Items {
+		pod := podResource(&pods.Items[i])
+		evts, err := clientset.CoreV1().Events(rel.Namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod.Name),
+		})
+		if err != nil {
+			pod.Error = fmt.Sprintf("failed to list events: %v", err)
+		} else {
+			for _, e := range evts.Items {
+				pod.Events = append(pod.Events, EventDetail{LastSeen: lastSeen(e), Type: e.Type, Reason: e.Reason, Message: e.Message})
+			}
+		}
+		out.Pods = append(out.Pods, pod)
+	}
+	return out, nil
+}
+
+// describeReleaseResource reads the live state of the manifest resource r.
+func describeReleaseResource(ctx context.Context, clientset kubernetes.Interface, namespace string, r Resource) ReleaseResource {
+	res := ReleaseResource{Kind: r.Kind, Name: r.Name}
+	get := metav1.GetOptions{}
+	var err error
+	switch r.Kind {
+	case "Deployment":
+		d, e := clientset.AppsV1().Deployments(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{
+				"replicas": replicaCount(d.Spec.Replicas), "desired": d.Status.Replicas, "ready": d.Status.ReadyReplicas,
+				"updated": d.Status.UpdatedReplicas, "available": d.Status.AvailableReplicas,
+			}
+		}
+	case "ReplicaSet":
+		rs, e := clientset.AppsV1().ReplicaSets(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"replicas": replicaCount(rs.Spec.Replicas), "desired": rs.Status.Replicas, "ready": rs.Status.ReadyReplicas}
+		}
+	case "StatefulSet":
+		ss, e := clientset.AppsV1().StatefulSets(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"replicas": replicaCount(ss.Spec.Replicas), "current": ss.Status.CurrentReplicas, "ready": ss.Status.ReadyReplicas}
+		}
+	case "DaemonSet":
+		ds, e := clientset.AppsV1().DaemonSets(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{
+				"desired": ds.Status.DesiredNumberScheduled, "current": ds.Status.CurrentNumberScheduled, "ready": ds.Status.NumberReady,
+				"updated": ds.Status.UpdatedNumberScheduled, "available": ds.Status.NumberAvailable,
+			}
+		}
+	case "Pod":
+		pod, e := clientset.CoreV1().Pods(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res = podResource(pod)
+		}
+	case "Service":
+		svc, e := clientset.CoreV1().Services(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{
+				"type": string(svc.Spec.Type), "cluster_ip": svc.Spec.ClusterIP, "external_ip": getExternalIP(svc), "age": resourceAge(svc.CreationTimestamp),
+			}
+			for _, p := range svc.Spec.Ports {
+				res.Ports = append(res.Ports, PortDetail{Name: p.Name, Port: p.Port, TargetPort: p.TargetPort.String(), Protocol: string(p.Protocol), NodePort: p.NodePort})
+			}
+		}
+	case "ServiceAccount":
+		sa, e := clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"secrets": len(sa.Secrets), "age": resourceAge(sa.CreationTimestamp)}
+		}
+	case "ConfigMap":
+		cm, e := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"data": len(cm.Data), "binary_data": len(cm.BinaryData), "age": resourceAge(cm.CreationTimestamp)}
+		}
+	case "Secret":
+		secret, e := clientset.CoreV1().Secrets(namespace).Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"type": string(secret.Type), "data": len(secret.Data), "age": resourceAge(secret.CreationTimestamp)}
+		}
+	case "Namespace":
+		ns, e := clientset.CoreV1().Namespaces().Get(ctx, r.Name, get)
+		if err = e; err == nil {
+			res.Status = map[string]any{"status": string(ns.Status.Phase), "age": resourceAge(ns.CreationTimestamp)}
+		}
+	}
+	if err != nil {
+		res.Error = fmt.Sprintf("failed to get details: %v", err)
+	}
+	return res
+}
+
+// podResource describes pod with its containers.
+func podResource(pod *corev1.Pod) ReleaseResource {
+	ready := false
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+			ready = true
+			break
+		}
+	}
+	res := ReleaseResource{Kind: "Pod", Name: pod.Name, Status: map[string]any{
+		"phase": string(pod.Status.Phase), "ready": ready, "node": pod.Spec.NodeName, "pod_ip": pod.Status.PodIP,
+	}}
+	if pod.Status.StartTime != nil {
+		res.Status["start_time"] = pod.Status.StartTime.Time
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		state := ""
+		if cs.State.Waiting != nil {
+			state = fmt.Sprintf("Waiting (%s)", cs.State.Waiting.Reason)
+		} else if cs.State.Terminated != nil {
+			state = fmt.Sprintf("Terminated (%s)", cs.State.Terminated.Reason)
+		} else if cs.State.Running != nil {
+			state = fmt.Sprintf("Running (since %s)", cs.State.Running.StartedAt.Format(time.RFC1123))
+		}
+		res.Containers = append(res.Containers, ContainerDetail{Name: cs.Name, Ready: cs.Ready, State: state, Restarts: cs.RestartCount, Image: cs.Image})
+	}
+	return res
+}
+
+// resourceAge is how long ago created was, to the second.
+func resourceAge(created metav1.Time) string {
+	return time.Since(created.Time).Round(time.Second).String()
+}
+
+// printResourcesFromRelease prints the live state of the resources created
+// by rel and of its pods, one table per resource.
+func printResourcesFromRelease(rel *release.Release) {
+	resources, err := parseResourcesFromManifest(rel.Manifest)
+	if err != nil {
+		pterm.Error.WithShowLineNumber(false).Printfln("Error parsing manifest: %v", err)
+		return
+	}
+	if len(resources) == 0 {
+		pterm.Info.Println("No Kubernetes resources were created by this release.")
+		return
+	}
+
+	pterm.DefaultSection.Println("RESOURCES")
+	clientset, err := getKubeClient()
+	if err != nil {
+		pterm.Error.WithShowLineNumber(false).Printfln("Error getting kube client for detailed resource info: %v", err)
+		tableData := pterm.TableData{{"Kind", "Name"}}
+		for _, r := range resources {
+			tableData = append(tableData, []string{r.Kind, r.Name})
+		}
+		pterm.DefaultTable.WithHasHeader(true).WithData(tableData).Render()
+		return
+	}
+	details, err := collectReleaseResources(context.Background(), clientset, rel)
+	if err != nil {
+		pterm.Error.WithShowLineNumber(false).Printfln("Error parsing manifest: %v", err)
+		return
+	}
+	renderReleaseResources(rel.Name, details)
+}
+
+// renderReleaseResources prints res as the tables of selm status.
+func renderReleaseResources(releaseName string, res ReleaseResources) {
+	for _, r := range res.Resources {
+		if r.Error != "" {
+			pterm.Warning.Printfln("%s/%s: %s", r.Kind, r.Name, r.Error)
+			continue
+		}
+		pterm.DefaultSection.Printf("%s: %s", r.Kind, r.Name)
+		renderReleaseResource(r)
+		pterm.Println()
+	}
+
+	pterm.DefaultSection.Println("PODS ASSOCIATED WITH THE RELEASE")
+	switch {
+	case res.PodsError != "":
+		pterm.Error.WithShowLineNumber(false).Printfln("Error listing pods for release '%s': %s", releaseName, res.PodsError)
+	case len(res.Pods) == 0:
+		pterm.Warning.Printf("No pods found for release '%s'\n", releaseName)
+	}
+	for _, pod := range res.Pods {
+		pterm.DefaultSection.Printf("Pod: %s", pod.Name)
+		renderReleaseResource(pod)
+		if pod.Error != "" {
+			pterm.Warning.Printf("Error fetching events for pod %s: %s\n", pod.Name, pod.Error)
+		} else if len(pod.Events) > 0 {
+			pterm.Println()
+			pterm.Info.Println("Events:")
+			for _, e := range pod.Events {
+				pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(pterm.TableData{
+					{"LAST SEEN", time.Since(e.LastSeen).Round(time.Second).String() + " ago"},
+					{"TYPE", e.Type},
+					{"REASON", e.Reason},
+					{"MESSAGE", e.Message},
+				}).Render()
+			}
+		}
+		pterm.Println()
+	}
+}
+
+// renderReleaseResource prints the status table of r and the tables of its
+// containers and ports.
+func renderReleaseResource(r ReleaseResource) {
+	fields, ok := resourceStatusFields[r.Kind]
+	if !ok {
+		pterm.Info.Println("No additional details available for this resource type")
+		return
+	}
+	tableData := pterm.TableData{}
+	for _, f := range fields {
+		value := r.Status[f]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC1123)
+		}
+		if value == nil {
+			value = ""
+		}
+		tableData = append(tableData, []string{strings.ToUpper(strings.ReplaceAll(f, "_", " ")), fmt.Sprint(value)})
+	}
+	pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(tableData).Render()
+
+	if len(r.Containers) > 0 {
+		pterm.Println()
+		pterm.Info.Println("Containers:")
+		for _, c := range r.Containers {
+			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(pterm.TableData{
+				{"NAME", c.Name},
+				{"READY", fmt.Sprint(c.Ready)},
+				{"STATE", c.State},
+				{"RESTARTS", fmt.Sprint(c.Restarts)},
+				{"IMAGE", c.Image},
+			}).Render()
+		}
+	}
+	if len(r.Ports) > 0 {
+		pterm.Println()
+		pterm.Info.Println("Ports:")
+		for _, p := range r.Ports {
+			nodePort := ""
+			if p.NodePort > 0 {
+				nodePort = fmt.Sprint(p.NodePort)
+			}
+			pterm.DefaultTable.WithHasHeader(false).WithBoxed(true).WithData(pterm.TableData{
+				{"NAME", p.Name},
+				{"PORT", fmt.Sprint(p.Port)},
+				{"TARGET PORT", p.TargetPort},
+				{"PROTOCOL", p.Protocol},
+				{"NODE PORT", nodePort},
+			}).Render()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+const releaseResourcesManifest = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+`
+
+func TestCollectReleaseResources(t *testing.T) {
+	replicas := int32(2)
+	labels := map[string]string{"app.kubernetes.io/instance": "web"}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 1, UpdatedReplicas: 2, AvailableReplicas: 1},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.0.0.7",
+				Ports: []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "apps", Labels: labels},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true, RestartCount: 3, Image: "web:v2"}},
+			},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "web-1.1", Namespace: "apps"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-1"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Started",
+		},
+	)
+	rel := &release.Release{Name: "web", Namespace: "apps", Manifest: releaseResourcesManifest}
+
+	got, err := collectReleaseResources(context.Background(), clientset, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Resources) != 4 {
+		t.Fatalf("resources = %+v, want 4", got.Resources)
+	}
+	if dep := got.Resources[0]; dep.Status["replicas"] != int32(2) || dep.Status["ready"] != int32(1) {
+		t.Errorf("Deployment status = %v", dep.Status)
+	}
+	if svc := got.Resources[1]; svc.Status["cluster_ip"] != "10.0.0.7" || len(svc.Ports) != 1 || svc.Ports[0].TargetPort != "8080" {
+		t.Errorf("Service = %+v", svc)
+	}
+	if cm := got.Resources[2]; cm.Error == "" {
+		t.Errorf("a ConfigMap that doesn't exist = %+v, want an error", cm)
+	}
+	if ing := got.Resources[3]; ing.Status != nil || ing.Error != "" {
+		t.Errorf("a kind without details = %+v", ing)
+	}
+	if len(got.Pods) != 1 || got.Pods[0].Status["ready"] != true || got.Pods[0].Containers[0].Restarts != 3 ||
+		len(got.Pods[0].Events) != 1 || got.Pods[0].Events[0].Reason != "Started" {
+		t.Errorf("pods = %+v", got.Pods)
+	}
+}
+
+func TestReleaseStatusDocument(t *testing.T) {
+	status := ReleaseStatus{
+		ReleaseSummary:    ReleaseSummary{Name: "web", Namespace: "apps", Revision: 4, Status: "deployed", Chart: "web-1.2.0"},
+		AllResourcesReady: true,
+		NotReadyResources: []string{},
+		ReleaseResources: ReleaseResources{
+			Resources: []ReleaseResource{{Kind: "Secret", Name: "web", Status: map[string]any{"type": "Opaque", "data": 2}}},
+			Pods:      []ReleaseResource{},
+		},
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"name", "revision", "chart", "all_resources_ready", "not_ready_resources", "resources", "pods"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("status document has no %q key: %s", key, data)
+		}
+	}
+
+	// YAML uses the same keys as JSON.
+	out, err := yaml.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "all_resources_ready: true") || !strings.Contains(string(out), "app_version:") {
+		t.Errorf("status YAML =\n%s", out)
+	}
+}
@@ -4,23 +4,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/repo"
 )
 
 func Repo_Add(args []string,
 	username, password, certFile, keyFile, caFile, helmConfigDir string,
+	repoAuth RepoAuth,
 	useAI bool,
 ) error {
 	repoName := args[0]
 	repoURL := args[1]
 
+	if err := repoAuth.Validate(); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
 	pterm.Info.Printfln("Adding repo %s...", repoName)
 
 	// CRITICAL: Get settings the SAME way Helm CLI does
@@ -64,7 +70,7 @@ func Repo_Add(args []string,
 	}
 
 	// Create and test the repository
-	if err := createAndTestRepository(repoFile, repoName, repoURL, username, password, certFile, keyFile, caFile, settings); err != nil {
+	if err := createAndTestRepository(repoFile, repoName, repoURL, username, password, certFile, keyFile, caFile, repoAuth, settings); err != nil {
 		ai.AIExplainError(useAI, err.Error())
 		return err
 	}
@@ -107,7 +113,32 @@ func loadOrCreateRepoFile(configPath string) (*repo.File, error) {
 	return repoFile, nil
 }
 
-func createAndTestRepository(repoFile *repo.File, repoName, repoURL, username, password, certFile, keyFile, caFile string, settings *helmCLI.EnvSettings) error {
+// ListRepoNames returns the names of the chart repositories configured in
+// the Helm repositories file (under helmConfigDir when set, otherwise the
+// same default location the Helm CLI uses), for use in shell completion.
+// Unlike loadOrCreateRepoFile it never prints anything; a missing
+// repositories file simply yields no names.
+func ListRepoNames(helmConfigDir string) ([]string, error) {
+	settings := getHelmSettings(helmConfigDir)
+
+	if _, err := os.Stat(settings.RepositoryConfig); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	repoFile, err := repo.LoadFile(settings.RepositoryConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(repoFile.Repositories))
+	for _, entry := range repoFile.Repositories {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func createAndTestRepository(repoFile *repo.File, repoName, repoURL, username, password, certFile, keyFile, caFile string, repoAuth RepoAuth, settings *helmCLI.EnvSettings) error {
 	// Create repository entry
 	entry := &repo.Entry{
 		Name:     repoName,
@@ -119,8 +150,13 @@ func createAndTestRepository(repoFile *repo.File, repoName, repoURL, username, p
 		CAFile:   caFile,
 	}
 
-	// Create chart repository
-	chartRepo, err := repo.NewChartRepository(entry, getter.All(settings))
+	// Create chart repository, authenticated as later commands will be
+	providers, authedEntry, err := repoProviders(settings, entry, repoAuth)
+	if err != nil {
+		pterm.Error.Printfln("✗ Failed to set up repository auth: %v", err)
+		return err
+	}
+	chartRepo, err := repo.NewChartRepository(authedEntry, providers)
 	if err != nil {
 		pterm.Error.Printfln("✗ Failed to create chart repository: %v", err)
 		return fmt.Errorf("failed to create chart repository: %v", err)
@@ -144,6 +180,10 @@ func createAndTestRepository(repoFile *repo.File, repoName, repoURL, username, p
 		pterm.Error.Printfln("✗ Failed to write repositories file: %v", err)
 		return fmt.Errorf("failed to write repositories file: %v", err)
 	}
+	if err := saveRepoAuth(settings.RepositoryConfig, repoName, repoAuth); err != nil {
+		pterm.Error.Printfln("✗ Failed to write %s: %v", repoAuthFile, err)
+		return fmt.Errorf("failed to write %s: %v", repoAuthFile, err)
+	}
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 
@@ -0,0 +1,369 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+	"sigs.k8s.io/yaml"
+)
+
+// repoAuthFile holds the RepoAuth of each chart repository, next to the
+// repositories.yaml Helm keeps basic auth and client certificates in. Helm
+// ignores it, so repositories that need it only work through smurf.
+const repoAuthFile = "repository-auth.yaml"
+
+// RepoAuth is how smurf authenticates to a chart repository beyond what
+// repositories.yaml can express. At most one token source may be set.
+type RepoAuth struct {
+	Token            string `json:"token,omitempty"`            // static bearer token
+	TokenEnv         string `json:"tokenEnv,omitempty"`         // environment variable holding the bearer token
+	TokenCommand     string `json:"tokenCommand,omitempty"`     // shell command printing the bearer token, rerun when the repository answers 401
+	CredentialHelper bool   `json:"credentialHelper,omitempty"` // resolve the login like smurf's registry pushes: docker config and its credential helpers
+}
+
+// IsZero reports whether a uses nothing beyond repositories.yaml.
+func (a RepoAuth) IsZero() bool {
+	return a == RepoAuth{}
+}
+
+// Validate rejects more than one token source.
+func (a RepoAuth) Validate() error {
+	sources := 0
+	for _, set := range []bool{a.Token != "", a.TokenEnv != "", a.TokenCommand != "", a.CredentialHelper} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("use only one of --token, --token-env, --token-command and --credential-helper")
+	}
+	return nil
+}
+
+func repoAuthPath(repositoryConfig string) string {
+	return filepath.Join(filepath.Dir(repositoryConfig), repoAuthFile)
+}
+
+// loadRepoAuths reads the RepoAuth of every repository configured in
+// repositoryConfig. A missing file means none has any.
+func loadRepoAuths(repositoryConfig string) (map[string]RepoAuth, error) {
+	data, err := os.ReadFile(repoAuthPath(repositoryConfig))
+	if os.IsNotExist(err) {
+		return map[string]RepoAuth{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	auths := map[string]RepoAuth{}
+	if err := yaml.Unmarshal(data, &auths); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", repoAuthPath(repositoryConfig), err)
+	}
+	return auths, nil
+}
+
+// saveRepoAuth records a for the repository name, or forgets the
+// repository's entry when a is zero. The file may hold tokens, so it is
+// written 0600.
+func saveRepoAuth(repositoryConfig, name string, a RepoAuth) error {
+	auths, err := loadRepoAuths(repositoryConfig)
+	if err != nil {
+		return err
+	}
+	if a.IsZero() {
+		if _, ok := auths[name]; !ok {
+			return nil
+		}
+		delete(auths, name)
+	} else {
+		auths[name] = a
+	}
+	data, err := yaml.Marshal(auths)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(repoAuthPath(repositoryConfig), data, 0600)
+}
+
+// repoProviders returns the getters for the chart repository entry: Helm's
+// own when a needs nothing extra, otherwise Helm's with http and https
+// replaced by a getter that authenticates with a's bearer token. With
+// --credential-helper and no token, the resolved username and password are
+// set on a copy of entry, which is returned in place of entry so that
+// credentials never end up in repositories.yaml.
+func repoProviders(settings *helmCLI.EnvSettings, entry *repo.Entry, a RepoAuth) (getter.Providers, *repo.Entry, error) {
+	providers := getter.All(settings)
+	tokens := repoTokenSource(entry, a)
+	if a.CredentialHelper && entry.Username == "" {
+		cred, err := resolveRepoCredential(entry.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cred.AccessToken != "" {
+			tokens = &repoToken{token: cred.AccessToken, fetch: func() (string, error) {
+				cred, err := resolveRepoCredential(entry.URL)
+				return cred.AccessToken, err
+			}}
+		} else {
+			resolved := *entry
+			resolved.Username, resolved.Password = cred.Username, cred.Password
+			entry = &resolved
+		}
+	}
+	if tokens == nil {
+		return providers, entry, nil
+	}
+
+	client, err := repoHTTPClient(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid repository URL %s: %w", entry.URL, err)
+	}
+	g := &tokenGetter{client: client, host: u.Host, passAll: entry.PassCredentialsAll, tokens: tokens}
+	authed := getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New:     func(...getter.Option) (getter.Getter, error) { return g, nil },
+	}}
+	for _, p := range providers {
+		if !p.Provides("http") && !p.Provides("https") {
+			authed = append(authed, p)
+		}
+	}
+	return authed, entry, nil
+}
+
+// repoTokenSource returns where a's bearer token comes from, or nil when
+// it names none.
+func repoTokenSource(entry *repo.Entry, a RepoAuth) *repoToken {
+	switch {
+	case a.Token != "":
+		return &repoToken{token: a.Token}
+	case a.TokenEnv != "":
+		return &repoToken{fetch: func() (string, error) {
+			if token := os.Getenv(a.TokenEnv); token != "" {
+				return token, nil
+			}
+			return "", fmt.Errorf("%s is not set; it holds the token for repository %s", a.TokenEnv, entry.Name)
+		}}
+	case a.TokenCommand != "":
+		return &repoToken{fetch: func() (string, error) { return runTokenCommand(a.TokenCommand) }}
+	}
+	return nil
+}
+
+// resolveRepoCredential looks up the login for the host of repoURL through
+// the resolver smurf's registry pushes use.
+func resolveRepoCredential(repoURL string) (auth.Credential, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return auth.EmptyCredential, fmt.Errorf("invalid repository URL %s: %w", repoURL, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cred, err := docker.RegistryCredential(ctx, u.Host)
+	if err != nil {
+		return auth.EmptyCredential, fmt.Errorf("failed to resolve credentials for %s: %w", u.Host, err)
+	}
+	if cred == auth.EmptyCredential {
+		return auth.EmptyCredential, fmt.Errorf("no credentials found for %s; log in with docker login %s or configure a credential helper for it", u.Host, u.Host)
+	}
+	return cred, nil
+}
+
+// runTokenCommand runs command with sh and returns what it printed, trimmed.
+func runTokenCommand(command string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token command %q failed: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("token command %q printed nothing", command)
+	}
+	return token, nil
+}
+
+// repoToken is a bearer token that is fetched on first use and fetched
+// again when the repository rejects it. A token without fetch is static.
+type repoToken struct {
+	mu    sync.Mutex
+	token string
+	fetch func() (string, error)
+}
+
+func (t *repoToken) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == "" && t.fetch != nil {
+		token, err := t.fetch()
+		if err != nil {
+			return "", err
+		}
+		t.token = token
+	}
+	return t.token, nil
+}
+
+// refresh fetches a new token in place of rejected, and reports whether
+// there is one worth retrying with.
+func (t *repoToken) refresh(rejected string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fetch == nil {
+		return false, nil
+	}
+	if t.token != rejected {
+		// Another request already refreshed it.
+		return true, nil
+	}
+	token, err := t.fetch()
+	if err != nil {
+		return false, err
+	}
+	t.token = token
+	return token != rejected, nil
+}
+
+// tokenGetter is an http/https getter.Getter that sends a bearer token to
+// the repository's host. Like Helm's getter, it sends it to other hosts
+// (where an index may point chart downloads) only with pass-credentials.
+type tokenGetter struct {
+	client  *http.Client
+	host    string
+	passAll bool
+	tokens  *repoToken
+}
+
+func (g *tokenGetter) Get(href string, _ ...getter.Option) (*bytes.Buffer, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	send := g.passAll || u.Host == g.host
+
+	token := ""
+	if send {
+		if token, err = g.tokens.get(); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := g.do(href, token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && send {
+		resp.Body.Close()
+		retry, err := g.tokens.refresh(token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh the token for %s: %w", g.host, err)
+		}
+		if !retry {
+			return nil, fmt.Errorf("failed to fetch %s : %s", href, resp.Status)
+		}
+		if token, err = g.tokens.get(); err != nil {
+			return nil, err
+		}
+		if resp, err = g.do(href, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s : %s", href, resp.Status)
+	}
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, resp.Body)
+	return buf, err
+}
+
+func (g *tokenGetter) do(href, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "smurf")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return g.client.Do(req)
+}
+
+// repoHTTPClient returns an HTTP client with the TLS settings of entry: its
+// CA bundle, its client certificate and insecure-skip-tls-verify.
+func repoHTTPClient(entry *repo.Entry) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: entry.InsecureSkipTLSverify}
+	if entry.CertFile != "" && entry.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(entry.CertFile, entry.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if entry.CAFile != "" {
+		pem, err := os.ReadFile(entry.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", entry.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 2 * time.Minute}, nil
+}
+
+// registryAuthorizer makes an OCI registry client resolve logins like
+// smurf's image pushes: ECR and GCP tokens are minted when the registry asks
+// for credentials, so an expired one is replaced rather than failing the
+// pull. Logins saved in credFile (helm registry login) come next.
+func registryAuthorizer(credFile string) registry.ClientOption {
+	store, err := credentials.NewStore(credFile, credentials.StoreOptions{DetectDefaultNativeStore: true})
+	if err != nil {
+		store = nil
+	}
+	return registry.ClientOptAuthorizer(auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+		Credential: func(ctx context.Context, host string) (auth.Credential, error) {
+			cred, err := docker.RegistryCredential(ctx, host)
+			if err == nil && cred != auth.EmptyCredential {
+				return cred, nil
+			}
+			if store != nil {
+				if saved, serr := store.Get(ctx, host); serr == nil && saved != auth.EmptyCredential {
+					return saved, nil
+				}
+			}
+			return auth.EmptyCredential, err
+		},
+	})
+}
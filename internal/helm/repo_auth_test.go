@@ -0,0 +1,83 @@
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestRepoAuthSaveLoad(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "repositories.yaml")
+	if err := saveRepoAuth(config, "internal", RepoAuth{TokenEnv: "CHARTS_TOKEN"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(repoAuthPath(config)); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("stat %s = %v, %v; want mode 0600", repoAuthFile, info, err)
+	}
+	auths, err := loadRepoAuths(config)
+	if err != nil || auths["internal"].TokenEnv != "CHARTS_TOKEN" {
+		t.Fatalf("loadRepoAuths = %v, %v", auths, err)
+	}
+
+	if err := saveRepoAuth(config, "internal", RepoAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	if auths, _ := loadRepoAuths(config); len(auths) != 0 {
+		t.Errorf("auths after clearing = %v, want none", auths)
+	}
+
+	if err := (RepoAuth{Token: "t", CredentialHelper: true}).Validate(); err == nil {
+		t.Error("Validate accepted two token sources")
+	}
+}
+
+func TestRepoProvidersRefreshesRejectedToken(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("apiVersion: v1\nentries: {}\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("stale\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entry := &repo.Entry{Name: "internal", URL: srv.URL}
+	a := RepoAuth{TokenCommand: "cat " + tokenFile + "; echo fresh > " + tokenFile}
+	providers, entry, err := repoProviders(helmCLI.New(), entry, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := providers.ByScheme("http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get(entry.URL + "/index.yaml"); err != nil {
+		t.Fatalf("Get = %v, want the refreshed token to be accepted", err)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer stale" || seen[1] != "Bearer fresh" {
+		t.Errorf("Authorization headers = %q, want stale then fresh", seen)
+	}
+
+	// Hosts other than the repository's never see the token.
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("token sent to %s", r.Host)
+		}
+	}))
+	defer other.Close()
+	if _, err := g.Get(other.URL + "/chart.tgz"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	helmCLI "helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/repo"
 )
@@ -44,6 +43,13 @@ func Repo_Update(args []string, helmConfigDir string, useAI bool) error {
 		return fmt.Errorf("failed to load repository config: %v", err)
 	}
 
+	auths, err := loadRepoAuths(settings.RepositoryConfig)
+	if err != nil {
+		pterm.Error.Printfln("✗ Failed to load repository auth: %v", err)
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to load repository auth: %v", err)
+	}
+
 	var repos []*repo.ChartRepository
 	updateAll := len(args) == 0
 
@@ -53,7 +59,13 @@ func Repo_Update(args []string, helmConfigDir string, useAI bool) error {
 			continue
 		}
 
-		r, err := repo.NewChartRepository(cfg, getter.All(settings))
+		providers, entry, err := repoProviders(settings, cfg, auths[cfg.Name])
+		if err != nil {
+			pterm.Warning.Printfln("⚠ Failed to set up auth for %s: %v", cfg.Name, err)
+			ai.AIExplainError(useAI, err.Error())
+			continue
+		}
+		r, err := repo.NewChartRepository(entry, providers)
 		if err != nil {
 			pterm.Warning.Printfln("⚠ Failed to create chart repository for %s: %v", cfg.Name, err)
 			ai.AIExplainError(useAI, err.Error())
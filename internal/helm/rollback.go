@@ -9,7 +9,6 @@ import (
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/cli"
 )
 
 // HelmRollback performs a rollback of a specified Helm release to a given revision.
@@ -28,7 +27,7 @@ func HelmRollback(releaseName string, revision int, opts RollbackOptions, histor
 
 	pterm.Success.Printfln("Starting Helm Rollback for release: %s to revision %d \n", releaseName, revision)
 
-	settings := cli.New()
+	settings := newSettings("")
 	settings.Debug = opts.Debug
 
 	actionConfig := new(action.Configuration)
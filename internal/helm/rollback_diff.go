@@ -0,0 +1,222 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// RollbackPreview is what rolling a release back to a revision changes: the
+// chart, the user-supplied values and each rendered resource.
+type RollbackPreview struct {
+	Release         string
+	CurrentRevision int
+	TargetRevision  int
+	CurrentChart    string
+	TargetChart     string
+	ValuesDiff      string
+	Resources       []ResourceDiff
+}
+
+// ResourceDiff is the change to one resource of the release manifest.
+type ResourceDiff struct {
+	Resource string `json:"resource"` // Kind/name
+	Change   string `json:"change"`   // added, removed or changed
+	Diff     string `json:"diff"`
+}
+
+// Empty reports whether the rollback changes neither values nor resources.
+func (p *RollbackPreview) Empty() bool {
+	return p.ValuesDiff == "" && len(p.Resources) == 0
+}
+
+// PreviewRollback compares the deployed revision of releaseName with
+// revision, without changing anything.
+func PreviewRollback(releaseName string, revision int, namespace string, useAI bool) (*RollbackPreview, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("helm init failed: %w", err)
+	}
+
+	current, err := cfg.Releases.Last(releaseName)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("failed to get release %s: %w", releaseName, err)
+	}
+	target, err := cfg.Releases.Get(releaseName, revision)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return nil, fmt.Errorf("revision %d of release %s not found: %w", revision, releaseName, err)
+	}
+	return compareRevisions(current, target)
+}
+
+func compareRevisions(current, target *release.Release) (*RollbackPreview, error) {
+	preview := &RollbackPreview{
+		Release:         current.Name,
+		CurrentRevision: current.Version,
+		TargetRevision:  target.Version,
+		CurrentChart:    chartRef(current),
+		TargetChart:     chartRef(target),
+	}
+
+	registerSecretValues(current.Config)
+	registerSecretValues(target.Config)
+	currentConfig, targetConfig := redactValuesPair(current.Config, target.Config)
+	currentValues, err := yaml.Marshal(currentConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values of revision %d: %w", current.Version, err)
+	}
+	targetValues, err := yaml.Marshal(targetConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values of revision %d: %w", target.Version, err)
+	}
+	preview.ValuesDiff = unifiedDiff(string(currentValues), string(targetValues),
+		fmt.Sprintf("values (revision %d)", current.Version), fmt.Sprintf("values (revision %d)", target.Version))
+
+	preview.Resources = diffManifests(current.Manifest, target.Manifest,
+		fmt.Sprintf("revision %d", current.Version), fmt.Sprintf("revision %d", target.Version))
+	return preview, nil
+}
+
+// diffManifests compares two release manifests resource by resource and
+// returns the resources that are added, removed or changed, sorted by
+// Kind/name, with their secrets redacted.
+func diffManifests(before, after, fromName, toName string) []ResourceDiff {
+	from, to := manifestResources(before), manifestResources(after)
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := []ResourceDiff{}
+	for _, name := range names {
+		a, inBefore := from[name]
+		b, inAfter := to[name]
+		d := ResourceDiff{Resource: name, Change: "changed"}
+		switch {
+		case !inBefore:
+			d.Change = "added"
+		case !inAfter:
+			d.Change = "removed"
+		case a == b:
+			continue
+		}
+		d.Diff = redactManifestDiff(unifiedDiff(a, b, fromName, toName))
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+func chartRef(rel *release.Release) string {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return ""
+	}
+	return rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+}
+
+// manifestResources splits a release manifest into its resources, keyed by
+// Kind/name.
+func manifestResources(manifest string) map[string]string {
+	resources := map[string]string{}
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var head struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &head); err != nil || head.Kind == "" {
+			continue
+		}
+		resources[head.Kind+"/"+head.Metadata.Name] = strings.TrimSpace(doc) + "\n"
+	}
+	return resources
+}
+
+func unifiedDiff(a, b, fromName, toName string) string {
+	if a == b {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	return diff
+}
+
+// PrintRollbackPreview prints the changes of a rollback as colored unified
+// diffs, one per changed resource.
+func PrintRollbackPreview(p *RollbackPreview) {
+	pterm.DefaultSection.Printfln("Rollback of %s: revision %d → %d", p.Release, p.CurrentRevision, p.TargetRevision)
+	if p.CurrentChart != p.TargetChart {
+		pterm.Info.Printfln("Chart: %s → %s", p.CurrentChart, p.TargetChart)
+	}
+	if p.Empty() {
+		pterm.Info.Println("The target revision has the same values and resources as the current one.")
+		return
+	}
+
+	if p.ValuesDiff != "" {
+		pterm.Println(pterm.Bold.Sprint("Values"))
+		printColoredDiff(p.ValuesDiff)
+	}
+	for _, r := range p.Resources {
+		pterm.Println(pterm.Bold.Sprintf("%s (%s)", r.Resource, r.Change))
+		printColoredDiff(r.Diff)
+	}
+
+	counts := map[string]int{}
+	for _, r := range p.Resources {
+		counts[r.Change]++
+	}
+	pterm.Info.Printfln("Resources: %d changed, %d added, %d removed", counts["changed"], counts["added"], counts["removed"])
+}
+
+// PrintFileDiff prints the changes from before to after, the old and new
+// content of the file name, as a colored unified diff. It reports whether
+// there were any.
+func PrintFileDiff(name, before, after string) bool {
+	diff := unifiedDiff(before, after, name, name)
+	if diff == "" {
+		return false
+	}
+	printColoredDiff(diff)
+	return true
+}
+
+func printColoredDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			pterm.Println(pterm.Gray(line))
+		case strings.HasPrefix(line, "+"):
+			pterm.Println(pterm.Green(line))
+		case strings.HasPrefix(line, "-"):
+			pterm.Println(pterm.Red(line))
+		case strings.HasPrefix(line, "@@"):
+			pterm.Println(pterm.Cyan(line))
+		default:
+			pterm.Println(line)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func testRevision(version int, chartVersion string, values map[string]interface{}, manifest string) *release.Release {
+	return &release.Release{
+		Name:     "web",
+		Version:  version,
+		Config:   values,
+		Manifest: manifest,
+		Chart:    &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: chartVersion}},
+	}
+}
+
+func TestCompareRevisions(t *testing.T) {
+	current := testRevision(5, "1.3.0", map[string]interface{}{"replicaCount": 3},
+		`---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+---
+# Source: web/templates/hpa.yaml
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+`)
+	target := testRevision(3, "1.2.0", map[string]interface{}{"replicaCount": 2},
+		`---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+`)
+
+	p, err := compareRevisions(current, target)
+	if err != nil {
+		t.Fatalf("compareRevisions: %v", err)
+	}
+	if p.CurrentChart != "web-1.3.0" || p.TargetChart != "web-1.2.0" {
+		t.Errorf("charts = %s → %s, want web-1.3.0 → web-1.2.0", p.CurrentChart, p.TargetChart)
+	}
+	if !strings.Contains(p.ValuesDiff, "-replicaCount: 3") || !strings.Contains(p.ValuesDiff, "+replicaCount: 2") {
+		t.Errorf("values diff missing replicaCount change:\n%s", p.ValuesDiff)
+	}
+
+	want := map[string]string{
+		"ConfigMap/web-config":        "added",
+		"Deployment/web":              "changed",
+		"HorizontalPodAutoscaler/web": "removed",
+	}
+	if len(p.Resources) != len(want) {
+		t.Fatalf("resources = %+v, want %v", p.Resources, want)
+	}
+	for _, r := range p.Resources {
+		if want[r.Resource] != r.Change {
+			t.Errorf("%s: change = %q, want %q", r.Resource, r.Change, want[r.Resource])
+		}
+	}
+	if d := p.Resources[1].Diff; !strings.Contains(d, "-  replicas: 3") || !strings.Contains(d, "+  replicas: 2") {
+		t.Errorf("Deployment diff missing replicas change:\n%s", d)
+	}
+}
+
+func TestCompareRevisionsUnchanged(t *testing.T) {
+	manifest := "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"
+	values := map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}}
+
+	p, err := compareRevisions(testRevision(2, "1.0.0", values, manifest), testRevision(1, "1.0.0", values, manifest))
+	if err != nil {
+		t.Fatalf("compareRevisions: %v", err)
+	}
+	if !p.Empty() {
+		t.Errorf("preview of identical revisions is not empty: %+v", p)
+	}
+}
@@ -0,0 +1,214 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// revisionAnnotation is the rollout revision the deployment controller
+// stamps on a Deployment and on the ReplicaSet of each revision.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// rolloutProgress is where the rollout of a Deployment stands against the
+// budget of its rollingUpdate strategy.
+type rolloutProgress struct {
+	Name     string
+	Strategy appsv1.DeploymentStrategyType
+	// Desired is spec.replicas; Total counts the pods of every ReplicaSet
+	// of the Deployment, old and new.
+	Desired, Updated, Available, Total int32
+	// MaxUnavailable and MaxSurge are the strategy's limits resolved to pod
+	// counts the way the deployment controller does.
+	MaxUnavailable, MaxSurge int32
+	// Stalled is set once the Progressing condition reports
+	// ProgressDeadlineExceeded.
+	Stalled      bool
+	StallMessage string
+}
+
+// deploymentRolloutProgress reads the rollout progress of d. Percentages
+// round as the deployment controller rounds them: maxSurge up,
+// maxUnavailable down, and both at least one pod between them.
+func deploymentRolloutProgress(d *appsv1.Deployment) rolloutProgress {
+	p := rolloutProgress{
+		Name:      d.Name,
+		Strategy:  d.Spec.Strategy.Type,
+		Desired:   replicaCount(d.Spec.Replicas),
+		Updated:   d.Status.UpdatedReplicas,
+		Available: d.Status.AvailableReplicas,
+		Total:     d.Status.Replicas,
+	}
+	if p.Strategy == "" {
+		p.Strategy = appsv1.RollingUpdateDeploymentStrategyType
+	}
+
+	if p.Strategy == appsv1.RecreateDeploymentStrategyType {
+		p.MaxUnavailable = p.Desired
+	} else {
+		defaultBudget := intstr.FromString("25%")
+		maxSurge, maxUnavailable := &defaultBudget, &defaultBudget
+		if ru := d.Spec.Strategy.RollingUpdate; ru != nil {
+			if ru.MaxSurge != nil {
+				maxSurge = ru.MaxSurge
+			}
+			if ru.MaxUnavailable != nil {
+				maxUnavailable = ru.MaxUnavailable
+			}
+		}
+		surge, _ := intstr.GetScaledValueFromIntOrPercent(maxSurge, int(p.Desired), true)
+		unavailable, _ := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, int(p.Desired), false)
+		if surge == 0 && unavailable == 0 {
+			unavailable = 1
+		}
+		p.MaxSurge, p.MaxUnavailable = int32(surge), int32(unavailable)
+	}
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			p.Stalled, p.StallMessage = true, c.Message
+		}
+	}
+	return p
+}
+
+// unavailable is how many of the desired pods are not available.
+func (p rolloutProgress) unavailable() int32 {
+	if p.Available >= p.Desired {
+		return 0
+	}
+	return p.Desired - p.Available
+}
+
+// done reports whether every desired pod runs the new revision and is
+// available, with no old pods left.
+func (p rolloutProgress) done() bool {
+	return p.Updated == p.Desired && p.Available == p.Desired && p.Total == p.Desired
+}
+
+// violations lists how the rollout exceeds its budget: more pods
+// unavailable than maxUnavailable allows, or more pods than maxSurge
+// allows. A Recreate rollout has no budget.
+func (p rolloutProgress) violations() []string {
+	if p.Strategy == appsv1.RecreateDeploymentStrategyType {
+		return nil
+	}
+	var out []string
+	if u := p.unavailable(); u > p.MaxUnavailable {
+		out = append(out, fmt.Sprintf("%d unavailable exceeds maxUnavailable %d", u, p.MaxUnavailable))
+	}
+	if limit := p.Desired + p.MaxSurge; p.Total > limit {
+		out = append(out, fmt.Sprintf("%d pods exceed replicas+maxSurge %d", p.Total, limit))
+	}
+	return out
+}
+
+// String renders p as one progress line, e.g.
+// "web: 3/5 updated, 4/5 available (1 unavailable, maxUnavailable 1), 6 pods (max 6)".
+func (p rolloutProgress) String() string {
+	if p.Strategy == appsv1.RecreateDeploymentStrategyType {
+		return fmt.Sprintf("%s: %d/%d updated, %d/%d available (Recreate)", p.Name, p.Updated, p.Desired, p.Available, p.Desired)
+	}
+	return fmt.Sprintf("%s: %d/%d updated, %d/%d available (%d unavailable, maxUnavailable %d), %d pods (max %d)",
+		p.Name, p.Updated, p.Desired, p.Available, p.Desired, p.unavailable(), p.MaxUnavailable, p.Total, p.Desired+p.MaxSurge)
+}
+
+// listReleaseDeployments returns the Deployments of release in namespace.
+func listReleaseDeployments(ctx context.Context, clientset kubernetes.Interface, namespace, releaseName string) ([]appsv1.Deployment, error) {
+	list, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf(appKubernets, releaseName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// newReplicaSet returns the ReplicaSet of the current revision of d, the
+// one a stalled rollout is stuck creating pods for, or nil.
+func newReplicaSet(ctx context.Context, clientset kubernetes.Interface, d *appsv1.Deployment) (*appsv1.ReplicaSet, error) {
+	revision := d.Annotations[revisionAnnotation]
+	if revision == "" {
+		return nil, nil
+	}
+	list, err := clientset.AppsV1().ReplicaSets(d.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		rs := &list.Items[i]
+		if rs.Annotations[revisionAnnotation] != revision {
+			continue
+		}
+		for _, owner := range rs.OwnerReferences {
+			if owner.Kind == "Deployment" && (owner.UID == d.UID || owner.Name == d.Name) {
+				return rs, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// replicaSetEvents returns the events of the ReplicaSet name, oldest first.
+func replicaSetEvents(ctx context.Context, clientset kubernetes.Interface, namespace, name string) ([]corev1.Event, error) {
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=ReplicaSet,involvedObject.name=%s", name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := list.Items
+	sort.SliceStable(events, func(i, j int) bool { return lastSeen(events[i]).Before(lastSeen(events[j])) })
+	return events, nil
+}
+
+// stalledRollout is a stalled rollout with the events of the ReplicaSet it
+// could not bring up, captured while they exist: an atomic rollback
+// scales that ReplicaSet away.
+type stalledRollout struct {
+	progress   rolloutProgress
+	replicaSet string
+	events     []corev1.Event
+}
+
+// captureStalledRollout reads the events of the new ReplicaSet of d, whose
+// rollout p reports stalled.
+func captureStalledRollout(ctx context.Context, clientset kubernetes.Interface, d *appsv1.Deployment, p rolloutProgress) stalledRollout {
+	s := stalledRollout{progress: p}
+	rs, err := newReplicaSet(ctx, clientset, d)
+	if err != nil || rs == nil {
+		return s
+	}
+	s.replicaSet = rs.Name
+	s.events, _ = replicaSetEvents(ctx, clientset, d.Namespace, rs.Name)
+	return s
+}
+
+// printStalledRollout prints why a rollout stalled and the events of the
+// ReplicaSet it could not bring up, which usually name the cause: quota
+// exceeded, admission webhooks, unschedulable pods.
+func printStalledRollout(s stalledRollout) {
+	fmt.Printf("    Stalled   : %s\n", s.progress.StallMessage)
+	if s.replicaSet == "" {
+		return
+	}
+	if len(s.events) == 0 {
+		fmt.Printf("    ReplicaSet %s has no events\n", s.replicaSet)
+		return
+	}
+	fmt.Printf("    Events of ReplicaSet %s:\n", s.replicaSet)
+	for _, e := range s.events {
+		prefix := "      ℹ"
+		if e.Type == corev1.EventTypeWarning {
+			prefix = "      ⚠"
+		}
+		fmt.Printf("%s  [%s] %s\n", prefix, e.Reason, strings.TrimSpace(e.Message))
+	}
+}
@@ -0,0 +1,100 @@
+package helm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func rollingDeployment(replicas int32, maxSurge, maxUnavailable *intstr.IntOrString, status appsv1.DeploymentStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", UID: "dep-uid",
+			Annotations: map[string]string{revisionAnnotation: "3"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: appsv1.DeploymentStrategy{
+				Type:          appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: maxSurge, MaxUnavailable: maxUnavailable},
+			},
+		},
+		Status: status,
+	}
+}
+
+func TestDeploymentRolloutProgress(t *testing.T) {
+	one := intstr.FromInt(1)
+	zero := intstr.FromInt(0)
+	third := intstr.FromString("30%")
+
+	// 25% of 5 is 1.25: maxSurge rounds up to 2, maxUnavailable down to 1.
+	p := deploymentRolloutProgress(rollingDeployment(5, nil, nil, appsv1.DeploymentStatus{UpdatedReplicas: 3, AvailableReplicas: 4, Replicas: 7}))
+	if p.MaxSurge != 2 || p.MaxUnavailable != 1 {
+		t.Errorf("default budget of 5 replicas = surge %d, unavailable %d; want 2, 1", p.MaxSurge, p.MaxUnavailable)
+	}
+	if v := p.violations(); len(v) != 0 {
+		t.Errorf("violations within budget = %v", v)
+	}
+	if want := "web: 3/5 updated, 4/5 available (1 unavailable, maxUnavailable 1), 7 pods (max 7)"; p.String() != want {
+		t.Errorf("String() = %q, want %q", p.String(), want)
+	}
+
+	p = deploymentRolloutProgress(rollingDeployment(10, &one, &third, appsv1.DeploymentStatus{UpdatedReplicas: 2, AvailableReplicas: 6, Replicas: 12}))
+	v := p.violations()
+	if len(v) != 2 || v[0] != "4 unavailable exceeds maxUnavailable 3" || v[1] != "12 pods exceed replicas+maxSurge 11" {
+		t.Errorf("violations = %v", v)
+	}
+
+	// Both zero is not a valid budget; the controller allows one unavailable.
+	p = deploymentRolloutProgress(rollingDeployment(2, &zero, &zero, appsv1.DeploymentStatus{}))
+	if p.MaxUnavailable != 1 || p.MaxSurge != 0 {
+		t.Errorf("zero budget = surge %d, unavailable %d; want 0, 1", p.MaxSurge, p.MaxUnavailable)
+	}
+
+	d := rollingDeployment(3, nil, nil, appsv1.DeploymentStatus{UpdatedReplicas: 3, AvailableReplicas: 0, Replicas: 3})
+	d.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	if p = deploymentRolloutProgress(d); p.violations() != nil || !strings.HasSuffix(p.String(), "(Recreate)") {
+		t.Errorf("Recreate rollout = %q, violations %v", p.String(), p.violations())
+	}
+}
+
+func TestCaptureStalledRollout(t *testing.T) {
+	d := rollingDeployment(2, nil, nil, appsv1.DeploymentStatus{
+		UpdatedReplicas: 1, AvailableReplicas: 2, Replicas: 3,
+		Conditions: []appsv1.DeploymentCondition{{
+			Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse,
+			Reason: "ProgressDeadlineExceeded", Message: `ReplicaSet "web-7d9f" has timed out progressing.`,
+		}},
+	})
+	p := deploymentRolloutProgress(d)
+	if !p.Stalled || !strings.Contains(p.StallMessage, "timed out") {
+		t.Fatalf("progress = %+v, want a stalled rollout", p)
+	}
+
+	owner := []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "dep-uid"}}
+	rs := func(name, revision string) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", OwnerReferences: owner,
+			Annotations: map[string]string{revisionAnnotation: revision}}}
+	}
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "web-7d9f.1", Namespace: "apps"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FailedCreate",
+		Message:        `pods "web-7d9f-x" is forbidden: exceeded quota: compute`,
+		LastTimestamp:  metav1.NewTime(at),
+		InvolvedObject: corev1.ObjectReference{Kind: "ReplicaSet", Name: "web-7d9f"},
+	}
+	clientset := fake.NewSimpleClientset(rs("web-5c4b", "2"), rs("web-7d9f", "3"), event)
+
+	s := captureStalledRollout(context.Background(), clientset, d, p)
+	if s.replicaSet != "web-7d9f" || len(s.events) != 1 || s.events[0].Reason != "FailedCreate" {
+		t.Errorf("captureStalledRollout = %+v, want the FailedCreate event of web-7d9f", s)
+	}
+}
@@ -0,0 +1,290 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pterm/pterm"
+)
+
+// ScaffoldOptions selects what `selm create --scaffold` generates next to
+// the chart.
+type ScaffoldOptions struct {
+	Registry  string // hub (default), ecr, ghcr or gcp
+	CI        string // github (default), gitlab or none
+	Image     string // image repository, without a tag; a placeholder for Registry when empty
+	Namespace string // release namespace, default when empty
+}
+
+// scaffoldRegistries are the registries smurf deploy pushes to, keyed by the
+// --registry name, with the sdkr switch that selects each one and a
+// placeholder image repository.
+var scaffoldRegistries = map[string]struct{ flag, image string }{
+	"hub":  {"dockerHub", "my-dockerhub-user/%s"},
+	"ecr":  {"awsECR", "123456789012.dkr.ecr.us-east-1.amazonaws.com/%s"},
+	"ghcr": {"ghcrRepo", "ghcr.io/my-org/%s"},
+	"gcp":  {"gcpRepo", "us-docker.pkg.dev/my-project/my-repo/%s"},
+}
+
+// scaffoldData is what the scaffold templates are rendered with.
+type scaffoldData struct {
+	Name, Namespace, Image, Registry, RegistryFlag string
+}
+
+// CreateScaffold creates a chart like CreateChart and, around it in saveDir,
+// a smurf.yaml that deploys it with `smurf deploy` and a CI workflow that
+// runs the deploy, so a new service has a working pipeline in one command.
+// The chart's values keep image.repository and image.tag, the fields
+// smurf deploy writes the pushed image to. Nothing is written when one of
+// the files exists already.
+func CreateScaffold(chartName, saveDir string, opts ScaffoldOptions) error {
+	files, image, err := scaffoldFiles(chartName, opts)
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{chartName}, sortedKeys(files)...) {
+		path := filepath.Join(saveDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists; delete or rename it before creating the scaffold", path)
+		}
+	}
+
+	if err := CreateChart(chartName, saveDir); err != nil {
+		return err
+	}
+	valuesPath := filepath.Join(saveDir, chartName, "values.yaml")
+	if err := setScaffoldImage(valuesPath, image); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(files) {
+		content := files[name]
+		path := filepath.Join(saveDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		// smurf.yaml can hold credentials, so keep it readable only by the
+		// owner, like smurf init does.
+		mode := os.FileMode(0644)
+		if name == "smurf.yaml" {
+			mode = 0600
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		pterm.Success.Printfln("Created %s", path)
+	}
+
+	pterm.Info.Println("Next steps:")
+	pterm.Info.Printfln("  1. Add a Dockerfile to %s and set the image repository in smurf.yaml", saveDir)
+	if opts.CI != "none" {
+		pterm.Info.Println("  2. Add the registry credentials and KUBECONFIG_B64 (a base64 kubeconfig) as CI secrets")
+		pterm.Info.Println("  3. Push to the default branch, or run `IMAGE_TAG=dev smurf deploy` locally")
+	} else {
+		pterm.Info.Println("  2. Run `IMAGE_TAG=dev smurf deploy`")
+	}
+	return nil
+}
+
+// scaffoldFiles renders the smurf.yaml and CI workflow of a scaffold, keyed
+// by their path relative to the scaffold directory, and returns the image
+// repository the chart deploys.
+func scaffoldFiles(chartName string, opts ScaffoldOptions) (map[string]string, string, error) {
+	if opts.Registry == "" {
+		opts.Registry = "hub"
+	}
+	if opts.CI == "" {
+		opts.CI = "github"
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	registry, ok := scaffoldRegistries[opts.Registry]
+	if !ok {
+		return nil, "", fmt.Errorf("invalid registry %q: must be one of hub, ecr, ghcr, gcp", opts.Registry)
+	}
+	if opts.Image == "" {
+		opts.Image = fmt.Sprintf(registry.image, filepath.Base(chartName))
+	}
+	if strings.Contains(filepath.Base(opts.Image), ":") {
+		return nil, "", fmt.Errorf("invalid image %q: give the repository without a tag; the tag comes from IMAGE_TAG", opts.Image)
+	}
+	data := scaffoldData{
+		Name:         filepath.Base(chartName),
+		Namespace:    opts.Namespace,
+		Image:        opts.Image,
+		Registry:     opts.Registry,
+		RegistryFlag: registry.flag,
+	}
+
+	files := map[string]string{}
+	var err error
+	if files["smurf.yaml"], err = renderScaffold(scaffoldSmurfYAML, data); err != nil {
+		return nil, "", err
+	}
+	switch opts.CI {
+	case "github":
+		files[filepath.Join(".github", "workflows", "deploy.yml")], err = renderScaffold(scaffoldGitHubWorkflow, data)
+	case "gitlab":
+		files[".gitlab-ci.yml"], err = renderScaffold(scaffoldGitLabCI, data)
+	case "none":
+	default:
+		return nil, "", fmt.Errorf("invalid CI %q: must be one of github, gitlab, none", opts.CI)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return files, opts.Image, nil
+}
+
+func renderScaffold(text string, data scaffoldData) (string, error) {
+	tmpl, err := template.New("scaffold").Delims("[[", "]]").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// setScaffoldImage points image.repository of a new chart at image, in
+// place of the nginx placeholder helm create writes.
+func setScaffoldImage(valuesPath, image string) error {
+	values, err := os.ReadFile(valuesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", valuesPath, err)
+	}
+	const placeholder = "image:\n  repository: nginx\n"
+	if !strings.Contains(string(values), placeholder) {
+		pterm.Warning.Printfln("image.repository not found in %s; set it to %s", valuesPath, image)
+		return nil
+	}
+	replacement := "image:\n  # smurf deploy writes the pushed image to image.repository and image.tag.\n  repository: " + image + "\n"
+	values = []byte(strings.Replace(string(values), placeholder, replacement, 1))
+	return os.WriteFile(valuesPath, values, 0644)
+}
+
+const scaffoldSmurfYAML = `# smurf deploy builds the image, pushes it and deploys the chart with it.
+# IMAGE_TAG is set by the CI workflow; export it to deploy from your machine.
+sdkr:
+  imageName: "[[ .Image ]]:${IMAGE_TAG}"
+  [[ .RegistryFlag ]]: true
+[[- if eq .Registry "hub" ]]
+  docker_username: "${DOCKER_USERNAME}"
+  docker_password: "${DOCKER_PASSWORD}"
+[[- else if eq .Registry "ghcr" ]]
+  github_username: "${GITHUB_USERNAME}"
+  github_token: "${GITHUB_TOKEN}"
+[[- end ]]
+selm:
+  deployHelm: true
+  releaseName: "[[ .Name ]]"
+  namespace: "[[ .Namespace ]]"
+  chartName: "./[[ .Name ]]"
+  fileName: "./[[ .Name ]]/values.yaml"
+timeouts:
+  build: 1500
+  push: 600
+  helmWait: 600
+  readiness: 300
+`
+
+const scaffoldGitHubWorkflow = `name: Deploy [[ .Name ]]
+
+on:
+  push:
+    branches: [main]
+  workflow_dispatch:
+
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    permissions:
+      contents: read
+[[- if eq .Registry "ghcr" ]]
+      packages: write
+[[- end ]]
+    env:
+      IMAGE_TAG: ${{ github.sha }}
+    steps:
+      - uses: actions/checkout@v4
+
+      - name: Setup Smurf
+        uses: clouddrove/smurf@v1.1.5
+[[- if eq .Registry "ecr" ]]
+
+      - name: Configure AWS credentials
+        uses: aws-actions/configure-aws-credentials@v4
+        with:
+          aws-access-key-id: ${{ secrets.AWS_ACCESS_KEY_ID }}
+          aws-secret-access-key: ${{ secrets.AWS_SECRET_ACCESS_KEY }}
+          aws-region: us-east-1
+[[- else if eq .Registry "gcp" ]]
+
+      - name: Authenticate to Google Cloud
+        uses: google-github-actions/auth@v2
+        with:
+          credentials_json: ${{ secrets.GCP_CREDENTIALS }}
+[[- end ]]
+
+      - name: Configure kubectl
+        run: |
+          mkdir -p ~/.kube
+          echo "${{ secrets.KUBECONFIG_B64 }}" | base64 -d > ~/.kube/config
+
+      - name: Deploy
+        run: smurf deploy
+[[- if eq .Registry "hub" ]]
+        env:
+          DOCKER_USERNAME: ${{ secrets.DOCKER_USERNAME }}
+          DOCKER_PASSWORD: ${{ secrets.DOCKER_PASSWORD }}
+[[- else if eq .Registry "ghcr" ]]
+        env:
+          GITHUB_USERNAME: ${{ github.actor }}
+          GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}
+[[- end ]]
+`
+
+const scaffoldGitLabCI = `# CI/CD variables: KUBECONFIG_B64 (a base64 kubeconfig)
+[[- if eq .Registry "hub" ]], DOCKER_USERNAME, DOCKER_PASSWORD
+[[- else if eq .Registry "ghcr" ]], GITHUB_USERNAME, GITHUB_TOKEN
+[[- else if eq .Registry "ecr" ]], AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION
+[[- else if eq .Registry "gcp" ]], GOOGLE_APPLICATION_CREDENTIALS (file)
+[[- end ]]
+stages:
+  - deploy
+
+deploy-[[ .Name ]]:
+  stage: deploy
+  image: docker:27
+  services:
+    - docker:27-dind
+  variables:
+    DOCKER_HOST: tcp://docker:2375
+    DOCKER_TLS_CERTDIR: ""
+    IMAGE_TAG: $CI_COMMIT_SHORT_SHA
+  before_script:
+    - apk add --no-cache bash curl
+    - curl -fsSL https://raw.githubusercontent.com/clouddrove/smurf/master/install/install.sh | bash
+    - mkdir -p ~/.kube && echo "$KUBECONFIG_B64" | base64 -d > ~/.kube/config
+  script:
+    - smurf deploy
+  rules:
+    - if: $CI_COMMIT_BRANCH == $CI_DEFAULT_BRANCH
+`
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
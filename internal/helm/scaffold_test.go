@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCreateScaffold(t *testing.T) {
+	dir := t.TempDir()
+	opts := ScaffoldOptions{Registry: "ghcr", CI: "github", Image: "ghcr.io/acme/api", Namespace: "apps"}
+	if err := CreateScaffold("api", dir, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// The chart values carry the image smurf deploy overwrites.
+	var values struct {
+		Image struct{ Repository, Tag string }
+	}
+	readYAML(t, filepath.Join(dir, "api", "values.yaml"), &values)
+	if values.Image.Repository != "ghcr.io/acme/api" {
+		t.Errorf("image.repository = %q, want ghcr.io/acme/api", values.Image.Repository)
+	}
+
+	var cfg struct {
+		Sdkr map[string]interface{}
+		Selm map[string]interface{}
+	}
+	readYAML(t, filepath.Join(dir, "smurf.yaml"), &cfg)
+	if cfg.Sdkr["imageName"] != "ghcr.io/acme/api:${IMAGE_TAG}" || cfg.Sdkr["ghcrRepo"] != true {
+		t.Errorf("sdkr = %v", cfg.Sdkr)
+	}
+	if cfg.Selm["chartName"] != "./api" || cfg.Selm["namespace"] != "apps" || cfg.Selm["deployHelm"] != true {
+		t.Errorf("selm = %v", cfg.Selm)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "smurf.yaml")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("smurf.yaml mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	var workflow map[string]interface{}
+	readYAML(t, filepath.Join(dir, ".github", "workflows", "deploy.yml"), &workflow)
+	if data, _ := os.ReadFile(filepath.Join(dir, ".github", "workflows", "deploy.yml")); !strings.Contains(string(data), "run: smurf deploy") {
+		t.Errorf("workflow does not run smurf deploy:\n%s", data)
+	}
+
+	// A second run must not touch the existing scaffold.
+	if err := CreateScaffold("api", dir, opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second CreateScaffold = %v, want an already exists error", err)
+	}
+}
+
+func TestScaffoldFiles(t *testing.T) {
+	for _, registry := range []string{"hub", "ecr", "ghcr", "gcp"} {
+		for _, ci := range []string{"github", "gitlab"} {
+			files, image, err := scaffoldFiles("charts/web", ScaffoldOptions{Registry: registry, CI: ci})
+			if err != nil {
+				t.Fatalf("%s/%s: %v", registry, ci, err)
+			}
+			if !strings.HasSuffix(image, "/web") {
+				t.Errorf("%s: image = %q, want a placeholder ending in /web", registry, image)
+			}
+			for name, content := range files {
+				var doc map[string]interface{}
+				if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+					t.Errorf("%s/%s: %s is not valid YAML: %v\n%s", registry, ci, name, err, content)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		opts ScaffoldOptions
+		want string
+	}{
+		{ScaffoldOptions{Registry: "quay"}, "invalid registry"},
+		{ScaffoldOptions{CI: "jenkins"}, "invalid CI"},
+		{ScaffoldOptions{Image: "acme/web:1.0"}, "without a tag"},
+	}
+	for _, tt := range tests {
+		if _, _, err := scaffoldFiles("web", tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("scaffoldFiles(%+v) = %v, want it to contain %q", tt.opts, err, tt.want)
+		}
+	}
+}
+
+func readYAML(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
@@ -0,0 +1,199 @@
+package helm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/clouddrove/smurf/configs"
+	"helm.sh/helm/v3/pkg/postrender"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// podSpecPaths is where the pod spec sits in each workload kind.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// schedulingPostRenderer injects selm.scheduling into the pod spec of every
+// workload Helm renders. Helm passes hooks to no post-renderer, so hook
+// workloads keep the chart's placement.
+type schedulingPostRenderer struct {
+	scheduling configs.SchedulingConfig
+}
+
+// schedulingRenderer returns the post-renderer of configs.Scheduling, or nil
+// when it injects nothing, for the PostRenderer of a Helm action.
+func schedulingRenderer() postrender.PostRenderer {
+	if configs.Scheduling.IsZero() {
+		return nil
+	}
+	return schedulingPostRenderer{scheduling: configs.Scheduling}
+}
+
+// Run rewrites the workloads of the rendered manifests; every other document
+// is passed through unchanged.
+func (r schedulingPostRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	reader := yamlutil.NewYAMLReader(bufio.NewReader(rendered))
+	out := new(bytes.Buffer)
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scheduling overlay: failed to read the rendered manifests: %w", err)
+		}
+		doc = bytes.TrimSpace(doc)
+		if len(doc) == 0 {
+			continue
+		}
+		patched, err := r.patch(doc)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(patched)
+		out.WriteString("\n")
+	}
+	return out, nil
+}
+
+// patch applies the overlay to the manifest doc when it is a workload.
+func (r schedulingPostRenderer) patch(doc []byte) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal(doc, &obj); err != nil || obj == nil {
+		// Not an object (e.g. only comments); leave it to Helm.
+		return doc, nil
+	}
+	kind, _ := obj["kind"].(string)
+	path, ok := podSpecPaths[kind]
+	if !ok {
+		return doc, nil
+	}
+	podSpec := nestedMap(obj, path...)
+	r.apply(podSpec, kind, workloadSelector(obj, kind))
+
+	patched, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("scheduling overlay: failed to write %s: %w", kind, err)
+	}
+	return bytes.TrimSpace(patched), nil
+}
+
+// apply merges the overlay into podSpec. selector labels the pods of the
+// workload, for spread constraints without a labelSelector.
+func (r schedulingPostRenderer) apply(podSpec map[string]interface{}, kind string, selector map[string]interface{}) {
+	if len(r.scheduling.NodeSelector) > 0 {
+		nodeSelector, _ := podSpec["nodeSelector"].(map[string]interface{})
+		if nodeSelector == nil {
+			nodeSelector = map[string]interface{}{}
+		}
+		for k, v := range r.scheduling.NodeSelector {
+			nodeSelector[k] = v
+		}
+		podSpec["nodeSelector"] = nodeSelector
+	}
+
+	if len(r.scheduling.Tolerations) > 0 {
+		tolerations, _ := podSpec["tolerations"].([]interface{})
+		for _, t := range r.scheduling.Tolerations {
+			t := normalizeOverlay(t)
+			if !containsEqual(tolerations, t) {
+				tolerations = append(tolerations, t)
+			}
+		}
+		podSpec["tolerations"] = tolerations
+	}
+
+	// A DaemonSet runs one pod per node; spreading it means nothing.
+	if len(r.scheduling.TopologySpreadConstraints) > 0 && kind != "DaemonSet" {
+		constraints, _ := podSpec["topologySpreadConstraints"].([]interface{})
+		for _, c := range r.scheduling.TopologySpreadConstraints {
+			c := normalizeOverlay(c)
+			if _, ok := c["labelSelector"]; !ok && len(selector) > 0 {
+				c["labelSelector"] = map[string]interface{}{"matchLabels": selector}
+			}
+			replaced := false
+			for i, existing := range constraints {
+				if m, ok := existing.(map[string]interface{}); ok && m["topologyKey"] == c["topologyKey"] {
+					constraints[i], replaced = c, true
+				}
+			}
+			if !replaced {
+				constraints = append(constraints, c)
+			}
+		}
+		podSpec["topologySpreadConstraints"] = constraints
+	}
+}
+
+// workloadSelector returns the labels that select the pods of a workload:
+// its selector's matchLabels, or a Pod's own labels.
+func workloadSelector(obj map[string]interface{}, kind string) map[string]interface{} {
+	switch kind {
+	case "Pod":
+		labels, _ := nestedMap(obj, "metadata")["labels"].(map[string]interface{})
+		return labels
+	case "CronJob", "Job":
+		// Jobs select their pods by controller-uid, which is not known
+		// before they run.
+		return nil
+	}
+	matchLabels, _ := nestedMap(obj, "spec", "selector")["matchLabels"].(map[string]interface{})
+	if matchLabels == nil && kind == "ReplicationController" {
+		matchLabels, _ = nestedMap(obj, "spec")["selector"].(map[string]interface{})
+	}
+	return matchLabels
+}
+
+// nestedMap returns the map at path in obj, creating the maps that are
+// missing.
+func nestedMap(obj map[string]interface{}, path ...string) map[string]interface{} {
+	m := obj
+	for _, key := range path {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	return m
+}
+
+// normalizeOverlay copies an overlay entry read from smurf.yaml into the
+// map[string]interface{} form the rendered manifests decode to. The copy
+// keeps one workload's labelSelector out of the next workload.
+func normalizeOverlay(entry map[string]interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(convertToMapStringInterface(entry))
+	if err != nil {
+		return entry
+	}
+	var out map[string]interface{}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return entry
+	}
+	return out
+}
+
+// containsEqual reports whether list holds an element deeply equal to v.
+func containsEqual(list []interface{}, v map[string]interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
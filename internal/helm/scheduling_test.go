@@ -0,0 +1,166 @@
+package helm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+	"sigs.k8s.io/yaml"
+)
+
+const schedulingManifests = `---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      nodeSelector:
+        disk: ssd
+        pool: general
+      tolerations:
+      - key: dedicated
+        operator: Equal
+        value: platform
+        effect: NoSchedule
+      topologySpreadConstraints:
+      - maxSkew: 3
+        topologyKey: topology.kubernetes.io/zone
+        whenUnsatisfiable: ScheduleAnyway
+      containers:
+      - name: web
+        image: web:v1
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: report
+            image: report:v1
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  selector:
+    matchLabels:
+      app: agent
+  template:
+    spec:
+      containers:
+      - name: agent
+        image: agent:v1
+`
+
+const schedulingConfig = `
+selm:
+  scheduling:
+    nodeSelector:
+      pool: platform
+    tolerations:
+      - key: dedicated
+        operator: Equal
+        value: platform
+        effect: NoSchedule
+      - key: spot
+        operator: Exists
+    topologySpreadConstraints:
+      - maxSkew: 1
+        topologyKey: topology.kubernetes.io/zone
+        whenUnsatisfiable: DoNotSchedule
+`
+
+func TestSchedulingPostRenderer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smurf.yaml")
+	if err := os.WriteFile(path, []byte(schedulingConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scheduling, err := configs.LoadSelmScheduling(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := configs.Scheduling
+	configs.Scheduling = scheduling
+	defer func() { configs.Scheduling = old }()
+
+	out, err := schedulingRenderer().Run(bytes.NewBufferString(schedulingManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{}
+	for _, doc := range strings.Split(out.String(), "---\n") {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj == nil {
+			continue
+		}
+		docs[obj["kind"].(string)] = obj
+	}
+	if len(docs) != 4 {
+		t.Fatalf("post-rendered %d documents, want 4:\n%s", len(docs), out)
+	}
+	if !strings.Contains(out.String(), "# Source: web/templates/service.yaml\napiVersion: v1") {
+		t.Errorf("a document that is not a workload was rewritten:\n%s", out)
+	}
+
+	pod := nestedMap(docs["Deployment"], "spec", "template", "spec")
+	if want := map[string]interface{}{"disk": "ssd", "pool": "platform"}; !reflect.DeepEqual(pod["nodeSelector"], want) {
+		t.Errorf("Deployment nodeSelector = %v, want %v", pod["nodeSelector"], want)
+	}
+	if tolerations := pod["tolerations"].([]interface{}); len(tolerations) != 2 {
+		t.Errorf("Deployment tolerations = %v, want the chart's one and spot", tolerations)
+	}
+	constraints := pod["topologySpreadConstraints"].([]interface{})
+	c := constraints[0].(map[string]interface{})
+	if len(constraints) != 1 || c["maxSkew"] != float64(1) ||
+		!reflect.DeepEqual(c["labelSelector"], map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}) {
+		t.Errorf("Deployment topologySpreadConstraints = %v, want the overlay's, selecting app=web", constraints)
+	}
+
+	cron := nestedMap(docs["CronJob"], "spec", "jobTemplate", "spec", "template", "spec")
+	if cron["nodeSelector"] == nil || len(cron["tolerations"].([]interface{})) != 2 {
+		t.Errorf("CronJob pod spec = %v", cron)
+	}
+	if c := cron["topologySpreadConstraints"].([]interface{})[0].(map[string]interface{}); c["labelSelector"] != nil {
+		t.Errorf("a Job's constraint got a labelSelector: %v", c)
+	}
+
+	agent := nestedMap(docs["DaemonSet"], "spec", "template", "spec")
+	if agent["nodeSelector"] == nil || agent["topologySpreadConstraints"] != nil {
+		t.Errorf("DaemonSet pod spec = %v, want the node selector without spread constraints", agent)
+	}
+}
+
+func TestSchedulingRendererEmpty(t *testing.T) {
+	old := configs.Scheduling
+	configs.Scheduling = configs.SchedulingConfig{}
+	defer func() { configs.Scheduling = old }()
+	if r := schedulingRenderer(); r != nil {
+		t.Errorf("schedulingRenderer() without selm.scheduling = %v, want nil", r)
+	}
+}
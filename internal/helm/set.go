@@ -0,0 +1,177 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// HelmSetValues upgrades a release in place, reusing the chart and the
+// user-supplied values stored with its current revision and patching only
+// the given keys. setValues use --set semantics and setString values are
+// always treated as strings, as with --set-string.
+func HelmSetValues(releaseName string, setValues, setString []string, opts SetValuesOptions, useAI bool) error {
+	if len(setValues) == 0 && len(setString) == 0 {
+		return fmt.Errorf("at least one key=value must be given")
+	}
+
+	actionConfig, err := initActionConfig(opts.Namespace, opts.Debug)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to initialize helm: %w", err)
+	}
+
+	current, err := action.NewGet(actionConfig).Run(releaseName)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("failed to get release %s in namespace %s: %w", releaseName, opts.Namespace, err)
+	}
+	if current.Chart == nil {
+		return fmt.Errorf("release %s has no stored chart", releaseName)
+	}
+
+	in := newInterpolator()
+	if setValues, err = interpolateSets(setValues, in); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	if setString, err = interpolateSets(setString, in); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	vals, err := patchValues(current.Config, setValues, setString)
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	pterm.Info.Printfln("Patching release %s (revision %d, chart %s-%s):", releaseName, current.Version, current.Chart.Name(), current.Chart.Metadata.Version)
+	before, after := redactValuesPair(current.Config, vals)
+	for _, key := range patchedKeys(setValues, setString) {
+		pterm.Printfln("  %s: %s → %s", key, formatValue(lookupValue(before, key)), formatValue(lookupValue(after, key)))
+	}
+
+	if err := backupRelease(current); err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	client := action.NewUpgrade(actionConfig)
+	client.Namespace = opts.Namespace
+	client.Atomic = opts.Atomic
+	client.Timeout = time.Duration(opts.Timeout) * time.Second
+	client.Wait = opts.Wait || opts.Atomic
+	client.WaitForJobs = opts.Wait
+	client.MaxHistory = opts.HistoryMax
+	client.CleanupOnFail = true
+	client.SubNotes = true
+	client.PostRenderer = schedulingRenderer()
+	// vals already holds the full set of stored user values with the patch
+	// applied, so neither reuse nor reset the release's values.
+	client.ReuseValues = false
+	client.ResetValues = false
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Upgrading release %s...", releaseName))
+	rel, err := client.Run(releaseName, current.Chart, vals)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Upgrade of release %s failed\n", releaseName))
+		describeFailedResources(opts.Namespace, releaseName)
+		ai.AIExplainError(useAI, err.Error())
+		return fmt.Errorf("upgrade failed: %w", err)
+	}
+	spinner.Success(fmt.Sprintf("Release %s upgraded to revision %d\n", releaseName, rel.Version))
+
+	if opts.Wait {
+		if err := verifyFinalReadiness(opts.Namespace, releaseName, configs.Timeouts.ReadinessTimeout(), opts.Debug); err != nil {
+			ai.AIExplainError(useAI, err.Error())
+			return fmt.Errorf("readiness verification failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// patchValues returns a deep copy of base with the --set style setValues and
+// the --set-string style setString applied on top.
+func patchValues(base map[string]interface{}, setValues, setString []string) (map[string]interface{}, error) {
+	vals, err := copyValues(base)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, set := range setValues {
+		if err := strvals.ParseInto(set, vals); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", set, err)
+		}
+	}
+	for _, set := range setString {
+		if err := strvals.ParseIntoString(set, vals); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", set, err)
+		}
+	}
+	return vals, nil
+}
+
+// copyValues deep copies a values map through JSON, the form Helm stores
+// release values in, so patching never mutates the stored release.
+func copyValues(vals map[string]interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if len(vals) == 0 {
+		return out, nil
+	}
+	data, err := json.Marshal(vals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy release values: %w", err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to copy release values: %w", err)
+	}
+	return out, nil
+}
+
+// patchedKeys returns the keys named by key=value assignments, in order.
+// A single assignment may set several comma separated keys.
+func patchedKeys(sets ...[]string) []string {
+	var keys []string
+	for _, set := range sets {
+		for _, s := range set {
+			for _, pair := range strings.Split(s, ",") {
+				if key, _, ok := strings.Cut(pair, "="); ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// lookupValue returns the value at a dotted key path in vals, or nil if it
+// isn't set. List indexes are not resolved.
+func lookupValue(vals map[string]interface{}, key string) interface{} {
+	var cur interface{} = vals
+	for _, part := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if cur, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return cur
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return none
+	}
+	return fmt.Sprintf("%v", v)
+}
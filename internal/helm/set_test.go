@@ -0,0 +1,50 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatchValues(t *testing.T) {
+	base := map[string]interface{}{
+		"replicaCount": float64(2),
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.25"},
+	}
+
+	got, err := patchValues(base, []string{"replicaCount=5", "image.tag=1.27"}, []string{"podAnnotations.build=0012"})
+	if err != nil {
+		t.Fatalf("patchValues: %v", err)
+	}
+
+	if v := lookupValue(got, "replicaCount"); v != int64(5) {
+		t.Errorf("replicaCount = %#v, want 5", v)
+	}
+	if v := lookupValue(got, "image.tag"); v != "1.27" {
+		t.Errorf("image.tag = %#v, want 1.27", v)
+	}
+	if v := lookupValue(got, "image.repository"); v != "nginx" {
+		t.Errorf("untouched keys must be kept, image.repository = %#v", v)
+	}
+	if v := lookupValue(got, "podAnnotations.build"); v != "0012" {
+		t.Errorf("--set-string value = %#v, want the string 0012", v)
+	}
+
+	// The stored release values must not be mutated.
+	if v := lookupValue(base, "image.tag"); v != "1.25" {
+		t.Errorf("base was mutated: image.tag = %#v", v)
+	}
+
+	if _, err := patchValues(base, []string{"a[=b"}, nil); err == nil {
+		t.Error("expected an error for a malformed assignment")
+	}
+}
+
+func TestPatchedKeys(t *testing.T) {
+	got := patchedKeys([]string{"a=1,b.c=2"}, []string{"d=x"})
+	if want := []string{"a", "b.c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("patchedKeys = %v, want %v", got, want)
+	}
+	if lookupValue(map[string]interface{}{}, "missing.key") != nil {
+		t.Error("lookup of a missing key must return nil")
+	}
+}
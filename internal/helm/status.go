@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -9,6 +10,16 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 )
 
+// ReleaseStatus is the document selm status prints with --output json or
+// yaml: the release, its readiness and the live state of its resources.
+type ReleaseStatus struct {
+	ReleaseSummary
+	Notes             string   `json:"notes" yaml:"notes"`
+	AllResourcesReady bool     `json:"all_resources_ready" yaml:"all_resources_ready"`
+	NotReadyResources []string `json:"not_ready_resources" yaml:"not_ready_resources"`
+	ReleaseResources
+}
+
 // HelmStatus retrieves and displays the status of a specified Helm release within a given namespace.
 // It initializes the Helm action configuration, fetches the release status, and presents it in a formatted table.
 // Additionally, it checks the readiness of the associated Kubernetes resources and provides detailed feedback.
@@ -105,22 +116,20 @@ func HelmStatus(releaseName, namespace, format string, useAI bool) error {
 	}
 
 	if !isTable {
-		if notReadyResources == nil {
-			notReadyResources = []string{}
+		status := ReleaseStatus{
+			ReleaseSummary:    releaseSummary(rel),
+			Notes:             rel.Info.Notes,
+			AllResourcesReady: allReady,
+			NotReadyResources: notReadyResources,
 		}
-		result := map[string]interface{}{
-			"name":                rel.Name,
-			"namespace":           rel.Namespace,
-			"status":              rel.Info.Status.String(),
-			"revision":            rel.Version,
-			"notes":               rel.Info.Notes,
-			"all_resources_ready": allReady,
-			"not_ready_resources": notReadyResources,
+		if status.NotReadyResources == nil {
+			status.NotReadyResources = []string{}
 		}
-		if format == "yaml" {
-			return printYAML(result)
+		status.ReleaseResources, err = collectReleaseResources(context.Background(), clientset, rel)
+		if err != nil {
+			return fmt.Errorf("error reading the release resources: %w", err)
 		}
-		return printJSON(result)
+		return printStructured(status, format)
 	}
 
 	if !allReady {
@@ -0,0 +1,285 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// statusWatchInterval is how often `selm status --watch` refreshes.
+const statusWatchInterval = 2 * time.Second
+
+// resourceState is the readiness of one resource of a release, as the watch
+// view shows it.
+type resourceState struct {
+	Resource string // Kind/Name
+	Ready    bool
+	Detail   string // e.g. "2/3 ready", "Running", "Bound"
+}
+
+// WatchStatus shows the status of a release and its workloads, refreshing it
+// until the release is deployed and every workload is ready, the release
+// fails, timeout passes (0 means no limit) or the user interrupts. It ends
+// with a condensed summary and returns an error unless the release is ready.
+func WatchStatus(releaseName, namespace string, timeout time.Duration, useAI bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, "secrets", nil); err != nil {
+		logDetailedError("helm status", err, namespace, releaseName)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	start := time.Now()
+	area, _ := pterm.DefaultArea.Start()
+	var rel *release.Release
+	var states []resourceState
+	for {
+		rel, err = action.NewStatus(actionConfig).Run(releaseName)
+		if err != nil {
+			_ = area.Stop()
+			logDetailedError("helm status", err, namespace, releaseName)
+			ai.AIExplainError(useAI, err.Error())
+			return err
+		}
+		resources, err := parseResourcesFromManifest(rel.Manifest)
+		if err != nil {
+			_ = area.Stop()
+			return fmt.Errorf("error parsing manifest for readiness check: %w", err)
+		}
+		states = releaseResourceStates(ctx, clientset, rel.Namespace, resources)
+		area.Update(renderStatusWatch(rel, states, time.Since(start)))
+
+		if statusSettled(rel, states) || rel.Info.Status == release.StatusFailed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(statusWatchInterval):
+			continue
+		}
+		break
+	}
+	_ = area.Stop()
+
+	summary := statusSummary(rel, states, time.Since(start))
+	switch {
+	case statusSettled(rel, states):
+		pterm.Success.Println(summary)
+		return nil
+	case rel.Info.Status == release.StatusFailed:
+		pterm.Error.Println(summary)
+		describeFailedResources(rel.Namespace, rel.Name)
+		err = fmt.Errorf("release %s failed: %s", rel.Name, rel.Info.Description)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		pterm.Warning.Println(summary)
+		err = fmt.Errorf("release %s was not ready within %s", rel.Name, timeout)
+	default:
+		pterm.Warning.Println(summary)
+		err = fmt.Errorf("interrupted before release %s was ready", rel.Name)
+	}
+	ai.AIExplainError(useAI, err.Error())
+	return err
+}
+
+// statusSettled reports whether the release is deployed and every resource
+// watched is ready.
+func statusSettled(rel *release.Release, states []resourceState) bool {
+	if rel.Info == nil || rel.Info.Status != release.StatusDeployed {
+		return false
+	}
+	for _, s := range states {
+		if !s.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// renderStatusWatch is one frame of the watch view.
+func renderStatusWatch(rel *release.Release, states []resourceState, elapsed time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Release %s in %s: %s, revision %d (watching for %s, Ctrl+C to stop)\n\n",
+		rel.Name, rel.Namespace, colorizeStatus(rel.Info.Status.String()), rel.Version, elapsed.Round(time.Second))
+	if len(states) == 0 {
+		b.WriteString("No workloads to wait for.\n")
+		return b.String()
+	}
+	data := [][]string{{"RESOURCE", "READY", "DETAIL"}}
+	for _, s := range states {
+		ready := pterm.FgGreen.Sprint("yes")
+		if !s.Ready {
+			ready = pterm.FgYellow.Sprint("no")
+		}
+		data = append(data, []string{s.Resource, ready, s.Detail})
+	}
+	table, _ := pterm.DefaultTable.WithHasHeader(true).WithData(data).Srender()
+	b.WriteString(table)
+	return b.String()
+}
+
+// statusSummary condenses the final state of a watch into one line, naming
+// the resources that are not ready.
+func statusSummary(rel *release.Release, states []resourceState, elapsed time.Duration) string {
+	ready := 0
+	var pending []string
+	for _, s := range states {
+		if s.Ready {
+			ready++
+		} else {
+			pending = append(pending, fmt.Sprintf("%s (%s)", s.Resource, s.Detail))
+		}
+	}
+	summary := fmt.Sprintf("%s revision %d is %s; %d/%d resources ready after %s",
+		rel.Name, rel.Version, rel.Info.Status, ready, len(states), elapsed.Round(time.Second))
+	if len(pending) > 0 {
+		summary += "; not ready: " + strings.Join(pending, ", ")
+	}
+	return summary
+}
+
+// releaseResourceStates returns the readiness of the workloads among
+// resources. Kinds without a notion of readiness are left out.
+func releaseResourceStates(ctx context.Context, clientset kubernetes.Interface, namespace string, resources []Resource) []resourceState {
+	var states []resourceState
+	for _, res := range resources {
+		ns := namespace
+		if res.Namespace != "" {
+			ns = res.Namespace
+		}
+		var state resourceState
+		var err error
+		switch res.Kind {
+		case "Deployment":
+			var obj *appsv1.Deployment
+			if obj, err = clientset.AppsV1().Deployments(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = deploymentState(obj)
+			}
+		case "StatefulSet":
+			var obj *appsv1.StatefulSet
+			if obj, err = clientset.AppsV1().StatefulSets(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = statefulSetState(obj)
+			}
+		case "DaemonSet":
+			var obj *appsv1.DaemonSet
+			if obj, err = clientset.AppsV1().DaemonSets(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = daemonSetState(obj)
+			}
+		case "Job":
+			var obj *batchv1.Job
+			if obj, err = clientset.BatchV1().Jobs(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = jobState(obj)
+			}
+		case "Pod":
+			var obj *corev1.Pod
+			if obj, err = clientset.CoreV1().Pods(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = podState(obj)
+			}
+		case "PersistentVolumeClaim":
+			var obj *corev1.PersistentVolumeClaim
+			if obj, err = clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, res.Name, metav1.GetOptions{}); err == nil {
+				state = resourceState{Ready: obj.Status.Phase == corev1.ClaimBound, Detail: string(obj.Status.Phase)}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			state = resourceState{Detail: "not found"}
+			if !isNotFound(err) {
+				state.Detail = err.Error()
+			}
+		}
+		state.Resource = res.Kind + "/" + res.Name
+		states = append(states, state)
+	}
+	return states
+}
+
+func replicaCount(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func deploymentState(d *appsv1.Deployment) resourceState {
+	want := replicaCount(d.Spec.Replicas)
+	s := d.Status
+	return resourceState{
+		Ready:  s.ObservedGeneration >= d.Generation && s.UpdatedReplicas == want && s.AvailableReplicas == want && s.Replicas == want,
+		Detail: fmt.Sprintf("%d/%d ready, %d updated", s.ReadyReplicas, want, s.UpdatedReplicas),
+	}
+}
+
+func statefulSetState(ss *appsv1.StatefulSet) resourceState {
+	want := replicaCount(ss.Spec.Replicas)
+	s := ss.Status
+	return resourceState{
+		Ready:  s.ObservedGeneration >= ss.Generation && s.ReadyReplicas == want && s.UpdatedReplicas == want,
+		Detail: fmt.Sprintf("%d/%d ready, %d updated", s.ReadyReplicas, want, s.UpdatedReplicas),
+	}
+}
+
+func daemonSetState(ds *appsv1.DaemonSet) resourceState {
+	s := ds.Status
+	return resourceState{
+		Ready:  s.ObservedGeneration >= ds.Generation && s.NumberReady == s.DesiredNumberScheduled && s.UpdatedNumberScheduled == s.DesiredNumberScheduled,
+		Detail: fmt.Sprintf("%d/%d ready, %d updated", s.NumberReady, s.DesiredNumberScheduled, s.UpdatedNumberScheduled),
+	}
+}
+
+func jobState(j *batchv1.Job) resourceState {
+	for _, c := range j.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return resourceState{Ready: true, Detail: "Complete"}
+		case batchv1.JobFailed:
+			return resourceState{Detail: "Failed: " + c.Reason}
+		}
+	}
+	want := replicaCount(j.Spec.Completions)
+	return resourceState{Detail: fmt.Sprintf("%d/%d succeeded, %d active", j.Status.Succeeded, want, j.Status.Active)}
+}
+
+func podState(p *corev1.Pod) resourceState {
+	switch p.Status.Phase {
+	case corev1.PodSucceeded:
+		return resourceState{Ready: true, Detail: string(p.Status.Phase)}
+	case corev1.PodRunning:
+		if isPodReady(*p) {
+			return resourceState{Ready: true, Detail: string(p.Status.Phase)}
+		}
+		return resourceState{Detail: "Running, not ready"}
+	}
+	return resourceState{Detail: string(p.Status.Phase)}
+}
@@ -0,0 +1,72 @@
+package helm
+
+import (
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWorkloadStates(t *testing.T) {
+	three := int32(3)
+	tests := []struct {
+		name  string
+		state resourceState
+		ready bool
+	}{
+		{"deployment rolling", deploymentState(&appsv1.Deployment{
+			Spec:   appsv1.DeploymentSpec{Replicas: &three},
+			Status: appsv1.DeploymentStatus{Replicas: 4, UpdatedReplicas: 2, AvailableReplicas: 3, ReadyReplicas: 3},
+		}), false},
+		{"deployment ready", deploymentState(&appsv1.Deployment{
+			Spec:   appsv1.DeploymentSpec{Replicas: &three},
+			Status: appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3, ReadyReplicas: 3},
+		}), true},
+		{"statefulset default replicas", statefulSetState(&appsv1.StatefulSet{
+			Status: appsv1.StatefulSetStatus{ReadyReplicas: 1, UpdatedReplicas: 1},
+		}), true},
+		{"daemonset updating", daemonSetState(&appsv1.DaemonSet{
+			Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2, UpdatedNumberScheduled: 1},
+		}), false},
+		{"job complete", jobState(&batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}}}), true},
+		{"job failed", jobState(&batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+		}}}), false},
+		{"pod not ready", podState(&corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}), false},
+	}
+	for _, tt := range tests {
+		if tt.state.Ready != tt.ready {
+			t.Errorf("%s: ready = %v (%s), want %v", tt.name, tt.state.Ready, tt.state.Detail, tt.ready)
+		}
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	rel := &release.Release{Name: "api", Version: 4, Info: &release.Info{Status: release.StatusDeployed}}
+	states := []resourceState{
+		{Resource: "Deployment/api", Ready: true, Detail: "2/2 ready, 2 updated"},
+		{Resource: "Job/migrate", Detail: "0/1 succeeded, 1 active"},
+	}
+
+	if statusSettled(rel, states) {
+		t.Error("statusSettled = true with a job still running")
+	}
+	want := "api revision 4 is deployed; 1/2 resources ready after 42s; not ready: Job/migrate (0/1 succeeded, 1 active)"
+	if got := statusSummary(rel, states, 42*time.Second); got != want {
+		t.Errorf("statusSummary = %q, want %q", got, want)
+	}
+
+	states[1].Ready = true
+	if !statusSettled(rel, states) {
+		t.Error("statusSettled = false with every resource ready")
+	}
+	rel.Info.Status = release.StatusPendingUpgrade
+	if statusSettled(rel, states) {
+		t.Error("statusSettled = true while the upgrade is pending")
+	}
+}
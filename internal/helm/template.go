@@ -2,40 +2,35 @@ package helm
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 )
 
 // HelmTemplate renders the Helm templates for a given chart, values files, and optionally a remote repo.
-// HelmTemplate renders the Helm templates for a given chart, values files, and optionally a remote repo.
-func HelmTemplate(releaseName, chartPath, namespace, repoURL string, valuesFiles []string, useAI bool) error {
+// Rendering is client-only: no cluster configuration is loaded, so it works
+// without kubeconfig or cluster credentials.
+func HelmTemplate(releaseName, chartPath, namespace, repoURL, version string, valuesFiles []string, useAI bool) error {
 	settings := cli.New()
-	actionConfig := new(action.Configuration)
-
-	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), nil); err != nil {
-		pterm.Error.Printfln("Failed to initialize action configuration: %v", err)
-		ai.AIExplainError(useAI, err.Error())
-		return err
-	}
+	settings.SetNamespace(namespace)
 
-	client := action.NewInstall(actionConfig)
+	client := action.NewInstall(new(action.Configuration))
 	client.DryRun = true
 	client.ReleaseName = releaseName
 	client.Namespace = namespace
 	client.Replace = true
 	client.ClientOnly = true
+	client.PostRenderer = schedulingRenderer()
 	client.ChartPathOptions.RepoURL = repoURL // Set repo URL if provided
+	client.ChartPathOptions.Version = version
 
 	spinner, _ := pterm.DefaultSpinner.Start("Locating chart...")
 
 	// ALWAYS use LocateChart to resolve the chart reference
-	chartPathFinal, err := client.ChartPathOptions.LocateChart(chartPath, settings)
+	chartPathFinal, err := locateChart(chartPath, client.ChartPathOptions, settings, false)
 	if err != nil {
 		spinner.Fail(fmt.Sprintf("Failed to locate chart '%s': %v", chartPath, err))
 		ai.AIExplainError(useAI, err.Error())
@@ -44,7 +39,7 @@ func HelmTemplate(releaseName, chartPath, namespace, repoURL string, valuesFiles
 	spinner.Success(fmt.Sprintf("Chart located: %s", chartPathFinal))
 
 	spinner, _ = pterm.DefaultSpinner.Start("Loading chart...")
-	chart, err := loader.Load(chartPathFinal)
+	chart, err := loadLocalChart(chartPathFinal, false)
 	if err != nil {
 		spinner.Fail(fmt.Sprintf("Failed to load chart: %v", err))
 		ai.AIExplainError(useAI, err.Error())
@@ -54,9 +49,10 @@ func HelmTemplate(releaseName, chartPath, namespace, repoURL string, valuesFiles
 
 	// Process values files - CORRECTED VERSION
 	vals := make(map[string]interface{})
+	in := newInterpolator()
 	for _, f := range valuesFiles {
 		spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Reading values file: %s", f))
-		additionalVals, err := chartutil.ReadValuesFile(f)
+		additionalVals, err := readValuesFile(f, in)
 		if err != nil {
 			spinner.Fail(fmt.Sprintf("Error reading values file '%s': %v", f, err))
 			ai.AIExplainError(useAI, err.Error())
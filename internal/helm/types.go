@@ -32,3 +32,13 @@ type RollbackOptions struct {
 	Timeout   int
 	Wait      bool
 }
+
+// SetValuesOptions holds the options for patching a release's values in place.
+type SetValuesOptions struct {
+	Namespace  string
+	Timeout    int
+	Wait       bool
+	Atomic     bool
+	Debug      bool
+	HistoryMax int
+}
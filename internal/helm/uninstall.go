@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/clouddrove/smurf/internal/ai"
@@ -194,14 +193,6 @@ func performUninstall(actionConfig *action.Configuration, opts UninstallOptions)
 }
 
 func initializeActionConfig(actionConfig *action.Configuration, namespace string) error {
-	// Set up Helm environment
-	if settings.KubeConfig == "" {
-		settings.KubeConfig = filepath.Join(homeDir(), ".kube", "config")
-	}
-	if settings.KubeContext == "" {
-		settings.KubeContext = os.Getenv("KUBECONTEXT")
-	}
-
 	return actionConfig.Init(
 		settings.RESTClientGetter(),
 		namespace,
@@ -249,10 +240,3 @@ func checkRemainingResources(clientset *kubernetes.Clientset, namespace, release
 
 	return remaining, nil
 }
-
-func homeDir() string {
-	if h := os.Getenv("HOME"); h != "" {
-		return h
-	}
-	return os.Getenv("USERPROFILE") // windows
-}
@@ -0,0 +1,614 @@
+package helm
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"sigs.k8s.io/yaml"
+)
+
+// UnitTestOptions holds the options for running chart unit tests.
+type UnitTestOptions struct {
+	// TestFiles are glob patterns, relative to the chart, selecting the test
+	// suite files. Defaults to tests/*_test.yaml, as helm-unittest does.
+	TestFiles []string
+	// Values are extra values files applied to every test.
+	Values []string
+	// JUnitFile, when set, receives a JUnit XML report of the run.
+	JUnitFile string
+	// FailFast stops at the first failing suite.
+	FailFast bool
+}
+
+// unitTestSuite is a helm-unittest compatible test suite file.
+type unitTestSuite struct {
+	Suite        string                 `json:"suite"`
+	Templates    []string               `json:"templates"`
+	Values       []string               `json:"values"`
+	Set          map[string]interface{} `json:"set"`
+	Release      unitTestRelease        `json:"release"`
+	Capabilities unitTestCapabilities   `json:"capabilities"`
+	Chart        unitTestChart          `json:"chart"`
+	Tests        []unitTestJob          `json:"tests"`
+}
+
+type unitTestRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Upgrade   bool   `json:"upgrade"`
+}
+
+type unitTestCapabilities struct {
+	MajorVersion string   `json:"majorVersion"`
+	MinorVersion string   `json:"minorVersion"`
+	APIVersions  []string `json:"apiVersions"`
+}
+
+type unitTestChart struct {
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion"`
+}
+
+// unitTestJob is a single test ("it") within a suite.
+type unitTestJob struct {
+	It            string                 `json:"it"`
+	Template      string                 `json:"template"`
+	Templates     []string               `json:"templates"`
+	DocumentIndex *int                   `json:"documentIndex"`
+	Values        []string               `json:"values"`
+	Set           map[string]interface{} `json:"set"`
+	Release       unitTestRelease        `json:"release"`
+	Capabilities  unitTestCapabilities   `json:"capabilities"`
+	Chart         unitTestChart          `json:"chart"`
+	Skip          bool                   `json:"skip"`
+	Asserts       []unitTestAssert       `json:"asserts"`
+}
+
+// unitTestResult is the outcome of one test.
+type unitTestResult struct {
+	Name     string
+	Skipped  bool
+	Failures []string
+	Duration time.Duration
+}
+
+func (r unitTestResult) passed() bool { return len(r.Failures) == 0 }
+
+// unitSuiteResult is the outcome of one suite file.
+type unitSuiteResult struct {
+	Name     string
+	File     string
+	Err      error // the suite itself could not be loaded
+	Tests    []unitTestResult
+	Duration time.Duration
+}
+
+func (s unitSuiteResult) passed() bool {
+	if s.Err != nil {
+		return false
+	}
+	for _, t := range s.Tests {
+		if !t.passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// HelmUnitTest runs the helm-unittest compatible test suites of a chart:
+// each test renders the chart's templates locally with its values and checks
+// the rendered documents against its assertions. No cluster is needed.
+func HelmUnitTest(chartPath string, opts UnitTestOptions, useAI bool) error {
+	results, err := runUnitTests(chartPath, opts)
+	if err != nil {
+		pterm.Error.Println(err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	printUnitTestResults(chartPath, results)
+
+	if opts.JUnitFile != "" {
+		if err := writeJUnitReport(opts.JUnitFile, results); err != nil {
+			return err
+		}
+		pterm.Info.Printfln("JUnit report written to %s", opts.JUnitFile)
+	}
+
+	for _, s := range results {
+		if !s.passed() {
+			return fmt.Errorf("chart unit tests failed")
+		}
+	}
+	return nil
+}
+
+// runUnitTests loads the chart and runs every matching suite.
+func runUnitTests(chartPath string, opts UnitTestOptions) ([]unitSuiteResult, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+
+	patterns := opts.TestFiles
+	if len(patterns) == 0 {
+		patterns = []string{filepath.Join("tests", "*_test.yaml")}
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(chartPath, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid test file pattern %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no test suites found in %s matching %s", chartPath, strings.Join(patterns, ", "))
+	}
+	sort.Strings(files)
+
+	var results []unitSuiteResult
+	for _, file := range files {
+		res := runUnitTestSuite(chrt, file, opts.Values)
+		results = append(results, res)
+		if opts.FailFast && !res.passed() {
+			break
+		}
+	}
+	return results, nil
+}
+
+func runUnitTestSuite(chrt *chart.Chart, file string, extraValues []string) unitSuiteResult {
+	start := time.Now()
+	res := unitSuiteResult{Name: filepath.Base(file), File: file}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	var suite unitTestSuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		res.Err = fmt.Errorf("invalid test suite %s: %w", file, err)
+		return res
+	}
+	if suite.Suite != "" {
+		res.Name = suite.Suite
+	}
+
+	suiteDir := filepath.Dir(file)
+	for _, job := range suite.Tests {
+		jobStart := time.Now()
+		result := unitTestResult{Name: job.It}
+		if job.Skip {
+			result.Skipped = true
+		} else {
+			result.Failures = runUnitTestJob(chrt, suite, job, suiteDir, extraValues)
+		}
+		result.Duration = time.Since(jobStart)
+		res.Tests = append(res.Tests, result)
+	}
+
+	res.Duration = time.Since(start)
+	return res
+}
+
+// runUnitTestJob renders the chart for one test and returns the messages of
+// the assertions that failed.
+func runUnitTestJob(chrt *chart.Chart, suite unitTestSuite, job unitTestJob, suiteDir string, extraValues []string) []string {
+	vals := map[string]interface{}{}
+	for _, f := range extraValues {
+		fileVals, err := chartutil.ReadValuesFile(f)
+		if err != nil {
+			return []string{fmt.Sprintf("failed to read values file %s: %v", f, err)}
+		}
+		vals = mergeMaps(vals, fileVals.AsMap())
+	}
+	for _, f := range append(append([]string{}, suite.Values...), job.Values...) {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(suiteDir, f)
+		}
+		fileVals, err := chartutil.ReadValuesFile(f)
+		if err != nil {
+			return []string{fmt.Sprintf("failed to read values file %s: %v", f, err)}
+		}
+		vals = mergeMaps(vals, fileVals.AsMap())
+	}
+	for _, set := range []map[string]interface{}{suite.Set, job.Set} {
+		setVals, err := expandSetPaths(set)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		vals = mergeMaps(vals, setVals)
+	}
+
+	rendered, renderErr := renderForUnitTest(chrt, vals, mergeUnitTestRelease(suite.Release, job.Release),
+		mergeUnitTestCapabilities(suite.Capabilities, job.Capabilities), mergeUnitTestChart(suite.Chart, job.Chart))
+
+	defaultTemplates := suite.Templates
+	if job.Template != "" {
+		defaultTemplates = []string{job.Template}
+	} else if len(job.Templates) > 0 {
+		defaultTemplates = job.Templates
+	}
+
+	var failures []string
+	for i, a := range job.Asserts {
+		templates := defaultTemplates
+		if a.Template != "" {
+			templates = []string{a.Template}
+		}
+		docIndex := job.DocumentIndex
+		if a.DocumentIndex != nil {
+			docIndex = a.DocumentIndex
+		}
+
+		docs, err := selectDocuments(rendered, templates, docIndex)
+		if err != nil && renderErr == nil {
+			failures = append(failures, fmt.Sprintf("assert %d (%s): %v", i, a.Type, err))
+			continue
+		}
+
+		if msg := a.evaluate(docs, renderErr); msg != "" {
+			failures = append(failures, fmt.Sprintf("assert %d (%s): %s", i, a.Type, msg))
+		}
+	}
+	return failures
+}
+
+// expandSetPaths turns helm-unittest `set` entries, whose keys are dotted
+// value paths, into a nested values map.
+func expandSetPaths(set map[string]interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		tokens, err := parseValuePath(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid set path %q: %w", k, err)
+		}
+		var nested interface{} = set[k]
+		for i := len(tokens) - 1; i >= 0; i-- {
+			key, ok := tokens[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid set path %q: list indexes are not supported in set", k)
+			}
+			nested = map[string]interface{}{key: nested}
+		}
+		out = mergeMaps(out, nested.(map[string]interface{}))
+	}
+	return out, nil
+}
+
+func mergeUnitTestRelease(suite, job unitTestRelease) unitTestRelease {
+	rel := unitTestRelease{Name: "RELEASE-NAME", Namespace: "NAMESPACE", Revision: 1}
+	for _, r := range []unitTestRelease{suite, job} {
+		if r.Name != "" {
+			rel.Name = r.Name
+		}
+		if r.Namespace != "" {
+			rel.Namespace = r.Namespace
+		}
+		if r.Revision != 0 {
+			rel.Revision = r.Revision
+		}
+		if r.Upgrade {
+			rel.Upgrade = true
+		}
+	}
+	return rel
+}
+
+func mergeUnitTestCapabilities(suite, job unitTestCapabilities) unitTestCapabilities {
+	caps := suite
+	if job.MajorVersion != "" {
+		caps.MajorVersion = job.MajorVersion
+	}
+	if job.MinorVersion != "" {
+		caps.MinorVersion = job.MinorVersion
+	}
+	caps.APIVersions = append(append([]string{}, suite.APIVersions...), job.APIVersions...)
+	return caps
+}
+
+func mergeUnitTestChart(suite, job unitTestChart) unitTestChart {
+	c := suite
+	if job.Version != "" {
+		c.Version = job.Version
+	}
+	if job.AppVersion != "" {
+		c.AppVersion = job.AppVersion
+	}
+	return c
+}
+
+// renderForUnitTest renders the chart locally and returns the manifests
+// keyed by their path inside the chart (e.g. "templates/deployment.yaml").
+func renderForUnitTest(chrt *chart.Chart, vals map[string]interface{}, rel unitTestRelease, c unitTestCapabilities, meta unitTestChart) (map[string]string, error) {
+	if meta.Version != "" || meta.AppVersion != "" {
+		copied := *chrt
+		md := *chrt.Metadata
+		if meta.Version != "" {
+			md.Version = meta.Version
+		}
+		if meta.AppVersion != "" {
+			md.AppVersion = meta.AppVersion
+		}
+		copied.Metadata = &md
+		chrt = &copied
+	}
+
+	caps := chartutil.DefaultCapabilities.Copy()
+	if c.MajorVersion != "" || c.MinorVersion != "" {
+		major, minor := caps.KubeVersion.Major, caps.KubeVersion.Minor
+		if c.MajorVersion != "" {
+			major = c.MajorVersion
+		}
+		if c.MinorVersion != "" {
+			minor = c.MinorVersion
+		}
+		caps.KubeVersion = chartutil.KubeVersion{
+			Version: fmt.Sprintf("v%s.%s.0", major, minor),
+			Major:   major,
+			Minor:   minor,
+		}
+	}
+	caps.APIVersions = append(caps.APIVersions, c.APIVersions...)
+
+	renderVals, err := chartutil.ToRenderValues(chrt, vals, chartutil.ReleaseOptions{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Revision,
+		IsInstall: !rel.Upgrade,
+		IsUpgrade: rel.Upgrade,
+	}, caps)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := engine.Render(chrt, renderVals)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make(map[string]string, len(out))
+	prefix := chrt.Name() + "/"
+	for name, content := range out {
+		manifests[strings.TrimPrefix(name, prefix)] = content
+	}
+	return manifests, nil
+}
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// selectDocuments returns the parsed YAML documents rendered from the given
+// templates (helm-unittest style paths, with globs), optionally narrowed to
+// one document index.
+func selectDocuments(rendered map[string]string, templates []string, docIndex *int) ([]map[string]interface{}, error) {
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no template selected: set templates on the suite or template on the test")
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var docs []map[string]interface{}
+	matched := false
+	for _, tpl := range templates {
+		if !strings.HasPrefix(tpl, "templates/") && !strings.HasPrefix(tpl, "charts/") {
+			tpl = "templates/" + tpl
+		}
+		for _, name := range names {
+			if ok, _ := path.Match(tpl, name); !ok {
+				continue
+			}
+			matched = true
+			if strings.HasSuffix(name, "NOTES.txt") {
+				continue
+			}
+			for _, raw := range yamlDocumentSeparator.Split(rendered[name], -1) {
+				var doc map[string]interface{}
+				if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+					return nil, fmt.Errorf("%s does not render valid YAML: %w", name, err)
+				}
+				if doc != nil {
+					docs = append(docs, doc)
+				}
+			}
+		}
+	}
+	if !matched && rendered != nil {
+		return nil, fmt.Errorf("template %s not found in chart", strings.Join(templates, ", "))
+	}
+
+	if docIndex != nil {
+		if *docIndex < 0 || *docIndex >= len(docs) {
+			return nil, fmt.Errorf("documentIndex %d out of range (%d documents)", *docIndex, len(docs))
+		}
+		return docs[*docIndex : *docIndex+1], nil
+	}
+	return docs, nil
+}
+
+// printUnitTestResults prints a helm-unittest style report of the run.
+func printUnitTestResults(chartPath string, results []unitSuiteResult) {
+	pterm.DefaultSection.Printfln("Chart unit tests: %s", chartPath)
+
+	var suitesPassed, suitesFailed, testsPassed, testsFailed, testsSkipped int
+	for _, s := range results {
+		if s.passed() {
+			suitesPassed++
+			pterm.Printfln("%s %s (%s)", pterm.Green("PASS"), s.Name, s.File)
+		} else {
+			suitesFailed++
+			pterm.Printfln("%s %s (%s)", pterm.Red("FAIL"), s.Name, s.File)
+		}
+		if s.Err != nil {
+			pterm.Printfln("    %s", pterm.Red(s.Err.Error()))
+			continue
+		}
+		for _, t := range s.Tests {
+			switch {
+			case t.Skipped:
+				testsSkipped++
+				pterm.Printfln("  %s %s", pterm.Yellow("-"), t.Name)
+			case t.passed():
+				testsPassed++
+				pterm.Printfln("  %s %s", pterm.Green("✓"), t.Name)
+			default:
+				testsFailed++
+				pterm.Printfln("  %s %s", pterm.Red("✗"), t.Name)
+				for _, f := range t.Failures {
+					pterm.Printfln("      %s", f)
+				}
+			}
+		}
+	}
+
+	pterm.Println()
+	pterm.Printfln("Test Suites: %d passed, %d failed, %d total", suitesPassed, suitesFailed, len(results))
+	pterm.Printfln("Tests:       %d passed, %d failed, %d skipped, %d total", testsPassed, testsFailed, testsSkipped, testsPassed+testsFailed+testsSkipped)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnitReport writes the results as JUnit XML, one <testsuite> per
+// suite file and one <testcase> per test.
+func writeJUnitReport(file string, results []unitSuiteResult) error {
+	report := junitTestSuites{Name: "smurf selm unittest"}
+	var total time.Duration
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, s := range results {
+		suite := junitTestSuite{
+			Name:      s.Name,
+			Time:      junitSeconds(s.Duration),
+			Timestamp: now,
+		}
+		if s.Err != nil {
+			suite.Tests, suite.Errors = 1, 1
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "load suite",
+				Classname: s.File,
+				Time:      junitSeconds(0),
+				Error:     &junitMessage{Message: "failed to load test suite", Text: s.Err.Error()},
+			})
+		}
+		for _, t := range s.Tests {
+			tc := junitTestCase{Name: t.Name, Classname: s.File, Time: junitSeconds(t.Duration)}
+			switch {
+			case t.Skipped:
+				suite.Skipped++
+				tc.Skipped = &junitMessage{}
+			case !t.passed():
+				suite.Failures++
+				tc.Failure = &junitMessage{
+					Message: fmt.Sprintf("%d assertion(s) failed", len(t.Failures)),
+					Text:    strings.Join(t.Failures, "\n"),
+				}
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		total += s.Duration
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = junitSeconds(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create JUnit report directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(file, append([]byte(xml.Header), append(data, '\n')...), 0o644); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// normalizeValue converts a value from a test file into the same JSON types
+// the rendered documents use, so they can be compared with reflect.DeepEqual.
+func normalizeValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,368 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unitTestAssert is one helm-unittest assertion: a single key naming the
+// assertion type (equal, isKind, ...) holding its parameters, plus the
+// optional not, template and documentIndex modifiers.
+type unitTestAssert struct {
+	Type          string
+	Not           bool
+	Template      string
+	DocumentIndex *int
+	Params        map[string]interface{}
+}
+
+// negatedAssertions maps helm-unittest's negated assertion names to the
+// assertion they invert.
+var negatedAssertions = map[string]string{
+	"notEqual":          "equal",
+	"isNotNull":         "isNull",
+	"isNotEmpty":        "isEmpty",
+	"notExists":         "exists",
+	"notContains":       "contains",
+	"notMatchRegex":     "matchRegex",
+	"isNotSubset":       "isSubset",
+	"notFailedTemplate": "failedTemplate",
+}
+
+func (a *unitTestAssert) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for key, value := range raw {
+		switch key {
+		case "not":
+			if err := json.Unmarshal(value, &a.Not); err != nil {
+				return fmt.Errorf("invalid not: %w", err)
+			}
+		case "template":
+			if err := json.Unmarshal(value, &a.Template); err != nil {
+				return fmt.Errorf("invalid template: %w", err)
+			}
+		case "documentIndex":
+			if err := json.Unmarshal(value, &a.DocumentIndex); err != nil {
+				return fmt.Errorf("invalid documentIndex: %w", err)
+			}
+		default:
+			if a.Type != "" {
+				return fmt.Errorf("assertion has more than one type: %s and %s", a.Type, key)
+			}
+			a.Type = key
+			if string(value) != "null" {
+				if err := json.Unmarshal(value, &a.Params); err != nil {
+					return fmt.Errorf("invalid %s parameters: %w", key, err)
+				}
+			}
+		}
+	}
+	if a.Type == "" {
+		return fmt.Errorf("assertion has no type")
+	}
+	return nil
+}
+
+// evaluate checks the assertion against the selected documents (or the
+// render error) and returns a failure message, or "" when it holds.
+func (a unitTestAssert) evaluate(docs []map[string]interface{}, renderErr error) string {
+	kind, not := a.Type, a.Not
+	if base, ok := negatedAssertions[kind]; ok {
+		kind, not = base, !not
+	}
+
+	if kind == "failedTemplate" {
+		ok, msg := assertFailedTemplate(a.Params, renderErr)
+		return outcome(ok, not, msg)
+	}
+	if renderErr != nil {
+		return fmt.Sprintf("chart failed to render: %v", renderErr)
+	}
+
+	if kind == "hasDocuments" {
+		count, err := intParam(a.Params, "count")
+		if err != nil {
+			return err.Error()
+		}
+		return outcome(len(docs) == count, not, fmt.Sprintf("expected %d documents, got %d", count, len(docs)))
+	}
+
+	if len(docs) == 0 {
+		return "no documents rendered for the selected templates"
+	}
+	for i, doc := range docs {
+		ok, msg, err := assertDocument(kind, a.Params, doc)
+		if err != nil {
+			return err.Error()
+		}
+		if res := outcome(ok, not, msg); res != "" {
+			return fmt.Sprintf("document %d: %s", i, res)
+		}
+	}
+	return ""
+}
+
+// outcome applies negation to an assertion result.
+func outcome(ok, not bool, msg string) string {
+	if ok != not {
+		return ""
+	}
+	if not {
+		return "expected NOT: " + msg
+	}
+	return msg
+}
+
+func assertDocument(kind string, params map[string]interface{}, doc map[string]interface{}) (bool, string, error) {
+	switch kind {
+	case "isKind", "isAPIVersion":
+		field := map[string]string{"isKind": "kind", "isAPIVersion": "apiVersion"}[kind]
+		want, err := stringParam(params, "of")
+		if err != nil {
+			return false, "", err
+		}
+		got := doc[field]
+		return got == want, fmt.Sprintf("expected %s %q, got %v", field, want, got), nil
+	}
+
+	p, err := stringParam(params, "path")
+	if err != nil {
+		return false, "", err
+	}
+	value, found, err := lookupPath(doc, p)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch kind {
+	case "equal":
+		want := normalizeValue(params["value"])
+		return found && reflect.DeepEqual(value, want), fmt.Sprintf("expected %s to equal %v, got %v", p, describe(want), describe(value)), nil
+	case "isNull":
+		return value == nil, fmt.Sprintf("expected %s to be null, got %v", p, describe(value)), nil
+	case "exists":
+		return found, fmt.Sprintf("expected %s to exist", p), nil
+	case "isEmpty":
+		return isEmptyValue(value), fmt.Sprintf("expected %s to be empty, got %v", p, describe(value)), nil
+	case "matchRegex":
+		pattern, err := stringParam(params, "pattern")
+		if err != nil {
+			return false, "", err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return false, fmt.Sprintf("expected %s to be a string, got %v", p, describe(value)), nil
+		}
+		return re.MatchString(s), fmt.Sprintf("expected %s (%q) to match %q", p, s, pattern), nil
+	case "contains":
+		list, ok := value.([]interface{})
+		if !ok {
+			return false, fmt.Sprintf("expected %s to be a list, got %v", p, describe(value)), nil
+		}
+		want := normalizeValue(params["content"])
+		anyMatch, _ := params["any"].(bool)
+		count := 0
+		for _, item := range list {
+			if reflect.DeepEqual(item, want) || (anyMatch && isSubset(item, want)) {
+				count++
+			}
+		}
+		if c, err := intParam(params, "count"); err == nil {
+			return count == c, fmt.Sprintf("expected %s to contain %v %d time(s), found %d", p, describe(want), c, count), nil
+		}
+		return count > 0, fmt.Sprintf("expected %s to contain %v", p, describe(want)), nil
+	case "isSubset":
+		want := normalizeValue(params["content"])
+		return isSubset(value, want), fmt.Sprintf("expected %s to contain %v", p, describe(want)), nil
+	case "lengthEqual":
+		want, err := intParam(params, "count")
+		if err != nil {
+			return false, "", err
+		}
+		length := -1
+		switch v := value.(type) {
+		case []interface{}:
+			length = len(v)
+		case map[string]interface{}:
+			length = len(v)
+		}
+		return length == want, fmt.Sprintf("expected %s to have length %d, got %d", p, want, length), nil
+	}
+	return false, "", fmt.Errorf("unsupported assertion %q", kind)
+}
+
+func assertFailedTemplate(params map[string]interface{}, renderErr error) (bool, string) {
+	if renderErr == nil {
+		return false, "expected the chart to fail rendering, but it rendered"
+	}
+	if msg, ok := params["errorMessage"].(string); ok && msg != "" {
+		return strings.Contains(renderErr.Error(), msg), fmt.Sprintf("expected render error containing %q, got %q", msg, renderErr.Error())
+	}
+	if pattern, ok := params["errorPattern"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Sprintf("invalid errorPattern %q: %v", pattern, err)
+		}
+		return re.MatchString(renderErr.Error()), fmt.Sprintf("expected render error matching %q, got %q", pattern, renderErr.Error())
+	}
+	return true, fmt.Sprintf("chart failed to render: %v", renderErr)
+}
+
+func stringParam(params map[string]interface{}, name string) (string, error) {
+	s, ok := params[name].(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("missing %q parameter", name)
+	}
+	return s, nil
+}
+
+func intParam(params map[string]interface{}, name string) (int, error) {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	}
+	return 0, fmt.Errorf("missing %q parameter", name)
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+// isSubset reports whether every key of want is present in got with an
+// equal (or, for maps, recursively contained) value.
+func isSubset(got, want interface{}) bool {
+	wantMap, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(got, want)
+	}
+	gotMap, ok := got.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for k, wv := range wantMap {
+		gv, ok := gotMap[k]
+		if !ok || !isSubset(gv, wv) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// parseValuePath splits a helm-unittest path such as
+// spec.containers[0].env or metadata.labels["app.kubernetes.io/name"] into
+// map keys (string) and list indexes (int).
+func parseValuePath(p string) ([]interface{}, error) {
+	var tokens []interface{}
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '\\':
+			if i+1 < len(p) {
+				i++
+				cur.WriteByte(p[i])
+			}
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in path %q", p)
+			}
+			inner := p[i+1 : i+end]
+			i += end
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				tokens = append(tokens, inner[1:len(inner)-1])
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index [%s] in path %q", inner, p)
+			}
+			tokens = append(tokens, idx)
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return tokens, nil
+}
+
+// lookupPath resolves a helm-unittest path in a rendered document.
+func lookupPath(doc map[string]interface{}, p string) (interface{}, bool, error) {
+	tokens, err := parseValuePath(p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cur interface{} = doc
+	for _, tok := range tokens {
+		switch key := tok.(type) {
+		case string:
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, false, nil
+			}
+		case int:
+			list, ok := cur.([]interface{})
+			if !ok || key < 0 || key >= len(list) {
+				return nil, false, nil
+			}
+			cur = list[key]
+		}
+	}
+	return cur, true, nil
+}
@@ -0,0 +1,143 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestChart creates a minimal chart with a deployment template and the
+// given test suite under tests/.
+func writeTestChart(t *testing.T, suite string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "app")
+	files := map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"values.yaml": "replicaCount: 1\nimage:\n  repository: nginx\n  tag: \"1.25\"\n",
+		"templates/deployment.yaml": `{{- if not .Values.image.repository }}{{ fail "image.repository is required" }}{{ end -}}
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-app
+  labels:
+    app.kubernetes.io/name: app
+spec:
+  replicas: {{ .Values.replicaCount }}
+  template:
+    spec:
+      containers:
+        - name: app
+          image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+`,
+		"tests/deployment_test.yaml": suite,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunUnitTests(t *testing.T) {
+	chartDir := writeTestChart(t, `suite: deployment
+templates:
+  - deployment.yaml
+tests:
+  - it: renders defaults
+    asserts:
+      - isKind:
+          of: Deployment
+      - hasDocuments:
+          count: 1
+      - equal:
+          path: spec.replicas
+          value: 1
+      - equal:
+          path: metadata.labels["app.kubernetes.io/name"]
+          value: app
+      - matchRegex:
+          path: spec.template.spec.containers[0].image
+          pattern: ^nginx:1\.25$
+  - it: honours set
+    set:
+      image.tag: "2.0"
+      replicaCount: 3
+    release:
+      name: prod
+    asserts:
+      - equal:
+          path: spec.replicas
+          value: 3
+      - equal:
+          path: metadata.name
+          value: prod-app
+      - notEqual:
+          path: spec.template.spec.containers[0].image
+          value: nginx:1.25
+  - it: fails without a repository
+    set:
+      image.repository: ""
+    asserts:
+      - failedTemplate:
+          errorMessage: image.repository is required
+  - it: reports a wrong expectation
+    asserts:
+      - equal:
+          path: spec.replicas
+          value: 2
+`)
+
+	results, err := runUnitTests(chartDir, UnitTestOptions{})
+	if err != nil {
+		t.Fatalf("runUnitTests: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("results = %+v", results)
+	}
+
+	tests := results[0].Tests
+	for _, tc := range tests[:3] {
+		if !tc.passed() {
+			t.Errorf("%q failed: %v", tc.Name, tc.Failures)
+		}
+	}
+	if tests[3].passed() || !strings.Contains(tests[3].Failures[0], "expected spec.replicas to equal 2") {
+		t.Errorf("wrong expectation should fail with a clear message, got %v", tests[3].Failures)
+	}
+
+	report := filepath.Join(t.TempDir(), "junit.xml")
+	if err := writeJUnitReport(report, results); err != nil {
+		t.Fatalf("writeJUnitReport: %v", err)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<testsuite name="deployment" tests="4" failures="1"`, `<testcase name="renders defaults"`, "<failure "} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JUnit report missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestParseValuePath(t *testing.T) {
+	got, err := parseValuePath(`spec.containers[0].env["MY.VAR"].value`)
+	if err != nil {
+		t.Fatalf("parseValuePath: %v", err)
+	}
+	want := []interface{}{"spec", "containers", 0, "env", "MY.VAR", "value"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseValuePath = %#v, want %#v", got, want)
+	}
+
+	if _, err := parseValuePath("a[0"); err == nil {
+		t.Error("expected an error for an unclosed index")
+	}
+}
@@ -10,12 +10,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/internal/ci"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/strvals"
 	corev1 "k8s.io/api/core/v1"
@@ -53,9 +54,9 @@ func HelmUpgrade(
 		pterm.Printf("Timeout: %v\n", timeout)
 		pterm.Printf("Wait: %t\n", wait)
 		pterm.Printf("Force: %t (Helm native flag)\n", force)
-		pterm.Printf("Set values: %v\n", setValues)
+		pterm.Printf("Set values: %v\n", redactSets(setValues))
 		pterm.Printf("Values files: %v\n", valuesFiles)
-		pterm.Printf("Set literal: %v\n", setLiteral)
+		pterm.Printf("Set literal: %v\n", redactSets(setLiteral))
 		pterm.Printf("Repo URL: %s\n", repoURL)
 		pterm.Printf("Version: %s\n", version)
 		pterm.Printf("History Max: %d\n", historyMax)
@@ -104,12 +105,52 @@ func HelmUpgrade(
 
 	// Load and merge values
 	fmt.Printf("📝 Processing values and configurations...\n")
-	vals, err := loadAndMergeValuesWithSets(valuesFiles, setValues, setLiteral, debug)
+	vals, layers, err := loadValueLayers(namespace, valuesFiles, setValues, setLiteral, debug)
 	if err != nil {
 		printErrorSummary("failed to load values", releaseName, namespace, chartRef, err)
 		ai.AIExplainError(useAI, err.Error())
 		return fmt.Errorf("failed to load values: %w", err)
 	}
+	if configs.MigrateValues {
+		migration, err := migrateStoredValues(actionConfig, releaseName, chart)
+		if err != nil {
+			printErrorSummary("values migration failed", releaseName, namespace, chartRef, err)
+			ai.AIExplainError(useAI, err.Error())
+			return err
+		}
+		if migration != nil {
+			if err := PrintValuesMigrationPreview(migration, "table"); err != nil {
+				return err
+			}
+			vals = mergeValues(migration.Values, vals, nil, configs.ListMergePaths)
+		}
+	}
+	if configs.ExplainValues {
+		if err := printValueOrigins(chart, vals, layers); err != nil {
+			return err
+		}
+	}
+
+	if err := checkValuesSchema(chart, vals); err != nil {
+		printErrorSummary("values schema validation failed", releaseName, namespace, chartRef, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	if err := applyHookSelection(actionConfig, chart, releaseName, namespace, vals, true); err != nil {
+		printErrorSummary("hook selection failed", releaseName, namespace, chartRef, err)
+		ai.AIExplainError(useAI, err.Error())
+		return err
+	}
+
+	// Keep a copy of the release the upgrade replaces off the cluster.
+	if last, err := actionConfig.Releases.Last(releaseName); err == nil {
+		if err := backupRelease(last); err != nil {
+			printErrorSummary("release backup failed", releaseName, namespace, chartRef, err)
+			ai.AIExplainError(useAI, err.Error())
+			return err
+		}
+	}
 
 	// Create upgrade client
 	fmt.Printf("🛠️  Setting up upgrade action...\n")
@@ -124,8 +165,10 @@ func HelmUpgrade(
 
 	client.CleanupOnFail = true // This is key for atomic!
 	client.SubNotes = true      // Better output
-	client.DisableHooks = false // Ensure hooks run
+	client.DisableHooks = configs.NoHooks
+	client.SkipSchemaValidation = configs.SkipSchemaValidation
 	client.DryRun = false
+	client.PostRenderer = schedulingRenderer()
 	client.ResetValues = false
 	client.ReuseValues = false
 	client.Recreate = false
@@ -153,6 +196,15 @@ func HelmUpgrade(
 		defer podMonitor.stop()
 	}
 
+	events := startReleaseEventWatcher(namespace, releaseName, debug)
+	defer events.stop()
+
+	// Keep the manifest the upgrade replaces for the change summary.
+	previous, prevErr := actionConfig.Releases.Deployed(releaseName)
+	if prevErr != nil {
+		previous, prevErr = actionConfig.Releases.Last(releaseName)
+	}
+
 	// Run the upgrade
 	rel, err := client.Run(releaseName, chart, vals)
 	if err != nil {
@@ -172,6 +224,11 @@ func HelmUpgrade(
 
 	// Handle successful upgrade
 	fmt.Printf("\n✅ Upgrade completed successfully (took %s)\n", upgradeDuration)
+	if prevErr == nil && rel != nil {
+		printChangeSummary(summarizeChanges(previous.Manifest, rel.Manifest))
+	} else if debug && prevErr != nil {
+		pterm.Printf("Skipping change summary: previous revision not available: %v\n", prevErr)
+	}
 
 	// IMPORTANT: Wait for pods to settle before checking status
 	fmt.Printf("\n⏳ Waiting for pods to stabilize...\n")
@@ -195,7 +252,7 @@ func HelmUpgrade(
 
 	// Verify readiness only if wait is enabled
 	if wait {
-		readinessTimeout := 5 * time.Minute
+		readinessTimeout := configs.Timeouts.ReadinessTimeout()
 		if debug {
 			pterm.Printf("Waiting for resources to be ready (timeout: %v)\n", readinessTimeout)
 		}
@@ -207,6 +264,10 @@ func HelmUpgrade(
 		pterm.Println("Skipping readiness verification (wait=false)")
 	}
 
+	if err := ci.Export(ci.ReleaseVars(rel.Name, rel.Namespace, rel.Version)); err != nil {
+		pterm.Warning.Printfln("Could not export the release to later CI jobs: %v", err)
+	}
+
 	// Print total time
 	totalDuration := time.Since(startTime).Round(time.Second)
 	fmt.Printf("\n⏱️  Total upgrade time: %s\n", totalDuration)
@@ -236,7 +297,7 @@ func loadChart(chartRef, repoURL, version string, debug bool) (*chart.Chart, err
 		if debug {
 			pterm.Printf("Loading local chart from: %s\n", absPath)
 		}
-		return loader.Load(absPath)
+		return loadLocalChart(absPath, debug)
 	}
 
 	// Repo chart (repo/chart)
@@ -249,7 +310,7 @@ func loadChart(chartRef, repoURL, version string, debug bool) (*chart.Chart, err
 	if debug {
 		pterm.Printf("Fetching chart %s from repo (version=%s, repo=%s)\n", chartRef, version, repoURL)
 	}
-	cp, err := chartPathOptions.LocateChart(chartRef, settings)
+	cp, err := locateChart(chartRef, chartPathOptions, settings, debug)
 	if err != nil {
 		return nil, fmt.Errorf("failed to locate chart %s: %w", chartRef, err)
 	}
@@ -262,19 +323,7 @@ func loadChart(chartRef, repoURL, version string, debug bool) (*chart.Chart, err
 }
 
 func initActionConfig(namespace string, debug bool) (*action.Configuration, error) {
-	settings := cli.New()
-	settings.SetNamespace(namespace)
-
-	// IMPORTANT: Set KubeContext if provided
-	if kubeContext := os.Getenv("KUBECONTEXT"); kubeContext != "" {
-		settings.KubeContext = kubeContext
-	}
-
-	// Set kubeconfig explicitly
-	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
-		settings.KubeConfig = kubeconfig
-	}
-
+	settings := newSettings(namespace)
 	actionConfig := new(action.Configuration)
 
 	logFn := func(format string, v ...interface{}) {
@@ -356,7 +405,22 @@ func verifyReleaseExists(actionConfig *action.Configuration, releaseName, namesp
 	return nil
 }
 
-func loadAndMergeValuesWithSets(valuesFiles, setValues, setLiteralValues []string, debug bool) (map[string]interface{}, error) {
+func loadAndMergeValuesWithSets(namespace string, valuesFiles, setValues, setLiteralValues []string, debug bool) (map[string]interface{}, error) {
+	vals, _, err := loadValueLayers(namespace, valuesFiles, setValues, setLiteralValues, debug)
+	return vals, err
+}
+
+// valueLayer is one source of user-supplied values, with the values it
+// sets by their dotted key path.
+type valueLayer struct {
+	Source string
+	Leaves map[string]interface{}
+}
+
+// loadValueLayers merges the values of a release from namespace defaults,
+// values files, --values-from documents, --set and --set-literal, in that
+// order, and also returns each of those sources on its own.
+func loadValueLayers(namespace string, valuesFiles, setValues, setLiteralValues []string, debug bool) (map[string]interface{}, []valueLayer, error) {
 	if debug {
 		pterm.Printf("Loading values from %d files\n", len(valuesFiles))
 		pterm.Printf("Applying %d set values\n", len(setValues))
@@ -365,53 +429,85 @@ func loadAndMergeValuesWithSets(valuesFiles, setValues, setLiteralValues []strin
 
 	resolvedFiles, err := resolveValuesPaths(valuesFiles, debug)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	in := newInterpolator()
+	if setValues, err = interpolateSets(setValues, in); err != nil {
+		return nil, nil, err
+	}
+	if setLiteralValues, err = interpolateSets(setLiteralValues, in); err != nil {
+		return nil, nil, err
 	}
 
-	vals := make(map[string]interface{})
+	// Namespace defaults (the smurf-defaults ConfigMap) are the base every
+	// user-supplied value overrides.
+	var layers []valueLayer
+	vals, err := loadNamespaceDefaults(namespace, debug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if vals == nil {
+		vals = make(map[string]interface{})
+	} else {
+		layers = append(layers, newValueLayer("namespace defaults "+namespaceDefaultsName, vals))
+	}
 	for i, f := range resolvedFiles {
 		if debug {
 			pterm.Printf("Reading values file %d: %s\n", i+1, f)
 		}
-		currentVals, err := chartutil.ReadValuesFile(f)
+		currentVals, err := readValuesFile(f, in)
 		if err != nil {
 			if debug {
 				pterm.Printf("Error reading values file: %v\n", err)
 			}
-			return nil, fmt.Errorf("failed to read %s: %w", f, err)
+			return nil, nil, fmt.Errorf("failed to read %s: %w", f, err)
 		}
-		vals = mergeMaps(vals, currentVals)
+		vals = mergeValues(vals, currentVals, nil, configs.ListMergePaths)
+		layers = append(layers, newValueLayer(valuesFiles[i], currentVals))
+	}
+
+	// In-cluster values (--values-from) override the files, like a later -f.
+	clusterVals, err := loadValuesFrom(debug)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, cv := range clusterVals {
+		vals = mergeValues(vals, cv.vals, nil, configs.ListMergePaths)
+		layers = append(layers, newValueLayer(cv.source, cv.vals))
 	}
 
 	for i, set := range setValues {
 		if debug {
-			pterm.Printf("Applying set value %d: %s\n", i+1, set)
+			pterm.Printf("Applying set value %d: %s\n", i+1, redactSets([]string{set})[0])
 		}
 		if err := strvals.ParseInto(set, vals); err != nil {
 			if debug {
 				pterm.Printf("Error parsing set value: %v\n", err)
 			}
-			return nil, fmt.Errorf("invalid --set value %s: %w", set, err)
+			return nil, nil, fmt.Errorf("invalid --set value %s: %w", redactSets([]string{set})[0], err)
 		}
+		layers = append(layers, setLayer("--set", set, strvals.ParseInto))
 	}
 
 	for i, setLiteral := range setLiteralValues {
 		if debug {
-			pterm.Printf("Applying literal value %d: %s\n", i+1, setLiteral)
+			pterm.Printf("Applying literal value %d: %s\n", i+1, redactSets([]string{setLiteral})[0])
 		}
 		if err := strvals.ParseIntoString(setLiteral, vals); err != nil {
 			if debug {
 				pterm.Printf("Error parsing literal value: %v\n", err)
 			}
-			return nil, fmt.Errorf("invalid --set-literal value %s: %w", setLiteral, err)
+			return nil, nil, fmt.Errorf("invalid --set-literal value %s: %w", redactSets([]string{setLiteral})[0], err)
 		}
+		layers = append(layers, setLayer("--set-literal", setLiteral, strvals.ParseIntoString))
 	}
 
 	if debug {
 		pterm.Println("All values processed successfully")
 	}
 
-	return vals, nil
+	return vals, layers, nil
 }
 
 func resolveValuesPaths(valuesFiles []string, debug bool) ([]string, error) {
@@ -464,7 +560,11 @@ func verifyFinalReadiness(namespace, releaseName string, timeout time.Duration,
 		pterm.Printf("Verifying readiness with timeout: %v\n", timeout)
 	}
 
-	deadline := time.Now().Add(timeout)
+	// Each kind waits for its own progress deadline, timeout for the kinds
+	// smurf.yaml doesn't set one for.
+	deadlines := newProgressDeadlines(timeout)
+	start := time.Now()
+	deadline := start.Add(deadlines.longest())
 	pollInterval := 5 * time.Second
 
 	clientset, err := getKubeClient()
@@ -477,7 +577,7 @@ func verifyFinalReadiness(namespace, releaseName string, timeout time.Duration,
 			// Provide detailed timeout information
 			pods, _ := getPods(namespace, releaseName)
 			return fmt.Errorf("readiness verification timed out after %s. %d pods found. Check pod logs for details",
-				timeout, len(pods))
+				deadlines.longest(), len(pods))
 		}
 
 		if debug {
@@ -485,7 +585,7 @@ func verifyFinalReadiness(namespace, releaseName string, timeout time.Duration,
 		}
 
 		// Check deployments, statefulsets, daemonsets first
-		allWorkloadsReady, workloadStatus, err := checkWorkloadReadiness(clientset, namespace, releaseName, debug)
+		notReady, workloadStatus, err := checkWorkloadReadiness(clientset, namespace, releaseName, debug)
 		if err != nil {
 			if debug {
 				pterm.Printf("Error checking workloads: %v\n", err)
@@ -494,7 +594,11 @@ func verifyFinalReadiness(namespace, releaseName string, timeout time.Duration,
 			continue // Retry on API errors
 		}
 
-		if !allWorkloadsReady {
+		if len(notReady) > 0 {
+			if kind, ok := deadlines.overdue(notReady, time.Since(start)); ok {
+				return fmt.Errorf("%s resources exceeded their progress deadline of %s: %s",
+					kind, deadlines[kind], workloadStatus)
+			}
 			if debug {
 				pterm.Printf("Workloads not ready: %s\n", workloadStatus)
 			}
@@ -551,22 +655,27 @@ func verifyFinalReadiness(namespace, releaseName string, timeout time.Duration,
 		time.Sleep(pollInterval)
 	}
 }
-func checkWorkloadReadiness(clientset *kubernetes.Clientset, namespace, releaseName string, debug bool) (bool, string, error) {
+
+// checkWorkloadReadiness returns the kinds of the release's workloads that
+// are not ready and, for each, the first of its workloads that isn't.
+func checkWorkloadReadiness(clientset *kubernetes.Clientset, namespace, releaseName string, debug bool) ([]string, string, error) {
 	labelSelector := fmt.Sprintf(appKubernets, releaseName)
+	var notReady, statuses []string
 
 	// Check Deployments
 	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
-		return false, "", err
+		return nil, "", err
 	}
 
 	for _, dep := range deployments.Items {
 		if dep.Status.ReadyReplicas != *dep.Spec.Replicas {
-			status := fmt.Sprintf("Deployment/%s: %d/%d ready",
-				dep.Name, dep.Status.ReadyReplicas, *dep.Spec.Replicas)
-			return false, status, nil
+			notReady = append(notReady, "Deployment")
+			statuses = append(statuses, fmt.Sprintf("Deployment/%s: %d/%d ready",
+				dep.Name, dep.Status.ReadyReplicas, *dep.Spec.Replicas))
+			break
 		}
 	}
 
@@ -575,14 +684,15 @@ func checkWorkloadReadiness(clientset *kubernetes.Clientset, namespace, releaseN
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
-		return false, "", err
+		return nil, "", err
 	}
 
 	for _, ss := range statefulsets.Items {
 		if ss.Status.ReadyReplicas != *ss.Spec.Replicas {
-			status := fmt.Sprintf("StatefulSet/%s: %d/%d ready",
-				ss.Name, ss.Status.ReadyReplicas, *ss.Spec.Replicas)
-			return false, status, nil
+			notReady = append(notReady, "StatefulSet")
+			statuses = append(statuses, fmt.Sprintf("StatefulSet/%s: %d/%d ready",
+				ss.Name, ss.Status.ReadyReplicas, *ss.Spec.Replicas))
+			break
 		}
 	}
 
@@ -591,18 +701,22 @@ func checkWorkloadReadiness(clientset *kubernetes.Clientset, namespace, releaseN
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
-		return false, "", err
+		return nil, "", err
 	}
 
 	for _, ds := range daemonsets.Items {
 		if ds.Status.NumberReady != ds.Status.DesiredNumberScheduled {
-			status := fmt.Sprintf("DaemonSet/%s: %d/%d ready",
-				ds.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
-			return false, status, nil
+			notReady = append(notReady, "DaemonSet")
+			statuses = append(statuses, fmt.Sprintf("DaemonSet/%s: %d/%d ready",
+				ds.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled))
+			break
 		}
 	}
 
-	return true, "all workloads ready", nil
+	if len(notReady) == 0 {
+		return nil, "all workloads ready", nil
+	}
+	return notReady, strings.Join(statuses, "; "), nil
 }
 
 func checkPodReadiness(pods []corev1.Pod, debug bool) (bool, []string) {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/pterm/pterm"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -32,8 +33,12 @@ type upgradePodMonitor struct {
 	snapshots   map[string]*corev1.Pod
 	diagnostics map[string]*cachedPodDiagnostics
 	seenPods    map[string]bool
-	stopCh      chan struct{}
-	doneCh      chan struct{}
+	// rollouts is the last progress line printed per Deployment, and
+	// stalled the stalled rollouts seen, by Deployment name.
+	rollouts map[string]string
+	stalled  map[string]stalledRollout
+	stopCh   chan struct{}
+	doneCh   chan struct{}
 }
 
 func newUpgradePodMonitor(namespace, releaseName string, debug bool) (*upgradePodMonitor, error) {
@@ -50,6 +55,8 @@ func newUpgradePodMonitor(namespace, releaseName string, debug bool) (*upgradePo
 		snapshots:   make(map[string]*corev1.Pod),
 		diagnostics: make(map[string]*cachedPodDiagnostics),
 		seenPods:    make(map[string]bool),
+		rollouts:    make(map[string]string),
+		stalled:     make(map[string]stalledRollout),
 		stopCh:      make(chan struct{}),
 		doneCh:      make(chan struct{}),
 	}, nil
@@ -85,6 +92,8 @@ func (m *upgradePodMonitor) stop() {
 }
 
 func (m *upgradePodMonitor) poll() {
+	m.pollRollouts()
+
 	pods, err := getPods(m.namespace, m.releaseName)
 	if err != nil {
 		if m.debug {
@@ -127,6 +136,62 @@ func (m *upgradePodMonitor) poll() {
 	}
 }
 
+// pollRollouts prints a progress line for each Deployment of the release
+// whose rollout moved since the last poll, warning when it exceeds its
+// maxUnavailable or maxSurge budget, and captures the ReplicaSet events of
+// a rollout that stalls.
+func (m *upgradePodMonitor) pollRollouts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deployments, err := listReleaseDeployments(ctx, m.clientset, m.namespace, m.releaseName)
+	if err != nil {
+		if m.debug {
+			pterm.Debug.Printf("upgrade monitor: failed to list deployments: %v\n", err)
+		}
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range deployments {
+		d := &deployments[i]
+		p := deploymentRolloutProgress(d)
+		violations := p.violations()
+		line := p.String()
+		if len(violations) > 0 {
+			line += " — " + strings.Join(violations, "; ")
+		}
+		previous, seen := m.rollouts[d.Name]
+		m.rollouts[d.Name] = line
+		if line == previous || (!seen && p.done()) {
+			continue
+		}
+		if len(violations) > 0 {
+			pterm.Warning.Println(line)
+		} else {
+			pterm.Info.Println(line)
+		}
+
+		if _, ok := m.stalled[d.Name]; p.Stalled && !ok {
+			m.stalled[d.Name] = captureStalledRollout(ctx, m.clientset, d, p)
+			pterm.Error.Printf("%s: rollout stalled: %s\n", d.Name, p.StallMessage)
+		}
+	}
+}
+
+// stalledRollouts returns the stalled rollouts seen, by Deployment name.
+func (m *upgradePodMonitor) stalledRollouts() map[string]stalledRollout {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]stalledRollout, len(m.stalled))
+	for name, s := range m.stalled {
+		out[name] = s
+	}
+	return out
+}
+
 func (m *upgradePodMonitor) failedSnapshots() []failedPodSnapshot {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -307,7 +372,7 @@ func printUpgradeFailureDiagnostics(namespace, releaseName string, monitor *upgr
 		}
 	}
 
-	printDeploymentRolloutStatus(clientset, namespace, releaseName)
+	printDeploymentRolloutStatus(clientset, namespace, releaseName, monitor.stalledRollouts())
 
 	if len(snapshots) == 0 {
 		printDiagnosticsSubSection("Failed Resource Details")
@@ -341,16 +406,19 @@ func printUpgradeFailureDiagnostics(namespace, releaseName string, monitor *upgr
 	fmt.Println(strings.Repeat("═", 80))
 }
 
-func printDeploymentRolloutStatus(clientset *kubernetes.Clientset, namespace, releaseName string) {
-	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: fmt.Sprintf(appKubernets, releaseName),
-	})
-	if err != nil || len(deployments.Items) == 0 {
+// printDeploymentRolloutStatus prints the rollout of each Deployment of the
+// release against its strategy budget. A stalled rollout comes with the
+// events of its new ReplicaSet: read now, or as stalled captured them before
+// an atomic rollback removed it.
+func printDeploymentRolloutStatus(clientset *kubernetes.Clientset, namespace, releaseName string, stalled map[string]stalledRollout) {
+	ctx := context.Background()
+	deployments, err := listReleaseDeployments(ctx, clientset, namespace, releaseName)
+	if err != nil || len(deployments) == 0 {
 		return
 	}
 
 	printDiagnosticsSubSection("Deployment Rollout Status")
-	for _, dep := range deployments.Items {
+	for _, dep := range deployments {
 		replicas := int32(0)
 		if dep.Spec.Replicas != nil {
 			replicas = *dep.Spec.Replicas
@@ -360,13 +428,29 @@ func printDeploymentRolloutStatus(clientset *kubernetes.Clientset, namespace, re
 		fmt.Printf("    Updated   : %d\n", dep.Status.UpdatedReplicas)
 		fmt.Printf("    Available : %d\n", dep.Status.AvailableReplicas)
 
+		p := deploymentRolloutProgress(&dep)
+		if p.Strategy == appsv1.RecreateDeploymentStrategyType {
+			fmt.Printf("    Strategy  : Recreate\n")
+		} else {
+			fmt.Printf("    Strategy  : RollingUpdate (maxUnavailable %d, maxSurge %d)\n", p.MaxUnavailable, p.MaxSurge)
+		}
+		for _, v := range p.violations() {
+			pterm.Warning.Printf("    Budget    : %s\n", v)
+		}
+		if p.Stalled {
+			printStalledRollout(captureStalledRollout(ctx, clientset, &dep, p))
+		} else if s, ok := stalled[dep.Name]; ok {
+			pterm.Warning.Println("    Note      : Rollout stalled and was rolled back — showing captured state")
+			printStalledRollout(s)
+		}
+
 		for _, cond := range dep.Status.Conditions {
 			if cond.Status != corev1.ConditionTrue && cond.Message != "" {
 				pterm.Warning.Printf("    Condition : %s (%s) — %s\n", cond.Type, cond.Reason, cond.Message)
 			}
 		}
 
-		events, evtErr := clientset.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{
+		events, evtErr := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
 			FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Deployment", dep.Name),
 		})
 		if evtErr == nil && len(events.Items) > 0 {
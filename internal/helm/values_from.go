@@ -0,0 +1,192 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/pterm/pterm"
+	"helm.sh/helm/v3/pkg/chartutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// valuesSource is a YAML values document stored in the cluster, given as
+// --values-from configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY.
+type valuesSource struct {
+	Kind      string // configmap or secret
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (s valuesSource) String() string {
+	return fmt.Sprintf("%s/%s/%s:%s", s.Kind, s.Namespace, s.Name, s.Key)
+}
+
+func parseValuesSource(ref string) (valuesSource, error) {
+	path, key, ok := strings.Cut(ref, ":")
+	parts := strings.Split(path, "/")
+	if !ok || key == "" || len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return valuesSource{}, fmt.Errorf("invalid --values-from %q: want configmap/NAMESPACE/NAME:KEY or secret/NAMESPACE/NAME:KEY", ref)
+	}
+	kind := strings.ToLower(parts[0])
+	if kind != "configmap" && kind != "secret" {
+		return valuesSource{}, fmt.Errorf("invalid --values-from %q: kind must be configmap or secret, not %q", ref, parts[0])
+	}
+	return valuesSource{Kind: kind, Namespace: parts[1], Name: parts[2], Key: key}, nil
+}
+
+// sourcedValues is a values document and where it was read from.
+type sourcedValues struct {
+	source string
+	vals   map[string]interface{}
+}
+
+// loadValuesFrom reads the values documents of configs.ValuesFrom from the
+// cluster, in order, later sources overriding earlier ones.
+func loadValuesFrom(debug bool) ([]sourcedValues, error) {
+	if len(configs.ValuesFrom) == 0 {
+		return nil, nil
+	}
+	sources := make([]valuesSource, 0, len(configs.ValuesFrom))
+	for _, ref := range configs.ValuesFrom {
+		src, err := parseValuesSource(ref)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	docs := make([]sourcedValues, 0, len(sources))
+	for _, src := range sources {
+		if debug {
+			pterm.Printf("Reading values from %s\n", src)
+		}
+		current, err := readValuesSource(ctx, clientset, src)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, sourcedValues{source: src.String(), vals: current})
+	}
+	return docs, nil
+}
+
+// namespaceDefaults is the ConfigMap, and its key, holding the default
+// values of every release installed into its namespace. Platform teams keep
+// cluster-specific settings there (ingress class, storage class) instead of
+// in every chart invocation.
+const (
+	namespaceDefaultsName = "smurf-defaults"
+	namespaceDefaultsKey  = "values.yaml"
+)
+
+// loadNamespaceDefaults reads the smurf-defaults values of namespace, unless
+// configs.NoNamespaceDefaults is set or namespace is empty, as it is for
+// client-only renders that may target another cluster than the current
+// context. A namespace without the ConfigMap has no defaults, and so does
+// one smurf may not read ConfigMaps in.
+func loadNamespaceDefaults(namespace string, debug bool) (map[string]interface{}, error) {
+	if configs.NoNamespaceDefaults || namespace == "" {
+		return nil, nil
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return namespaceDefaults(ctx, clientset, namespace, debug)
+}
+
+func namespaceDefaults(ctx context.Context, clientset kubernetes.Interface, namespace string, debug bool) (map[string]interface{}, error) {
+	src := valuesSource{Kind: "configmap", Namespace: namespace, Name: namespaceDefaultsName, Key: namespaceDefaultsKey}
+	vals, err := readValuesSource(ctx, clientset, src)
+	switch {
+	case apierrors.IsNotFound(err):
+		if debug {
+			pterm.Printf("No namespace defaults: %s not found\n", src)
+		}
+		return nil, nil
+	case apierrors.IsForbidden(err):
+		pterm.Warning.Printfln("Skipping namespace defaults: not allowed to read %s", src)
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	pterm.Info.Printfln("Applying namespace defaults from %s", src)
+	return vals, nil
+}
+
+// readValuesSource fetches and parses one in-cluster values document. The
+// strings of a Secret's values are registered as secrets, so they are masked
+// in error text sent to the AI provider like other credentials.
+func readValuesSource(ctx context.Context, clientset kubernetes.Interface, src valuesSource) (chartutil.Values, error) {
+	var data []byte
+	switch src.Kind {
+	case "configmap":
+		cm, err := clientset.CoreV1().ConfigMaps(src.Namespace).Get(ctx, src.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values from %s: %w", src, err)
+		}
+		if s, ok := cm.Data[src.Key]; ok {
+			data = []byte(s)
+		} else if b, ok := cm.BinaryData[src.Key]; ok {
+			data = b
+		} else {
+			return nil, fmt.Errorf("configmap %s/%s has no key %q", src.Namespace, src.Name, src.Key)
+		}
+	case "secret":
+		secret, err := clientset.CoreV1().Secrets(src.Namespace).Get(ctx, src.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values from %s: %w", src, err)
+		}
+		b, ok := secret.Data[src.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s has no key %q", src.Namespace, src.Name, src.Key)
+		}
+		data = b
+	}
+
+	vals, err := chartutil.ReadValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("values in %s are not valid YAML: %w", src, err)
+	}
+	if src.Kind == "secret" {
+		ai.RegisterSecrets(valueStrings(vals)...)
+	}
+	return vals, nil
+}
+
+// valueStrings returns the string leaves of a values tree.
+func valueStrings(v interface{}) []string {
+	var out []string
+	switch t := v.(type) {
+	case chartutil.Values:
+		for _, child := range t {
+			out = append(out, valueStrings(child)...)
+		}
+	case map[string]interface{}:
+		for _, child := range t {
+			out = append(out, valueStrings(child)...)
+		}
+	case []interface{}:
+		for _, child := range t {
+			out = append(out, valueStrings(child)...)
+		}
+	case string:
+		out = append(out, t)
+	}
+	return out
+}
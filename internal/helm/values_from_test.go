@@ -0,0 +1,104 @@
+package helm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestParseValuesSource(t *testing.T) {
+	got, err := parseValuesSource("ConfigMap/platform/env-config:values.yaml")
+	if err != nil {
+		t.Fatalf("parseValuesSource: %v", err)
+	}
+	want := valuesSource{Kind: "configmap", Namespace: "platform", Name: "env-config", Key: "values.yaml"}
+	if got != want {
+		t.Errorf("parseValuesSource = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{
+		"configmap/platform/env-config",
+		"configmap/env-config:values.yaml",
+		"secret//app:values.yaml",
+		"deployment/platform/web:values.yaml",
+		"secret/platform/app:",
+	} {
+		if _, err := parseValuesSource(bad); err == nil {
+			t.Errorf("parseValuesSource(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestReadValuesSource(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "env-config", Namespace: "platform"},
+			Data:       map[string]string{"values.yaml": "replicaCount: 3\ningress:\n  host: app.example.com\n"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-secrets", Namespace: "platform"},
+			Data:       map[string][]byte{"values.yaml": []byte("db:\n  password: s3cr3t-db-pass\n")},
+		},
+	)
+	ctx := context.Background()
+
+	vals, err := readValuesSource(ctx, clientset, valuesSource{Kind: "configmap", Namespace: "platform", Name: "env-config", Key: "values.yaml"})
+	if err != nil {
+		t.Fatalf("readValuesSource(configmap): %v", err)
+	}
+	want := map[string]interface{}{
+		"replicaCount": float64(3),
+		"ingress":      map[string]interface{}{"host": "app.example.com"},
+	}
+	if !reflect.DeepEqual(map[string]interface{}(vals), want) {
+		t.Errorf("configmap values = %v, want %v", vals, want)
+	}
+
+	if _, err := readValuesSource(ctx, clientset, valuesSource{Kind: "secret", Namespace: "platform", Name: "app-secrets", Key: "values.yaml"}); err != nil {
+		t.Fatalf("readValuesSource(secret): %v", err)
+	}
+	if got := ai.Redact("password is s3cr3t-db-pass"); got != "password is [REDACTED]" {
+		t.Errorf("secret value not registered for redaction: %q", got)
+	}
+
+	if _, err := readValuesSource(ctx, clientset, valuesSource{Kind: "configmap", Namespace: "platform", Name: "env-config", Key: "missing.yaml"}); err == nil {
+		t.Error("readValuesSource with a missing key succeeded, want an error")
+	}
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "smurf-defaults", Namespace: "apps"},
+		Data:       map[string]string{"values.yaml": "ingress:\n  className: nginx-internal\n"},
+	})
+	ctx := context.Background()
+
+	vals, err := namespaceDefaults(ctx, clientset, "apps", false)
+	if err != nil {
+		t.Fatalf("namespaceDefaults(apps): %v", err)
+	}
+	want := map[string]interface{}{"ingress": map[string]interface{}{"className": "nginx-internal"}}
+	if !reflect.DeepEqual(vals, want) {
+		t.Errorf("namespaceDefaults(apps) = %v, want %v", vals, want)
+	}
+
+	if vals, err := namespaceDefaults(ctx, clientset, "other", false); err != nil || vals != nil {
+		t.Errorf("namespaceDefaults(other) = %v, %v; want no defaults", vals, err)
+	}
+
+	clientset.PrependReactor("get", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "smurf-defaults", nil)
+	})
+	if vals, err := namespaceDefaults(ctx, clientset, "apps", false); err != nil || vals != nil {
+		t.Errorf("namespaceDefaults without RBAC = %v, %v; want no defaults", vals, err)
+	}
+}
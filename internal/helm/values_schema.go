@@ -0,0 +1,172 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// SchemaViolation is an effective value that breaks a values schema: the
+// chart's values.schema.json, a subchart's, or the --values-schema file.
+type SchemaViolation struct {
+	Schema  string `json:"schema"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// checkValuesSchema validates the values vals give chrt against the schemas
+// of the chart and its subcharts and against configs.ValuesSchema, and
+// prints the violations. It runs before Helm touches the cluster, so a bad
+// value fails with every violation listed rather than Helm's first error
+// at render time. configs.SkipSchemaValidation turns it off.
+func checkValuesSchema(chrt *chart.Chart, vals map[string]interface{}) error {
+	if configs.SkipSchemaValidation {
+		return nil
+	}
+	violations, err := validateValuesSchema(chrt, vals, configs.ValuesSchema)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	rows := [][]string{{"SCHEMA", "VALUE", "VIOLATION"}}
+	for _, v := range violations {
+		rows = append(rows, []string{v.Schema, v.Path, v.Message})
+	}
+	pterm.Error.Printfln("Values schema violations: %d", len(violations))
+	_ = pterm.DefaultTable.WithHasHeader().WithData(rows).Render()
+	return fmt.Errorf("values don't match the values schema: %d violation(s), first: %s: %s",
+		len(violations), violations[0].Path, violations[0].Message)
+}
+
+// validateValuesSchema returns the violations of the effective values of
+// chrt with vals, the chart's defaults included. schemaFile, when set, is
+// a JSON Schema every release of the chart must also meet. A chart schema
+// that can't be compiled, e.g. one with $refs to the network, is skipped
+// with a warning and left to Helm.
+func validateValuesSchema(chrt *chart.Chart, vals map[string]interface{}, schemaFile string) ([]SchemaViolation, error) {
+	effective, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge the values with the chart's: %w", err)
+	}
+
+	violations := []SchemaViolation{}
+	if schemaFile != "" {
+		data, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the values schema: %w", err)
+		}
+		abs, err := filepath.Abs(schemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", schemaFile, err)
+		}
+		found, err := schemaViolations(data, "file://"+filepath.ToSlash(abs), effective, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid values schema %s: %w", schemaFile, err)
+		}
+		for i := range found {
+			found[i].Schema = schemaFile
+		}
+		violations = append(violations, found...)
+	}
+	violations = append(violations, chartSchemaViolations(chrt, effective, "")...)
+	return violations, nil
+}
+
+// chartSchemaViolations validates vals against the values.schema.json of
+// chrt and of its subcharts, which see their own section of vals. prefix is
+// where vals sit in the parent's values.
+func chartSchemaViolations(chrt *chart.Chart, vals map[string]interface{}, prefix string) []SchemaViolation {
+	var violations []SchemaViolation
+	if chrt.Schema != nil {
+		found, err := schemaViolations(chrt.Schema, "file:///"+chrt.Name()+"/values.schema.json", vals, prefix)
+		if err != nil {
+			pterm.Warning.Printfln("Not checking the values schema of %s: %v", chrt.Name(), err)
+		}
+		for _, v := range found {
+			v.Schema = chrt.Name() + "/values.schema.json"
+			violations = append(violations, v)
+		}
+	}
+	for _, sub := range chrt.Dependencies() {
+		subVals, ok := vals[sub.Name()].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		violations = append(violations, chartSchemaViolations(sub, subVals, joinValuePath(prefix, sub.Name()))...)
+	}
+	return violations
+}
+
+// schemaViolations validates vals against the JSON Schema data, known as
+// url for its relative $refs. Paths of violations start with prefix.
+func schemaViolations(data []byte, url string, vals map[string]interface{}, prefix string) ([]SchemaViolation, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-trip through JSON so that numbers from YAML and --set are of
+	// the types the validator knows.
+	raw, err := json.Marshal(vals)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	err = schema.Validate(instance)
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		return leafViolations(ve, prefix), nil
+	}
+	return nil, err
+}
+
+// leafViolations flattens the causes of err to the violations that explain
+// it, e.g. the failing property rather than the object holding it.
+func leafViolations(err *jsonschema.ValidationError, prefix string) []SchemaViolation {
+	if len(err.Causes) == 0 {
+		path := prefix
+		for _, token := range err.InstanceLocation {
+			path = joinValuePath(path, token)
+		}
+		if path == "" {
+			path = "(root)"
+		}
+		return []SchemaViolation{{Path: path, Message: err.BasicOutput().Error.String()}}
+	}
+	var out []SchemaViolation
+	for _, cause := range err.Causes {
+		out = append(out, leafViolations(cause, prefix)...)
+	}
+	return out
+}
+
+// joinValuePath appends key to the dotted values path prefix.
+func joinValuePath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,109 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const webValuesSchema = `{
+  "$schema": "https://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {"repository": {"type": "string"}, "tag": {"type": "string"}}
+    }
+  }
+}`
+
+const redisValuesSchema = `{
+  "type": "object",
+  "properties": {"port": {"type": "integer", "maximum": 65535}}
+}`
+
+func TestValidateValuesSchema(t *testing.T) {
+	redis := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "redis", Version: "1.0.0", APIVersion: chart.APIVersionV2},
+		Values:   map[string]interface{}{"port": 6379},
+		Schema:   []byte(redisValuesSchema),
+	}
+	web := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "web", Version: "1.0.0", APIVersion: chart.APIVersionV2},
+		Values: map[string]interface{}{
+			"replicaCount": 1,
+			"image":        map[string]interface{}{"repository": "web", "tag": "v1"},
+		},
+		Schema: []byte(webValuesSchema),
+	}
+	web.AddDependency(redis)
+
+	// The chart's defaults meet its schema.
+	violations, err := validateValuesSchema(web, map[string]interface{}{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Fatalf("violations of the defaults = %+v, want none", violations)
+	}
+
+	userSchema := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(userSchema, []byte(`{
+  "type": "object",
+  "properties": {"image": {"properties": {"tag": {"not": {"const": "latest"}}}}}
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vals := map[string]interface{}{
+		"replicaCount": 0,
+		"image":        map[string]interface{}{"repository": 42, "tag": "latest"},
+		"redis":        map[string]interface{}{"port": 70000},
+	}
+	violations, err = validateValuesSchema(web, vals, userSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]SchemaViolation{}
+	for _, v := range violations {
+		got[v.Path] = v
+	}
+	want := map[string]string{
+		"image.tag":        userSchema,
+		"replicaCount":     "web/values.schema.json",
+		"image.repository": "web/values.schema.json",
+		"redis.port":       "redis/values.schema.json",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("violations = %+v, want %d", violations, len(want))
+	}
+	for path, schema := range want {
+		v, ok := got[path]
+		if !ok {
+			t.Errorf("no violation at %s in %+v", path, violations)
+			continue
+		}
+		if v.Schema != schema || v.Message == "" {
+			t.Errorf("violation at %s = %+v, want one of %s with a message", path, v, schema)
+		}
+	}
+	if !strings.Contains(got["replicaCount"].Message, "1") {
+		t.Errorf("replicaCount message = %q, want the minimum", got["replicaCount"].Message)
+	}
+}
+
+func TestValidateValuesSchemaInvalidFile(t *testing.T) {
+	c := &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.0.0", APIVersion: chart.APIVersionV2}}
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"type": 3}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validateValuesSchema(c, nil, path); err == nil {
+		t.Error("a schema that doesn't compile was accepted")
+	}
+}
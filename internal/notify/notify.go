@@ -0,0 +1,335 @@
+// Package notify sends deploy notifications: a message rendered from a Go
+// template over the deploy's context, to Slack, a webhook or email, for the
+// events each notification of deploy.notifications is set up for.
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/pterm/pterm"
+)
+
+// The events of a deploy.
+const (
+	Started   = "started"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// signatureHeader carries the hex HMAC-SHA256 of a webhook body, as for
+// sdkr.webhooks.
+const signatureHeader = "X-Smurf-Signature-256"
+
+// DefaultTemplates are the messages of events a notification has no
+// template for.
+var DefaultTemplates = map[string]string{
+	Started:   `🚀 Deploy of {{.Image}} to {{.Environment}} started by {{.Actor}}{{with .Links.run}} · {{.}}{{end}}`,
+	Succeeded: `✅ Deployed {{.Image}} to {{.Environment}} in {{.Duration}}{{with .Releases}} ({{join . ", "}}){{end}}{{with .Links.run}} · {{.}}{{end}}`,
+	Failed:    `❌ Deploy of {{.Image}} to {{.Environment}} failed after {{.Duration}}: {{.Error}}{{with .Links.run}} · {{.}}{{end}}`,
+}
+
+// defaultSubject is the subject of notification emails.
+const defaultSubject = `[smurf] deploy {{.Event}}: {{.Image}} to {{.Environment}}`
+
+// Event is the context of a deploy that templates see.
+type Event struct {
+	Event       string            `json:"event"` // started, succeeded or failed
+	Environment string            `json:"environment"`
+	Actor       string            `json:"actor"`
+	Image       string            `json:"image,omitempty"` // repository:tag
+	Repository  string            `json:"repository,omitempty"`
+	Tag         string            `json:"tag,omitempty"`
+	Digest      string            `json:"digest,omitempty"`
+	Releases    []string          `json:"releases,omitempty"` // namespace/name of the releases deployed
+	Commit      string            `json:"commit,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+	Duration    string            `json:"duration,omitempty"`  // of the run so far
+	Durations   map[string]string `json:"durations,omitempty"` // per phase: build, push, helm
+	Error       string            `json:"error,omitempty"`
+	Links       map[string]string `json:"links,omitempty"` // run: the CI job, and deploy.links
+}
+
+// funcs are the functions templates may call besides the built-in ones.
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// Notifier sends the notifications of a deploy.
+type Notifier struct {
+	notifications []notification
+	client        *http.Client
+}
+
+// notification is a configs.NotificationConfig with its templates parsed.
+type notification struct {
+	configs.NotificationConfig
+	templates map[string]*template.Template
+	subject   *template.Template
+}
+
+// New parses the templates of notifications, so a broken template fails the
+// deploy before it starts rather than when it is sent.
+func New(notifications []configs.NotificationConfig) (*Notifier, error) {
+	n := &Notifier{client: &http.Client{Timeout: 15 * time.Second}}
+	for i, cfg := range notifications {
+		parsed := notification{NotificationConfig: cfg, templates: map[string]*template.Template{}}
+		for _, event := range configs.DeployEvents {
+			text, ok := cfg.Templates[event]
+			if !ok {
+				text = DefaultTemplates[event]
+			}
+			tmpl, err := template.New(event).Funcs(funcs).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("deploy.notifications[%d]: template for %s: %w", i, event, err)
+			}
+			parsed.templates[event] = tmpl
+		}
+		if cfg.Email != nil {
+			subject := cfg.Email.Subject
+			if subject == "" {
+				subject = defaultSubject
+			}
+			tmpl, err := template.New("subject").Funcs(funcs).Parse(subject)
+			if err != nil {
+				return nil, fmt.Errorf("deploy.notifications[%d]: email subject: %w", i, err)
+			}
+			parsed.subject = tmpl
+		}
+		n.notifications = append(n.notifications, parsed)
+	}
+	return n, nil
+}
+
+// Notify sends every notification set up for event.Event to each of its
+// sinks. A failed notification is reported as a warning; only those marked
+// failOnError turn their failure into an error.
+func (n *Notifier) Notify(event Event) error {
+	if n == nil {
+		return nil
+	}
+	var errs []error
+	for i, nt := range n.notifications {
+		if !nt.Sends(event.Event) {
+			continue
+		}
+		name := nt.Name
+		if name == "" {
+			name = fmt.Sprintf("deploy.notifications[%d]", i)
+		}
+		err := n.send(nt, event)
+		if err != nil {
+			pterm.Warning.Printfln("Notification %s failed: %v", name, err)
+			if nt.FailOnError {
+				errs = append(errs, fmt.Errorf("notification %s: %w", name, err))
+			}
+			continue
+		}
+		pterm.Info.Printfln("Notification %s sent: deploy %s", name, event.Event)
+	}
+	return errors.Join(errs...)
+}
+
+// send renders the message of nt for event and delivers it to each sink.
+func (n *Notifier) send(nt notification, event Event) error {
+	message, err := render(nt.templates[event.Event], event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if nt.Slack != nil {
+		if err := n.sendSlack(nt.Slack, message); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	if nt.Webhook != nil {
+		if err := n.sendWebhook(nt.Webhook, message, event); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if nt.Email != nil {
+		subject, err := render(nt.subject, event)
+		if err == nil {
+			err = sendEmail(nt.Email, subject, message)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// render executes tmpl over event.
+func render(tmpl *template.Template, event Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// sendSlack posts message to a Slack incoming webhook.
+func (n *Notifier) sendSlack(sink *configs.SlackSink, message string) error {
+	payload := map[string]string{"text": message}
+	if sink.Channel != "" {
+		payload["channel"] = sink.Channel
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return n.post(sink.WebhookURL, body, nil)
+}
+
+// webhookPayload is the body of a webhook notification: the rendered
+// message and the context it was rendered from.
+type webhookPayload struct {
+	Event   string `json:"event"`
+	Message string `json:"message"`
+	Deploy  Event  `json:"deploy"`
+}
+
+// sendWebhook posts message and event as JSON, signed with the sink's
+// secret.
+func (n *Notifier) sendWebhook(sink *configs.WebhookSink, message string, event Event) error {
+	body, err := json.Marshal(webhookPayload{Event: "deploy." + event.Event, Message: message, Deploy: event})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"X-Smurf-Event": "deploy." + event.Event}
+	if sink.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(sink.Secret))
+		mac.Write(body)
+		headers[signatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	for k, v := range sink.Headers {
+		headers[k] = v
+	}
+	return n.post(sink.URL, body, headers)
+}
+
+// postAttempts and postBackoff bound the retries of a post that fails with
+// a network error or a 5xx response.
+var (
+	postAttempts = 3
+	postBackoff  = 2 * time.Second
+)
+
+// post sends body as JSON to rawURL.
+func (n *Notifier) post(rawURL string, body []byte, headers map[string]string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL: must be an http(s) URL")
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= postAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * postBackoff)
+		}
+		req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "smurf")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			// The error may quote the URL, and with it the token in a
+			// Slack webhook URL.
+			lastErr = fmt.Errorf("request failed: %w", errors.Unwrap(err))
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("unexpected response %s", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// sendMail is smtp.SendMail; the tests swap it.
+var sendMail = smtp.SendMail
+
+// sendEmail mails message through the sink's SMTP server, which upgrades
+// to TLS when the server offers STARTTLS.
+func sendEmail(sink *configs.EmailSink, subject, message string) error {
+	port := sink.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if sink.Username != "" {
+		auth = smtp.PlainAuth("", sink.Username, sink.Password, sink.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", sink.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(sink.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	addr := net.JoinHostPort(sink.Host, strconv.Itoa(port))
+	return sendMail(addr, auth, sink.From, sink.To, msg.Bytes())
+}
+
+// RunURL is the web page of the CI job smurf runs in, "" outside CI:
+// GitHub Actions, GitLab CI/CD and Jenkins are recognized.
+func RunURL() string {
+	if server, repo, run := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID"); server != "" && repo != "" && run != "" {
+		return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, run)
+	}
+	for _, env := range []string{"CI_JOB_URL", "BUILD_URL"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Commit is the commit the CI job runs for, "" outside CI.
+func Commit() string {
+	for _, env := range []string{"GITHUB_SHA", "CI_COMMIT_SHA", "GIT_COMMIT"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
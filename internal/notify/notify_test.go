@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+func testEvent(event string) Event {
+	return Event{
+		Event:       event,
+		Environment: "production",
+		Actor:       "octocat",
+		Image:       "ghcr.io/acme/api:1.8.0",
+		Releases:    []string{"apps/api", "apps/worker"},
+		Duration:    "2m5s",
+		Links:       map[string]string{"run": "https://github.com/acme/api/actions/runs/42"},
+	}
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	n, err := New([]configs.NotificationConfig{{Webhook: &configs.WebhookSink{URL: "https://cd.example"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		Started:   "🚀 Deploy of ghcr.io/acme/api:1.8.0 to production started by octocat · https://github.com/acme/api/actions/runs/42",
+		Succeeded: "✅ Deployed ghcr.io/acme/api:1.8.0 to production in 2m5s (apps/api, apps/worker) · https://github.com/acme/api/actions/runs/42",
+	}
+	for event, want := range tests {
+		got, err := render(n.notifications[0].templates[event], testEvent(event))
+		if err != nil || got != want {
+			t.Errorf("%s message = %q, %v, want %q", event, got, err, want)
+		}
+	}
+
+	e := testEvent(Failed)
+	e.Error, e.Links = "release api: timed out", nil
+	got, _ := render(n.notifications[0].templates[Failed], e)
+	if want := "❌ Deploy of ghcr.io/acme/api:1.8.0 to production failed after 2m5s: release api: timed out"; got != want {
+		t.Errorf("failed message = %q, want %q", got, want)
+	}
+}
+
+func TestNewRejectsBrokenTemplate(t *testing.T) {
+	_, err := New([]configs.NotificationConfig{{
+		Templates: map[string]string{Failed: "{{.Image"},
+		Webhook:   &configs.WebhookSink{URL: "https://cd.example"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "template for failed") {
+		t.Errorf("New() error = %v, want a template error", err)
+	}
+}
+
+func TestNotifySinks(t *testing.T) {
+	var slackBody map[string]string
+	var hookBody webhookPayload
+	var hookSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/slack":
+			_ = json.Unmarshal(data, &slackBody)
+		case "/hook":
+			_ = json.Unmarshal(data, &hookBody)
+			mac := hmac.New(sha256.New, []byte("s3cret"))
+			mac.Write(data)
+			if r.Header.Get(signatureHeader) == "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+				hookSignature = "valid"
+			}
+		}
+	}))
+	defer srv.Close()
+
+	var mailTo []string
+	var mail string
+	defer func(orig func(string, smtp.Auth, string, []string, []byte) error) { sendMail = orig }(sendMail)
+	sendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" || from != "deploy@example.com" {
+			t.Errorf("mail sent to %s from %s", addr, from)
+		}
+		mailTo, mail = to, string(msg)
+		return nil
+	}
+
+	n, err := New([]configs.NotificationConfig{
+		{
+			Events:    []string{Failed},
+			Templates: map[string]string{Failed: "{{upper .Environment}} down: {{.Error}}"},
+			Slack:     &configs.SlackSink{WebhookURL: srv.URL + "/slack", Channel: "#deploys"},
+			Email:     &configs.EmailSink{Host: "smtp.example.com", From: "deploy@example.com", To: []string{"oncall@example.com"}},
+		},
+		{Webhook: &configs.WebhookSink{URL: srv.URL + "/hook", Secret: "s3cret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(testEvent(Started)); err != nil {
+		t.Fatal(err)
+	}
+	if slackBody != nil || mail != "" {
+		t.Error("the failed-only notification was sent on started")
+	}
+	if hookBody.Event != "deploy.started" || hookBody.Deploy.Actor != "octocat" || hookSignature != "valid" {
+		t.Errorf("webhook got %+v with a %q signature", hookBody, hookSignature)
+	}
+
+	e := testEvent(Failed)
+	e.Error = "timed out"
+	if err := n.Notify(e); err != nil {
+		t.Fatal(err)
+	}
+	if slackBody["text"] != "PRODUCTION down: timed out" || slackBody["channel"] != "#deploys" {
+		t.Errorf("slack got %v", slackBody)
+	}
+	if len(mailTo) != 1 || !strings.Contains(mail, "Subject: [smurf] deploy failed: ghcr.io/acme/api:1.8.0 to production\r\n") ||
+		!strings.HasSuffix(mail, "\r\n\r\nPRODUCTION down: timed out\r\n") {
+		t.Errorf("mail to %v:\n%s", mailTo, mail)
+	}
+}
+
+func TestNotifyFailOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	defer func(attempts int, backoff time.Duration) { postAttempts, postBackoff = attempts, backoff }(postAttempts, postBackoff)
+	postAttempts, postBackoff = 1, 0
+
+	sinks := []configs.NotificationConfig{{Webhook: &configs.WebhookSink{URL: srv.URL}}}
+	n, _ := New(sinks)
+	if err := n.Notify(testEvent(Succeeded)); err != nil {
+		t.Errorf("Notify() = %v, want only a warning", err)
+	}
+	sinks[0].FailOnError = true
+	n, _ = New(sinks)
+	if err := n.Notify(testEvent(Succeeded)); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Notify() = %v, want the 403", err)
+	}
+}
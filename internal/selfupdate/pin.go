@@ -0,0 +1,99 @@
+package selfupdate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// PinFile is the file a repository pins the smurf version it expects in,
+// as an exact version (1.4.2) or a constraint (~> 1.4).
+const PinFile = ".smurf-version"
+
+// Modes of SMURF_VERSION_CHECK, which decides what a binary that does not
+// match the pin does.
+const (
+	ModeWarn  = "warn"  // run anyway after a warning; the default
+	ModeBlock = "block" // refuse to run
+	ModeOff   = "off"   // ignore the pin
+)
+
+// Pin is the smurf version a repository expects.
+type Pin struct {
+	Constraint string
+	Path       string // the PinFile it was read from
+}
+
+// FindPin reads the PinFile in dir or the nearest of its parents that has
+// one, and returns nil when none has.
+func FindPin(dir string) (*Pin, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, PinFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			constraint := pinConstraint(string(data))
+			if constraint == "" {
+				return nil, fmt.Errorf("%s is empty", path)
+			}
+			if _, err := version.NewConstraint(constraint); err != nil {
+				return nil, fmt.Errorf("invalid smurf version %q in %s: %w", constraint, path, err)
+			}
+			return &Pin{Constraint: constraint, Path: path}, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// pinConstraint is the first line of a PinFile that is neither blank nor a
+// # comment.
+func pinConstraint(data string) string {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// Matches reports whether the smurf version current satisfies the pin. A
+// build without a release version, such as dev, matches any pin.
+func (p *Pin) Matches(current string) bool {
+	v, err := version.NewVersion(current)
+	if err != nil {
+		return true
+	}
+	constraints, err := version.NewConstraint(p.Constraint)
+	if err != nil {
+		return false
+	}
+	return constraints.Check(v)
+}
+
+// CheckMode returns the SMURF_VERSION_CHECK mode, ModeWarn when unset.
+func CheckMode() (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("SMURF_VERSION_CHECK")))
+	switch mode {
+	case "":
+		return ModeWarn, nil
+	case ModeWarn, ModeBlock, ModeOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid SMURF_VERSION_CHECK %q: must be one of warn, block, off", mode)
+}
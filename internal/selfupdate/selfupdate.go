@@ -0,0 +1,325 @@
+// Package selfupdate finds smurf releases on GitHub and replaces the
+// running binary with one of them.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// releasesURL is the GitHub API endpoint of smurf's releases.
+var releasesURL = "https://api.github.com/repos/clouddrove/smurf/releases"
+
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+// Release is a published smurf release.
+type Release struct {
+	Tag        string  `json:"tag_name"`
+	URL        string  `json:"html_url"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Latest returns the newest release that is not a pre-release.
+func Latest() (*Release, error) {
+	var rel Release
+	if err := getJSON(releasesURL+"/latest", &rel); err != nil {
+		return nil, fmt.Errorf("failed to look up the latest smurf release: %w", err)
+	}
+	return &rel, nil
+}
+
+// Find returns the release tagged tag, with or without its leading v.
+func Find(tag string) (*Release, error) {
+	tag = "v" + strings.TrimPrefix(tag, "v")
+	var rel Release
+	if err := getJSON(releasesURL+"/tags/"+tag, &rel); err != nil {
+		return nil, fmt.Errorf("failed to look up smurf release %s: %w", tag, err)
+	}
+	return &rel, nil
+}
+
+// NewestMatching returns the newest release that satisfies constraint, out
+// of the last 100. Pre-releases only match a constraint that names one.
+func NewestMatching(constraint string) (*Release, error) {
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smurf version %q: %w", constraint, err)
+	}
+	var releases []Release
+	if err := getJSON(releasesURL+"?per_page=100", &releases); err != nil {
+		return nil, fmt.Errorf("failed to list smurf releases: %w", err)
+	}
+	var newest *Release
+	var newestVersion *version.Version
+	for i, rel := range releases {
+		v, err := version.NewVersion(rel.Tag)
+		if rel.Draft || err != nil || !constraints.Check(v) {
+			continue
+		}
+		if newestVersion == nil || v.GreaterThan(newestVersion) {
+			newest, newestVersion = &releases[i], v
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no smurf release satisfies %q", constraint)
+	}
+	return newest, nil
+}
+
+// Newer reports whether the release tagged tag is newer than the smurf
+// version current. A build without a release version is never outdated.
+func Newer(tag, current string) bool {
+	v, err := version.NewVersion(tag)
+	if err != nil {
+		return false
+	}
+	c, err := version.NewVersion(current)
+	if err != nil {
+		return false
+	}
+	return v.GreaterThan(c)
+}
+
+// Executable returns the path of the running smurf binary, with symlinks
+// resolved.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the smurf binary: %w", err)
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// Install replaces the binary at exe with the one of rel for this OS and
+// architecture, after checking its archive against the release's
+// checksums.txt.
+func Install(rel *Release, exe string) error {
+	if strings.Contains(filepath.ToSlash(exe), "/Cellar/") {
+		return fmt.Errorf("%s is managed by Homebrew; run brew upgrade smurf instead", exe)
+	}
+	archive := archiveName(rel.Tag)
+	archiveURL, sumsURL := "", ""
+	for _, a := range rel.Assets {
+		switch a.Name {
+		case archive:
+			archiveURL = a.URL
+		case "checksums.txt":
+			sumsURL = a.URL
+		}
+	}
+	if archiveURL == "" {
+		return fmt.Errorf("smurf %s has no build for %s/%s (no %s)", rel.Tag, runtime.GOOS, runtime.GOARCH, archive)
+	}
+	if sumsURL == "" {
+		return fmt.Errorf("smurf %s has no checksums.txt to verify %s against", rel.Tag, archive)
+	}
+	sum, err := releaseChecksum(sumsURL, archive)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(exe)
+	download, err := os.CreateTemp(dir, ".smurf-download-*")
+	if err != nil {
+		return writeError(dir, err)
+	}
+	defer os.Remove(download.Name())
+	defer download.Close()
+	if err := downloadFile(archiveURL, download, sum); err != nil {
+		return err
+	}
+
+	// Write the new binary next to the old one and rename it into place, so
+	// an interrupted update never leaves a half-written smurf behind.
+	staged, err := os.CreateTemp(dir, ".smurf-update-*")
+	if err != nil {
+		return writeError(dir, err)
+	}
+	defer os.Remove(staged.Name())
+	if err := extractBinary(download.Name(), archive, staged); err != nil {
+		staged.Close()
+		return err
+	}
+	if err := staged.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(staged.Name(), 0o755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// A running executable cannot be replaced on Windows, only renamed.
+		old := exe + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return writeError(dir, err)
+		}
+	}
+	if err := os.Rename(staged.Name(), exe); err != nil {
+		return writeError(dir, err)
+	}
+	return nil
+}
+
+func writeError(dir string, err error) error {
+	if os.IsPermission(err) {
+		return fmt.Errorf("no permission to write to %s; rerun with sudo or reinstall smurf to a directory you own: %w", dir, err)
+	}
+	return fmt.Errorf("failed to replace the smurf binary: %w", err)
+}
+
+// archiveName is the release archive of tag for this OS and architecture,
+// e.g. smurf-v1.4.2-linux-amd64.tar.gz.
+func archiveName(tag string) string {
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("smurf-v%s-%s-%s.%s", strings.TrimPrefix(tag, "v"), runtime.GOOS, runtime.GOARCH, ext)
+}
+
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "smurf.exe"
+	}
+	return "smurf"
+}
+
+func getJSON(url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// An authenticated request is not held to the API's anonymous rate
+	// limit, which CI runners behind a shared address hit quickly.
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// releaseChecksum returns the SHA-256 sum the checksums file at url lists
+// for archive.
+func releaseChecksum(url, archive string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download checksums: GET %s: %s", url, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == archive {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums: %w", err)
+	}
+	return "", fmt.Errorf("%s is not in checksums.txt", archive)
+}
+
+// downloadFile writes url to w and fails unless its SHA-256 sum is sum.
+func downloadFile(url string, w io.Writer, sum string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download smurf: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download smurf: GET %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return fmt.Errorf("failed to download smurf: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, got, sum)
+	}
+	return nil
+}
+
+// extractBinary copies the smurf executable out of the release archive at
+// path, named name, to w.
+func extractBinary(path, name string, w io.Writer) error {
+	if strings.HasSuffix(name, ".zip") {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if f.Name != binaryName() {
+				continue
+			}
+			src, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			defer src.Close()
+			_, err = io.Copy(w, src)
+			return err
+		}
+		return fmt.Errorf("%s has no %s", name, binaryName())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s has no %s", name, binaryName())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if h.Typeflag == tar.TypeReg && filepath.Base(h.Name) == binaryName() {
+			_, err = io.Copy(w, tr)
+			return err
+		}
+	}
+}
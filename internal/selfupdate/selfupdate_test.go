@@ -0,0 +1,235 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFindPin(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if pin, err := FindPin(nested); err != nil || pin != nil {
+		t.Fatalf("FindPin without a pin file = %v, %v; want nil, nil", pin, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, PinFile), []byte("# smurf used by CI\n\n~> 1.4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pin, err := FindPin(nested)
+	if err != nil {
+		t.Fatalf("FindPin: %v", err)
+	}
+	if pin.Constraint != "~> 1.4" || pin.Path != filepath.Join(root, PinFile) {
+		t.Errorf("pin = %+v, want ~> 1.4 from %s", pin, filepath.Join(root, PinFile))
+	}
+
+	if err := os.WriteFile(filepath.Join(root, PinFile), []byte("latest please\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindPin(nested); err == nil {
+		t.Error("FindPin accepted an invalid version")
+	}
+}
+
+func TestPinMatches(t *testing.T) {
+	tests := []struct {
+		constraint, current string
+		want                bool
+	}{
+		{"1.4.2", "v1.4.2", true},
+		{"v1.4.2", "v1.4.3", false},
+		{"~> 1.4", "v1.5.0", true},
+		{"~> 1.4.0", "v1.5.0", false},
+		{">= 1.4, < 2", "v2.0.0", false},
+		{"1.4.2", "dev", true},
+	}
+	for _, tt := range tests {
+		pin := &Pin{Constraint: tt.constraint}
+		if got := pin.Matches(tt.current); got != tt.want {
+			t.Errorf("Pin{%q}.Matches(%q) = %v, want %v", tt.constraint, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMode(t *testing.T) {
+	t.Setenv("SMURF_VERSION_CHECK", "")
+	if mode, err := CheckMode(); err != nil || mode != ModeWarn {
+		t.Errorf("default mode = %q, %v; want warn", mode, err)
+	}
+	t.Setenv("SMURF_VERSION_CHECK", "Block")
+	if mode, err := CheckMode(); err != nil || mode != ModeBlock {
+		t.Errorf("mode = %q, %v; want block", mode, err)
+	}
+	t.Setenv("SMURF_VERSION_CHECK", "strict")
+	if _, err := CheckMode(); err == nil {
+		t.Error("CheckMode accepted an unknown mode")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	if !Newer("v1.5.0", "v1.4.2") {
+		t.Error("v1.5.0 should be newer than v1.4.2")
+	}
+	if Newer("v1.4.2", "v1.4.2") {
+		t.Error("a release is not newer than itself")
+	}
+	if Newer("v1.5.0", "dev") {
+		t.Error("a dev build is never outdated")
+	}
+}
+
+// fakeReleases serves the releases API and the assets of the given tags,
+// each with a smurf binary printing its tag.
+func fakeReleases(t *testing.T, tags ...string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	var releases []Release
+	for _, tag := range tags {
+		archive := archiveName(tag)
+		data := releaseArchive(t, "#!/bin/sh\necho "+tag+"\n")
+		sum := sha256.Sum256(data)
+		base := "/download/" + tag + "/"
+		mux.HandleFunc(base+archive, func(w http.ResponseWriter, r *http.Request) { w.Write(data) })
+		mux.HandleFunc(base+"checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s  %s\n", hex.EncodeToString(sum[:]), archive)
+		})
+		releases = append(releases, Release{
+			Tag:        tag,
+			Prerelease: strings.Contains(tag, "-"),
+			Assets: []Asset{
+				{Name: archive, URL: srv.URL + base + archive},
+				{Name: "checksums.txt", URL: srv.URL + base + "checksums.txt"},
+			},
+		})
+	}
+	mux.HandleFunc("/releases", func(w http.ResponseWriter, r *http.Request) { json.NewEncoder(w).Encode(releases) })
+	for i := range releases {
+		rel := releases[i]
+		mux.HandleFunc("/releases/tags/"+rel.Tag, func(w http.ResponseWriter, r *http.Request) { json.NewEncoder(w).Encode(rel) })
+	}
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(releases[0])
+	})
+
+	old := releasesURL
+	releasesURL = srv.URL + "/releases"
+	t.Cleanup(func() { releasesURL = old })
+	return srv
+}
+
+// releaseArchive is a release archive for this OS holding a smurf binary
+// with content.
+func releaseArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if runtime.GOOS == "windows" {
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(binaryName())
+		w.Write([]byte(content))
+		zw.Close()
+		return buf.Bytes()
+	}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: binaryName(), Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestNewestMatching(t *testing.T) {
+	fakeReleases(t, "v1.5.0", "v1.4.3", "v1.4.10", "v1.4.11-rc.1", "v1.3.0")
+
+	tests := map[string]string{
+		"~> 1.4.0":    "v1.4.10",
+		"1.3.0":       "v1.3.0",
+		">= 1.0":      "v1.5.0",
+		"1.4.11-rc.1": "v1.4.11-rc.1",
+	}
+	for constraint, want := range tests {
+		rel, err := NewestMatching(constraint)
+		if err != nil {
+			t.Errorf("NewestMatching(%q): %v", constraint, err)
+			continue
+		}
+		if rel.Tag != want {
+			t.Errorf("NewestMatching(%q) = %s, want %s", constraint, rel.Tag, want)
+		}
+	}
+	if _, err := NewestMatching("~> 2.0"); err == nil {
+		t.Error("NewestMatching found a release for an unreleased version")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	fakeReleases(t, "v1.5.0", "v1.4.2")
+	exe := filepath.Join(t.TempDir(), binaryName())
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := Find("1.4.2")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if err := Install(rel, exe); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "echo v1.4.2") {
+		t.Errorf("installed binary = %q, want the v1.4.2 one", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(exe))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".smurf-") {
+			t.Errorf("Install left %s behind", e.Name())
+		}
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	srv := fakeReleases(t, "v1.5.0")
+	rel, err := Latest()
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	// Point the archive at another release's bytes.
+	other := releaseArchive(t, "tampered")
+	srv.Config.Handler.(*http.ServeMux).HandleFunc("/tampered", func(w http.ResponseWriter, r *http.Request) { w.Write(other) })
+	rel.Assets[0].URL = srv.URL + "/tampered"
+
+	exe := filepath.Join(t.TempDir(), binaryName())
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(rel, exe); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Install = %v, want a checksum mismatch", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Errorf("binary replaced despite the checksum mismatch: %q", data)
+	}
+}
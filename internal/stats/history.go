@@ -0,0 +1,291 @@
+// Package stats keeps the durations of past deploy runs, phase by phase, so
+// a run that is much slower than usual can be called out.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// keepRuns is how many runs the history keeps per service; older ones are
+// dropped as new ones are recorded.
+const keepRuns = 200
+
+// baselineRuns is how many of the most recent successful runs of a phase the
+// rolling median is taken over, and minBaseline how many it needs before a
+// slowdown is reported at all.
+const (
+	baselineRuns = 20
+	minBaseline  = 5
+)
+
+// minSlowdown is the least a phase has to be slower than its median to be a
+// regression, so a 4s build taking 8s doesn't raise an alarm.
+const minSlowdown = 30 * time.Second
+
+// DefaultThreshold is how many times its median a phase may take before the
+// run counts as a regression.
+const DefaultThreshold = 1.5
+
+var deploysBucket = []byte("deploys")
+
+// Run is one deploy run of a service: when it started, whether it succeeded
+// and how long each phase it ran took.
+type Run struct {
+	Time   time.Time                `json:"time"`
+	Result string                   `json:"result"`
+	Phases map[string]time.Duration `json:"phases"`
+}
+
+// Regression is a phase of a run that took Factor times its Median.
+type Regression struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"`
+	Median   time.Duration `json:"median"`
+	Factor   float64       `json:"factor"`
+}
+
+// PhaseTrend sums up the history of one phase of a service.
+type PhaseTrend struct {
+	Phase  string        `json:"phase"`
+	Runs   int           `json:"runs"`
+	Last   time.Duration `json:"last"`
+	Median time.Duration `json:"median"`
+	P90    time.Duration `json:"p90"`
+	// Change is how much slower (positive) or faster the median of the most
+	// recent baseline runs is than the median of the ones before them, as a
+	// fraction; zero when there are too few runs to tell.
+	Change float64 `json:"change"`
+}
+
+// History is the deploy duration history in ~/.smurf/history.db.
+type History struct {
+	db *bolt.DB
+}
+
+// HistoryPath is where the history is kept, under SmurfHome.
+func HistoryPath() (string, error) {
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "history.db"), nil
+}
+
+// Open opens the history at path, creating it. It waits a few seconds for
+// another smurf that has it open and then gives up, since the history is
+// never worth blocking a deploy for.
+func Open(path string) (*History, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deploy history %s: %w", path, err)
+	}
+	return &History{db: db}, nil
+}
+
+// Close closes the history.
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// Record adds run to the history of service, dropping the oldest runs past
+// keepRuns.
+func (h *History) Record(service string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		deploys, err := tx.CreateBucketIfNotExists(deploysBucket)
+		if err != nil {
+			return err
+		}
+		b, err := deploys.CreateBucketIfNotExists([]byte(service))
+		if err != nil {
+			return err
+		}
+		// Keys are the start time, so the cursor walks runs oldest first.
+		if err := b.Put([]byte(run.Time.UTC().Format(time.RFC3339Nano)), data); err != nil {
+			return err
+		}
+		var keys [][]byte
+		_ = b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, append([]byte(nil), k...))
+			return nil
+		})
+		for len(keys) > keepRuns {
+			if err := b.Delete(keys[0]); err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+}
+
+// Runs returns the recorded runs of service, oldest first.
+func (h *History) Runs(service string) ([]Run, error) {
+	var runs []Run
+	err := h.db.View(func(tx *bolt.Tx) error {
+		deploys := tx.Bucket(deploysBucket)
+		if deploys == nil {
+			return nil
+		}
+		b := deploys.Bucket([]byte(service))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("corrupt deploy history entry %s/%s: %w", service, k, err)
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	return runs, err
+}
+
+// Services returns the services with recorded runs, sorted.
+func (h *History) Services() ([]string, error) {
+	var services []string
+	err := h.db.View(func(tx *bolt.Tx) error {
+		deploys := tx.Bucket(deploysBucket)
+		if deploys == nil {
+			return nil
+		}
+		return deploys.ForEach(func(k, v []byte) error {
+			if v == nil {
+				services = append(services, string(k))
+			}
+			return nil
+		})
+	})
+	sort.Strings(services)
+	return services, err
+}
+
+// Regressions compares each phase of run with the rolling median of the
+// same phase over the successful runs in past (oldest first), and returns
+// the phases that took more than threshold times their median, and at least
+// minSlowdown longer.
+func Regressions(past []Run, run Run, threshold float64) []Regression {
+	if threshold <= 1 {
+		threshold = DefaultThreshold
+	}
+	var regressions []Regression
+	for _, phase := range sortedPhases(run.Phases) {
+		d := run.Phases[phase]
+		samples := phaseDurations(past, phase)
+		if len(samples) > baselineRuns {
+			samples = samples[len(samples)-baselineRuns:]
+		}
+		if len(samples) < minBaseline {
+			continue
+		}
+		median := percentile(samples, 50)
+		if median <= 0 || d-median < minSlowdown {
+			continue
+		}
+		if factor := float64(d) / float64(median); factor > threshold {
+			regressions = append(regressions, Regression{Phase: phase, Duration: d, Median: median, Factor: factor})
+		}
+	}
+	return regressions
+}
+
+// Trends sums up each phase over the successful runs (oldest first).
+func Trends(runs []Run) []PhaseTrend {
+	seen := map[string]time.Duration{}
+	for _, run := range runs {
+		for phase, d := range run.Phases {
+			seen[phase] = d
+		}
+	}
+	var trends []PhaseTrend
+	for _, phase := range sortedPhases(seen) {
+		samples := phaseDurations(runs, phase)
+		if len(samples) == 0 {
+			continue
+		}
+		t := PhaseTrend{
+			Phase:  phase,
+			Runs:   len(samples),
+			Last:   samples[len(samples)-1],
+			Median: percentile(samples, 50),
+			P90:    percentile(samples, 90),
+		}
+		if len(samples) >= 2*minBaseline {
+			recent := samples[max(len(samples)-baselineRuns, len(samples)/2):]
+			earlier := samples[:len(samples)-len(recent)]
+			if before := percentile(earlier, 50); before > 0 {
+				t.Change = float64(percentile(recent, 50)-before) / float64(before)
+			}
+		}
+		trends = append(trends, t)
+	}
+	return trends
+}
+
+// phaseDurations returns the durations of phase in the successful runs,
+// in their order.
+func phaseDurations(runs []Run, phase string) []time.Duration {
+	var samples []time.Duration
+	for _, run := range runs {
+		if run.Result != "succeeded" {
+			continue
+		}
+		if d, ok := run.Phases[phase]; ok {
+			samples = append(samples, d)
+		}
+	}
+	return samples
+}
+
+// percentile returns the p-th percentile of samples (nearest rank), without
+// reordering them.
+func percentile(samples []time.Duration, p int) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if p == 50 && len(sorted)%2 == 0 {
+		return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+// sortedPhases returns the phases in deploy order (build, push, helm), with
+// any others after them by name.
+func sortedPhases(phases map[string]time.Duration) []string {
+	order := map[string]int{"build": 0, "push": 1, "helm": 2}
+	names := make([]string, 0, len(phases))
+	for name := range phases {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, iok := order[names[i]]
+		oj, jok := order[names[j]]
+		switch {
+		case iok && jok:
+			return oi < oj
+		case iok != jok:
+			return iok
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
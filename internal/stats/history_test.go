@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordRuns(t *testing.T) {
+	h, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < keepRuns+5; i++ {
+		run := Run{Time: start.Add(time.Duration(i) * time.Minute), Result: "succeeded", Phases: map[string]time.Duration{"build": time.Duration(i) * time.Second}}
+		if err := h.Record("api", run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := h.Runs("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != keepRuns {
+		t.Fatalf("kept %d runs, want %d", len(runs), keepRuns)
+	}
+	if runs[0].Phases["build"] != 5*time.Second {
+		t.Errorf("oldest kept run built in %s, want the 5 oldest dropped", runs[0].Phases["build"])
+	}
+	if services, _ := h.Services(); len(services) != 1 || services[0] != "api" {
+		t.Errorf("Services = %v, want [api]", services)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	var past []Run
+	for _, d := range []time.Duration{60, 62, 58, 61, 300, 59} {
+		past = append(past, Run{Result: "succeeded", Phases: map[string]time.Duration{"build": d * time.Second, "push": 10 * time.Second}})
+	}
+	past = append(past, Run{Result: "failed", Phases: map[string]time.Duration{"build": time.Second}})
+
+	run := Run{Result: "succeeded", Phases: map[string]time.Duration{"build": 150 * time.Second, "push": 25 * time.Second}}
+	regressions := Regressions(past, run, 0)
+	if len(regressions) != 1 || regressions[0].Phase != "build" || regressions[0].Median != 60500*time.Millisecond {
+		t.Fatalf("Regressions = %+v, want build against a median of 60.5s", regressions)
+	}
+
+	// Too few runs to compare with.
+	if r := Regressions(past[:3], run, 0); len(r) != 0 {
+		t.Errorf("Regressions with 3 past runs = %+v, want none", r)
+	}
+}
@@ -0,0 +1,115 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+)
+
+// ServiceTrends is the phase trends of one service, as PrintTrends shows
+// them.
+type ServiceTrends struct {
+	Service string       `json:"service"`
+	Runs    int          `json:"runs"`
+	Failed  int          `json:"failed"`
+	LastRun time.Time    `json:"lastRun"`
+	Phases  []PhaseTrend `json:"phases"`
+}
+
+// Summarize sums up the runs of service (oldest first).
+func Summarize(service string, runs []Run) ServiceTrends {
+	s := ServiceTrends{Service: service, Runs: len(runs), Phases: Trends(runs)}
+	for _, run := range runs {
+		if run.Result != "succeeded" {
+			s.Failed++
+		}
+		if run.Time.After(s.LastRun) {
+			s.LastRun = run.Time
+		}
+	}
+	if s.Phases == nil {
+		s.Phases = []PhaseTrend{}
+	}
+	return s
+}
+
+// PrintTrends renders the trends of each service as a table, or as JSON
+// when format is json.
+func PrintTrends(services []ServiceTrends, format string) error {
+	if format == "json" {
+		if services == nil {
+			services = []ServiceTrends{}
+		}
+		return utils.PrintJSON(services)
+	}
+	if len(services) == 0 {
+		pterm.Info.Println("No deploy durations recorded yet; smurf deploy records them as it runs.")
+		return nil
+	}
+
+	tableData := pterm.TableData{{"SERVICE", "PHASE", "RUNS", "LAST", "MEDIAN", "P90", "TREND"}}
+	for _, s := range services {
+		if len(s.Phases) == 0 {
+			tableData = append(tableData, []string{s.Service, "-", fmt.Sprint(s.Runs), "", "", "", ""})
+			continue
+		}
+		for i, p := range s.Phases {
+			service := ""
+			if i == 0 {
+				service = s.Service
+			}
+			tableData = append(tableData, []string{
+				service,
+				p.Phase,
+				fmt.Sprint(p.Runs),
+				p.Last.String(),
+				p.Median.String(),
+				p.P90.String(),
+				trend(p.Change),
+			})
+		}
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// PrintRuns renders the most recent limit runs of a service, newest first.
+func PrintRuns(runs []Run, limit int) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	tableData := pterm.TableData{{"TIME", "RESULT", "BUILD", "PUSH", "HELM"}}
+	for i := len(runs) - 1; i >= 0 && (limit <= 0 || len(runs)-i <= limit); i-- {
+		r := runs[i]
+		result := pterm.Green(r.Result)
+		if r.Result != "succeeded" {
+			result = pterm.Red(r.Result)
+		}
+		row := []string{r.Time.Local().Format("2006-01-02 15:04:05"), result}
+		for _, phase := range []string{"build", "push", "helm"} {
+			d, ok := r.Phases[phase]
+			if !ok {
+				row = append(row, "-")
+				continue
+			}
+			row = append(row, d.String())
+		}
+		tableData = append(tableData, row)
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// trend renders the change of a phase's median: red when it got slower by
+// more than a tenth, green when it got faster by as much.
+func trend(change float64) string {
+	switch {
+	case change == 0:
+		return "-"
+	case change > 0.1:
+		return pterm.Red(fmt.Sprintf("▲ %+.0f%%", change*100))
+	case change < -0.1:
+		return pterm.Green(fmt.Sprintf("▼ %+.0f%%", change*100))
+	}
+	return fmt.Sprintf("%+.0f%%", change*100)
+}
@@ -7,15 +7,17 @@ import (
 	"os"
 	"strings"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 )
 
+// Apply plans and applies the configuration in dir. parallelism limits the
+// concurrent operations terraform runs (0 keeps terraform's default of 10),
+// and slowest is how many of the slowest resources to report afterwards.
 func Apply(approve bool, vars []string,
 	varFiles []string, lock bool,
 	dir string, targets []string,
-	state string, useAI bool) error {
+	state string, parallelism, slowest int, useAI bool) error {
 
 	defer cleanupPlanFile()
 
@@ -23,23 +25,34 @@ func Apply(approve bool, vars []string,
 	tf, err := initTerraform(dir, useAI)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	vars = append(envVars, vars...)
+
 	planOptions, err := buildPlanOptions(vars, varFiles, targets, state)
 	if err != nil {
 		Error("Failed to build plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
+	if parallelism > 0 {
+		planOptions = append(planOptions, tfexec.Parallelism(parallelism))
+	}
 
 	// Generate Plan
 	Step("Generating Terraform plan...")
 	_, err = tf.Plan(context.Background(), append(planOptions, tfexec.Out("plan.out"))...)
 	if err != nil {
 		Error("Failed to generate plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 	Success("Terraform plan generated successfully.")
@@ -65,15 +78,15 @@ func Apply(approve bool, vars []string,
 
 	// Apply
 	Step("Applying changes...")
-	tf.SetStdout(os.Stdout)
 	tf.SetStderr(os.Stderr)
 
-	applyOpts := buildApplyOptions(lock, "plan.out", state, targets)
+	applyOpts := buildApplyOptions(lock, "plan.out", state, targets, parallelism)
 
-	err = tf.Apply(context.Background(), applyOpts...)
+	err = runApplyJSON(tf, os.Stdout, applyOpts, slowest)
+	recordApply(tf, dir, show, err)
 	if err != nil {
 		Error("Terraform apply failed: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -83,10 +96,12 @@ func Apply(approve bool, vars []string,
 	return nil
 }
 
-func ApplyWithPlan(planFile string, vars []string,
+// ApplyWithPlan applies a saved plan, with parallelism and slowest as for
+// Apply. With approvedHash set, it refuses a plan file whose hash differs.
+func ApplyWithPlan(planFile, approvedHash string, vars []string,
 	varFiles []string, lock bool,
 	dir string, targets []string,
-	state string, useAI bool) error {
+	state string, parallelism, slowest int, useAI bool) error {
 
 	Step("Initializing Terraform client...")
 	tf, err := initTerraform(dir, useAI)
@@ -97,6 +112,12 @@ func ApplyWithPlan(planFile string, vars []string,
 	if _, err := os.Stat(planFile); os.IsNotExist(err) {
 		return fmt.Errorf("plan file not found: %s", planFile)
 	}
+	if approvedHash != "" {
+		if err := verifyApprovedHash(planFile, approvedHash); err != nil {
+			Error("%v", err)
+			return err
+		}
+	}
 
 	Info("Applying plan from file: %s", planFile)
 
@@ -111,20 +132,20 @@ func ApplyWithPlan(planFile string, vars []string,
 	}
 
 	Step("Applying changes from plan file...")
-	tf.SetStdout(os.Stdout)
 	tf.SetStderr(os.Stderr)
 
-	applyOpts := buildApplyOptions(lock, planFile, state, targets)
+	applyOpts := buildApplyOptions(lock, planFile, state, targets, parallelism)
 
 	applyOpts, err = addVarsAndFiles(vars, varFiles, applyOpts)
 	if err != nil {
 		return err
 	}
 
-	err = tf.Apply(context.Background(), applyOpts...)
+	err = runApplyJSON(tf, os.Stdout, applyOpts, slowest)
+	recordApply(tf, dir, show, err)
 	if err != nil {
 		Error("Terraform apply failed: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -142,7 +163,7 @@ func initTerraform(dir string, useAI bool) (*tfexec.Terraform, error) {
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return nil, err
 	}
 	return tf, nil
@@ -173,12 +194,16 @@ func buildPlanOptions(vars []string, varFiles []string, targets []string, state
 	return opts, nil
 }
 
-func buildApplyOptions(lock bool, planOrDir string, state string, targets []string) []tfexec.ApplyOption {
+func buildApplyOptions(lock bool, planOrDir string, state string, targets []string, parallelism int) []tfexec.ApplyOption {
 	opts := []tfexec.ApplyOption{
 		tfexec.Lock(lock),
 		tfexec.DirOrPlan(planOrDir),
 	}
 
+	if parallelism > 0 {
+		opts = append(opts, tfexec.Parallelism(parallelism))
+	}
+
 	if state != "" {
 		opts = append(opts, tfexec.State(state))
 	}
@@ -208,10 +233,20 @@ func addVarsAndFiles(vars []string, varFiles []string, opts []tfexec.ApplyOption
 func showPlan(tf *tfexec.Terraform, planFile string, useAI bool) (*tfjson.Plan, error) {
 	Step("Showing plan details...")
 
+	// Parse the plan first, so the values it marks sensitive are registered
+	// before the rendered plan is printed.
+	show, err := tf.ShowPlanFile(context.Background(), planFile)
+	if err != nil {
+		Error("Failed to parse plan: %v", err)
+		explainError(useAI, err.Error())
+		return nil, err
+	}
+	registerPlanSecrets(show)
+
 	planDetail, err := tf.ShowPlanFileRaw(context.Background(), planFile)
 	if err != nil {
 		Error("Failed to read plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return nil, err
 	}
 
@@ -223,13 +258,6 @@ func showPlan(tf *tfexec.Terraform, planFile string, useAI bool) (*tfjson.Plan,
 
 	writer.Write([]byte(colorizeNoChanges(string(planDetail))))
 
-	show, err := tf.ShowPlanFile(context.Background(), planFile)
-	if err != nil {
-		Error("Failed to parse plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
-		return nil, err
-	}
-
 	return show, nil
 }
 
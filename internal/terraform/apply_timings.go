@@ -0,0 +1,184 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pterm/pterm"
+)
+
+// DefaultSlowestResources is how many resources the post-apply timing report
+// lists by default.
+const DefaultSlowestResources = 10
+
+// ResourceTiming is how long terraform spent applying one resource, taken
+// from the apply_complete and apply_errored messages of `apply -json`.
+type ResourceTiming struct {
+	Address string
+	Action  string // create, update, delete, replace, ...
+	Elapsed time.Duration
+	Failed  bool
+}
+
+// applyMessage is the part of a machine-readable apply message smurf reads.
+// See https://developer.hashicorp.com/terraform/internals/machine-readable-ui.
+type applyMessage struct {
+	Level   string `json:"@level"`
+	Message string `json:"@message"`
+	Type    string `json:"type"`
+	Hook    struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action         string  `json:"action"`
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+	} `json:"hook"`
+	Diagnostic *tfjson.Diagnostic `json:"diagnostic"`
+}
+
+// applyStream receives the output of `terraform apply -json`. It prints each
+// message as terraform's human-readable output would, redacted like the rest
+// of this package's logs, and records the resource timings and the error
+// diagnostics along the way.
+type applyStream struct {
+	out     io.Writer
+	partial []byte
+	timings []ResourceTiming
+	errors  []string
+}
+
+func (s *applyStream) Write(p []byte) (int, error) {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.handle(s.partial[:i])
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush handles a final message that was not terminated by a newline.
+func (s *applyStream) flush() {
+	if len(bytes.TrimSpace(s.partial)) > 0 {
+		s.handle(s.partial)
+	}
+	s.partial = nil
+}
+
+func (s *applyStream) handle(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	var msg applyMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		// Not a JSON message, e.g. a provider writing to stdout directly.
+		fmt.Fprintln(s.out, ai.Redact(string(line)))
+		return
+	}
+
+	switch msg.Type {
+	case "version":
+		return
+	case "apply_complete", "apply_errored":
+		s.timings = append(s.timings, ResourceTiming{
+			Address: msg.Hook.Resource.Addr,
+			Action:  msg.Hook.Action,
+			Elapsed: time.Duration(msg.Hook.ElapsedSeconds * float64(time.Second)),
+			Failed:  msg.Type == "apply_errored",
+		})
+	case "diagnostic":
+		s.printDiagnostic(msg)
+		return
+	}
+
+	text := ai.Redact(msg.Message)
+	switch {
+	case msg.Type == "apply_errored":
+		text = RedText(text)
+	case msg.Type == "apply_complete" || msg.Type == "change_summary":
+		text = GreenText(text)
+	}
+	fmt.Fprintln(s.out, text)
+}
+
+func (s *applyStream) printDiagnostic(msg applyMessage) {
+	if msg.Diagnostic == nil {
+		fmt.Fprintln(s.out, ai.Redact(msg.Message))
+		return
+	}
+	d := msg.Diagnostic
+	text := ai.Redact(d.Summary)
+	if d.Address != "" {
+		text = fmt.Sprintf("%s (%s)", text, d.Address)
+	}
+	if d.Detail != "" {
+		text += "\n\n" + ai.Redact(d.Detail)
+	}
+	if d.Severity == tfjson.DiagnosticSeverityError {
+		s.errors = append(s.errors, text)
+		fmt.Fprintf(s.out, "\n%s %s\n\n", RedText("Error:"), text)
+		return
+	}
+	fmt.Fprintf(s.out, "\n%s %s\n\n", YellowText("Warning:"), text)
+}
+
+// runApplyJSON applies with `terraform apply -json`, printing its progress
+// and, when slowest is positive, a report of the slowest resources, also
+// after a failed apply. Error diagnostics are added to the returned error,
+// since terraform writes them to the JSON stream instead of stderr.
+func runApplyJSON(tf *tfexec.Terraform, out io.Writer, opts []tfexec.ApplyOption, slowest int) error {
+	stream := &applyStream{out: out}
+	start := time.Now()
+	err := tf.ApplyJSON(context.Background(), stream, opts...)
+	stream.flush()
+
+	if slowest > 0 {
+		printSlowestResources(stream.timings, slowest, time.Since(start))
+	}
+	if err != nil && len(stream.errors) > 0 {
+		return fmt.Errorf("%w\n%s", err, strings.Join(stream.errors, "\n"))
+	}
+	return err
+}
+
+// slowestResources returns up to n timings, slowest first.
+func slowestResources(timings []ResourceTiming, n int) []ResourceTiming {
+	sorted := append([]ResourceTiming(nil), timings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Elapsed > sorted[j].Elapsed
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func printSlowestResources(timings []ResourceTiming, n int, total time.Duration) {
+	if len(timings) == 0 {
+		return
+	}
+	top := slowestResources(timings, n)
+	pterm.DefaultSection.Printfln("Slowest resources (%d of %d, apply took %s)", len(top), len(timings), total.Round(time.Second))
+	data := [][]string{{"RESOURCE", "ACTION", "DURATION", "RESULT"}}
+	for _, t := range top {
+		result := GreenText("ok")
+		if t.Failed {
+			result = RedText("failed")
+		}
+		data = append(data, []string{t.Address, t.Action, t.Elapsed.String(), result})
+	}
+	_ = pterm.DefaultTable.WithHasHeader(true).WithData(data).Render()
+}
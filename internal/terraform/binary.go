@@ -0,0 +1,374 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/hashicorp/go-version"
+)
+
+// terraformReleasesURL is where terraform releases are downloaded from.
+var terraformReleasesURL = "https://releases.hashicorp.com/terraform"
+
+var (
+	binaryMu sync.Mutex
+	// binaries caches the binary chosen for each version constraint, so a
+	// command that creates several terraform instances resolves it once.
+	binaries = map[string]string{}
+)
+
+var requiredVersionPattern = regexp.MustCompile(`(?m)^\s*required_version\s*=\s*"([^"]+)"`)
+
+// terraformBinary returns the terraform binary to run the configuration in
+// workingDir with. When stf.terraformVersion or a required_version
+// constraint applies, that is the terraform on PATH if it satisfies the
+// constraint, otherwise the newest matching release, downloaded once into
+// ~/.smurf/terraform/<version>. Without a constraint it is the terraform on
+// PATH.
+func terraformBinary(workingDir string) (string, error) {
+	return resolveBinary(workingDir, true)
+}
+
+// pinnedTerraform is terraformBinary for callers that pick the binary
+// themselves when no version applies: it returns "" in that case.
+func pinnedTerraform(workingDir string) (string, error) {
+	constraint, _, err := versionConstraint(workingDir)
+	if err != nil || constraint == "" {
+		return "", err
+	}
+	return terraformBinary(workingDir)
+}
+
+// resolveBinary is terraformBinary; with install false it neither downloads
+// nor prints anything, and falls back to PATH when no matching binary is at
+// hand, as shell completion needs.
+func resolveBinary(workingDir string, install bool) (string, error) {
+	constraint, source, err := versionConstraint(workingDir)
+	if err != nil {
+		return "", err
+	}
+	if constraint == "" {
+		return exec.LookPath("terraform")
+	}
+
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+	if path, ok := binaries[constraint]; ok {
+		return path, nil
+	}
+
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid terraform version constraint %q in %s: %w", constraint, source, err)
+	}
+
+	path, v := pathTerraform(constraints)
+	if path == "" {
+		path, v, err = cachedTerraform(constraints)
+		if err != nil {
+			return "", err
+		}
+	}
+	if path == "" {
+		if !install {
+			return exec.LookPath("terraform")
+		}
+		if path, v, err = installTerraform(constraints); err != nil {
+			return "", fmt.Errorf("no terraform matching %q (%s): %w", constraint, source, err)
+		}
+	}
+
+	if install {
+		Info("Using terraform %s (%s %s)", v, source, constraint)
+	}
+	binaries[constraint] = path
+	return path, nil
+}
+
+// versionConstraint returns the terraform version the configuration in
+// workingDir needs and where that requirement comes from:
+// stf.terraformVersion, which wins, or the required_version settings of its
+// .tf files, all of which must hold.
+func versionConstraint(workingDir string) (constraint, source string, err error) {
+	if v := strings.TrimSpace(configs.Stf.TerraformVersion); v != "" {
+		return v, "stf.terraformVersion", nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(workingDir, "*.tf"))
+	if err != nil {
+		return "", "", err
+	}
+	sort.Strings(files)
+	var required []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, m := range requiredVersionPattern.FindAllStringSubmatch(string(data), -1) {
+			required = append(required, strings.TrimSpace(m[1]))
+		}
+	}
+	if len(required) == 0 {
+		return "", "", nil
+	}
+	return strings.Join(required, ", "), "required_version", nil
+}
+
+// pathTerraform returns the terraform on PATH and its version if it
+// satisfies constraints.
+func pathTerraform(constraints version.Constraints) (string, *version.Version) {
+	path, err := exec.LookPath("terraform")
+	if err != nil {
+		return "", nil
+	}
+	out, err := exec.Command(path, "version", "-json").Output()
+	if err != nil {
+		return "", nil
+	}
+	var info struct {
+		Version string `json:"terraform_version"`
+	}
+	if json.Unmarshal(out, &info) != nil {
+		return "", nil
+	}
+	v, err := version.NewVersion(info.Version)
+	if err != nil || !constraints.Check(v) {
+		return "", nil
+	}
+	return path, v
+}
+
+// terraformCacheDir is ~/.smurf/terraform, holding one directory per
+// downloaded version.
+func terraformCacheDir() (string, error) {
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "terraform"), nil
+}
+
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "terraform.exe"
+	}
+	return "terraform"
+}
+
+// cachedTerraform returns the newest downloaded terraform that satisfies
+// constraints, or an empty path when there is none.
+func cachedTerraform(constraints version.Constraints) (string, *version.Version, error) {
+	dir, err := terraformCacheDir()
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("failed to read terraform cache %s: %w", dir, err)
+	}
+
+	var versions []string
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), binaryName())); err == nil {
+			versions = append(versions, e.Name())
+		}
+	}
+	v := newestMatching(versions, constraints)
+	if v == nil {
+		return "", nil, nil
+	}
+	return filepath.Join(dir, v.Original(), binaryName()), v, nil
+}
+
+// newestMatching returns the newest of versions that satisfies constraints.
+// Pre-releases only match constraints that name a pre-release.
+func newestMatching(versions []string, constraints version.Constraints) *version.Version {
+	var newest *version.Version
+	for _, s := range versions {
+		v, err := version.NewVersion(s)
+		if err != nil || !constraints.Check(v) {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// installTerraform downloads the newest terraform release that satisfies
+// constraints into the cache, after checking it against the release's
+// SHA256SUMS.
+func installTerraform(constraints version.Constraints) (string, *version.Version, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	var index struct {
+		Versions map[string]json.RawMessage `json:"versions"`
+	}
+	if err := getJSON(client, terraformReleasesURL+"/index.json", &index); err != nil {
+		return "", nil, fmt.Errorf("failed to list terraform releases: %w", err)
+	}
+	versions := make([]string, 0, len(index.Versions))
+	for v := range index.Versions {
+		versions = append(versions, v)
+	}
+	v := newestMatching(versions, constraints)
+	if v == nil {
+		return "", nil, fmt.Errorf("no terraform release satisfies it")
+	}
+
+	cacheDir, err := terraformCacheDir()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", nil, fmt.Errorf("failed to create terraform cache %s: %w", cacheDir, err)
+	}
+
+	Info("Downloading terraform %s to %s", v.Original(), cacheDir)
+	archive := fmt.Sprintf("terraform_%s_%s_%s.zip", v.Original(), runtime.GOOS, runtime.GOARCH)
+	releaseURL := fmt.Sprintf("%s/%s", terraformReleasesURL, v.Original())
+	sum, err := releaseChecksum(client, fmt.Sprintf("%s/terraform_%s_SHA256SUMS", releaseURL, v.Original()), archive)
+	if err != nil {
+		return "", nil, err
+	}
+
+	zipFile, err := os.CreateTemp(cacheDir, "download-*.zip")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(zipFile.Name())
+	defer zipFile.Close()
+	if err := download(client, releaseURL+"/"+archive, zipFile, sum); err != nil {
+		return "", nil, err
+	}
+
+	staging, err := os.MkdirTemp(cacheDir, v.Original()+".tmp-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := extractBinary(zipFile.Name(), filepath.Join(staging, binaryName())); err != nil {
+		return "", nil, err
+	}
+
+	// Rename the complete directory into place, so a run that is cut short
+	// or a concurrent download never leaves a half-written binary behind.
+	final := filepath.Join(cacheDir, v.Original())
+	if err := os.Rename(staging, final); err != nil {
+		if _, statErr := os.Stat(filepath.Join(final, binaryName())); statErr != nil {
+			return "", nil, fmt.Errorf("failed to install terraform %s: %w", v.Original(), err)
+		}
+	}
+	Success("Installed terraform %s", v.Original())
+	return filepath.Join(final, binaryName()), v, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// releaseChecksum returns the SHA-256 sum the SHA256SUMS file at url lists
+// for archive.
+func releaseChecksum(client *http.Client, url, archive string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download checksums: GET %s: %s", url, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == archive {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums: %w", err)
+	}
+	return "", fmt.Errorf("no terraform build for %s/%s (%s not in checksums)", runtime.GOOS, runtime.GOARCH, archive)
+}
+
+// download writes url to w and fails unless its SHA-256 sum is sum.
+func download(client *http.Client, url string, w io.Writer, sum string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download terraform: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download terraform: GET %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return fmt.Errorf("failed to download terraform: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, got, sum)
+	}
+	return nil
+}
+
+// extractBinary copies the terraform executable out of a release archive.
+func extractBinary(archive, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open terraform archive: %w", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != binaryName() {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read terraform archive: %w", err)
+		}
+		defer src.Close()
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		return out.Close()
+	}
+	return fmt.Errorf("terraform archive has no %s", binaryName())
+}
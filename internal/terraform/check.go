@@ -0,0 +1,249 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/pterm/pterm"
+)
+
+// CheckOptions configures a policy check of a Terraform plan.
+type CheckOptions struct {
+	Dir        string
+	PolicyDir  string
+	PlanFile   string   // existing binary plan file or plan JSON; generated when empty
+	Vars       []string // only used when the plan is generated
+	VarFiles   []string // only used when the plan is generated
+	Namespaces []string // Rego packages to evaluate; all packages when empty
+	FailOnWarn bool
+}
+
+// PolicyResult is a single deny/warn message reported by a Rego policy.
+type PolicyResult struct {
+	Namespace string
+	Message   string
+	Warning   bool
+}
+
+// conftestResult mirrors one entry of `conftest test --output json`.
+type conftestResult struct {
+	Filename  string `json:"filename"`
+	Namespace string `json:"namespace"`
+	Successes int    `json:"successes"`
+	Failures  []struct {
+		Msg string `json:"msg"`
+	} `json:"failures"`
+	Warnings []struct {
+		Msg string `json:"msg"`
+	} `json:"warnings"`
+}
+
+// Check evaluates the JSON representation of a Terraform plan against the
+// Rego policies in opts.PolicyDir using Conftest, and fails with the
+// violation details when any policy denies the plan. If opts.PlanFile is
+// empty a fresh plan is generated in opts.Dir first (the same way
+// `smurf stf plan` does), so the check can sit in the same pipeline step as
+// plan/apply.
+func Check(opts CheckOptions, useAI bool) error {
+	conftestBinary, err := exec.LookPath("conftest")
+	if err != nil {
+		Error("Conftest binary not found in PATH. Please install Conftest (https://www.conftest.dev).")
+		return fmt.Errorf("conftest binary not found: %w", err)
+	}
+
+	if _, err := os.Stat(opts.PolicyDir); err != nil {
+		Error("Policy directory not found: %s", opts.PolicyDir)
+		return fmt.Errorf("policy directory not found: %s", opts.PolicyDir)
+	}
+
+	planJSON, cleanup, err := planJSONForCheck(opts)
+	if err != nil {
+		explainError(useAI, err.Error())
+		return err
+	}
+	defer cleanup()
+
+	Step("Evaluating plan against policies in %s...", opts.PolicyDir)
+	args := []string{"test", planJSON, "--policy", opts.PolicyDir, "--output", "json", "--no-color"}
+	if len(opts.Namespaces) == 0 {
+		args = append(args, "--all-namespaces")
+	}
+	for _, ns := range opts.Namespaces {
+		args = append(args, "--namespace", ns)
+	}
+
+	cmd := exec.Command(conftestBinary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Conftest exits non-zero when a policy fails, so the exit status alone
+	// can't tell violations apart from a broken policy; only a run without
+	// parseable results counts as an execution error.
+	runErr := cmd.Run()
+	results, successes, parseErr := parseConftestResults(stdout.Bytes())
+	if parseErr != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" && runErr != nil {
+			msg = runErr.Error()
+		}
+		Error("Failed to evaluate policies: %s", msg)
+		explainError(useAI, msg)
+		return fmt.Errorf("failed to evaluate policies: %s", msg)
+	}
+
+	failures, warnings := printPolicyResults(results)
+	Info("%d passed, %d warning(s), %d failure(s)", successes, warnings, failures)
+
+	if failures > 0 {
+		Error("Plan violates %d policy rule(s)", failures)
+		return fmt.Errorf("plan violates %d policy rule(s)", failures)
+	}
+	if warnings > 0 && opts.FailOnWarn {
+		Error("Plan produced %d policy warning(s) and --fail-on-warn is set", warnings)
+		return fmt.Errorf("plan produced %d policy warning(s)", warnings)
+	}
+
+	Success("Plan complies with all policies")
+	return nil
+}
+
+// planJSONForCheck returns the path of a plan JSON document for Conftest,
+// generating and/or converting the plan as needed. The returned cleanup
+// function removes any temporary files it created.
+func planJSONForCheck(opts CheckOptions) (string, func(), error) {
+	noop := func() {
+		// Nothing was created, nothing to remove.
+	}
+
+	if strings.HasSuffix(opts.PlanFile, ".json") {
+		Info("Using plan JSON: %s", opts.PlanFile)
+		return opts.PlanFile, noop, nil
+	}
+
+	tf, err := GetTerraform(opts.Dir)
+	if err != nil {
+		return "", noop, err
+	}
+
+	var temps []string
+	cleanup := func() {
+		for _, f := range temps {
+			os.Remove(f)
+		}
+	}
+
+	planFile := opts.PlanFile
+	if planFile == "" {
+		f, err := os.CreateTemp("", "smurf-check-*.tfplan")
+		if err != nil {
+			return "", noop, fmt.Errorf("failed to create temporary plan file: %w", err)
+		}
+		f.Close()
+		planFile = f.Name()
+		temps = append(temps, planFile)
+
+		envVars, err := workspaceVars(opts.Dir)
+		if err != nil {
+			cleanup()
+			return "", noop, err
+		}
+		planOptions := []tfexec.PlanOption{tfexec.Out(planFile)}
+		for _, v := range append(envVars, opts.Vars...) {
+			planOptions = append(planOptions, tfexec.Var(v))
+		}
+		for _, vf := range opts.VarFiles {
+			planOptions = append(planOptions, tfexec.VarFile(vf))
+		}
+
+		Step("Generating Terraform plan for policy check...")
+		if _, err := tf.Plan(context.Background(), planOptions...); err != nil {
+			cleanup()
+			Error("Failed to generate plan: %v", err)
+			return "", noop, fmt.Errorf("failed to generate plan: %w", err)
+		}
+	} else {
+		Info("Using plan file: %s", planFile)
+	}
+
+	plan, err := tf.ShowPlanFile(context.Background(), planFile)
+	if err != nil {
+		cleanup()
+		Error("Failed to read plan file: %v", err)
+		return "", noop, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to encode plan as JSON: %w", err)
+	}
+
+	jsonFile := filepath.Join(os.TempDir(), filepath.Base(planFile)+".json")
+	if err := os.WriteFile(jsonFile, data, 0o600); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write plan JSON: %w", err)
+	}
+	temps = append(temps, jsonFile)
+
+	return jsonFile, cleanup, nil
+}
+
+// parseConftestResults decodes `conftest test --output json` output into a
+// flat list of policy results and the total number of passed rules.
+func parseConftestResults(data []byte) ([]PolicyResult, int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, 0, errors.New("conftest produced no output")
+	}
+
+	var raw []conftestResult
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("unable to parse conftest output: %w", err)
+	}
+
+	var results []PolicyResult
+	successes := 0
+	for _, r := range raw {
+		successes += r.Successes
+		for _, f := range r.Failures {
+			results = append(results, PolicyResult{Namespace: r.Namespace, Message: f.Msg})
+		}
+		for _, w := range r.Warnings {
+			results = append(results, PolicyResult{Namespace: r.Namespace, Message: w.Msg, Warning: true})
+		}
+	}
+	return results, successes, nil
+}
+
+// printPolicyResults renders policy results as a table and returns the
+// number of failures and warnings.
+func printPolicyResults(results []PolicyResult) (int, int) {
+	if len(results) == 0 {
+		return 0, 0
+	}
+
+	failures, warnings := 0, 0
+	tableData := pterm.TableData{{"SEVERITY", "NAMESPACE", "MESSAGE"}}
+	for _, r := range results {
+		severity := RedText("DENY")
+		if r.Warning {
+			severity = YellowText("WARN")
+			warnings++
+		} else {
+			failures++
+		}
+		tableData = append(tableData, []string{severity, r.Namespace, r.Message})
+	}
+
+	_ = pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	return failures, warnings
+}
@@ -0,0 +1,42 @@
+package terraform
+
+import "testing"
+
+func TestParseConftestResults(t *testing.T) {
+	t.Run("failures and warnings", func(t *testing.T) {
+		out := []byte(`[
+  {"filename": "plan.json", "namespace": "main", "successes": 2,
+   "failures": [{"msg": "aws_s3_bucket.logs must enable encryption"}],
+   "warnings": [{"msg": "aws_instance.web is missing the owner tag"}]},
+  {"filename": "plan.json", "namespace": "tags", "successes": 1}
+]`)
+		results, successes, err := parseConftestResults(out)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if successes != 3 {
+			t.Errorf("successes = %d, want 3", successes)
+		}
+		if len(results) != 2 {
+			t.Fatalf("got %d results, want 2", len(results))
+		}
+		if results[0].Warning || results[0].Namespace != "main" {
+			t.Errorf("first result = %+v, want a deny in main", results[0])
+		}
+		if !results[1].Warning {
+			t.Errorf("second result = %+v, want a warning", results[1])
+		}
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		if _, _, err := parseConftestResults([]byte("  \n")); err == nil {
+			t.Error("expected an error for empty output")
+		}
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		if _, _, err := parseConftestResults([]byte("Error: no policies found")); err == nil {
+			t.Error("expected an error for non-JSON output")
+		}
+	})
+}
@@ -4,33 +4,39 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
 
+	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/pterm/pterm"
 )
 
 // getTerraform locates the Terraform binary and initializes a Terraform instance
 func GetTerraform(dir string) (*tfexec.Terraform, error) {
-	terraformBinary, err := exec.LookPath("terraform")
-	if err != nil {
-		pterm.Error.Println("Terraform binary not found in PATH. Please install Terraform.")
-		return nil, err
-	}
-
 	// Use the specified directory or default to current directory
 	workingDir := "."
 	if dir != "" {
 		workingDir = dir
 	}
 
-	tf, err := tfexec.NewTerraform(workingDir, terraformBinary)
+	binary, err := terraformBinary(workingDir)
+	if err != nil {
+		pterm.Error.Printf("Terraform binary not available: %v\n", err)
+		return nil, err
+	}
+
+	tf, err := tfexec.NewTerraform(workingDir, binary)
 	if err != nil {
 		pterm.Error.Printf("Error creating Terraform instance: %v\n", err)
 		return nil, err
 	}
 
+	if err := applyRunIsolation(tf, workingDir); err != nil {
+		pterm.Error.Printf("Error preparing the Terraform environment: %v\n", err)
+		return nil, err
+	}
+	trackSecretsSource(tf)
+
 	return tf, nil
 }
 
@@ -43,7 +49,7 @@ func (w *CustomColorWriter) Write(p []byte) (n int, err error) {
 	scanner := bufio.NewScanner(bytes.NewReader(p))
 
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := ai.Redact(scanner.Text())
 
 		if len(strings.TrimSpace(line)) == 0 {
 			fmt.Fprintln(w.Writer)
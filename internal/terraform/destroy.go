@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -19,10 +18,18 @@ func Destroy(approve bool, lock bool,
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	vars = append(envVars, vars...)
+
 	Info("Preparing Terraform destroy operation in directory: %s", dir)
 
 	// Build plan options
@@ -34,7 +41,7 @@ func Destroy(approve bool, lock bool,
 	if len(vars) > 0 {
 		Info("Setting %d variable(s)...", len(vars))
 		for _, v := range vars {
-			Info("Using variable: %s", v)
+			Info("Using variable: %s", varName(v))
 			planOptions = append(planOptions, tfexec.Var(v))
 		}
 	}
@@ -44,7 +51,7 @@ func Destroy(approve bool, lock bool,
 		for _, vf := range varFiles {
 			if _, err := os.Stat(vf); os.IsNotExist(err) {
 				Error("Variable file not found: %s", vf)
-				ai.AIExplainError(useAI, err.Error())
+				explainError(useAI, err.Error())
 				return fmt.Errorf("variable file not found: %s", vf)
 			}
 			Info("Using var-file: %s", vf)
@@ -56,14 +63,14 @@ func Destroy(approve bool, lock bool,
 	_, err = tf.Plan(context.Background(), planOptions...)
 	if err != nil {
 		Error("Failed to generate destroy plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
 	show, err := tf.ShowPlanFile(context.Background(), "plan.out")
 	if err != nil {
 		Error("Failed to parse plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -75,7 +82,7 @@ func Destroy(approve bool, lock bool,
 	planDetail, err := tf.ShowPlanFileRaw(context.Background(), "plan.out")
 	if err != nil {
 		Error("Failed to show plan details: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -112,7 +119,7 @@ func Destroy(approve bool, lock bool,
 	err = tf.Apply(context.Background(), applyOptions...)
 	if err != nil {
 		Error("Terraform destroy failed: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -17,7 +16,14 @@ func DetectDrift(dir string, useAI bool) error {
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
+		return err
+	}
+
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -26,10 +32,14 @@ func DetectDrift(dir string, useAI bool) error {
 	Info("Starting Terraform drift detection...")
 
 	// Generate drift plan
-	_, err = tf.Plan(context.Background(), tfexec.Out(planFile), tfexec.Refresh(true))
+	planOptions := []tfexec.PlanOption{tfexec.Out(planFile), tfexec.Refresh(true)}
+	for _, v := range envVars {
+		planOptions = append(planOptions, tfexec.Var(v))
+	}
+	_, err = tf.Plan(context.Background(), planOptions...)
 	if err != nil {
 		Error("Failed to execute Terraform plan for drift detection: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -39,7 +49,7 @@ func DetectDrift(dir string, useAI bool) error {
 	plan, err := tf.ShowPlanFile(context.Background(), planFile)
 	if err != nil {
 		Error("Failed to read drift plan file: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -0,0 +1,166 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/pterm/pterm"
+)
+
+// consoleSensitive is what terraform console prints for a sensitive value.
+const consoleSensitive = "(sensitive value)"
+
+// EvalResult is the value of one expression evaluated by Eval.
+type EvalResult struct {
+	Expression string      `json:"expression"`
+	Value      interface{} `json:"value"`
+}
+
+// Eval evaluates expressions against the configuration and state in dir
+// with a non-interactive `terraform console`, the way `terraform console`
+// would answer them, and prints their values. Strings print raw and other
+// values as JSON, or every result as one JSON document with format "json".
+// With expect set, the value of the single expression must equal it (as a
+// string, or as JSON for other values) or Eval fails.
+func Eval(dir string, expressions, vars, varFiles []string, expect *string, format string, useAI bool) error {
+	if len(expressions) == 0 {
+		return errors.New("no expression to evaluate")
+	}
+	if expect != nil && len(expressions) != 1 {
+		return errors.New("--expect checks a single expression")
+	}
+	if format == "json" {
+		// Keep stdout for the JSON document alone.
+		pterm.SetDefaultOutput(os.Stderr)
+		defer pterm.SetDefaultOutput(os.Stdout)
+	}
+	results, err := evalExpressions(dir, expressions, vars, varFiles)
+	if err != nil {
+		pterm.Error.Printfln("Evaluation failed: %v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+
+	if format == "json" {
+		if err := utils.PrintJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if len(results) == 1 {
+				fmt.Println(formatEvalValue(r.Value))
+			} else {
+				fmt.Printf("%s = %s\n", r.Expression, formatEvalValue(r.Value))
+			}
+		}
+	}
+
+	if expect != nil {
+		if !evalMatches(results[0].Value, *expect) {
+			return fmt.Errorf("%s = %s, expected %s", results[0].Expression, formatEvalValue(results[0].Value), *expect)
+		}
+		if format != "json" {
+			pterm.Success.Printfln("%s is %s", results[0].Expression, *expect)
+		}
+	}
+	return nil
+}
+
+// evalExpressions feeds each expression, wrapped in jsonencode so its
+// value prints on one line, to terraform console and decodes the answers.
+func evalExpressions(dir string, expressions, vars, varFiles []string) ([]EvalResult, error) {
+	var input strings.Builder
+	for _, expr := range expressions {
+		if strings.ContainsAny(expr, "\r\n") {
+			return nil, fmt.Errorf("expression %q spans several lines; terraform console reads one expression per line", expr)
+		}
+		fmt.Fprintf(&input, "jsonencode(%s)\n", expr)
+	}
+
+	workingDir := "."
+	if dir != "" {
+		workingDir = dir
+	}
+	args := []string{"console"}
+	for _, v := range vars {
+		args = append(args, "-var="+v)
+	}
+	for _, f := range varFiles {
+		args = append(args, "-var-file="+f)
+	}
+	cmd := createSecureCommand(workingDir, args...)
+	cmd.Stdin = strings.NewReader(input.String())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("terraform console: %s", ai.Redact(msg))
+		}
+		return nil, fmt.Errorf("terraform console: %w", err)
+	}
+	return parseConsoleOutput(expressions, stdout.String())
+}
+
+// parseConsoleOutput decodes the console's answers to the jsonencode
+// wrapped expressions, one quoted JSON string per line.
+func parseConsoleOutput(expressions []string, out string) ([]EvalResult, error) {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != len(expressions) {
+		return nil, fmt.Errorf("terraform console answered %d of %d expressions:\n%s", len(lines), len(expressions), out)
+	}
+
+	results := make([]EvalResult, len(expressions))
+	for i, line := range lines {
+		expr := expressions[i]
+		if line == consoleSensitive {
+			return nil, fmt.Errorf("%s is sensitive; wrap it in nonsensitive() to print it", expr)
+		}
+		encoded, err := strconv.Unquote(line)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected console answer for %s: %s", expr, line)
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(encoded), &v); err != nil {
+			return nil, fmt.Errorf("unexpected console answer for %s: %w", expr, err)
+		}
+		results[i] = EvalResult{Expression: expr, Value: v}
+	}
+	return results, nil
+}
+
+// formatEvalValue prints a string as it is and any other value as compact
+// JSON.
+func formatEvalValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// evalMatches reports whether v equals expect: a string value its text, any
+// other value the JSON expect decodes to.
+func evalMatches(v interface{}, expect string) bool {
+	if s, ok := v.(string); ok {
+		return s == expect
+	}
+	var want interface{}
+	if err := json.Unmarshal([]byte(expect), &want); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(v, want)
+}
@@ -0,0 +1,53 @@
+package terraform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConsoleOutput(t *testing.T) {
+	exprs := []string{"local.vpc_cidr", "length(local.azs)", "local.tags"}
+	out := "\"\\\"10.20.0.0/16\\\"\"\n\"3\"\n\"{\\\"env\\\":\\\"prod\\\"}\"\n"
+	got, err := parseConsoleOutput(exprs, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EvalResult{
+		{Expression: "local.vpc_cidr", Value: "10.20.0.0/16"},
+		{Expression: "length(local.azs)", Value: float64(3)},
+		{Expression: "local.tags", Value: map[string]interface{}{"env": "prod"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConsoleOutput = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseConsoleOutput([]string{"var.db_password"}, "(sensitive value)\n"); err == nil || !strings.Contains(err.Error(), "nonsensitive") {
+		t.Errorf("a sensitive value: err = %v", err)
+	}
+	if _, err := parseConsoleOutput(exprs, "\"3\"\n"); err == nil {
+		t.Error("parseConsoleOutput accepted fewer answers than expressions")
+	}
+}
+
+func TestEvalMatches(t *testing.T) {
+	for _, tc := range []struct {
+		value  interface{}
+		expect string
+		want   bool
+	}{
+		{"10.20.0.0/16", "10.20.0.0/16", true},
+		{"10.20.0.0/16", "10.30.0.0/16", false},
+		{"3", "3", true},
+		{float64(3), "3", true},
+		{float64(3), "4", false},
+		{true, "true", true},
+		{[]interface{}{"a", "b"}, `["a","b"]`, true},
+		{[]interface{}{"a", "b"}, `["b","a"]`, false},
+		{float64(3), "three", false},
+	} {
+		if got := evalMatches(tc.value, tc.expect); got != tc.want {
+			t.Errorf("evalMatches(%v, %q) = %v, want %v", tc.value, tc.expect, got, tc.want)
+		}
+	}
+}
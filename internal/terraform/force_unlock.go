@@ -0,0 +1,229 @@
+package terraform
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/utils"
+)
+
+// LockInfo is the metadata terraform records with a state lock.
+type LockInfo struct {
+	ID        string `json:"id"`
+	Path      string `json:"path,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Who       string `json:"who,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Created   string `json:"created,omitempty"`
+	Info      string `json:"info,omitempty"`
+}
+
+// unlockAuditRecord is one line of the force-unlock audit log.
+type unlockAuditRecord struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Dir    string    `json:"dir"`
+	Lock   LockInfo  `json:"lock"`
+	Reason string    `json:"reason"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// unlockAuditFile is the audit log under the smurf home directory.
+const unlockAuditFile = "audit/terraform-force-unlock.jsonl"
+
+// lockFieldPattern matches a field of the "Lock Info:" block terraform
+// prints when it can't acquire a lock, with or without the diagnostic
+// border.
+var lockFieldPattern = regexp.MustCompile(`^[│|\s]*(ID|Path|Operation|Who|Version|Created|Info):\s*(.*?)\s*$`)
+
+// ForceUnlock releases the state lock of the configuration in dir after
+// showing who holds it, since when and for which operation. The lock ID
+// must be typed back to confirm; when expectedID is set it must also be
+// the ID of the current lock, so a lock taken since the incident started
+// is not released by mistake. Every unlock, and every failed one, is
+// appended to the audit log with reason.
+func ForceUnlock(dir, expectedID, reason string, useAI bool) error {
+	return forceUnlock(dir, expectedID, reason, os.Stdin, useAI)
+}
+
+func forceUnlock(dir, expectedID, reason string, in io.Reader, useAI bool) error {
+	Info("Inspecting the state lock...")
+	lock, err := inspectLock(dir)
+	if err != nil {
+		Error("%v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	if lock == nil {
+		Success("The state is not locked; nothing to unlock")
+		return nil
+	}
+	printLockInfo(lock)
+
+	if expectedID != "" && expectedID != lock.ID {
+		err := fmt.Errorf("the state is locked with ID %s, not %s; the lock changed hands, inspect it again before unlocking", lock.ID, expectedID)
+		Error("%v", err)
+		return err
+	}
+
+	input := bufio.NewReader(in)
+	if strings.TrimSpace(reason) == "" {
+		fmt.Print("\nReason for the unlock (recorded in the audit log): ")
+		reason, _ = input.ReadString('\n')
+		if reason = strings.TrimSpace(reason); reason == "" {
+			err := errors.New("a reason is required to force-unlock the state")
+			Error("%v", err)
+			return err
+		}
+	}
+	fmt.Printf("\n⚠️  Releasing a lock that is still in use can corrupt the state.\nType the lock ID to confirm: ")
+	typed, _ := input.ReadString('\n')
+	if strings.TrimSpace(typed) != lock.ID {
+		Info("The typed ID does not match; the lock was not released")
+		return errors.New("force-unlock cancelled: lock ID not confirmed")
+	}
+
+	record := unlockAuditRecord{
+		Time:   time.Now().UTC(),
+		Actor:  utils.Actor(),
+		Dir:    absDir(dir),
+		Lock:   *lock,
+		Reason: reason,
+		Result: "unlocked",
+	}
+	Info("Releasing lock %s...", lock.ID)
+	unlockErr := runTerraformCommandWithOutput(dir, "force-unlock", "-force", lock.ID)
+	if unlockErr != nil {
+		record.Result, record.Error = "failed", unlockErr.Error()
+	}
+	auditPath, auditErr := appendUnlockAudit(record)
+	if auditErr != nil {
+		Warn("Failed to record the audit note: %v", auditErr)
+	} else {
+		Info("Audit note recorded in %s", auditPath)
+	}
+
+	if unlockErr != nil {
+		Error("Failed to release the lock: %v", unlockErr)
+		explainError(useAI, unlockErr.Error())
+		return fmt.Errorf("failed to release the lock: %w", unlockErr)
+	}
+	Success("Lock %s released", lock.ID)
+	return nil
+}
+
+// inspectLock returns the current lock of the state in dir, or nil when it
+// is not locked. Terraform has no command that reads a lock, so a plan is
+// started with -lock-timeout=0s: it fails at once with the lock metadata
+// when the state is locked, and otherwise takes and releases the lock.
+func inspectLock(dir string) (*LockInfo, error) {
+	_, err := runTerraformCommand(dir, "plan", "-refresh=false", "-lock-timeout=0s", "-input=false", "-no-color", "-detailed-exitcode")
+	if err == nil {
+		return nil, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run terraform: %w", err)
+	}
+	if exitErr.ExitCode() == 2 {
+		return nil, nil
+	}
+	stderr := string(exitErr.Stderr)
+	if lock := parseLockInfo(stderr); lock != nil {
+		return lock, nil
+	}
+	if strings.Contains(stderr, "acquiring the state lock") {
+		return nil, fmt.Errorf("the state is locked but terraform did not report the lock: %s", strings.TrimSpace(stderr))
+	}
+	// Variables are checked after the lock is taken, so a plan that is only
+	// missing one got past the lock.
+	if strings.Contains(stderr, "No value for required variable") {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("failed to inspect the state lock: %w", commandError(err))
+}
+
+// parseLockInfo extracts the "Lock Info:" block from terraform output, or
+// returns nil when there is none.
+func parseLockInfo(output string) *LockInfo {
+	_, block, ok := strings.Cut(output, "Lock Info:")
+	if !ok {
+		return nil
+	}
+	lock := &LockInfo{}
+	fields := map[string]*string{
+		"ID": &lock.ID, "Path": &lock.Path, "Operation": &lock.Operation, "Who": &lock.Who,
+		"Version": &lock.Version, "Created": &lock.Created, "Info": &lock.Info,
+	}
+	for _, line := range strings.Split(block, "\n") {
+		if m := lockFieldPattern.FindStringSubmatch(line); m != nil && *fields[m[1]] == "" {
+			*fields[m[1]] = m[2]
+		}
+	}
+	if lock.ID == "" {
+		return nil
+	}
+	return lock
+}
+
+func printLockInfo(lock *LockInfo) {
+	Warn("The state is locked")
+	fmt.Printf("  ID:        %s\n", CyanText(lock.ID))
+	fmt.Printf("  Operation: %s\n", lock.Operation)
+	fmt.Printf("  Who:       %s\n", lock.Who)
+	created := lock.Created
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", lock.Created); err == nil {
+		created = fmt.Sprintf("%s (%s ago)", lock.Created, time.Since(t).Round(time.Second))
+	}
+	fmt.Printf("  Created:   %s\n", created)
+	fmt.Printf("  Path:      %s\n", lock.Path)
+	if lock.Version != "" {
+		fmt.Printf("  Version:   %s\n", lock.Version)
+	}
+	if lock.Info != "" {
+		fmt.Printf("  Info:      %s\n", lock.Info)
+	}
+}
+
+// appendUnlockAudit appends record to the force-unlock audit log and
+// returns its path.
+func appendUnlockAudit(record unlockAuditRecord) (string, error) {
+	home, err := utils.SmurfHome()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(home, unlockAuditFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
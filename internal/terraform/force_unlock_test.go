@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLockInfo(t *testing.T) {
+	want := LockInfo{
+		ID:        "5e1f0c3a-8d2b-4c41-9d7e-2f6a1b0c9e44",
+		Path:      "my-tf-state/prod/terraform.tfstate",
+		Operation: "OperationTypeApply",
+		Who:       "runner@ci-42",
+		Version:   "1.9.5",
+		Created:   "2026-10-17 08:12:44.123456 +0000 UTC",
+	}
+	outputs := map[string]string{
+		"plain": `Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        5e1f0c3a-8d2b-4c41-9d7e-2f6a1b0c9e44
+  Path:      my-tf-state/prod/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       runner@ci-42
+  Version:   1.9.5
+  Created:   2026-10-17 08:12:44.123456 +0000 UTC
+  Info:      
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.
+`,
+		"bordered": `╷
+│ Error: Error acquiring the state lock
+│ 
+│ Error message: resource temporarily unavailable
+│ Lock Info:
+│   ID:        5e1f0c3a-8d2b-4c41-9d7e-2f6a1b0c9e44
+│   Path:      my-tf-state/prod/terraform.tfstate
+│   Operation: OperationTypeApply
+│   Who:       runner@ci-42
+│   Version:   1.9.5
+│   Created:   2026-10-17 08:12:44.123456 +0000 UTC
+│   Info:      
+╵
+`,
+	}
+	for name, output := range outputs {
+		t.Run(name, func(t *testing.T) {
+			got := parseLockInfo(output)
+			if got == nil || *got != want {
+				t.Errorf("parseLockInfo = %+v, want %+v", got, want)
+			}
+		})
+	}
+	if got := parseLockInfo("Error: No value for required variable"); got != nil {
+		t.Errorf("parseLockInfo without a lock = %+v, want nil", got)
+	}
+}
+
+func TestAppendUnlockAudit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SMURF_HOME", home)
+	for _, result := range []string{"failed", "unlocked"} {
+		rec := unlockAuditRecord{Time: time.Now().UTC(), Actor: "oncall", Dir: "/infra/prod", Lock: LockInfo{ID: "abc"}, Reason: "runner died", Result: result}
+		if _, err := appendUnlockAudit(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(home, unlockAuditFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	var results []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec unlockAuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		results = append(results, rec.Result)
+	}
+	if len(results) != 2 || results[0] != "failed" || results[1] != "unlocked" {
+		t.Errorf("audit results = %v, want [failed unlocked]", results)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -219,7 +218,7 @@ func GetFmtTerraform() (*tfexec.Terraform, error) {
 		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	terraformPath, err := exec.LookPath("terraform")
+	terraformPath, err := terraformBinary(workDir)
 	if err != nil {
 		Error("Terraform executable not found: %v", err)
 		return nil, fmt.Errorf("terraform executable not found: %w", err)
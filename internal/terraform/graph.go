@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 )
@@ -17,7 +16,7 @@ func Graph(dir string, useAI bool) error {
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -26,7 +25,7 @@ func Graph(dir string, useAI bool) error {
 	graphDOT, err := tf.Graph(context.Background(), tfexec.DrawCycles(true))
 	if err != nil {
 		Error("Error generating Terraform graph: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -1,13 +1,25 @@
 package terraform
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 )
@@ -243,6 +255,74 @@ func TestOutputsToJSON(t *testing.T) {
 	}
 }
 
+func TestSensitiveLeaves(t *testing.T) {
+	value := map[string]interface{}{
+		"name": "db",
+		"auth": map[string]interface{}{"user": "admin", "password": "s3cret-pw"},
+		"keys": []interface{}{"key-one", "key-two"},
+		"port": float64(5432),
+	}
+	mask := map[string]interface{}{
+		"auth": map[string]interface{}{"password": true},
+		"keys": []interface{}{false, true},
+		"port": true,
+	}
+	got := sensitiveLeaves(value, mask)
+	want := map[string]bool{"s3cret-pw": true, "key-two": true, "5432": true}
+	if len(got) != len(want) {
+		t.Fatalf("sensitiveLeaves = %v, want %v", got, want)
+	}
+	for _, leaf := range got {
+		if !want[leaf] {
+			t.Errorf("unexpected sensitive leaf %q", leaf)
+		}
+	}
+	if got := sensitiveLeaves(value, false); got != nil {
+		t.Errorf("false mask = %v, want nil", got)
+	}
+}
+
+func TestVarName(t *testing.T) {
+	if got := varName("db_password=hunter2=x"); got != "db_password" {
+		t.Errorf("varName = %q, want db_password", got)
+	}
+}
+
+func TestRegisterPlanSecrets(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{{
+			Change: &tfjson.Change{
+				After:          map[string]interface{}{"token": "plan-token-value", "name": "web"},
+				AfterSensitive: map[string]interface{}{"token": true},
+			},
+		}},
+		Variables: map[string]*tfjson.PlanVariable{
+			"api_key": {Value: "variable-api-key"},
+			"region":  {Value: "us-east-1"},
+		},
+		Config: &tfjson.Config{RootModule: &tfjson.ConfigModule{
+			Variables: map[string]*tfjson.ConfigVariable{
+				"api_key": {Sensitive: true},
+				"region":  {},
+			},
+		}},
+	}
+	registerPlanSecrets(plan)
+	registerOutputSecrets(map[string]tfexec.OutputMeta{
+		"conn": {Sensitive: true, Value: json.RawMessage(`"output-conn-string"`)},
+	})
+
+	got := ai.Redact("token plan-token-value key variable-api-key conn output-conn-string name web in us-east-1")
+	for _, secret := range []string{"plan-token-value", "variable-api-key", "output-conn-string"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%q was not redacted: %s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "web") || !strings.Contains(got, "us-east-1") {
+		t.Errorf("non-sensitive values were redacted: %s", got)
+	}
+}
+
 func TestColorizeNoChanges(t *testing.T) {
 	plan := "Terraform will perform the following actions\nNo changes. Your infrastructure matches the configuration\ndone"
 	got := colorizeNoChanges(plan)
@@ -286,15 +366,19 @@ func TestBuildPlanOptions(t *testing.T) {
 }
 
 func TestBuildApplyOptions(t *testing.T) {
-	// lock + dirOrPlan are always present; state and each target add one more.
-	opts := buildApplyOptions(true, "plan.out", "terraform.tfstate", []string{"a", "b"})
+	// lock + dirOrPlan are always present; parallelism, state and each target add one more.
+	opts := buildApplyOptions(true, "plan.out", "terraform.tfstate", []string{"a", "b"}, 0)
 	if len(opts) != 5 {
 		t.Errorf("len(opts) = %d, want 5", len(opts))
 	}
-	optsNoState := buildApplyOptions(false, ".", "", nil)
+	optsNoState := buildApplyOptions(false, ".", "", nil, 0)
 	if len(optsNoState) != 2 {
 		t.Errorf("len(optsNoState) = %d, want 2", len(optsNoState))
 	}
+	optsParallel := buildApplyOptions(false, ".", "", nil, 4)
+	if len(optsParallel) != 3 {
+		t.Errorf("len(optsParallel) = %d, want 3", len(optsParallel))
+	}
 }
 
 func TestAddVarsAndFiles(t *testing.T) {
@@ -332,3 +416,164 @@ func TestTerraformCommand(t *testing.T) {
 		t.Errorf("terraformCommand = %q, want an absolute path or \"terraform\"", got)
 	}
 }
+
+func TestVersionConstraint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"versions.tf": "terraform {\n  required_version = \">= 1.5.0\"\n}\n",
+		"main.tf":     "terraform {\n  required_version = \"< 2.0.0\"\n}\n# required_version = \"1.0\" in a comment\n",
+		"notes.txt":   "required_version = \"0.12\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	got, source, err := versionConstraint(dir)
+	if err != nil {
+		t.Fatalf("versionConstraint: %v", err)
+	}
+	if got != "< 2.0.0, >= 1.5.0" || source != "required_version" {
+		t.Errorf("versionConstraint = %q (%s), want \"< 2.0.0, >= 1.5.0\" (required_version)", got, source)
+	}
+
+	configs.Stf.TerraformVersion = "1.9.5"
+	t.Cleanup(func() { configs.Stf.TerraformVersion = "" })
+	if got, source, _ := versionConstraint(dir); got != "1.9.5" || source != "stf.terraformVersion" {
+		t.Errorf("versionConstraint = %q (%s), want 1.9.5 (stf.terraformVersion)", got, source)
+	}
+
+	configs.Stf.TerraformVersion = ""
+	if got, _, _ := versionConstraint(t.TempDir()); got != "" {
+		t.Errorf("versionConstraint without constraints = %q, want empty", got)
+	}
+}
+
+func TestNewestMatching(t *testing.T) {
+	versions := []string{"1.5.7", "1.9.5", "1.10.0-beta1", "1.9.8", "0.15.5", "not-a-version"}
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"~> 1.9.0", "1.9.8"},
+		{">= 1.5, < 1.9", "1.5.7"},
+		{"1.9.5", "1.9.5"},
+		{">= 1.0", "1.9.8"},
+		{"1.10.0-beta1", "1.10.0-beta1"},
+		{">= 2.0", ""},
+	}
+	for _, tt := range tests {
+		c, err := version.NewConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("NewConstraint(%q): %v", tt.constraint, err)
+		}
+		got := ""
+		if v := newestMatching(versions, c); v != nil {
+			got = v.Original()
+		}
+		if got != tt.want {
+			t.Errorf("newestMatching(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestInstallTerraform(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create(binaryName())
+	_, _ = w.Write([]byte("#!/bin/sh\necho terraform\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	sum := sha256.Sum256(archive.Bytes())
+	zipName := fmt.Sprintf("terraform_1.9.8_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name":"terraform","versions":{"1.9.5":{},"1.9.8":{},"1.10.0":{}}}`)
+	})
+	mux.HandleFunc("/1.9.8/terraform_1.9.8_SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", hex.EncodeToString(sum[:]), zipName)
+	})
+	mux.HandleFunc("/1.9.8/"+zipName, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive.Bytes())
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	oldURL := terraformReleasesURL
+	terraformReleasesURL = srv.URL
+	t.Cleanup(func() { terraformReleasesURL = oldURL })
+	t.Setenv("SMURF_HOME", t.TempDir())
+
+	c, _ := version.NewConstraint("~> 1.9.0")
+	path, v, err := installTerraform(c)
+	if err != nil {
+		t.Fatalf("installTerraform: %v", err)
+	}
+	if v.Original() != "1.9.8" {
+		t.Errorf("installed version = %s, want 1.9.8", v.Original())
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("installed binary %s missing or not executable: %v", path, err)
+	}
+
+	cached, cv, err := cachedTerraform(c)
+	if err != nil || cached != path || cv.Original() != "1.9.8" {
+		t.Errorf("cachedTerraform = %s, %v, %v; want %s", cached, cv, err, path)
+	}
+
+	sum[0] ^= 0xff
+	mux.HandleFunc("/1.9.5/terraform_1.9.5_SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  terraform_1.9.5_%s_%s.zip\n", hex.EncodeToString(sum[:]), runtime.GOOS, runtime.GOARCH)
+	})
+	mux.HandleFunc(fmt.Sprintf("/1.9.5/terraform_1.9.5_%s_%s.zip", runtime.GOOS, runtime.GOARCH), func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive.Bytes())
+	})
+	exact, _ := version.NewConstraint("1.9.5")
+	if _, _, err := installTerraform(exact); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("installTerraform with a bad checksum: err = %v, want checksum mismatch", err)
+	}
+}
+
+func TestApplyStream(t *testing.T) {
+	ai.RegisterSecrets("hunter2-secret")
+
+	var out bytes.Buffer
+	stream := &applyStream{out: &out}
+	messages := []string{
+		`{"@level":"info","@message":"Terraform 1.9.5","type":"version","terraform":"1.9.5","ui":"1.2"}`,
+		`{"@level":"info","@message":"aws_vpc.main: Creating...","type":"apply_start","hook":{"resource":{"addr":"aws_vpc.main"},"action":"create"}}`,
+		`{"@level":"info","@message":"aws_vpc.main: Creation complete after 3s [id=vpc-1]","type":"apply_complete","hook":{"resource":{"addr":"aws_vpc.main"},"action":"create","elapsed_seconds":3}}`,
+		`{"@level":"info","@message":"aws_db_instance.db: Creation complete after 7m12s","type":"apply_complete","hook":{"resource":{"addr":"aws_db_instance.db"},"action":"create","elapsed_seconds":432}}`,
+		`{"@level":"error","@message":"aws_iam_role.ci: Creation errored after 1s","type":"apply_errored","hook":{"resource":{"addr":"aws_iam_role.ci"},"action":"create","elapsed_seconds":1}}`,
+		`{"@level":"error","@message":"Error: access denied","type":"diagnostic","diagnostic":{"severity":"error","summary":"access denied","detail":"token hunter2-secret is not allowed","address":"aws_iam_role.ci"}}`,
+	}
+	// Split the stream mid-message, as pipe reads do.
+	data := strings.Join(messages, "\n")
+	stream.Write([]byte(data[:50]))
+	stream.Write([]byte(data[50:]))
+	stream.flush()
+
+	got := out.String()
+	for _, want := range []string{"aws_vpc.main: Creating...", "Creation complete after 7m12s", "access denied (aws_iam_role.ci)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Terraform 1.9.5") || strings.Contains(got, "hunter2-secret") {
+		t.Errorf("output has the version message or an unredacted secret:\n%s", got)
+	}
+	if len(stream.errors) != 1 {
+		t.Errorf("errors = %q, want the one diagnostic", stream.errors)
+	}
+
+	top := slowestResources(stream.timings, 2)
+	if len(top) != 2 || top[0].Address != "aws_db_instance.db" || top[0].Elapsed != 432*time.Second || top[1].Address != "aws_vpc.main" {
+		t.Errorf("slowestResources = %+v", top)
+	}
+	if len(stream.timings) != 3 || !stream.timings[2].Failed {
+		t.Errorf("timings = %+v, want 3 with the last failed", stream.timings)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"os"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -18,10 +17,18 @@ func Import(address, id, dir string, vars, varFiles []string,
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	vars = append(envVars, vars...)
+
 	// Setup output
 	tf.SetStdout(os.Stdout)
 	tf.SetStderr(os.Stderr)
@@ -48,7 +55,7 @@ func Import(address, id, dir string, vars, varFiles []string,
 	// Apply variables
 	if len(vars) > 0 {
 		for _, v := range vars {
-			Info("Applying variable: %s", v)
+			Info("Applying variable: %s", varName(v))
 			importOptions = append(importOptions, tfexec.Var(v))
 		}
 	}
@@ -82,7 +89,7 @@ func Import(address, id, dir string, vars, varFiles []string,
 	err = tf.Import(context.Background(), address, id, importOptions...)
 	if err != nil {
 		Error("Failed to import resource: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/pterm/pterm"
 )
 
@@ -19,17 +20,20 @@ func logTime() string {
 	return time.Now().Format("15:04:05")
 }
 
+// The helpers below pass every message through ai.Redact, so sensitive
+// values terraform reported (see sensitive.go) never reach the log.
+
 // Info prints informational messages in cyan
 func Info(message string, args ...interface{}) {
 	timestamp := pterm.LightCyan(logTime())
-	text := fmt.Sprintf(message, args...)
+	text := ai.Redact(fmt.Sprintf(message, args...))
 	fmt.Printf("%s ℹ %s\n", timestamp, text)
 }
 
 // Success prints success messages in green
 func Success(message string, args ...interface{}) {
 	timestamp := pterm.LightGreen(logTime())
-	text := fmt.Sprintf(message, args...)
+	text := ai.Redact(fmt.Sprintf(message, args...))
 	coloredText := pterm.LightGreen(text)
 	fmt.Printf("%s ✔ %s\n", timestamp, coloredText)
 }
@@ -37,7 +41,7 @@ func Success(message string, args ...interface{}) {
 // Warn prints warning messages in yellow
 func Warn(message string, args ...interface{}) {
 	timestamp := pterm.Yellow(logTime())
-	text := fmt.Sprintf(message, args...)
+	text := ai.Redact(fmt.Sprintf(message, args...))
 	coloredText := pterm.Yellow(text)
 	fmt.Printf("%s ⚠ %s\n", timestamp, coloredText)
 }
@@ -45,7 +49,7 @@ func Warn(message string, args ...interface{}) {
 // Error prints error messages in red
 func Error(message string, args ...interface{}) {
 	timestamp := pterm.LightRed(logTime())
-	text := fmt.Sprintf(message, args...)
+	text := ai.Redact(fmt.Sprintf(message, args...))
 	coloredText := pterm.LightRed(text)
 	fmt.Printf("%s ✖ %s\n", timestamp, coloredText)
 }
@@ -53,12 +57,12 @@ func Error(message string, args ...interface{}) {
 // Step prints step or progress messages in blue
 func Step(message string, args ...interface{}) {
 	timestamp := pterm.LightBlue(logTime())
-	text := fmt.Sprintf(message, args...)
+	text := ai.Redact(fmt.Sprintf(message, args...))
 	coloredText := pterm.LightBlue(text)
 	fmt.Printf("%s ▶ %s\n", timestamp, coloredText)
 }
 
 // Warning prints a warning message
 func Warning(format string, args ...interface{}) {
-	pterm.Warning.Printf(format+"\n", args...)
+	pterm.Warning.Println(ai.Redact(fmt.Sprintf(format, args...)))
 }
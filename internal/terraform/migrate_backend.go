@@ -0,0 +1,275 @@
+package terraform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backendOverrideFile is the override file migrate-backend writes the new
+// backend block to. Terraform lets a backend block in an override file
+// replace the one in the configuration, whatever its type.
+const backendOverrideFile = "smurf_backend_override.tf"
+
+// backendTarget is the backend a migration moves the state to.
+type backendTarget struct {
+	Type   string
+	Config map[string]string
+}
+
+// stateSummary is what a migration compares before and after: the same
+// state must arrive, so lineage, serial and resource count must not change.
+type stateSummary struct {
+	Serial    int64
+	Lineage   string
+	Resources int
+}
+
+// MigrateBackend moves the state of the configuration in dir to the backend
+// given by to (s3://BUCKET/KEY, gs://BUCKET/PREFIX,
+// azurerm://ACCOUNT/CONTAINER/KEY or a local path), with backendConfig as
+// extra KEY=VALUE backend settings. The current state is backed up next to
+// the configuration first, and the migrated state is checked against it;
+// when the check fails the configuration is pointed back at the old backend,
+// which still holds the state.
+func MigrateBackend(dir, to string, backendConfig []string, autoApprove, useAI bool) error {
+	target, err := parseBackendURL(to, backendConfig)
+	if err != nil {
+		Error("%v", err)
+		return err
+	}
+
+	Info("Reading the current state...")
+	data, err := runTerraformCommand(dir, "state", "pull")
+	if err != nil {
+		err = fmt.Errorf("failed to read the current state (has the directory been initialized?): %w", commandError(err))
+		Error("%v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		err := errors.New("the current backend holds no state; run `smurf stf init` with the new backend instead")
+		Error("%v", err)
+		return err
+	}
+	before, err := readStateSummary(data)
+	if err != nil {
+		Error("%v", err)
+		return err
+	}
+	Info("Current state - Serial: %d, Lineage: %s, Resources: %d", before.Serial, before.Lineage, before.Resources)
+
+	backupPath := filepath.Join(dir, fmt.Sprintf("terraform.tfstate.backup.%d", time.Now().Unix()))
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		Error("Failed to back up the state: %v", err)
+		return fmt.Errorf("failed to back up the state: %w", err)
+	}
+	Success("State backed up to: %s", backupPath)
+
+	if !autoApprove && !confirmAction(fmt.Sprintf("Migrate the state to the %s backend at %s?", target.Type, to)) {
+		Info("Migration cancelled")
+		return nil
+	}
+
+	overridePath := filepath.Join(dir, backendOverrideFile)
+	restore, err := writeBackendOverride(overridePath, target)
+	if err != nil {
+		Error("%v", err)
+		return err
+	}
+
+	Info("Migrating the state to the %s backend...", target.Type)
+	if err := runTerraformCommandWithOutput(dir, "init", "-migrate-state", "-force-copy", "-input=false"); err != nil {
+		restore()
+		Error("State migration failed: %v", err)
+		Info("The state is unchanged in the old backend; a copy is in %s", backupPath)
+		explainError(useAI, err.Error())
+		return fmt.Errorf("state migration failed: %w", err)
+	}
+
+	data, err = runTerraformCommand(dir, "state", "pull")
+	if err == nil {
+		var after stateSummary
+		if after, err = readStateSummary(data); err == nil {
+			Info("Migrated state - Serial: %d, Lineage: %s, Resources: %d", after.Serial, after.Lineage, after.Resources)
+			err = verifyMigratedState(before, after)
+		}
+	} else {
+		err = fmt.Errorf("failed to read the migrated state: %w", commandError(err))
+	}
+	if err != nil {
+		Error("%v", err)
+		Warning("Pointing the configuration back at the old backend...")
+		restore()
+		if rerr := runTerraformCommandWithOutput(dir, "init", "-reconfigure", "-input=false"); rerr != nil {
+			Error("Failed to reinitialize the old backend: %v", rerr)
+		}
+		Info("A copy of the state from before the migration is in %s", backupPath)
+		explainError(useAI, err.Error())
+		return err
+	}
+
+	Success("State migrated to the %s backend", target.Type)
+	Info("Move the backend block in %s into your configuration, then delete the file", overridePath)
+	return nil
+}
+
+// parseBackendURL turns a migrate-backend --to URL and KEY=VALUE settings
+// into a backend.
+func parseBackendURL(to string, backendConfig []string) (backendTarget, error) {
+	var t backendTarget
+	scheme, rest, ok := strings.Cut(to, "://")
+	if !ok {
+		scheme, rest = "local", to
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	switch scheme {
+	case "s3":
+		if len(parts) < 2 {
+			return t, fmt.Errorf("invalid backend %q: want s3://BUCKET/KEY", to)
+		}
+		t = backendTarget{Type: "s3", Config: map[string]string{"bucket": parts[0], "key": strings.Join(parts[1:], "/")}}
+	case "gs", "gcs":
+		if len(parts) < 2 {
+			return t, fmt.Errorf("invalid backend %q: want gs://BUCKET/PREFIX", to)
+		}
+		t = backendTarget{Type: "gcs", Config: map[string]string{"bucket": parts[0], "prefix": strings.Join(parts[1:], "/")}}
+	case "azurerm":
+		if len(parts) < 3 {
+			return t, fmt.Errorf("invalid backend %q: want azurerm://ACCOUNT/CONTAINER/KEY", to)
+		}
+		t = backendTarget{Type: "azurerm", Config: map[string]string{
+			"storage_account_name": parts[0],
+			"container_name":       parts[1],
+			"key":                  strings.Join(parts[2:], "/"),
+		}}
+	case "local", "file":
+		if rest == "" {
+			return t, fmt.Errorf("invalid backend %q: want a path", to)
+		}
+		path, err := url.PathUnescape(rest)
+		if err != nil {
+			return t, fmt.Errorf("invalid backend %q: %w", to, err)
+		}
+		t = backendTarget{Type: "local", Config: map[string]string{"path": path}}
+	default:
+		return t, fmt.Errorf("unsupported backend %q: use s3://, gs://, azurerm:// or a local path", scheme)
+	}
+	if t.Type != "local" && parts[0] == "" {
+		return t, fmt.Errorf("invalid backend %q: missing bucket", to)
+	}
+
+	for _, kv := range backendConfig {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return t, fmt.Errorf("invalid backend setting %q: want KEY=VALUE", kv)
+		}
+		t.Config[strings.TrimSpace(k)] = v
+	}
+	return t, nil
+}
+
+// renderBackendOverride is the override file holding the backend block of t.
+func renderBackendOverride(t backendTarget) string {
+	keys := make([]string, 0, len(t.Config))
+	width := 0
+	for k := range t.Config {
+		keys = append(keys, k)
+		width = max(width, len(k))
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Written by smurf stf migrate-backend. Move this backend block into your\n")
+	b.WriteString("# configuration and delete this file.\n")
+	b.WriteString("terraform {\n")
+	fmt.Fprintf(&b, "  backend %q {\n", t.Type)
+	for _, k := range keys {
+		v := strings.ReplaceAll(t.Config[k], "${", "$${")
+		fmt.Fprintf(&b, "    %-*s = %q\n", width, k, v)
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+// writeBackendOverride writes the backend block of t to path and returns a
+// func that puts back whatever was there before.
+func writeBackendOverride(path string, t backendTarget) (func(), error) {
+	previous, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(renderBackendOverride(t)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return func() {
+		var err error
+		if existed {
+			err = os.WriteFile(path, previous, 0644)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			Warn("Failed to restore %s: %v", path, err)
+		}
+	}, nil
+}
+
+// readStateSummary reads the serial, lineage and number of managed resource
+// instances of a state file.
+func readStateSummary(data []byte) (stateSummary, error) {
+	var state struct {
+		Serial    int64  `json:"serial"`
+		Lineage   string `json:"lineage"`
+		Resources []struct {
+			Mode      string            `json:"mode"`
+			Instances []json.RawMessage `json:"instances"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return stateSummary{}, fmt.Errorf("failed to parse the state: %w", err)
+	}
+	s := stateSummary{Serial: state.Serial, Lineage: state.Lineage}
+	for _, r := range state.Resources {
+		if r.Mode == "managed" {
+			s.Resources += len(r.Instances)
+		}
+	}
+	return s, nil
+}
+
+// verifyMigratedState reports how the migrated state differs from the state
+// before the migration, or nil when it is the same state.
+func verifyMigratedState(before, after stateSummary) error {
+	var diffs []string
+	if after.Lineage != before.Lineage {
+		diffs = append(diffs, fmt.Sprintf("lineage %s, want %s", after.Lineage, before.Lineage))
+	}
+	if after.Serial != before.Serial {
+		diffs = append(diffs, fmt.Sprintf("serial %d, want %d", after.Serial, before.Serial))
+	}
+	if after.Resources != before.Resources {
+		diffs = append(diffs, fmt.Sprintf("%d resources, want %d", after.Resources, before.Resources))
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("migrated state does not match the original: %s", strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// commandError adds the stderr of a failed terraform command to its error.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
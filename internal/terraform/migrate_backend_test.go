@@ -0,0 +1,62 @@
+package terraform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBackendURL(t *testing.T) {
+	tests := []struct {
+		to     string
+		extra  []string
+		want   string
+		errMsg string
+	}{
+		{to: "s3://tf-state/prod/app.tfstate", extra: []string{"region=us-east-1"},
+			want: "backend \"s3\" {\n    bucket = \"tf-state\"\n    key    = \"prod/app.tfstate\"\n    region = \"us-east-1\"\n  }"},
+		{to: "gs://tf-state/prod", want: "backend \"gcs\" {\n    bucket = \"tf-state\"\n    prefix = \"prod\"\n  }"},
+		{to: "azurerm://acct/state/prod.tfstate", want: "container_name       = \"state\""},
+		{to: "state/terraform.tfstate", want: "backend \"local\" {\n    path = \"state/terraform.tfstate\"\n  }"},
+		{to: "s3://tf-state", errMsg: "want s3://BUCKET/KEY"},
+		{to: "consul://state", errMsg: "unsupported backend"},
+		{to: "s3://tf-state/key", extra: []string{"region"}, errMsg: "want KEY=VALUE"},
+	}
+	for _, tt := range tests {
+		target, err := parseBackendURL(tt.to, tt.extra)
+		if tt.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("parseBackendURL(%q) err = %v, want it to contain %q", tt.to, err, tt.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBackendURL(%q): %v", tt.to, err)
+			continue
+		}
+		if got := renderBackendOverride(target); !strings.Contains(got, tt.want) {
+			t.Errorf("renderBackendOverride(%q) =\n%s\nwant it to contain\n%s", tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyMigratedState(t *testing.T) {
+	state := `{"version":4,"serial":7,"lineage":"abc","resources":[
+		{"mode":"managed","type":"aws_s3_bucket","instances":[{},{}]},
+		{"mode":"data","type":"aws_caller_identity","instances":[{}]}]}`
+	before, err := readStateSummary([]byte(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != (stateSummary{Serial: 7, Lineage: "abc", Resources: 2}) {
+		t.Fatalf("readStateSummary = %+v", before)
+	}
+
+	if err := verifyMigratedState(before, before); err != nil {
+		t.Errorf("verifyMigratedState(same) = %v", err)
+	}
+	after := stateSummary{Serial: 8, Lineage: "abc", Resources: 1}
+	err = verifyMigratedState(before, after)
+	if err == nil || !strings.Contains(err.Error(), "serial 8, want 7, 1 resources, want 2") {
+		t.Errorf("verifyMigratedState = %v", err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"os"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/clouddrove/smurf/internal/utils"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/pterm/pterm"
@@ -56,7 +55,7 @@ func Output(dir, format string, useAI bool) error {
 	if err := tf.Refresh(context.Background()); err != nil {
 		if isTable {
 			pterm.Error.Printf("Error refreshing  state: %v\n", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 		}
 		return err
 	}
@@ -65,10 +64,11 @@ func Output(dir, format string, useAI bool) error {
 	if err != nil {
 		if isTable {
 			pterm.Error.Printf("Error getting Infrastructure outputs: %v\n", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 		}
 		return err
 	}
+	registerOutputSecrets(outputs)
 
 	if !isTable {
 		return utils.PrintJSON(outputsToJSON(outputs))
@@ -7,7 +7,6 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -25,10 +24,18 @@ func Plan(vars []string, varFiles []string,
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return false, err
 	}
 
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
+		return false, err
+	}
+	vars = append(envVars, vars...)
+
 	var outputBuffer bytes.Buffer
 	customWriter := &CustomColorWriter{
 		Buffer: &outputBuffer,
@@ -73,7 +80,7 @@ func Plan(vars []string, varFiles []string,
 	if len(vars) > 0 {
 		Info("Setting %d variable(s)...", len(vars))
 		for _, v := range vars {
-			Info("Using variable: %s", v)
+			Info("Using variable: %s", varName(v))
 			planOptions = append(planOptions, tfexec.Var(v))
 		}
 	}
@@ -84,7 +91,7 @@ func Plan(vars []string, varFiles []string,
 		for _, vf := range varFiles {
 			if _, err := os.Stat(vf); os.IsNotExist(err) {
 				Error("Variable file not found: %s", vf)
-				ai.AIExplainError(useAI, fmt.Sprintf("Variable file not found: %s", vf))
+				explainError(useAI, fmt.Sprintf("Variable file not found: %s", vf))
 				return false, fmt.Errorf("variable file not found: %s", vf)
 			}
 			Info("Using var-file: %s", vf)
@@ -117,7 +124,7 @@ func Plan(vars []string, varFiles []string,
 	Step("Generating Terraform plan...")
 	hasChanges, err := tf.Plan(context.Background(), planOptions...)
 	if err != nil {
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return false, err
 	}
 
@@ -126,7 +133,12 @@ func Plan(vars []string, varFiles []string,
 		// Check if plan file was created and has content
 		if fileInfo, err := os.Stat(out); err == nil && fileInfo.Size() > 0 {
 			Success("Terraform plan saved to: %s", out)
-			Info("To apply this plan, run: smurf stf apply %s", out)
+			sum, err := writePlanHash(out)
+			if err != nil {
+				return hasChanges, err
+			}
+			Info("Plan hash: %s (saved to %s%s)", sum, out, planHashSuffix)
+			Info("To apply exactly this plan, run: smurf stf apply %s --approved-hash %s", out, sum)
 		} else if !hasChanges {
 			Success("No changes; the infrastructure is up to date with the current configuration.")
 			// Clean up empty plan file
@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// planHashSuffix names the file plan writes a saved plan's hash to, next
+// to the plan, in sha256sum format.
+const planHashSuffix = ".sha256"
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// planFileHash is the SHA-256 of the plan file at path, the exact bytes
+// terraform applies.
+func planFileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read plan file: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read plan file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writePlanHash records the hash of the saved plan planFile in
+// planFile.sha256, for a reviewer to approve, and returns it.
+func writePlanHash(planFile string) (string, error) {
+	sum, err := planFileHash(planFile)
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(planFile))
+	if err := os.WriteFile(planFile+planHashSuffix, []byte(line), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write plan hash: %w", err)
+	}
+	return sum, nil
+}
+
+// parseApprovedHash returns the SHA-256 of an --approved-hash: the hash
+// itself, optionally prefixed with sha256:, or a file plan wrote it to.
+func parseApprovedHash(approved string) (string, error) {
+	value := strings.TrimSpace(approved)
+	if data, err := os.ReadFile(value); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("approved hash file %s is empty", value)
+		}
+		value = fields[0]
+	}
+	value = strings.ToLower(strings.TrimPrefix(value, "sha256:"))
+	if !sha256Pattern.MatchString(value) {
+		return "", fmt.Errorf("invalid approved hash %q: must be a SHA-256 hex digest or the %s file plan wrote", approved, planHashSuffix)
+	}
+	return value, nil
+}
+
+// verifyApprovedHash fails unless the plan file on disk is the one whose
+// hash was approved, so a later stage applies exactly what was reviewed.
+func verifyApprovedHash(planFile, approved string) error {
+	want, err := parseApprovedHash(approved)
+	if err != nil {
+		return err
+	}
+	got, err := planFileHash(planFile)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("plan file %s does not match the approved plan: its hash is %s, the approved hash is %s; re-run the plan and have it reviewed again", planFile, got, want)
+	}
+	Success("Plan file %s matches the approved hash %s", planFile, want)
+	return nil
+}
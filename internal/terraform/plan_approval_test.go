@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyApprovedHash(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "tfplan")
+	if err := os.WriteFile(planFile, []byte("reviewed plan"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := writePlanHash(planFile)
+	if err != nil {
+		t.Fatalf("writePlanHash: %v", err)
+	}
+	data, err := os.ReadFile(planFile + planHashSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sum + "  tfplan\n"; string(data) != want {
+		t.Errorf("hash file = %q, want %q", data, want)
+	}
+
+	for _, approved := range []string{sum, "sha256:" + strings.ToUpper(sum), planFile + planHashSuffix} {
+		if err := verifyApprovedHash(planFile, approved); err != nil {
+			t.Errorf("verifyApprovedHash(%q): %v", approved, err)
+		}
+	}
+
+	if err := os.WriteFile(planFile, []byte("replanned after review"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyApprovedHash(planFile, sum); err == nil || !strings.Contains(err.Error(), "does not match the approved plan") {
+		t.Errorf("verifyApprovedHash of a changed plan = %v, want a mismatch", err)
+	}
+	if err := verifyApprovedHash(planFile, "abc123"); err == nil || !strings.Contains(err.Error(), "invalid approved hash") {
+		t.Errorf("verifyApprovedHash(abc123) = %v, want an invalid hash", err)
+	}
+}
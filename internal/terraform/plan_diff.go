@@ -0,0 +1,284 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// PlanDiffOptions configures DiffPlans.
+type PlanDiffOptions struct {
+	Base          string   // git ref to compare with, like main
+	Dir           string   // terraform directory, inside a git repository
+	Vars          []string // NAME=VALUE, for both plans
+	VarFiles      []string // for both plans; files inside the repository are read from each checkout
+	BackendConfig []string // backend settings for terraform init of the base checkout
+	Refresh       bool
+}
+
+// PlanDiff is what the working tree plans to change beyond what the base
+// ref plans, resource by resource.
+type PlanDiff struct {
+	Base        string         `json:"base"`
+	BaseCommit  string         `json:"baseCommit"`
+	HeadCommit  string         `json:"headCommit"`
+	BaseChanges PlanChanges    `json:"baseChanges"`
+	HeadChanges PlanChanges    `json:"headChanges"`
+	Resources   []ResourceDiff `json:"resources"`
+}
+
+// ResourceDiff is a resource whose planned change differs between the two
+// plans. Status is added when only the working tree changes it, removed when
+// only the base does, and changed when both do, differently.
+type ResourceDiff struct {
+	Address string   `json:"address"`
+	Status  string   `json:"status"`
+	Base    []string `json:"base,omitempty"`
+	Head    []string `json:"head,omitempty"`
+}
+
+// DiffPlans plans the terraform directory of opts twice, as it is in the
+// working tree and as it is at opts.Base (checked out in a temporary git
+// worktree that is removed afterwards), and compares the two plans. Both
+// plans run against the same state, so the result is what the branch's
+// changes add to main's.
+func DiffPlans(opts PlanDiffOptions, useAI bool) (*PlanDiff, error) {
+	d, err := planDiff(opts)
+	if err != nil {
+		Error("Plan diff failed: %v", err)
+		explainError(useAI, err.Error())
+		return nil, err
+	}
+	return d, nil
+}
+
+func planDiff(opts PlanDiffOptions) (*PlanDiff, error) {
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	// Compare resolved paths: the toplevel git prints has its symlinks resolved.
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	baseCommit, err := gitOutput(dir, "rev-parse", "--verify", opts.Base+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown base ref %q: %w", opts.Base, err)
+	}
+	headCommit, _ := gitOutput(dir, "rev-parse", "HEAD")
+
+	tmp, err := os.MkdirTemp("", "smurf-plan-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "base")
+
+	Step("Checking out %s (%s) in a temporary worktree...", opts.Base, shortCommit(baseCommit))
+	if _, err := gitOutput(root, "worktree", "add", "--detach", worktree, baseCommit); err != nil {
+		return nil, fmt.Errorf("failed to check out %s: %w", opts.Base, err)
+	}
+	defer func() {
+		if _, err := gitOutput(root, "worktree", "remove", "--force", worktree); err != nil {
+			_, _ = gitOutput(root, "worktree", "prune")
+		}
+	}()
+	baseDir := filepath.Join(worktree, rel)
+	if _, err := os.Stat(baseDir); err != nil {
+		return nil, fmt.Errorf("%s does not exist at %s", rel, opts.Base)
+	}
+
+	Step("Planning %s at %s...", rel, opts.Base)
+	basePlan, err := planJSON(baseDir, mapVarFiles(opts.VarFiles, root, worktree), opts, filepath.Join(tmp, "base.plan"), true)
+	if err != nil {
+		return nil, fmt.Errorf("plan of %s failed: %w", opts.Base, err)
+	}
+	Step("Planning %s in the working tree...", rel)
+	headPlan, err := planJSON(dir, mapVarFiles(opts.VarFiles, root, root), opts, filepath.Join(tmp, "head.plan"), false)
+	if err != nil {
+		return nil, fmt.Errorf("plan of the working tree failed: %w", err)
+	}
+
+	d := &PlanDiff{Base: opts.Base, BaseCommit: baseCommit, HeadCommit: headCommit, Resources: diffResourceChanges(basePlan, headPlan)}
+	d.BaseChanges, _ = planSummary(basePlan)
+	d.HeadChanges, _ = planSummary(headPlan)
+	return d, nil
+}
+
+// planJSON plans dir into planFile and returns the plan as terraform show
+// -json reads it. The base checkout is fresh, so it is initialized first.
+func planJSON(dir string, varFiles []string, opts PlanDiffOptions, planFile string, init bool) (*tfjson.Plan, error) {
+	tf, err := GetTerraform(dir)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if init {
+		initOptions := []tfexec.InitOption{tfexec.Upgrade(false)}
+		for _, bc := range opts.BackendConfig {
+			initOptions = append(initOptions, tfexec.BackendConfig(bc))
+		}
+		if err := tf.Init(ctx, initOptions...); err != nil {
+			return nil, fmt.Errorf("terraform init failed: %w", err)
+		}
+	}
+
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		return nil, err
+	}
+	planOptions := []tfexec.PlanOption{tfexec.Out(planFile), tfexec.Refresh(opts.Refresh)}
+	for _, v := range append(envVars, opts.Vars...) {
+		planOptions = append(planOptions, tfexec.Var(v))
+	}
+	for _, vf := range varFiles {
+		planOptions = append(planOptions, tfexec.VarFile(vf))
+	}
+	if _, err := tf.Plan(ctx, planOptions...); err != nil {
+		return nil, err
+	}
+	return tf.ShowPlanFile(ctx, planFile)
+}
+
+// mapVarFiles makes varFiles absolute, and moves the ones inside the
+// repository at root to the same place in checkout, so each plan reads the
+// variables of its own revision.
+func mapVarFiles(varFiles []string, root, checkout string) []string {
+	mapped := make([]string, 0, len(varFiles))
+	for _, vf := range varFiles {
+		abs, err := filepath.Abs(vf)
+		if err != nil {
+			mapped = append(mapped, vf)
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			abs = filepath.Join(checkout, rel)
+		}
+		mapped = append(mapped, abs)
+	}
+	return mapped
+}
+
+// diffResourceChanges compares the resource changes of two plans, ignoring no-ops and
+// reads. A resource both plans change the same way is left out unless the
+// values it is changed to differ.
+func diffResourceChanges(base, head *tfjson.Plan) []ResourceDiff {
+	baseChanges, headChanges := plannedChanges(base), plannedChanges(head)
+	var diffs []ResourceDiff
+	for address, h := range headChanges {
+		b, ok := baseChanges[address]
+		switch {
+		case !ok:
+			diffs = append(diffs, ResourceDiff{Address: address, Status: "added", Head: actionNames(h.Actions)})
+		case !reflect.DeepEqual(b.Actions, h.Actions) || !reflect.DeepEqual(b.After, h.After) || !reflect.DeepEqual(b.AfterUnknown, h.AfterUnknown):
+			diffs = append(diffs, ResourceDiff{Address: address, Status: "changed", Base: actionNames(b.Actions), Head: actionNames(h.Actions)})
+		}
+	}
+	for address, b := range baseChanges {
+		if _, ok := headChanges[address]; !ok {
+			diffs = append(diffs, ResourceDiff{Address: address, Status: "removed", Base: actionNames(b.Actions)})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Address < diffs[j].Address })
+	return diffs
+}
+
+func plannedChanges(plan *tfjson.Plan) map[string]*tfjson.Change {
+	changes := map[string]*tfjson.Change{}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		changes[rc.Address] = rc.Change
+	}
+	return changes
+}
+
+func actionNames(actions tfjson.Actions) []string {
+	if actions.Replace() {
+		return []string{"replace"}
+	}
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = string(a)
+	}
+	return names
+}
+
+// PrintPlanDiff renders d as a list of resources, or as JSON when format is
+// json.
+func PrintPlanDiff(d *PlanDiff, format string) error {
+	if format == "json" {
+		if d.Resources == nil {
+			d.Resources = []ResourceDiff{}
+		}
+		return utils.PrintJSON(d)
+	}
+	fmt.Printf("Working tree (%s) vs %s (%s)\n", shortCommit(d.HeadCommit), d.Base, shortCommit(d.BaseCommit))
+	fmt.Printf("  %-14s +%d ~%d -%d\n", d.Base+":", d.BaseChanges.Add, d.BaseChanges.Change, d.BaseChanges.Destroy)
+	fmt.Printf("  %-14s +%d ~%d -%d\n", "working tree:", d.HeadChanges.Add, d.HeadChanges.Change, d.HeadChanges.Destroy)
+	fmt.Println()
+	if len(d.Resources) == 0 {
+		Success("Both plans make the same changes; this branch adds nothing to %s's plan.", d.Base)
+		return nil
+	}
+	for _, r := range d.Resources {
+		switch r.Status {
+		case "added":
+			fmt.Printf("%s %s: %s %s\n", GreenText("+"), r.Address, strings.Join(r.Head, ","), GreyText("(only this branch)"))
+		case "removed":
+			fmt.Printf("%s %s: %s %s\n", RedText("-"), r.Address, strings.Join(r.Base, ","), GreyText("(only "+d.Base+")"))
+		default:
+			base, head := strings.Join(r.Base, ","), strings.Join(r.Head, ",")
+			if base == head {
+				fmt.Printf("%s %s: %s %s\n", YellowText("~"), r.Address, head, GreyText("(different values)"))
+			} else {
+				fmt.Printf("%s %s: %s → %s\n", YellowText("~"), r.Address, base, head)
+			}
+		}
+	}
+	Info("%d resource(s) planned differently than on %s.", len(d.Resources), d.Base)
+	return nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// gitOutput runs git in dir and returns its trimmed output, or its stderr
+// as the error.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestDiffResourceChanges(t *testing.T) {
+	change := func(address string, after map[string]interface{}, actions ...tfjson.Action) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{Address: address, Change: &tfjson.Change{Actions: actions, After: after}}
+	}
+	base := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("aws_instance.web", map[string]interface{}{"instance_type": "t3.small"}, tfjson.ActionUpdate),
+		change("aws_iam_role.ci", nil, tfjson.ActionUpdate),
+		change("aws_s3_bucket.old", nil, tfjson.ActionDelete),
+		change("aws_vpc.main", nil, tfjson.ActionNoop),
+	}}
+	head := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("aws_instance.web", map[string]interface{}{"instance_type": "t3.large"}, tfjson.ActionUpdate),
+		change("aws_iam_role.ci", nil, tfjson.ActionDelete, tfjson.ActionCreate),
+		change("aws_s3_bucket.logs", nil, tfjson.ActionCreate),
+		change("aws_vpc.main", nil, tfjson.ActionNoop),
+	}}
+
+	got := diffResourceChanges(base, head)
+	want := []ResourceDiff{
+		{Address: "aws_iam_role.ci", Status: "changed", Base: []string{"update"}, Head: []string{"replace"}},
+		{Address: "aws_instance.web", Status: "changed", Base: []string{"update"}, Head: []string{"update"}},
+		{Address: "aws_s3_bucket.logs", Status: "added", Head: []string{"create"}},
+		{Address: "aws_s3_bucket.old", Status: "removed", Base: []string{"delete"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffResourceChanges =\n%+v\nwant\n%+v", got, want)
+	}
+	if diffs := diffResourceChanges(base, base); len(diffs) != 0 {
+		t.Errorf("diff of a plan with itself = %+v, want none", diffs)
+	}
+}
+
+func TestMapVarFiles(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inRepo := filepath.Join(root, "env", "prod.tfvars")
+	shared := filepath.Join(outside, "shared.tfvars")
+	for _, f := range []string{inRepo, shared} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := mapVarFiles([]string{inRepo, shared}, root, "/tmp/base")
+	want := []string{filepath.Join("/tmp/base", "env", "prod.tfvars"), shared}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapVarFiles = %v, want %v", got, want)
+	}
+}
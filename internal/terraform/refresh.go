@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 )
@@ -15,17 +14,25 @@ func Refresh(vars []string, varFiles []string, lock bool, dir string, useAI bool
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
+	envVars, err := workspaceVars(dir)
+	if err != nil {
+		Error("Failed to resolve stf.env variables: %v", err)
+		explainError(useAI, err.Error())
+		return err
+	}
+	vars = append(envVars, vars...)
+
 	Info("Refreshing Terraform state...")
 	applyOptions := []tfexec.RefreshCmdOption{}
 
 	// Handle variables
-	if vars != nil {
+	if len(vars) > 0 {
 		for _, v := range vars {
-			Info("Using variable: %s", v)
+			Info("Using variable: %s", varName(v))
 			applyOptions = append(applyOptions, tfexec.Var(v))
 		}
 	}
@@ -60,7 +67,7 @@ func Refresh(vars []string, varFiles []string, lock bool, dir string, useAI bool
 	if err != nil {
 		Error("Terraform refresh failed: %v", err)
 		Error("The above error occurred while Terraform attempted to refresh the state file.")
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -68,7 +75,7 @@ func Refresh(vars []string, varFiles []string, lock bool, dir string, useAI bool
 	state, err := tf.Show(context.Background())
 	if err != nil {
 		Error("Error reading updated state: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return fmt.Errorf("error reading updated state: %v", err)
 	}
 
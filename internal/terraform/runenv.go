@@ -0,0 +1,157 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// essentialEnv lists the variables terraform and its providers need to run at
+// all. They are passed through even when stf.isolation.envAllowlist is set.
+var essentialEnv = []string{
+	"PATH", "HOME", "USER", "TMPDIR", "TMP", "TEMP",
+	"SystemRoot", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+}
+
+// isolationEnv returns TF_DATA_DIR and TF_PLUGIN_CACHE_DIR for a run in
+// workingDir according to configs.Stf.Isolation, creating the directories so
+// terraform can use them right away. Unset options are left out.
+func isolationEnv(workingDir string) (map[string]string, error) {
+	iso := configs.Stf.Isolation
+	env := map[string]string{}
+
+	if iso.DataDir != "" {
+		dataDir, err := resolveDataDir(workingDir, iso.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory %s: %w", dataDir, err)
+		}
+		env["TF_DATA_DIR"] = dataDir
+	}
+
+	if iso.PluginCacheDir != "" {
+		cacheDir, err := expandHome(iso.PluginCacheDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create plugin cache directory %s: %w", cacheDir, err)
+		}
+		env["TF_PLUGIN_CACHE_DIR"] = cacheDir
+	}
+
+	return env, nil
+}
+
+// resolveDataDir makes a relative data directory absolute against the
+// terraform working directory, so every command of a run finds the same
+// .terraform contents regardless of where smurf was started.
+func resolveDataDir(workingDir, dataDir string) (string, error) {
+	dataDir, err := expandHome(dataDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(workingDir, dataDir)
+	}
+	abs, err := filepath.Abs(dataDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve data directory %s: %w", dataDir, err)
+	}
+	return abs, nil
+}
+
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~")), nil
+}
+
+// filterEnv keeps the entries of environ whose name is essential or matches
+// one of the allowlist patterns (shell globs such as AWS_* or ARM_CLIENT_ID).
+func filterEnv(environ []string, allowlist []string) map[string]string {
+	env := map[string]string{}
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if envAllowed(name, allowlist) {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+func envAllowed(name string, allowlist []string) bool {
+	for _, essential := range essentialEnv {
+		if name == essential {
+			return true
+		}
+	}
+	for _, pattern := range allowlist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRunIsolation configures tf with the stf.isolation settings of
+// smurf.yaml for a run in workingDir.
+//
+// Without an allowlist terraform keeps inheriting smurf's environment, so the
+// data and plugin cache directories are exported to the process. With one,
+// tf gets an explicit, filtered environment instead. terraform-exec refuses
+// to pass variables it manages itself (TF_VAR_*, TF_LOG, TF_WORKSPACE, ...)
+// through an explicit environment; those are dropped with a warning.
+func applyRunIsolation(tf *tfexec.Terraform, workingDir string) error {
+	extra, err := isolationEnv(workingDir)
+	if err != nil {
+		return err
+	}
+
+	allowlist := configs.Stf.Isolation.EnvAllowlist
+	if len(allowlist) == 0 {
+		return configs.ExportEnvironmentVariables(extra)
+	}
+
+	env := filterEnv(os.Environ(), allowlist)
+	for k, v := range extra {
+		env[k] = v
+	}
+	if dropped := tfexec.ProhibitedEnv(env); len(dropped) > 0 {
+		sort.Strings(dropped)
+		Warn("Not passing %s to terraform: use --var/--var-file and smurf's flags instead", strings.Join(dropped, ", "))
+		env = tfexec.CleanEnv(env)
+	}
+	return tf.SetEnv(env)
+}
+
+// isolationEnviron returns the isolation variables for workingDir as
+// KEY=VALUE entries, for terraform commands smurf runs without tfexec.
+func isolationEnviron(workingDir string) ([]string, error) {
+	extra, err := isolationEnv(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	var environ []string
+	for k, v := range extra {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+	return environ, nil
+}
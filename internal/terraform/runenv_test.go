@@ -0,0 +1,67 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+)
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/ci",
+		"AWS_REGION=us-east-1",
+		"AWS_PROFILE=prod",
+		"ARM_CLIENT_ID=abc",
+		"GITHUB_TOKEN=secret",
+		"MALFORMED",
+	}
+
+	env := filterEnv(environ, []string{"AWS_*", "ARM_CLIENT_ID"})
+	for _, name := range []string{"PATH", "HOME", "AWS_REGION", "AWS_PROFILE", "ARM_CLIENT_ID"} {
+		if _, ok := env[name]; !ok {
+			t.Errorf("%s should pass the allowlist", name)
+		}
+	}
+	if _, ok := env["GITHUB_TOKEN"]; ok {
+		t.Error("GITHUB_TOKEN should be filtered out")
+	}
+	if len(env) != 5 {
+		t.Errorf("env = %v, want 5 entries", env)
+	}
+}
+
+func TestIsolationEnv(t *testing.T) {
+	workDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "plugin-cache")
+
+	prev := configs.Stf
+	t.Cleanup(func() { configs.Stf = prev })
+	configs.Stf = configs.StfConfig{Isolation: configs.RunIsolation{
+		DataDir:        ".terraform-job-42",
+		PluginCacheDir: cacheDir,
+	}}
+
+	env, err := isolationEnv(workDir)
+	if err != nil {
+		t.Fatalf("isolationEnv: %v", err)
+	}
+	if want := filepath.Join(workDir, ".terraform-job-42"); env["TF_DATA_DIR"] != want {
+		t.Errorf("TF_DATA_DIR = %q, want %q", env["TF_DATA_DIR"], want)
+	}
+	if env["TF_PLUGIN_CACHE_DIR"] != cacheDir {
+		t.Errorf("TF_PLUGIN_CACHE_DIR = %q, want %q", env["TF_PLUGIN_CACHE_DIR"], cacheDir)
+	}
+	for _, dir := range env {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("%s should have been created", dir)
+		}
+	}
+
+	configs.Stf = configs.StfConfig{}
+	if env, _ := isolationEnv(workDir); len(env) != 0 {
+		t.Errorf("env = %v, want none without isolation settings", env)
+	}
+}
@@ -0,0 +1,487 @@
+package terraform
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/utils"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// RunRecord is the record of one apply in the run log.
+type RunRecord struct {
+	ID               string      `json:"id" dynamodbav:"id"`
+	Time             time.Time   `json:"time" dynamodbav:"time"`
+	Actor            string      `json:"actor" dynamodbav:"actor"`
+	Dir              string      `json:"dir" dynamodbav:"dir"`
+	Workspace        string      `json:"workspace,omitempty" dynamodbav:"workspace,omitempty"`
+	TerraformVersion string      `json:"terraformVersion,omitempty" dynamodbav:"terraformVersion,omitempty"`
+	PlanHash         string      `json:"planHash" dynamodbav:"planHash"`
+	Changes          PlanChanges `json:"changes" dynamodbav:"changes"`
+	Result           string      `json:"result" dynamodbav:"result"`
+	Error            string      `json:"error,omitempty" dynamodbav:"error,omitempty"`
+}
+
+// PlanChanges counts the resource changes of a plan.
+type PlanChanges struct {
+	Add     int `json:"add" dynamodbav:"add"`
+	Change  int `json:"change" dynamodbav:"change"`
+	Destroy int `json:"destroy" dynamodbav:"destroy"`
+}
+
+// runStore keeps the run log at one location.
+type runStore interface {
+	put(record RunRecord) error
+	// list returns the newest limit records (all when limit is 0), oldest
+	// first.
+	list(limit int) ([]RunRecord, error)
+}
+
+// defaultRunLog is where the run log is kept when stf.runLog is not set,
+// under the smurf home directory.
+const defaultRunLog = "terraform-runs.jsonl"
+
+// openRunStore opens the run log at location: s3://BUCKET/PREFIX (one
+// object per run), gs://BUCKET/PREFIX (the same, with the gcloud CLI),
+// dynamodb://TABLE (one item per run, keyed by the string attribute id) or
+// a local JSON Lines file. An empty location is the file in the smurf home
+// directory.
+func openRunStore(location string) (runStore, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		if location == "" {
+			home, err := utils.SmurfHome()
+			if err != nil {
+				return nil, err
+			}
+			location = filepath.Join(home, defaultRunLog)
+		}
+		return fileRunStore{path: location}, nil
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid run log %q: no bucket or table", location)
+	}
+	switch scheme {
+	case "s3":
+		return s3RunStore{bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+	case "gs":
+		return gcsRunStore{bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+	case "dynamodb":
+		if prefix != "" {
+			return nil, fmt.Errorf("invalid run log %q: must be dynamodb://TABLE", location)
+		}
+		return dynamoRunStore{table: bucket}, nil
+	}
+	return nil, fmt.Errorf("invalid run log %q: must be s3://, gs://, dynamodb:// or a file path", location)
+}
+
+// recordApply appends the apply of the plan show to the run log of
+// stf.runLog. A run log that can't be written only warns: the apply has
+// happened either way.
+func recordApply(tf *tfexec.Terraform, dir string, show *tfjson.Plan, applyErr error) {
+	record := RunRecord{
+		ID:     newRunID(),
+		Time:   time.Now().UTC(),
+		Actor:  utils.Actor(),
+		Dir:    absDir(dir),
+		Result: "applied",
+	}
+	record.Changes, record.PlanHash = planSummary(show)
+	if applyErr != nil {
+		record.Result, record.Error = "failed", applyErr.Error()
+	}
+	if v, _, err := tf.Version(context.Background(), true); err == nil {
+		record.TerraformVersion = v.String()
+	}
+	if ws, err := tf.WorkspaceShow(context.Background()); err == nil {
+		record.Workspace = ws
+	}
+
+	store, err := openRunStore(configs.Stf.RunLog)
+	if err == nil {
+		err = store.put(record)
+	}
+	if err != nil {
+		Warn("Failed to record the apply in the run log: %v", err)
+	}
+}
+
+// planSummary counts the changes of show and hashes them: the address and
+// actions of every changed resource, so two applies of the same change set
+// have the same hash.
+func planSummary(show *tfjson.Plan) (PlanChanges, string) {
+	var changes PlanChanges
+	var lines []string
+	for _, rc := range show.ResourceChanges {
+		if rc.Change == nil || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		switch {
+		case rc.Change.Actions.Create():
+			changes.Add++
+		case rc.Change.Actions.Update():
+			changes.Change++
+		case rc.Change.Actions.Delete():
+			changes.Destroy++
+		case rc.Change.Actions.Replace():
+			changes.Add++
+			changes.Destroy++
+		}
+		actions := make([]string, len(rc.Change.Actions))
+		for i, a := range rc.Change.Actions {
+			actions[i] = string(a)
+		}
+		lines = append(lines, rc.Address+" "+strings.Join(actions, ","))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return changes, hex.EncodeToString(sum[:])
+}
+
+// newRunID returns a run ID that sorts by time.
+func newRunID() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// ListRuns returns the newest limit applies of the run log of stf.runLog
+// (all when limit is 0), oldest first.
+func ListRuns(limit int) ([]RunRecord, error) {
+	store, err := openRunStore(configs.Stf.RunLog)
+	if err != nil {
+		return nil, err
+	}
+	return store.list(limit)
+}
+
+// PrintRuns renders records as a table, or as JSON when format is json.
+func PrintRuns(records []RunRecord, format string) error {
+	if format == "json" {
+		if records == nil {
+			records = []RunRecord{}
+		}
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	if len(records) == 0 {
+		Info("No applies recorded in the run log yet")
+		return nil
+	}
+	fmt.Printf("%-20s  %-16s  %-10s  %-14s  %-12s  %-9s  %-7s  %s\n", "TIME", "WHO", "WORKSPACE", "CHANGES", "PLAN", "TERRAFORM", "RESULT", "DIR")
+	for _, r := range records {
+		changes := fmt.Sprintf("+%d ~%d -%d", r.Changes.Add, r.Changes.Change, r.Changes.Destroy)
+		hash := r.PlanHash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		result := fmt.Sprintf("%-7s", r.Result)
+		if r.Result == "failed" {
+			result = RedText(result)
+		}
+		fmt.Printf("%-20s  %-16s  %-10s  %-14s  %-12s  %-9s  %s  %s\n",
+			r.Time.Local().Format("2006-01-02 15:04:05"), r.Actor, r.Workspace, changes, hash, r.TerraformVersion, result, r.Dir)
+	}
+	return nil
+}
+
+// newestRuns sorts records oldest first and keeps the newest limit.
+func newestRuns(records []RunRecord, limit int) []RunRecord {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records
+}
+
+// fileRunStore keeps the run log as a local JSON Lines file.
+type fileRunStore struct {
+	path string
+}
+
+func (s fileRunStore) put(record RunRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s fileRunStore) list(limit int) ([]RunRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var records []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var r RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.path, n, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newestRuns(records, limit), nil
+}
+
+// runObjectName is the name of the object holding record in a bucket run
+// log. Names start with the run ID, so they list in time order.
+func runObjectName(prefix string, record RunRecord) string {
+	return path.Join(prefix, record.ID+".json")
+}
+
+// s3RunStore keeps one object per run under a prefix of an S3 bucket.
+type s3RunStore struct {
+	bucket, prefix string
+}
+
+func awsSession() (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return sess, nil
+}
+
+func (s s3RunStore) put(record RunRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sess, err := awsSession()
+	if err != nil {
+		return err
+	}
+	_, err = s3.New(sess).PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(runObjectName(s.prefix, record)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (s s3RunStore) list(limit int) ([]RunRecord, error) {
+	sess, err := awsSession()
+	if err != nil {
+		return nil, err
+	}
+	client := s3.New(sess)
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	var keys []string
+	err = client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(prefix)},
+		func(page *s3.ListObjectsV2Output, _ bool) bool {
+			for _, obj := range page.Contents {
+				if key := aws.StringValue(obj.Key); strings.HasSuffix(key, ".json") {
+					keys = append(keys, key)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	keys = newestKeys(keys, limit)
+
+	records := make([]RunRecord, 0, len(keys))
+	for _, key := range keys {
+		obj, err := client.GetObject(&s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, key, err)
+		}
+		var r RunRecord
+		err = json.NewDecoder(obj.Body).Decode(&r)
+		obj.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, key, err)
+		}
+		records = append(records, r)
+	}
+	return newestRuns(records, limit), nil
+}
+
+// newestKeys sorts object keys named by runObjectName and keeps the newest
+// limit.
+func newestKeys(keys []string, limit int) []string {
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[len(keys)-limit:]
+	}
+	return keys
+}
+
+// gcsRunStore keeps one object per run under a prefix of a Cloud Storage
+// bucket, through the gcloud CLI and its credentials.
+type gcsRunStore struct {
+	bucket, prefix string
+}
+
+func (s gcsRunStore) url(name string) string {
+	return "gs://" + s.bucket + "/" + name
+}
+
+func (s gcsRunStore) put(record RunRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = runGcloud(bytes.NewReader(body), "storage", "cp", "-", s.url(runObjectName(s.prefix, record)))
+	return err
+}
+
+func (s gcsRunStore) list(limit int) ([]RunRecord, error) {
+	dir := s.url(s.prefix)
+	if s.prefix != "" {
+		dir += "/"
+	}
+	out, err := runGcloud(nil, "storage", "ls", dir)
+	if err != nil {
+		if strings.Contains(err.Error(), "matched no objects") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var urls []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); strings.HasSuffix(line, ".json") {
+			urls = append(urls, line)
+		}
+	}
+	urls = newestKeys(urls, limit)
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	// gcloud storage cat prints the objects back to back; each is one JSON
+	// document.
+	out, err = runGcloud(nil, append([]string{"storage", "cat"}, urls...)...)
+	if err != nil {
+		return nil, err
+	}
+	var records []RunRecord
+	dec := json.NewDecoder(strings.NewReader(out))
+	for {
+		var r RunRecord
+		if err := dec.Decode(&r); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		records = append(records, r)
+	}
+	return newestRuns(records, limit), nil
+}
+
+func runGcloud(stdin io.Reader, args ...string) (string, error) {
+	gcloud, err := exec.LookPath("gcloud")
+	if err != nil {
+		return "", errors.New("a gs:// run log needs the gcloud CLI on PATH")
+	}
+	cmd := exec.Command(gcloud, args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("gcloud %s: %s", strings.Join(args[:2], " "), msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// dynamoRunStore keeps one item per run in a DynamoDB table whose partition
+// key is the string attribute id.
+type dynamoRunStore struct {
+	table string
+}
+
+func (s dynamoRunStore) put(record RunRecord) error {
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return err
+	}
+	sess, err := awsSession()
+	if err != nil {
+		return err
+	}
+	_, err = dynamodb.New(sess).PutItem(&dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item})
+	return err
+}
+
+func (s dynamoRunStore) list(limit int) ([]RunRecord, error) {
+	sess, err := awsSession()
+	if err != nil {
+		return nil, err
+	}
+	var records []RunRecord
+	var scanErr error
+	err = dynamodb.New(sess).ScanPages(&dynamodb.ScanInput{TableName: aws.String(s.table)},
+		func(page *dynamodb.ScanOutput, _ bool) bool {
+			var items []RunRecord
+			if scanErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); scanErr != nil {
+				return false
+			}
+			records = append(records, items...)
+			return true
+		})
+	if err == nil {
+		err = scanErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb://%s: %w", s.table, err)
+	}
+	return newestRuns(records, limit), nil
+}
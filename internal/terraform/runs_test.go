@@ -0,0 +1,92 @@
+package terraform
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestPlanSummary(t *testing.T) {
+	change := func(address string, actions ...tfjson.Action) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{Address: address, Change: &tfjson.Change{Actions: actions}}
+	}
+	plan := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("aws_s3_bucket.logs", tfjson.ActionCreate),
+		change("aws_instance.web", tfjson.ActionDelete, tfjson.ActionCreate),
+		change("aws_iam_role.ci", tfjson.ActionUpdate),
+		change("aws_vpc.main", tfjson.ActionNoop),
+		change("aws_subnet.old", tfjson.ActionDelete),
+	}}
+	changes, hash := planSummary(plan)
+	if want := (PlanChanges{Add: 2, Change: 1, Destroy: 2}); changes != want {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	// The order of the resource changes doesn't change the hash, the
+	// change set does.
+	reordered := &tfjson.Plan{ResourceChanges: append([]*tfjson.ResourceChange{}, plan.ResourceChanges[4], plan.ResourceChanges[0],
+		plan.ResourceChanges[1], plan.ResourceChanges[2], plan.ResourceChanges[3])}
+	if _, h := planSummary(reordered); h != hash {
+		t.Errorf("hash of reordered plan = %s, want %s", h, hash)
+	}
+	plan.ResourceChanges[2] = change("aws_iam_role.ci", tfjson.ActionDelete)
+	if _, h := planSummary(plan); h == hash {
+		t.Error("hash unchanged after the change set changed")
+	}
+}
+
+func TestOpenRunStore(t *testing.T) {
+	tests := []struct {
+		location string
+		want     runStore
+	}{
+		{"s3://audit/terraform/prod/", s3RunStore{bucket: "audit", prefix: "terraform/prod"}},
+		{"gs://audit", gcsRunStore{bucket: "audit"}},
+		{"dynamodb://terraform-runs", dynamoRunStore{table: "terraform-runs"}},
+		{"runs.jsonl", fileRunStore{path: "runs.jsonl"}},
+	}
+	for _, tt := range tests {
+		got, err := openRunStore(tt.location)
+		if err != nil || got != tt.want {
+			t.Errorf("openRunStore(%q) = %#v, %v; want %#v", tt.location, got, err, tt.want)
+		}
+	}
+	for _, location := range []string{"s3://", "dynamodb://table/extra", "ftp://host/runs"} {
+		if _, err := openRunStore(location); err == nil {
+			t.Errorf("openRunStore(%q) succeeded, want an error", location)
+		}
+	}
+
+	t.Setenv("SMURF_HOME", t.TempDir())
+	store, err := openRunStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := store.(fileRunStore).path; filepath.Base(path) != defaultRunLog {
+		t.Errorf("default run log = %s, want %s in the smurf home", path, defaultRunLog)
+	}
+}
+
+func TestFileRunStore(t *testing.T) {
+	store := fileRunStore{path: filepath.Join(t.TempDir(), "runs", "log.jsonl")}
+	if records, err := store.list(0); err != nil || len(records) != 0 {
+		t.Fatalf("list of a missing log = %v, %v; want none", records, err)
+	}
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, result := range []string{"applied", "failed", "applied"} {
+		record := RunRecord{ID: newRunID(), Time: start.Add(time.Duration(i) * time.Hour), Actor: "ci", Result: result}
+		if err := store.put(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.list(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Result != "failed" || !records[1].Time.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("list(2) = %+v, want the two newest, oldest first", records)
+	}
+}
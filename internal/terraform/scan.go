@@ -0,0 +1,637 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// scanSeverities are the severities a scan can be gated on, lowest first,
+// the same as those of `smurf sdkr scan --fail-on`.
+var scanSeverities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// ScanOptions configures a static analysis of a Terraform configuration.
+type ScanOptions struct {
+	Dir       string
+	PlanFile  string   // binary plan file or plan JSON to scan as well; optional
+	Scanners  []string // checkov, trivy, tfsec; the first one installed when empty
+	FailOn    string   // fail on findings of this severity or higher
+	Output    string   // table (default), json or sarif
+	SARIFFile string   // also write the findings as SARIF to this file
+}
+
+// ScanFinding is a failed check reported by a scanner, in the same shape
+// whichever scanner reported it.
+type ScanFinding struct {
+	Scanner   string `json:"scanner"`
+	RuleID    string `json:"ruleId"`
+	Severity  string `json:"severity"` // LOW, MEDIUM, HIGH, CRITICAL or UNKNOWN
+	Title     string `json:"title"`
+	Resource  string `json:"resource,omitempty"`
+	File      string `json:"file"`
+	StartLine int    `json:"startLine,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	HelpURL   string `json:"helpUrl,omitempty"`
+}
+
+// iacScanner runs one external scanner and normalizes its JSON report.
+type iacScanner struct {
+	name       string
+	url        string
+	configArgs func(dir string) []string
+	planArgs   func(file string) []string // nil when the scanner can't read plans
+	parse      func(data []byte) ([]ScanFinding, error)
+}
+
+// iacScanners are the supported scanners, in the order one is picked when
+// none is named.
+var iacScanners = []iacScanner{
+	{
+		name: "checkov",
+		url:  "https://www.checkov.io",
+		configArgs: func(dir string) []string {
+			return []string{"-d", dir, "--framework", "terraform", "-o", "json", "--quiet", "--compact", "--soft-fail"}
+		},
+		planArgs: func(file string) []string {
+			return []string{"-f", file, "--framework", "terraform_plan", "-o", "json", "--quiet", "--compact", "--soft-fail"}
+		},
+		parse: parseCheckov,
+	},
+	{
+		name: "trivy",
+		url:  "https://trivy.dev",
+		configArgs: func(dir string) []string {
+			return []string{"config", "--format", "json", "--quiet", dir}
+		},
+		planArgs: func(file string) []string {
+			return []string{"config", "--format", "json", "--quiet", file}
+		},
+		parse: parseTrivyConfig,
+	},
+	{
+		name: "tfsec",
+		url:  "https://github.com/aquasecurity/tfsec",
+		configArgs: func(dir string) []string {
+			return []string{dir, "--format", "json", "--soft-fail", "--no-color"}
+		},
+		parse: parseTfsec,
+	},
+}
+
+// ValidScanSeverity reports a --fail-on severity that is not one of LOW,
+// MEDIUM, HIGH or CRITICAL. An empty severity turns the gate off.
+func ValidScanSeverity(severity string) error {
+	if severity == "" || severityRank(severity) >= 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid severity %q: must be one of %s", severity, strings.Join(scanSeverities, ", "))
+}
+
+// severityRank is the position of severity in scanSeverities, or -1 for a
+// severity no gate fails on (UNKNOWN, INFO).
+func severityRank(severity string) int {
+	for i, s := range scanSeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Scan runs IaC security scanners over the configuration in opts.Dir, and
+// over opts.PlanFile when it is set, and reports their findings in one
+// table, JSON list or SARIF log. With opts.FailOn it fails when any finding
+// has that severity or higher.
+func Scan(opts ScanOptions, useAI bool) error {
+	if err := ValidScanSeverity(opts.FailOn); err != nil {
+		return err
+	}
+	isTable := opts.Output == "" || opts.Output == "table"
+	scanners, err := selectScanners(opts.Scanners)
+	if err != nil {
+		return err
+	}
+
+	var planFile string
+	if opts.PlanFile != "" {
+		file, cleanup, err := scanPlanJSON(opts.Dir, opts.PlanFile)
+		if err != nil {
+			explainError(useAI, err.Error())
+			return err
+		}
+		defer cleanup()
+		planFile = file
+	}
+
+	var findings []ScanFinding
+	for _, s := range scanners {
+		if isTable {
+			Step("Scanning %s with %s...", opts.Dir, s.name)
+		}
+		found, err := runScanner(s, s.configArgs(opts.Dir))
+		if err != nil {
+			explainError(useAI, err.Error())
+			return err
+		}
+		findings = append(findings, relativeFindings(found, opts.Dir)...)
+
+		if planFile == "" {
+			continue
+		}
+		if s.planArgs == nil {
+			if isTable {
+				Warn("%s can't scan plans; only the configuration was scanned with it", s.name)
+			}
+			continue
+		}
+		if isTable {
+			Step("Scanning plan %s with %s...", opts.PlanFile, s.name)
+		}
+		found, err = runScanner(s, s.planArgs(planFile))
+		if err != nil {
+			explainError(useAI, err.Error())
+			return err
+		}
+		for i := range found {
+			found[i].File, found[i].StartLine, found[i].EndLine = opts.PlanFile, 0, 0
+		}
+		findings = append(findings, found...)
+	}
+	sortFindings(findings)
+
+	if opts.SARIFFile != "" {
+		data, err := json.MarshalIndent(findingsSARIF(findings, scanners), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(opts.SARIFFile, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("failed to write SARIF report: %w", err)
+		}
+	}
+
+	switch opts.Output {
+	case "json":
+		if findings == nil {
+			findings = []ScanFinding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "sarif":
+		data, err := json.MarshalIndent(findingsSARIF(findings, scanners), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		printScanFindings(findings, opts.FailOn)
+		if opts.SARIFFile != "" {
+			Info("SARIF report written to %s", opts.SARIFFile)
+		}
+	}
+
+	if n := failingFindings(findings, opts.FailOn); n > 0 {
+		if isTable {
+			Error("%d finding(s) of severity %s or higher", n, strings.ToUpper(opts.FailOn))
+		}
+		return fmt.Errorf("%d finding(s) of severity %s or higher", n, strings.ToUpper(opts.FailOn))
+	}
+	if isTable && len(findings) == 0 {
+		Success("No findings")
+	}
+	return nil
+}
+
+// selectScanners returns the named scanners, or the first supported one on
+// PATH when none is named. A named scanner that is not installed fails.
+func selectScanners(names []string) ([]iacScanner, error) {
+	if len(names) == 0 {
+		for _, s := range iacScanners {
+			if _, err := exec.LookPath(s.name); err == nil {
+				return []iacScanner{s}, nil
+			}
+		}
+		return nil, errors.New("no IaC scanner found in PATH: install checkov, trivy or tfsec")
+	}
+
+	var selected []iacScanner
+	for _, name := range names {
+		var found *iacScanner
+		for i := range iacScanners {
+			if strings.EqualFold(iacScanners[i].name, name) {
+				found = &iacScanners[i]
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown scanner %q: must be one of checkov, trivy, tfsec", name)
+		}
+		if _, err := exec.LookPath(found.name); err != nil {
+			return nil, fmt.Errorf("%s not found in PATH: install it from %s", found.name, found.url)
+		}
+		selected = append(selected, *found)
+	}
+	return selected, nil
+}
+
+// runScanner runs s with args and parses its report. Scanners are asked
+// not to fail on findings, so a run without a parseable report is an error.
+func runScanner(s iacScanner, args []string) ([]ScanFinding, error) {
+	cmd := exec.Command(s.name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	findings, err := s.parse(stdout.Bytes())
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" && runErr != nil {
+			msg = runErr.Error()
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s failed: %s", s.name, msg)
+	}
+	for i := range findings {
+		findings[i].Scanner = s.name
+		findings[i].Severity = normalizeSeverity(findings[i].Severity)
+	}
+	return findings, nil
+}
+
+// scanPlanJSON returns the path of the JSON form of planFile, converting a
+// binary plan with `terraform show -json`. The cleanup function removes any
+// file it wrote.
+func scanPlanJSON(dir, planFile string) (string, func(), error) {
+	noop := func() {
+		// Nothing was created, nothing to remove.
+	}
+	if strings.HasSuffix(planFile, ".json") {
+		return planFile, noop, nil
+	}
+
+	tf, err := GetTerraform(dir)
+	if err != nil {
+		return "", noop, err
+	}
+	plan, err := tf.ShowPlanFile(context.Background(), planFile)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to read plan file: %w", err)
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to encode plan as JSON: %w", err)
+	}
+	f, err := os.CreateTemp("", "smurf-scan-*.tfplan.json")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to write plan JSON: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", noop, fmt.Errorf("failed to write plan JSON: %w", err)
+	}
+	return f.Name(), func() { os.Remove(f.Name()) }, nil
+}
+
+// normalizeSeverity upper-cases severity and maps what the scanners report
+// without a severity to UNKNOWN.
+func normalizeSeverity(severity string) string {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	if severityRank(severity) < 0 {
+		return "UNKNOWN"
+	}
+	return severity
+}
+
+// relativeFindings makes the file of each finding relative to dir; checkov
+// reports /main.tf for dir/main.tf, tfsec absolute paths.
+func relativeFindings(findings []ScanFinding, dir string) []ScanFinding {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return findings
+	}
+	for i, f := range findings {
+		file := filepath.FromSlash(f.File)
+		if filepath.IsAbs(file) {
+			if rel, err := filepath.Rel(absDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			} else if f.Scanner == "checkov" {
+				file = strings.TrimPrefix(file, string(filepath.Separator))
+			}
+		}
+		findings[i].File = filepath.ToSlash(file)
+	}
+	return findings
+}
+
+// sortFindings orders findings by severity, highest first, then by
+// location.
+func sortFindings(findings []ScanFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.StartLine < b.StartLine
+	})
+}
+
+// failingFindings counts the findings of severity failOn or higher.
+func failingFindings(findings []ScanFinding, failOn string) int {
+	if failOn == "" {
+		return 0
+	}
+	n := 0
+	for _, f := range findings {
+		if severityRank(f.Severity) >= severityRank(failOn) {
+			n++
+		}
+	}
+	return n
+}
+
+// printScanFindings renders findings as a table, failing ones in red, with
+// a count per severity.
+func printScanFindings(findings []ScanFinding, failOn string) {
+	if len(findings) == 0 {
+		return
+	}
+	counts := map[string]int{}
+	data := pterm.TableData{{"SEVERITY", "RULE", "RESOURCE", "LOCATION", "TITLE", "SCANNER"}}
+	for _, f := range findings {
+		counts[f.Severity]++
+		severity := f.Severity
+		switch {
+		case failOn != "" && severityRank(f.Severity) >= severityRank(failOn):
+			severity = RedText(severity)
+		case severityRank(f.Severity) >= severityRank("HIGH"):
+			severity = YellowText(severity)
+		}
+		location := f.File
+		if f.StartLine > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.StartLine)
+		}
+		data = append(data, []string{severity, f.RuleID, f.Resource, location, f.Title, f.Scanner})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+
+	var summary []string
+	for _, s := range append([]string{"UNKNOWN"}, scanSeverities...) {
+		if counts[s] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	Info("%d finding(s): %s", len(findings), strings.Join(summary, ", "))
+}
+
+// checkovReport mirrors one framework's report of `checkov -o json`.
+type checkovReport struct {
+	Results struct {
+		FailedChecks []struct {
+			CheckID       string  `json:"check_id"`
+			CheckName     string  `json:"check_name"`
+			FilePath      string  `json:"file_path"`
+			FileLineRange []int   `json:"file_line_range"`
+			Resource      string  `json:"resource"`
+			Severity      *string `json:"severity"`
+			Guideline     string  `json:"guideline"`
+		} `json:"failed_checks"`
+	} `json:"results"`
+}
+
+// parseCheckov normalizes `checkov -o json`, one report or, with several
+// frameworks, a list of them. Checkov only reports severities with a
+// Prisma Cloud API key; without one findings are UNKNOWN.
+func parseCheckov(data []byte) ([]ScanFinding, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("checkov produced no output")
+	}
+	var reports []checkovReport
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &reports); err != nil {
+			return nil, fmt.Errorf("unable to parse checkov output: %w", err)
+		}
+	} else {
+		var r checkovReport
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("unable to parse checkov output: %w", err)
+		}
+		reports = []checkovReport{r}
+	}
+
+	var findings []ScanFinding
+	for _, r := range reports {
+		for _, c := range r.Results.FailedChecks {
+			f := ScanFinding{RuleID: c.CheckID, Title: c.CheckName, Resource: c.Resource, File: c.FilePath, HelpURL: c.Guideline}
+			if c.Severity != nil {
+				f.Severity = *c.Severity
+			}
+			if len(c.FileLineRange) == 2 {
+				f.StartLine, f.EndLine = c.FileLineRange[0], c.FileLineRange[1]
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// trivyConfigReport mirrors `trivy config --format json`.
+type trivyConfigReport struct {
+	Results []struct {
+		Target            string `json:"Target"`
+		Misconfigurations []struct {
+			ID            string `json:"ID"`
+			Title         string `json:"Title"`
+			Severity      string `json:"Severity"`
+			PrimaryURL    string `json:"PrimaryURL"`
+			Status        string `json:"Status"`
+			CauseMetadata struct {
+				Resource  string `json:"Resource"`
+				StartLine int    `json:"StartLine"`
+				EndLine   int    `json:"EndLine"`
+			} `json:"CauseMetadata"`
+		} `json:"Misconfigurations"`
+	} `json:"Results"`
+}
+
+// parseTrivyConfig normalizes the failed checks of `trivy config --format
+// json`.
+func parseTrivyConfig(data []byte) ([]ScanFinding, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("trivy produced no output")
+	}
+	var report trivyConfigReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("unable to parse trivy output: %w", err)
+	}
+	var findings []ScanFinding
+	for _, r := range report.Results {
+		for _, m := range r.Misconfigurations {
+			if m.Status != "" && m.Status != "FAIL" {
+				continue
+			}
+			findings = append(findings, ScanFinding{
+				RuleID: m.ID, Severity: m.Severity, Title: m.Title, Resource: m.CauseMetadata.Resource,
+				File: r.Target, StartLine: m.CauseMetadata.StartLine, EndLine: m.CauseMetadata.EndLine, HelpURL: m.PrimaryURL,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// tfsecReport mirrors `tfsec --format json`.
+type tfsecReport struct {
+	Results []struct {
+		RuleID          string   `json:"rule_id"`
+		RuleDescription string   `json:"rule_description"`
+		Severity        string   `json:"severity"`
+		Resource        string   `json:"resource"`
+		Links           []string `json:"links"`
+		Location        struct {
+			Filename  string `json:"filename"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+		} `json:"location"`
+	} `json:"results"`
+}
+
+// parseTfsec normalizes `tfsec --format json`.
+func parseTfsec(data []byte) ([]ScanFinding, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("tfsec produced no output")
+	}
+	var report tfsecReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("unable to parse tfsec output: %w", err)
+	}
+	var findings []ScanFinding
+	for _, r := range report.Results {
+		f := ScanFinding{
+			RuleID: r.RuleID, Severity: r.Severity, Title: r.RuleDescription, Resource: r.Resource,
+			File: r.Location.Filename, StartLine: r.Location.StartLine, EndLine: r.Location.EndLine,
+		}
+		if len(r.Links) > 0 {
+			f.HelpURL = r.Links[0]
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// SARIF 2.1.0, as much of it as code scanning dashboards read.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool struct {
+			Driver sarifDriver `json:"driver"`
+		} `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string            `json:"id"`
+		ShortDescription sarifText         `json:"shortDescription"`
+		HelpURI          string            `json:"helpUri,omitempty"`
+		Properties       map[string]string `json:"properties,omitempty"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifText       `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifText struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *sarifRegion `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+	}
+	sarifRegion struct {
+		StartLine int `json:"startLine"`
+		EndLine   int `json:"endLine,omitempty"`
+	}
+)
+
+// sarifSecuritySeverity is the CVSS-like score GitHub code scanning ranks
+// a rule's severity by.
+var sarifSecuritySeverity = map[string]string{"CRITICAL": "9.5", "HIGH": "8.0", "MEDIUM": "5.5", "LOW": "2.0"}
+
+// findingsSARIF converts findings into a SARIF log with one run per scanner.
+func findingsSARIF(findings []ScanFinding, scanners []iacScanner) sarifLog {
+	log := sarifLog{Schema: "https://json.schemastore.org/sarif-2.1.0.json", Version: "2.1.0", Runs: []sarifRun{}}
+	for _, s := range scanners {
+		run := sarifRun{Results: []sarifResult{}}
+		run.Tool.Driver = sarifDriver{Name: s.name, InformationURI: s.url, Rules: []sarifRule{}}
+		seen := map[string]bool{}
+		for _, f := range findings {
+			if f.Scanner != s.name {
+				continue
+			}
+			if !seen[f.RuleID] {
+				seen[f.RuleID] = true
+				rule := sarifRule{ID: f.RuleID, ShortDescription: sarifText{f.Title}, HelpURI: f.HelpURL}
+				if score, ok := sarifSecuritySeverity[f.Severity]; ok {
+					rule.Properties = map[string]string{"security-severity": score}
+				}
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+			}
+			message := f.Title
+			if f.Resource != "" {
+				message = f.Resource + ": " + f.Title
+			}
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = f.File
+			if f.StartLine > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.StartLine, EndLine: f.EndLine}
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID: f.RuleID, Level: sarifLevel(f.Severity), Message: sarifText{message}, Locations: []sarifLocation{loc},
+			})
+		}
+		log.Runs = append(log.Runs, run)
+	}
+	return log
+}
+
+// sarifLevel maps a severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	}
+	return "note"
+}
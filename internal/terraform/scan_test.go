@@ -0,0 +1,90 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScannerReports(t *testing.T) {
+	checkov := `[{"check_type":"terraform","results":{"failed_checks":[
+  {"check_id":"CKV_AWS_18","check_name":"Ensure the S3 bucket has access logging enabled","file_path":"/main.tf",
+   "file_line_range":[1,4],"resource":"aws_s3_bucket.logs","severity":null,"guideline":"https://docs.example/ckv_aws_18"}]}},
+ {"check_type":"secrets","results":{"failed_checks":[]}}]`
+	trivy := `{"Results":[{"Target":"main.tf","Misconfigurations":[
+  {"ID":"AVD-AWS-0086","Title":"S3 Access block should block public ACL","Severity":"HIGH","PrimaryURL":"https://avd.example/0086",
+   "Status":"FAIL","CauseMetadata":{"Resource":"aws_s3_bucket.logs","StartLine":1,"EndLine":4}},
+  {"ID":"AVD-AWS-0088","Title":"Bucket encryption","Severity":"HIGH","Status":"PASS"}]}]}`
+	tfsec := `{"results":[{"rule_id":"AVD-AWS-0089","rule_description":"Bucket has logging disabled","severity":"MEDIUM",
+  "resource":"aws_s3_bucket.logs","links":["https://aquasecurity.github.io/tfsec/aws-s3-enable-bucket-logging"],
+  "location":{"filename":"/work/infra/main.tf","start_line":1,"end_line":4}}]}`
+
+	cases := []struct {
+		name  string
+		parse func([]byte) ([]ScanFinding, error)
+		data  string
+		want  ScanFinding
+	}{
+		{"checkov", parseCheckov, checkov, ScanFinding{RuleID: "CKV_AWS_18", Title: "Ensure the S3 bucket has access logging enabled",
+			Resource: "aws_s3_bucket.logs", File: "/main.tf", StartLine: 1, EndLine: 4, HelpURL: "https://docs.example/ckv_aws_18"}},
+		{"trivy", parseTrivyConfig, trivy, ScanFinding{RuleID: "AVD-AWS-0086", Severity: "HIGH", Title: "S3 Access block should block public ACL",
+			Resource: "aws_s3_bucket.logs", File: "main.tf", StartLine: 1, EndLine: 4, HelpURL: "https://avd.example/0086"}},
+		{"tfsec", parseTfsec, tfsec, ScanFinding{RuleID: "AVD-AWS-0089", Severity: "MEDIUM", Title: "Bucket has logging disabled",
+			Resource: "aws_s3_bucket.logs", File: "/work/infra/main.tf", StartLine: 1, EndLine: 4,
+			HelpURL: "https://aquasecurity.github.io/tfsec/aws-s3-enable-bucket-logging"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.parse([]byte(c.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], c.want) {
+				t.Errorf("findings = %+v, want [%+v]", got, c.want)
+			}
+			if _, err := c.parse([]byte("  ")); err == nil {
+				t.Error("expected an error for empty output")
+			}
+		})
+	}
+}
+
+func TestScanGateAndSARIF(t *testing.T) {
+	findings := []ScanFinding{
+		{Scanner: "checkov", RuleID: "CKV_AWS_18", Severity: normalizeSeverity(""), Title: "logging", File: "/main.tf", StartLine: 1},
+		{Scanner: "tfsec", RuleID: "AVD-AWS-0089", Severity: normalizeSeverity("medium"), Title: "logging", File: "/work/infra/main.tf", StartLine: 1},
+		{Scanner: "tfsec", RuleID: "AVD-AWS-0086", Severity: normalizeSeverity("critical"), Title: "public ACL", Resource: "aws_s3_bucket.logs", File: "/work/infra/s3.tf", StartLine: 3},
+	}
+	relativeFindings(findings, "/work/infra")
+	if got := []string{findings[0].File, findings[1].File, findings[2].File}; !reflect.DeepEqual(got, []string{"main.tf", "main.tf", "s3.tf"}) {
+		t.Errorf("relative files = %q", got)
+	}
+
+	sortFindings(findings)
+	if findings[0].Severity != "CRITICAL" || findings[2].Severity != "UNKNOWN" {
+		t.Errorf("sorted severities = %s, %s, %s", findings[0].Severity, findings[1].Severity, findings[2].Severity)
+	}
+	for failOn, want := range map[string]int{"": 0, "low": 2, "medium": 2, "high": 1, "CRITICAL": 1} {
+		if n := failingFindings(findings, failOn); n != want {
+			t.Errorf("failingFindings(%q) = %d, want %d", failOn, n, want)
+		}
+	}
+	if err := ValidScanSeverity("severe"); err == nil {
+		t.Error("ValidScanSeverity accepted an unknown severity")
+	}
+
+	log := findingsSARIF(findings, []iacScanner{iacScanners[0], iacScanners[2]})
+	if len(log.Runs) != 2 || log.Runs[0].Tool.Driver.Name != "checkov" || len(log.Runs[1].Results) != 2 {
+		t.Fatalf("SARIF runs = %+v", log.Runs)
+	}
+	r := log.Runs[1].Results[0]
+	if r.RuleID != "AVD-AWS-0086" || r.Level != "error" || r.Message.Text != "aws_s3_bucket.logs: public ACL" ||
+		r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "s3.tf" || r.Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("first tfsec result = %+v", r)
+	}
+	if got := log.Runs[1].Tool.Driver.Rules[0].Properties["security-severity"]; got != "9.5" {
+		t.Errorf("security-severity = %q, want 9.5", got)
+	}
+	if log.Runs[0].Results[0].Level != "note" {
+		t.Errorf("UNKNOWN level = %q, want note", log.Runs[0].Results[0].Level)
+	}
+}
@@ -0,0 +1,210 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Terraform reports which values are sensitive next to the values
+// themselves: outputs carry a sensitive flag, and state and plan values a
+// mask of the same shape (sensitive_values, before_sensitive,
+// after_sensitive) holding true for each sensitive part. The values marked
+// there are registered with ai.RegisterSecrets, so they are masked in every
+// log line printed through this package and in the text sent to the AI
+// provider.
+
+// stateSecrets remembers the last Terraform instance created, so an AI
+// explanation can load the sensitive values of its state before sending
+// anything; it is only loaded once per run.
+var stateSecrets struct {
+	sync.Mutex
+	tf     *tfexec.Terraform
+	loaded bool
+}
+
+func trackSecretsSource(tf *tfexec.Terraform) {
+	stateSecrets.Lock()
+	defer stateSecrets.Unlock()
+	stateSecrets.tf, stateSecrets.loaded = tf, false
+}
+
+// explainError sends msg to the AI provider like ai.AIExplainError, after
+// registering the sensitive values of the current state, so they are
+// redacted from it even when they were never printed by this run.
+func explainError(useAI bool, msg string) {
+	if useAI || ai.Offline {
+		loadStateSecrets()
+	}
+	ai.AIExplainError(useAI, msg)
+}
+
+func loadStateSecrets() {
+	stateSecrets.Lock()
+	defer stateSecrets.Unlock()
+	if stateSecrets.tf == nil || stateSecrets.loaded {
+		return
+	}
+	stateSecrets.loaded = true
+
+	ctx := context.Background()
+	// Either call fails on an uninitialized directory; there is then no state
+	// to leak.
+	if state, err := stateSecrets.tf.Show(ctx); err == nil {
+		registerStateSecrets(state)
+	}
+	if outputs, err := stateSecrets.tf.Output(ctx); err == nil {
+		registerOutputSecrets(outputs)
+	}
+}
+
+// varName returns the name of a --var NAME=VALUE assignment, so the value,
+// which may be a secret, is not logged.
+func varName(assignment string) string {
+	name, _, _ := strings.Cut(assignment, "=")
+	return name
+}
+
+// registerOutputSecrets registers the values of the sensitive outputs.
+func registerOutputSecrets(outputs map[string]tfexec.OutputMeta) {
+	for _, meta := range outputs {
+		if !meta.Sensitive {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(meta.Value, &v); err != nil {
+			continue
+		}
+		ai.RegisterSecrets(sensitiveLeaves(v, true)...)
+	}
+}
+
+// registerStateSecrets registers the sensitive outputs and resource
+// attributes of state.
+func registerStateSecrets(state *tfjson.State) {
+	if state == nil || state.Values == nil {
+		return
+	}
+	for _, out := range state.Values.Outputs {
+		if out != nil && out.Sensitive {
+			ai.RegisterSecrets(sensitiveLeaves(out.Value, true)...)
+		}
+	}
+	registerModuleSecrets(state.Values.RootModule)
+}
+
+func registerModuleSecrets(module *tfjson.StateModule) {
+	if module == nil {
+		return
+	}
+	for _, r := range module.Resources {
+		if r == nil || len(r.SensitiveValues) == 0 {
+			continue
+		}
+		var mask interface{}
+		if err := json.Unmarshal(r.SensitiveValues, &mask); err != nil {
+			continue
+		}
+		ai.RegisterSecrets(sensitiveLeaves(r.AttributeValues, mask)...)
+	}
+	for _, child := range module.ChildModules {
+		registerModuleSecrets(child)
+	}
+}
+
+// registerPlanSecrets registers everything a plan marks sensitive: the
+// before and after values of resource changes, output changes, the prior
+// state, and the values of variables declared sensitive.
+func registerPlanSecrets(plan *tfjson.Plan) {
+	if plan == nil {
+		return
+	}
+	for _, rc := range plan.ResourceChanges {
+		if rc == nil || rc.Change == nil {
+			continue
+		}
+		ai.RegisterSecrets(sensitiveLeaves(rc.Change.Before, rc.Change.BeforeSensitive)...)
+		ai.RegisterSecrets(sensitiveLeaves(rc.Change.After, rc.Change.AfterSensitive)...)
+	}
+	for _, oc := range plan.OutputChanges {
+		if oc == nil {
+			continue
+		}
+		ai.RegisterSecrets(sensitiveLeaves(oc.Before, oc.BeforeSensitive)...)
+		ai.RegisterSecrets(sensitiveLeaves(oc.After, oc.AfterSensitive)...)
+	}
+	registerStateSecrets(plan.PriorState)
+
+	if plan.Config != nil && plan.Config.RootModule != nil {
+		for name, v := range plan.Config.RootModule.Variables {
+			if v == nil || !v.Sensitive {
+				continue
+			}
+			if pv, ok := plan.Variables[name]; ok && pv != nil {
+				ai.RegisterSecrets(sensitiveLeaves(pv.Value, true)...)
+			}
+		}
+	}
+}
+
+// sensitiveLeaves returns the scalar values in value that mask marks
+// sensitive. mask is true for a wholly sensitive value, or a map or slice
+// mirroring value's structure.
+func sensitiveLeaves(value, mask interface{}) []string {
+	switch m := mask.(type) {
+	case bool:
+		if m {
+			return scalarLeaves(value)
+		}
+	case map[string]interface{}:
+		if obj, ok := value.(map[string]interface{}); ok {
+			var leaves []string
+			for k, sub := range m {
+				leaves = append(leaves, sensitiveLeaves(obj[k], sub)...)
+			}
+			return leaves
+		}
+	case []interface{}:
+		if list, ok := value.([]interface{}); ok {
+			var leaves []string
+			for i, sub := range m {
+				if i < len(list) {
+					leaves = append(leaves, sensitiveLeaves(list[i], sub)...)
+				}
+			}
+			return leaves
+		}
+	}
+	return nil
+}
+
+// scalarLeaves returns every string and number in value, as text.
+func scalarLeaves(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case json.Number:
+		return []string{v.String()}
+	case map[string]interface{}:
+		var leaves []string
+		for _, sub := range v {
+			leaves = append(leaves, scalarLeaves(sub)...)
+		}
+		return leaves
+	case []interface{}:
+		var leaves []string
+		for _, sub := range v {
+			leaves = append(leaves, scalarLeaves(sub)...)
+		}
+		return leaves
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/pterm/pterm"
 )
@@ -18,7 +17,7 @@ func ShowState(vars []string, varFiles []string, dir string, jsonOutput bool, us
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -37,7 +36,7 @@ func ShowState(vars []string, varFiles []string, dir string, jsonOutput bool, us
 		state, err := tf.Show(context.Background())
 		if err != nil {
 			Error("Failed to show state: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 			return err
 		}
 		// Convert state to JSON string
@@ -52,9 +51,10 @@ func ShowState(vars []string, varFiles []string, dir string, jsonOutput bool, us
 		state, err := tf.Show(context.Background())
 		if err != nil {
 			Error("Failed to show state: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 			return err
 		}
+		registerStateSecrets(state)
 
 		// Pretty print the state
 		printStateHumanReadable(state)
@@ -70,7 +70,7 @@ func ShowResource(resourceAddr string, vars []string, varFiles []string, dir str
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client for show resource: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -88,9 +88,10 @@ func ShowResource(resourceAddr string, vars []string, varFiles []string, dir str
 	state, err := tf.Show(context.Background())
 	if err != nil {
 		Error("Failed to get state: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
+	registerStateSecrets(state)
 
 	// Find the specific resource
 	var foundResource *tfjson.StateResource
@@ -135,14 +136,14 @@ func ShowPlan(planFile string, vars []string, varFiles []string, dir string, jso
 	tf, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client for show plan: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
 	// Check if plan file exists
 	if _, err := os.Stat(planFile); os.IsNotExist(err) {
 		Error("Plan file not found: %s", planFile)
-		ai.AIExplainError(useAI, fmt.Sprintf("Plan file not found: %s", planFile))
+		explainError(useAI, fmt.Sprintf("Plan file not found: %s", planFile))
 		return fmt.Errorf("plan file not found: %s", planFile)
 	}
 
@@ -161,7 +162,7 @@ func ShowPlan(planFile string, vars []string, varFiles []string, dir string, jso
 		output, err := tf.ShowPlanFileRaw(context.Background(), planFile)
 		if err != nil {
 			Error("Failed to read plan: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 			return err
 		}
 		fmt.Println(string(output))
@@ -170,7 +171,7 @@ func ShowPlan(planFile string, vars []string, varFiles []string, dir string, jso
 		plan, err := tf.ShowPlanFile(context.Background(), planFile)
 		if err != nil {
 			Error("Failed to read plan: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 			return err
 		}
 
@@ -178,6 +179,7 @@ func ShowPlan(planFile string, vars []string, varFiles []string, dir string, jso
 			Error("Plan is empty or invalid")
 			return fmt.Errorf("plan is empty or invalid")
 		}
+		registerPlanSecrets(plan)
 
 		// Print human-readable plan summary
 		printPlanHumanReadable(plan)
@@ -223,6 +225,10 @@ func printStateHumanReadable(state *tfjson.State) {
 	if len(state.Values.Outputs) > 0 {
 		pterm.DefaultSection.Println("Outputs")
 		for name, output := range state.Values.Outputs {
+			if output.Sensitive {
+				pterm.Printf("  %s = %s\n", pterm.FgYellow.Sprint(name), "[sensitive value hidden]")
+				continue
+			}
 			pterm.Printf("  %s = %v\n", pterm.FgYellow.Sprint(name), output.Value)
 		}
 	}
@@ -261,8 +267,14 @@ func printResourceHumanReadable(resource *tfjson.StateResource) {
 
 	pterm.Println()
 	pterm.DefaultSection.Println("Attributes")
+	var sensitive map[string]interface{}
+	_ = json.Unmarshal(resource.SensitiveValues, &sensitive)
 	for key, value := range resource.AttributeValues {
-		// Skip sensitive values or very long values for readability
+		if len(sensitiveLeaves(value, sensitive[key])) > 0 {
+			pterm.Printf("  %s = %s\n", pterm.FgCyan.Sprint(key), "[sensitive value hidden]")
+			continue
+		}
+		// Truncate very long values for readability
 		valueStr := fmt.Sprintf("%v", value)
 		if len(valueStr) > 100 {
 			valueStr = valueStr[:97] + "..."
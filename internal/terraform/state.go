@@ -4,10 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"sort"
 
-	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/configs"
 	"github.com/clouddrove/smurf/internal/utils"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
@@ -37,7 +36,7 @@ func StateList(dir, format string, useAI bool) error {
 	if err != nil {
 		if isTable {
 			Error("Failed to initialize Terraform: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 		}
 		return err
 	}
@@ -46,7 +45,7 @@ func StateList(dir, format string, useAI bool) error {
 	if err != nil {
 		if isTable {
 			Error("Unable to read Terraform state: %v", err)
-			ai.AIExplainError(useAI, err.Error())
+			explainError(useAI, err.Error())
 		}
 		return fmt.Errorf("failed to read state: %v", err)
 	}
@@ -101,20 +100,31 @@ func resourceAddressesForJSON(resources []string) []string {
 // the terraform binary is missing or the instance can't be created, which
 // would violate the "completion functions never print" rule.
 func StateResourceAddresses(ctx context.Context, dir string) ([]string, error) {
-	terraformBinary, err := exec.LookPath("terraform")
+	workingDir := "."
+	if dir != "" {
+		workingDir = dir
+	}
+
+	// Never download a terraform release from shell completion.
+	binary, err := resolveBinary(workingDir, false)
 	if err != nil {
 		return nil, err
 	}
 
-	workingDir := "."
-	if dir != "" {
-		workingDir = dir
+	tf, err := tfexec.NewTerraform(workingDir, binary)
+	if err != nil {
+		return nil, err
 	}
 
-	tf, err := tfexec.NewTerraform(workingDir, terraformBinary)
+	// Only point terraform at the isolated data directory; the allowlist
+	// warning of applyRunIsolation must not leak into completion output.
+	extra, err := isolationEnv(workingDir)
 	if err != nil {
 		return nil, err
 	}
+	if err := configs.ExportEnvironmentVariables(extra); err != nil {
+		return nil, err
+	}
 
 	state, err := tf.Show(ctx)
 	if err != nil {
@@ -8,16 +8,19 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-
-	"github.com/clouddrove/smurf/internal/ai"
 )
 
 // resolveTerraformBinary resolves and validates terraform binary securely.
 // This prevents PATH injection (CWE-426 / SonarQube warning).
-func resolveTerraformBinary() (string, error) {
-	terraformPath, err := exec.LookPath("terraform")
+func resolveTerraformBinary(dir string) (string, error) {
+	terraformPath, err := pinnedTerraform(dir)
 	if err != nil {
-		return "", fmt.Errorf("terraform binary not found in PATH")
+		return "", err
+	}
+	if terraformPath == "" {
+		if terraformPath, err = exec.LookPath("terraform"); err != nil {
+			return "", fmt.Errorf("terraform binary not found in PATH")
+		}
 	}
 
 	info, err := os.Stat(terraformPath)
@@ -47,7 +50,7 @@ func StatePull(dir string, useAI bool) error {
 	_, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -62,7 +65,7 @@ func StatePull(dir string, useAI bool) error {
 	state, err := pullRemoteState(dir)
 	if err != nil {
 		Error("Failed to pull remote state: %v", err)
-		ai.AIExplainError(useAI, formatPullErrorMessage(err))
+		explainError(useAI, formatPullErrorMessage(err))
 		return fmt.Errorf("state pull failed: %v", err)
 	}
 
@@ -77,14 +80,19 @@ func StatePull(dir string, useAI bool) error {
 // pullRemoteState executes terraform state pull securely
 func pullRemoteState(workingDir string) ([]byte, error) {
 
-	terraformPath, err := resolveTerraformBinary()
+	terraformPath, err := resolveTerraformBinary(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := isolationEnviron(workingDir)
 	if err != nil {
 		return nil, err
 	}
 
 	cmd := exec.Command(terraformPath, "state", "pull")
 	cmd.Dir = workingDir
-	cmd.Env = secureEnv()
+	cmd.Env = append(secureEnv(), extra...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -108,14 +116,19 @@ func pullRemoteState(workingDir string) ([]byte, error) {
 // checkBackendConfiguration verifies if a remote backend is configured
 func checkBackendConfiguration(dir string) error {
 
-	terraformPath, err := resolveTerraformBinary()
+	terraformPath, err := resolveTerraformBinary(dir)
+	if err != nil {
+		return err
+	}
+
+	extra, err := isolationEnviron(dir)
 	if err != nil {
 		return err
 	}
 
 	cmd := exec.Command(terraformPath, "init", "-backend=true", "-get=false")
 	cmd.Dir = dir
-	cmd.Env = secureEnv()
+	cmd.Env = append(secureEnv(), extra...)
 
 	if cmd.Run() != nil {
 		return fmt.Errorf("backend initialization check failed")
@@ -191,7 +204,7 @@ func StatePullToFile(dir, outputFile string, useAI bool) error {
 	state, err := pullRemoteState(dir)
 	if err != nil {
 		Error("Failed to pull remote state: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -9,7 +9,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/clouddrove/smurf/internal/ai"
+	"github.com/clouddrove/smurf/configs"
 )
 
 // terraformCommand returns the terraform command with secure PATH
@@ -34,7 +34,13 @@ func terraformCommand() string {
 
 // createSecureCommand creates an exec.Cmd with a secure environment
 func createSecureCommand(dir string, args ...string) *exec.Cmd {
-	cmd := exec.Command(terraformCommand(), args...)
+	name := terraformCommand()
+	if pinned, err := pinnedTerraform(dir); err != nil {
+		Warn("Running the default terraform: %v", err)
+	} else if pinned != "" {
+		name = pinned
+	}
+	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
 
 	// Create a clean environment with sanitized PATH
@@ -44,14 +50,26 @@ func createSecureCommand(dir string, args ...string) *exec.Cmd {
 		// Preserve other essential environment variables but filter PATH
 	}
 
+	environ := os.Environ()
+	if allowlist := configs.Stf.Isolation.EnvAllowlist; len(allowlist) > 0 {
+		environ = environ[:0:0]
+		for k, v := range filterEnv(os.Environ(), allowlist) {
+			environ = append(environ, k+"="+v)
+		}
+	}
+
 	// Copy existing environment but filter out unsafe PATH entries
-	for _, env := range os.Environ() {
+	for _, env := range environ {
 		if !strings.HasPrefix(env, "PATH=") {
 			cleanEnv = append(cleanEnv, env)
 		}
 	}
 
-	cmd.Env = cleanEnv
+	extra, err := isolationEnviron(dir)
+	if err != nil {
+		Warn("Running terraform without stf.isolation settings: %v", err)
+	}
+	cmd.Env = append(cleanEnv, extra...)
 	return cmd
 }
 
@@ -75,7 +93,7 @@ func StatePush(dir string, force, backup, lock bool, lockTimeout string, useAI b
 	// Validate directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		Error("Directory does not exist: %s", dir)
-		ai.AIExplainError(useAI, fmt.Sprintf("Directory not found: %s", dir))
+		explainError(useAI, fmt.Sprintf("Directory not found: %s", dir))
 		return fmt.Errorf("directory not found: %s", dir)
 	}
 
@@ -83,7 +101,7 @@ func StatePush(dir string, force, backup, lock bool, lockTimeout string, useAI b
 	localStatePath := filepath.Join(dir, "terraform.tfstate")
 	if _, err := os.Stat(localStatePath); os.IsNotExist(err) {
 		Error("Local state file not found at %s", localStatePath)
-		ai.AIExplainError(useAI, "Local state file not found")
+		explainError(useAI, "Local state file not found")
 		return fmt.Errorf("local state file not found")
 	}
 
@@ -153,7 +171,7 @@ func StatePush(dir string, force, backup, lock bool, lockTimeout string, useAI b
 
 	if err := runTerraformCommandWithOutput(dir, args...); err != nil {
 		Error("Failed to push state: %v", err)
-		ai.AIExplainError(useAI, formatPushErrorMessage(err))
+		explainError(useAI, formatPushErrorMessage(err))
 		return err
 	}
 
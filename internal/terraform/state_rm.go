@@ -6,15 +6,13 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/clouddrove/smurf/internal/ai"
 )
 
 // StateRm removes specified resources from the Terraform state
 func StateRm(dir string, addresses []string, backup bool, useAI bool) error {
 	// Validate input
 	if len(addresses) == 0 {
-		ai.AIExplainError(useAI, "No resource addresses provided")
+		explainError(useAI, "No resource addresses provided")
 		return fmt.Errorf("at least one resource address must be specified")
 	}
 
@@ -22,7 +20,7 @@ func StateRm(dir string, addresses []string, backup bool, useAI bool) error {
 	_, err := GetTerraform(dir)
 	if err != nil {
 		Error("Failed to initialize Terraform: %v", err)
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
@@ -60,7 +58,7 @@ func StateRm(dir string, addresses []string, backup bool, useAI bool) error {
 
 	// If there were failures and AI is enabled, provide help
 	if len(failed) > 0 && useAI {
-		ai.AIExplainError(useAI, formatFailureMessage(failed))
+		explainError(useAI, formatFailureMessage(failed))
 	}
 
 	return nil
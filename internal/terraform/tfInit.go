@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/clouddrove/smurf/configs"
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/pterm/pterm"
 )
@@ -85,7 +84,7 @@ func InitWithOptions(opts configs.InitOptions) error {
 	tf, err := GetTerraform(opts.Dir)
 	if err != nil {
 		Error("Failed to initialize Terraform client: %v", err)
-		ai.AIExplainError(opts.UseAI, err.Error())
+		explainError(opts.UseAI, err.Error())
 		return err
 	}
 
@@ -155,7 +154,7 @@ func InitWithOptions(opts configs.InitOptions) error {
 			Error("Initialization failed: %v", err)
 		}
 
-		ai.AIExplainError(opts.UseAI, err.Error())
+		explainError(opts.UseAI, err.Error())
 		return err
 	}
 
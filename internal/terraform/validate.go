@@ -5,10 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
-	"github.com/clouddrove/smurf/internal/ai"
 	"github.com/hashicorp/terraform-exec/tfexec"
 )
 
@@ -113,7 +111,7 @@ func GetValidateTerraform(dir string) (*tfexec.Terraform, error) {
 		}
 	}
 
-	terraformPath, err := exec.LookPath("terraform")
+	terraformPath, err := terraformBinary(workDir)
 	if err != nil {
 		Error("Terraform executable not found: %v", err)
 		return nil, fmt.Errorf("terraform executable not found: %w", err)
@@ -125,6 +123,11 @@ func GetValidateTerraform(dir string) (*tfexec.Terraform, error) {
 		return nil, fmt.Errorf("failed to create Terraform executor: %w", err)
 	}
 
+	if err := applyRunIsolation(tf, workDir); err != nil {
+		Error("Failed to prepare the Terraform environment: %v", err)
+		return nil, fmt.Errorf("failed to prepare the Terraform environment: %w", err)
+	}
+
 	Info("Terraform executable initialized successfully")
 	return tf, nil
 }
@@ -133,7 +136,7 @@ func GetValidateTerraform(dir string) (*tfexec.Terraform, error) {
 func Validate(dir string, useAI bool) error {
 	tf, err := GetValidateTerraform(dir)
 	if err != nil {
-		ai.AIExplainError(useAI, err.Error())
+		explainError(useAI, err.Error())
 		return err
 	}
 
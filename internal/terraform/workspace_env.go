@@ -0,0 +1,133 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+)
+
+// allWorkspaces is the stf.env block that applies to every workspace.
+const allWorkspaces = "*"
+
+var variableBlockPattern = regexp.MustCompile(`(?m)^\s*variable\s+"([^"]+)"`)
+
+// workspaceVars returns the stf.env variables of the workspace selected in
+// workingDir as NAME=VALUE assignments, to be passed before the --var and
+// --var-file flags so those still win. They reach the terraform process
+// only, never smurf's own environment: terraform-exec refuses TF_VAR_*
+// variables in the environment it passes, so they go as -var instead.
+// Variables the configuration doesn't declare are left out, since terraform
+// rejects an undeclared -var where it ignores an undeclared TF_VAR_.
+func workspaceVars(workingDir string) ([]string, error) {
+	if len(configs.Stf.Env) == 0 {
+		return nil, nil
+	}
+	if workingDir == "" {
+		workingDir = "."
+	}
+	ai.RegisterSecrets(configs.Stf.EnvSecrets...)
+
+	workspace, err := currentWorkspace(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for _, block := range []string{allWorkspaces, workspace} {
+		for name, value := range configs.Stf.Env[block] {
+			values[strings.TrimPrefix(name, "TF_VAR_")] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	declared, err := declaredVariables(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	var vars []string
+	for name, value := range values {
+		if declared[name] {
+			vars = append(vars, name+"="+value)
+		}
+	}
+	sort.Strings(vars)
+	if len(vars) > 0 {
+		Info("Using %d variable(s) from stf.env for workspace %s", len(vars), workspace)
+	}
+	return vars, nil
+}
+
+// currentWorkspace returns the workspace terraform uses in workingDir:
+// TF_WORKSPACE, else the one recorded by 'terraform workspace select' in the
+// data directory, else default.
+func currentWorkspace(workingDir string) (string, error) {
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws, nil
+	}
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if configs.Stf.Isolation.DataDir != "" {
+		dataDir = configs.Stf.Isolation.DataDir
+	}
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	dataDir, err := resolveDataDir(workingDir, dataDir)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "environment"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "default", nil
+		}
+		return "", fmt.Errorf("failed to read the selected workspace: %w", err)
+	}
+	if ws := strings.TrimSpace(string(data)); ws != "" {
+		return ws, nil
+	}
+	return "default", nil
+}
+
+// declaredVariables returns the names of the variables declared by the
+// .tf and .tf.json files of the root module in workingDir.
+func declaredVariables(workingDir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !(strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(workingDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(name, ".tf") {
+			for _, m := range variableBlockPattern.FindAllStringSubmatch(string(data), -1) {
+				declared[m[1]] = true
+			}
+			continue
+		}
+		var doc struct {
+			Variable map[string]json.RawMessage `json:"variable"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		for v := range doc.Variable {
+			declared[v] = true
+		}
+	}
+	return declared, nil
+}
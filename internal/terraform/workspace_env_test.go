@@ -0,0 +1,74 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/ai"
+)
+
+func TestWorkspaceVars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"variables.tf":   "variable \"region\" {}\nvariable \"db_password\" {\n  sensitive = true\n}\n",
+		"extra.tf.json":  `{"variable": {"instance_type": {"default": "t3.micro"}}}`,
+		"notes.md":       `variable "ignored" {}`,
+		"modules/x/x.tf": `variable "nested" {}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prev := configs.Stf
+	t.Cleanup(func() { configs.Stf = prev })
+	configs.Stf = configs.StfConfig{
+		Env: map[string]map[string]string{
+			"*":    {"TF_VAR_region": "us-east-1", "TF_VAR_instance_type": "t3.small", "TF_VAR_nested": "x"},
+			"prod": {"TF_VAR_instance_type": "m5.large", "TF_VAR_db_password": "s3cr3t-pass"},
+		},
+		EnvSecrets: []string{"s3cr3t-pass"},
+	}
+	t.Setenv("TF_WORKSPACE", "")
+	t.Setenv("TF_DATA_DIR", "")
+
+	vars, err := workspaceVars(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"instance_type=t3.small", "region=us-east-1"}; !reflect.DeepEqual(vars, want) {
+		t.Errorf("default workspace vars = %v, want %v", vars, want)
+	}
+
+	// The workspace 'terraform workspace select' recorded.
+	if err := os.MkdirAll(filepath.Join(dir, ".terraform"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".terraform", "environment"), []byte("prod"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vars, err = workspaceVars(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"db_password=s3cr3t-pass", "instance_type=m5.large", "region=us-east-1"}; !reflect.DeepEqual(vars, want) {
+		t.Errorf("prod vars = %v, want %v", vars, want)
+	}
+	if got := ai.Redact("password is s3cr3t-pass"); got != "password is [REDACTED]" {
+		t.Errorf("Redact = %q, want the stf.env secret masked", got)
+	}
+
+	// TF_WORKSPACE wins over the recorded workspace.
+	t.Setenv("TF_WORKSPACE", "staging")
+	if ws, err := currentWorkspace(dir); err != nil || ws != "staging" {
+		t.Errorf("currentWorkspace = %q, %v, want staging", ws, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 )
 
@@ -51,3 +52,31 @@ func CreateYamlFile(fileName, content string) error {
 	fmt.Printf("✅ %s created successfully at %s\n", fileName, filePath)
 	return nil
 }
+
+// SmurfHome returns the directory smurf keeps its local state in (caches,
+// downloaded tools, user settings): $SMURF_HOME when set, otherwise ~/.smurf.
+// The directory is not created; callers create the subdirectory they need.
+func SmurfHome() (string, error) {
+	if dir := os.Getenv("SMURF_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".smurf"), nil
+}
+
+// Actor identifies who runs smurf, for deploy records and audit notes: an
+// explicit SMURF_ACTOR, the CI system's user, or the local OS user.
+func Actor() string {
+	for _, env := range []string{"SMURF_ACTOR", "GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_USER_ID", "USER"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -131,3 +131,17 @@ func TestCreateYamlFile(t *testing.T) {
 		}
 	})
 }
+
+func TestSmurfHome(t *testing.T) {
+	t.Setenv("SMURF_HOME", "/tmp/custom-smurf")
+	if got, err := SmurfHome(); err != nil || got != "/tmp/custom-smurf" {
+		t.Errorf("SmurfHome() = %q, %v; want /tmp/custom-smurf", got, err)
+	}
+
+	t.Setenv("SMURF_HOME", "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if got, err := SmurfHome(); err != nil || got != filepath.Join(home, ".smurf") {
+		t.Errorf("SmurfHome() = %q, %v; want %s", got, err, filepath.Join(home, ".smurf"))
+	}
+}